@@ -0,0 +1,99 @@
+package csicontroller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// Reasons of the attachment conditions populated on Mountpoint Pods.
+const (
+	reasonWorkloadPodActive     = "WorkloadPodActive"
+	reasonWorkloadPodTerminated = "WorkloadPodTerminated"
+	reasonWorkloadPodNotFound   = "WorkloadPodNotFound"
+)
+
+// reconcileAttachment updates attachment conditions of given Mountpoint `pod` for its workload Pod.
+func (r *Reconciler) reconcileAttachment(ctx context.Context, pod *corev1.Pod) (reconcile.Result, error) {
+	workloadPod, err := getWorkloadPod(ctx, r, pod)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to get workload Pod of Mountpoint Pod", "mountpointPod", pod.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, r.updateAttachmentConditions(ctx, pod, workloadPod)
+}
+
+// updateAttachmentConditions updates [mppod.ConditionWorkloadAttached] and [mppod.ConditionUnmountPending] conditions
+// of given Mountpoint `pod` for its `workloadPod`, which is nil if the workload Pod does not exist anymore.
+// The conditions are only patched if they are changed, and only for Mountpoint Pods that are not terminated or terminating.
+func (r *Reconciler) updateAttachmentConditions(ctx context.Context, pod *corev1.Pod, workloadPod *corev1.Pod) error {
+	log := logf.FromContext(ctx).WithValues("mountpointPod", pod.Name)
+
+	if !isPodActive(pod) {
+		return nil
+	}
+
+	attached, reason, message := corev1.ConditionFalse, reasonWorkloadPodNotFound, "Workload Pod does not exist"
+	if workloadPod != nil {
+		if isPodActive(workloadPod) {
+			attached, reason, message = corev1.ConditionTrue, reasonWorkloadPodActive, "Workload Pod is "+string(workloadPod.Status.Phase)
+		} else {
+			reason, message = reasonWorkloadPodTerminated, "Workload Pod is terminated or terminating"
+		}
+	}
+
+	unmountPending := corev1.ConditionFalse
+	if attached == corev1.ConditionFalse && pod.Status.Phase == corev1.PodRunning {
+		unmountPending = corev1.ConditionTrue
+	}
+
+	patch := client.StrategicMergeFrom(pod.DeepCopy())
+	changed := setPodCondition(pod, mppod.ConditionWorkloadAttached, attached, reason, message)
+	changed = setPodCondition(pod, mppod.ConditionUnmountPending, unmountPending, reason, message) || changed
+	if !changed {
+		return nil
+	}
+
+	if err := r.Status().Patch(ctx, pod, patch); err != nil {
+		log.Error(err, "Failed to update attachment conditions")
+		return err
+	}
+
+	log.V(debugLevel).Info("Attachment conditions updated", "workloadAttached", attached, "unmountPending", unmountPending, "reason", reason)
+	return nil
+}
+
+// setPodCondition sets the condition of `conditionType` on `pod` with given values, and returns whether it's changed.
+// The transition time of the condition is only updated if its status is changed.
+func setPodCondition(pod *corev1.Pod, conditionType corev1.PodConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+
+		if condition.Status == status && condition.Reason == reason && condition.Message == message {
+			return false
+		}
+		if condition.Status != status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Status, condition.Reason, condition.Message = status, reason, message
+		return true
+	}
+
+	pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	})
+	return true
+}
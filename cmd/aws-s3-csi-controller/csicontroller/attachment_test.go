@@ -0,0 +1,112 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestUpdatingAttachmentConditionsOfMountpointPods(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	workloadPod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"}}
+	mountpointPod := func(phase corev1.PodPhase) *corev1.Pod {
+		mpPod := mppod.NewCreator(podConfig).Create(workloadPod(corev1.PodRunning), pv)
+		mpPod.Status = corev1.PodStatus{
+			Phase:      phase,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		}
+		return mpPod
+	}
+
+	reconcileMountpointPod := func(t *testing.T, objects ...client.Object) *corev1.Pod {
+		t.Helper()
+		c := fake.NewClientBuilder().
+			WithObjects(objects...).
+			WithStatusSubresource(&corev1.Pod{}).
+			WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+				return []string{string(o.GetUID())}
+			}).
+			Build()
+		r := csicontroller.NewReconciler(c, record.NewFakeRecorder(10), podConfig, csicontroller.DefaultRestartPolicy)
+
+		mpPod := objects[len(objects)-1]
+		key := types.NamespacedName{Namespace: mpPod.GetNamespace(), Name: mpPod.GetName()}
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+		assert.NoError(t, err)
+
+		got := &corev1.Pod{}
+		assert.NoError(t, c.Get(context.Background(), key, got))
+		return got
+	}
+	assertCondition := func(t *testing.T, pod *corev1.Pod, conditionType corev1.PodConditionType, status corev1.ConditionStatus, reason string) {
+		t.Helper()
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == conditionType {
+				assert.Equals(t, status, condition.Status)
+				assert.Equals(t, reason, condition.Reason)
+				return
+			}
+		}
+		t.Fatalf("Condition %q not found in %v", conditionType, pod.Status.Conditions)
+	}
+
+	t.Run("attached to running workload Pod", func(t *testing.T) {
+		got := reconcileMountpointPod(t, workloadPod(corev1.PodRunning), mountpointPod(corev1.PodRunning))
+		assertCondition(t, got, mppod.ConditionWorkloadAttached, corev1.ConditionTrue, "WorkloadPodActive")
+		assertCondition(t, got, mppod.ConditionUnmountPending, corev1.ConditionFalse, "WorkloadPodActive")
+		// Existing conditions populated by kubelet should be kept
+		assertCondition(t, got, corev1.PodReady, corev1.ConditionTrue, "")
+	})
+
+	t.Run("pending unmount after workload Pod terminated", func(t *testing.T) {
+		got := reconcileMountpointPod(t, workloadPod(corev1.PodSucceeded), mountpointPod(corev1.PodRunning))
+		assertCondition(t, got, mppod.ConditionWorkloadAttached, corev1.ConditionFalse, "WorkloadPodTerminated")
+		assertCondition(t, got, mppod.ConditionUnmountPending, corev1.ConditionTrue, "WorkloadPodTerminated")
+	})
+
+	t.Run("workload Pod not found", func(t *testing.T) {
+		got := reconcileMountpointPod(t, mountpointPod(corev1.PodPending))
+		assertCondition(t, got, mppod.ConditionWorkloadAttached, corev1.ConditionFalse, "WorkloadPodNotFound")
+		assertCondition(t, got, mppod.ConditionUnmountPending, corev1.ConditionFalse, "WorkloadPodNotFound")
+	})
+
+	t.Run("keeps transition time if not changed", func(t *testing.T) {
+		transitioned := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		mpPod := mountpointPod(corev1.PodRunning)
+		mpPod.Status.Conditions = append(mpPod.Status.Conditions, corev1.PodCondition{
+			Type:               mppod.ConditionWorkloadAttached,
+			Status:             corev1.ConditionTrue,
+			Reason:             "WorkloadPodActive",
+			Message:            "Workload Pod is Pending",
+			LastTransitionTime: transitioned,
+		})
+
+		got := reconcileMountpointPod(t, workloadPod(corev1.PodRunning), mpPod)
+		for _, condition := range got.Status.Conditions {
+			if condition.Type == mppod.ConditionWorkloadAttached {
+				assert.Equals(t, "Workload Pod is Running", condition.Message)
+				assert.Equals(t, true, transitioned.Equal(&condition.LastTransitionTime))
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// withVolumeAttributesClass returns a copy of `pv` with the parameters of its VolumeAttributesClass applied
+// to its volume attributes, or `pv` itself if it has no VolumeAttributesClass.
+//
+// Parameters of VolumeAttributesClasses are immutable, and changing the class of a bound volume doesn't affect
+// running Mountpoint Pods, so the class a Mountpoint Pod is spawned with identifies the attributes it's running with.
+// Only [volumecontext.MutableAttributes] are applied, as the CSI Driver Controller rejects other parameters.
+func (r *Reconciler) withVolumeAttributesClass(ctx context.Context, pv *corev1.PersistentVolume) (*corev1.PersistentVolume, error) {
+	className := pv.Spec.VolumeAttributesClassName
+	if className == nil || *className == "" || pv.Spec.CSI == nil {
+		return pv, nil
+	}
+
+	vac := &storagev1beta1.VolumeAttributesClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: *className}, vac); err != nil {
+		return nil, fmt.Errorf("failed to get VolumeAttributesClass %q: %w", *className, err)
+	}
+
+	pv = pv.DeepCopy()
+	if pv.Spec.CSI.VolumeAttributes == nil {
+		pv.Spec.CSI.VolumeAttributes = map[string]string{}
+	}
+	for _, key := range volumecontext.MutableAttributes {
+		if value, ok := vac.Parameters[key]; ok {
+			pv.Spec.CSI.VolumeAttributes[key] = value
+		}
+	}
+	return pv, nil
+}
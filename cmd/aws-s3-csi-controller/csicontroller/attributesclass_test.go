@@ -0,0 +1,89 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestSpawningMountpointPodWithVolumeAttributesClass(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+			}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef:                  &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			VolumeAttributesClassName: ptr.To("large-cache"),
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       "s3.csi.aws.com",
+					VolumeHandle: "s3-csi-driver-volume",
+					VolumeAttributes: map[string]string{
+						"cacheType":         "emptyDir",
+						"cacheDirSizeLimit": "1Gi",
+					},
+				},
+			},
+		},
+	}
+	vac := &storagev1beta1.VolumeAttributesClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "large-cache"},
+		DriverName: "s3.csi.aws.com",
+		Parameters: map[string]string{"cacheDirSizeLimit": "10Gi", "bucketName": "ignored"},
+	}
+
+	c := fake.NewClientBuilder().WithObjects(workloadPod, pvc, pv).Build()
+	r := csicontroller.NewReconciler(c, record.NewFakeRecorder(10), podConfig, csicontroller.DefaultRestartPolicy)
+	reconcileWorkloadPod := func() error {
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+		return err
+	}
+
+	// Mountpoint Pods are not spawned until the VolumeAttributesClass of the volume exists
+	if err := reconcileWorkloadPod(); err == nil {
+		t.Fatalf("Expected reconciling to fail without the VolumeAttributesClass")
+	}
+	assert.NoError(t, c.Create(context.Background(), vac))
+	assert.NoError(t, reconcileWorkloadPod())
+
+	mpPod := &corev1.Pod{}
+	mpPodName := mppod.MountpointPodNameFor("workload-uid", "s3-pv")
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: mpPodName}, mpPod))
+	assert.Equals(t, "large-cache", mpPod.Annotations[mppod.AnnotationVolumeAttributesClass])
+
+	for _, volume := range mpPod.Spec.Volumes {
+		if volume.Name == "cache" {
+			assert.Equals(t, resource.MustParse("10Gi"), *volume.EmptyDir.SizeLimit)
+			return
+		}
+	}
+	t.Fatalf("Expected Mountpoint Pod to have a cache volume, got %v", mpPod.Spec.Volumes)
+}
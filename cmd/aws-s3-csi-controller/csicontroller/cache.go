@@ -0,0 +1,90 @@
+package csicontroller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// PodCacheOptions returns the cache options for Pods, which only caches the details of Pods the controller acts on.
+//
+// Pods in `mountpointNamespace` (i.e., Mountpoint and headroom Pods) are cached as is. In other namespaces, only
+// scheduled Pods are cached as the controller ignores unscheduled Pods, and Pods without any PVC-backed volumes
+// are trimmed to their metadata, phase and resources (see [trimIrrelevantPod]), which drastically lowers memory usage
+// on clusters with many Pods that never use S3 volumes.
+func PodCacheOptions(mountpointNamespace string) cache.ByObject {
+	return cache.ByObject{
+		Namespaces: map[string]cache.Config{
+			mountpointNamespace: {},
+			cache.AllNamespaces: {
+				FieldSelector: fields.OneTermNotEqualSelector("spec.nodeName", ""),
+				Transform:     trimIrrelevantPod,
+			},
+		},
+	}
+}
+
+// trimIrrelevantPod trims given Pod to its metadata, phase and resources if it does not have any PVC-backed volumes,
+// as the controller only spawns Mountpoint Pods for volumes backed by PVCs. Other Pods are only stripped of their
+// managed fields.
+//
+// The kept fields are the ones cached readers of any Pod rely on: the phase and deletion timestamp to tell whether
+// the Pod is active, and the resources of its containers and init containers (including their restart policy,
+// which makes init containers sidecars) and its overhead, to compute free resources of nodes in [podRequests].
+// Readers needing any other field of Pods without PVC-backed volumes, e.g. their labels or volumes, must read them
+// with the API reader of the manager instead, which bypasses the cache.
+func trimIrrelevantPod(obj any) (any, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	if hasClaimVolumes(pod) {
+		return cache.TransformStripManagedFields()(pod)
+	}
+
+	return &corev1.Pod{
+		TypeMeta: pod.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              pod.Name,
+			Namespace:         pod.Namespace,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			CreationTimestamp: pod.CreationTimestamp,
+			DeletionTimestamp: pod.DeletionTimestamp,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:       pod.Spec.NodeName,
+			Containers:     trimContainers(pod.Spec.Containers),
+			InitContainers: trimContainers(pod.Spec.InitContainers),
+			Overhead:       pod.Spec.Overhead,
+		},
+		Status: corev1.PodStatus{Phase: pod.Status.Phase},
+	}, nil
+}
+
+// trimContainers trims given `containers` to their names, resources and restart policies.
+func trimContainers(containers []corev1.Container) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+	trimmed := make([]corev1.Container, len(containers))
+	for i, container := range containers {
+		trimmed[i] = corev1.Container{
+			Name:          container.Name,
+			Resources:     container.Resources,
+			RestartPolicy: container.RestartPolicy,
+		}
+	}
+	return trimmed
+}
+
+// hasClaimVolumes returns whether given `pod` has any volumes backed by PVCs, including generic ephemeral volumes.
+func hasClaimVolumes(pod *corev1.Pod) bool {
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim != nil || vol.Ephemeral != nil {
+			return true
+		}
+	}
+	return false
+}
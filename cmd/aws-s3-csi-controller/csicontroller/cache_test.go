@@ -0,0 +1,98 @@
+package csicontroller_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestPodCacheOptions(t *testing.T) {
+	options := csicontroller.PodCacheOptions(mountpointNamespace)
+
+	mountpointNamespaceConfig, ok := options.Namespaces[mountpointNamespace]
+	assert.Equals(t, true, ok)
+	assert.Equals(t, true, mountpointNamespaceConfig.FieldSelector == nil)
+
+	config := options.Namespaces[cache.AllNamespaces]
+	assert.Equals(t, "spec.nodeName!=", config.FieldSelector.String())
+
+	deletionTimestamp := metav1.Now()
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+	sidecarResources := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}}
+	overhead := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")}
+	newPod := func(volumes ...corev1.Volume) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "workload",
+				Namespace:         "default",
+				UID:               "workload-uid",
+				DeletionTimestamp: &deletionTimestamp,
+				Labels:            map[string]string{"app": "workload"},
+				ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				InitContainers: []corev1.Container{{
+					Name:          "sidecar",
+					Image:         "busybox",
+					RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+					Resources:     sidecarResources,
+				}},
+				Containers: []corev1.Container{{
+					Name:      "app",
+					Image:     "busybox",
+					Resources: resources,
+				}},
+				Overhead: overhead,
+				Volumes:  volumes,
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+		}
+	}
+
+	t.Run("trims Pods without PVC-backed volumes to the fields cached readers need", func(t *testing.T) {
+		got, err := config.Transform(newPod(corev1.Volume{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}))
+		assert.NoError(t, err)
+		assert.Equals(t, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid", DeletionTimestamp: &deletionTimestamp},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				InitContainers: []corev1.Container{{
+					Name:          "sidecar",
+					RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+					Resources:     sidecarResources,
+				}},
+				Containers: []corev1.Container{{
+					Name:      "app",
+					Resources: resources,
+				}},
+				Overhead: overhead,
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}, got)
+	})
+
+	t.Run("keeps Pods with PVC-backed volumes", func(t *testing.T) {
+		for _, source := range []corev1.VolumeSource{
+			{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"}},
+			{Ephemeral: &corev1.EphemeralVolumeSource{}},
+		} {
+			pod := newPod(corev1.Volume{Name: "data", VolumeSource: source})
+			got, err := config.Transform(pod.DeepCopy())
+			assert.NoError(t, err)
+
+			pod.ManagedFields = nil
+			assert.Equals(t, pod, got)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+)
+
+// DefaultCloneCommand is the default command of clone Jobs, it's the controller's binary running in clone mode.
+const DefaultCloneCommand = "/bin/aws-s3-csi-controller"
+
+// AnnotationCloneTotalObjects is the annotation of clone Jobs recording the number of objects in the source volume
+// at the time cloning started, to report progress without listing the source volume again.
+const AnnotationCloneTotalObjects = "s3.csi.aws.com/clone-total-objects"
+
+// cloneJobBackoffLimit is the number of times a clone Job retries copying before it fails.
+const cloneJobBackoffLimit = 3
+
+// Reasons of the events recorded on PersistentVolumeClaims of cloned volumes.
+const (
+	eventReasonCloneStarted  = "CloneStarted"
+	eventReasonCloneProgress = "CloneProgress"
+	eventReasonCloneFailed   = "CloneFailed"
+	eventReasonCloneComplete = "CloneComplete"
+)
+
+// A CloneJobConfig configures Jobs cloning volumes.
+type CloneJobConfig struct {
+	Namespace          string
+	Image              string
+	ImagePullPolicy    corev1.PullPolicy
+	Command            string
+	ServiceAccountName string
+}
+
+// CloneJobs is a [controller.Cloner] that clones each volume with a Kubernetes Job, which copies the objects of
+// the source volume into the new volume server-side with S3 `CopyObject`, so the objects are not downloaded.
+//
+// The progress is recorded as events on the PersistentVolumeClaim of the new volume on every retry of
+// `CreateVolume` until the Job completes, by counting the objects copied into the new volume so far.
+// Failed Jobs are deleted and re-created on the next retry, and completed Jobs are deleted once reported.
+type CloneJobs struct {
+	client   client.Client
+	recorder record.EventRecorder
+	s3       controller.S3Client
+	config   CloneJobConfig
+}
+
+var _ controller.Cloner = &CloneJobs{}
+
+// NewCloneJobs returns a new [CloneJobs] creating Jobs with `config`, and counting objects via `s3`.
+func NewCloneJobs(client client.Client, recorder record.EventRecorder, s3 controller.S3Client, config CloneJobConfig) *CloneJobs {
+	if config.Command == "" {
+		config.Command = DefaultCloneCommand
+	}
+	return &CloneJobs{client: client, recorder: recorder, s3: s3, config: config}
+}
+
+// Clone implements [controller.Cloner].
+func (c *CloneJobs) Clone(ctx context.Context, req controller.CloneRequest) (controller.CloneStatus, error) {
+	log := logf.FromContext(ctx).WithValues("volume", req.VolumeName, "source", req.Source.String())
+	pvc := c.pvc(ctx, req)
+
+	job := &batchv1.Job{}
+	err := c.client.Get(ctx, types.NamespacedName{Namespace: c.config.Namespace, Name: CloneJobName(req.VolumeName)}, job)
+	if apierrors.IsNotFound(err) {
+		return c.start(ctx, req, pvc)
+	}
+	if err != nil {
+		return controller.CloneStatus{}, fmt.Errorf("failed to get clone Job: %w", err)
+	}
+
+	total, _ := strconv.ParseInt(job.Annotations[AnnotationCloneTotalObjects], 10, 64)
+	switch {
+	case jobHasCondition(job, batchv1.JobFailed):
+		log.Info("Clone Job failed, deleting it to retry cloning", "job", job.Name)
+		if err := c.deleteJob(ctx, job); err != nil {
+			return controller.CloneStatus{}, err
+		}
+		c.event(pvc, corev1.EventTypeWarning, eventReasonCloneFailed,
+			"Job %s/%s cloning volume %s failed, cloning is retried. See logs of its Pods for details", job.Namespace, job.Name, req.Source)
+		return controller.CloneStatus{Failure: fmt.Sprintf("clone Job %s/%s failed", job.Namespace, job.Name), TotalObjects: total}, nil
+	case jobHasCondition(job, batchv1.JobComplete):
+		log.Info("Clone Job completed", "job", job.Name)
+		if err := c.deleteJob(ctx, job); err != nil {
+			return controller.CloneStatus{}, err
+		}
+		c.event(pvc, corev1.EventTypeNormal, eventReasonCloneComplete, "Cloned %d objects of volume %s", total, req.Source)
+		return controller.CloneStatus{Done: true, CopiedObjects: total, TotalObjects: total}, nil
+	}
+
+	usage, err := c.s3.PrefixUsage(ctx, req.Target.Bucket, req.Target.Prefix, 0)
+	if err != nil {
+		return controller.CloneStatus{}, fmt.Errorf("failed to count copied objects in %s: %w", req.Target, err)
+	}
+	c.event(pvc, corev1.EventTypeNormal, eventReasonCloneProgress, "Copied %d of %d objects of volume %s", usage.Objects, total, req.Source)
+	return controller.CloneStatus{CopiedObjects: usage.Objects, TotalObjects: total}, nil
+}
+
+// start creates a Job to clone the volume in `req`.
+func (c *CloneJobs) start(ctx context.Context, req controller.CloneRequest, pvc *corev1.PersistentVolumeClaim) (controller.CloneStatus, error) {
+	usage, err := c.s3.PrefixUsage(ctx, req.Source.Bucket, req.Source.Prefix, 0)
+	if err != nil {
+		return controller.CloneStatus{}, fmt.Errorf("failed to count objects in %s: %w", req.Source, err)
+	}
+
+	job := c.job(req, usage.Objects)
+	if err := c.client.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return controller.CloneStatus{}, fmt.Errorf("failed to create clone Job: %w", err)
+	}
+	logf.FromContext(ctx).Info("Created clone Job", "job", job.Name, "volume", req.VolumeName, "source", req.Source.String(), "objects", usage.Objects)
+	c.event(pvc, corev1.EventTypeNormal, eventReasonCloneStarted,
+		"Cloning %d objects (%d bytes) of volume %s with Job %s/%s", usage.Objects, usage.Bytes, req.Source, job.Namespace, job.Name)
+
+	return controller.CloneStatus{TotalObjects: usage.Objects}, nil
+}
+
+// job returns a Job copying the objects of the source volume in `req` into the new volume.
+func (c *CloneJobs) job(req controller.CloneRequest, totalObjects int64) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CloneJobName(req.VolumeName),
+			Namespace: c.config.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "aws-s3-csi-clone",
+				"app.kubernetes.io/managed-by": Name,
+			},
+			Annotations: map[string]string{
+				AnnotationCloneTotalObjects: strconv.FormatInt(totalObjects, 10),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To(int32(cloneJobBackoffLimit)),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: c.config.ServiceAccountName,
+					Containers: []corev1.Container{{
+						Name:            "clone",
+						Image:           c.config.Image,
+						ImagePullPolicy: c.config.ImagePullPolicy,
+						Command:         []string{c.config.Command},
+						Args: []string{
+							"--clone-from=" + req.Source.String(),
+							"--clone-to=" + req.Target.String(),
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: ptr.To(false),
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+							SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// deleteJob deletes `job` with its Pods.
+func (c *CloneJobs) deleteJob(ctx context.Context, job *batchv1.Job) error {
+	err := c.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete clone Job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	return nil
+}
+
+// pvc returns the PersistentVolumeClaim of the new volume in `req` to record events on,
+// or nil if it's not known or it can't be found.
+func (c *CloneJobs) pvc(ctx context.Context, req controller.CloneRequest) *corev1.PersistentVolumeClaim {
+	if req.PVCNamespace == "" || req.PVCName == "" {
+		return nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: req.PVCNamespace, Name: req.PVCName}, pvc); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to get PersistentVolumeClaim to record clone progress", "pvc", req.PVCNamespace+"/"+req.PVCName)
+		return nil
+	}
+	return pvc
+}
+
+// event records an event on `pvc` if it's known.
+func (c *CloneJobs) event(pvc *corev1.PersistentVolumeClaim, eventType, reason, messageFmt string, args ...any) {
+	if pvc != nil {
+		c.recorder.Eventf(pvc, eventType, reason, messageFmt, args...)
+	}
+}
+
+// CloneJobName returns the name of the Job cloning a volume into the volume named `volumeName`.
+func CloneJobName(volumeName string) string {
+	return "s3-csi-clone-" + volumeName
+}
+
+// jobHasCondition returns whether `job` has the condition of `conditionType` with true status.
+func jobHasCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
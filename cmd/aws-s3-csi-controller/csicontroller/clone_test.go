@@ -0,0 +1,132 @@
+package csicontroller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCloneJobs(t *testing.T) {
+	ctx := context.Background()
+	req := controller.CloneRequest{
+		VolumeName:   "pvc-9999",
+		Source:       controller.Location{Bucket: "shared-bucket", Prefix: "pvc-1234/"},
+		Target:       controller.Location{Bucket: "shared-bucket", Prefix: "pvc-9999/"},
+		PVCNamespace: "default",
+		PVCName:      "clone",
+	}
+	jobKey := types.NamespacedName{Namespace: "kube-system", Name: "s3-csi-clone-pvc-9999"}
+
+	setup := func() (*csicontroller.CloneJobs, *record.FakeRecorder, func() *batchv1.Job, func(batchv1.JobConditionType)) {
+		c := fake.NewClientBuilder().WithObjects(&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "clone"},
+		}).Build()
+		s3 := &fakeUsageS3Client{usage: map[string]controller.Usage{
+			"shared-bucket/pvc-1234/": {Objects: 10, Bytes: 1024},
+			"shared-bucket/pvc-9999/": {Objects: 4, Bytes: 512},
+		}}
+		recorder := record.NewFakeRecorder(10)
+		cloner := csicontroller.NewCloneJobs(c, recorder, s3, csicontroller.CloneJobConfig{
+			Namespace:          "kube-system",
+			Image:              "aws-s3-csi-controller:latest",
+			ServiceAccountName: "s3-csi-controller-sa",
+		})
+
+		getJob := func() *batchv1.Job {
+			job := &batchv1.Job{}
+			if err := c.Get(ctx, jobKey, job); err != nil {
+				return nil
+			}
+			return job
+		}
+		finishJob := func(conditionType batchv1.JobConditionType) {
+			job := getJob()
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{Type: conditionType, Status: corev1.ConditionTrue})
+			assert.NoError(t, c.Status().Update(ctx, job))
+		}
+		return cloner, recorder, getJob, finishJob
+	}
+
+	t.Run("Reports progress until the Job completes", func(t *testing.T) {
+		cloner, recorder, getJob, finishJob := setup()
+
+		status, err := cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		assert.Equals(t, controller.CloneStatus{TotalObjects: 10}, status)
+		assertEvent(t, recorder, "Normal CloneStarted Cloning 10 objects (1024 bytes) of volume shared-bucket/pvc-1234/ with Job kube-system/s3-csi-clone-pvc-9999")
+
+		job := getJob()
+		container := job.Spec.Template.Spec.Containers[0]
+		assert.Equals(t, "aws-s3-csi-controller:latest", container.Image)
+		assert.Equals(t, []string{csicontroller.DefaultCloneCommand}, container.Command)
+		assert.Equals(t, []string{"--clone-from=shared-bucket/pvc-1234/", "--clone-to=shared-bucket/pvc-9999/"}, container.Args)
+		assert.Equals(t, "s3-csi-controller-sa", job.Spec.Template.Spec.ServiceAccountName)
+		assert.Equals(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+
+		status, err = cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		assert.Equals(t, controller.CloneStatus{CopiedObjects: 4, TotalObjects: 10}, status)
+		assertEvent(t, recorder, "Normal CloneProgress Copied 4 of 10 objects of volume shared-bucket/pvc-1234/")
+
+		finishJob(batchv1.JobComplete)
+		status, err = cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		assert.Equals(t, controller.CloneStatus{Done: true, CopiedObjects: 10, TotalObjects: 10}, status)
+		assertEvent(t, recorder, "Normal CloneComplete Cloned 10 objects of volume shared-bucket/pvc-1234/")
+		assert.Equals(t, (*batchv1.Job)(nil), getJob())
+	})
+
+	t.Run("Restarts failed Jobs", func(t *testing.T) {
+		cloner, recorder, getJob, finishJob := setup()
+
+		_, err := cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		<-recorder.Events
+
+		finishJob(batchv1.JobFailed)
+		status, err := cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		assert.Equals(t, "clone Job kube-system/s3-csi-clone-pvc-9999 failed", status.Failure)
+		assertEvent(t, recorder, "Warning CloneFailed Job kube-system/s3-csi-clone-pvc-9999 cloning volume shared-bucket/pvc-1234/ failed")
+		assert.Equals(t, (*batchv1.Job)(nil), getJob())
+
+		_, err = cloner.Clone(ctx, req)
+		assert.NoError(t, err)
+		assertEvent(t, recorder, "Normal CloneStarted")
+		if getJob() == nil {
+			t.Fatal("Expected the clone Job to be re-created")
+		}
+	})
+
+	t.Run("Does not record events without PVC", func(t *testing.T) {
+		cloner, recorder, _, _ := setup()
+
+		_, err := cloner.Clone(ctx, controller.CloneRequest{VolumeName: req.VolumeName, Source: req.Source, Target: req.Target})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, len(recorder.Events))
+	})
+}
+
+// assertEvent asserts that the next event in `recorder` starts with `prefix`.
+func assertEvent(t *testing.T, recorder *record.FakeRecorder, prefix string) {
+	t.Helper()
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, prefix) {
+			t.Fatalf("Expected event starting with %q, got %q", prefix, event)
+		}
+	default:
+		t.Fatalf("Expected event starting with %q, got none", prefix)
+	}
+}
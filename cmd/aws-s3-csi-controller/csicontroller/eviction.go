@@ -0,0 +1,97 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// EvictionWebhookPath is the path the eviction webhook is served at.
+const EvictionWebhookPath = "/validate-mountpoint-pod-eviction"
+
+// An EvictionValidator validates evictions of Mountpoint Pods, for example during a node drain.
+//
+// If a Mountpoint Pod gets evicted before the workload Pod using it, the workload Pod's mount breaks in the middle of
+// its teardown. It's not possible to prevent that with finalizers as they don't stop kubelet from terminating containers
+// of a Pod scheduled for deletion, so this validator rejects evictions of Mountpoint Pods with "429 Too Many Requests"
+// until their workload Pods are terminated. Eviction clients, like `kubectl drain`, retry such evictions
+// similar to evictions blocked by PodDisruptionBudgets.
+type EvictionValidator struct {
+	client.Reader
+	mountpointNamespace string
+}
+
+// NewEvictionValidator returns a new eviction validator for Mountpoint Pods in given `mountpointNamespace`.
+func NewEvictionValidator(reader client.Reader, mountpointNamespace string) *EvictionValidator {
+	return &EvictionValidator{Reader: reader, mountpointNamespace: mountpointNamespace}
+}
+
+// SetupWithManager registers the validator as a webhook on given `mgr`'s webhook server.
+// The webhook needs to be configured with a ValidatingWebhookConfiguration for `CREATE` operations on `pods/eviction`.
+// It relies on the index of Pods by UID registered by [Reconciler.SetupWithManager].
+func (v *EvictionValidator) SetupWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(EvictionWebhookPath, &webhook.Admission{Handler: v})
+}
+
+// Handle decides whether the eviction in `req` is allowed.
+func (v *EvictionValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create || req.SubResource != "eviction" || req.Namespace != v.mountpointNamespace {
+		return admission.Allowed("")
+	}
+
+	log := logf.FromContext(ctx).WithValues("mountpointPod", req.Name)
+
+	mpPod := &corev1.Pod{}
+	err := v.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, mpPod)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Allowed("Mountpoint Pod not found")
+		}
+		log.Error(err, "Failed to get Mountpoint Pod")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// Mountpoint Pod is already terminated, there is no mount to break.
+	if !isPodActive(mpPod) {
+		return admission.Allowed("Mountpoint Pod is not running")
+	}
+
+	workloadPodUID := mpPod.Labels[mppod.LabelPodUID]
+	if workloadPodUID == "" {
+		return admission.Allowed("Mountpoint Pod has no workload Pod")
+	}
+
+	workloadPods := &corev1.PodList{}
+	err = v.List(ctx, workloadPods, client.MatchingFields{podUIDIndexField: workloadPodUID})
+	if err != nil {
+		log.Error(err, "Failed to get workload Pod", "workloadPodUID", workloadPodUID)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for _, workloadPod := range workloadPods.Items {
+		// A workload Pod that is still running might be using the mount provided by the Mountpoint Pod,
+		// even if it's scheduled for termination.
+		if workloadPod.Status.Phase == corev1.PodRunning {
+			mountpointPodEvictionsRejectedTotal.Inc()
+			log.Info("Rejecting eviction of Mountpoint Pod as its workload Pod is still running",
+				"workloadPod", types.NamespacedName{Namespace: workloadPod.Namespace, Name: workloadPod.Name})
+			return admission.Errored(http.StatusTooManyRequests,
+				fmt.Errorf("Mountpoint Pod is still in use by workload Pod %s/%s, it can be evicted after the workload Pod is terminated",
+					workloadPod.Namespace, workloadPod.Name))
+		}
+	}
+
+	return admission.Allowed("Workload Pod is not running")
+}
@@ -0,0 +1,105 @@
+package csicontroller_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+const mountpointNamespace = "mount-s3"
+
+func TestEvictionValidator(t *testing.T) {
+	workloadPod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	mountpointPod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mp-pod",
+				Namespace: mountpointNamespace,
+				Labels:    map[string]string{mppod.LabelPodUID: "workload-uid"},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	evictionRequest := func(namespace, name string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation:   admissionv1.Create,
+			SubResource: "eviction",
+			Namespace:   namespace,
+			Name:        name,
+		}}
+	}
+
+	testCases := []struct {
+		name       string
+		objects    []client.Object
+		req        admission.Request
+		allowed    bool
+		rejectCode int32
+	}{
+		{
+			name:       "rejects eviction if workload Pod is running",
+			objects:    []client.Object{mountpointPod(corev1.PodRunning), workloadPod(corev1.PodRunning)},
+			req:        evictionRequest(mountpointNamespace, "mp-pod"),
+			allowed:    false,
+			rejectCode: http.StatusTooManyRequests,
+		},
+		{
+			name:    "allows eviction if workload Pod is terminated",
+			objects: []client.Object{mountpointPod(corev1.PodRunning), workloadPod(corev1.PodSucceeded)},
+			req:     evictionRequest(mountpointNamespace, "mp-pod"),
+			allowed: true,
+		},
+		{
+			name:    "allows eviction if workload Pod does not exist",
+			objects: []client.Object{mountpointPod(corev1.PodRunning)},
+			req:     evictionRequest(mountpointNamespace, "mp-pod"),
+			allowed: true,
+		},
+		{
+			name:    "allows eviction if Mountpoint Pod is terminated",
+			objects: []client.Object{mountpointPod(corev1.PodFailed), workloadPod(corev1.PodRunning)},
+			req:     evictionRequest(mountpointNamespace, "mp-pod"),
+			allowed: true,
+		},
+		{
+			name:    "allows eviction of Pods in other namespaces",
+			objects: []client.Object{workloadPod(corev1.PodRunning)},
+			req:     evictionRequest("default", "workload"),
+			allowed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithObjects(tc.objects...).
+				WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+					return []string{string(o.GetUID())}
+				}).
+				Build()
+			validator := csicontroller.NewEvictionValidator(client, mountpointNamespace)
+
+			resp := validator.Handle(context.Background(), tc.req)
+			assert.Equals(t, tc.allowed, resp.Allowed)
+			if !tc.allowed {
+				assert.Equals(t, tc.rejectCode, resp.Result.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package csicontroller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// requestsForMountpointPod maps events of Mountpoint Pods to requests of their workload Pods.
+//
+// A workload Pod and the Mountpoint Pods providing its volumes are reconciled together in a single pass keyed by the
+// workload Pod, so a burst of events from a workload Pod and its Mountpoint Pods is deduplicated by the work queue,
+// and a workload Pod and its Mountpoint Pods are never reconciled concurrently by different workers, which would
+// otherwise race to spawn and respawn the same Mountpoint Pods.
+//
+// Mountpoint Pods are only reconciled on their own if their workload Pods do not exist anymore.
+func (r *Reconciler) requestsForMountpointPod(ctx context.Context, o client.Object) []reconcile.Request {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}}
+
+	workloadPod, err := getWorkloadPod(ctx, r, o)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to get workload Pod of Mountpoint Pod", "mountpointPod", o.GetName())
+		return []reconcile.Request{request}
+	}
+	if workloadPod == nil {
+		return []reconcile.Request{request}
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: workloadPod.Namespace, Name: workloadPod.Name}}}
+}
+
+// isMountpointPodObject returns whether given object is a Mountpoint Pod, see [Reconciler.isMountpointPod].
+func (r *Reconciler) isMountpointPodObject(o client.Object) bool {
+	pod, ok := o.(*corev1.Pod)
+	return ok && r.isMountpointPod(pod)
+}
+
+// mergeResults merges results of reconciling multiple Mountpoint Pods of a workload Pod,
+// so the workload Pod is requeued after the earliest requested time.
+func mergeResults(a, b reconcile.Result) reconcile.Result {
+	merged := reconcile.Result{Requeue: a.Requeue || b.Requeue}
+	switch {
+	case a.RequeueAfter == 0:
+		merged.RequeueAfter = b.RequeueAfter
+	case b.RequeueAfter == 0:
+		merged.RequeueAfter = a.RequeueAfter
+	default:
+		merged.RequeueAfter = min(a.RequeueAfter, b.RequeueAfter)
+	}
+	return merged
+}
@@ -0,0 +1,181 @@
+package csicontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// LabelHeadroom is populated on headroom Pods, which are not Mountpoint Pods even though they're in the same namespace.
+const LabelHeadroom = "s3.csi.aws.com/headroom"
+
+// HeadroomDaemonSetName is the name of the DaemonSet running headroom Pods in the Mountpoint Pods' namespace.
+const HeadroomDaemonSetName = "mountpoint-headroom"
+
+// DefaultHeadroomImage is the default image of headroom Pods, they only need to reserve resources.
+const DefaultHeadroomImage = "registry.k8s.io/pause:3.10"
+
+// annotationHeadroomTemplateHash is populated on the headroom DaemonSet with the hash of its desired Pod template.
+const annotationHeadroomTemplateHash = "s3.csi.aws.com/headroom-template-hash"
+
+// headroomSyncInterval is the interval to re-sync the headroom DaemonSet, e.g. to pick up new resource profiles.
+const headroomSyncInterval = time.Minute
+
+// A HeadroomPolicy configures how much room to reserve in each node for future Mountpoint Pods.
+type HeadroomPolicy struct {
+	// MountpointPodsPerNode is the number of Mountpoint Pods to reserve room for in each node.
+	MountpointPodsPerNode int
+	// PriorityClassName is the priority class of headroom Pods,
+	// its value must be lower than the priority of Mountpoint Pods for them to preempt headroom Pods.
+	PriorityClassName string
+	// Image is the image of headroom Pods.
+	Image string
+	// NodeSelector is the node selector of headroom Pods, which should match the node selector of Mountpoint Pods.
+	NodeSelector map[string]string
+}
+
+// A HeadroomManager reserves room for future Mountpoint Pods in each node using a DaemonSet of low-priority headroom Pods.
+//
+// Without headroom, workload Pods might fill up a node and leave no room for their Mountpoint Pods, which then stay
+// `Pending` as they must run in the same node as their workload Pods. Headroom Pods make the scheduler and cluster
+// autoscaler account for the resources of future Mountpoint Pods while placing workload Pods, and they get preempted
+// once Mountpoint Pods are spawned. Headroom Pods are sized using the current resources of Mountpoint Pods.
+type HeadroomManager struct {
+	namespace string
+	policy    HeadroomPolicy
+	creator   *mppod.Creator
+
+	client.Client
+}
+
+// NewHeadroomManager returns a new headroom manager running headroom Pods in `namespace` according to `policy`,
+// sized using resources of Mountpoint Pods created by `creator`.
+func NewHeadroomManager(client client.Client, creator *mppod.Creator, namespace string, policy HeadroomPolicy) *HeadroomManager {
+	return &HeadroomManager{Client: client, creator: creator, namespace: namespace, policy: policy}
+}
+
+// Start syncs the headroom DaemonSet periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (m *HeadroomManager) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("headroom-manager")
+
+	ticker := time.NewTicker(headroomSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Sync(ctx); err != nil {
+			log.Error(err, "Failed to sync headroom DaemonSet")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Sync creates or updates the headroom DaemonSet once to match the policy and current resources of Mountpoint Pods.
+func (m *HeadroomManager) Sync(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("headroom-manager")
+
+	resources := m.creator.Resources()
+	if resources == nil || len(resources.Requests) == 0 {
+		log.Info("Mountpoint Pods have no resource requests yet, not reserving headroom")
+		return nil
+	}
+
+	desired := m.daemonSet(resources.Requests)
+
+	existing := &appsv1.DaemonSet{}
+	err := m.Get(ctx, types.NamespacedName{Namespace: m.namespace, Name: HeadroomDaemonSetName}, existing)
+	if apierrors.IsNotFound(err) {
+		if err := m.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create headroom DaemonSet: %w", err)
+		}
+		log.Info("Headroom DaemonSet created", "mountpointPodsPerNode", m.policy.MountpointPodsPerNode, "requests", resources.Requests)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get headroom DaemonSet: %w", err)
+	}
+
+	// The API server populates default values in the template, so it's compared using the hash of the desired template.
+	if existing.Annotations[annotationHeadroomTemplateHash] == desired.Annotations[annotationHeadroomTemplateHash] {
+		return nil
+	}
+
+	existing.Spec.Template = desired.Spec.Template
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[annotationHeadroomTemplateHash] = desired.Annotations[annotationHeadroomTemplateHash]
+	if err := m.Update(ctx, existing); err != nil {
+		return fmt.Errorf("failed to update headroom DaemonSet: %w", err)
+	}
+	log.Info("Headroom DaemonSet updated", "mountpointPodsPerNode", m.policy.MountpointPodsPerNode, "requests", resources.Requests)
+	return nil
+}
+
+// daemonSet returns the desired headroom DaemonSet with a container per Mountpoint Pod to reserve room for,
+// each requesting `requests`.
+func (m *HeadroomManager) daemonSet(requests corev1.ResourceList) *appsv1.DaemonSet {
+	labels := map[string]string{LabelHeadroom: "true"}
+
+	containers := make([]corev1.Container, m.policy.MountpointPodsPerNode)
+	for i := range containers {
+		containers[i] = corev1.Container{
+			Name:      fmt.Sprintf("headroom-%d", i),
+			Image:     m.policy.Image,
+			Resources: corev1.ResourceRequirements{Requests: requests.DeepCopy()},
+			SecurityContext: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: ptr.To(false),
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			},
+		}
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers:                    containers,
+			PriorityClassName:             m.policy.PriorityClassName,
+			NodeSelector:                  m.policy.NodeSelector,
+			TerminationGracePeriodSeconds: ptr.To[int64](0),
+		},
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        HeadroomDaemonSetName,
+			Namespace:   m.namespace,
+			Labels:      labels,
+			Annotations: map[string]string{annotationHeadroomTemplateHash: templateHash(template)},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: template,
+		},
+	}
+}
+
+// templateHash returns a hash of `template` to detect changes in the desired headroom Pods.
+func templateHash(template corev1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	hash := fnv.New64a()
+	hash.Write(data)
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
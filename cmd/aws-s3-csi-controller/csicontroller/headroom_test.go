@@ -0,0 +1,78 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestReservingHeadroomForMountpointPods(t *testing.T) {
+	policy := csicontroller.HeadroomPolicy{
+		MountpointPodsPerNode: 2,
+		PriorityClassName:     "mountpoint-headroom",
+		Image:                 csicontroller.DefaultHeadroomImage,
+		NodeSelector:          map[string]string{"s3-cache": "nvme"},
+	}
+	key := types.NamespacedName{Namespace: mountpointNamespace, Name: csicontroller.HeadroomDaemonSetName}
+	resources := func(memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		}
+	}
+
+	t.Run("no resources", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		m := csicontroller.NewHeadroomManager(c, mppod.NewCreator(mppod.Config{Namespace: mountpointNamespace}), mountpointNamespace, policy)
+
+		assert.NoError(t, m.Sync(context.Background()))
+		err := c.Get(context.Background(), key, &appsv1.DaemonSet{})
+		assert.Equals(t, true, apierrors.IsNotFound(err))
+	})
+
+	t.Run("creates and updates DaemonSet", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		creator := mppod.NewCreator(mppod.Config{Namespace: mountpointNamespace})
+		creator.SetResources(resources("512Mi"))
+		m := csicontroller.NewHeadroomManager(c, creator, mountpointNamespace, policy)
+
+		assert.NoError(t, m.Sync(context.Background()))
+		ds := &appsv1.DaemonSet{}
+		assert.NoError(t, c.Get(context.Background(), key, ds))
+
+		podSpec := ds.Spec.Template.Spec
+		assert.Equals(t, "mountpoint-headroom", podSpec.PriorityClassName)
+		assert.Equals(t, map[string]string{"s3-cache": "nvme"}, podSpec.NodeSelector)
+		assert.Equals(t, 2, len(podSpec.Containers))
+		for _, container := range podSpec.Containers {
+			assert.Equals(t, csicontroller.DefaultHeadroomImage, container.Image)
+			assert.Equals(t, resources("512Mi").Requests, container.Resources.Requests)
+			assert.Equals(t, corev1.ResourceList(nil), container.Resources.Limits)
+		}
+		assert.Equals(t, "true", ds.Spec.Template.Labels[csicontroller.LabelHeadroom])
+		resourceVersion := ds.ResourceVersion
+
+		// Syncing without any changes should not update the DaemonSet
+		assert.NoError(t, m.Sync(context.Background()))
+		assert.NoError(t, c.Get(context.Background(), key, ds))
+		assert.Equals(t, resourceVersion, ds.ResourceVersion)
+
+		creator.SetResources(resources("1Gi"))
+		assert.NoError(t, m.Sync(context.Background()))
+		assert.NoError(t, c.Get(context.Background(), key, ds))
+		assert.Equals(t, resource.MustParse("1Gi"), ds.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory])
+	})
+}
@@ -0,0 +1,209 @@
+package csicontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// LabelImagePuller is populated on image puller Pods, which are not Mountpoint Pods even though they're in the same namespace.
+const LabelImagePuller = "s3.csi.aws.com/image-puller"
+
+// imagePullerNamePrefix is the prefix of the names of image puller Pods, followed by a hash of their node and image.
+const imagePullerNamePrefix = "mp-image-puller-"
+
+// imagePullerSyncInterval is the interval to look for pending workload Pods nominated to nodes.
+const imagePullerSyncInterval = 10 * time.Second
+
+// An ImagePuller pulls Mountpoint images into nodes that pending workload Pods using S3 volumes are nominated to,
+// so their Mountpoint Pods don't wait for the image once the workload Pods are scheduled.
+//
+// The scheduler nominates a node to a Pod before it's bound there, e.g. while it preempts other Pods to make room for it.
+// For each such Pod, an image puller Pod is created in the nominated node for the image of each Mountpoint Pod the
+// Pod would get. Image puller Pods run the Mountpoint image with `--help`, which exits right away, and they're kept
+// while workload Pods are nominated to their node so the image is not pulled again, and deleted afterwards.
+// Image puller Pods are not created if the node already reports the image in its status.
+type ImagePuller struct {
+	reconciler *Reconciler
+	// reader reads objects directly from the API server, as unscheduled Pods and Nodes are not cached.
+	reader client.Reader
+}
+
+// NewImagePuller returns a new image puller creating image puller Pods for the Mountpoint Pods `reconciler` would spawn.
+// Pending workload Pods and nodes are read with `reader`.
+func NewImagePuller(reconciler *Reconciler, reader client.Reader) *ImagePuller {
+	return &ImagePuller{reconciler: reconciler, reader: reader}
+}
+
+// Start syncs image puller Pods periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (p *ImagePuller) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("image-puller")
+
+	ticker := time.NewTicker(imagePullerSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Sync(ctx); err != nil {
+				log.Error(err, "Failed to sync image puller Pods")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Sync creates image puller Pods for the workload Pods currently nominated to nodes once,
+// and deletes the ones whose nodes don't have such workload Pods anymore.
+func (p *ImagePuller) Sync(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("image-puller")
+
+	desired, err := p.desiredPullers(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := &corev1.PodList{}
+	if err := p.reconciler.List(ctx, existing, client.InNamespace(p.reconciler.mountpointPodConfig.Namespace), client.HasLabels{LabelImagePuller}); err != nil {
+		return fmt.Errorf("failed to list image puller Pods: %w", err)
+	}
+	for i := range existing.Items {
+		puller := &existing.Items[i]
+		if _, ok := desired[puller.Name]; ok {
+			delete(desired, puller.Name)
+			continue
+		}
+		if puller.DeletionTimestamp != nil {
+			continue
+		}
+		if err := p.reconciler.Delete(ctx, puller); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete image puller Pod %s: %w", puller.Name, err)
+		}
+		log.V(debugLevel).Info("Image puller Pod deleted", "imagePullerPod", puller.Name, "node", puller.Spec.NodeName)
+	}
+
+	nodes := map[string]*corev1.Node{}
+	for _, puller := range desired {
+		nodeName, image := puller.Spec.NodeName, puller.Spec.Containers[0].Image
+		node, ok := nodes[nodeName]
+		if !ok {
+			node = &corev1.Node{}
+			err := p.reader.Get(ctx, types.NamespacedName{Name: nodeName}, node)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get node %s: %w", nodeName, err)
+			}
+			nodes[nodeName] = node
+		}
+		if hasImage(node, image) {
+			continue
+		}
+
+		if err := p.reconciler.Create(ctx, puller); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create image puller Pod %s: %w", puller.Name, err)
+		}
+		log.Info("Pulling Mountpoint image into node nominated to pending workload Pods", "imagePullerPod", puller.Name, "node", nodeName, "image", image)
+	}
+	return nil
+}
+
+// desiredPullers returns image puller Pods by their names for the Mountpoint Pods of workload Pods nominated to nodes.
+// Workload Pods with volumes not bound yet are skipped, as their Mountpoint Pods are not known before they're bound.
+func (p *ImagePuller) desiredPullers(ctx context.Context) (map[string]*corev1.Pod, error) {
+	pending := &corev1.PodList{}
+	if err := p.reader.List(ctx, pending, client.MatchingFieldsSelector{Selector: fields.OneTermEqualSelector("spec.nodeName", "")}); err != nil {
+		return nil, fmt.Errorf("failed to list unscheduled Pods: %w", err)
+	}
+
+	desired := map[string]*corev1.Pod{}
+	for i := range pending.Items {
+		pod := &pending.Items[i]
+		if pod.Status.NominatedNodeName == "" || !isPodActive(pod) || pod.Namespace == p.reconciler.mountpointPodConfig.Namespace {
+			continue
+		}
+		if ok, err := p.reconciler.inShard(ctx, pod); err != nil || !ok {
+			continue
+		}
+
+		pvs, err := p.reconciler.s3Volumes(ctx, pod)
+		if errors.Is(err, errPVCIsNotBoundToAPV) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Mountpoint Pods are created as if the workload Pod was scheduled to the nominated node, to find their images.
+		scheduled := pod.DeepCopy()
+		scheduled.Spec.NodeName = pod.Status.NominatedNodeName
+		for _, pv := range pvs {
+			puller := p.puller(scheduled.Spec.NodeName, p.reconciler.mountpointPodCreator.Create(scheduled, pv))
+			desired[puller.Name] = puller
+		}
+	}
+	return desired, nil
+}
+
+// puller returns an image puller Pod pulling the image of given `mpPod` into `node` with its image pull secrets.
+//
+// It's bound to `node` directly without a scheduler, as the node might be full until the workload Pods nominated
+// to it preempt other Pods, and it tolerates all taints as the workload Pods might tolerate them.
+func (p *ImagePuller) puller(node string, mpPod *corev1.Pod) *corev1.Pod {
+	container := mpPod.Spec.Containers[0]
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      imagePullerNamePrefix + pullerHash(node, container.Image),
+			Namespace: mpPod.Namespace,
+			Labels:    map[string]string{LabelImagePuller: "true"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:         node,
+			RestartPolicy:    corev1.RestartPolicyNever,
+			ImagePullSecrets: mpPod.Spec.ImagePullSecrets,
+			Tolerations:      []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:            "pull",
+				Image:           container.Image,
+				ImagePullPolicy: container.ImagePullPolicy,
+				Command:         container.Command,
+				Args:            []string{"--help"},
+				SecurityContext: container.SecurityContext,
+			}},
+			TerminationGracePeriodSeconds: ptr.To[int64](0),
+		},
+	}
+}
+
+// pullerHash returns a hash of `node` and `image` to name the image puller Pod pulling `image` into `node`,
+// as node names and images can be longer than Pod names.
+func pullerHash(node, image string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(node))
+	hash.Write([]byte{0})
+	hash.Write([]byte(image))
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// hasImage returns whether `node` reports `image` in its status. Nodes only report a limited number of their
+// largest images, so images not reported might still be present.
+func hasImage(node *corev1.Node, image string) bool {
+	return slices.ContainsFunc(node.Status.Images, func(i corev1.ContainerImage) bool {
+		return slices.Contains(i.Names, image)
+	})
+}
@@ -0,0 +1,112 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestImagePuller(t *testing.T) {
+	const image = "mp-image:1.10.0"
+
+	newNode := func(name string, images ...string) *corev1.Node {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if len(images) > 0 {
+			node.Status.Images = []corev1.ContainerImage{{Names: images}}
+		}
+		return node
+	}
+	newPendingPod := func(name, nominatedNode, claimName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			}}}},
+			Status: corev1.PodStatus{Phase: corev1.PodPending, NominatedNodeName: nominatedNode},
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	unboundPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "unbound-claim", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().
+		WithObjects(
+			newNode("node-1"), newNode("node-2", "docker.io/library/"+image, image), newNode("node-3"),
+			pvc, unboundPVC, pv,
+			newPendingPod("nominated", "node-1", "s3-claim"),
+			newPendingPod("nominated-to-node-with-image", "node-2", "s3-claim"),
+			newPendingPod("not-nominated", "", "s3-claim"),
+			newPendingPod("unbound", "node-3", "unbound-claim"),
+		).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+			return []string{o.(*corev1.Pod).Spec.NodeName}
+		}).
+		Build()
+	r := csicontroller.NewReconciler(c, record.NewFakeRecorder(10), mppod.Config{
+		Namespace: mountpointNamespace,
+		Container: mppod.ContainerConfig{
+			Image:            image,
+			Command:          "/bin/aws-s3-csi-mounter",
+			ImagePullSecrets: []string{"registry-credentials"},
+		},
+	}, csicontroller.DefaultRestartPolicy)
+	puller := csicontroller.NewImagePuller(r, c)
+
+	listPullers := func() []corev1.Pod {
+		t.Helper()
+		list := &corev1.PodList{}
+		assert.NoError(t, c.List(context.Background(), list, client.InNamespace(mountpointNamespace), client.HasLabels{csicontroller.LabelImagePuller}))
+		return list.Items
+	}
+
+	// The image is only pulled into the node without it that a workload Pod with bound volumes is nominated to
+	assert.NoError(t, puller.Sync(context.Background()))
+	pullers := listPullers()
+	assert.Equals(t, 1, len(pullers))
+	assert.Equals(t, "node-1", pullers[0].Spec.NodeName)
+	assert.Equals(t, corev1.RestartPolicyNever, pullers[0].Spec.RestartPolicy)
+	assert.Equals(t, []corev1.LocalObjectReference{{Name: "registry-credentials"}}, pullers[0].Spec.ImagePullSecrets)
+	assert.Equals(t, image, pullers[0].Spec.Containers[0].Image)
+	assert.Equals(t, []string{"/bin/aws-s3-csi-mounter"}, pullers[0].Spec.Containers[0].Command)
+
+	// The completed image puller is kept while the workload Pod is nominated to the node
+	pullers[0].Status.Phase = corev1.PodSucceeded
+	assert.NoError(t, c.Status().Update(context.Background(), &pullers[0]))
+	assert.NoError(t, puller.Sync(context.Background()))
+	pullers = listPullers()
+	assert.Equals(t, 1, len(pullers))
+	assert.Equals(t, corev1.PodSucceeded, pullers[0].Status.Phase)
+
+	// The image puller is deleted once the workload Pod is scheduled
+	nominated := &corev1.Pod{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "nominated"}, nominated))
+	nominated.Spec.NodeName = "node-1"
+	assert.NoError(t, c.Update(context.Background(), nominated))
+	assert.NoError(t, puller.Sync(context.Background()))
+	assert.Equals(t, 0, len(listPullers()))
+}
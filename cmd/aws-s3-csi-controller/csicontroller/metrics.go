@@ -0,0 +1,79 @@
+package csicontroller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Types of Pods reconciled by the controller, used as `pod_type` label in metrics.
+const (
+	podTypeMountpoint = "mountpoint"
+	podTypeWorkload   = "workload"
+)
+
+// Reasons of Mountpoint Pod deletions, used as `reason` label in metrics.
+const (
+	deleteReasonSucceeded           = "succeeded"
+	deleteReasonWorkloadTerminating = "workload_terminating"
+	deleteReasonFailed              = "failed"
+	deleteReasonOrphaned            = "orphaned"
+	deleteReasonUpgraded            = "upgraded"
+	deleteReasonNodeMismatch        = "node_mismatch"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconciling Mountpoint and workload Pods.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"pod_type"})
+	mountpointPodsSpawnedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "mountpoint_pods_spawned_total",
+		Help:      "Total number of Mountpoint Pods spawned.",
+	})
+	mountpointPodsDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "mountpoint_pods_deleted_total",
+		Help:      "Total number of Mountpoint Pods deleted by reason.",
+	}, []string{"reason"})
+	mountpointPodCreateConflictsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "mountpoint_pod_create_conflicts_total",
+		Help:      "Total number of Mountpoint Pod creations failed due to an already existing Mountpoint Pod.",
+	})
+	mountpointPodRestartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "mountpoint_pod_restarts_total",
+		Help:      "Total number of failed Mountpoint Pods respawned.",
+	})
+	mountpointPodEvictionsRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "mountpoint_pod_evictions_rejected_total",
+		Help:      "Total number of Mountpoint Pod evictions rejected as their workload Pods were still running.",
+	})
+	volumeUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "volume_used_bytes",
+		Help:      "Total size of objects in S3 volumes by PersistentVolume, a lower bound if not all objects are counted.",
+	}, []string{"volume"})
+	volumeObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi_controller",
+		Name:      "volume_objects",
+		Help:      "Number of objects in S3 volumes by PersistentVolume, a lower bound if not all objects are counted.",
+	}, []string{"volume"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileDuration,
+		mountpointPodsSpawnedTotal,
+		mountpointPodsDeletedTotal,
+		mountpointPodCreateConflictsTotal,
+		mountpointPodRestartsTotal,
+		mountpointPodEvictionsRejectedTotal,
+		volumeUsedBytes,
+		volumeObjects,
+	)
+}
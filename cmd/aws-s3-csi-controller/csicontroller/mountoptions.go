@@ -0,0 +1,100 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// MountOptionsWebhookPath is the path the mount options webhook is served at.
+const MountOptionsWebhookPath = "/validate-mount-options"
+
+// A MountOptionsValidator validates mount options of PersistentVolumes and StorageClasses using the CSI Driver.
+// It also validates attributes used to customize Mountpoint Pods, see [mppod.ValidateVolumeAttributes].
+//
+// Mount options are only passed to Mountpoint while the volume is mounted, so malformed options otherwise
+// fail only after a workload Pod using the volume is scheduled. This validator rejects such objects at creation time.
+// PersistentVolumeClaims do not have mount options, they inherit them from their StorageClass or PersistentVolume.
+type MountOptionsValidator struct {
+	decoder admission.Decoder
+}
+
+// NewMountOptionsValidator returns a new mount options validator using given `decoder` to decode objects.
+func NewMountOptionsValidator(decoder admission.Decoder) *MountOptionsValidator {
+	return &MountOptionsValidator{decoder: decoder}
+}
+
+// SetupWithServer registers the validator as a webhook on given webhook `server`.
+// The webhook needs to be configured with a ValidatingWebhookConfiguration for `CREATE` and `UPDATE` operations
+// on `persistentvolumes` and `storageclasses`.
+func (v *MountOptionsValidator) SetupWithServer(server webhook.Server) {
+	server.Register(MountOptionsWebhookPath, &webhook.Admission{Handler: v})
+}
+
+// Handle decides whether the object in `req` has valid mount options.
+func (v *MountOptionsValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var mountOptions []string
+	// StorageClass parameters are passed to the volume attributes of provisioned PersistentVolumes.
+	var volumeAttributes map[string]string
+	switch req.Kind.Kind {
+	case "PersistentVolume":
+		pv := &corev1.PersistentVolume{}
+		if err := v.decoder.Decode(req, pv); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != mountpointCSIDriverName {
+			return admission.Allowed("")
+		}
+		volumeAttributes = pv.Spec.CSI.VolumeAttributes
+		mountOptions = pv.Spec.MountOptions
+		if attr := pv.Spec.CSI.VolumeAttributes[volumecontext.MountOptions]; attr != "" {
+			mountOptions = append(mountOptions, strings.Split(attr, ",")...)
+		}
+		// Volumes with only `ReadOnlyMany` access mode are always mounted as read-only by the CSI Driver.
+		if len(pv.Spec.AccessModes) == 1 && pv.Spec.AccessModes[0] == corev1.ReadOnlyMany {
+			mountOptions = append(mountOptions, mountpoint.ArgReadOnly)
+		}
+	case "StorageClass":
+		sc := &storagev1.StorageClass{}
+		if err := v.decoder.Decode(req, sc); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if sc.Provisioner != mountpointCSIDriverName {
+			return admission.Allowed("")
+		}
+		mountOptions = sc.MountOptions
+		volumeAttributes = sc.Parameters
+	default:
+		return admission.Allowed("")
+	}
+
+	if err := mppod.ValidateVolumeAttributes(volumeAttributes); err != nil {
+		logf.FromContext(ctx).Info("Rejecting object with invalid Mountpoint Pod attributes",
+			"kind", req.Kind.Kind, "name", req.Name, "error", err.Error())
+		return admission.Denied(fmt.Sprintf("invalid Mountpoint Pod attributes for %s: %s", mountpointCSIDriverName, strings.ReplaceAll(err.Error(), "\n", "; ")))
+	}
+
+	if err := mountpoint.ValidateArgs(mountOptions); err != nil {
+		logf.FromContext(ctx).Info("Rejecting object with invalid mount options",
+			"kind", req.Kind.Kind, "name", req.Name, "error", err.Error())
+		return admission.Denied(fmt.Sprintf("invalid mount options for %s: %s", mountpointCSIDriverName, strings.ReplaceAll(err.Error(), "\n", "; ")))
+	}
+
+	return admission.Allowed("")
+}
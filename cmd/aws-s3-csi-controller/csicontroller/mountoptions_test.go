@@ -0,0 +1,130 @@
+package csicontroller_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestMountOptionsValidator(t *testing.T) {
+	pv := func(driver string, mountOptions []string, volumeAttributes map[string]string) runtime.Object {
+		return &corev1.PersistentVolume{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
+			ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+			Spec: corev1.PersistentVolumeSpec{
+				MountOptions: mountOptions,
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{
+						Driver:           driver,
+						VolumeHandle:     "s3-csi-driver-volume",
+						VolumeAttributes: volumeAttributes,
+					},
+				},
+			},
+		}
+	}
+	storageClass := func(provisioner string, mountOptions []string, parameters ...map[string]string) runtime.Object {
+		sc := &storagev1.StorageClass{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "storage.k8s.io/v1", Kind: "StorageClass"},
+			ObjectMeta:   metav1.ObjectMeta{Name: "s3-sc"},
+			Provisioner:  provisioner,
+			MountOptions: mountOptions,
+		}
+		if len(parameters) > 0 {
+			sc.Parameters = parameters[0]
+		}
+		return sc
+	}
+	request := func(kind string, obj runtime.Object) admission.Request {
+		raw, err := json.Marshal(obj)
+		assert.NoError(t, err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Kind:      metav1.GroupVersionKind{Kind: kind},
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	testCases := []struct {
+		name    string
+		req     admission.Request
+		allowed bool
+	}{
+		{
+			name:    "allows PV with valid mount options",
+			req:     request("PersistentVolume", pv("s3.csi.aws.com", []string{"allow-delete", "uid=1000"}, nil)),
+			allowed: true,
+		},
+		{
+			name:    "rejects PV with conflicting mount options",
+			req:     request("PersistentVolume", pv("s3.csi.aws.com", []string{"uid=1000", "uid=2000"}, nil)),
+			allowed: false,
+		},
+		{
+			name: "rejects ReadOnlyMany PV with mount options enabling writes",
+			req: request("PersistentVolume", func() runtime.Object {
+				obj := pv("s3.csi.aws.com", []string{"allow-delete"}, nil).(*corev1.PersistentVolume)
+				obj.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+				return obj
+			}()),
+			allowed: false,
+		},
+		{
+			name:    "rejects PV with invalid mount options in volume attributes",
+			req:     request("PersistentVolume", pv("s3.csi.aws.com", nil, map[string]string{"mountOptions": "gid=admin"})),
+			allowed: false,
+		},
+		{
+			name: "allows PV with valid Mountpoint Pod attributes",
+			req: request("PersistentVolume", pv("s3.csi.aws.com", nil, map[string]string{
+				"mountpointPodLabels":      `{"team": "ml"}`,
+				"mountpointPodTolerations": `[{"key": "nvidia.com/gpu", "operator": "Exists", "effect": "NoSchedule"}]`,
+			})),
+			allowed: true,
+		},
+		{
+			name:    "rejects PV with invalid Mountpoint Pod labels",
+			req:     request("PersistentVolume", pv("s3.csi.aws.com", nil, map[string]string{"mountpointPodLabels": "team=ml"})),
+			allowed: false,
+		},
+		{
+			name:    "rejects StorageClass with invalid Mountpoint Pod tolerations",
+			req:     request("StorageClass", storageClass("s3.csi.aws.com", nil, map[string]string{"mountpointPodTolerations": `[{"operator": "Maybe"}]`})),
+			allowed: false,
+		},
+		{
+			name:    "allows PV of other drivers",
+			req:     request("PersistentVolume", pv("ebs.csi.aws.com", []string{"uid=1000", "uid=2000"}, nil)),
+			allowed: true,
+		},
+		{
+			name:    "rejects StorageClass with unsupported mount options",
+			req:     request("StorageClass", storageClass("s3.csi.aws.com", []string{"foreground"})),
+			allowed: false,
+		},
+		{
+			name:    "allows StorageClass of other provisioners",
+			req:     request("StorageClass", storageClass("ebs.csi.aws.com", []string{"foreground"})),
+			allowed: true,
+		},
+	}
+
+	validator := csicontroller.NewMountOptionsValidator(admission.NewDecoder(scheme.Scheme))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := validator.Handle(context.Background(), tc.req)
+			assert.Equals(t, tc.allowed, resp.Allowed)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package csicontroller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/platform"
+)
+
+// eventReasonUnsupportedNode is the reason of the event recorded on workload Pods scheduled to nodes not supported
+// by the CSI Driver, e.g. Windows nodes, where Mountpoint Pods are not spawned.
+const eventReasonUnsupportedNode = "MountpointPodUnsupportedNode"
+
+// checkNode returns an error if the node with `nodeName` is known to be unsupported by the CSI Driver from its labels.
+// Nodes that cannot be retrieved are assumed to be supported, so Mountpoint Pods are still spawned for them.
+//
+// Only metadata of nodes is retrieved and cached, as only their labels are needed.
+func (r *Reconciler) checkNode(ctx context.Context, nodeName string) error {
+	node := &metav1.PartialObjectMetadata{}
+	node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		logf.FromContext(ctx).V(debugLevel).Info("Failed to get node to check its platform - assuming it's supported", "node", nodeName, "error", err)
+		return nil
+	}
+	return platform.CheckNode(&corev1.Node{ObjectMeta: node.ObjectMeta})
+}
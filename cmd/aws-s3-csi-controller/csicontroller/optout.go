@@ -0,0 +1,40 @@
+package csicontroller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// LabelMounter is the label of namespaces to opt all volumes of their workload Pods out of Mountpoint Pods with
+// [volumecontext.MounterSystemd], as with `mounter` volume attribute of individual volumes. Opted-out volumes are only
+// mounted by the CSI Driver Node Pod, e.g. for workloads whose namespaces can't fit Mountpoint Pods in their quotas
+// or NetworkPolicies.
+const LabelMounter = "s3.csi.aws.com/mounter"
+
+// eventReasonMountpointPodOptedOut is the reason of the event recorded on workload Pods once a volume is not provided
+// by a Mountpoint Pod as it's opted out.
+const eventReasonMountpointPodOptedOut = "MountpointPodOptedOut"
+
+// isOptedOutOfMountpointPods returns whether the volume with `attributes` (either volume attributes of a PV, or
+// parameters of a StorageClass) used by workload Pods in `namespace` is opted out of Mountpoint Pods, either by its
+// `mounter` attribute or by [LabelMounter] of `namespace`.
+func isOptedOutOfMountpointPods(ctx context.Context, reader client.Reader, namespace string, attributes map[string]string) (bool, error) {
+	if attributes[volumecontext.Mounter] == volumecontext.MounterSystemd {
+		return true, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return ns.Labels[LabelMounter] == volumecontext.MounterSystemd, nil
+}
@@ -0,0 +1,111 @@
+package csicontroller_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestOptingOutOfMountpointPods(t *testing.T) {
+	objects := func(namespaceLabels, volumeAttributes map[string]string) []client.Object {
+		return []client.Object{
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Labels: namespaceLabels}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "legacy", UID: "workload-uid"},
+				Spec: corev1.PodSpec{
+					NodeName: "node-1",
+					Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+					}}},
+				},
+				Status: corev1.PodStatus{Phase: corev1.PodPending},
+			},
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "legacy"},
+				Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+				Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+			},
+			&corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+				Spec: corev1.PersistentVolumeSpec{
+					ClaimRef: &corev1.ObjectReference{Namespace: "legacy", Name: "s3-claim"},
+					PersistentVolumeSource: corev1.PersistentVolumeSource{
+						CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume", VolumeAttributes: volumeAttributes},
+					},
+				},
+			},
+		}
+	}
+
+	for name, test := range map[string]struct {
+		namespaceLabels  map[string]string
+		volumeAttributes map[string]string
+		optedOut         bool
+	}{
+		"opted out by volume attribute": {
+			volumeAttributes: map[string]string{"mounter": "systemd"},
+			optedOut:         true,
+		},
+		"opted out by namespace label": {
+			namespaceLabels: map[string]string{csicontroller.LabelMounter: "systemd"},
+			optedOut:        true,
+		},
+		"not opted out": {
+			namespaceLabels:  map[string]string{csicontroller.LabelMounter: "pod"},
+			volumeAttributes: map[string]string{"bucketName": "test-bucket"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithObjects(objects(test.namespaceLabels, test.volumeAttributes)...).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := csicontroller.NewReconciler(c, recorder, mppod.Config{Namespace: mountpointNamespace}, csicontroller.DefaultRestartPolicy)
+
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "legacy", Name: "workload"}})
+			assert.NoError(t, err)
+
+			mpPods := &corev1.PodList{}
+			assert.NoError(t, c.List(context.Background(), mpPods, client.InNamespace(mountpointNamespace)))
+			assert.Equals(t, !test.optedOut, len(mpPods.Items) == 1)
+
+			assert.Equals(t, 1, len(recorder.Events))
+			event := <-recorder.Events
+			assert.Equals(t, test.optedOut, strings.HasPrefix(event, "Normal MountpointPodOptedOut"))
+
+			// Opted-out workload Pods are not gated, as they don't wait for Mountpoint Pods
+			injector := csicontroller.NewSchedulingGateInjector(c, admission.NewDecoder(scheme.Scheme), mountpointNamespace)
+			raw, err := json.Marshal(&corev1.Pod{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				ObjectMeta: metav1.ObjectMeta{Name: "another-workload"},
+				Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+				}}}},
+			})
+			assert.NoError(t, err)
+			resp := injector.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+				Namespace: "legacy",
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			assert.Equals(t, true, resp.Allowed)
+			assert.Equals(t, !test.optedOut, len(resp.Patches) > 0)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// orphanCollectionInterval is the interval to look for orphaned Mountpoint Pods.
+const orphanCollectionInterval = time.Minute
+
+// An OrphanCollector periodically deletes orphaned Mountpoint Pods, i.e. Mountpoint Pods whose workload Pods
+// or PersistentVolumes do not exist anymore, once they have been orphaned for longer than a TTL.
+//
+// Orphaned Mountpoint Pods are normally cleaned up once the CSI Driver Node Pod unmounts their volumes, but they
+// might be left behind if the volume is never unmounted, for example if the controller or the node plugin crashed
+// between spawning a Mountpoint Pod and its workload Pod being deleted. Orphans are detected from
+// [mppod.ConditionWorkloadAttached] if available, and from the first time the collector observed them otherwise.
+type OrphanCollector struct {
+	namespace string
+	ttl       time.Duration
+	// orphanedSince holds the first time Mountpoint Pods are observed as orphaned by UID.
+	orphanedSince map[types.UID]time.Time
+	// shard is the set of namespaces whose workload Pods' Mountpoint Pods are collected, all namespaces if nil.
+	shard *NamespaceShard
+
+	client.Client
+}
+
+// NewOrphanCollector returns a new collector deleting Mountpoint Pods in `namespace` orphaned for longer than `ttl`.
+func NewOrphanCollector(client client.Client, namespace string, ttl time.Duration) *OrphanCollector {
+	return &OrphanCollector{
+		Client:        client,
+		namespace:     namespace,
+		ttl:           ttl,
+		orphanedSince: map[types.UID]time.Time{},
+	}
+}
+
+// SetNamespaceShard limits the collector to Mountpoint Pods of workload Pods in the namespaces of `shard`.
+func (c *OrphanCollector) SetNamespaceShard(shard *NamespaceShard) {
+	c.shard = shard
+}
+
+// Start collects orphaned Mountpoint Pods periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (c *OrphanCollector) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("orphan-collector")
+
+	ticker := time.NewTicker(min(orphanCollectionInterval, c.ttl))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Collect(ctx); err != nil {
+				log.Error(err, "Failed to collect orphaned Mountpoint Pods")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Collect looks for orphaned Mountpoint Pods once, and deletes the ones orphaned for longer than the TTL.
+func (c *OrphanCollector) Collect(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("orphan-collector")
+
+	list := &corev1.PodList{}
+	if err := c.List(ctx, list, client.InNamespace(c.namespace), client.HasLabels{mppod.LabelPodUID, mppod.LabelVolumeName}); err != nil {
+		return fmt.Errorf("failed to list Mountpoint Pods: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[types.UID]bool, len(list.Items))
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		if ok, err := c.shard.ContainsMountpointPod(ctx, pod); err != nil || !ok {
+			if err != nil {
+				log.Error(err, "Failed to check if Mountpoint Pod belongs to the namespace shard", "mountpointPod", pod.Name)
+			}
+			continue
+		}
+
+		reason, err := c.orphanReason(ctx, pod)
+		if err != nil {
+			log.Error(err, "Failed to check if Mountpoint Pod is orphaned", "mountpointPod", pod.Name)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		seen[pod.UID] = true
+
+		since, ok := c.orphanedSince[pod.UID]
+		if !ok {
+			since = now
+			c.orphanedSince[pod.UID] = since
+		}
+		if condition := podCondition(pod, mppod.ConditionWorkloadAttached); condition != nil &&
+			condition.Status == corev1.ConditionFalse && condition.LastTransitionTime.Time.Before(since) {
+			since = condition.LastTransitionTime.Time
+		}
+
+		if orphanedFor := now.Sub(since); orphanedFor < c.ttl {
+			log.V(debugLevel).Info("Mountpoint Pod is orphaned - waiting for TTL", "mountpointPod", pod.Name, "reason", reason, "orphanedFor", orphanedFor)
+			continue
+		}
+
+		err = c.Delete(ctx, pod)
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to delete orphaned Mountpoint Pod", "mountpointPod", pod.Name)
+			continue
+		}
+		if err == nil {
+			mountpointPodsDeletedTotal.WithLabelValues(deleteReasonOrphaned).Inc()
+		}
+		log.Info("Orphaned Mountpoint Pod deleted", "mountpointPod", pod.Name, "reason", reason, "orphanedSince", since)
+	}
+
+	// Forget Mountpoint Pods that are deleted or not orphaned anymore.
+	for uid := range c.orphanedSince {
+		if !seen[uid] {
+			delete(c.orphanedSince, uid)
+		}
+	}
+
+	return nil
+}
+
+// orphanReason returns why given Mountpoint `pod` is orphaned, or an empty string if it's not orphaned.
+func (c *OrphanCollector) orphanReason(ctx context.Context, pod *corev1.Pod) (string, error) {
+	workloadPod, err := getWorkloadPod(ctx, c, pod)
+	if err != nil {
+		return "", err
+	}
+	if workloadPod == nil {
+		return "workload Pod does not exist", nil
+	}
+
+	err = c.Get(ctx, types.NamespacedName{Name: pod.Labels[mppod.LabelVolumeName]}, &corev1.PersistentVolume{})
+	if apierrors.IsNotFound(err) {
+		return "PersistentVolume does not exist", nil
+	}
+	return "", err
+}
+
+// podCondition returns the condition of `conditionType` on `pod`, or nil if it's not set.
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCollectingOrphanedMountpointPods(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: mountpointNamespace})
+
+	workloadPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")}}
+	}
+	pv := func(name string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	// Workload Pod is deleted, and the Mountpoint Pod has been marked as unattached for 2 hours
+	longOrphaned := creator.Create(workloadPod("deleted-long-ago"), pv("s3-pv"))
+	longOrphaned.Status.Conditions = []corev1.PodCondition{{
+		Type:               mppod.ConditionWorkloadAttached,
+		Status:             corev1.ConditionFalse,
+		Reason:             "WorkloadPodNotFound",
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+	}}
+	// Workload Pod is deleted, and there is no attachment condition on the Mountpoint Pod
+	newlyOrphaned := creator.Create(workloadPod("deleted"), pv("s3-pv"))
+	// PV is deleted
+	missingPV := creator.Create(workloadPod("running"), pv("deleted-pv"))
+	// Both workload Pod and PV exist
+	attached := creator.Create(workloadPod("running"), pv("s3-pv"))
+
+	c := fake.NewClientBuilder().
+		WithObjects(workloadPod("running"), pv("s3-pv"), longOrphaned, newlyOrphaned, missingPV, attached).
+		WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+			return []string{string(o.GetUID())}
+		}).
+		Build()
+	exists := func(pod *corev1.Pod) bool {
+		t.Helper()
+		err := c.Get(context.Background(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatalf("Failed to get Pod: %v", err)
+		}
+		return err == nil
+	}
+
+	ttl := 100 * time.Millisecond
+	collector := csicontroller.NewOrphanCollector(c, mountpointNamespace, ttl)
+
+	assert.NoError(t, collector.Collect(context.Background()))
+	assert.Equals(t, false, exists(longOrphaned))
+	assert.Equals(t, true, exists(newlyOrphaned))
+	assert.Equals(t, true, exists(missingPV))
+	assert.Equals(t, true, exists(attached))
+
+	time.Sleep(ttl)
+
+	assert.NoError(t, collector.Collect(context.Background()))
+	assert.Equals(t, false, exists(newlyOrphaned))
+	assert.Equals(t, false, exists(missingPV))
+	assert.Equals(t, true, exists(attached))
+}
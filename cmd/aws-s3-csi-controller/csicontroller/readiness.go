@@ -0,0 +1,56 @@
+package csicontroller
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// eventReasonMountpointPodNotReady is the reason of the event recorded on workload Pods if their Mountpoint Pod
+// is not running because of a problem that needs attention, e.g. its image can't be pulled.
+const eventReasonMountpointPodNotReady = "MountpointPodNotReady"
+
+// waitingReasons are reasons of waiting containers that don't resolve on their own, or only after a backoff.
+var waitingReasons = []string{
+	"ErrImagePull",
+	"ImagePullBackOff",
+	"InvalidImageName",
+	"CreateContainerConfigError",
+	"CreateContainerError",
+	"CrashLoopBackOff",
+}
+
+// recordMountpointPodProblem records an event on `workloadPod` with the reason its Mountpoint `mpPod` is not running,
+// if it's because of a problem that needs attention. Otherwise workload Pods only wait in `ContainerCreating` without
+// any indication of why their volumes are not mounted. Repeated events are aggregated by the event recorder.
+func (r *Reconciler) recordMountpointPodProblem(workloadPod, mpPod *corev1.Pod) {
+	if problem := mountpointPodProblem(mpPod); problem != "" {
+		r.recorder.Eventf(workloadPod, corev1.EventTypeWarning, eventReasonMountpointPodNotReady,
+			"Mountpoint Pod %s/%s is not ready: %s", mpPod.Namespace, mpPod.Name, problem)
+	}
+}
+
+// mountpointPodProblem returns why Mountpoint `pod` is not running, or an empty string if it's running or
+// it's expected to run soon. It reports Mountpoint Pods that can't be scheduled, containers that can't be started,
+// and containers that are restarted after being killed for running out of memory.
+func mountpointPodProblem(pod *corev1.Pod) string {
+	if i := slices.IndexFunc(pod.Status.Conditions, func(c corev1.PodCondition) bool {
+		return c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable
+	}); i >= 0 {
+		return fmt.Sprintf("%s: %s", corev1.PodReasonUnschedulable, pod.Status.Conditions[i].Message)
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && slices.Contains(waitingReasons, waiting.Reason) {
+			if terminated := status.LastTerminationState.Terminated; waiting.Reason == "CrashLoopBackOff" && terminated != nil && terminated.Reason == "OOMKilled" {
+				return fmt.Sprintf("container %s is restarted after being OOMKilled, consider raising its memory limit", status.Name)
+			}
+			return fmt.Sprintf("container %s is waiting: %s: %s", status.Name, waiting.Reason, waiting.Message)
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.Reason == "OOMKilled" {
+			return fmt.Sprintf("container %s is OOMKilled, consider raising its memory limit", status.Name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package csicontroller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestRecordingMountpointPodProblems(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+			}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		status        corev1.PodStatus
+		expectedEvent string
+	}{
+		{
+			name: "image pull error",
+			status: corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "mountpoint",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "manifest unknown"}},
+			}}},
+			expectedEvent: "container mountpoint is waiting: ErrImagePull: manifest unknown",
+		},
+		{
+			name: "unschedulable",
+			status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+				Message: "0/1 nodes are available: 1 node(s) had untolerated taint {dedicated: gpu}",
+			}}},
+			expectedEvent: "Unschedulable: 0/1 nodes are available: 1 node(s) had untolerated taint {dedicated: gpu}",
+		},
+		{
+			name: "out of memory",
+			status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+				Name:                 "mountpoint",
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			}}},
+			expectedEvent: "container mountpoint is restarted after being OOMKilled",
+		},
+		{
+			name: "container creating",
+			status: corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "mountpoint",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+			}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mpPod := mppod.NewCreator(podConfig).Create(workloadPod, pv)
+			mpPod.Status = tc.status
+
+			c := fake.NewClientBuilder().WithObjects(workloadPod, pvc, pv, mpPod).WithStatusSubresource(mpPod).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := csicontroller.NewReconciler(c, recorder, podConfig, csicontroller.DefaultRestartPolicy)
+
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+			assert.NoError(t, err)
+
+			if tc.expectedEvent == "" {
+				assert.Equals(t, 0, len(recorder.Events))
+				return
+			}
+			assert.Equals(t, 1, len(recorder.Events))
+			event := <-recorder.Events
+			if !strings.HasPrefix(event, "Warning MountpointPodNotReady") || !strings.Contains(event, tc.expectedEvent) {
+				t.Fatalf("Unexpected event %q", event)
+			}
+		})
+	}
+}
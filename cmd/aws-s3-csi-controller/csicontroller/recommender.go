@@ -0,0 +1,171 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// DefaultRecommendationInterval is the default interval to collect resource usage of Mountpoint Pods.
+const DefaultRecommendationInterval = time.Minute
+
+// recommendationMargin is the safety margin added on top of the peak usage to recommend resources.
+const recommendationMargin = 1.2
+
+// minRecommendedCPUMillis is the lowest CPU recommendation, as Mountpoint Pods with lower requests get throttled.
+const minRecommendedCPUMillis = 10
+
+var podMetricsListGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetricsList"}
+
+// podMetrics represents the subset of `metrics.k8s.io/v1beta1` PodMetrics used by the recommender.
+// The Metrics API is accessed via unstructured objects to not depend on `k8s.io/metrics` module.
+type podMetrics struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Containers        []struct {
+		Name  string              `json:"name"`
+		Usage corev1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// A ResourceRecommender periodically collects resource usage of Mountpoint Pods from the Metrics API (i.e., metrics-server),
+// and records their peak usage and recommended resources as annotations on the Mountpoint Pods (see [mppod.AnnotationRecommendedMemory]).
+//
+// Operators can use the recommendations to tune the resources of Mountpoint Pods, for example to avoid OOM kills.
+// Recommendations never decrease during the lifetime of a Mountpoint Pod, as the peak usage is kept in the annotations.
+type ResourceRecommender struct {
+	namespace string
+	interval  time.Duration
+
+	client.Client
+}
+
+// NewResourceRecommender returns a new recommender for Mountpoint Pods in `namespace` collecting usage every `interval`.
+func NewResourceRecommender(client client.Client, namespace string, interval time.Duration) *ResourceRecommender {
+	return &ResourceRecommender{Client: client, namespace: namespace, interval: interval}
+}
+
+// Start collects resource usage periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (r *ResourceRecommender) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("resource-recommender")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Recommend(ctx); err != nil {
+				log.Error(err, "Failed to recommend resources for Mountpoint Pods")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Recommend collects current resource usage of Mountpoint Pods once, and updates their annotations if their peak usage increased.
+func (r *ResourceRecommender) Recommend(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("resource-recommender")
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podMetricsListGVK)
+	if err := r.List(ctx, list, client.InNamespace(r.namespace), client.HasLabels{mppod.LabelVolumeName}); err != nil {
+		return fmt.Errorf("failed to list Mountpoint Pod metrics: %w", err)
+	}
+
+	for _, item := range list.Items {
+		var metrics podMetrics
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), &metrics); err != nil {
+			log.Error(err, "Failed to parse Pod metrics", "mountpointPod", item.GetName())
+			continue
+		}
+
+		if err := r.recordUsage(ctx, &metrics); err != nil {
+			log.Error(err, "Failed to record resource usage", "mountpointPod", metrics.Name)
+		}
+	}
+
+	return nil
+}
+
+// recordUsage updates annotations of the Mountpoint Pod with given `metrics` if its peak usage increased.
+func (r *ResourceRecommender) recordUsage(ctx context.Context, metrics *podMetrics) error {
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: metrics.Namespace, Name: metrics.Name}, pod); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	var cpu, memory resource.Quantity
+	for _, container := range metrics.Containers {
+		cpu.Add(container.Usage[corev1.ResourceCPU])
+		memory.Add(container.Usage[corev1.ResourceMemory])
+	}
+
+	peakCPU := maxQuantity(cpu, pod.Annotations[mppod.AnnotationPeakCPU])
+	peakMemory := maxQuantity(memory, pod.Annotations[mppod.AnnotationPeakMemory])
+
+	annotations := map[string]string{
+		mppod.AnnotationPeakCPU:           peakCPU.String(),
+		mppod.AnnotationPeakMemory:        peakMemory.String(),
+		mppod.AnnotationRecommendedCPU:    recommendCPU(peakCPU).String(),
+		mppod.AnnotationRecommendedMemory: recommendMemory(peakMemory).String(),
+	}
+
+	changed := false
+	for key, value := range annotations {
+		if pod.Annotations[key] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	for key, value := range annotations {
+		pod.Annotations[key] = value
+	}
+	if err := r.Patch(ctx, pod, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	logf.FromContext(ctx).V(debugLevel).Info("Updated resource recommendations", "mountpointPod", pod.Name,
+		"recommendedCPU", annotations[mppod.AnnotationRecommendedCPU], "recommendedMemory", annotations[mppod.AnnotationRecommendedMemory])
+	return nil
+}
+
+// maxQuantity returns the greater of `current` and `previous` quantity, `previous` is ignored if it's not a valid quantity.
+func maxQuantity(current resource.Quantity, previous string) resource.Quantity {
+	if prev, err := resource.ParseQuantity(previous); err == nil && prev.Cmp(current) > 0 {
+		return prev
+	}
+	return current
+}
+
+// recommendCPU returns recommended CPU for given `peak` usage in millicores.
+func recommendCPU(peak resource.Quantity) *resource.Quantity {
+	millis := int64(math.Ceil(float64(peak.MilliValue()) * recommendationMargin))
+	return resource.NewMilliQuantity(max(millis, minRecommendedCPUMillis), resource.DecimalSI)
+}
+
+// recommendMemory returns recommended memory for given `peak` usage rounded up to MiB.
+func recommendMemory(peak resource.Quantity) *resource.Quantity {
+	const mib = 1024 * 1024
+	mibs := int64(math.Ceil(float64(peak.Value()) * recommendationMargin / mib))
+	return resource.NewQuantity(max(mibs, 1)*mib, resource.BinarySI)
+}
@@ -0,0 +1,80 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestResourceRecommender(t *testing.T) {
+	ctx := context.Background()
+	mpPodName := types.NamespacedName{Namespace: mountpointNamespace, Name: "mp-pod"}
+	mpPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: mpPodName.Namespace,
+		Name:      mpPodName.Name,
+		Labels:    map[string]string{mppod.LabelVolumeName: "test-vol"},
+	}}
+	podMetrics := func(cpu, memory string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "metrics.k8s.io/v1beta1",
+			"kind":       "PodMetrics",
+			"metadata": map[string]interface{}{
+				"namespace": mpPodName.Namespace,
+				"name":      mpPodName.Name,
+				"labels":    map[string]interface{}{mppod.LabelVolumeName: "test-vol"},
+			},
+			"containers": []interface{}{map[string]interface{}{
+				"name":  "mountpoint",
+				"usage": map[string]interface{}{"cpu": cpu, "memory": memory},
+			}},
+		}}
+	}
+
+	metrics := podMetrics("50m", "100Mi")
+	c := fake.NewClientBuilder().WithObjects(mpPod, metrics).Build()
+	recommender := csicontroller.NewResourceRecommender(c, mountpointNamespace, time.Minute)
+
+	annotations := func() map[string]string {
+		assert.NoError(t, recommender.Recommend(ctx))
+		pod := &corev1.Pod{}
+		assert.NoError(t, c.Get(ctx, mpPodName, pod))
+		return pod.Annotations
+	}
+
+	assert.Equals(t, map[string]string{
+		mppod.AnnotationPeakCPU:           "50m",
+		mppod.AnnotationPeakMemory:        "100Mi",
+		mppod.AnnotationRecommendedCPU:    "60m",
+		mppod.AnnotationRecommendedMemory: "120Mi",
+	}, annotations())
+
+	// Recommendations don't decrease once usage goes down
+	updateMetrics(t, c, metrics, podMetrics("10m", "20Mi"))
+	assert.Equals(t, "120Mi", annotations()[mppod.AnnotationRecommendedMemory])
+
+	// Recommendations increase with a new peak
+	updateMetrics(t, c, metrics, podMetrics("1", "1Gi"))
+	assert.Equals(t, map[string]string{
+		mppod.AnnotationPeakCPU:           "1",
+		mppod.AnnotationPeakMemory:        "1Gi",
+		mppod.AnnotationRecommendedCPU:    "1200m",
+		mppod.AnnotationRecommendedMemory: "1229Mi",
+	}, annotations())
+}
+
+func updateMetrics(t *testing.T, c client.Client, existing, updated *unstructured.Unstructured) {
+	updated.SetResourceVersion(existing.GetResourceVersion())
+	assert.NoError(t, c.Update(context.Background(), updated))
+	existing.SetResourceVersion(updated.GetResourceVersion())
+}
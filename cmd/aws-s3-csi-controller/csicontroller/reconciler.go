@@ -4,49 +4,112 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
 )
 
 const debugLevel = 4
 
 const mountpointCSIDriverName = "s3.csi.aws.com"
 
+// eventReasonMountpointPodScheduled is the reason of the event recorded on workload Pods once a Mountpoint Pod
+// is scheduled to provide one of their volumes.
+const eventReasonMountpointPodScheduled = "MountpointPodScheduled"
+
+// podUIDIndexField is the field index to lookup workload Pods by their UIDs.
+const podUIDIndexField = "metadata.uid"
+
 // A Reconciler reconciles Mountpoint Pods by watching other workload Pods thats using S3 CSI Driver.
+//
+// Mountpoint Pods are not shared between workloads, each workload Pod gets a dedicated Mountpoint Pod for each
+// of its volumes (see [mppod.MountpointPodNameFor]). Therefore the FUSE load of a Mountpoint Pod is bounded by
+// a single workload, and there is no need to limit the number of workloads per Mountpoint Pod.
 type Reconciler struct {
 	mountpointPodConfig  mppod.Config
 	mountpointPodCreator *mppod.Creator
+	restartPolicy        RestartPolicy
+	workQueueConfig      WorkQueueConfig
+	recorder             record.EventRecorder
+	// shard is the set of namespaces whose workload Pods are reconciled, all namespaces if nil.
+	shard *NamespaceShard
 
 	client.Client
 }
 
 // NewReconciler returns a new reconciler created from `client` and `podConfig`.
-func NewReconciler(client client.Client, podConfig mppod.Config) *Reconciler {
+// Failed Mountpoint Pods are restarted according to `restartPolicy`. Scheduling and failures of Mountpoint Pods
+// are recorded as events on workload Pods using `recorder`.
+func NewReconciler(client client.Client, recorder record.EventRecorder, podConfig mppod.Config, restartPolicy RestartPolicy) *Reconciler {
 	creator := mppod.NewCreator(podConfig)
-	return &Reconciler{Client: client, mountpointPodConfig: podConfig, mountpointPodCreator: creator}
+	return &Reconciler{
+		Client:               client,
+		recorder:             recorder,
+		mountpointPodConfig:  podConfig,
+		mountpointPodCreator: creator,
+		restartPolicy:        restartPolicy,
+		workQueueConfig:      DefaultWorkQueueConfig,
+	}
+}
+
+// SetWorkQueueConfig sets the configuration of the work queue, it must be called before `SetupWithManager`.
+func (r *Reconciler) SetWorkQueueConfig(config WorkQueueConfig) {
+	r.workQueueConfig = config
+}
+
+// MountpointPodCreator returns the creator used to create Mountpoint Pods.
+func (r *Reconciler) MountpointPodCreator() *mppod.Creator {
+	return r.mountpointPodCreator
 }
 
 // SetupWithManager configures reconciler to run with given `mgr`.
-// It automatically configures reconciler to reconcile Pods in the cluster.
-func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+// It automatically configures reconciler to reconcile Pods in the cluster, where events of Mountpoint Pods are
+// reconciled through their workload Pods (see [Reconciler.requestsForMountpointPod]),
+// and indexes Pods by their UIDs to lookup workload Pods of Mountpoint Pods.
+func (r *Reconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, podUIDIndexField, func(o client.Object) []string {
+		return []string{string(o.GetUID())}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index Pods by UID: %w", err)
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		Named(Name).
-		For(&corev1.Pod{}).
+		For(&corev1.Pod{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return !r.isMountpointPodObject(o)
+		}))).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.requestsForMountpointPod),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.isMountpointPodObject))).
+		Watches(&corev1.PersistentVolume{}, handler.EnqueueRequestsFromMapFunc(r.requestsForUpgradedVolume),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isUpgradeRequested)))
+	if r.shard != nil {
+		b = b.Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.requestsForNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}))
+	}
+	return b.WithOptions(r.workQueueConfig.controllerOptions()).
 		Complete(r)
 }
 
 // Reconcile reconciles either a Mountpoint- or a workload-Pod.
 //
-// For Mountpoint Pods, it deletes completed Pods and logs each status change.
-// For workload Pods, it decides if it needs to spawn a Mountpoint Pod to provide a volume for the workload Pod.
+// For Mountpoint Pods, it deletes completed Pods, restarts failed Pods and logs each status change.
+// For workload Pods, it decides if it needs to spawn a Mountpoint Pod to provide a volume for the workload Pod,
+// and reconciles existing Mountpoint Pods of the workload Pod.
 func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	log := logf.FromContext(ctx).WithValues("pod", req.NamespacedName)
 
@@ -63,34 +126,50 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		return reconcile.Result{}, err
 	}
 
+	if ok, err := r.inShard(ctx, pod); err != nil {
+		log.Error(err, "Failed to check if Pod belongs to the namespace shard")
+		return reconcile.Result{}, err
+	} else if !ok {
+		log.V(debugLevel).Info("Pod does not belong to the namespace shard - ignoring")
+		return reconcile.Result{}, nil
+	}
+
 	if r.isMountpointPod(pod) {
+		defer observeReconcileDuration(podTypeMountpoint, time.Now())
 		return r.reconcileMountpointPod(ctx, pod)
 	}
 
+	defer observeReconcileDuration(podTypeWorkload, time.Now())
 	return r.reconcileWorkloadPod(ctx, pod)
 }
 
-// reconcileMountpointPod reconciles given Mountpoint `pod`, and deletes it if its completed.
+// observeReconcileDuration records duration of a reconciliation of given `podType` started at `start`.
+func observeReconcileDuration(podType string, start time.Time) {
+	reconcileDuration.WithLabelValues(podType).Observe(time.Since(start).Seconds())
+}
+
+// reconcileMountpointPod reconciles given Mountpoint `pod`, deletes it if its completed, restarts it if its failed,
+// and updates its attachment conditions otherwise.
 func (r *Reconciler) reconcileMountpointPod(ctx context.Context, pod *corev1.Pod) (reconcile.Result, error) {
 	log := logf.FromContext(ctx).WithValues("mountpointPod", pod.Name)
 
 	switch pod.Status.Phase {
 	case corev1.PodPending:
 		log.V(debugLevel).Info("Pod pending to be scheduled")
+		return r.reconcileAttachment(ctx, pod)
 	case corev1.PodRunning:
 		log.V(debugLevel).Info("Pod is running")
+		return r.reconcileAttachment(ctx, pod)
 	case corev1.PodSucceeded:
-		err := r.deleteMountpointPod(ctx, pod)
+		err := r.deleteMountpointPod(ctx, pod, deleteReasonSucceeded)
 		if err != nil {
 			log.Error(err, "Failed to delete succeeded Pod")
 			return reconcile.Result{}, err
 		}
 		log.Info("Pod succeeded and successfully deleted")
 	case corev1.PodFailed:
-		// TODO: We should probably delete failed Pods after some time to trigger a retry on the whole operation.
-		//       Maybe just returning a `reconcile.Result{RequeueAfter: ...}`
-		//       and deleting in next cycle would be a good way?
 		log.Info("Pod failed", "reason", pod.Status.Reason)
+		return r.restartFailedMountpointPod(ctx, pod)
 	}
 
 	return reconcile.Result{}, nil
@@ -110,11 +189,22 @@ func (r *Reconciler) reconcileWorkloadPod(ctx context.Context, pod *corev1.Pod)
 		return reconcile.Result{}, nil
 	}
 
-	var requeue bool
+	var result reconcile.Result
 	var errs []error
 
 	for _, vol := range pod.Spec.Volumes {
+		// CSI ephemeral (inline) volumes are not backed by a PV, and they're mounted by the CSI Driver Node Pod directly.
+		if vol.CSI != nil {
+			log.V(debugLevel).Info("Volume is an inline CSI volume - ignoring", "volume", vol.Name)
+			continue
+		}
+
 		podPVC := vol.PersistentVolumeClaim
+		if vol.Ephemeral != nil {
+			// Generic ephemeral volumes are backed by a PVC created for the Pod with a deterministic name.
+			// See https://kubernetes.io/docs/concepts/storage/ephemeral-volumes/#persistentvolumeclaim-naming.
+			podPVC = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pod.Name + "-" + vol.Name}
+		}
 		if podPVC == nil {
 			continue
 		}
@@ -125,7 +215,7 @@ func (r *Reconciler) reconcileWorkloadPod(ctx context.Context, pod *corev1.Pod)
 		pvc, pv, err := r.getBoundPVForPodClaim(ctx, pod, podPVC)
 		if err != nil {
 			if errors.Is(err, errPVCIsNotBoundToAPV) {
-				requeue = true
+				result.Requeue = true
 			} else {
 				errs = append(errs, err)
 			}
@@ -139,14 +229,15 @@ func (r *Reconciler) reconcileWorkloadPod(ctx context.Context, pod *corev1.Pod)
 
 		log.V(debugLevel).Info("Found bound PV for PVC", "pvc", pvc.Name, "volumeName", pv.Name)
 
-		err = r.spawnOrDeleteMountpointPodIfNeeded(ctx, pod, pvc, pv, csiSpec)
+		res, err := r.spawnOrDeleteMountpointPodIfNeeded(ctx, pod, pvc, pv, csiSpec)
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
+		result = mergeResults(result, res)
 	}
 
-	return reconcile.Result{Requeue: requeue}, errors.Join(errs...)
+	return result, errors.Join(errs...)
 }
 
 // spawnOrDeleteMountpointPodIfNeeded spawns or deletes existing Mountpoint Pod for given `workloadPod` and volume if needed.
@@ -157,29 +248,42 @@ func (r *Reconciler) reconcileWorkloadPod(ctx context.Context, pod *corev1.Pod)
 // the Mountpoint Pod will be scheduled for termination as well. This is because if `workloadPod` never transition into its `Running` state,
 // the Mountpoint Pod might never got a successful mount operation, and thus it might never get unmount operation to cleanly exit
 // and might hang there until it reaches its timeout. We just terminate it in this case to prevent unnecessary waits.
+//
+// If there is an existing Mountpoint Pod that is completed or failed, it's reconciled as in `reconcileMountpointPod`.
+// If there is an existing running Mountpoint Pod, it's upgraded if requested as in `reconcileUpgrade`.
+// If there is an existing active Mountpoint Pod in another node than `workloadPod` (e.g., spawned in the candidate node
+// of a gated `workloadPod` that is scheduled elsewhere, see [SchedulingGateController]), it's deleted to be respawned.
+// If there is an existing active Mountpoint Pod that is not running because of a problem (e.g., its image can't be pulled),
+// the problem is recorded as an event on `workloadPod` as in `recordMountpointPodProblem`.
+// No Mountpoint Pod is spawned if the volume is opted out of Mountpoint Pods (see [LabelMounter]).
 func (r *Reconciler) spawnOrDeleteMountpointPodIfNeeded(
 	ctx context.Context,
 	workloadPod *corev1.Pod,
 	pvc *corev1.PersistentVolumeClaim,
 	pv *corev1.PersistentVolume,
 	csiSpec *corev1.CSIPersistentVolumeSource,
-) error {
+) (reconcile.Result, error) {
 	mpPodName := mppod.MountpointPodNameFor(string(workloadPod.UID), pvc.Spec.VolumeName)
 
 	log := logf.FromContext(ctx).WithValues(
 		"workloadPod", types.NamespacedName{Namespace: workloadPod.Namespace, Name: workloadPod.Name},
 		"mountpointPod", mpPodName,
-		"pvc", pvc.Name, "volumeName", pv.Name)
+		"pvc", pvc.Name, "volumeName", pv.Name,
+		logging.KeyVolumeID, csiSpec.VolumeHandle, logging.KeyPodUID, workloadPod.UID)
 
 	mpPod := &corev1.Pod{}
 	err := r.Get(ctx, types.NamespacedName{Namespace: r.mountpointPodConfig.Namespace, Name: mpPodName}, mpPod)
 	if err != nil && !apierrors.IsNotFound(err) {
 		log.Error(err, "Failed to get Mountpoint Pod")
-		return err
+		return reconcile.Result{}, err
 	}
 
 	isMountpointPodExists := err == nil
 
+	if isMountpointPodExists && !isPodActive(mpPod) {
+		return r.reconcileMountpointPod(ctx, mpPod)
+	}
+
 	// `workloadPod` is not active, its either terminated (i.e., `phase == Succeeded or phase == Failed`) or
 	// its scheduled for termination (i.e., `DeletionTimestamp != nil`)
 	if !isPodActive(workloadPod) {
@@ -188,76 +292,135 @@ func (r *Reconciler) spawnOrDeleteMountpointPodIfNeeded(
 		// Mountpoint Pod might take some time to terminate on its own.
 		if isMountpointPodExists && workloadPod.Status.Phase == corev1.PodPending {
 			log.Info("Deleting scheduled Mountpoint Pod")
-			err := r.deleteMountpointPod(ctx, mpPod)
+			err := r.deleteMountpointPod(ctx, mpPod, deleteReasonWorkloadTerminating)
 			if err != nil {
 				log.Error(err, "Failed to delete scheduled Mountpoint Pod")
-				return err
+				return reconcile.Result{}, err
 			}
 
 			log.Info("Scheduled Mountpoint Pod deleted")
-			return err
+			return reconcile.Result{}, nil
 		}
 
 		// No need to do anything - either there was no Mountpoint Pod for `pod` or it was in `Running` state,
 		// so a clean unmount operation will be performed and Mountpoint Pod will cleany exit (and get deleted by `reconcileMountpointPod`).
-		return nil
+		// The Mountpoint Pod is marked as pending unmount in the meantime.
+		if isMountpointPodExists {
+			return reconcile.Result{}, r.updateAttachmentConditions(ctx, mpPod, workloadPod)
+		}
+		return reconcile.Result{}, nil
 	}
 
 	if isMountpointPodExists {
-		log.V(debugLevel).Info("Mountpoint Pod already exists - ignoring")
-		return nil
+		if node := mountpointPodNode(mpPod); node != "" && node != workloadPod.Spec.NodeName {
+			// Mountpoint Pods spawned ahead for gated workload Pods are in their candidate node, which the scheduler
+			// might not pick for them. They're replaced with Mountpoint Pods in the node of the workload Pod.
+			log.Info("Mountpoint Pod is in another node than the workload Pod - deleting it to respawn", "mountpointPodNode", node, "workloadPodNode", workloadPod.Spec.NodeName)
+			if err := r.deleteMountpointPod(ctx, mpPod, deleteReasonNodeMismatch); err != nil {
+				log.Error(err, "Failed to delete Mountpoint Pod in another node")
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{Requeue: true}, nil
+		}
+
+		log.V(debugLevel).Info("Mountpoint Pod already exists - updating attachment conditions")
+		if err := r.updateAttachmentConditions(ctx, mpPod, workloadPod); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.recordMountpointPodProblem(workloadPod, mpPod)
+		if mpPod.Status.Phase == corev1.PodRunning {
+			return r.reconcileUpgrade(ctx, mpPod, workloadPod, pv)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if optedOut, err := isOptedOutOfMountpointPods(ctx, r, workloadPod.Namespace, csiSpec.VolumeAttributes); err != nil {
+		log.Error(err, "Failed to check if volume is opted out of Mountpoint Pods")
+		return reconcile.Result{}, err
+	} else if optedOut {
+		log.V(debugLevel).Info("Volume is opted out of Mountpoint Pods - not spawning Mountpoint Pod")
+		r.recorder.Eventf(workloadPod, corev1.EventTypeNormal, eventReasonMountpointPodOptedOut,
+			"Mountpoint Pod is not spawned to provide volume %s as it's opted out, it's mounted by the CSI Driver Node Pod", pv.Name)
+		return reconcile.Result{}, nil
+	}
+
+	if err := r.checkNode(ctx, workloadPod.Spec.NodeName); err != nil {
+		log.Info("Node is not supported - not spawning Mountpoint Pod", "reason", err.Error())
+		r.recorder.Eventf(workloadPod, corev1.EventTypeWarning, eventReasonUnsupportedNode,
+			"Mountpoint Pod is not spawned to provide volume %s: %v", pv.Name, err)
+		return reconcile.Result{}, nil
 	}
 
-	if err := r.spawnMountpointPod(ctx, workloadPod, pvc, pv, csiSpec, mpPodName); err != nil {
+	if err := r.spawnMountpointPod(ctx, workloadPod, pv, mpPodName, 0); err != nil {
 		log.Error(err, "Failed to spawn Mountpoint Pod")
-		return err
+		return reconcile.Result{}, err
 	}
+	r.recorder.Eventf(workloadPod, corev1.EventTypeNormal, eventReasonMountpointPodScheduled,
+		"Mountpoint Pod %s/%s is scheduled to node %s to provide volume %s", r.mountpointPodConfig.Namespace, mpPodName, workloadPod.Spec.NodeName, pv.Name)
 
-	return nil
+	return reconcile.Result{}, nil
 }
 
 // spawnMountpointPod spawns a new Mountpoint Pod for given `workloadPod` and volume.
 // The Mountpoint Pod will be spawned into the same node as `workloadPod`, which then the mount operation
 // will be continued by the CSI Driver Node component in that node.
+// A non-zero `restarts` is recorded on the Mountpoint Pod if its respawned after a failure.
 func (r *Reconciler) spawnMountpointPod(
 	ctx context.Context,
 	workloadPod *corev1.Pod,
-	pvc *corev1.PersistentVolumeClaim,
 	pv *corev1.PersistentVolume,
-	_ *corev1.CSIPersistentVolumeSource,
 	name string,
+	restarts int,
 ) error {
 	log := logf.FromContext(ctx).WithValues(
 		"workloadPod", types.NamespacedName{Namespace: workloadPod.Namespace, Name: workloadPod.Name},
 		"mountpointPod", name,
-		"pvc", pvc.Name, "volumeName", pv.Name)
+		"volumeName", pv.Name)
 
 	log.Info("Spawning Mountpoint Pod")
 
-	mpPod := r.mountpointPodCreator.Create(workloadPod, pvc)
+	pv, err := r.withVolumeAttributesClass(ctx, pv)
+	if err != nil {
+		log.Error(err, "Failed to apply VolumeAttributesClass of the volume")
+		return err
+	}
+
+	mpPod := r.mountpointPodCreator.Create(workloadPod, pv)
 	if mpPod.Name != name {
 		err := fmt.Errorf("Mountpoint Pod name mismatch %s vs %s", mpPod.Name, name)
 		log.Error(err, "Name mismatch on Mountpoint Pod")
 		return err
 	}
 
-	err := r.Create(ctx, mpPod)
+	if restarts > 0 {
+		if mpPod.Annotations == nil {
+			mpPod.Annotations = map[string]string{}
+		}
+		mpPod.Annotations[mppod.AnnotationRestartCount] = strconv.Itoa(restarts)
+	}
+
+	err = r.Create(ctx, mpPod)
 	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			mountpointPodCreateConflictsTotal.Inc()
+		}
 		log.Error(err, "Failed to create Mountpoint Pod")
 		return err
 	}
 
+	mountpointPodsSpawnedTotal.Inc()
 	log.Info("Mountpoint Pod spawned", "mountpointPodUID", mpPod.UID)
 	return nil
 }
 
-// deleteMountpointPod deletes given `mountpointPod`.
+// deleteMountpointPod deletes given `mountpointPod` for given `reason` with given `opts`.
 // It does not return an error if `mountpointPod` does not exists in the control plane.
-func (r *Reconciler) deleteMountpointPod(ctx context.Context, mountpointPod *corev1.Pod) error {
-	log := logf.FromContext(ctx).WithValues("mountpointPod", mountpointPod.Name)
+func (r *Reconciler) deleteMountpointPod(ctx context.Context, mountpointPod *corev1.Pod, reason string, opts ...client.DeleteOption) error {
+	log := logf.FromContext(ctx).WithValues("mountpointPod", mountpointPod.Name, "reason", reason)
 
-	err := r.Delete(ctx, mountpointPod)
+	err := r.Delete(ctx, mountpointPod, opts...)
 	if err == nil {
+		mountpointPodsDeletedTotal.WithLabelValues(reason).Inc()
 		log.Info("Mountpoint Pod deleted")
 		return nil
 	}
@@ -315,10 +478,10 @@ func (r *Reconciler) getBoundPVForPodClaim(
 }
 
 // isMountpointPod returns whether given `pod` is a Mountpoint Pod.
-// It currently checks namespace of `pod`.
+// It currently checks namespace of `pod`, excluding headroom and image puller Pods that are also running in the same namespace.
 func (r *Reconciler) isMountpointPod(pod *corev1.Pod) bool {
 	// TODO: Do we need to perform any additional check here?
-	return pod.Namespace == r.mountpointPodConfig.Namespace
+	return pod.Namespace == r.mountpointPodConfig.Namespace && pod.Labels[LabelHeadroom] == "" && pod.Labels[LabelImagePuller] == ""
 }
 
 // extractCSISpecFromPV tries to extract `CSIPersistentVolumeSource` from given `pv`.
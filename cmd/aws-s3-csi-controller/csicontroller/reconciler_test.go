@@ -0,0 +1,178 @@
+package csicontroller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestSpawningMountpointPodRecordsEvent(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+			}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithObjects(workloadPod, pvc, pv).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := csicontroller.NewReconciler(c, recorder, podConfig, csicontroller.DefaultRestartPolicy)
+
+	reconcileWorkloadPod := func() {
+		t.Helper()
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+		assert.NoError(t, err)
+	}
+
+	reconcileWorkloadPod()
+
+	mpPodName := mppod.MountpointPodNameFor("workload-uid", "s3-pv")
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: mpPodName}, &corev1.Pod{}))
+
+	assert.Equals(t, 1, len(recorder.Events))
+	event := <-recorder.Events
+	if !strings.HasPrefix(event, "Normal MountpointPodScheduled") || !strings.Contains(event, mpPodName) || !strings.Contains(event, "node-1") {
+		t.Fatalf("Unexpected event %q", event)
+	}
+
+	// Reconciling again with an existing Mountpoint Pod should not record the event again
+	reconcileWorkloadPod()
+	assert.Equals(t, 0, len(recorder.Events))
+}
+
+func TestReconcilingWorkloadPodReconcilesItsMountpointPods(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+			}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+	mpPod := mppod.NewCreator(podConfig).Create(workloadPod, pv)
+	mpPod.Status = corev1.PodStatus{
+		Phase: corev1.PodFailed,
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "mountpoint",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, FinishedAt: metav1.Now()}},
+		}},
+	}
+
+	c := fake.NewClientBuilder().
+		WithObjects(workloadPod, pvc, pv, mpPod).
+		WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+			return []string{string(o.GetUID())}
+		}).
+		Build()
+	restartPolicy := csicontroller.RestartPolicy{MaxRestarts: 1, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	r := csicontroller.NewReconciler(c, record.NewFakeRecorder(10), podConfig, restartPolicy)
+
+	// The failed Mountpoint Pod is restarted after the backoff, by requeueing the workload Pod.
+	res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+	assert.NoError(t, err)
+	if res.RequeueAfter <= 0 || res.RequeueAfter > time.Hour {
+		t.Fatalf("Expected to requeue the workload Pod within an hour, got %v", res.RequeueAfter)
+	}
+
+	got := &corev1.Pod{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: mpPod.Name}, got))
+	assert.Equals(t, corev1.PodFailed, got.Status.Phase)
+}
+
+func TestNotSpawningMountpointPodOnUnsupportedNode(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "windows-node", Labels: map[string]string{corev1.LabelOSStable: "windows"}}}
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "windows-node",
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+			}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithObjects(node, workloadPod, pvc, pv).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := csicontroller.NewReconciler(c, recorder, podConfig, csicontroller.DefaultRestartPolicy)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+	assert.NoError(t, err)
+
+	mpPods := &corev1.PodList{}
+	assert.NoError(t, c.List(context.Background(), mpPods, client.InNamespace(mountpointNamespace)))
+	assert.Equals(t, 0, len(mpPods.Items))
+
+	assert.Equals(t, 1, len(recorder.Events))
+	event := <-recorder.Events
+	if !strings.HasPrefix(event, "Warning MountpointPodUnsupportedNode") || !strings.Contains(event, `operating system "windows" is not supported`) {
+		t.Fatalf("Unexpected event %q", event)
+	}
+}
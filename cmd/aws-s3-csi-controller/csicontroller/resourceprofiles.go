@@ -0,0 +1,74 @@
+package csicontroller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// A ResourceProfileReconciler watches the ConfigMap containing resource profiles of Mountpoint Pods,
+// and applies its default profile to Mountpoint Pods spawned afterwards without restarting the controller.
+// Existing Mountpoint Pods are not updated.
+type ResourceProfileReconciler struct {
+	configMap types.NamespacedName
+	creator   *mppod.Creator
+
+	client.Client
+}
+
+// NewResourceProfileReconciler returns a new reconciler to watch `configMap` and apply its resource profile to `creator`.
+func NewResourceProfileReconciler(client client.Client, creator *mppod.Creator, configMap types.NamespacedName) *ResourceProfileReconciler {
+	return &ResourceProfileReconciler{Client: client, creator: creator, configMap: configMap}
+}
+
+// SetupWithManager configures reconciler to run with given `mgr`.
+// It only reconciles the configured ConfigMap.
+func (r *ResourceProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(Name+"-resource-profiles").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetNamespace() == r.configMap.Namespace && object.GetName() == r.configMap.Name
+		}))).
+		Complete(r)
+}
+
+// Reconcile applies the default resource profile in the ConfigMap to newly spawned Mountpoint Pods.
+//
+// If the ConfigMap is deleted, Mountpoint Pods are spawned without any resources.
+// If the ConfigMap is invalid, the last valid profile is kept until the ConfigMap is fixed.
+func (r *ResourceProfileReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := logf.FromContext(ctx).WithValues("configMap", req.NamespacedName)
+
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, req.NamespacedName, configMap)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ConfigMap not found - spawning Mountpoint Pods without resources")
+			r.creator.SetResources(corev1.ResourceRequirements{})
+			return reconcile.Result{}, nil
+		}
+		log.Error(err, "Failed to get ConfigMap")
+		return reconcile.Result{}, err
+	}
+
+	resources, err := mppod.ParseResourceProfiles([]byte(configMap.Data[mppod.ResourceProfilesKey]))
+	if err != nil {
+		// Retrying wouldn't help, the ConfigMap will be reconciled again once it's updated.
+		log.Error(err, "Invalid resource profiles - keeping the last valid profile")
+		return reconcile.Result{}, nil
+	}
+
+	r.creator.SetResources(resources)
+	log.Info("Applied resource profile to new Mountpoint Pods", "requests", resources.Requests, "limits", resources.Limits)
+	return reconcile.Result{}, nil
+}
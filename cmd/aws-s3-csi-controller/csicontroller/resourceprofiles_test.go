@@ -0,0 +1,53 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestResourceProfileReconciler(t *testing.T) {
+	ctx := context.Background()
+	configMapName := types.NamespacedName{Namespace: "kube-system", Name: "mountpoint-pod-resources"}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: configMapName.Namespace, Name: configMapName.Name},
+		Data:       map[string]string{mppod.ResourceProfilesKey: "defaultProfile: small"},
+	}
+
+	client := fake.NewClientBuilder().WithObjects(configMap).Build()
+	creator := mppod.NewCreator(mppod.Config{Namespace: mountpointNamespace})
+	reconciler := csicontroller.NewResourceProfileReconciler(client, creator, configMapName)
+
+	mountpointPodResources := func() corev1.ResourceRequirements {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: configMapName})
+		assert.NoError(t, err)
+		mpPod := creator.Create(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"}},
+			&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "test-vol"}})
+		return mpPod.Spec.Containers[0].Resources
+	}
+
+	assert.Equals(t, mppod.BuiltinResourceProfiles["small"], mountpointPodResources())
+
+	// Updating the ConfigMap applies the new profile
+	configMap.Data[mppod.ResourceProfilesKey] = "defaultProfile: large"
+	assert.NoError(t, client.Update(ctx, configMap))
+	assert.Equals(t, mppod.BuiltinResourceProfiles["large"], mountpointPodResources())
+
+	// Invalid profiles are ignored and the last valid profile is kept
+	configMap.Data[mppod.ResourceProfilesKey] = "defaultProfile: huge"
+	assert.NoError(t, client.Update(ctx, configMap))
+	assert.Equals(t, mppod.BuiltinResourceProfiles["large"], mountpointPodResources())
+
+	// Deleting the ConfigMap removes the resources
+	assert.NoError(t, client.Delete(ctx, configMap))
+	assert.Equals(t, corev1.ResourceRequirements{}, mountpointPodResources())
+}
@@ -0,0 +1,189 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// Reasons of events recorded on workload Pods for failures of their Mountpoint Pods.
+const (
+	eventReasonMountpointPodRestarted     = "MountpointPodRestarted"
+	eventReasonMountpointPodRestartsLimit = "MountpointPodRestartLimitReached"
+)
+
+// A RestartPolicy configures how failed Mountpoint Pods are restarted.
+//
+// A failed Mountpoint Pod is deleted and respawned after a backoff, which starts with `InitialBackoff` and doubles
+// with each restart up to `MaxBackoff`. After `MaxRestarts`, the failed Mountpoint Pod is kept for troubleshooting
+// and no longer restarted.
+type RestartPolicy struct {
+	MaxRestarts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRestartPolicy is the default policy to restart failed Mountpoint Pods.
+var DefaultRestartPolicy = RestartPolicy{
+	MaxRestarts:    5,
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+}
+
+// backoff returns the duration to wait before restarting a Mountpoint Pod that has been restarted `restarts` times.
+func (p RestartPolicy) backoff(restarts int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 0; i < restarts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, p.MaxBackoff)
+}
+
+// restartFailedMountpointPod restarts given failed Mountpoint `pod` by deleting and respawning it after a backoff.
+//
+// Mountpoint Pods are named deterministically for their workload Pods and volumes, so the number of restarts is
+// carried over to the respawned Mountpoint Pod with [mppod.AnnotationRestartCount]. Each restart, and reaching
+// the restart limit, is recorded as an event on the workload Pod as the Mountpoint Pod is not visible to its users.
+func (r *Reconciler) restartFailedMountpointPod(ctx context.Context, pod *corev1.Pod) (reconcile.Result, error) {
+	log := logf.FromContext(ctx).WithValues("mountpointPod", pod.Name)
+
+	if pod.DeletionTimestamp != nil {
+		log.V(debugLevel).Info("Failed Pod is already scheduled for deletion - ignoring")
+		return reconcile.Result{}, nil
+	}
+
+	workloadPod, err := getWorkloadPod(ctx, r, pod)
+	if err != nil {
+		log.Error(err, "Failed to get workload Pod of failed Pod")
+		return reconcile.Result{}, err
+	}
+
+	// There is nothing to provide a volume for, the failed Pod can be cleaned up.
+	if workloadPod == nil || !isPodActive(workloadPod) {
+		err := r.deleteMountpointPod(ctx, pod, deleteReasonFailed)
+		if err != nil {
+			log.Error(err, "Failed to delete failed Pod")
+			return reconcile.Result{}, err
+		}
+		log.Info("Pod failed and workload Pod is not active, failed Pod deleted")
+		return reconcile.Result{}, nil
+	}
+
+	log = log.WithValues("workloadPod", types.NamespacedName{Namespace: workloadPod.Namespace, Name: workloadPod.Name})
+
+	restarts := restartCount(pod)
+	if restarts >= r.restartPolicy.MaxRestarts {
+		log.Info("Pod failed and reached the restart limit - not restarting", "restarts", restarts)
+		r.recorder.Eventf(workloadPod, corev1.EventTypeWarning, eventReasonMountpointPodRestartsLimit,
+			"Mountpoint Pod %s/%s for volume %q failed after %d restarts and will not be restarted: %s",
+			pod.Namespace, pod.Name, pod.Labels[mppod.LabelVolumeName], restarts, podFailureReason(pod))
+		return reconcile.Result{}, nil
+	}
+
+	if wait := r.restartPolicy.backoff(restarts) - time.Since(podFailedAt(pod)); wait > 0 {
+		log.Info("Pod failed, restarting after backoff", "restarts", restarts, "backoff", wait)
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+
+	pv := &corev1.PersistentVolume{}
+	err = r.Get(ctx, types.NamespacedName{Name: pod.Labels[mppod.LabelVolumeName]}, pv)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("PV of failed Pod not found - deleting failed Pod")
+			return reconcile.Result{}, r.deleteMountpointPod(ctx, pod, deleteReasonFailed)
+		}
+		log.Error(err, "Failed to get PV of failed Pod")
+		return reconcile.Result{}, err
+	}
+
+	// Containers of a failed Pod are not running, so it can be deleted without a grace period,
+	// which frees its name immediately to respawn the Mountpoint Pod.
+	err = r.deleteMountpointPod(ctx, pod, deleteReasonFailed, client.GracePeriodSeconds(0))
+	if err != nil {
+		log.Error(err, "Failed to delete failed Pod")
+		return reconcile.Result{}, err
+	}
+
+	err = r.spawnMountpointPod(ctx, workloadPod, pv, pod.Name, restarts+1)
+	if err != nil {
+		log.Error(err, "Failed to respawn failed Pod")
+		return reconcile.Result{}, err
+	}
+
+	mountpointPodRestartsTotal.Inc()
+	r.recorder.Eventf(workloadPod, corev1.EventTypeWarning, eventReasonMountpointPodRestarted,
+		"Mountpoint Pod %s/%s for volume %q failed and has been restarted (%d/%d): %s",
+		pod.Namespace, pod.Name, pod.Labels[mppod.LabelVolumeName], restarts+1, r.restartPolicy.MaxRestarts, podFailureReason(pod))
+	log.Info("Pod failed and restarted", "restarts", restarts+1)
+	return reconcile.Result{}, nil
+}
+
+// getWorkloadPod returns the workload Pod of given Mountpoint `pod` using `c`, or nil if the workload Pod does not exist anymore.
+func getWorkloadPod(ctx context.Context, c client.Reader, pod client.Object) (*corev1.Pod, error) {
+	workloadPodUID := pod.GetLabels()[mppod.LabelPodUID]
+	if workloadPodUID == "" {
+		return nil, nil
+	}
+
+	workloadPods := &corev1.PodList{}
+	err := c.List(ctx, workloadPods, client.MatchingFields{podUIDIndexField: workloadPodUID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(workloadPods.Items) == 0 {
+		return nil, nil
+	}
+	return &workloadPods.Items[0], nil
+}
+
+// restartCount returns the number of times given Mountpoint `pod` has been restarted.
+func restartCount(pod *corev1.Pod) int {
+	restarts, err := strconv.Atoi(pod.Annotations[mppod.AnnotationRestartCount])
+	if err != nil || restarts < 0 {
+		return 0
+	}
+	return restarts
+}
+
+// podFailedAt returns the time given failed `pod` has failed at.
+// It falls back to the creation time of `pod` if none of its containers has terminated, for example if it failed
+// to be admitted by kubelet.
+func podFailedAt(pod *corev1.Pod) time.Time {
+	var failedAt time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(failedAt) {
+			failedAt = terminated.FinishedAt.Time
+		}
+	}
+	if failedAt.IsZero() {
+		failedAt = pod.CreationTimestamp.Time
+	}
+	return failedAt
+}
+
+// podFailureReason returns a human-readable reason for the failure of given `pod`.
+func podFailureReason(pod *corev1.Pod) string {
+	if pod.Status.Message != "" {
+		return pod.Status.Message
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Sprintf("container %s exited with %d (%s)", status.Name, terminated.ExitCode, terminated.Reason)
+		}
+	}
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	return "unknown reason"
+}
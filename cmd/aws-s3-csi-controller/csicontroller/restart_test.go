@@ -0,0 +1,148 @@
+package csicontroller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestRestartingFailedMountpointPods(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+	restartPolicy := csicontroller.RestartPolicy{MaxRestarts: 2, InitialBackoff: time.Minute, MaxBackoff: time.Hour}
+
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{PersistentVolumeSource: corev1.PersistentVolumeSource{
+			CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+		}},
+	}
+	failedMountpointPod := func(restarts string, failedAgo time.Duration) *corev1.Pod {
+		mpPod := mppod.NewCreator(podConfig).Create(workloadPod, pv)
+		if restarts != "" {
+			mpPod.Annotations = map[string]string{mppod.AnnotationRestartCount: restarts}
+		}
+		mpPod.Status = corev1.PodStatus{
+			Phase:  corev1.PodFailed,
+			Reason: "Evicted",
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "mountpoint",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode:   137,
+					Reason:     "OOMKilled",
+					FinishedAt: metav1.NewTime(time.Now().Add(-failedAgo)),
+				}},
+			}},
+		}
+		return mpPod
+	}
+
+	setup := func(t *testing.T, objects ...client.Object) (client.Client, *record.FakeRecorder, *csicontroller.Reconciler) {
+		t.Helper()
+		c := fake.NewClientBuilder().
+			WithObjects(objects...).
+			WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+				return []string{string(o.GetUID())}
+			}).
+			Build()
+		recorder := record.NewFakeRecorder(10)
+		return c, recorder, csicontroller.NewReconciler(c, recorder, podConfig, restartPolicy)
+	}
+	reconcileMountpointPod := func(t *testing.T, r *csicontroller.Reconciler, mpPod *corev1.Pod) reconcile.Result {
+		t.Helper()
+		res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: mpPod.Namespace, Name: mpPod.Name}})
+		assert.NoError(t, err)
+		return res
+	}
+	getMountpointPod := func(t *testing.T, c client.Client, mpPod *corev1.Pod) (*corev1.Pod, error) {
+		t.Helper()
+		got := &corev1.Pod{}
+		err := c.Get(context.Background(), types.NamespacedName{Namespace: mpPod.Namespace, Name: mpPod.Name}, got)
+		return got, err
+	}
+	assertEvent := func(t *testing.T, recorder *record.FakeRecorder, reason string) {
+		t.Helper()
+		select {
+		case event := <-recorder.Events:
+			if !strings.Contains(event, reason) || !strings.Contains(event, "OOMKilled") {
+				t.Fatalf("Unexpected event %q, expected reason %q", event, reason)
+			}
+		default:
+			t.Fatalf("Expected an event with reason %q", reason)
+		}
+	}
+
+	t.Run("waits for backoff before restarting", func(t *testing.T) {
+		mpPod := failedMountpointPod("1", time.Minute)
+		c, recorder, r := setup(t, workloadPod.DeepCopy(), pv.DeepCopy(), mpPod)
+
+		res := reconcileMountpointPod(t, r, mpPod)
+		// Second restart waits for 2 minutes, and the Pod failed a minute ago.
+		if res.RequeueAfter <= 0 || res.RequeueAfter > time.Minute {
+			t.Fatalf("Expected to requeue within a minute, got %v", res.RequeueAfter)
+		}
+
+		got, err := getMountpointPod(t, c, mpPod)
+		assert.NoError(t, err)
+		assert.Equals(t, corev1.PodFailed, got.Status.Phase)
+		assert.Equals(t, 0, len(recorder.Events))
+	})
+
+	t.Run("respawns after backoff", func(t *testing.T) {
+		mpPod := failedMountpointPod("1", 3*time.Minute)
+		c, recorder, r := setup(t, workloadPod.DeepCopy(), pv.DeepCopy(), mpPod)
+
+		res := reconcileMountpointPod(t, r, mpPod)
+		assert.Equals(t, reconcile.Result{}, res)
+
+		got, err := getMountpointPod(t, c, mpPod)
+		assert.NoError(t, err)
+		assert.Equals(t, corev1.PodPhase(""), got.Status.Phase)
+		assert.Equals(t, "2", got.Annotations[mppod.AnnotationRestartCount])
+		assert.Equals(t, "workload-uid", got.Labels[mppod.LabelPodUID])
+		assertEvent(t, recorder, "MountpointPodRestarted")
+	})
+
+	t.Run("stops restarting after the limit", func(t *testing.T) {
+		mpPod := failedMountpointPod("2", time.Hour)
+		c, recorder, r := setup(t, workloadPod.DeepCopy(), pv.DeepCopy(), mpPod)
+
+		res := reconcileMountpointPod(t, r, mpPod)
+		assert.Equals(t, reconcile.Result{}, res)
+
+		got, err := getMountpointPod(t, c, mpPod)
+		assert.NoError(t, err)
+		assert.Equals(t, corev1.PodFailed, got.Status.Phase)
+		assertEvent(t, recorder, "MountpointPodRestartLimitReached")
+	})
+
+	t.Run("deletes if workload Pod does not exist", func(t *testing.T) {
+		mpPod := failedMountpointPod("", time.Hour)
+		c, recorder, r := setup(t, pv.DeepCopy(), mpPod)
+
+		res := reconcileMountpointPod(t, r, mpPod)
+		assert.Equals(t, reconcile.Result{}, res)
+
+		_, err := getMountpointPod(t, c, mpPod)
+		assert.Equals(t, true, apierrors.IsNotFound(err))
+		assert.Equals(t, 0, len(recorder.Events))
+	})
+}
@@ -0,0 +1,634 @@
+package csicontroller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/capacity"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/platform"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// SchedulingGateName is the scheduling gate injected into workload Pods using S3 volumes,
+// which is removed once their Mountpoint Pods are running.
+const SchedulingGateName = "s3.csi.aws.com/mountpoint-pod"
+
+// LabelSchedulingGated is populated on workload Pods with [SchedulingGateName] to find them without caching
+// unscheduled Pods, it's removed along with the gate.
+const LabelSchedulingGated = "s3.csi.aws.com/scheduling-gated"
+
+// AnnotationCandidateNode is populated on gated workload Pods with the node their Mountpoint Pods are spawned to.
+const AnnotationCandidateNode = "s3.csi.aws.com/candidate-node"
+
+// SchedulingGateWebhookPath is the path the scheduling gate webhook is served at.
+const SchedulingGateWebhookPath = "/mutate-scheduling-gate"
+
+// DefaultSchedulingGateTimeout is the default duration after which workload Pods are ungated even if their
+// Mountpoint Pods are not running, so they're scheduled as if they were never gated.
+const DefaultSchedulingGateTimeout = 5 * time.Minute
+
+// schedulingGateSyncInterval is the interval to check gated workload Pods.
+const schedulingGateSyncInterval = 5 * time.Second
+
+// podNodeNameIndexField is the field index to lookup Pods by the node they're scheduled to.
+const podNodeNameIndexField = "spec.nodeName"
+
+// candidateNodeAffinityWeight is the weight of the preferred node affinity term added to ungated workload Pods
+// for their candidate node, which is the highest weight to outweigh other preferences of the Pods.
+const candidateNodeAffinityWeight = 100
+
+// Reasons of the events recorded on gated workload Pods.
+const (
+	eventReasonSchedulingGateRemoved  = "MountpointPodCapacityReserved"
+	eventReasonSchedulingGateTimedOut = "MountpointPodCapacityTimeout"
+)
+
+// A SchedulingGateInjector injects [SchedulingGateName] into workload Pods using S3 volumes on their creation.
+type SchedulingGateInjector struct {
+	client.Reader
+	decoder             admission.Decoder
+	mountpointNamespace string
+}
+
+// NewSchedulingGateInjector returns a new injector for workload Pods outside of `mountpointNamespace`,
+// using given `decoder` to decode Pods.
+func NewSchedulingGateInjector(reader client.Reader, decoder admission.Decoder, mountpointNamespace string) *SchedulingGateInjector {
+	return &SchedulingGateInjector{Reader: reader, decoder: decoder, mountpointNamespace: mountpointNamespace}
+}
+
+// SetupWithManager registers the injector as a webhook on given `mgr`'s webhook server.
+// The webhook needs to be configured with a MutatingWebhookConfiguration for `CREATE` operations on `pods`,
+// preferably with `failurePolicy: Ignore` so Pods are still created without the gate if the webhook is unavailable.
+func (i *SchedulingGateInjector) SetupWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(SchedulingGateWebhookPath, &webhook.Admission{Handler: i})
+}
+
+// Handle injects [SchedulingGateName] into the Pod in `req` if it uses S3 volumes.
+func (i *SchedulingGateInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create || req.Kind.Kind != "Pod" || req.SubResource != "" || req.Namespace == i.mountpointNamespace {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := i.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if pod.Spec.NodeName != "" || slices.ContainsFunc(pod.Spec.SchedulingGates, isMountpointSchedulingGate) {
+		return admission.Allowed("")
+	}
+	// The namespace of Pods is not set in the object if they're created with a namespaced request.
+	pod.Namespace = req.Namespace
+
+	usesS3, err := i.usesS3Volumes(ctx, pod)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to check if Pod uses S3 volumes - not gating it", "pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+		return admission.Allowed("")
+	}
+	if !usesS3 {
+		return admission.Allowed("")
+	}
+
+	pod.Spec.SchedulingGates = append(pod.Spec.SchedulingGates, corev1.PodSchedulingGate{Name: SchedulingGateName})
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[LabelSchedulingGated] = "true"
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// usesS3Volumes returns whether `pod` has any volumes backed by PVCs bound to S3 volumes, or provisioned
+// by the CSI Driver if they're not bound yet, which are not opted out of Mountpoint Pods.
+func (i *SchedulingGateInjector) usesS3Volumes(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	for _, vol := range pod.Spec.Volumes {
+		var storageClassName *string
+		switch {
+		case vol.Ephemeral != nil && vol.Ephemeral.VolumeClaimTemplate != nil:
+			storageClassName = vol.Ephemeral.VolumeClaimTemplate.Spec.StorageClassName
+		case vol.PersistentVolumeClaim != nil:
+			pvc := &corev1.PersistentVolumeClaim{}
+			err := i.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: vol.PersistentVolumeClaim.ClaimName}, pvc)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if pvc.Spec.VolumeName != "" {
+				pv := &corev1.PersistentVolume{}
+				if err := i.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+					return false, client.IgnoreNotFound(err)
+				}
+				if csiSpec := extractCSISpecFromPV(pv); csiSpec != nil {
+					optedOut, err := isOptedOutOfMountpointPods(ctx, i, pod.Namespace, csiSpec.VolumeAttributes)
+					if err != nil || !optedOut {
+						return err == nil, err
+					}
+				}
+				continue
+			}
+			storageClassName = pvc.Spec.StorageClassName
+		}
+
+		if storageClassName == nil || *storageClassName == "" {
+			continue
+		}
+		sc := &storagev1.StorageClass{}
+		err := i.Get(ctx, types.NamespacedName{Name: *storageClassName}, sc)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if sc.Provisioner == mountpointCSIDriverName {
+			optedOut, err := isOptedOutOfMountpointPods(ctx, i, pod.Namespace, sc.Parameters)
+			if err != nil || !optedOut {
+				return err == nil, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// A SchedulingGateController removes [SchedulingGateName] from workload Pods once Mountpoint Pods for their volumes
+// are running in a node the workload Pods fit in.
+//
+// Without the gate, workload Pods are scheduled before their Mountpoint Pods are spawned, and they wait in
+// `ContainerCreating` until their Mountpoint Pods are running, or forever if their Mountpoint Pods don't fit in the node.
+// For gated Pods, the controller picks a candidate node with room for both the workload Pod and its Mountpoint Pods,
+// spawns the Mountpoint Pods there, and once they're running, makes the workload Pod prefer the candidate node
+// and removes the gate. The scheduler remains the authority on where the workload Pod runs, if it picks another node,
+// the reconciler replaces the Mountpoint Pods in the candidate node (see [Reconciler.spawnOrDeleteMountpointPodIfNeeded]).
+// Gated Pods are ungated without any preference after a timeout, or if their volumes are not bound yet as their
+// Mountpoint Pods can't be spawned before that.
+type SchedulingGateController struct {
+	reconciler *Reconciler
+	// reader reads gated Pods directly from the API server, as unscheduled Pods are not cached.
+	reader  client.Reader
+	timeout time.Duration
+}
+
+// NewSchedulingGateController returns a new controller spawning Mountpoint Pods for gated workload Pods with
+// `reconciler`, and ungating them after `timeout` at the latest. Gated Pods are read with `reader`.
+func NewSchedulingGateController(reconciler *Reconciler, reader client.Reader, timeout time.Duration) *SchedulingGateController {
+	return &SchedulingGateController{reconciler: reconciler, reader: reader, timeout: timeout}
+}
+
+// SetupWithManager indexes cached Pods by their nodes to compute free resources of nodes, and adds the controller
+// to given `mgr`.
+func (c *SchedulingGateController) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, podNodeNameIndexField, func(o client.Object) []string {
+		return []string{o.(*corev1.Pod).Spec.NodeName}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index Pods by node name: %w", err)
+	}
+	return mgr.Add(c)
+}
+
+// Start syncs gated workload Pods periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (c *SchedulingGateController) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("scheduling-gate")
+
+	ticker := time.NewTicker(schedulingGateSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Sync(ctx); err != nil {
+				log.Error(err, "Failed to sync gated Pods")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Sync checks all gated workload Pods once, and ungates the ones whose Mountpoint Pods are running.
+func (c *SchedulingGateController) Sync(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("scheduling-gate")
+
+	list := &corev1.PodList{}
+	if err := c.reader.List(ctx, list, client.MatchingLabels{LabelSchedulingGated: "true"}); err != nil {
+		return fmt.Errorf("failed to list gated Pods: %w", err)
+	}
+
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if ok, err := c.reconciler.inShard(ctx, pod); err != nil || !ok {
+			continue
+		}
+		if err := c.syncPod(ctx, pod); err != nil {
+			log.Error(err, "Failed to sync gated Pod", "pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+		}
+	}
+	return nil
+}
+
+// syncPod spawns Mountpoint Pods for gated workload `pod` in a candidate node, and ungates it once they're running.
+func (c *SchedulingGateController) syncPod(ctx context.Context, pod *corev1.Pod) error {
+	log := logf.FromContext(ctx).WithName("scheduling-gate").WithValues("pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+
+	if !slices.ContainsFunc(pod.Spec.SchedulingGates, isMountpointSchedulingGate) {
+		return c.ungate(ctx, pod, "")
+	}
+
+	pvs, err := c.reconciler.s3Volumes(ctx, pod)
+	if errors.Is(err, errPVCIsNotBoundToAPV) {
+		log.Info("Pod has unbound volumes, Mountpoint Pods can't be spawned before it's scheduled - ungating it")
+		return c.ungate(ctx, pod, "")
+	}
+	if err != nil {
+		return err
+	}
+	if len(pvs) == 0 {
+		log.Info("Pod has no volumes provided by Mountpoint Pods - ungating it")
+		return c.ungate(ctx, pod, "")
+	}
+
+	candidate := pod.Annotations[AnnotationCandidateNode]
+	if gatedFor := time.Since(pod.CreationTimestamp.Time); gatedFor > c.timeout {
+		if err := c.deleteMountpointPods(ctx, pod, pvs); err != nil {
+			return err
+		}
+		c.reconciler.recorder.Eventf(pod, corev1.EventTypeWarning, eventReasonSchedulingGateTimedOut,
+			"Mountpoint Pods are not running after %s, scheduling Pod without reserved capacity for them", gatedFor.Round(time.Second))
+		log.Info("Mountpoint Pods are not running before timeout - ungating Pod", "candidateNode", candidate, "gatedFor", gatedFor)
+		return c.ungate(ctx, pod, "")
+	}
+
+	if candidate == "" {
+		candidate, err = c.selectCandidateNode(ctx, pod, pvs)
+		if err != nil {
+			return err
+		}
+		if candidate == "" {
+			log.V(debugLevel).Info("No node has room for Pod and its Mountpoint Pods yet - waiting")
+			return nil
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[AnnotationCandidateNode] = candidate
+		if err := c.reconciler.Patch(ctx, pod, patch); err != nil {
+			return fmt.Errorf("failed to record candidate node: %w", err)
+		}
+		log.Info("Selected candidate node for Pod and its Mountpoint Pods", "candidateNode", candidate)
+	}
+
+	// Mountpoint Pods are spawned as if the workload Pod was scheduled to the candidate node, which then
+	// the reconciler finds once the workload Pod is scheduled there.
+	scheduled := pod.DeepCopy()
+	scheduled.Spec.NodeName = candidate
+	running := true
+	for _, pv := range pvs {
+		name := mppod.MountpointPodNameFor(string(pod.UID), pv.Name)
+		mpPod := &corev1.Pod{}
+		err := c.reconciler.Get(ctx, types.NamespacedName{Namespace: c.reconciler.mountpointPodConfig.Namespace, Name: name}, mpPod)
+		if apierrors.IsNotFound(err) {
+			if err := c.reconciler.spawnMountpointPod(ctx, scheduled, pv, name, 0); err != nil && !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			running = false
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get Mountpoint Pod %s: %w", name, err)
+		}
+		running = running && mpPod.Status.Phase == corev1.PodRunning
+	}
+	if !running {
+		log.V(debugLevel).Info("Mountpoint Pods are not running yet - waiting", "candidateNode", candidate)
+		return nil
+	}
+
+	c.reconciler.recorder.Eventf(pod, corev1.EventTypeNormal, eventReasonSchedulingGateRemoved,
+		"Mountpoint Pods are running in node %s, scheduling Pod to it", candidate)
+	log.Info("Mountpoint Pods are running - ungating Pod", "candidateNode", candidate)
+	return c.ungate(ctx, pod, candidate)
+}
+
+// s3Volumes returns the S3 volumes of `pod` not opted out of Mountpoint Pods, or [errPVCIsNotBoundToAPV] if any of its PVCs are not bound yet.
+func (r *Reconciler) s3Volumes(ctx context.Context, pod *corev1.Pod) ([]*corev1.PersistentVolume, error) {
+	var pvs []*corev1.PersistentVolume
+	for _, vol := range pod.Spec.Volumes {
+		podPVC := vol.PersistentVolumeClaim
+		if vol.Ephemeral != nil {
+			podPVC = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pod.Name + "-" + vol.Name}
+		}
+		if podPVC == nil {
+			continue
+		}
+		_, pv, err := r.getBoundPVForPodClaim(ctx, pod, podPVC)
+		if err != nil {
+			return nil, err
+		}
+		csiSpec := extractCSISpecFromPV(pv)
+		if csiSpec == nil {
+			continue
+		}
+		optedOut, err := isOptedOutOfMountpointPods(ctx, r, pod.Namespace, csiSpec.VolumeAttributes)
+		if err != nil {
+			return nil, err
+		}
+		if !optedOut {
+			pvs = append(pvs, pv)
+		}
+	}
+	return pvs, nil
+}
+
+// selectCandidateNode returns the node with the most free memory that `pod` and the Mountpoint Pods of its `pvs`
+// fit in, and that has spare Mountpoint capacity for them if it's advertised, or an empty string if there are no such nodes.
+func (c *SchedulingGateController) selectCandidateNode(ctx context.Context, pod *corev1.Pod, pvs []*corev1.PersistentVolume) (string, error) {
+	nodes := &corev1.NodeList{}
+	if err := c.reconciler.List(ctx, nodes); err != nil {
+		return "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	mountpointPods := len(pvs)
+	requests := podRequests(pod)
+	if resources := c.reconciler.mountpointPodCreator.Resources(); resources != nil {
+		for range mountpointPods {
+			addResources(requests, resources.Requests)
+		}
+	}
+
+	var candidate string
+	var candidateFreeMemory resource.Quantity
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !isNodeFeasible(node, pod, pvs, c.reconciler.mountpointPodConfig.NodeSelector) {
+			continue
+		}
+
+		free, err := c.freeResources(ctx, node)
+		if err != nil {
+			return "", err
+		}
+		if !fits(requests, free) {
+			continue
+		}
+		// Nodes limiting their Mountpoint processes would fail to mount volumes of the Pod without spare capacity.
+		if spare, ok := capacity.Spare(node); ok && spare < mountpointPods {
+			continue
+		}
+		if freeMemory := free[corev1.ResourceMemory]; candidate == "" || freeMemory.Cmp(candidateFreeMemory) > 0 {
+			candidate, candidateFreeMemory = node.Name, freeMemory
+		}
+	}
+	return candidate, nil
+}
+
+// freeResources returns the allocatable resources of `node` not requested by Pods running in it.
+func (c *SchedulingGateController) freeResources(ctx context.Context, node *corev1.Node) (corev1.ResourceList, error) {
+	pods := &corev1.PodList{}
+	if err := c.reconciler.List(ctx, pods, client.MatchingFields{podNodeNameIndexField: node.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list Pods in node %s: %w", node.Name, err)
+	}
+
+	free := node.Status.Allocatable.DeepCopy()
+	for i := range pods.Items {
+		if !isPodActive(&pods.Items[i]) {
+			continue
+		}
+		for name, quantity := range podRequests(&pods.Items[i]) {
+			if value, ok := free[name]; ok {
+				value.Sub(quantity)
+				free[name] = value
+			}
+		}
+	}
+	return free, nil
+}
+
+// ungate removes [SchedulingGateName] and [LabelSchedulingGated] from `pod`, and makes it prefer `node` if it's not empty.
+// The node is only a preference rather than a requirement, as node affinity is immutable once Pods are ungated, and
+// a required node would leave `pod` unschedulable forever if the node is gone or filled up before it's scheduled.
+func (c *SchedulingGateController) ungate(ctx context.Context, pod *corev1.Pod, node string) error {
+	pod.Spec.SchedulingGates = slices.DeleteFunc(pod.Spec.SchedulingGates, isMountpointSchedulingGate)
+	delete(pod.Labels, LabelSchedulingGated)
+
+	if node != "" {
+		if pod.Spec.Affinity == nil {
+			pod.Spec.Affinity = &corev1.Affinity{}
+		}
+		if pod.Spec.Affinity.NodeAffinity == nil {
+			pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		nodeAffinity := pod.Spec.Affinity.NodeAffinity
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, corev1.PreferredSchedulingTerm{
+			Weight: candidateNodeAffinityWeight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchFields: []corev1.NodeSelectorRequirement{{Key: metav1.ObjectNameField, Operator: corev1.NodeSelectorOpIn, Values: []string{node}}},
+			},
+		})
+	}
+
+	if err := c.reconciler.Update(ctx, pod); err != nil {
+		return fmt.Errorf("failed to remove scheduling gate: %w", err)
+	}
+	return nil
+}
+
+// deleteMountpointPods deletes Mountpoint Pods spawned for gated `pod` in its candidate node, as they'd conflict
+// with the Mountpoint Pods of the node it's eventually scheduled to.
+func (c *SchedulingGateController) deleteMountpointPods(ctx context.Context, pod *corev1.Pod, pvs []*corev1.PersistentVolume) error {
+	for _, pv := range pvs {
+		mpPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: c.reconciler.mountpointPodConfig.Namespace,
+			Name:      mppod.MountpointPodNameFor(string(pod.UID), pv.Name),
+		}}
+		if err := c.reconciler.Delete(ctx, mpPod); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete Mountpoint Pod %s: %w", mpPod.Name, err)
+		}
+	}
+	return nil
+}
+
+// isNodeFeasible returns whether `pod` with `pvs` and its Mountpoint Pods constrained by `mountpointNodeSelector`
+// can be scheduled to `node` based on its readiness, node constraints of `pod` and `pvs`, and taints of `node`.
+//
+// It filters out nodes the scheduler would never pick for `pod`, rather than replicating the scheduler, so other
+// constraints like inter-Pod affinities or topology spread constraints are not evaluated. They might make the scheduler
+// pick another node, as the candidate node is only a preference for `pod` (see [SchedulingGateController.ungate]).
+func isNodeFeasible(node *corev1.Node, pod *corev1.Pod, pvs []*corev1.PersistentVolume, mountpointNodeSelector map[string]string) bool {
+	if node.Spec.Unschedulable || platform.CheckNode(node) != nil {
+		return false
+	}
+	ready := slices.ContainsFunc(node.Status.Conditions, func(c corev1.NodeCondition) bool {
+		return c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue
+	})
+	if !ready {
+		return false
+	}
+	nodeLabels := labels.Set(node.Labels)
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(nodeLabels) || !labels.SelectorFromSet(mountpointNodeSelector).Matches(nodeLabels) {
+		return false
+	}
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil && !matchesNodeSelector(node, affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution) {
+		return false
+	}
+	for _, pv := range pvs {
+		if pv.Spec.NodeAffinity != nil && !matchesNodeSelector(node, pv.Spec.NodeAffinity.Required) {
+			return false
+		}
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := slices.ContainsFunc(pod.Spec.Tolerations, func(t corev1.Toleration) bool { return t.ToleratesTaint(taint) })
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesNodeSelector returns whether `node` matches any of the terms of `selector`, or true if `selector` is nil.
+func matchesNodeSelector(node *corev1.Node, selector *corev1.NodeSelector) bool {
+	if selector == nil {
+		return true
+	}
+	return slices.ContainsFunc(selector.NodeSelectorTerms, func(term corev1.NodeSelectorTerm) bool {
+		return matchesNodeSelectorTerm(node, term)
+	})
+}
+
+// matchesNodeSelectorTerm returns whether `node` matches all requirements of `term`, a term without
+// any requirements matches no nodes as in the scheduler.
+func matchesNodeSelectorTerm(node *corev1.Node, term corev1.NodeSelectorTerm) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, expr := range term.MatchExpressions {
+		op, ok := nodeSelectorOperators[expr.Operator]
+		if !ok {
+			return false
+		}
+		requirement, err := labels.NewRequirement(expr.Key, op, expr.Values)
+		if err != nil || !requirement.Matches(labels.Set(node.Labels)) {
+			return false
+		}
+	}
+	// `metadata.name` is the only field supported in node selector terms.
+	for _, field := range term.MatchFields {
+		if field.Key != metav1.ObjectNameField {
+			return false
+		}
+		switch field.Operator {
+		case corev1.NodeSelectorOpIn:
+			if !slices.Contains(field.Values, node.Name) {
+				return false
+			}
+		case corev1.NodeSelectorOpNotIn:
+			if slices.Contains(field.Values, node.Name) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// nodeSelectorOperators maps operators of node selector requirements to label selector operators.
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// podRequests returns the resources requested by `pod`, which is the larger of the sum of its containers' requests
+// and the requests of any of its init containers, plus its overhead.
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if value, ok := requests[name]; !ok || quantity.Cmp(value) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResources(requests, pod.Spec.Overhead)
+	return requests
+}
+
+// addResources adds `resources` to `to`.
+func addResources(to, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		value := to[name]
+		value.Add(quantity)
+		to[name] = value
+	}
+}
+
+// fits returns whether `requests` fit in `free` resources, resources not known by `free` are assumed to fit.
+func fits(requests, free corev1.ResourceList) bool {
+	for name, quantity := range requests {
+		if value, ok := free[name]; ok && quantity.Cmp(value) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mountpointPodNode returns the node `mpPod` is scheduled to, or the node it's pinned to if it's not scheduled yet.
+func mountpointPodNode(mpPod *corev1.Pod) string {
+	if mpPod.Spec.NodeName != "" {
+		return mpPod.Spec.NodeName
+	}
+	if mpPod.Spec.Affinity == nil || mpPod.Spec.Affinity.NodeAffinity == nil || mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, field := range term.MatchFields {
+			if field.Key == metav1.ObjectNameField && field.Operator == corev1.NodeSelectorOpIn && len(field.Values) == 1 {
+				return field.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+func isMountpointSchedulingGate(gate corev1.PodSchedulingGate) bool {
+	return gate.Name == SchedulingGateName
+}
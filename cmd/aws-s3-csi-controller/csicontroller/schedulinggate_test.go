@@ -0,0 +1,230 @@
+package csicontroller_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/capacity"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestSchedulingGateInjector(t *testing.T) {
+	claim := func(name, volumeName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+		}
+	}
+	volume := func(name, driver string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name},
+			}},
+		}
+	}
+	c := fake.NewClientBuilder().WithObjects(
+		claim("s3-claim", "s3-pv"), volume("s3-pv", "s3.csi.aws.com"),
+		claim("ebs-claim", "ebs-pv"), volume("ebs-pv", "ebs.csi.aws.com"),
+	).Build()
+	injector := csicontroller.NewSchedulingGateInjector(c, admission.NewDecoder(scheme.Scheme), mountpointNamespace)
+
+	handle := func(namespace, claimName string) admission.Response {
+		pod := &corev1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Name: "workload"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			}}}},
+		}
+		raw, err := json.Marshal(pod)
+		assert.NoError(t, err)
+		return injector.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+			Namespace: namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		}})
+	}
+
+	resp := handle("default", "s3-claim")
+	assert.Equals(t, true, resp.Allowed)
+	patched := map[string]any{}
+	for _, op := range resp.Patches {
+		patched[op.Path] = op.Value
+	}
+	assert.Equals(t, []any{map[string]any{"name": csicontroller.SchedulingGateName}}, patched["/spec/schedulingGates"])
+	assert.Equals(t, map[string]any{csicontroller.LabelSchedulingGated: "true"}, patched["/metadata/labels"])
+
+	// Pods not using S3 volumes are not gated
+	for _, claimName := range []string{"ebs-claim", "missing-claim"} {
+		resp := handle("default", claimName)
+		assert.Equals(t, true, resp.Allowed)
+		assert.Equals(t, 0, len(resp.Patches))
+	}
+}
+
+func TestSchedulingGateController(t *testing.T) {
+	newNode := func(name, memory string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)},
+				Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+	newGatedPod := func(name string, createdAt time.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "default", UID: types.UID(name + "-uid"),
+				Labels:            map[string]string{csicontroller.LabelSchedulingGated: "true"},
+				CreationTimestamp: metav1.NewTime(createdAt),
+			},
+			Spec: corev1.PodSpec{
+				SchedulingGates: []corev1.PodSchedulingGate{{Name: csicontroller.SchedulingGateName}},
+				Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+				}}},
+				Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+				}}},
+			},
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+	// The workload Pod alone fits in the small node, but not along with its Mountpoint Pod
+	smallNode, largeNode := newNode("small-node", "2.25Gi"), newNode("large-node", "8Gi")
+	// The full node has the most free memory, but it's already running as many Mountpoints as it can
+	fullNode := newNode("full-node", "16Gi")
+	fullNode.Annotations = map[string]string{capacity.AnnotationMaxMountpoints: "4", capacity.AnnotationActiveMountpoints: "4"}
+	busyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "busy", Namespace: "default"},
+		Spec: corev1.PodSpec{NodeName: "large-node", Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("5Gi")},
+		}}}},
+	}
+	// The medium node would have the most free memory if Pods cached without S3 volumes were counted as requesting nothing
+	mediumNode := newNode("medium-node", "6Gi")
+	transform := csicontroller.PodCacheOptions(mountpointNamespace).Namespaces[cache.AllNamespaces].Transform
+	trimmed, err := transform(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "non-s3", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName: "medium-node",
+			Containers: []corev1.Container{{Name: "app", Image: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			}}},
+			Volumes: []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	assert.NoError(t, err)
+	nonS3Pod := trimmed.(*corev1.Pod)
+	assert.Equals(t, 0, len(nonS3Pod.Spec.Volumes))
+
+	// The pinned workload Pod can't be scheduled to the large node, and it doesn't fit in other nodes
+	pinnedPod := newGatedPod("pinned-workload", time.Now())
+	pinnedPod.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchFields: []corev1.NodeSelectorRequirement{{Key: metav1.ObjectNameField, Operator: corev1.NodeSelectorOpNotIn, Values: []string{"large-node"}}},
+		}}},
+	}}
+
+	c := fake.NewClientBuilder().
+		WithObjects(smallNode, largeNode, mediumNode, fullNode, busyPod, nonS3Pod, pvc, pv, pinnedPod, newGatedPod("workload", time.Now()), newGatedPod("stuck-workload", time.Now().Add(-time.Hour))).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+			return []string{o.(*corev1.Pod).Spec.NodeName}
+		}).
+		Build()
+	recorder := record.NewFakeRecorder(10)
+	r := csicontroller.NewReconciler(c, recorder, mppod.Config{Namespace: mountpointNamespace}, csicontroller.DefaultRestartPolicy)
+	r.MountpointPodCreator().SetResources(corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+	})
+	controller := csicontroller.NewSchedulingGateController(r, c, csicontroller.DefaultSchedulingGateTimeout)
+
+	getPod := func(namespace, name string) *corev1.Pod {
+		t.Helper()
+		pod := &corev1.Pod{}
+		assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, pod))
+		return pod
+	}
+
+	// Pods gated for longer than the timeout are ungated without any constraints
+	assert.NoError(t, controller.Sync(context.Background()))
+	stuck := getPod("default", "stuck-workload")
+	assert.Equals(t, 0, len(stuck.Spec.SchedulingGates))
+	assert.Equals(t, "", stuck.Labels[csicontroller.LabelSchedulingGated])
+	assert.Equals(t, (*corev1.Affinity)(nil), stuck.Spec.Affinity)
+
+	// The Mountpoint Pod is spawned in the node with room for both the workload and the Mountpoint Pod
+	workload := getPod("default", "workload")
+	assert.Equals(t, "large-node", workload.Annotations[csicontroller.AnnotationCandidateNode])
+	assert.Equals(t, 1, len(workload.Spec.SchedulingGates))
+	mpPodName := mppod.MountpointPodNameFor("workload-uid", "s3-pv")
+	mpPod := getPod(mountpointNamespace, mpPodName)
+	assert.Equals(t, "large-node", mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchFields[0].Values[0])
+
+	// Required node affinity of the workload Pod is respected
+	pinned := getPod("default", "pinned-workload")
+	assert.Equals(t, "", pinned.Annotations[csicontroller.AnnotationCandidateNode])
+	assert.Equals(t, 1, len(pinned.Spec.SchedulingGates))
+
+	// The workload Pod is ungated and prefers the node once its Mountpoint Pod is running
+	mpPod.Status.Phase = corev1.PodRunning
+	assert.NoError(t, c.Status().Update(context.Background(), mpPod))
+	assert.NoError(t, controller.Sync(context.Background()))
+	workload = getPod("default", "workload")
+	assert.Equals(t, 0, len(workload.Spec.SchedulingGates))
+	assert.Equals(t, (*corev1.NodeSelector)(nil), workload.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+	assert.Equals(t, []corev1.PreferredSchedulingTerm{{Weight: 100, Preference: corev1.NodeSelectorTerm{MatchFields: []corev1.NodeSelectorRequirement{{
+		Key: metav1.ObjectNameField, Operator: corev1.NodeSelectorOpIn, Values: []string{"large-node"},
+	}}}}}, workload.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+
+	// The Mountpoint Pod is respawned in the node the workload Pod is scheduled to if the scheduler picks another node
+	workload.Spec.NodeName = "small-node"
+	assert.NoError(t, c.Update(context.Background(), workload))
+	reconcileWorkload := func() {
+		t.Helper()
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload"}})
+		assert.NoError(t, err)
+	}
+	reconcileWorkload()
+	err = c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: mpPodName}, &corev1.Pod{})
+	assert.Equals(t, true, apierrors.IsNotFound(err))
+	reconcileWorkload()
+	mpPod = getPod(mountpointNamespace, mpPodName)
+	assert.Equals(t, "small-node", mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchFields[0].Values[0])
+}
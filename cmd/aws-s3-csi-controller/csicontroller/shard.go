@@ -0,0 +1,95 @@
+package csicontroller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// A NamespaceShard is the set of namespaces whose workload Pods a controller is responsible for, selected by labels
+// of the namespaces. It allows running multiple controllers against a large cluster, each reconciling a disjoint set
+// of namespaces and the Mountpoint Pods of their workload Pods.
+//
+// A nil NamespaceShard contains all namespaces.
+type NamespaceShard struct {
+	selector labels.Selector
+
+	client.Reader
+}
+
+// NewNamespaceShard returns a new shard of namespaces matching `selector`.
+func NewNamespaceShard(reader client.Reader, selector labels.Selector) *NamespaceShard {
+	return &NamespaceShard{Reader: reader, selector: selector}
+}
+
+// Contains returns whether workload Pods in given `namespace` belong to the shard.
+// Namespaces that do not exist anymore belong to every shard, so Mountpoint Pods left behind by their workload Pods
+// are cleaned up by any controller.
+func (s *NamespaceShard) Contains(ctx context.Context, namespace string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := s.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return s.selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// ContainsMountpointPod returns whether given Mountpoint Pod belongs to the shard, i.e. whether the namespace of
+// its workload Pod in [mppod.AnnotationWorkloadPod] belongs to the shard. Mountpoint Pods without the annotation
+// don't belong to any shard.
+func (s *NamespaceShard) ContainsMountpointPod(ctx context.Context, pod client.Object) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	namespace, _, ok := strings.Cut(pod.GetAnnotations()[mppod.AnnotationWorkloadPod], "/")
+	if !ok {
+		return false, nil
+	}
+	return s.Contains(ctx, namespace)
+}
+
+// SetNamespaceShard limits the reconciler to workload Pods in the namespaces of `shard` and their Mountpoint Pods,
+// it must be called before `SetupWithManager`.
+func (r *Reconciler) SetNamespaceShard(shard *NamespaceShard) {
+	r.shard = shard
+}
+
+// inShard returns whether given Pod, either a workload or a Mountpoint Pod, belongs to the shard of the reconciler.
+func (r *Reconciler) inShard(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	if r.isMountpointPod(pod) {
+		return r.shard.ContainsMountpointPod(ctx, pod)
+	}
+	return r.shard.Contains(ctx, pod.Namespace)
+}
+
+// requestsForNamespace maps events of namespaces to requests of their workload Pods, so workload Pods are reconciled
+// once their namespace's labels are changed to match the shard of the reconciler.
+func (r *Reconciler) requestsForNamespace(ctx context.Context, o client.Object) []reconcile.Request {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(o.GetName())); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Pods of namespace", "namespace", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range pods.Items {
+		if hasClaimVolumes(&pods.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetName(), Name: pods.Items[i].Name}})
+		}
+	}
+	return requests
+}
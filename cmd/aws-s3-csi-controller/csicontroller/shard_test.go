@@ -0,0 +1,88 @@
+package csicontroller_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestReconcilingOnlyWorkloadPodsInNamespaceShard(t *testing.T) {
+	podConfig := mppod.Config{Namespace: mountpointNamespace}
+
+	newNamespace := func(name, shard string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"shard": shard}}}
+	}
+	newWorkloadPod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: namespace, UID: types.UID(namespace + "-workload-uid")},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+				}}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodPending},
+		}
+	}
+	newPVC := func(namespace string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: namespace},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: namespace + "-pv"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		}
+	}
+	newPV := func(namespace string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace + "-pv"},
+			Spec: corev1.PersistentVolumeSpec{
+				ClaimRef: &corev1.ObjectReference{Namespace: namespace, Name: "s3-claim"},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+				},
+			},
+		}
+	}
+
+	c := fake.NewClientBuilder().
+		WithObjects(
+			newNamespace("tenant-a", "a"), newWorkloadPod("tenant-a"), newPVC("tenant-a"), newPV("tenant-a"),
+			newNamespace("tenant-b", "b"), newWorkloadPod("tenant-b"), newPVC("tenant-b"), newPV("tenant-b"),
+		).
+		Build()
+	r := csicontroller.NewReconciler(c, record.NewFakeRecorder(10), podConfig, csicontroller.DefaultRestartPolicy)
+	r.SetNamespaceShard(csicontroller.NewNamespaceShard(c, labels.SelectorFromSet(labels.Set{"shard": "a"})))
+
+	reconcileWorkloadPod := func(namespace string) {
+		t.Helper()
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "workload"}})
+		assert.NoError(t, err)
+	}
+	getMountpointPod := func(namespace string) error {
+		name := mppod.MountpointPodNameFor(namespace+"-workload-uid", namespace+"-pv")
+		return c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: name}, &corev1.Pod{})
+	}
+
+	reconcileWorkloadPod("tenant-a")
+	assert.NoError(t, getMountpointPod("tenant-a"))
+
+	// Workload Pods in namespaces outside of the shard are ignored
+	reconcileWorkloadPod("tenant-b")
+	assert.Equals(t, true, apierrors.IsNotFound(getMountpointPod("tenant-b")))
+
+	// Workload Pods are reconciled once their namespace is moved into the shard
+	assert.NoError(t, c.Update(context.Background(), newNamespace("tenant-b", "a")))
+	reconcileWorkloadPod("tenant-b")
+	assert.NoError(t, getMountpointPod("tenant-b"))
+}
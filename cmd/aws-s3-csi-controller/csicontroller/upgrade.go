@@ -0,0 +1,116 @@
+package csicontroller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// AnnotationUpgradeMountpoint can be set to "true" on PersistentVolumes to replace their running Mountpoint Pods
+// with an outdated version of Mountpoint (see [mppod.AnnotationUpgradeAvailable]), one Mountpoint Pod at a time.
+//
+// Replacing a Mountpoint Pod re-mounts the volume for its workload Pod, which interrupts I/O on the volume,
+// so it's meant to be set during a maintenance window and removed afterwards.
+const AnnotationUpgradeMountpoint = "s3.csi.aws.com/upgrade-mountpoint"
+
+// eventReasonMountpointPodUpgraded is the reason of the event recorded on workload Pods once their Mountpoint Pod
+// is replaced to upgrade Mountpoint.
+const eventReasonMountpointPodUpgraded = "MountpointPodUpgraded"
+
+// upgradeWaitInterval is the interval to check again whether a Mountpoint Pod can be replaced, while another
+// Mountpoint Pod of the same volume is being replaced.
+const upgradeWaitInterval = 10 * time.Second
+
+// reconcileUpgrade marks given running Mountpoint `pod` with [mppod.AnnotationUpgradeAvailable] if it runs a version of
+// Mountpoint different from the one newly spawned Mountpoint Pods of `pv` would run, and replaces it if
+// [AnnotationUpgradeMountpoint] is set on `pv`.
+//
+// Mountpoint Pods of a volume are replaced one at a time: a Mountpoint Pod is only deleted once other Mountpoint Pods
+// of the volume are not pending or terminating, and the reconciliation of its workload Pod respawns it with
+// the new version once it's deleted.
+func (r *Reconciler) reconcileUpgrade(ctx context.Context, pod, workloadPod *corev1.Pod, pv *corev1.PersistentVolume) (reconcile.Result, error) {
+	log := logf.FromContext(ctx).WithValues("mountpointPod", pod.Name)
+
+	version := r.mountpointPodCreator.MountpointVersionFor(pv)
+	if version == pod.Labels[mppod.LabelMountpointVersion] {
+		version = ""
+	}
+	if err := r.markUpgradeAvailable(ctx, pod, version); err != nil {
+		log.Error(err, "Failed to mark Pod with available upgrade")
+		return reconcile.Result{}, err
+	}
+	if version == "" || !isUpgradeRequested(pv) {
+		return reconcile.Result{}, nil
+	}
+
+	mpPods := &corev1.PodList{}
+	err := r.List(ctx, mpPods, client.InNamespace(pod.Namespace), client.MatchingLabels{mppod.LabelVolumeName: pv.Name})
+	if err != nil {
+		log.Error(err, "Failed to list Mountpoint Pods of the volume")
+		return reconcile.Result{}, err
+	}
+	for _, mpPod := range mpPods.Items {
+		if mpPod.Name != pod.Name && (mpPod.DeletionTimestamp != nil || mpPod.Status.Phase == corev1.PodPending) {
+			log.V(debugLevel).Info("Another Mountpoint Pod of the volume is being replaced - waiting before upgrading", "otherMountpointPod", mpPod.Name)
+			return reconcile.Result{RequeueAfter: upgradeWaitInterval}, nil
+		}
+	}
+
+	if err := r.deleteMountpointPod(ctx, pod, deleteReasonUpgraded); err != nil {
+		log.Error(err, "Failed to delete Pod to upgrade")
+		return reconcile.Result{}, err
+	}
+	log.Info("Pod deleted to upgrade Mountpoint", "from", pod.Labels[mppod.LabelMountpointVersion], "to", version)
+
+	r.recorder.Eventf(workloadPod, corev1.EventTypeNormal, eventReasonMountpointPodUpgraded,
+		"Mountpoint Pod %s/%s for volume %q is being replaced to upgrade Mountpoint from %s to %s",
+		pod.Namespace, pod.Name, pv.Name, pod.Labels[mppod.LabelMountpointVersion], version)
+	return reconcile.Result{}, nil
+}
+
+// isUpgradeRequested returns whether [AnnotationUpgradeMountpoint] is set on given PersistentVolume object.
+func isUpgradeRequested(o client.Object) bool {
+	return o.GetAnnotations()[AnnotationUpgradeMountpoint] == "true"
+}
+
+// requestsForUpgradedVolume maps events of PersistentVolumes to requests of the workload Pods of their Mountpoint Pods,
+// so Mountpoint Pods are upgraded once [AnnotationUpgradeMountpoint] is set on their volume.
+func (r *Reconciler) requestsForUpgradedVolume(ctx context.Context, o client.Object) []reconcile.Request {
+	mpPods := &corev1.PodList{}
+	err := r.List(ctx, mpPods, client.InNamespace(r.mountpointPodConfig.Namespace), client.MatchingLabels{mppod.LabelVolumeName: o.GetName()})
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Mountpoint Pods of volume to upgrade", "volumeName", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range mpPods.Items {
+		requests = append(requests, r.requestsForMountpointPod(ctx, &mpPods.Items[i])...)
+	}
+	return requests
+}
+
+// markUpgradeAvailable sets [mppod.AnnotationUpgradeAvailable] on Mountpoint `pod` to `version`, or removes it if `version` is empty.
+// The annotation is only patched if it's changed.
+func (r *Reconciler) markUpgradeAvailable(ctx context.Context, pod *corev1.Pod, version string) error {
+	if pod.Annotations[mppod.AnnotationUpgradeAvailable] == version {
+		return nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	if version == "" {
+		delete(pod.Annotations, mppod.AnnotationUpgradeAvailable)
+	} else {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[mppod.AnnotationUpgradeAvailable] = version
+	}
+	return r.Patch(ctx, pod, patch)
+}
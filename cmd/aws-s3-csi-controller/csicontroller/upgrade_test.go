@@ -0,0 +1,113 @@
+package csicontroller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestUpgradingMountpointPods(t *testing.T) {
+	oldConfig := mppod.Config{Namespace: mountpointNamespace, MountpointVersion: "1.14.0"}
+	newConfig := mppod.Config{Namespace: mountpointNamespace, MountpointVersion: "1.15.0"}
+
+	newWorkloadPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"},
+				}}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-claim", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "s3-pv"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-pv"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "s3-claim"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: "s3-csi-driver-volume"},
+			},
+		},
+	}
+
+	workloadA, workloadB := newWorkloadPod("workload-a"), newWorkloadPod("workload-b")
+	mpPodA, mpPodB := mppod.NewCreator(oldConfig).Create(workloadA, pv), mppod.NewCreator(oldConfig).Create(workloadB, pv)
+	mpPodA.Status.Phase, mpPodB.Status.Phase = corev1.PodRunning, corev1.PodRunning
+
+	c := fake.NewClientBuilder().
+		WithObjects(workloadA, workloadB, pvc, pv, mpPodA, mpPodB).
+		WithIndex(&corev1.Pod{}, "metadata.uid", func(o client.Object) []string {
+			return []string{string(o.GetUID())}
+		}).
+		Build()
+	recorder := record.NewFakeRecorder(10)
+	r := csicontroller.NewReconciler(c, recorder, newConfig, csicontroller.DefaultRestartPolicy)
+
+	reconcileWorkloadPod := func(name string) reconcile.Result {
+		t.Helper()
+		res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}})
+		assert.NoError(t, err)
+		return res
+	}
+	getMountpointPod := func(name string) (*corev1.Pod, error) {
+		pod := &corev1.Pod{}
+		return pod, c.Get(context.Background(), types.NamespacedName{Namespace: mountpointNamespace, Name: name}, pod)
+	}
+
+	// Outdated Mountpoint Pods are marked, but not replaced without the annotation on the volume
+	reconcileWorkloadPod("workload-a")
+	got, err := getMountpointPod(mpPodA.Name)
+	assert.NoError(t, err)
+	assert.Equals(t, "1.15.0", got.Annotations[mppod.AnnotationUpgradeAvailable])
+	assert.Equals(t, 0, len(recorder.Events))
+
+	pv.Annotations = map[string]string{csicontroller.AnnotationUpgradeMountpoint: "true"}
+	assert.NoError(t, c.Update(context.Background(), pv))
+
+	// Only one Mountpoint Pod of the volume is replaced at a time
+	mpPodB.Status.Phase = corev1.PodPending
+	assert.NoError(t, c.Status().Update(context.Background(), mpPodB))
+	res := reconcileWorkloadPod("workload-a")
+	if res.RequeueAfter <= 0 {
+		t.Fatalf("Expected to requeue while another Mountpoint Pod is pending, got %v", res)
+	}
+	_, err = getMountpointPod(mpPodA.Name)
+	assert.NoError(t, err)
+
+	mpPodB.Status.Phase = corev1.PodRunning
+	assert.NoError(t, c.Status().Update(context.Background(), mpPodB))
+	reconcileWorkloadPod("workload-a")
+	_, err = getMountpointPod(mpPodA.Name)
+	assert.Equals(t, true, apierrors.IsNotFound(err))
+
+	event := <-recorder.Events
+	if !strings.HasPrefix(event, "Normal MountpointPodUpgraded") || !strings.Contains(event, "from 1.14.0 to 1.15.0") {
+		t.Fatalf("Unexpected event %q", event)
+	}
+
+	// The workload Pod respawns its Mountpoint Pod with the new version
+	reconcileWorkloadPod("workload-a")
+	got, err = getMountpointPod(mpPodA.Name)
+	assert.NoError(t, err)
+	assert.Equals(t, "1.15.0", got.Labels[mppod.LabelMountpointVersion])
+}
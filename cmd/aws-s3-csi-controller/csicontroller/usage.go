@@ -0,0 +1,159 @@
+package csicontroller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// DefaultUsageReportMaxObjects is the default maximum number of objects to count per volume in each report.
+const DefaultUsageReportMaxObjects = 100_000
+
+// Annotations recording storage usage of PersistentVolumes.
+const (
+	AnnotationUsedBytes       = "s3.csi.aws.com/used-bytes"
+	AnnotationObjectCount     = "s3.csi.aws.com/object-count"
+	AnnotationUsagePartial    = "s3.csi.aws.com/usage-partial"
+	AnnotationUsageReportedAt = "s3.csi.aws.com/usage-reported-at"
+)
+
+// A UsageReporter periodically lists objects of S3 volumes, and records their storage usage
+// as annotations on their PersistentVolumes (see [AnnotationUsedBytes]) and as metrics.
+//
+// Mountpoint reports a fixed and very large capacity via `statfs`, and capacity of PersistentVolumes is ignored
+// by the CSI Driver, so the reported usage is the only realistic size of a volume in dashboards.
+// Capacity of PersistentVolumes is not modified, as it's the requested size of the volume.
+//
+// Volumes with more than the configured maximum number of objects are only partially counted
+// to limit the cost of listing, their usage is a lower bound and they're annotated with [AnnotationUsagePartial].
+// Composite volumes and volumes with prefixes expanded per workload Pod are not reported.
+type UsageReporter struct {
+	s3         controller.S3Client
+	interval   time.Duration
+	maxObjects int64
+	// reported is the names of volumes reported in the last report, to remove metrics of deleted volumes.
+	reported map[string]bool
+
+	client.Client
+}
+
+// NewUsageReporter returns a new reporter listing objects via `s3` every `interval`, counting at most `maxObjects` objects per volume.
+func NewUsageReporter(client client.Client, s3 controller.S3Client, interval time.Duration, maxObjects int64) *UsageReporter {
+	return &UsageReporter{Client: client, s3: s3, interval: interval, maxObjects: maxObjects}
+}
+
+// Start reports storage usage periodically until `ctx` is cancelled, it implements `manager.Runnable`.
+func (r *UsageReporter) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("usage-reporter")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Report(ctx); err != nil {
+				log.Error(err, "Failed to report storage usage of volumes")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Report lists objects of each S3 volume once, and updates their annotations and metrics.
+func (r *UsageReporter) Report(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("usage-reporter")
+
+	pvs := &corev1.PersistentVolumeList{}
+	if err := r.List(ctx, pvs); err != nil {
+		return fmt.Errorf("failed to list PersistentVolumes: %w", err)
+	}
+
+	reported := make(map[string]bool, len(pvs.Items))
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		bucket, prefix, ok := usageScope(pv)
+		if !ok {
+			continue
+		}
+
+		// Metrics of volumes failed to be listed are kept until the next successful report.
+		reported[pv.Name] = true
+
+		usage, err := r.s3.PrefixUsage(ctx, bucket, prefix, r.maxObjects)
+		if err != nil {
+			log.Error(err, "Failed to list objects of volume", "pv", pv.Name, "bucket", bucket, "prefix", prefix)
+			continue
+		}
+
+		volumeUsedBytes.WithLabelValues(pv.Name).Set(float64(usage.Bytes))
+		volumeObjects.WithLabelValues(pv.Name).Set(float64(usage.Objects))
+
+		if err := r.recordUsage(ctx, pv, usage); err != nil {
+			log.Error(err, "Failed to record storage usage", "pv", pv.Name)
+		}
+	}
+
+	r.forgetDeletedVolumes(reported)
+	return nil
+}
+
+// recordUsage updates annotations of `pv` with given `usage`.
+func (r *UsageReporter) recordUsage(ctx context.Context, pv *corev1.PersistentVolume, usage controller.Usage) error {
+	patch := client.MergeFrom(pv.DeepCopy())
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string)
+	}
+	pv.Annotations[AnnotationUsedBytes] = strconv.FormatInt(usage.Bytes, 10)
+	pv.Annotations[AnnotationObjectCount] = strconv.FormatInt(usage.Objects, 10)
+	pv.Annotations[AnnotationUsageReportedAt] = time.Now().UTC().Format(time.RFC3339)
+	if usage.Partial {
+		pv.Annotations[AnnotationUsagePartial] = "true"
+	} else {
+		delete(pv.Annotations, AnnotationUsagePartial)
+	}
+	if err := r.Patch(ctx, pv, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	logf.FromContext(ctx).V(debugLevel).Info("Updated storage usage", "pv", pv.Name,
+		"usedBytes", usage.Bytes, "objects", usage.Objects, "partial", usage.Partial)
+	return nil
+}
+
+// forgetDeletedVolumes removes metrics of volumes that are not `reported` anymore, e.g. deleted PersistentVolumes.
+func (r *UsageReporter) forgetDeletedVolumes(reported map[string]bool) {
+	for name := range r.reported {
+		if !reported[name] {
+			volumeUsedBytes.DeleteLabelValues(name)
+			volumeObjects.DeleteLabelValues(name)
+		}
+	}
+	r.reported = reported
+}
+
+// usageScope returns the bucket and prefix of `pv` to report storage usage of,
+// and false if `pv` is not an S3 volume or its usage cannot be reported.
+func usageScope(pv *corev1.PersistentVolume) (bucket string, prefix string, ok bool) {
+	csiSource := pv.Spec.CSI
+	if csiSource == nil || csiSource.Driver != mountpointCSIDriverName {
+		return "", "", false
+	}
+
+	bucket = csiSource.VolumeAttributes[volumecontext.BucketName]
+	prefix = csiSource.VolumeAttributes[volumecontext.Prefix]
+	if bucket == "" || strings.Contains(prefix, "$(") {
+		return "", "", false
+	}
+	return bucket, prefix, true
+}
@@ -0,0 +1,99 @@
+package csicontroller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+type fakeUsageS3Client struct {
+	controller.S3Client
+
+	usage      map[string]controller.Usage
+	maxObjects int64
+}
+
+func (c *fakeUsageS3Client) PrefixUsage(ctx context.Context, bucket string, prefix string, maxObjects int64) (controller.Usage, error) {
+	c.maxObjects = maxObjects
+	usage, ok := c.usage[bucket+"/"+prefix]
+	if !ok {
+		return controller.Usage{}, errors.New("access denied")
+	}
+	return usage, nil
+}
+
+func TestUsageReporter(t *testing.T) {
+	ctx := context.Background()
+	s3Volume := func(name string, attributes map[string]string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+				Driver:           "s3.csi.aws.com",
+				VolumeHandle:     name,
+				VolumeAttributes: attributes,
+			}}},
+		}
+	}
+	ebsVolume := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "ebs-vol"},
+		Spec: corev1.PersistentVolumeSpec{PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+			Driver:       "ebs.csi.aws.com",
+			VolumeHandle: "vol-0123",
+		}}},
+	}
+
+	c := fake.NewClientBuilder().WithObjects(
+		s3Volume("bucket-vol", map[string]string{"bucketName": "bucket"}),
+		s3Volume("prefix-vol", map[string]string{"bucketName": "bucket", "prefix": "team-a/"}),
+		s3Volume("pod-prefix-vol", map[string]string{"bucketName": "bucket", "prefix": "$(POD_NAME)/"}),
+		s3Volume("denied-vol", map[string]string{"bucketName": "denied"}),
+		s3Volume("composite-vol", map[string]string{"buckets": `[{"bucketName": "bucket", "path": "data"}]`}),
+		ebsVolume,
+	).Build()
+	s3 := &fakeUsageS3Client{usage: map[string]controller.Usage{
+		"bucket/":        {Bytes: 4096, Objects: 3},
+		"bucket/team-a/": {Bytes: 1024, Objects: 10, Partial: true},
+	}}
+	reporter := csicontroller.NewUsageReporter(c, s3, time.Hour, 10)
+
+	assert.NoError(t, reporter.Report(ctx))
+	assert.Equals(t, int64(10), s3.maxObjects)
+
+	annotations := func(name string) map[string]string {
+		pv := &corev1.PersistentVolume{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: name}, pv))
+		delete(pv.Annotations, csicontroller.AnnotationUsageReportedAt)
+		return pv.Annotations
+	}
+
+	assert.Equals(t, map[string]string{
+		csicontroller.AnnotationUsedBytes:   "4096",
+		csicontroller.AnnotationObjectCount: "3",
+	}, annotations("bucket-vol"))
+	assert.Equals(t, map[string]string{
+		csicontroller.AnnotationUsedBytes:    "1024",
+		csicontroller.AnnotationObjectCount:  "10",
+		csicontroller.AnnotationUsagePartial: "true",
+	}, annotations("prefix-vol"))
+	for _, name := range []string{"pod-prefix-vol", "denied-vol", "composite-vol", "ebs-vol"} {
+		assert.Equals(t, map[string]string(nil), annotations(name))
+	}
+
+	// Volumes that are fully counted later are not annotated as partial anymore
+	s3.usage["bucket/team-a/"] = controller.Usage{Bytes: 512, Objects: 5}
+	assert.NoError(t, reporter.Report(ctx))
+	assert.Equals(t, map[string]string{
+		csicontroller.AnnotationUsedBytes:   "512",
+		csicontroller.AnnotationObjectCount: "5",
+	}, annotations("prefix-vol"))
+}
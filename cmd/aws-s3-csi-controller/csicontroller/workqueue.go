@@ -0,0 +1,56 @@
+package csicontroller
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// A WorkQueueConfig configures how many Pods are reconciled concurrently, and how fast they're queued.
+//
+// Each Pod is requeued with a per-item exponential backoff starting with `BaseDelay` up to `MaxDelay` on failures,
+// and all Pods are queued at most `QPS` per second with bursts of `Burst`, whichever is slower.
+type WorkQueueConfig struct {
+	MaxConcurrentReconciles int
+	BaseDelay               time.Duration
+	MaxDelay                time.Duration
+	QPS                     float64
+	Burst                   int
+}
+
+// DefaultWorkQueueConfig is the default configuration of the work queue, which is controller-runtime's default.
+var DefaultWorkQueueConfig = WorkQueueConfig{
+	MaxConcurrentReconciles: 1,
+	BaseDelay:               5 * time.Millisecond,
+	MaxDelay:                1000 * time.Second,
+	QPS:                     10,
+	Burst:                   100,
+}
+
+// Validate returns an error if `c` cannot be used to configure a work queue.
+func (c WorkQueueConfig) Validate() error {
+	switch {
+	case c.MaxConcurrentReconciles < 1:
+		return fmt.Errorf("maximum concurrent reconciles must be positive, got %d", c.MaxConcurrentReconciles)
+	case c.BaseDelay <= 0 || c.MaxDelay < c.BaseDelay:
+		return fmt.Errorf("base delay must be positive and not greater than max delay, got %s and %s", c.BaseDelay, c.MaxDelay)
+	case c.QPS <= 0 || c.Burst < 1:
+		return fmt.Errorf("QPS and burst must be positive, got %v and %d", c.QPS, c.Burst)
+	}
+	return nil
+}
+
+// controllerOptions returns options of a controller using a work queue configured with `c`.
+func (c WorkQueueConfig) controllerOptions() controller.Options {
+	return controller.Options{
+		MaxConcurrentReconciles: c.MaxConcurrentReconciles,
+		RateLimiter: workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](c.BaseDelay, c.MaxDelay),
+			&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(c.QPS), c.Burst)},
+		),
+	}
+}
@@ -0,0 +1,36 @@
+package csicontroller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestValidatingWorkQueueConfig(t *testing.T) {
+	assert.NoError(t, csicontroller.DefaultWorkQueueConfig.Validate())
+	assert.NoError(t, csicontroller.WorkQueueConfig{
+		MaxConcurrentReconciles: 10,
+		BaseDelay:               100 * time.Millisecond,
+		MaxDelay:                time.Minute,
+		QPS:                     200,
+		Burst:                   1000,
+	}.Validate())
+
+	for name, modify := range map[string]func(*csicontroller.WorkQueueConfig){
+		"no concurrent reconciles":       func(c *csicontroller.WorkQueueConfig) { c.MaxConcurrentReconciles = 0 },
+		"zero base delay":                func(c *csicontroller.WorkQueueConfig) { c.BaseDelay = 0 },
+		"max delay less than base delay": func(c *csicontroller.WorkQueueConfig) { c.MaxDelay = c.BaseDelay / 2 },
+		"zero QPS":                       func(c *csicontroller.WorkQueueConfig) { c.QPS = 0 },
+		"zero burst":                     func(c *csicontroller.WorkQueueConfig) { c.Burst = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := csicontroller.DefaultWorkQueueConfig
+			modify(&config)
+			if err := config.Validate(); err == nil {
+				t.Fatal("Expected an error for invalid work queue configuration")
+			}
+		})
+	}
+}
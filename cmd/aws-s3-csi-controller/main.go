@@ -2,62 +2,494 @@
 //
 // `aws-s3-csi-controller` is the entrypoint binary for the CSI Driver's controller component.
 // It is responsible for acting on cluster events and spawning Mountpoint Pods when necessary.
-// It is also responsible for managing Mountpoint Pods, for example it ensures that completed Mountpoint Pods gets deleted.
-// It doesn't implement CSI's controller service as of today.
+// It is also responsible for managing Mountpoint Pods, for example it ensures that completed Mountpoint Pods gets deleted,
+// and failed Mountpoint Pods gets restarted with a backoff.
+// It can also reject evictions of Mountpoint Pods until their workload Pods are terminated if `--enable-eviction-webhook` is passed.
+// It can also gate scheduling of workload Pods until their Mountpoint Pods are running if `--enable-scheduling-gate-webhook` is passed.
+// It can also apply resource profiles from a ConfigMap to Mountpoint Pods if `--mountpoint-resource-profiles` is passed.
+// It can also record resource recommendations on Mountpoint Pods if `--enable-resource-recommendations` is passed.
+// It can also delete orphaned Mountpoint Pods if `--orphan-mountpoint-pod-ttl` is passed.
+// It can also record storage usage of S3 volumes on their PersistentVolumes if `--usage-report-interval` is passed.
+// It also implements CSI's controller service for dynamic provisioning if `--csi-endpoint` is passed.
+// It can also clone volumes with Jobs if `--clone-image` is passed, which run it in clone mode with `--clone-from` and `--clone-to`
+// to copy the objects of the source volume into the new volume and exit.
+// It can also run only a webhook rejecting PersistentVolumes and StorageClasses with invalid mount options with
+// `mount-options-webhook` subcommand, which does not need access to the API server, see [runMountOptionsWebhook].
+// It can run with multiple replicas for high availability if `--leader-elect` is passed, in which case only the leader
+// reconciles Pods and runs periodic tasks, while webhooks and CSI's controller service are served by all replicas.
+// It can also run out-of-cluster against the cluster of `--kubeconfig` and `--kube-context`, and only reconcile workload Pods
+// in namespaces matching `--workload-namespace-selector`, so multiple controllers can split a large cluster between them.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/version"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
 )
 
 var mountpointNamespace = flag.String("mountpoint-namespace", "mount-s3", "Namespace to spawn Mountpoint Pods in.")
 var mountpointVersion = flag.String("mountpoint-version", os.Getenv("MOUNTPOINT_VERSION"), "Version of Mountpoint within the given Mountpoint image.")
 var mountpointImage = flag.String("mountpoint-image", os.Getenv("MOUNTPOINT_IMAGE"), "Image of Mountpoint to use in spawned Mountpoint Pods.")
 var mountpointImagePullPolicy = flag.String("mountpoint-image-pull-policy", os.Getenv("MOUNTPOINT_IMAGE_PULL_POLICY"), "Pull policy of Mountpoint images.")
+var mountpointImagePullSecrets = flag.String("mountpoint-image-pull-secrets", "", "Comma-separated names of Secrets in the Mountpoint Pods' namespace to pull Mountpoint images with, e.g. for a private mirror. Can be overridden by `mountpointImagePullSecrets` volume attribute.")
+var mountpointPodSeccompProfile = flag.String("mountpoint-pod-seccomp-profile", "", "Seccomp profile of Mountpoint containers in \"RuntimeDefault\", \"Unconfined\" or \"Localhost/<path>\" format. The container runtime's default is used if empty.")
+var mountpointPodAppArmorProfile = flag.String("mountpoint-pod-apparmor-profile", "", "AppArmor profile of Mountpoint containers in \"RuntimeDefault\", \"Unconfined\" or \"Localhost/<name>\" format. The container runtime's default is used if empty.")
+var mountpointPodRunAsUser = flag.Int64("mountpoint-pod-run-as-user", -1, "User ID to run Mountpoint containers as. The user of the Mountpoint image is used if negative.")
+var mountpointPodRunAsGroup = flag.Int64("mountpoint-pod-run-as-group", -1, "Group ID to run Mountpoint containers as. The group of the Mountpoint image is used if negative.")
+var mountpointPodReadOnlyRootFilesystem = flag.Bool("mountpoint-pod-read-only-root-filesystem", false, "Whether to mount the root filesystem of Mountpoint containers as read-only.")
+var mountpointPodDropCapabilities = flag.String("mountpoint-pod-drop-capabilities", "ALL", "Comma-separated capabilities to drop from Mountpoint containers.")
 var mountpointContainerCommand = flag.String("mountpoint-container-command", "/bin/aws-s3-csi-mounter", "Entrypoint command of the Mountpoint Pods.")
+var mountpointPodNodeSelector = flag.String("mountpoint-pod-node-selector", "", "Node selector in \"key=value,key2=value2\" format to add to Mountpoint Pods, which makes Mountpoint Pods unschedulable on nodes not matching it.")
+var mountpointPodMountTimeout = flag.Duration("mountpoint-pod-mount-timeout", 0, "Timeout for Mountpoint Pods to receive mount options from the CSI Driver Node Pod, can be overridden by `mountTimeout` volume attribute. The default of the Mountpoint Pod's entrypoint is used if 0.")
+var mountpointPodMetricsPort = flag.Int("mountpoint-pod-metrics-port", 0, "Port for Mountpoint Pods to expose metrics of Mountpoint on in Prometheus format. Metrics of Mountpoint are not exposed if 0.")
+var mountpointPodCABundleSecret = flag.String("mountpoint-pod-ca-bundle-secret", "", "Secret in the Mountpoint Pods' namespace with a CA bundle at \"ca.crt\" key to mount into Mountpoint Pods as their trust store, e.g. to trust a TLS-intercepting proxy. Can be overridden by `caBundleSecretRef` volume attribute.")
+var mountpointPodPriorityClass = flag.String("mountpoint-pod-priority-class", "", "Priority class of Mountpoint Pods whose workload Pods' priority class is not mapped in --mountpoint-pod-priority-classes. The default priority of the cluster is used if empty.")
+var mountpointPodPriorityClasses = flag.String("mountpoint-pod-priority-classes", "", "Priority classes of Mountpoint Pods by the priority class of their workload Pods in \"workload-class=mountpoint-class,workload-class2=mountpoint-class2\" format.")
+var headroomMountpointPodsPerNode = flag.Int("headroom-mountpoint-pods-per-node", 0, "Number of Mountpoint Pods to reserve room for in each node with low-priority headroom Pods. Requires --mountpoint-resource-profiles. Headroom is disabled if 0.")
+var headroomPriorityClass = flag.String("headroom-priority-class", "", "Priority class of headroom Pods, its value must be lower than the priority of Mountpoint Pods.")
+var headroomImage = flag.String("headroom-image", csicontroller.DefaultHeadroomImage, "Image of headroom Pods.")
+var prePullMountpointImage = flag.Bool("pre-pull-mountpoint-image", false, "Pull Mountpoint images into nodes that pending workload Pods using S3 volumes are nominated to, e.g. while they preempt other Pods, so their Mountpoint Pods don't wait for the image.")
+var metricsBindAddress = flag.String("metrics-bind-address", ":8080", "The address to expose Prometheus metrics on. Metrics are not exposed if \"0\".")
+var enableEvictionWebhook = flag.Bool("enable-eviction-webhook", false, "Serve a webhook to reject evictions of Mountpoint Pods until their workload Pods are terminated.")
+var enableSchedulingGateWebhook = flag.Bool("enable-scheduling-gate-webhook", false, "Serve a webhook to gate scheduling of workload Pods using S3 volumes until their Mountpoint Pods are running in a node they fit in.")
+var schedulingGateTimeout = flag.Duration("scheduling-gate-timeout", csicontroller.DefaultSchedulingGateTimeout, "Duration after which gated workload Pods are scheduled even if their Mountpoint Pods are not running.")
+var mountpointResourceProfiles = flag.String("mountpoint-resource-profiles", "", "ConfigMap in \"namespace/name\" format to watch for resource profiles of Mountpoint Pods. Mountpoint Pods are spawned without resources if empty.")
+var enableResourceRecommendations = flag.Bool("enable-resource-recommendations", false, "Record peak resource usage and recommended resources as annotations on Mountpoint Pods. Requires metrics-server.")
+var resourceRecommendationInterval = flag.Duration("resource-recommendation-interval", csicontroller.DefaultRecommendationInterval, "Interval to collect resource usage of Mountpoint Pods for recommendations.")
+var mountpointPodMaxRestarts = flag.Int("mountpoint-pod-max-restarts", csicontroller.DefaultRestartPolicy.MaxRestarts, "Maximum number of times to restart a failed Mountpoint Pod for the same workload Pod and volume.")
+var mountpointPodRestartBackoff = flag.Duration("mountpoint-pod-restart-backoff", csicontroller.DefaultRestartPolicy.InitialBackoff, "Initial backoff before restarting a failed Mountpoint Pod, doubled with each restart.")
+var mountpointPodMaxRestartBackoff = flag.Duration("mountpoint-pod-max-restart-backoff", csicontroller.DefaultRestartPolicy.MaxBackoff, "Maximum backoff before restarting a failed Mountpoint Pod.")
+var usageReportInterval = flag.Duration("usage-report-interval", 0, "Interval to list objects of S3 volumes and record their storage usage as annotations on PersistentVolumes and as metrics. Usage reporting is disabled if 0.")
+var usageReportMaxObjects = flag.Int64("usage-report-max-objects", csicontroller.DefaultUsageReportMaxObjects, "Maximum number of objects to count per volume in each usage report, usage of larger volumes is a lower bound. Unlimited if 0.")
+var leaderElect = flag.Bool("leader-elect", false, "Enable leader election, so only one replica acts on cluster events at a time. Required to run more than one replica.")
+var leaderElectionNamespace = flag.String("leader-election-namespace", "", "Namespace of the leader election Lease. The namespace the controller is running in is used if empty.")
+var leaderElectionID = flag.String("leader-election-id", "aws-s3-csi-controller-leader", "Name of the leader election Lease.")
+var leaderElectionLeaseDuration = flag.Duration("leader-election-lease-duration", 15*time.Second, "Duration that non-leader replicas wait before forcing to acquire leadership.")
+var leaderElectionRenewDeadline = flag.Duration("leader-election-renew-deadline", 10*time.Second, "Duration that the leader retries refreshing leadership before giving it up.")
+var leaderElectionRetryPeriod = flag.Duration("leader-election-retry-period", 2*time.Second, "Duration that replicas wait between tries of acquiring or renewing leadership.")
+var maxConcurrentReconciles = flag.Int("max-concurrent-reconciles", csicontroller.DefaultWorkQueueConfig.MaxConcurrentReconciles, "Maximum number of Pods to reconcile concurrently.")
+var reconcileBaseDelay = flag.Duration("reconcile-base-delay", csicontroller.DefaultWorkQueueConfig.BaseDelay, "Initial delay before requeueing a Pod that failed to reconcile, doubled with each failure.")
+var reconcileMaxDelay = flag.Duration("reconcile-max-delay", csicontroller.DefaultWorkQueueConfig.MaxDelay, "Maximum delay before requeueing a Pod that failed to reconcile.")
+var reconcileQPS = flag.Float64("reconcile-qps", csicontroller.DefaultWorkQueueConfig.QPS, "Maximum number of Pods to queue for reconciliation per second.")
+var reconcileBurst = flag.Int("reconcile-burst", csicontroller.DefaultWorkQueueConfig.Burst, "Maximum burst of Pods to queue for reconciliation above --reconcile-qps.")
+var orphanMountpointPodTTL = flag.Duration("orphan-mountpoint-pod-ttl", 0, "Delete Mountpoint Pods whose workload Pods or PersistentVolumes do not exist anymore after this duration. Orphaned Mountpoint Pods are not collected if 0.")
+var logFormat = logging.RegisterFlag(logging.FormatJSON)
+var kubeContext = flag.String("kube-context", "", "Context of the kubeconfig to use, e.g. to run out-of-cluster against a target cluster. The current context is used if empty.")
+var workloadNamespaceSelector = flag.String("workload-namespace-selector", "", "Label selector of namespaces to reconcile workload Pods in, e.g. \"shard=a\". Workload Pods in all namespaces are reconciled if empty.")
+var csiEndpoint = flag.String("csi-endpoint", "", "CSI Endpoint to serve CSI's controller service for dynamic provisioning. Dynamic provisioning is disabled if empty.")
+var cloneImage = flag.String("clone-image", "", "Image of Jobs cloning volumes, which must contain this binary at --clone-command. Cloning volumes is disabled if empty.")
+var cloneCommand = flag.String("clone-command", csicontroller.DefaultCloneCommand, "Path of this binary in --clone-image.")
+var cloneNamespace = flag.String("clone-namespace", "", "Namespace to create Jobs cloning volumes in. Required with --clone-image.")
+var cloneServiceAccount = flag.String("clone-service-account", "", "Service account of Jobs cloning volumes, it needs permissions to list and copy objects of volumes. The default service account of --clone-namespace is used if empty.")
+var cloneFrom = flag.String("clone-from", "", "Run in clone mode, which copies the objects of the volume at \"<bucket>/<prefix>\" into the volume at --clone-to and exits. Used by Jobs cloning volumes.")
+var cloneTo = flag.String("clone-to", "", "Location of the volume to copy objects into in clone mode, in \"<bucket>/<prefix>\" format.")
+var cloneConcurrency = flag.Int("clone-concurrency", controller.DefaultCloneConcurrency, "Number of objects to copy in parallel in clone mode.")
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == mountOptionsWebhookCommand {
+		os.Exit(runMountOptionsWebhook(os.Args[2:]))
+	}
+
 	flag.Parse()
 
-	logf.SetLogger(zap.New())
+	if err := logging.ValidateFormat(*logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logf.SetLogger(zap.New(logging.ZapOptions(*logFormat)...))
 
 	log := logf.Log.WithName(csicontroller.Name)
 
-	mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{})
+	if *cloneFrom != "" || *cloneTo != "" {
+		if err := runClone(); err != nil {
+			log.Error(err, "Failed to clone volume", "from", *cloneFrom, "to", *cloneTo)
+			os.Exit(1)
+		}
+		return
+	}
+
+	options := manager.Options{
+		Metrics: metricsserver.Options{BindAddress: *metricsBindAddress},
+
+		LeaderElection:          *leaderElect,
+		LeaderElectionNamespace: *leaderElectionNamespace,
+		LeaderElectionID:        *leaderElectionID,
+		LeaseDuration:           leaderElectionLeaseDuration,
+		RenewDeadline:           leaderElectionRenewDeadline,
+		RetryPeriod:             leaderElectionRetryPeriod,
+		// The leader steps down on shutdown, so another replica takes over without waiting for the lease to expire.
+		LeaderElectionReleaseOnCancel: true,
+	}
+
+	nodeSelector, err := labels.ConvertSelectorToLabelsMap(*mountpointPodNodeSelector)
+	if err != nil {
+		log.Error(err, "Invalid --mountpoint-pod-node-selector", "value", *mountpointPodNodeSelector)
+		os.Exit(1)
+	}
+
+	imagePullSecrets, err := mppod.ParseImagePullSecrets(*mountpointImagePullSecrets)
+	if err != nil {
+		log.Error(err, "Invalid --mountpoint-image-pull-secrets", "value", *mountpointImagePullSecrets)
+		os.Exit(1)
+	}
+
+	priorityClasses, err := mppod.ParsePriorityClasses(*mountpointPodPriorityClasses)
+	if err != nil {
+		log.Error(err, "Invalid --mountpoint-pod-priority-classes", "value", *mountpointPodPriorityClasses)
+		os.Exit(1)
+	}
+
+	securityContext, err := mountpointSecurityContext()
+	if err != nil {
+		log.Error(err, "Invalid security context of Mountpoint Pods")
+		os.Exit(1)
+	}
+
+	if *headroomMountpointPodsPerNode > 0 && (*mountpointResourceProfiles == "" || *headroomPriorityClass == "") {
+		log.Error(nil, "--headroom-mountpoint-pods-per-node requires --mountpoint-resource-profiles and --headroom-priority-class")
+		os.Exit(1)
+	}
+
+	if *mountpointPodMetricsPort < 0 || *mountpointPodMetricsPort > 65535 {
+		log.Error(nil, "Invalid --mountpoint-pod-metrics-port, expected a port number", "value", *mountpointPodMetricsPort)
+		os.Exit(1)
+	}
+
+	if *cloneImage != "" && *cloneNamespace == "" {
+		log.Error(nil, "--clone-image requires --clone-namespace")
+		os.Exit(1)
+	}
+
+	workQueueConfig := csicontroller.WorkQueueConfig{
+		MaxConcurrentReconciles: *maxConcurrentReconciles,
+		BaseDelay:               *reconcileBaseDelay,
+		MaxDelay:                *reconcileMaxDelay,
+		QPS:                     *reconcileQPS,
+		Burst:                   *reconcileBurst,
+	}
+	if err := workQueueConfig.Validate(); err != nil {
+		log.Error(err, "Invalid work queue configuration")
+		os.Exit(1)
+	}
+
+	// Managed fields are never used by the controller, and they are a large part of objects' size.
+	options.Cache.DefaultTransform = cache.TransformStripManagedFields()
+	options.Cache.ByObject = map[client.Object]cache.ByObject{
+		// Only cache the headroom DaemonSet's namespace instead of all DaemonSets in the cluster.
+		&appsv1.DaemonSet{}: {
+			Namespaces: map[string]cache.Config{*mountpointNamespace: {}},
+		},
+		// Only cache details of Pods that might use S3 volumes.
+		&corev1.Pod{}: csicontroller.PodCacheOptions(*mountpointNamespace),
+	}
+	if *cloneImage != "" {
+		// Only cache clone Jobs' namespace instead of all Jobs in the cluster.
+		options.Cache.ByObject[&batchv1.Job{}] = cache.ByObject{
+			Namespaces: map[string]cache.Config{*cloneNamespace: {}},
+		}
+	}
+
+	var resourceProfiles types.NamespacedName
+	if *mountpointResourceProfiles != "" {
+		namespace, name, ok := strings.Cut(*mountpointResourceProfiles, "/")
+		if !ok || namespace == "" || name == "" {
+			log.Error(nil, "Invalid --mountpoint-resource-profiles, expected \"namespace/name\"", "value", *mountpointResourceProfiles)
+			os.Exit(1)
+		}
+		resourceProfiles = types.NamespacedName{Namespace: namespace, Name: name}
+
+		// Only cache the resource profiles ConfigMap instead of all ConfigMaps in the cluster.
+		options.Cache.ByObject[&corev1.ConfigMap{}] = cache.ByObject{
+			Namespaces: map[string]cache.Config{namespace: {}},
+			Field:      fields.OneTermEqualSelector("metadata.name", name),
+		}
+	}
+
+	var shardSelector labels.Selector
+	if *workloadNamespaceSelector != "" {
+		shardSelector, err = labels.Parse(*workloadNamespaceSelector)
+		if err != nil {
+			log.Error(err, "Invalid workload namespace selector", "selector", *workloadNamespaceSelector)
+			os.Exit(1)
+		}
+	}
+
+	restConfig, err := config.GetConfigWithContext(*kubeContext)
+	if err != nil {
+		log.Error(err, "Failed to load kubeconfig", "context", *kubeContext)
+		os.Exit(1)
+	}
+
+	mgr, err := manager.New(restConfig, options)
 	if err != nil {
 		log.Error(err, "Failed to create a new manager")
 		os.Exit(1)
 	}
 
-	err = csicontroller.NewReconciler(mgr.GetClient(), mppod.Config{
+	reconciler := csicontroller.NewReconciler(mgr.GetClient(), mgr.GetEventRecorderFor(csicontroller.Name), mppod.Config{
 		Namespace:         *mountpointNamespace,
 		MountpointVersion: *mountpointVersion,
 		Container: mppod.ContainerConfig{
-			Command:         *mountpointContainerCommand,
-			Image:           *mountpointImage,
-			ImagePullPolicy: corev1.PullPolicy(*mountpointImagePullPolicy),
+			Command:          *mountpointContainerCommand,
+			Image:            *mountpointImage,
+			ImagePullPolicy:  corev1.PullPolicy(*mountpointImagePullPolicy),
+			ImagePullSecrets: imagePullSecrets,
 		},
-		CSIDriverVersion: version.GetVersion().DriverVersion,
-	}).SetupWithManager(mgr)
+		CSIDriverVersion:  version.GetVersion().DriverVersion,
+		NodeSelector:      nodeSelector,
+		MountTimeout:      *mountpointPodMountTimeout,
+		SecurityContext:   securityContext,
+		MetricsPort:       int32(*mountpointPodMetricsPort),
+		Proxy:             envprovider.ProxyFromEnvironment(),
+		CABundleSecretRef: *mountpointPodCABundleSecret,
+		PriorityClassName: *mountpointPodPriorityClass,
+		PriorityClasses:   priorityClasses,
+	}, csicontroller.RestartPolicy{
+		MaxRestarts:    *mountpointPodMaxRestarts,
+		InitialBackoff: *mountpointPodRestartBackoff,
+		MaxBackoff:     *mountpointPodMaxRestartBackoff,
+	})
+	reconciler.SetWorkQueueConfig(workQueueConfig)
+	var shard *csicontroller.NamespaceShard
+	if shardSelector != nil {
+		shard = csicontroller.NewNamespaceShard(mgr.GetClient(), shardSelector)
+		reconciler.SetNamespaceShard(shard)
+	}
+	err = reconciler.SetupWithManager(context.Background(), mgr)
 	if err != nil {
 		log.Error(err, "Failed to create controller")
 		os.Exit(1)
 	}
 
+	if resourceProfiles.Name != "" {
+		err = csicontroller.NewResourceProfileReconciler(mgr.GetClient(), reconciler.MountpointPodCreator(), resourceProfiles).SetupWithManager(mgr)
+		if err != nil {
+			log.Error(err, "Failed to create resource profiles controller")
+			os.Exit(1)
+		}
+	}
+
+	if *enableResourceRecommendations {
+		err = mgr.Add(csicontroller.NewResourceRecommender(mgr.GetClient(), *mountpointNamespace, *resourceRecommendationInterval))
+		if err != nil {
+			log.Error(err, "Failed to create resource recommender")
+			os.Exit(1)
+		}
+	}
+
+	if *orphanMountpointPodTTL > 0 {
+		orphanCollector := csicontroller.NewOrphanCollector(mgr.GetClient(), *mountpointNamespace, *orphanMountpointPodTTL)
+		orphanCollector.SetNamespaceShard(shard)
+		err = mgr.Add(orphanCollector)
+		if err != nil {
+			log.Error(err, "Failed to create orphan collector")
+			os.Exit(1)
+		}
+	}
+
+	if *usageReportInterval > 0 {
+		err = mgr.Add(newUsageReporter(mgr.GetClient(), *usageReportInterval, *usageReportMaxObjects))
+		if err != nil {
+			log.Error(err, "Failed to create usage reporter")
+			os.Exit(1)
+		}
+	}
+
+	if *headroomMountpointPodsPerNode > 0 {
+		err = mgr.Add(csicontroller.NewHeadroomManager(mgr.GetClient(), reconciler.MountpointPodCreator(), *mountpointNamespace, csicontroller.HeadroomPolicy{
+			MountpointPodsPerNode: *headroomMountpointPodsPerNode,
+			PriorityClassName:     *headroomPriorityClass,
+			Image:                 *headroomImage,
+			NodeSelector:          nodeSelector,
+		}))
+		if err != nil {
+			log.Error(err, "Failed to create headroom manager")
+			os.Exit(1)
+		}
+	}
+
+	if *enableEvictionWebhook {
+		csicontroller.NewEvictionValidator(mgr.GetClient(), *mountpointNamespace).SetupWithManager(mgr)
+	}
+
+	if *enableSchedulingGateWebhook {
+		csicontroller.NewSchedulingGateInjector(mgr.GetClient(), admission.NewDecoder(mgr.GetScheme()), *mountpointNamespace).SetupWithManager(mgr)
+		err = csicontroller.NewSchedulingGateController(reconciler, mgr.GetAPIReader(), *schedulingGateTimeout).SetupWithManager(context.Background(), mgr)
+		if err != nil {
+			log.Error(err, "Failed to create scheduling gate controller")
+			os.Exit(1)
+		}
+	}
+
+	if *prePullMountpointImage {
+		err = mgr.Add(csicontroller.NewImagePuller(reconciler, mgr.GetAPIReader()))
+		if err != nil {
+			log.Error(err, "Failed to create image puller")
+			os.Exit(1)
+		}
+	}
+
+	if *csiEndpoint != "" {
+		err = mgr.Add(newCSIControllerService(*csiEndpoint, mgr))
+		if err != nil {
+			log.Error(err, "Failed to create CSI controller service")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
 		log.Error(err, "Failed to start manager")
 		os.Exit(1)
 	}
 }
+
+// A nonLeaderRunnable is a runnable that runs on all replicas regardless of leader election.
+type nonLeaderRunnable struct {
+	manager.RunnableFunc
+}
+
+// NeedLeaderElection implements `manager.LeaderElectionRunnable`.
+func (nonLeaderRunnable) NeedLeaderElection() bool {
+	return false
+}
+
+// newCSIControllerService returns a runnable serving CSI's controller service on given `endpoint`.
+// It runs on all replicas, as the CSI sidecars next to each replica elect their own leader to call it.
+func newCSIControllerService(endpoint string, mgr manager.Manager) nonLeaderRunnable {
+	return nonLeaderRunnable{func(ctx context.Context) error {
+		s3Client, err := controller.NewS3Client(ctx)
+		if err != nil {
+			return err
+		}
+
+		controllerServer := controller.NewS3ControllerServer(s3Client)
+		if *cloneImage != "" {
+			controllerServer.SetCloner(csicontroller.NewCloneJobs(mgr.GetClient(), mgr.GetEventRecorderFor(csicontroller.Name), s3Client, csicontroller.CloneJobConfig{
+				Namespace:          *cloneNamespace,
+				Image:              *cloneImage,
+				ImagePullPolicy:    corev1.PullPolicy(*mountpointImagePullPolicy),
+				Command:            *cloneCommand,
+				ServiceAccountName: *cloneServiceAccount,
+			}))
+		}
+
+		drv := &driver.Driver{
+			Endpoint:         endpoint,
+			ControllerServer: controllerServer,
+		}
+
+		go func() {
+			<-ctx.Done()
+			drv.Stop()
+		}()
+
+		return drv.Run()
+	}}
+}
+
+// runClone copies the objects of the volume at `--clone-from` into the volume at `--clone-to`, it's run by clone Jobs.
+func runClone() error {
+	source, err := controller.ParseLocation(*cloneFrom)
+	if err != nil {
+		return fmt.Errorf("invalid --clone-from: %w", err)
+	}
+	target, err := controller.ParseLocation(*cloneTo)
+	if err != nil {
+		return fmt.Errorf("invalid --clone-to: %w", err)
+	}
+
+	ctx := signals.SetupSignalHandler()
+	s3Client, err := controller.NewS3Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	log := logf.Log.WithName("clone")
+	log.Info("Cloning volume", "from", source.String(), "to", target.String())
+	lastLogged := time.Now()
+	err = controller.CopyPrefix(ctx, s3Client, source, target, *cloneConcurrency, func(copied, total int) {
+		if copied == total || time.Since(lastLogged) > 10*time.Second {
+			log.Info("Copied objects", "copied", copied, "total", total)
+			lastLogged = time.Now()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Cloned volume", "from", source.String(), "to", target.String())
+	return nil
+}
+
+// newUsageReporter returns a runnable reporting storage usage of S3 volumes every `interval`.
+func newUsageReporter(c client.Client, interval time.Duration, maxObjects int64) manager.RunnableFunc {
+	return func(ctx context.Context) error {
+		s3Client, err := controller.NewS3Client(ctx)
+		if err != nil {
+			return err
+		}
+		return csicontroller.NewUsageReporter(c, s3Client, interval, maxObjects).Start(ctx)
+	}
+}
+
+// mountpointSecurityContext returns the security context of Mountpoint containers configured with flags.
+func mountpointSecurityContext() (*corev1.SecurityContext, error) {
+	seccompProfile, err := mppod.ParseSeccompProfile(*mountpointPodSeccompProfile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mountpoint-pod-seccomp-profile: %w", err)
+	}
+	appArmorProfile, err := mppod.ParseAppArmorProfile(*mountpointPodAppArmorProfile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mountpoint-pod-apparmor-profile: %w", err)
+	}
+
+	securityContext := &corev1.SecurityContext{
+		SeccompProfile:  seccompProfile,
+		AppArmorProfile: appArmorProfile,
+		Capabilities:    &corev1.Capabilities{Drop: []corev1.Capability{}},
+	}
+	for _, capability := range strings.Split(*mountpointPodDropCapabilities, ",") {
+		if capability = strings.TrimSpace(capability); capability != "" {
+			securityContext.Capabilities.Drop = append(securityContext.Capabilities.Drop, corev1.Capability(capability))
+		}
+	}
+	if *mountpointPodRunAsUser >= 0 {
+		securityContext.RunAsUser = mountpointPodRunAsUser
+		securityContext.RunAsNonRoot = ptr.To(*mountpointPodRunAsUser != 0)
+	}
+	if *mountpointPodRunAsGroup >= 0 {
+		securityContext.RunAsGroup = mountpointPodRunAsGroup
+	}
+	if *mountpointPodReadOnlyRootFilesystem {
+		securityContext.ReadOnlyRootFilesystem = ptr.To(true)
+	}
+	return securityContext, mppod.ValidateSecurityContext(securityContext)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-controller/csicontroller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// mountOptionsWebhookCommand is the subcommand to run only the mount options webhook, see [runMountOptionsWebhook].
+const mountOptionsWebhookCommand = "mount-options-webhook"
+
+const mountOptionsWebhookUsage = `Usage: aws-s3-csi-controller mount-options-webhook [flags]
+
+Serves a validating admission webhook at /validate-mount-options rejecting PersistentVolumes and StorageClasses
+of the CSI Driver with invalid mount options.
+
+Flags:
+`
+
+// runMountOptionsWebhook runs the mount options webhook with given command line `args` until it's signalled to stop,
+// and returns the exit code.
+//
+// The webhook only validates the objects in admission requests, so unlike the controller it does not need access to
+// the API server, and it can be deployed on its own with a serving certificate at `--cert-dir`.
+func runMountOptionsWebhook(args []string) int {
+	flags := flag.NewFlagSet(mountOptionsWebhookCommand, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), mountOptionsWebhookUsage)
+		flags.PrintDefaults()
+	}
+	var (
+		port                   = flags.Int("port", webhook.DefaultPort, "Port to serve the webhook on.")
+		certDir                = flags.String("cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory of the serving certificate of the webhook, with \"tls.crt\" and \"tls.key\" files. The certificate is reloaded once the files change.")
+		healthProbeBindAddress = flags.String("health-probe-bind-address", ":8081", "The address to serve the readiness probe on at \"/readyz\". The probe is not served if \"0\".")
+		logFormat              = logging.RegisterFlagSet(flags, logging.FormatJSON)
+	)
+	flags.Parse(args)
+
+	if err := logging.ValidateFormat(*logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logf.SetLogger(zap.New(logging.ZapOptions(*logFormat)...))
+
+	log := logf.Log.WithName(csicontroller.Name).WithName(mountOptionsWebhookCommand)
+
+	server := webhook.NewServer(webhook.Options{Port: *port, CertDir: *certDir})
+	csicontroller.NewMountOptionsValidator(admission.NewDecoder(scheme.Scheme)).SetupWithServer(server)
+
+	if *healthProbeBindAddress != "0" {
+		mux := http.NewServeMux()
+		mux.Handle("/readyz", http.StripPrefix("/readyz", &healthz.Handler{Checks: map[string]healthz.Checker{"webhook": server.StartedChecker()}}))
+		go func() {
+			if err := http.ListenAndServe(*healthProbeBindAddress, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error(err, "Failed to serve readiness probe", "address", *healthProbeBindAddress)
+			}
+		}()
+	}
+
+	log.Info("Serving mount options webhook", "port", *port, "path", csicontroller.MountOptionsWebhookPath)
+	if err := server.Start(signals.SetupSignalHandler()); err != nil && !errors.Is(err, context.Canceled) {
+		log.Error(err, "Failed to serve mount options webhook")
+		return 1
+	}
+	return 0
+}
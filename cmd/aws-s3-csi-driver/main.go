@@ -20,10 +20,21 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketcheck"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketregion"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/vault"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/version"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 )
 
@@ -39,6 +50,42 @@ func main() {
 		printVersion = flag.Bool("version", false, "Print the version and exit")
 		mpVersion    = flag.String("mp-version", os.Getenv("MOUNTPOINT_VERSION"), "mp version to report in service name")
 		nodeID       = flag.String("node-id", os.Getenv(NodeIDEnvVar), "node-id to report in NodeGetInfo RPC")
+		metricsAddr  = flag.String("metrics-address", "", "The address to expose Prometheus metrics on, e.g. \":9810\". Metrics are not exposed if empty.")
+		logFormat    = logging.RegisterFlag(logging.FormatText)
+		policyFile   = flag.String("mount-options-policy-file", "", "Path of the policy file to allow or deny mount options per namespace. Mount options are not restricted if empty.")
+		stsRegion    = flag.String("sts-region", "", "The default STS region for pod-level credentials and `stsRoleArn`. It's detected automatically if empty.")
+		stsEndpoint  = flag.String("sts-endpoint", "", "The default STS endpoint URL for pod-level credentials and `stsRoleArn`, e.g., a regional STS interface VPC endpoint. The regional STS endpoint is used if empty.")
+		stsRoles     = flag.String("sts-allowed-role-arns", "", "Comma-separated ARNs of the only roles `stsRoleArn` can assume with the CSI Driver's own credentials.")
+		mountTimeout = flag.Duration("mount-timeout", mounter.DefaultMountTimeout, "Timeout for Mountpoint to establish a mount.")
+		mountRetries = flag.Int("mount-retries", node.DefaultMountRetryPolicy.Retries, "Number of times to retry a failed mount before failing NodePublishVolume, can be overridden by `mountRetries` volume attribute.")
+		mountBackoff = flag.Duration("mount-retry-backoff", node.DefaultMountRetryPolicy.Backoff, "Initial backoff before retrying a failed mount, doubled with each retry. Can be overridden by `mountRetryBackoff` volume attribute.")
+		maxBackoff   = flag.Duration("mount-retry-max-backoff", node.DefaultMountRetryPolicy.MaxBackoff, "Maximum backoff before retrying a failed mount.")
+		rpcTimeout   = flag.Duration("rpc-timeout", 0, "Timeout of each CSI RPC. RPCs only have the deadlines set by their callers if 0.")
+		maxMounts    = flag.Int("max-concurrent-mounts", 0, "Maximum number of mount and unmount RPCs to handle concurrently, others wait for a slot until their deadline. Not limited if 0.")
+		maxUnmounts  = flag.Int("max-concurrent-unmounts", 0, "Maximum number of unmount RPCs to handle concurrently, in a pool separate from mount RPCs. Unmount RPCs share the limit of --max-concurrent-mounts if 0.")
+		maxMPs       = flag.Int("max-mountpoints", 0, "Maximum number of Mountpoint processes to run in this node. Volumes needing more fail to mount with `ResourceExhausted` until others are unmounted, and the capacity is advertised via annotations on the Node object. Not limited if 0.")
+		unmountGrace = flag.Duration("unmount-grace-period", 0, "How long to retry unmounting busy mounts, e.g. while Mountpoint is still uploading files being written, can be overridden by `unmountGracePeriod` volume attribute. Busy mounts fail to unmount immediately if 0.")
+		forceUnmount = flag.Duration("force-unmount-timeout", 0, "How long to wait for an unmount before unmounting forcefully, which aborts the FUSE connection of the hung Mountpoint process and detaches the mount lazily. Hung unmounts are not escalated if 0.")
+		configFile   = flag.Bool("mountpoint-config-file", false, "Pass mount options to Mountpoint processes spawned by the node plugin via a configuration file written next to the target path, rather than command-line arguments.")
+		mounterKind  = flag.String("mounter", mounter.KindSystemd, "How to spawn Mountpoint processes, either \"systemd\" to spawn them on the host via systemd, or \"process\" to spawn them as child processes of the CSI Driver Node Pod.")
+		procMemory   = flag.String("mountpoint-process-memory-limit", "", "Memory limit of each Mountpoint process with \"process\" mounter, e.g. \"2Gi\", enforced with cgroup v2. Not limited if empty.")
+		procCPU      = flag.String("mountpoint-process-cpu-limit", "", "CPU limit of each Mountpoint process with \"process\" mounter, e.g. \"500m\", enforced with cgroup v2. Not limited if empty.")
+		procRestarts = flag.Int("mountpoint-process-max-restarts", system.DefaultProcessRestartPolicy.MaxRestarts, "Maximum number of times to restart a Mountpoint process exiting with an error in a row with \"process\" mounter.")
+		credProcess  = flag.String("credential-process", "", "Command to obtain driver-level credentials with via `credential_process`, e.g. \"/opt/broker/bin/get-credentials --role s3\". The executable must exist at the same absolute path on the host and in the container. Driver-level credentials are resolved from the environment (IRSA or IMDS) if empty.")
+		vaultAddr    = flag.String("vault-address", "", "Address of HashiCorp Vault to retrieve credentials of volumes with `authenticationSource: vault` from. Vault is not used if empty.")
+		vaultNS      = flag.String("vault-namespace", "", "Vault Enterprise namespace to send requests to.")
+		vaultRole    = flag.String("vault-kubernetes-role", "", "Role to login to Vault with its Kubernetes auth method using the service account tokens of workload Pods, required with `--vault-address`.")
+		vaultAuth    = flag.String("vault-kubernetes-auth-path", vault.DefaultKubernetesAuthPath, "Path Vault's Kubernetes auth method is mounted at.")
+		vaultAud     = flag.String("vault-kubernetes-audience", vault.DefaultAudience, "Audience of the service account tokens of workload Pods to login to Vault with, which must be in `tokenRequests` of the CSIDriver object.")
+		checkTrust   = flag.Bool("pod-identity-trust-check", false, "Check roles of pod-level credentials trust the service accounts of workload Pods before mounting volumes, to fail with actionable errors for misconfigured trust policies.")
+		saWait       = flag.Duration("service-account-wait", mounter.DefaultServiceAccountWait, "How long to wait for the service account of a workload Pod with pod-level credentials to be created within a mount, e.g. if it's applied after the Pod by GitOps tooling. Kubelet retries the mount afterwards. Missing service accounts fail mounts immediately if 0.")
+		reissueToken = flag.String("reissue-pod-tokens", "", "Comma-separated service accounts in \"namespace/name\" format to re-issue tokens of pod-level credentials for close to expiry if kubelet has not republished their volumes with new tokens, and if token files are removed while the volumes are still published. Requires permission to create tokens of the service accounts. Tokens are not re-issued if empty.")
+		applyVACs    = flag.Bool("volume-attributes-classes", false, "Apply parameters of the VolumeAttributesClass of volumes when mounting them, which override `cacheDirSizeLimit`, `metadataTTL` and `logLevel` volume attributes. Requires `podInfoOnMount` on the CSIDriver object.")
+		purgeCaches  = flag.Bool("purge-caches-on-disk-pressure", false, "Purge local caches of volumes while the node reports `DiskPressure` condition, as they're not accounted as ephemeral storage of any Pod and kubelet can't reclaim them by evicting Pods.")
+		mountGroup   = flag.Bool("volume-mount-group", false, "Advertise `VOLUME_MOUNT_GROUP` capability, so kubelet passes `fsGroup` of workload Pods to volumes with `respectPodFSGroup`. Requires `fsGroupPolicy: File` on the CSIDriver object.")
+		allowInline  = flag.Bool("allow-inline-volumes", false, "Mount CSI ephemeral (inline) volumes declared in Pod specs, which are restricted to a subset of volume attributes and pod-level credentials. Requires `Ephemeral` in `volumeLifecycleModes` of the CSIDriver object.")
+		checkBuckets = flag.Bool("bucket-preflight-check", false, "Check buckets exist and are accessible with the credentials of volumes before mounting them, to fail with actionable errors for misconfigured buckets and IAM permissions.")
+		detectRegion = flag.Bool("detect-bucket-region", false, "Detect regions of buckets with a `HeadBucket` request signed with the credentials of volumes, sent to the S3 endpoint of the node's region or the endpoint of the volume, and pass them to Mountpoint if the region is not configured explicitly.")
 	)
 	klog.InitFlags(nil)
 	// Set logging to stderr false otherwise klog won't call our logger set via
@@ -47,7 +94,12 @@ func main() {
 	flag.Set("alsologtostderr", "false")
 	flag.Parse()
 
-	klog.SetOutput(&newlineEscapingStderrWriter{})
+	if *logFormat == logging.FormatText {
+		klog.SetOutput(&newlineEscapingStderrWriter{})
+	}
+	if err := logging.SetupKlog(*logFormat); err != nil {
+		klog.Fatalln(err)
+	}
 
 	if *printVersion {
 		info, err := version.GetVersionJSON()
@@ -65,16 +117,102 @@ func main() {
 		klog.Fatalln("node-id is required")
 	}
 
-	drv, err := driver.NewDriver(*endpoint, *mpVersion, *nodeID)
+	drv, err := driver.NewDriver(*endpoint, *mpVersion, *nodeID, *mounterKind)
 	if err != nil {
 		klog.Fatalf("failed to create driver: %s", err)
 	}
 
+	if drv.NodeServer != nil {
+		drv.NodeServer.MountOptionsPolicyFile = *policyFile
+		drv.NodeServer.MountRetryPolicy = node.MountRetryPolicy{Retries: *mountRetries, Backoff: *mountBackoff, MaxBackoff: *maxBackoff}
+		drv.NodeServer.UnmountGracePeriod = *unmountGrace
+		drv.NodeServer.ForceUnmountTimeout = *forceUnmount
+		drv.NodeServer.MaxMountpoints = *maxMPs
+		drv.NodeServer.PurgeCachesOnDiskPressure = *purgeCaches
+		switch m := drv.NodeServer.Mounter.(type) {
+		case *mounter.SystemdMounter:
+			m.MountTimeout = *mountTimeout
+			m.UseConfigFile = *configFile
+		case *mounter.ProcessMounter:
+			m.MountTimeout = *mountTimeout
+			m.UseConfigFile = *configFile
+			m.Supervisor.RestartPolicy.MaxRestarts = *procRestarts
+			limits, err := processLimits(*procMemory, *procCPU)
+			if err != nil {
+				klog.Fatalf("invalid Mountpoint process limits: %s", err)
+			}
+			// Limits are enabled before any Mountpoint process is started, see [system.ProcessSupervisor.EnableLimits].
+			if err := m.Supervisor.EnableLimits(limits); err != nil {
+				klog.Fatalf("failed to limit Mountpoint processes: %s", err)
+			}
+		}
+		for _, serviceAccount := range strings.Split(*reissueToken, ",") {
+			if serviceAccount = strings.TrimSpace(serviceAccount); serviceAccount != "" {
+				if namespace, name, ok := strings.Cut(serviceAccount, "/"); !ok || namespace == "" || name == "" {
+					klog.Fatalf("invalid service account %q in --reissue-pod-tokens, expected \"namespace/name\"", serviceAccount)
+				}
+				drv.NodeServer.ReissueTokenServiceAccounts = append(drv.NodeServer.ReissueTokenServiceAccounts, serviceAccount)
+			}
+		}
+		drv.NodeServer.ApplyVolumeAttributesClasses = *applyVACs
+		drv.NodeServer.VolumeMountGroup = *mountGroup
+		drv.NodeServer.AllowInlineVolumes = *allowInline
+		if *checkBuckets {
+			drv.NodeServer.BucketChecker = bucketcheck.NewChecker(bucketcheck.DefaultTimeout)
+		}
+		if *detectRegion {
+			drv.NodeServer.BucketRegionDetector = bucketregion.NewDetector(mounter.RegionFromIMDSOnce, bucketregion.DefaultCacheTTL)
+		}
+		stsConfig := mounter.STSConfig{Region: *stsRegion, Endpoint: *stsEndpoint, CheckTrust: *checkTrust}
+		for _, roleARN := range strings.Split(*stsRoles, ",") {
+			if roleARN = strings.TrimSpace(roleARN); roleARN != "" {
+				stsConfig.AllowedRoleARNs = append(stsConfig.AllowedRoleARNs, roleARN)
+			}
+		}
+		err := drv.NodeServer.SetDefaultSTSConfig(stsConfig)
+		if err != nil {
+			klog.Fatalf("invalid STS configuration: %s", err)
+		}
+		drv.NodeServer.SetServiceAccountWait(*saWait)
+		if err := drv.NodeServer.SetDriverCredentialProcess(*credProcess); err != nil {
+			klog.Fatalf("invalid credential process: %s", err)
+		}
+		if *vaultAddr != "" {
+			client, err := vault.NewClient(vault.Config{
+				Address:            *vaultAddr,
+				Namespace:          *vaultNS,
+				KubernetesRole:     *vaultRole,
+				KubernetesAuthPath: *vaultAuth,
+				Audience:           *vaultAud,
+			})
+			if err != nil {
+				klog.Fatalf("invalid Vault configuration: %s", err)
+			}
+			drv.NodeServer.SetCredentialBackend(mounter.AuthenticationSourceVault, client)
+		}
+	}
+
+	drv.Limits = driver.ServerLimits{RequestTimeout: *rpcTimeout, MaxConcurrentMounts: *maxMounts, MaxConcurrentUnmounts: *maxUnmounts}
+
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr)
+	}
+
 	if err := drv.Run(); err != nil {
 		klog.Fatalln(err)
 	}
 }
 
+// serveMetrics serves Prometheus metrics on `/metrics` path of given `addr`.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	klog.Infof("Serving metrics on address: %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Errorf("Failed to serve metrics on %s: %v", addr, err)
+	}
+}
+
 var (
 	newline       = []byte("\n")
 	newlineEscape = []byte("")
@@ -91,3 +229,23 @@ func (*newlineEscapingStderrWriter) Write(b []byte) (int, error) {
 	_, err := os.Stderr.Write(append(bytes.ReplaceAll(b, newline, newlineEscape), newline...))
 	return n, err
 }
+
+// processLimits parses `memory` and `cpu` limits of Mountpoint processes in Kubernetes quantity format.
+func processLimits(memory string, cpu string) (system.ResourceLimits, error) {
+	var limits system.ResourceLimits
+	if memory != "" {
+		quantity, err := resource.ParseQuantity(memory)
+		if err != nil {
+			return limits, fmt.Errorf("invalid memory limit %q: %w", memory, err)
+		}
+		limits.MemoryMax = quantity.Value()
+	}
+	if cpu != "" {
+		quantity, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return limits, fmt.Errorf("invalid CPU limit %q: %w", cpu, err)
+		}
+		limits.CPUMaxMillis = quantity.MilliValue()
+	}
+	return limits, nil
+}
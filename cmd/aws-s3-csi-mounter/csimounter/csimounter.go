@@ -7,9 +7,12 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 
 	"k8s.io/klog/v2"
 
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mountoptions"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
@@ -37,6 +40,8 @@ type Options struct {
 	MountpointPath string
 	MountOptions   mountoptions.Options
 	CmdRunner      CmdRunner
+	// Metrics is optional, and collects metrics of Mountpoint from its logs if set.
+	Metrics *MetricsCollector
 }
 
 // Run runs Mountpoint with given options until completion and returns its exit code and its error (if any).
@@ -58,6 +63,16 @@ func Run(options Options) (int, error) {
 	// and also we want to wait until it terminates. We're passing `--foreground` to achieve this.
 	mountpointArgs.Set(mountpoint.ArgForeground, mountpoint.ArgNoValue)
 
+	// The cache directory passed by the CSI Driver Node Pod is a path on the host,
+	// the cache volume is mounted at `mppod.CacheDirPath` inside the Mountpoint Pod.
+	if _, ok := mountpointArgs.Value(mountpoint.ArgCache); ok {
+		mountpointArgs.Set(mountpoint.ArgCache, mppod.CacheDirPath)
+	}
+
+	if options.Metrics != nil {
+		mountpointArgs.Set(mountpoint.ArgLogMetrics, mountpoint.ArgNoValue)
+	}
+
 	args := append([]string{
 		mountOptions.BucketName,
 		// We pass FUSE fd using `ExtraFiles`, and each entry becomes as file descriptor 3+i.
@@ -66,16 +81,21 @@ func Run(options Options) (int, error) {
 
 	cmd := exec.Command(options.MountpointPath, args...)
 	cmd.ExtraFiles = []*os.File{fuseDev}
-	cmd.Env = options.MountOptions.Env
+	cmd.Env = withProxy(options.MountOptions.Env)
 
 	var stderrBuf bytes.Buffer
 
-	// Connect Mountpoint's stdout/stderr to this commands stdout/stderr,
-	// so Mountpoint logs can be viewable with `kubectl logs`.
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	// Forward Mountpoint's stdout/stderr to this commands logs, so Mountpoint logs can be viewable with `kubectl logs`.
+	// Each line is tagged with the volume and the bucket to make them easier to filter in log aggregators.
+	stdout := newLogForwarder("stdout", mountOptions.VolumeID, mountOptions.BucketName)
+	stderr := newLogForwarder("stderr", mountOptions.VolumeID, mountOptions.BucketName)
+	stdout.metrics, stderr.metrics = options.Metrics, options.Metrics
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(stderr, &stderrBuf)
 
 	exitCode, err := options.CmdRunner(cmd)
+	stdout.Flush()
+	stderr.Flush()
 	if err != nil {
 		// If Mountpoint fails, write it to `mountErrorPath` to let `PodMounter` running in the same node know.
 		if writeErr := os.WriteFile(mountErrorPath, stderrBuf.Bytes(), mountErrorFileperm); writeErr != nil {
@@ -86,3 +106,14 @@ func Run(options Options) (int, error) {
 
 	return exitCode, nil
 }
+
+// withProxy returns `env` with the proxy settings of this container appended, which are set on Mountpoint Pods
+// by the controller, unless `env` already has them.
+func withProxy(env []string) []string {
+	for key, value := range envprovider.ProxyFromEnvironment() {
+		if !slices.ContainsFunc(env, func(e string) bool { return strings.HasPrefix(e, key+"=") }) {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
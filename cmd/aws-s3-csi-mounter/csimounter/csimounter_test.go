@@ -1,15 +1,23 @@
 package csimounter_test
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/klog/v2"
 
 	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-mounter/csimounter"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter/mountertest"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mountoptions"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
 )
 
@@ -111,6 +119,122 @@ func TestRunningMountpoint(t *testing.T) {
 		assert.Equals(t, 0, exitCode)
 	})
 
+	t.Run("Replaces cache directory with the cache volume", func(t *testing.T) {
+		runner := func(c *exec.Cmd) (int, error) {
+			assert.Equals(t, []string{
+				mountpointPath,
+				"test-bucket", "/dev/fd/3",
+				"--cache=" + mppod.CacheDirPath,
+				"--foreground",
+			}, c.Args)
+			return 0, nil
+		}
+
+		exitCode, err := csimounter.Run(csimounter.Options{
+			MountpointPath: mountpointPath,
+			MountOptions: mountoptions.Options{
+				Fd:         int(mountertest.OpenDevNull(t).Fd()),
+				BucketName: "test-bucket",
+				Args:       []string{"--cache=/var/lib/kubelet/pods/test-pod/volumes/kubernetes.io~csi/test-vol/mountpoint-cache"},
+			},
+			CmdRunner: runner,
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, exitCode)
+	})
+
+	t.Run("Forwards Mountpoint logs tagged with volume and bucket", func(t *testing.T) {
+		var logs bytes.Buffer
+		klog.LogToStderr(false)
+		klog.SetOutput(&logs)
+		t.Cleanup(func() {
+			klog.SetOutput(os.Stderr)
+			klog.LogToStderr(true)
+		})
+
+		runner := func(c *exec.Cmd) (int, error) {
+			fmt.Fprint(c.Stdout, "mounted successfully\nsecond line without newline")
+			fmt.Fprint(c.Stderr, "an error\n")
+			return 0, nil
+		}
+
+		exitCode, err := csimounter.Run(csimounter.Options{
+			MountpointPath: mountpointPath,
+			MountOptions: mountoptions.Options{
+				Fd:         int(mountertest.OpenDevNull(t).Fd()),
+				VolumeID:   "test-volume",
+				BucketName: "test-bucket",
+			},
+			CmdRunner: runner,
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, exitCode)
+
+		for _, want := range []string{
+			`"mounted successfully" volume_id="test-volume" bucket="test-bucket" stream="stdout"`,
+			`"second line without newline" volume_id="test-volume" bucket="test-bucket" stream="stdout"`,
+			`"an error" volume_id="test-volume" bucket="test-bucket" stream="stderr"`,
+		} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected logs to contain %s, got: %s", want, logs.String())
+			}
+		}
+	})
+
+	t.Run("Logs a hint once for KMS access denied errors", func(t *testing.T) {
+		var logs bytes.Buffer
+		klog.LogToStderr(false)
+		klog.SetOutput(&logs)
+		t.Cleanup(func() {
+			klog.SetOutput(os.Stderr)
+			klog.LogToStderr(true)
+		})
+
+		runner := func(c *exec.Cmd) (int, error) {
+			for i := 0; i < 2; i++ {
+				fmt.Fprint(c.Stderr, "WARN mountpoint_s3::fuse: release failed: put failed: Client error: AccessDenied: User is not authorized to perform: kms:GenerateDataKey\n")
+			}
+			return 0, nil
+		}
+
+		exitCode, err := csimounter.Run(csimounter.Options{
+			MountpointPath: mountpointPath,
+			MountOptions: mountoptions.Options{
+				Fd:         int(mountertest.OpenDevNull(t).Fd()),
+				VolumeID:   "test-volume",
+				BucketName: "test-bucket",
+			},
+			CmdRunner: runner,
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, exitCode)
+		assert.Equals(t, 1, strings.Count(logs.String(), "requires kms:GenerateDataKey permission"))
+	})
+
+	t.Run("Collects metrics from Mountpoint logs", func(t *testing.T) {
+		metrics := csimounter.NewMetricsCollector("test-volume", "test-bucket")
+		runner := func(c *exec.Cmd) (int, error) {
+			args := mountpoint.ParseArgs(c.Args)
+			assert.Equals(t, true, args.Has(mountpoint.ArgLogMetrics))
+			fmt.Fprint(c.Stdout, "INFO mountpoint_s3::metrics: s3.requests[op=GetObject]: 12 (n=3)\n")
+			return 0, nil
+		}
+
+		exitCode, err := csimounter.Run(csimounter.Options{
+			MountpointPath: mountpointPath,
+			MountOptions: mountoptions.Options{
+				Fd:         int(mountertest.OpenDevNull(t).Fd()),
+				VolumeID:   "test-volume",
+				BucketName: "test-bucket",
+			},
+			CmdRunner: runner,
+			Metrics:   metrics,
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, exitCode)
+		assert.Equals(t, 1, testutil.CollectAndCount(metrics, "mountpoint_s3_requests_total"))
+	})
+
 	t.Run("Fails if file descriptor is invalid", func(t *testing.T) {
 		_, err := csimounter.Run(csimounter.Options{
 			MountpointPath: mountpointPath,
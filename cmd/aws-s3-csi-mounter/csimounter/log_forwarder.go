@@ -0,0 +1,80 @@
+package csimounter
+
+import (
+	"bytes"
+	"regexp"
+
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// kmsAccessDeniedPattern matches errors of Mountpoint caused by missing permissions on the KMS key objects are encrypted with.
+var kmsAccessDeniedPattern = regexp.MustCompile(`(?i)(AccessDenied|Forbidden|not authorized).*kms|kms.*(AccessDenied|Forbidden|not authorized)`)
+
+// kmsAccessDeniedHint is logged once Mountpoint fails due to missing KMS permissions, as S3 errors don't mention
+// which permission is missing.
+const kmsAccessDeniedHint = "Access to the KMS key of the bucket or the volume was denied. " +
+	"Uploading objects encrypted with SSE-KMS requires kms:GenerateDataKey permission on the key, " +
+	"and reading them requires kms:Decrypt permission, please check the key policy and the IAM policy of the credentials used by Mountpoint"
+
+// A logForwarder forwards output of Mountpoint to this process' logs line by line,
+// tagging each line with the volume and the bucket Mountpoint serves.
+//
+// Forwarded lines are emitted as structured logs, so they'll have `volume_id` and `bucket` fields
+// if this process is configured to emit JSON logs. Lines with metrics are recorded by `metrics` if it's set,
+// and only forwarded at a higher verbosity as they're emitted every few seconds.
+// A hint for missing KMS permissions is logged once if Mountpoint reports access denied errors from KMS.
+type logForwarder struct {
+	keysAndValues []interface{}
+	buf           []byte
+	metrics       *MetricsCollector
+	kmsHintLogged bool
+}
+
+// newLogForwarder returns a new log forwarder for Mountpoint's `stream` (e.g., "stdout") serving given `volumeID` and `bucket`.
+func newLogForwarder(stream, volumeID, bucket string) *logForwarder {
+	return &logForwarder{
+		keysAndValues: []interface{}{logging.KeyVolumeID, volumeID, "bucket", bucket, "stream", stream},
+	}
+}
+
+// Write forwards complete lines in `p` and buffers the remaining part until the next newline.
+func (f *logForwarder) Write(p []byte) (int, error) {
+	f.buf = append(f.buf, p...)
+	for {
+		i := bytes.IndexByte(f.buf, '\n')
+		if i < 0 {
+			break
+		}
+		f.forward(f.buf[:i])
+		f.buf = f.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush forwards the buffered incomplete line, if any.
+// It should be called after Mountpoint terminates.
+func (f *logForwarder) Flush() {
+	if len(f.buf) > 0 {
+		f.forward(f.buf)
+		f.buf = nil
+	}
+}
+
+func (f *logForwarder) forward(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+	if f.metrics != nil && f.metrics.Observe(string(line)) {
+		klog.V(4).InfoS(string(line), f.keysAndValues...)
+		return
+	}
+	klog.InfoS(string(line), f.keysAndValues...)
+
+	if !f.kmsHintLogged && kmsAccessDeniedPattern.Match(line) {
+		f.kmsHintLogged = true
+		klog.InfoS(kmsAccessDeniedHint, f.keysAndValues...)
+	}
+}
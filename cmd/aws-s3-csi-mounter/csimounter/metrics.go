@@ -0,0 +1,181 @@
+package csimounter
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// metricsLogTarget is the target of Mountpoint's log lines with metrics, which are emitted every few seconds
+// with `--log-metrics` and summarize the metrics since the previous emission.
+const metricsLogTarget = "mountpoint_s3::metrics: "
+
+// metricsNamespace is the prefix of the names of Mountpoint metrics exposed in Prometheus format.
+const metricsNamespace = "mountpoint"
+
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// A MetricsCollector collects metrics of Mountpoint from its `--log-metrics` output, and exposes them as Prometheus
+// metrics labelled by the volume and the bucket Mountpoint serves. It implements [prometheus.Collector].
+//
+// Names of the metrics follow Mountpoint's metric names, e.g. `s3.requests[op=GetObject]` is exposed as
+// `mountpoint_s3_requests_total{op="GetObject"}`:
+//   - Counters, which Mountpoint reports as a sum since the previous emission (e.g., `12 (n=3)`), are accumulated
+//     into `_total` counters.
+//   - Gauges, which Mountpoint reports as a single value, are exposed with their latest value.
+//   - Histograms, which Mountpoint reports as a summary (e.g., `n=3: min=1 p50=2 ... max=5`), are exposed as
+//     a `_count` counter of observations and gauges of their latest summary by `stat` label.
+type MetricsCollector struct {
+	volumeID string
+	bucket   string
+
+	mu      sync.Mutex
+	samples map[string]*metricSample
+}
+
+type metricSample struct {
+	name      string
+	labels    map[string]string
+	valueType prometheus.ValueType
+	value     float64
+}
+
+// NewMetricsCollector returns a new collector for Mountpoint serving given `volumeID` and `bucket`.
+func NewMetricsCollector(volumeID, bucket string) *MetricsCollector {
+	return &MetricsCollector{volumeID: volumeID, bucket: bucket, samples: map[string]*metricSample{}}
+}
+
+// Observe records the metric in given log `line` of Mountpoint, and returns whether it's a metric line.
+func (c *MetricsCollector) Observe(line string) bool {
+	_, metric, ok := strings.Cut(line, metricsLogTarget)
+	if !ok {
+		return false
+	}
+	key, value, ok := strings.Cut(metric, ": ")
+	if !ok {
+		return false
+	}
+	name, labels := parseMetricKey(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if count, summary, ok := strings.Cut(value, ": "); ok && strings.HasPrefix(count, "n=") {
+		if n, err := strconv.ParseFloat(strings.TrimPrefix(count, "n="), 64); err == nil {
+			c.add(name+"_count", labels, prometheus.CounterValue, n)
+		}
+		for _, stat := range strings.Fields(summary) {
+			statName, statValue, ok := strings.Cut(stat, "=")
+			if v, err := strconv.ParseFloat(statValue, 64); ok && err == nil {
+				c.set(name, withLabel(labels, "stat", statName), v)
+			}
+		}
+		return true
+	}
+
+	value, _, isCounter := strings.Cut(value, " (n=")
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return true
+	}
+	if isCounter {
+		c.add(name+"_total", labels, prometheus.CounterValue, v)
+	} else {
+		c.set(name, labels, v)
+	}
+	return true
+}
+
+func (c *MetricsCollector) add(name string, labels map[string]string, valueType prometheus.ValueType, v float64) {
+	c.sample(name, labels, valueType).value += v
+}
+
+func (c *MetricsCollector) set(name string, labels map[string]string, v float64) {
+	c.sample(name, labels, prometheus.GaugeValue).value = v
+}
+
+func (c *MetricsCollector) sample(name string, labels map[string]string, valueType prometheus.ValueType) *metricSample {
+	key := name
+	for _, k := range sortedKeys(labels) {
+		key += "," + k + "=" + labels[k]
+	}
+	s, ok := c.samples[key]
+	if !ok {
+		s = &metricSample{name: name, labels: labels, valueType: valueType}
+		c.samples[key] = s
+	}
+	return s
+}
+
+// Describe implements [prometheus.Collector]. It does not describe any metrics, as Mountpoint's metrics
+// are only known once they're reported, which makes it an unchecked collector.
+func (c *MetricsCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements [prometheus.Collector].
+func (c *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Samples of the same metric must have the same label names, labels missing in some samples are left empty.
+	labelNames := map[string][]string{}
+	for _, s := range c.samples {
+		for k := range s.labels {
+			if !slices.Contains(labelNames[s.name], k) {
+				labelNames[s.name] = append(labelNames[s.name], k)
+			}
+		}
+	}
+
+	for _, s := range c.samples {
+		names := slices.Clone(labelNames[s.name])
+		sort.Strings(names)
+		values := make([]string, 0, len(names)+2)
+		for _, k := range names {
+			values = append(values, s.labels[k])
+		}
+		desc := prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", s.name),
+			"Mountpoint metric "+s.name+", see Mountpoint's documentation for its meaning.",
+			append(names, logging.KeyVolumeID, "bucket"), nil)
+		ch <- prometheus.MustNewConstMetric(desc, s.valueType, s.value, append(values, c.volumeID, c.bucket)...)
+	}
+}
+
+// parseMetricKey parses a metric key of Mountpoint, e.g. `s3.requests[op=GetObject,type=Default]`,
+// into a Prometheus metric name and labels.
+func parseMetricKey(key string) (string, map[string]string) {
+	name, rest, _ := strings.Cut(key, "[")
+	labels := map[string]string{}
+	for _, label := range strings.Split(strings.TrimSuffix(rest, "]"), ",") {
+		if k, v, ok := strings.Cut(label, "="); ok {
+			labels[sanitizeMetricName(k)] = v
+		}
+	}
+	return sanitizeMetricName(name), labels
+}
+
+func sanitizeMetricName(name string) string {
+	return invalidMetricNameChars.ReplaceAllString(name, "_")
+}
+
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	labels = maps.Clone(labels)
+	labels[key] = value
+	return labels
+}
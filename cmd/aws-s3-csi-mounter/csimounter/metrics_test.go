@@ -0,0 +1,51 @@
+package csimounter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-mounter/csimounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCollectingMountpointMetrics(t *testing.T) {
+	collector := csimounter.NewMetricsCollector("test-volume", "test-bucket")
+
+	for _, line := range []string{
+		"2024-01-01T00:00:00.000Z  INFO mountpoint_s3::metrics: fuse.io_size[type=read]: n=2: min=1024 p10=1024 p50=1024 avg=1536 p90=2048 p99=2048 p99.9=2048 max=2048",
+		"2024-01-01T00:00:00.000Z  INFO mountpoint_s3::metrics: s3.requests[op=GetObject]: 12 (n=3)",
+		"2024-01-01T00:00:00.000Z  INFO mountpoint_s3::metrics: s3.requests[op=ListObjectsV2]: 1 (n=1)",
+		"2024-01-01T00:00:00.000Z  INFO mountpoint_s3::metrics: process.memory_usage: 1048576",
+		"2024-01-01T00:00:05.000Z  INFO mountpoint_s3::metrics: s3.requests[op=GetObject]: 8 (n=2)",
+		"2024-01-01T00:00:05.000Z  INFO mountpoint_s3::metrics: process.memory_usage: 2097152",
+	} {
+		assert.Equals(t, true, collector.Observe(line))
+	}
+	assert.Equals(t, false, collector.Observe("2024-01-01T00:00:05.000Z  WARN mountpoint_s3::fuse: lookup failed"))
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP mountpoint_fuse_io_size Mountpoint metric fuse_io_size, see Mountpoint's documentation for its meaning.
+# TYPE mountpoint_fuse_io_size gauge
+mountpoint_fuse_io_size{bucket="test-bucket",stat="avg",type="read",volume_id="test-volume"} 1536
+mountpoint_fuse_io_size{bucket="test-bucket",stat="max",type="read",volume_id="test-volume"} 2048
+mountpoint_fuse_io_size{bucket="test-bucket",stat="min",type="read",volume_id="test-volume"} 1024
+mountpoint_fuse_io_size{bucket="test-bucket",stat="p10",type="read",volume_id="test-volume"} 1024
+mountpoint_fuse_io_size{bucket="test-bucket",stat="p50",type="read",volume_id="test-volume"} 1024
+mountpoint_fuse_io_size{bucket="test-bucket",stat="p90",type="read",volume_id="test-volume"} 2048
+mountpoint_fuse_io_size{bucket="test-bucket",stat="p99",type="read",volume_id="test-volume"} 2048
+mountpoint_fuse_io_size{bucket="test-bucket",stat="p99.9",type="read",volume_id="test-volume"} 2048
+# HELP mountpoint_fuse_io_size_count Mountpoint metric fuse_io_size_count, see Mountpoint's documentation for its meaning.
+# TYPE mountpoint_fuse_io_size_count counter
+mountpoint_fuse_io_size_count{bucket="test-bucket",type="read",volume_id="test-volume"} 2
+# HELP mountpoint_process_memory_usage Mountpoint metric process_memory_usage, see Mountpoint's documentation for its meaning.
+# TYPE mountpoint_process_memory_usage gauge
+mountpoint_process_memory_usage{bucket="test-bucket",volume_id="test-volume"} 2.097152e+06
+# HELP mountpoint_s3_requests_total Mountpoint metric s3_requests_total, see Mountpoint's documentation for its meaning.
+# TYPE mountpoint_s3_requests_total counter
+mountpoint_s3_requests_total{bucket="test-bucket",op="GetObject",volume_id="test-volume"} 20
+mountpoint_s3_requests_total{bucket="test-bucket",op="ListObjectsV2",volume_id="test-volume"} 1
+`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,101 @@
+package csimounter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketcheck"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mountoptions"
+)
+
+// DefaultValidationTimeout is the default timeout of validating mount options.
+const DefaultValidationTimeout = 30 * time.Second
+
+// A ValidationResult represents the machine-readable result of validating mount options with `--validate-only`.
+type ValidationResult struct {
+	Valid  bool   `json:"valid"`
+	Bucket string `json:"bucket"`
+	Region string `json:"region,omitempty"`
+	// CredentialsSource is the source of the resolved credentials, e.g. `WebIdentityCredentials` or `ProcessProvider`.
+	CredentialsSource string `json:"credentialsSource,omitempty"`
+	// Code is the gRPC code of the problem if the mount options are not valid, e.g. `NotFound` or `PermissionDenied`.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Validate resolves credentials from the environment of given mount `options` and checks the bucket is accessible
+// with them, without mounting it. The environment of the mount options must be applied to the current process
+// before calling it (see [ApplyEnv]), as Mountpoint would be spawned with it.
+func Validate(ctx context.Context, options mountoptions.Options) ValidationResult {
+	result := ValidationResult{Bucket: options.BucketName}
+	fail := func(err error) ValidationResult {
+		result.Code = status.Code(err).String()
+		result.Message = status.Convert(err).Message()
+		return result
+	}
+
+	args := mountpoint.ParseArgs(options.Args)
+	region, _ := args.Value(mountpoint.ArgRegion)
+	region = cmp.Or(region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), config.WithEC2IMDSRegion())
+	if err != nil {
+		return fail(status.Errorf(codes.InvalidArgument, "Failed to load AWS config: %v", err))
+	}
+	result.Region = cfg.Region
+	if cfg.Region == "" {
+		return fail(status.Error(codes.InvalidArgument, "Failed to detect the region of the bucket, please set it with `region` mount option"))
+	}
+
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fail(status.Errorf(codes.Unauthenticated, "Failed to resolve credentials: %v", err))
+	}
+	result.CredentialsSource = credentials.Source
+
+	prefix, _ := args.Value(mountpoint.ArgPrefix)
+	endpoint, _ := args.Value(mountpoint.ArgEndpointURL)
+	err = bucketcheck.NewChecker(bucketcheck.DefaultTimeout).CheckBucket(ctx, bucketcheck.Input{
+		Bucket:         options.BucketName,
+		Prefix:         prefix,
+		Region:         cfg.Region,
+		Endpoint:       endpoint,
+		ForcePathStyle: args.Has(mountpoint.ArgForcePathStyle),
+		Credentials:    cfg.Credentials,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Valid = true
+	return result
+}
+
+// ApplyEnv replaces AWS environment variables of the current process with the ones in `env`,
+// so the AWS SDK resolves credentials and configuration as Mountpoint would be spawned with `env`.
+func ApplyEnv(env []string) error {
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); strings.HasPrefix(key, "AWS_") {
+			os.Unsetenv(key)
+		}
+	}
+	for _, entry := range env {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid environment variable %q", entry)
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package csimounter_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-mounter/csimounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mountoptions"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestValidatingMountOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/") {
+		case "test-bucket":
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`<ListBucketResult><KeyCount>0</KeyCount><IsTruncated>false</IsTruncated></ListBucketResult>`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Environment variables of the test process are restored after the test, `ApplyEnv` clears them.
+	t.Setenv("AWS_PROFILE", "leaked-from-node")
+
+	validate := func(bucket string, env []string) csimounter.ValidationResult {
+		assert.NoError(t, csimounter.ApplyEnv(env))
+		return csimounter.Validate(context.Background(), mountoptions.Options{
+			BucketName: bucket,
+			Args:       []string{"--endpoint-url=" + server.URL, "--force-path-style", "--region=us-east-1"},
+			Env:        env,
+		})
+	}
+	credentialsEnv := []string{"AWS_ACCESS_KEY_ID=access-key", "AWS_SECRET_ACCESS_KEY=secret-key"}
+
+	assert.Equals(t, csimounter.ValidationResult{
+		Valid:             true,
+		Bucket:            "test-bucket",
+		Region:            "us-east-1",
+		CredentialsSource: "EnvConfigCredentials",
+	}, validate("test-bucket", credentialsEnv))
+
+	assert.Equals(t, csimounter.ValidationResult{
+		Bucket:            "missing-bucket",
+		Region:            "us-east-1",
+		CredentialsSource: "EnvConfigCredentials",
+		Code:              "NotFound",
+		Message:           `Bucket "missing-bucket" not found, please check the bucket name and that it exists`,
+	}, validate("missing-bucket", credentialsEnv))
+
+	// Credentials are only resolved from the environment of the mount options
+	result := validate("test-bucket", []string{"AWS_EC2_METADATA_DISABLED=true", "AWS_CONFIG_FILE=/dev/null", "AWS_SHARED_CREDENTIALS_FILE=/dev/null"})
+	assert.Equals(t, false, result.Valid)
+	assert.Equals(t, "Unauthenticated", result.Code)
+}
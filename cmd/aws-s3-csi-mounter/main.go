@@ -4,23 +4,40 @@
 // It is responsible for receiving mount options from the CSI Driver Node Pod,
 // and spawning a Mountpoint instance in turn.
 // It will then wait until Mountpoint process terminates (which normally happens as a result of `unmount`).
+//
+// With `--metrics-address`, it also exposes metrics of Mountpoint collected from its logs in Prometheus format.
+//
+// With `--validate-only`, it instead resolves credentials from the received mount options and checks the bucket
+// is accessible with them without mounting it, and prints the result as a JSON object to stdout.
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog/v2"
 
 	"github.com/awslabs/aws-s3-csi-driver/cmd/aws-s3-csi-mounter/csimounter"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mountoptions"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
 )
 
 var mountSockRecvTimeout = flag.Duration("mount-sock-recv-timeout", 2*time.Minute, "Timeout for receiving mount options from passed Unix socket.")
+var logFormat = logging.RegisterFlag(logging.FormatText)
+var validateOnly = flag.Bool("validate-only", false, "Validate received mount options by resolving credentials and checking the bucket is accessible, without mounting it. The result is printed as a JSON object, and the exit code is non-zero if the mount options are not valid.")
+var mountOptionsFile = flag.String("mount-options-file", "", "Path of a JSON file to read mount options from with --validate-only instead of receiving them from the Unix socket, \"-\" for stdin.")
+var validationTimeout = flag.Duration("validation-timeout", csimounter.DefaultValidationTimeout, "Timeout for validating mount options with --validate-only.")
+var metricsAddress = flag.String("metrics-address", "", "The address to expose metrics of Mountpoint on in Prometheus format, e.g. \":9811\". Metrics are not exposed if empty.")
 var mountpointBinDir = flag.String("mountpoint-bin-dir", os.Getenv("MOUNTPOINT_BIN_DIR"), "Directory of mount-s3 binary.")
 
 var mountSockPath = mppod.PathInsideMountpointPod(mppod.KnownPathMountSock)
@@ -31,12 +48,27 @@ func main() {
 	klog.InitFlags(nil)
 	flag.Parse()
 
+	if err := logging.SetupKlog(*logFormat); err != nil {
+		klog.Fatalln(err)
+	}
+
+	if *validateOnly {
+		os.Exit(validate())
+	}
+
 	mountpointBinFullPath := filepath.Join(*mountpointBinDir, mountpointBin)
 	mountOptions := recvMountOptions()
 
+	var metrics *csimounter.MetricsCollector
+	if *metricsAddress != "" {
+		metrics = csimounter.NewMetricsCollector(mountOptions.VolumeID, mountOptions.BucketName)
+		go serveMetrics(*metricsAddress, metrics)
+	}
+
 	exitCode, err := csimounter.Run(csimounter.Options{
 		MountpointPath: mountpointBinFullPath,
 		MountOptions:   mountOptions,
+		Metrics:        metrics,
 	})
 	if err != nil {
 		klog.Fatalf("Failed to run Mountpoint: %v\n", err)
@@ -45,6 +77,18 @@ func main() {
 	os.Exit(exitCode)
 }
 
+// serveMetrics serves metrics collected by `metrics` on `/metrics` path of given `addr`.
+func serveMetrics(addr string, metrics *csimounter.MetricsCollector) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	klog.Infof("Serving metrics on address: %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Errorf("Failed to serve metrics on %s: %v", addr, err)
+	}
+}
+
 func recvMountOptions() mountoptions.Options {
 	ctx, cancel := context.WithTimeout(context.Background(), *mountSockRecvTimeout)
 	defer cancel()
@@ -56,3 +100,50 @@ func recvMountOptions() mountoptions.Options {
 	klog.Infof("Mount options has been received from %s", mountSockPath)
 	return options
 }
+
+// validate validates mount options without mounting, prints the result to stdout, and returns the exit code.
+func validate() int {
+	var mountOptions mountoptions.Options
+	if *mountOptionsFile != "" {
+		mountOptions = readMountOptions(*mountOptionsFile)
+	} else {
+		mountOptions = recvMountOptions()
+		// FUSE device is not used without mounting.
+		syscall.Close(mountOptions.Fd)
+	}
+
+	if err := csimounter.ApplyEnv(mountOptions.Env); err != nil {
+		klog.Fatalf("Failed to apply environment of mount options: %v\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *validationTimeout)
+	defer cancel()
+	result := csimounter.Validate(ctx, mountOptions)
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		klog.Fatalf("Failed to print validation result: %v\n", err)
+	}
+	if !result.Valid {
+		return 1
+	}
+	return 0
+}
+
+// readMountOptions reads mount options as a JSON object from `path`, or from stdin if it's "-".
+func readMountOptions(path string) mountoptions.Options {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		klog.Fatalf("Failed to read mount options from %s: %v\n", path, err)
+	}
+
+	var options mountoptions.Options
+	if err := json.Unmarshal(data, &options); err != nil {
+		klog.Fatalf("Failed to parse mount options from %s: %v\n", path, err)
+	}
+	return options
+}
@@ -5,19 +5,24 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/util"
 )
 
 const (
-	binDirKey     = "MOUNTPOINT_BIN_DIR"
-	installDirKey = "MOUNTPOINT_INSTALL_DIR"
+	binDirKey        = "MOUNTPOINT_BIN_DIR"
+	installDirKey    = "MOUNTPOINT_INSTALL_DIR"
+	checksumsFileKey = "MOUNTPOINT_CHECKSUMS_FILE"
 )
 
 // Copies files from a directory to a new directory
 // $ cp $SOURCE_DIR/* $DESTDIR/
 // Written as a go program to avoid bash and cp dependencies in the container.
 // Does not handle nested directories or anything beyond the simple install.
+//
+// Before copying any file, ELF binaries are checked to be built for the architecture of the node, and if
+// $MOUNTPOINT_CHECKSUMS_FILE is set, all files are checked to match their SHA256 checksums in it.
 func main() {
 	binDir := os.Getenv(binDirKey)
 	installDir := os.Getenv(installDirKey)
@@ -25,13 +30,13 @@ func main() {
 		log.Fatalf("Missing environment variable, %s and %s required", binDirKey, installDirKey)
 	}
 
-	err := installFiles(binDir, installDir)
+	err := installFiles(binDir, installDir, os.Getenv(checksumsFileKey))
 	if err != nil {
 		log.Fatalf("Failed install binDir %s installDir %s: %v", binDir, installDir, err)
 	}
 }
 
-func installFiles(binDir string, installDir string) error {
+func installFiles(binDir string, installDir string, checksumsFile string) error {
 
 	sd, err := os.Open(binDir)
 	if err != nil {
@@ -44,6 +49,26 @@ func installFiles(binDir string, installDir string) error {
 		return fmt.Errorf("Failed to read source directory: %w", err)
 	}
 
+	var checksums map[string]string
+	if checksumsFile != "" {
+		checksums, err = readChecksums(checksumsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range entries {
+		path := filepath.Join(binDir, name)
+		if err := checkArchitecture(path, runtime.GOARCH); err != nil {
+			return err
+		}
+		if checksums != nil {
+			if err := checkChecksum(path, checksums); err != nil {
+				return err
+			}
+		}
+	}
+
 	for _, name := range entries {
 		log.Printf("Copying file %s\n", name)
 		destFile := filepath.Join(installDir, name)
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// elfMachines maps Go architectures to the machine types of ELF binaries built for them.
+var elfMachines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+}
+
+// checkArchitecture returns an error if the file at `path` is an ELF binary built for an architecture other than `goarch`.
+// Files that are not ELF binaries, and architectures without a known ELF machine type, are not checked.
+func checkArchitecture(path string, goarch string) error {
+	expected, ok := elfMachines[goarch]
+	if !ok {
+		return nil
+	}
+
+	f, err := elf.Open(path)
+	if err != nil {
+		var formatErr *elf.FormatError
+		if errors.As(err, &formatErr) {
+			return nil
+		}
+		return fmt.Errorf("Failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if f.Machine != expected {
+		return fmt.Errorf("%s is built for %s but this node is %s (%s), please check the image matches the architecture of the node", filepath.Base(path), f.Machine, goarch, expected)
+	}
+	return nil
+}
+
+// readChecksums reads a manifest in the format of `sha256sum` output from `path`,
+// and returns hex-encoded SHA256 checksums by file name.
+func readChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checksums file: %w", err)
+	}
+	defer f.Close()
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		checksum, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("Invalid line in checksums file: %q", line)
+		}
+		// `sha256sum` prefixes names with `*` in binary mode
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		checksums[filepath.Base(name)] = strings.ToLower(checksum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read checksums file: %w", err)
+	}
+	return checksums, nil
+}
+
+// checkChecksum returns an error if the SHA256 checksum of the file at `path` is not the one in `checksums`.
+func checkChecksum(path string, checksums map[string]string) error {
+	name := filepath.Base(path)
+	expected, ok := checksums[name]
+	if !ok {
+		return fmt.Errorf("%s is not in checksums file", name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("Failed to read %s: %w", name, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("Checksum of %s is %s but expected %s, the file might be corrupted or tampered with", name, actual, expected)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCheckingArchitecture(t *testing.T) {
+	// The test binary itself is an ELF binary built for the current architecture
+	testBinary, err := os.Executable()
+	assert.NoError(t, err)
+	if runtime.GOOS != "linux" {
+		t.Skip("Test binary is only an ELF binary on Linux")
+	}
+
+	assert.NoError(t, checkArchitecture(testBinary, runtime.GOARCH))
+
+	otherArch := "arm64"
+	if runtime.GOARCH == "arm64" {
+		otherArch = "amd64"
+	}
+	err = checkArchitecture(testBinary, otherArch)
+	if err == nil || !strings.Contains(err.Error(), "please check the image matches the architecture of the node") {
+		t.Fatalf("Expected an architecture mismatch error, got: %v", err)
+	}
+
+	// Files other than ELF binaries are not checked
+	script := filepath.Join(t.TempDir(), "script.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"), 0755))
+	assert.NoError(t, checkArchitecture(script, otherArch))
+}
+
+func TestInstallingFilesWithChecksums(t *testing.T) {
+	binDir, installDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "mount-s3"), []byte("mount-s3"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "libfuse.so.2"), []byte("libfuse"), 0755))
+
+	checksumsFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+	writeChecksums := func(content string) {
+		t.Helper()
+		assert.NoError(t, os.WriteFile(checksumsFile, []byte(content), 0644))
+	}
+	mountS3Checksum := "10f418ee11aae2a1d4268407bce9b25365072171063c229f80c9ceae1a743d23"
+	libfuseChecksum := "e2bf63da72ed4bf622c0b420545a55180f4c80a5269a672ffb689b225709a803"
+
+	// Files missing from the manifest are not installed
+	writeChecksums(mountS3Checksum + "  mount-s3\n")
+	err := installFiles(binDir, installDir, checksumsFile)
+	if err == nil || !strings.Contains(err.Error(), "libfuse.so.2 is not in checksums file") {
+		t.Fatalf("Expected a missing checksum error, got: %v", err)
+	}
+	_, err = os.Stat(filepath.Join(installDir, "mount-s3"))
+	assert.Equals(t, true, os.IsNotExist(err))
+
+	// Checksums in `sha256sum` output format, in binary and text modes
+	writeChecksums(mountS3Checksum + " *mount-s3\n" + libfuseChecksum + "  libfuse.so.2\n")
+	assert.NoError(t, installFiles(binDir, installDir, checksumsFile))
+	installed, err := os.ReadFile(filepath.Join(installDir, "mount-s3"))
+	assert.NoError(t, err)
+	assert.Equals(t, "mount-s3", string(installed))
+
+	// Tampered files are not installed
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "mount-s3"), []byte("tampered"), 0755))
+	err = installFiles(binDir, installDir, checksumsFile)
+	if err == nil || !strings.Contains(err.Error(), "might be corrupted or tampered with") {
+		t.Fatalf("Expected a checksum mismatch error, got: %v", err)
+	}
+}
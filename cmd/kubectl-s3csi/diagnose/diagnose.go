@@ -0,0 +1,354 @@
+// Package diagnose collects a consolidated report of the S3 volumes of a workload Pod, by correlating its PVs,
+// the CSI Driver Node Pod on its node, its Mountpoint Pods, mount health and events.
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+const csiDriverName = "s3.csi.aws.com"
+
+// storageProvisionerAnnotation is populated on PVCs by the PV controller with the name of their provisioner.
+const storageProvisionerAnnotation = "volume.kubernetes.io/storage-provisioner"
+
+// Metrics of the CSI Driver Node Pods used to diagnose mounts.
+const (
+	metricMountHealthy          = "s3_csi_node_mount_healthy"
+	metricCredentialsExpiration = "s3_csi_node_credentials_expiration_timestamp_seconds"
+)
+
+// Sources of volumes in a workload Pod.
+const (
+	SourcePVC       = "pvc"
+	SourceEphemeral = "ephemeral"
+	SourceInline    = "inline"
+)
+
+// Options configures the workload Pod to diagnose and where to find the components of the CSI Driver.
+type Options struct {
+	// Namespace and Name of the workload Pod.
+	Namespace string
+	Name      string
+	// DriverNamespace is the namespace of the CSI Driver Node Pods.
+	DriverNamespace string
+	// NodePodSelector is the label selector of the CSI Driver Node Pods.
+	NodePodSelector string
+	// MountpointNamespace is the namespace of the Mountpoint Pods spawned by `aws-s3-csi-controller`.
+	MountpointNamespace string
+	// MetricsPort is the port the CSI Driver Node Pods expose metrics on, mount health is not checked if zero.
+	MetricsPort int
+}
+
+// A Report is the consolidated diagnostics of the S3 volumes of a workload Pod.
+type Report struct {
+	Pod *corev1.Pod
+	// NodePod is the CSI Driver Node Pod on the node of the workload Pod, or nil if not found.
+	NodePod *corev1.Pod
+	Volumes []VolumeReport
+	// Events are the events of the workload Pod, sorted by their last occurrence.
+	Events []corev1.Event
+	// Problems are the problems found that are not specific to a volume.
+	Problems []string
+}
+
+// A VolumeReport is the diagnostics of a single S3 volume of a workload Pod.
+type VolumeReport struct {
+	// Name is the name of the volume in the workload Pod's spec.
+	Name   string
+	Source string
+	// PVC and PV are nil for inline volumes, and PV is nil if the PVC is not bound yet.
+	PVC        *corev1.PersistentVolumeClaim
+	PV         *corev1.PersistentVolume
+	VolumeID   string
+	Attributes map[string]string
+	// SecretRef is the Secret referenced by `nodePublishSecretRef` of the volume, if any.
+	SecretRef *corev1.SecretReference
+	// MountpointPod is the Mountpoint Pod spawned for the volume, or nil if there isn't any.
+	MountpointPod *corev1.Pod
+	// MountHealthy is whether the mount is healthy as reported by the CSI Driver Node Pod, or nil if unknown.
+	MountHealthy *bool
+	// CredentialsExpiration is when the short-lived credentials of the volume expire, or nil if unknown.
+	CredentialsExpiration *time.Time
+	Problems              []string
+}
+
+// Collect collects the report of the workload Pod in `opts` using `client`.
+// It only returns an error if the workload Pod cannot be retrieved, other failures are reported as problems.
+func Collect(ctx context.Context, client kubernetes.Interface, opts Options) (*Report, error) {
+	pod, err := client.CoreV1().Pods(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Pod %s/%s: %w", opts.Namespace, opts.Name, err)
+	}
+
+	report := &Report{Pod: pod}
+	report.Volumes = collectVolumes(ctx, client, pod)
+	if len(report.Volumes) == 0 {
+		report.Problems = append(report.Problems, "Pod has no volumes provided by "+csiDriverName)
+	}
+
+	if pod.Spec.NodeName == "" {
+		report.Problems = append(report.Problems, "Pod is not scheduled to a node yet")
+	} else {
+		report.NodePod = findNodePod(ctx, client, opts, pod.Spec.NodeName, report)
+	}
+
+	for i := range report.Volumes {
+		vol := &report.Volumes[i]
+		if vol.PV != nil {
+			vol.MountpointPod = findMountpointPod(ctx, client, opts, pod, vol)
+		}
+	}
+
+	if report.NodePod != nil && opts.MetricsPort != 0 && len(report.Volumes) > 0 {
+		collectMountHealth(ctx, client, opts, report)
+	}
+
+	report.Events = collectEvents(ctx, client, pod, report)
+	return report, nil
+}
+
+// collectVolumes returns reports of the volumes of `pod` provided by the CSI Driver.
+func collectVolumes(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) []VolumeReport {
+	var volumes []VolumeReport
+	for _, vol := range pod.Spec.Volumes {
+		if vol.CSI != nil {
+			if vol.CSI.Driver == csiDriverName {
+				volumes = append(volumes, VolumeReport{Name: vol.Name, Source: SourceInline, Attributes: vol.CSI.VolumeAttributes})
+			}
+			continue
+		}
+
+		var claimName, source string
+		switch {
+		case vol.PersistentVolumeClaim != nil:
+			claimName, source = vol.PersistentVolumeClaim.ClaimName, SourcePVC
+		case vol.Ephemeral != nil:
+			// Generic ephemeral volumes are backed by a PVC created for the Pod with a deterministic name.
+			claimName, source = pod.Name+"-"+vol.Name, SourceEphemeral
+		default:
+			continue
+		}
+
+		report := VolumeReport{Name: vol.Name, Source: source}
+		pvc, err := client.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, claimName, metav1.GetOptions{})
+		if err != nil {
+			// We can't know whether a missing PVC would be provided by the CSI Driver, so only report it for ephemeral volumes
+			// as their PVCs are created on demand.
+			if source == SourceEphemeral || !apierrors.IsNotFound(err) {
+				report.Problems = append(report.Problems, fmt.Sprintf("Failed to get PVC %s: %v", claimName, err))
+				volumes = append(volumes, report)
+			}
+			continue
+		}
+		report.PVC = pvc
+
+		if pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName == "" {
+			if pvc.Annotations[storageProvisionerAnnotation] == csiDriverName {
+				report.Problems = append(report.Problems, fmt.Sprintf("PVC %s is not bound to a PV yet (%s)", pvc.Name, pvc.Status.Phase))
+				volumes = append(volumes, report)
+			}
+			continue
+		}
+
+		pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("Failed to get PV %s: %v", pvc.Spec.VolumeName, err))
+			volumes = append(volumes, report)
+			continue
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csiDriverName {
+			continue
+		}
+
+		report.PV = pv
+		report.VolumeID = pv.Spec.CSI.VolumeHandle
+		report.Attributes = pv.Spec.CSI.VolumeAttributes
+		report.SecretRef = pv.Spec.CSI.NodePublishSecretRef
+		if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Name != pvc.Name || pv.Spec.ClaimRef.Namespace != pvc.Namespace {
+			report.Problems = append(report.Problems, fmt.Sprintf("PV %s is not bound to PVC %s/%s", pv.Name, pvc.Namespace, pvc.Name))
+		}
+		volumes = append(volumes, report)
+	}
+	return volumes
+}
+
+// findNodePod returns the CSI Driver Node Pod running on `node`, and reports problems with it to `report`.
+func findNodePod(ctx context.Context, client kubernetes.Interface, opts Options, node string, report *Report) *corev1.Pod {
+	pods, err := client.CoreV1().Pods(opts.DriverNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: opts.NodePodSelector,
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+	})
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("Failed to list CSI Driver Node Pods: %v", err))
+		return nil
+	}
+
+	for i := range pods.Items {
+		// Field selectors might not be supported by all clients, so double-check the node.
+		if pods.Items[i].Spec.NodeName != node {
+			continue
+		}
+		nodePod := &pods.Items[i]
+		if !isPodReady(nodePod) {
+			report.Problems = append(report.Problems, fmt.Sprintf("CSI Driver Node Pod %s/%s is not ready", nodePod.Namespace, nodePod.Name))
+		}
+		return nodePod
+	}
+
+	report.Problems = append(report.Problems, fmt.Sprintf("No CSI Driver Node Pod found on node %s with %q in namespace %s",
+		node, opts.NodePodSelector, opts.DriverNamespace))
+	return nil
+}
+
+// findMountpointPod returns the Mountpoint Pod spawned for `vol` of `pod`, and reports problems with it to `vol`.
+func findMountpointPod(ctx context.Context, client kubernetes.Interface, opts Options, pod *corev1.Pod, vol *VolumeReport) *corev1.Pod {
+	name := mppod.MountpointPodNameFor(string(pod.UID), vol.PV.Name)
+	mpPod, err := client.CoreV1().Pods(opts.MountpointNamespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		// Mountpoint Pods are only spawned if `aws-s3-csi-controller` is deployed.
+		if !apierrors.IsNotFound(err) {
+			vol.Problems = append(vol.Problems, fmt.Sprintf("Failed to get Mountpoint Pod %s/%s: %v", opts.MountpointNamespace, name, err))
+		}
+		return nil
+	}
+
+	switch mpPod.Status.Phase {
+	case corev1.PodFailed:
+		vol.Problems = append(vol.Problems, fmt.Sprintf("Mountpoint Pod %s/%s failed: %s %s", mpPod.Namespace, mpPod.Name, mpPod.Status.Reason, mpPod.Status.Message))
+	case corev1.PodPending:
+		vol.Problems = append(vol.Problems, fmt.Sprintf("Mountpoint Pod %s/%s is pending", mpPod.Namespace, mpPod.Name))
+	}
+	if condition := podCondition(mpPod, mppod.ConditionUnmountPending); condition != nil && condition.Status == corev1.ConditionTrue {
+		vol.Problems = append(vol.Problems, fmt.Sprintf("Mountpoint Pod %s/%s is pending unmount since %s", mpPod.Namespace, mpPod.Name, condition.LastTransitionTime))
+	}
+	return mpPod
+}
+
+// collectMountHealth populates mount health and credentials expiration of the volumes in `report`
+// from the metrics of the CSI Driver Node Pod.
+func collectMountHealth(ctx context.Context, client kubernetes.Interface, opts Options, report *Report) {
+	nodePod := report.NodePod
+	wrapper := client.CoreV1().Pods(nodePod.Namespace).ProxyGet("http", nodePod.Name, strconv.Itoa(opts.MetricsPort), "/metrics", nil)
+	if wrapper == nil {
+		return
+	}
+	raw, err := wrapper.DoRaw(ctx)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("Failed to get metrics of CSI Driver Node Pod %s/%s, is `node.metrics.enabled` set? %v",
+			nodePod.Namespace, nodePod.Name, err))
+		return
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(raw))
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("Failed to parse metrics of CSI Driver Node Pod %s/%s: %v", nodePod.Namespace, nodePod.Name, err))
+		return
+	}
+
+	// Metrics of volumes are not labelled with the Pods using them, so they cover all mounts of the volume in the node.
+	for i := range report.Volumes {
+		vol := &report.Volumes[i]
+		if vol.VolumeID == "" {
+			continue
+		}
+
+		if value, ok := gaugeValue(families[metricMountHealthy], vol.VolumeID); ok {
+			healthy := value == 1
+			vol.MountHealthy = &healthy
+			if !healthy {
+				vol.Problems = append(vol.Problems, "A mount of the volume in the node is broken, the CSI Driver is trying to re-mount it")
+			}
+		}
+		if value, ok := gaugeValue(families[metricCredentialsExpiration], vol.VolumeID); ok {
+			expiration := time.Unix(int64(value), 0)
+			vol.CredentialsExpiration = &expiration
+			if time.Now().After(expiration) {
+				vol.Problems = append(vol.Problems, fmt.Sprintf("Credentials expired at %s and have not been refreshed", expiration.Format(time.RFC3339)))
+			}
+		}
+	}
+}
+
+// collectEvents returns the events of `pod`, and reports warning events as problems to `report`.
+func collectEvents(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, report *Report) []corev1.Event {
+	events, err := client.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pod.UID)).String(),
+	})
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("Failed to list events of the Pod: %v", err))
+		return nil
+	}
+
+	var podEvents []corev1.Event
+	for _, event := range events.Items {
+		// Field selectors might not be supported by all clients, so double-check the involved object.
+		if event.InvolvedObject.UID == pod.UID {
+			podEvents = append(podEvents, event)
+		}
+	}
+	sortEvents(podEvents)
+	return podEvents
+}
+
+// gaugeValue returns the value of the gauge in `family` with given `volumeID` label.
+func gaugeValue(family *dto.MetricFamily, volumeID string) (float64, bool) {
+	if family == nil {
+		return 0, false
+	}
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["volume_id"] != volumeID {
+			continue
+		}
+		if gauge := metric.GetGauge(); gauge != nil {
+			return gauge.GetValue(), true
+		}
+		// Metrics without type information are parsed as untyped.
+		return metric.GetUntyped().GetValue(), true
+	}
+	return 0, false
+}
+
+// AuthenticationSource returns a human-readable authentication source of `vol`.
+func (vol *VolumeReport) AuthenticationSource() string {
+	if vol.SecretRef != nil {
+		return fmt.Sprintf("secret %s/%s", vol.SecretRef.Namespace, vol.SecretRef.Name)
+	}
+	if source := vol.Attributes[volumecontext.AuthenticationSource]; source != "" {
+		return source
+	}
+	return "driver"
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	condition := podCondition(pod, corev1.PodReady)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package diagnose_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/kubectl-s3csi/diagnose"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+var opts = diagnose.Options{
+	Namespace:           "default",
+	Name:                "workload",
+	DriverNamespace:     "kube-system",
+	NodePodSelector:     "app=s3-csi-node",
+	MountpointNamespace: "mount-s3",
+	MetricsPort:         9810,
+}
+
+func TestCollectingReport(t *testing.T) {
+	workloadPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "default", UID: "workload-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Volumes: []corev1.Volume{
+				{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "s3-claim"}}},
+				{Name: "ebs", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "ebs-claim"}}},
+				{Name: "inline", VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+					Driver:           "s3.csi.aws.com",
+					VolumeAttributes: map[string]string{"bucketName": "inline-bucket", "authenticationSource": "pod"},
+				}}},
+				{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	objects := []runtime.Object{
+		workloadPod,
+		boundPVC("s3-claim", "s3-pv"),
+		csiPV("s3-pv", "s3.csi.aws.com", "s3-volume-id", "s3-claim"),
+		boundPVC("ebs-claim", "ebs-pv"),
+		csiPV("ebs-pv", "ebs.csi.aws.com", "ebs-volume-id", "ebs-claim"),
+		nodePod("s3-csi-node-other", "node-2", true),
+		nodePod("s3-csi-node-abcde", "node-1", true),
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "workload.1", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "workload", UID: "workload-uid"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "MountpointUnhealthy",
+			Message:        "Mountpoint terminated",
+			LastTimestamp:  metav1.Now(),
+		},
+	}
+
+	t.Run("healthy mounts", func(t *testing.T) {
+		client := fake.NewSimpleClientset(objects...)
+		serveNodeMetrics(client, `
+s3_csi_node_mount_healthy{volume_id="s3-volume-id"} 1
+s3_csi_node_mount_healthy{volume_id="other-volume-id"} 0
+`)
+
+		report, err := diagnose.Collect(context.Background(), client, opts)
+		assert.NoError(t, err)
+		assert.Equals(t, "s3-csi-node-abcde", report.NodePod.Name)
+		assert.Equals(t, 0, len(report.Problems))
+		assert.Equals(t, 1, len(report.Events))
+
+		// The EBS and ConfigMap volumes should be ignored
+		assert.Equals(t, 2, len(report.Volumes))
+		assert.Equals(t, "data", report.Volumes[0].Name)
+		assert.Equals(t, "s3-volume-id", report.Volumes[0].VolumeID)
+		assert.Equals(t, "driver", report.Volumes[0].AuthenticationSource())
+		assert.Equals(t, true, *report.Volumes[0].MountHealthy)
+		assert.Equals(t, 0, len(report.Volumes[0].Problems))
+		assert.Equals(t, "inline", report.Volumes[1].Name)
+		assert.Equals(t, "pod", report.Volumes[1].AuthenticationSource())
+
+		var out bytes.Buffer
+		report.Print(&out)
+		for _, expected := range []string{"default/workload (workload-uid)", "kube-system/s3-csi-node-abcde", `Volume "data" (pvc)`,
+			"test-bucket", `Volume "inline" (inline)`, "MountpointUnhealthy"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("Expected report to contain %q, got:\n%s", expected, out.String())
+			}
+		}
+	})
+
+	t.Run("broken mount and expired credentials", func(t *testing.T) {
+		client := fake.NewSimpleClientset(objects...)
+		serveNodeMetrics(client, `
+s3_csi_node_mount_healthy{volume_id="s3-volume-id"} 0
+s3_csi_node_credentials_expiration_timestamp_seconds{volume_id="s3-volume-id"} 1.7e+09
+`)
+
+		report, err := diagnose.Collect(context.Background(), client, opts)
+		assert.NoError(t, err)
+		assert.Equals(t, true, report.HasProblems())
+		assert.Equals(t, false, *report.Volumes[0].MountHealthy)
+		assert.Equals(t, 2, len(report.Volumes[0].Problems))
+		assert.Equals(t, time.Unix(1.7e+09, 0), *report.Volumes[0].CredentialsExpiration)
+	})
+
+	t.Run("failed Mountpoint Pod", func(t *testing.T) {
+		mpPod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        mppod.MountpointPodNameFor("workload-uid", "s3-pv"),
+				Namespace:   "mount-s3",
+				Annotations: map[string]string{mppod.AnnotationRestartCount: "3"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"},
+		}
+		client := fake.NewSimpleClientset(append(objects, mpPod)...)
+
+		report, err := diagnose.Collect(context.Background(), client, opts)
+		assert.NoError(t, err)
+		assert.Equals(t, mpPod.Name, report.Volumes[0].MountpointPod.Name)
+		assert.Equals(t, 1, len(report.Volumes[0].Problems))
+
+		var out bytes.Buffer
+		report.Print(&out)
+		if !strings.Contains(out.String(), "restarts: 3") {
+			t.Errorf("Expected report to contain restarts, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("missing node Pod", func(t *testing.T) {
+		client := fake.NewSimpleClientset(workloadPod, boundPVC("s3-claim", "s3-pv"), csiPV("s3-pv", "s3.csi.aws.com", "s3-volume-id", "s3-claim"))
+
+		report, err := diagnose.Collect(context.Background(), client, opts)
+		assert.NoError(t, err)
+		assert.Equals(t, (*corev1.Pod)(nil), report.NodePod)
+		assert.Equals(t, 1, len(report.Problems))
+	})
+
+	t.Run("missing workload Pod", func(t *testing.T) {
+		_, err := diagnose.Collect(context.Background(), fake.NewSimpleClientset(), opts)
+		if err == nil {
+			t.Fatal("Expected an error for missing workload Pod")
+		}
+	})
+}
+
+func boundPVC(name, volumeName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+}
+
+func csiPV(name, driver, volumeHandle, claimName string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: claimName},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+				Driver:           driver,
+				VolumeHandle:     volumeHandle,
+				VolumeAttributes: map[string]string{"bucketName": "test-bucket"},
+			}},
+		},
+	}
+}
+
+func nodePod(name, node string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: map[string]string{"app": "s3-csi-node"}},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// serveNodeMetrics makes `client` to return `metrics` for proxy requests to the metrics of the CSI Driver Node Pods.
+func serveNodeMetrics(client *fake.Clientset, metrics string) {
+	client.AddProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+		return true, staticResponse(metrics), nil
+	})
+}
+
+type staticResponse string
+
+func (r staticResponse) DoRaw(context.Context) ([]byte, error) {
+	return []byte(r), nil
+}
+
+func (r staticResponse) Stream(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(r))), nil
+}
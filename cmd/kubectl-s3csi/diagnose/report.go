@@ -0,0 +1,134 @@
+package diagnose
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+)
+
+// Print prints a human-readable form of the report to `w`.
+func (r *Report) Print(w io.Writer) {
+	p := &printer{w: w}
+
+	p.field(0, "Pod", fmt.Sprintf("%s/%s (%s)", r.Pod.Namespace, r.Pod.Name, r.Pod.UID))
+	p.field(0, "Phase", string(r.Pod.Status.Phase))
+	p.field(0, "Node", valueOr(r.Pod.Spec.NodeName, "<not scheduled>"))
+	if r.NodePod != nil {
+		p.field(0, "CSI Driver Node Pod", fmt.Sprintf("%s/%s (%s, ready: %t)", r.NodePod.Namespace, r.NodePod.Name, r.NodePod.Status.Phase, isPodReady(r.NodePod)))
+	}
+	p.problems(0, r.Problems)
+
+	for _, vol := range r.Volumes {
+		p.line(0, "")
+		p.line(0, fmt.Sprintf("Volume %q (%s)", vol.Name, vol.Source))
+		if vol.PVC != nil {
+			p.field(1, "PVC", fmt.Sprintf("%s (%s)", vol.PVC.Name, vol.PVC.Status.Phase))
+		}
+		if vol.PV != nil {
+			p.field(1, "PV", vol.PV.Name)
+			p.field(1, "Volume ID", vol.VolumeID)
+		}
+		if vol.Attributes != nil {
+			p.field(1, "Bucket", valueOr(vol.Attributes[volumecontext.BucketName], "<not set>"))
+			if prefix := vol.Attributes[volumecontext.Prefix]; prefix != "" {
+				p.field(1, "Prefix", prefix)
+			}
+			p.field(1, "Authentication", vol.AuthenticationSource())
+			if roleARN := vol.Attributes[volumecontext.STSRoleARN]; roleARN != "" {
+				p.field(1, "Assumed role", roleARN)
+			}
+		}
+		if vol.MountpointPod != nil {
+			mpPod := vol.MountpointPod
+			attached := "unknown"
+			if condition := podCondition(mpPod, mppod.ConditionWorkloadAttached); condition != nil {
+				attached = string(condition.Status)
+			}
+			p.field(1, "Mountpoint Pod", fmt.Sprintf("%s/%s (%s, attached: %s, restarts: %s)", mpPod.Namespace, mpPod.Name,
+				mpPod.Status.Phase, attached, valueOr(mpPod.Annotations[mppod.AnnotationRestartCount], "0")))
+		}
+		if vol.MountHealthy != nil {
+			p.field(1, "Mount healthy", fmt.Sprintf("%t", *vol.MountHealthy))
+		}
+		if vol.CredentialsExpiration != nil {
+			p.field(1, "Credentials expire", vol.CredentialsExpiration.Format(time.RFC3339))
+		}
+		p.problems(1, vol.Problems)
+	}
+
+	if len(r.Events) > 0 {
+		p.line(0, "")
+		p.line(0, "Events:")
+		for _, event := range r.Events {
+			p.line(1, fmt.Sprintf("%s\t%s\t%s (x%d, last seen %s)", event.Type, event.Reason, event.Message,
+				max(event.Count, 1), eventTime(event).Format(time.RFC3339)))
+		}
+	}
+}
+
+// HasProblems returns whether any problems are found in the report.
+func (r *Report) HasProblems() bool {
+	if len(r.Problems) > 0 {
+		return true
+	}
+	for _, vol := range r.Volumes {
+		if len(vol.Problems) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type printer struct {
+	w io.Writer
+}
+
+func (p *printer) line(indent int, text string) {
+	fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("  ", indent), text)
+}
+
+func (p *printer) field(indent int, name, value string) {
+	p.line(indent, fmt.Sprintf("%-22s%s", name+":", value))
+}
+
+func (p *printer) problems(indent int, problems []string) {
+	if len(problems) == 0 {
+		return
+	}
+	p.line(indent, "Problems:")
+	for _, problem := range problems {
+		p.line(indent+1, "- "+problem)
+	}
+}
+
+// sortEvents sorts `events` by their last occurrence.
+func sortEvents(events []corev1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+}
+
+// eventTime returns the last occurrence of `event`.
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
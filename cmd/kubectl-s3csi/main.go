@@ -0,0 +1,102 @@
+// `kubectl-s3csi` is a kubectl plugin to troubleshoot volumes provided by the CSI Driver.
+//
+// Once installed to `PATH`, it can be used as `kubectl s3csi diagnose [flags] POD`, which prints a consolidated report
+// of the S3 volumes of the given workload Pod, including their PVs, the CSI Driver Node Pod on its node,
+// Mountpoint Pods, mount health and events.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/awslabs/aws-s3-csi-driver/cmd/kubectl-s3csi/diagnose"
+)
+
+const usage = `Usage: kubectl s3csi diagnose [flags] POD
+
+Prints a consolidated report of the S3 volumes of POD. Exits with 2 if any problems are found.
+
+Flags:
+`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "diagnose" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet("diagnose", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	var (
+		kubeconfig          = flags.String("kubeconfig", "", "Path to the kubeconfig file, the default loading rules of kubectl are used if empty.")
+		kubeContext         = flags.String("context", "", "The kubeconfig context to use.")
+		namespace           = flags.String("namespace", "", "Namespace of the Pod, the namespace of the kubeconfig context is used if empty.")
+		driverNamespace     = flags.String("driver-namespace", "kube-system", "Namespace of the CSI Driver Node Pods.")
+		nodePodSelector     = flags.String("node-pod-selector", "app=s3-csi-node", "Label selector of the CSI Driver Node Pods.")
+		mountpointNamespace = flags.String("mountpoint-namespace", "mount-s3", "Namespace of the Mountpoint Pods.")
+		metricsPort         = flags.Int("metrics-port", 9810, "Port of the metrics of the CSI Driver Node Pods to check mount health, 0 to disable.")
+		timeout             = flags.Duration("timeout", 30*time.Second, "Timeout to collect the report.")
+	)
+	flags.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	flags.Parse(os.Args[2:])
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: *kubeContext})
+
+	if *namespace == "" {
+		ns, _, err := clientConfig.Namespace()
+		if err != nil {
+			fatalf("Failed to get namespace from kubeconfig: %v", err)
+		}
+		*namespace = ns
+	}
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		fatalf("Failed to load kubeconfig: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := diagnose.Collect(ctx, client, diagnose.Options{
+		Namespace:           *namespace,
+		Name:                flags.Arg(0),
+		DriverNamespace:     *driverNamespace,
+		NodePodSelector:     *nodePodSelector,
+		MountpointNamespace: *mountpointNamespace,
+		MetricsPort:         *metricsPort,
+	})
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	report.Print(os.Stdout)
+	if report.HasProblems() {
+		os.Exit(2)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
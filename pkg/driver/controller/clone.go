@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// Parameters the external-provisioner passes to `CreateVolume` with `--extra-create-metadata`.
+const (
+	paramPVCName      = "csi.storage.k8s.io/pvc/name"
+	paramPVCNamespace = "csi.storage.k8s.io/pvc/namespace"
+)
+
+// DefaultCloneConcurrency is the default number of objects copied in parallel while cloning a volume.
+const DefaultCloneConcurrency = 16
+
+// A Location is the bucket, or the prefix in a bucket, backing a volume.
+type Location struct {
+	Bucket string
+	// Prefix is empty for volumes backed by dedicated buckets, and it ends with "/" otherwise.
+	Prefix string
+}
+
+// String returns the location in "<bucket>/<prefix>" format, which can be parsed with [ParseLocation].
+func (l Location) String() string {
+	return l.Bucket + "/" + l.Prefix
+}
+
+// ParseLocation parses a location in "<bucket>/<prefix>" format, where the prefix is optional.
+func ParseLocation(s string) (Location, error) {
+	bucket, prefix, _ := strings.Cut(s, "/")
+	if bucket == "" {
+		return Location{}, fmt.Errorf("invalid location %q, expected \"<bucket>/<prefix>\"", s)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return Location{Bucket: bucket, Prefix: prefix}, nil
+}
+
+// A CloneRequest is a request to clone the objects of a volume into a new volume.
+type CloneRequest struct {
+	// VolumeName is the name of the new volume, i.e. the name of its PersistentVolume.
+	VolumeName string
+	Source     Location
+	Target     Location
+	// PVCNamespace and PVCName are the PersistentVolumeClaim the new volume is provisioned for,
+	// they're empty if the external-provisioner doesn't run with `--extra-create-metadata`.
+	PVCNamespace string
+	PVCName      string
+}
+
+// A CloneStatus is the progress of cloning a volume.
+type CloneStatus struct {
+	Done bool
+	// Failure is the reason cloning failed if it's not empty, cloning is restarted on the next call of [Cloner.Clone].
+	Failure       string
+	CopiedObjects int64
+	TotalObjects  int64
+}
+
+// A Cloner clones volumes asynchronously, as copying the objects of a large volume takes much longer than
+// the external-provisioner waits for a `CreateVolume` call.
+type Cloner interface {
+	// Clone starts cloning the volume in `req` if it's not started yet, and returns its progress.
+	// It's called on every retry of `CreateVolume` until cloning is done.
+	Clone(ctx context.Context, req CloneRequest) (CloneStatus, error)
+}
+
+// SetCloner enables cloning volumes with `cloner`. Volumes can't be cloned if it's not set.
+func (cs *S3ControllerServer) SetCloner(cloner Cloner) {
+	cs.cloner = cloner
+}
+
+// cloneSource returns the location of the source volume of a volume to clone.
+func (cs *S3ControllerServer) cloneSource(ctx context.Context, source *csi.VolumeContentSource_VolumeSource) (volume, error) {
+	if cs.cloner == nil {
+		return volume{}, status.Error(codes.InvalidArgument, "Cloning volumes is not enabled, see --clone-image flag of the controller")
+	}
+	sourceVolumeID := source.GetVolumeId()
+	if len(sourceVolumeID) == 0 {
+		return volume{}, status.Error(codes.InvalidArgument, "Source volume ID not provided")
+	}
+	if strings.HasPrefix(sourceVolumeID, restoredVolumeIDPrefix) {
+		return volume{}, status.Errorf(codes.InvalidArgument, "Volume %s is restored from a snapshot, clone its source volume instead", sourceVolumeID)
+	}
+	if err := cs.checkVolumeExists(ctx, sourceVolumeID); err != nil {
+		return volume{}, err
+	}
+	return parseVolumeID(sourceVolumeID), nil
+}
+
+// cloneVolume clones `source` into `target` provisioned for the volume in `req`. It returns nil once cloning is done,
+// or an `Aborted` error while cloning is in progress, which the external-provisioner retries until it's done.
+func (cs *S3ControllerServer) cloneVolume(ctx context.Context, req *csi.CreateVolumeRequest, source, target volume) error {
+	params := req.GetParameters()
+	st, err := cs.cloner.Clone(ctx, CloneRequest{
+		VolumeName:   req.GetName(),
+		Source:       Location{Bucket: source.bucket, Prefix: source.prefix},
+		Target:       Location{Bucket: target.bucket, Prefix: target.prefix},
+		PVCNamespace: params[paramPVCNamespace],
+		PVCName:      params[paramPVCName],
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not clone volume %s into volume %s: %v", source.id(), req.GetName(), err)
+	}
+
+	switch {
+	case st.Failure != "":
+		return status.Errorf(codes.Internal, "Cloning volume %s into volume %s failed, retrying: %s", source.id(), req.GetName(), st.Failure)
+	case !st.Done:
+		return status.Errorf(codes.Aborted, "Cloning volume %s into volume %s is in progress: copied %d of %d objects",
+			source.id(), req.GetName(), st.CopiedObjects, st.TotalObjects)
+	}
+
+	klog.V(4).Infof("CreateVolume: cloned volume %s into volume %s", source.id(), req.GetName())
+	return nil
+}
+
+// CopyPrefix copies all objects in `source` into `target` server-side with `concurrency` parallel copies, keeping
+// their keys relative to the prefixes. Copying the same objects again overwrites them, so it can be retried after
+// a failure. `progress` is called with the number of copied objects after each copy.
+func CopyPrefix(ctx context.Context, client S3Client, source, target Location, concurrency int, progress func(copied, total int)) error {
+	objects, err := client.ListObjects(ctx, source.Bucket, source.Prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list objects in %s: %w", source, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan string)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		copied   int
+		firstErr error
+	)
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				targetKey := target.Prefix + strings.TrimPrefix(key, source.Prefix)
+				err := client.CopyObject(ctx, source.Bucket, key, target.Bucket, targetKey)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to copy %s/%s to %s/%s: %w", source.Bucket, key, target.Bucket, targetKey, err)
+					cancel()
+				} else if err == nil {
+					copied++
+					progress(copied, len(objects))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		select {
+		case keys <- obj.Key:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(keys)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,142 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCreateVolumeFromVolume(t *testing.T) {
+	cloneReq := func() *csi.CreateVolumeRequest {
+		return &csi.CreateVolumeRequest{
+			Name:               "pvc-9999",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+			VolumeContentSource: &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Volume{
+				Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "shared-bucket/pvc-1234"},
+			}},
+			Parameters: map[string]string{
+				controller.ParamBucketName:         "shared-bucket",
+				"csi.storage.k8s.io/pvc/namespace": "default",
+				"csi.storage.k8s.io/pvc/name":      "clone",
+			},
+		}
+	}
+
+	t.Run("Waits until cloning is done", func(t *testing.T) {
+		cloner := &fakeCloner{status: controller.CloneStatus{CopiedObjects: 1, TotalObjects: 2}}
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+		server.SetCloner(cloner)
+
+		_, err := server.CreateVolume(context.Background(), cloneReq())
+		assert.Equals(t, codes.Aborted, status.Code(err))
+		assert.Equals(t, controller.CloneRequest{
+			VolumeName:   "pvc-9999",
+			Source:       controller.Location{Bucket: "shared-bucket", Prefix: "pvc-1234/"},
+			Target:       controller.Location{Bucket: "shared-bucket", Prefix: "pvc-9999/"},
+			PVCNamespace: "default",
+			PVCName:      "clone",
+		}, cloner.req)
+
+		cloner.status = controller.CloneStatus{Done: true, CopiedObjects: 2, TotalObjects: 2}
+		resp, err := server.CreateVolume(context.Background(), cloneReq())
+		assert.NoError(t, err)
+		assert.Equals(t, "shared-bucket/pvc-9999", resp.GetVolume().GetVolumeId())
+		assert.Equals(t, "shared-bucket/pvc-1234", resp.GetVolume().GetContentSource().GetVolume().GetVolumeId())
+	})
+
+	t.Run("Fails if cloning failed", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+		server.SetCloner(&fakeCloner{status: controller.CloneStatus{Failure: "clone Job failed"}})
+
+		_, err := server.CreateVolume(context.Background(), cloneReq())
+		assert.Equals(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("Fails if the source volume does not exist", func(t *testing.T) {
+		cloner := &fakeCloner{}
+		server := controller.NewS3ControllerServer(&fakeS3Client{missingBuckets: []string{"shared-bucket"}})
+		server.SetCloner(cloner)
+
+		_, err := server.CreateVolume(context.Background(), cloneReq())
+		assert.Equals(t, codes.NotFound, status.Code(err))
+		assert.Equals(t, controller.CloneRequest{}, cloner.req)
+	})
+
+	t.Run("Fails if cloning is not enabled", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateVolume(context.Background(), cloneReq())
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestCopyPrefix(t *testing.T) {
+	t.Run("Copies objects relative to prefixes", func(t *testing.T) {
+		client := &fakeS3Client{objects: map[string][]byte{
+			"shared-bucket/pvc-1234/a.txt":     []byte("a"),
+			"shared-bucket/pvc-1234/dir/b.txt": []byte("b"),
+			"shared-bucket/pvc-5678/c.txt":     []byte("c"),
+		}}
+
+		var copied, total int
+		err := controller.CopyPrefix(context.Background(), client,
+			controller.Location{Bucket: "shared-bucket", Prefix: "pvc-1234/"}, controller.Location{Bucket: "new-bucket"}, 4,
+			func(c, t int) { copied, total = c, t })
+		assert.NoError(t, err)
+		assert.Equals(t, 2, copied)
+		assert.Equals(t, 2, total)
+		assert.Equals(t, []byte("a"), client.objects["new-bucket/a.txt"])
+		assert.Equals(t, []byte("b"), client.objects["new-bucket/dir/b.txt"])
+		assert.Equals(t, 5, len(client.objects))
+	})
+
+	t.Run("Fails if listing fails", func(t *testing.T) {
+		client := &fakeS3Client{err: errors.New("access denied")}
+
+		err := controller.CopyPrefix(context.Background(), client,
+			controller.Location{Bucket: "shared-bucket", Prefix: "pvc-1234/"}, controller.Location{Bucket: "new-bucket"}, 4,
+			func(int, int) {})
+		if err == nil {
+			t.Fatal("Expected CopyPrefix to fail")
+		}
+	})
+}
+
+func TestParseLocation(t *testing.T) {
+	for input, want := range map[string]controller.Location{
+		"bucket":           {Bucket: "bucket"},
+		"bucket/":          {Bucket: "bucket"},
+		"bucket/prefix":    {Bucket: "bucket", Prefix: "prefix/"},
+		"bucket/a/prefix/": {Bucket: "bucket", Prefix: "a/prefix/"},
+	} {
+		got, err := controller.ParseLocation(input)
+		assert.NoError(t, err)
+		assert.Equals(t, want, got)
+
+		roundTrip, err := controller.ParseLocation(got.String())
+		assert.NoError(t, err)
+		assert.Equals(t, want, roundTrip)
+	}
+
+	_, err := controller.ParseLocation("/prefix")
+	if err == nil {
+		t.Fatal("Expected ParseLocation to fail without bucket")
+	}
+}
+
+type fakeCloner struct {
+	status controller.CloneStatus
+	req    controller.CloneRequest
+}
+
+func (c *fakeCloner) Clone(ctx context.Context, req controller.CloneRequest) (controller.CloneStatus, error) {
+	c.req = req
+	return c.status, nil
+}
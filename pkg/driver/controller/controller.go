@@ -0,0 +1,414 @@
+// Package controller implements CSI Controller service of the driver to support dynamic provisioning.
+//
+// Each dynamically provisioned volume is either backed by a dedicated S3 bucket created for the volume,
+// or by a prefix in a shared S3 bucket if `bucketName` parameter is specified in the StorageClass.
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
+)
+
+// StorageClass parameters supported in `CreateVolume`.
+const (
+	// ParamBucketName is the name of a shared bucket to provision volumes as prefixes in.
+	// If not specified, a new bucket will be created for each volume.
+	ParamBucketName = "bucketName"
+	// ParamBucketNamePrefix is prepended to the names of the buckets created for each volume.
+	ParamBucketNamePrefix = "bucketNamePrefix"
+)
+
+// passthroughParams are StorageClass parameters that are passed as-is to the node via volume context.
+var passthroughParams = []string{
+	volumecontext.AuthenticationSource,
+	volumecontext.AWSProfile,
+	volumecontext.STSRegion,
+	volumecontext.STSEndpoint,
+	volumecontext.STSRoleARN,
+	volumecontext.STSExternalID,
+	volumecontext.ScopedSessionPolicy,
+	volumecontext.VaultRole,
+	volumecontext.VaultMount,
+	volumecontext.EndpointURL,
+	volumecontext.ForcePathStyle,
+	volumecontext.UseFIPSEndpoint,
+	volumecontext.UseDualStackEndpoint,
+	volumecontext.CABundleSecretRef,
+	volumecontext.HTTPProxy,
+	volumecontext.HTTPSProxy,
+	volumecontext.NoProxy,
+	volumecontext.CacheType,
+	volumecontext.CacheDirSizeLimit,
+	volumecontext.CacheStorageClass,
+	volumecontext.CachePVC,
+	volumecontext.CacheExpressBucket,
+	volumecontext.MetadataTTL,
+	volumecontext.RespectPodFSGroup,
+	volumecontext.MountRetries,
+	volumecontext.MountRetryBackoff,
+	volumecontext.MountTimeout,
+	volumecontext.UnmountGracePeriod,
+	volumecontext.MountpointEnv,
+	volumecontext.Mounter,
+	volumecontext.MountpointImage,
+	volumecontext.MountpointVersion,
+	volumecontext.MountpointImagePullSecrets,
+	volumecontext.MountpointPodLabels,
+	volumecontext.MountpointPodAnnotations,
+	volumecontext.MountpointPodTolerations,
+	volumecontext.MountpointPodNodeSelector,
+	volumecontext.MountpointPodNodeAffinity,
+	volumecontext.MountpointPodSecurityContext,
+}
+
+var (
+	controllerCaps = []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+	}
+)
+
+var (
+	volumeCaps = []csi.VolumeCapability_AccessMode{
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
+	}
+)
+
+// S3ControllerServer is the implementation of the csi.ControllerServer interface
+type S3ControllerServer struct {
+	client S3Client
+	cloner Cloner
+
+	// ModifyVolume is whether to advertise `MODIFY_VOLUME` capability, so the external-resizer calls
+	// `ControllerModifyVolume` on changes of the VolumeAttributesClass of volumes. It's enabled by default.
+	ModifyVolume bool
+}
+
+func NewS3ControllerServer(client S3Client) *S3ControllerServer {
+	return &S3ControllerServer{client: client, ModifyVolume: true}
+}
+
+func (cs *S3ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	klog.V(4).Infof("CreateVolume: called with args %#v", req)
+
+	name := req.GetName()
+	if len(name) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume name not provided")
+	}
+
+	volCaps := req.GetVolumeCapabilities()
+	if len(volCaps) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not provided")
+	}
+
+	if !isValidVolumeCapabilities(volCaps) {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not supported")
+	}
+
+	params := req.GetParameters()
+	volumeCtx := map[string]string{}
+	for _, key := range passthroughParams {
+		if value, ok := params[key]; ok {
+			volumeCtx[key] = value
+		}
+	}
+	if err := validateMutableParameters(req.GetMutableParameters()); err != nil {
+		return nil, err
+	}
+	// Mutable parameters of the VolumeAttributesClass the volume is created with take precedence over the StorageClass.
+	maps.Copy(volumeCtx, req.GetMutableParameters())
+
+	var cloneSource *volume
+	if source := req.GetVolumeContentSource(); source != nil {
+		if snapshot := source.GetSnapshot(); snapshot != nil {
+			return cs.createVolumeFromSnapshot(ctx, req, snapshot, volumeCtx)
+		}
+		vol, err := cs.cloneSource(ctx, source.GetVolume())
+		if err != nil {
+			return nil, err
+		}
+		cloneSource = &vol
+	}
+
+	var vol volume
+	if bucket := params[ParamBucketName]; bucket != "" {
+		if zoneID, ok := topology.DirectoryBucketZoneID(bucket); ok && !topology.Allows(req.GetAccessibilityRequirements(), zoneID) {
+			return nil, status.Errorf(codes.ResourceExhausted, "Directory bucket %q in Availability Zone %s is not accessible from allowed topologies", bucket, zoneID)
+		}
+		vol = volume{bucket: bucket, prefix: name + "/"}
+		klog.V(4).Infof("CreateVolume: provisioning volume %s as prefix %q in shared bucket %s", name, vol.prefix, vol.bucket)
+	} else {
+		vol = volume{bucket: params[ParamBucketNamePrefix] + name}
+		klog.V(4).Infof("CreateVolume: provisioning volume %s as a new bucket %s", name, vol.bucket)
+		if err := cs.client.CreateBucket(ctx, vol.bucket); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not create bucket %q: %v", vol.bucket, err)
+		}
+	}
+
+	volumeCtx[volumecontext.BucketName] = vol.bucket
+	if vol.prefix != "" {
+		volumeCtx[volumecontext.Prefix] = vol.prefix
+	}
+
+	if cloneSource != nil {
+		if err := cs.cloneVolume(ctx, req, *cloneSource, vol); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId: vol.id(),
+			// S3 has no notion of capacity, we just echo back requested capacity.
+			CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+			VolumeContext: volumeCtx,
+			ContentSource: req.GetVolumeContentSource(),
+		},
+	}
+	// Volumes backed by directory buckets are only accessible from the zone of their buckets to avoid cross-zone mounts.
+	if zoneID, ok := topology.DirectoryBucketZoneID(vol.bucket); ok {
+		resp.Volume.AccessibleTopology = []*csi.Topology{topology.ForZone(zoneID)}
+	}
+	return resp, nil
+}
+
+func (cs *S3ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	klog.V(4).Infof("DeleteVolume: called with args: %#v", req)
+
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	if strings.HasPrefix(volumeID, restoredVolumeIDPrefix) {
+		// Volumes restored from snapshots are views of the objects of their source volumes, there is nothing to delete.
+		klog.V(4).Infof("DeleteVolume: volume %s is restored from a snapshot, not deleting any objects", volumeID)
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	vol := parseVolumeID(volumeID)
+	if vol.prefix != "" {
+		klog.V(4).Infof("DeleteVolume: deleting objects under prefix %q in bucket %s", vol.prefix, vol.bucket)
+		if err := cs.client.DeletePrefix(ctx, vol.bucket, vol.prefix); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not delete prefix %q in bucket %q: %v", vol.prefix, vol.bucket, err)
+		}
+	} else {
+		klog.V(4).Infof("DeleteVolume: deleting bucket %s", vol.bucket)
+		if err := cs.client.DeleteBucket(ctx, vol.bucket); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not delete bucket %q: %v", vol.bucket, err)
+		}
+	}
+
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func (cs *S3ControllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *S3ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *S3ControllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	klog.V(4).Infof("ControllerGetCapabilities: called with args %#v", req)
+	rpcTypes := slices.Clone(controllerCaps)
+	if cs.ModifyVolume {
+		rpcTypes = append(rpcTypes, csi.ControllerServiceCapability_RPC_MODIFY_VOLUME)
+	}
+	var capsResponse []*csi.ControllerServiceCapability
+	for _, cap := range rpcTypes {
+		c := &csi.ControllerServiceCapability{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{
+					Type: cap,
+				},
+			},
+		}
+		capsResponse = append(capsResponse, c)
+	}
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: capsResponse}, nil
+}
+
+func (cs *S3ControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+	klog.V(4).Infof("GetCapacity: called with args %#v", req)
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *S3ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+	klog.V(4).Infof("ListVolumes: called with args %#v", req)
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *S3ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	klog.V(4).Infof("ValidateVolumeCapabilities: called with args %#v", req)
+
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	volCaps := req.GetVolumeCapabilities()
+	if len(volCaps) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not provided")
+	}
+
+	if err := cs.checkVolumeExists(ctx, req.GetVolumeId()); err != nil {
+		return nil, err
+	}
+
+	if !isValidVolumeCapabilities(volCaps) {
+		return &csi.ValidateVolumeCapabilitiesResponse{Message: "Volume capabilities not supported"}, nil
+	}
+
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: volCaps,
+			Parameters:         req.GetParameters(),
+		},
+	}, nil
+}
+
+func (cs *S3ControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+// ControllerExpandVolume is a no-op as S3 has no notion of capacity,
+// it just echoes back requested capacity to let Kubernetes update capacity of the volume.
+func (cs *S3ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	klog.V(4).Infof("ControllerExpandVolume: called with args %#v", req)
+
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	capRange := req.GetCapacityRange()
+	if capRange == nil {
+		return nil, status.Error(codes.InvalidArgument, "Capacity range not provided")
+	}
+
+	requiredBytes, limitBytes := capRange.GetRequiredBytes(), capRange.GetLimitBytes()
+	if limitBytes > 0 && requiredBytes > limitBytes {
+		return nil, status.Errorf(codes.OutOfRange, "Required bytes %d exceeds limit bytes %d", requiredBytes, limitBytes)
+	}
+
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         requiredBytes,
+		NodeExpansionRequired: false,
+	}, nil
+}
+
+func (cs *S3ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+// ControllerModifyVolume only validates the mutable parameters of the new VolumeAttributesClass of the volume,
+// as there is nothing to modify in S3. The parameters are applied by the node plugin on the next mount of the volume,
+// as it resolves them from the VolumeAttributesClass of the PersistentVolume.
+func (cs *S3ControllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	klog.V(4).Infof("ControllerModifyVolume: called with args %#v", req)
+
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	if err := validateMutableParameters(req.GetMutableParameters()); err != nil {
+		return nil, err
+	}
+
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
+
+// checkVolumeExists returns a `NotFound` error if the bucket of `volumeID` does not exist. Prefixes in shared buckets
+// exist as long as their buckets do, as S3 has no notion of empty prefixes.
+func (cs *S3ControllerServer) checkVolumeExists(ctx context.Context, volumeID string) error {
+	if strings.HasPrefix(volumeID, restoredVolumeIDPrefix) {
+		return nil
+	}
+	vol := parseVolumeID(volumeID)
+	exists, err := cs.client.BucketExists(ctx, vol.bucket)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not check bucket %q exists: %v", vol.bucket, err)
+	}
+	if !exists {
+		return status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
+	}
+	return nil
+}
+
+// validateMutableParameters validates that only [volumecontext.MutableAttributes] are set in parameters of a VolumeAttributesClass.
+func validateMutableParameters(params map[string]string) error {
+	for key := range params {
+		if !slices.Contains(volumecontext.MutableAttributes, key) {
+			return status.Errorf(codes.InvalidArgument, "Parameter %q can't be set in a VolumeAttributesClass, only %s are supported",
+				key, strings.Join(volumecontext.MutableAttributes, ", "))
+		}
+	}
+	return nil
+}
+
+// A volume represents a dynamically provisioned volume.
+type volume struct {
+	bucket string
+	// prefix is empty if the volume is backed by a dedicated bucket.
+	prefix string
+}
+
+// id returns the CSI volume ID of the volume.
+// Volume IDs are in the form of "<bucket>" for dedicated buckets and "<bucket>/<prefix>" for shared buckets.
+func (v volume) id() string {
+	if v.prefix == "" {
+		return v.bucket
+	}
+	return v.bucket + "/" + strings.TrimSuffix(v.prefix, "/")
+}
+
+// parseVolumeID parses given volume ID created by [volume.id].
+func parseVolumeID(volumeID string) volume {
+	bucket, prefix, found := strings.Cut(volumeID, "/")
+	if !found || prefix == "" {
+		return volume{bucket: bucket}
+	}
+	return volume{bucket: bucket, prefix: prefix + "/"}
+}
+
+func isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
+	hasSupport := func(cap *csi.VolumeCapability) bool {
+		if cap.GetBlock() != nil {
+			return false
+		}
+		for _, c := range volumeCaps {
+			if c.GetMode() == cap.GetAccessMode().GetMode() {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, c := range volCaps {
+		if !hasSupport(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,378 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+var multiWriterVolCap = &csi.VolumeCapability{
+	AccessType: &csi.VolumeCapability_Mount{
+		Mount: &csi.VolumeCapability_MountVolume{},
+	},
+	AccessMode: &csi.VolumeCapability_AccessMode{
+		Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+	},
+}
+
+func TestCreateVolume(t *testing.T) {
+	t.Run("Creates a bucket per volume", func(t *testing.T) {
+		client := &fakeS3Client{}
+		server := controller.NewS3ControllerServer(client)
+
+		resp, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:               "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+			CapacityRange:      &csi.CapacityRange{RequiredBytes: 1024},
+			Parameters: map[string]string{
+				"bucketNamePrefix":     "s3-csi-",
+				"authenticationSource": "pod",
+				"unknown":              "value",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, []string{"s3-csi-pvc-1234"}, client.createdBuckets)
+		assert.Equals(t, "s3-csi-pvc-1234", resp.Volume.VolumeId)
+		assert.Equals(t, int64(1024), resp.Volume.CapacityBytes)
+		assert.Equals(t, map[string]string{
+			"bucketName":           "s3-csi-pvc-1234",
+			"authenticationSource": "pod",
+		}, resp.Volume.VolumeContext)
+	})
+
+	t.Run("Creates a prefix per volume in a shared bucket", func(t *testing.T) {
+		client := &fakeS3Client{}
+		server := controller.NewS3ControllerServer(client)
+
+		resp, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:               "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+			Parameters:         map[string]string{"bucketName": "shared-bucket"},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, len(client.createdBuckets))
+		assert.Equals(t, "shared-bucket/pvc-1234", resp.Volume.VolumeId)
+		assert.Equals(t, 0, len(resp.Volume.AccessibleTopology))
+		assert.Equals(t, map[string]string{
+			"bucketName": "shared-bucket",
+			"prefix":     "pvc-1234/",
+		}, resp.Volume.VolumeContext)
+	})
+
+	t.Run("Constrains topology of volumes in a shared directory bucket", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		resp, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:               "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+			Parameters:         map[string]string{"bucketName": "shared--use1-az4--x-s3"},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{"topology.k8s.aws/zone-id": "use1-az4"}},
+					{Segments: map[string]string{"topology.k8s.aws/zone-id": "use1-az6"}},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 1, len(resp.Volume.AccessibleTopology))
+		assert.Equals(t, map[string]string{"topology.k8s.aws/zone-id": "use1-az4"}, resp.Volume.AccessibleTopology[0].Segments)
+	})
+
+	t.Run("Fails if a shared directory bucket is not in allowed topologies", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:               "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+			Parameters:         map[string]string{"bucketName": "shared--use1-az4--x-s3"},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{"topology.k8s.aws/zone-id": "use1-az6"}}},
+			},
+		})
+		assert.Equals(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("Fails on unsupported volume capabilities", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name: "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{{
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			}},
+		})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Accepts single node writer access mode", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name: "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			}},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Fails if bucket creation fails", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{err: errors.New("access denied")})
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:               "pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+		})
+		assert.Equals(t, codes.Internal, status.Code(err))
+	})
+}
+
+func TestDeleteVolume(t *testing.T) {
+	t.Run("Deletes the bucket of the volume", func(t *testing.T) {
+		client := &fakeS3Client{}
+		server := controller.NewS3ControllerServer(client)
+
+		_, err := server.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "s3-csi-pvc-1234"})
+		assert.NoError(t, err)
+		assert.Equals(t, []string{"s3-csi-pvc-1234"}, client.deletedBuckets)
+		assert.Equals(t, 0, len(client.deletedPrefixes))
+	})
+
+	t.Run("Deletes the prefix of the volume in a shared bucket", func(t *testing.T) {
+		client := &fakeS3Client{}
+		server := controller.NewS3ControllerServer(client)
+
+		_, err := server.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "shared-bucket/pvc-1234"})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, len(client.deletedBuckets))
+		assert.Equals(t, []string{"shared-bucket/pvc-1234/"}, client.deletedPrefixes)
+	})
+
+	t.Run("Fails without volume ID", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestValidateVolumeCapabilities(t *testing.T) {
+	t.Run("Confirms supported volume capabilities", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		resp, err := server.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+			VolumeId:           "shared-bucket/pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, 1, len(resp.GetConfirmed().GetVolumeCapabilities()))
+	})
+
+	t.Run("Fails if the bucket of the volume does not exist", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{missingBuckets: []string{"shared-bucket"}})
+
+		_, err := server.ValidateVolumeCapabilities(context.Background(), &csi.ValidateVolumeCapabilitiesRequest{
+			VolumeId:           "shared-bucket/pvc-1234",
+			VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+		})
+		assert.Equals(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestControllerGetCapabilities(t *testing.T) {
+	hasModifyVolume := func(server *controller.S3ControllerServer) bool {
+		resp, err := server.ControllerGetCapabilities(context.Background(), &csi.ControllerGetCapabilitiesRequest{})
+		assert.NoError(t, err)
+		for _, cap := range resp.GetCapabilities() {
+			if cap.GetRpc().GetType() == csi.ControllerServiceCapability_RPC_MODIFY_VOLUME {
+				return true
+			}
+		}
+		return false
+	}
+
+	server := controller.NewS3ControllerServer(&fakeS3Client{})
+	assert.Equals(t, true, hasModifyVolume(server))
+	server.ModifyVolume = false
+	assert.Equals(t, false, hasModifyVolume(server))
+}
+
+func TestControllerExpandVolume(t *testing.T) {
+	server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+	resp, err := server.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "s3-csi-pvc-1234",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 2048},
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, int64(2048), resp.CapacityBytes)
+	assert.Equals(t, false, resp.NodeExpansionRequired)
+
+	_, err = server.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "s3-csi-pvc-1234",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 2048, LimitBytes: 1024},
+	})
+	assert.Equals(t, codes.OutOfRange, status.Code(err))
+
+	_, err = server.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+		VolumeId: "s3-csi-pvc-1234",
+	})
+	assert.Equals(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestControllerModifyVolume(t *testing.T) {
+	server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+	_, err := server.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "s3-csi-pvc-1234",
+		MutableParameters: map[string]string{"metadataTTL": "300", "logLevel": "debug"},
+	})
+	assert.NoError(t, err)
+
+	// Only mutable volume attributes can be modified
+	_, err = server.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "s3-csi-pvc-1234",
+		MutableParameters: map[string]string{"bucketName": "another-bucket"},
+	})
+	assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{})
+	assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+	// Volumes are created with mutable parameters of their VolumeAttributesClass
+	resp, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name:               "pvc-1234",
+		VolumeCapabilities: []*csi.VolumeCapability{multiWriterVolCap},
+		Parameters:         map[string]string{"bucketName": "shared-bucket", "metadataTTL": "60"},
+		MutableParameters:  map[string]string{"metadataTTL": "300"},
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, "300", resp.Volume.VolumeContext["metadataTTL"])
+}
+
+type fakeS3Client struct {
+	err error
+
+	createdBuckets  []string
+	missingBuckets  []string
+	deletedBuckets  []string
+	deletedPrefixes []string
+	// objects are the contents of objects keyed by "<bucket>/<key>".
+	objects map[string][]byte
+	// mu guards objects from concurrent copies.
+	mu sync.Mutex
+}
+
+var _ controller.S3Client = &fakeS3Client{}
+
+func (c *fakeS3Client) CreateBucket(ctx context.Context, bucket string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.createdBuckets = append(c.createdBuckets, bucket)
+	return nil
+}
+
+func (c *fakeS3Client) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return !slices.Contains(c.missingBuckets, bucket), c.err
+}
+
+func (c *fakeS3Client) DeleteBucket(ctx context.Context, bucket string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.deletedBuckets = append(c.deletedBuckets, bucket)
+	return nil
+}
+
+func (c *fakeS3Client) DeletePrefix(ctx context.Context, bucket string, prefix string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.deletedPrefixes = append(c.deletedPrefixes, bucket+"/"+prefix)
+	return nil
+}
+
+func (c *fakeS3Client) PrefixUsage(ctx context.Context, bucket string, prefix string, maxObjects int64) (controller.Usage, error) {
+	return controller.Usage{}, c.err
+}
+
+func (c *fakeS3Client) ListObjects(ctx context.Context, bucket string, prefix string) ([]controller.Object, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var objects []controller.Object
+	for path, body := range c.objects {
+		if key, ok := strings.CutPrefix(path, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			objects = append(objects, controller.Object{Key: key, ETag: fmt.Sprintf("%q", key), Size: int64(len(body))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (c *fakeS3Client) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	body, ok := c.objects[bucket+"/"+key]
+	if !ok {
+		return nil, controller.ErrObjectNotFound
+	}
+	return body, nil
+}
+
+func (c *fakeS3Client) PutObject(ctx context.Context, bucket string, key string, body []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.objects == nil {
+		c.objects = map[string][]byte{}
+	}
+	c.objects[bucket+"/"+key] = body
+	return nil
+}
+
+func (c *fakeS3Client) DeleteObject(ctx context.Context, bucket string, key string) error {
+	if c.err != nil {
+		return c.err
+	}
+	delete(c.objects, bucket+"/"+key)
+	return nil
+}
+
+func (c *fakeS3Client) CopyObject(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.objects[srcBucket+"/"+srcKey]
+	if !ok {
+		return controller.ErrObjectNotFound
+	}
+	c.objects[dstBucket+"/"+dstKey] = body
+	return nil
+}
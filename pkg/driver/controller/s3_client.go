@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// An S3Client is the subset of S3 operations needed to provision, delete, snapshot, clone and report usage of volumes.
+type S3Client interface {
+	// CreateBucket creates `bucket`. It does not return an error if `bucket` already exists and owned by the caller.
+	CreateBucket(ctx context.Context, bucket string) error
+	// BucketExists returns whether `bucket` exists.
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+	// DeleteBucket deletes all objects in `bucket` and then deletes `bucket`.
+	// It does not return an error if `bucket` does not exist.
+	DeleteBucket(ctx context.Context, bucket string) error
+	// DeletePrefix deletes all objects under `prefix` in `bucket`.
+	DeletePrefix(ctx context.Context, bucket string, prefix string) error
+	// PrefixUsage returns the total size and number of objects under `prefix` in `bucket`,
+	// counting at most `maxObjects` objects if it's positive.
+	PrefixUsage(ctx context.Context, bucket string, prefix string, maxObjects int64) (Usage, error)
+	// ListObjects returns all objects under `prefix` in `bucket`.
+	ListObjects(ctx context.Context, bucket string, prefix string) ([]Object, error)
+	// GetObject returns the contents of `key` in `bucket`, or [ErrObjectNotFound] if it does not exist.
+	GetObject(ctx context.Context, bucket string, key string) ([]byte, error)
+	// PutObject writes `body` to `key` in `bucket`.
+	PutObject(ctx context.Context, bucket string, key string, body []byte) error
+	// DeleteObject deletes `key` in `bucket`. It does not return an error if `key` does not exist.
+	DeleteObject(ctx context.Context, bucket string, key string) error
+	// CopyObject copies `srcKey` in `srcBucket` to `dstKey` in `dstBucket` server-side, without downloading it.
+	CopyObject(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string) error
+}
+
+// An Object represents an object in a bucket.
+type Object struct {
+	Key  string `json:"key"`
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+}
+
+// ErrObjectNotFound is returned by [S3Client.GetObject] if the object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// A Usage represents storage used by a volume.
+type Usage struct {
+	Bytes   int64
+	Objects int64
+	// Partial is whether only some of the objects are counted, in which case the usage is a lower bound.
+	Partial bool
+}
+
+// maxKeysPerDeleteObjects is the maximum number of keys allowed to be passed to a single `DeleteObjects` call.
+const maxKeysPerDeleteObjects = 1000
+
+// sdkS3Client is an [S3Client] implementation using AWS SDK.
+type sdkS3Client struct {
+	client *s3.Client
+	region string
+}
+
+// NewS3Client returns a new [S3Client] using the default AWS SDK config.
+// If the region is not configured via environment, it will be detected from IMDS.
+func NewS3Client(ctx context.Context) (S3Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithEC2IMDSRegion())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &sdkS3Client{client: s3.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+func (c *sdkS3Client) CreateBucket(ctx context.Context, bucket string) error {
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucket),
+	}
+
+	// `us-east-1` is the default location and S3 rejects requests with explicit `us-east-1` location constraint.
+	if c.region != "" && c.region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(c.region),
+		}
+	}
+
+	_, err := c.client.CreateBucket(ctx, input)
+	if err != nil {
+		var alreadyOwned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &alreadyOwned) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (c *sdkS3Client) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) || isNoSuchBucket(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *sdkS3Client) DeleteBucket(ctx context.Context, bucket string) error {
+	err := c.DeletePrefix(ctx, bucket, "")
+	if err != nil {
+		if isNoSuchBucket(err) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = c.client.DeleteBucket(ctx, &s3.DeleteBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil && !isNoSuchBucket(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *sdkS3Client) DeletePrefix(ctx context.Context, bucket string, prefix string) error {
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(maxKeysPerDeleteObjects),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+		}
+
+		output, err := c.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("failed to delete %d objects in %s, first error: %s", len(output.Errors), bucket, aws.ToString(output.Errors[0].Message))
+		}
+	}
+
+	return nil
+}
+
+func (c *sdkS3Client) PrefixUsage(ctx context.Context, bucket string, prefix string, maxObjects int64) (Usage, error) {
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var usage Usage
+	for paginator.HasMorePages() {
+		if maxObjects > 0 && usage.Objects >= maxObjects {
+			usage.Partial = true
+			break
+		}
+
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return usage, err
+		}
+
+		for _, obj := range page.Contents {
+			usage.Bytes += aws.ToInt64(obj.Size)
+			usage.Objects++
+		}
+	}
+
+	return usage, nil
+}
+
+func (c *sdkS3Client) ListObjects(ctx context.Context, bucket string, prefix string) ([]Object, error) {
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	var objects []Object
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Contents {
+			objects = append(objects, Object{Key: aws.ToString(obj.Key), ETag: aws.ToString(obj.ETag), Size: aws.ToInt64(obj.Size)})
+		}
+	}
+
+	return objects, nil
+}
+
+func (c *sdkS3Client) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	output, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
+func (c *sdkS3Client) PutObject(ctx context.Context, bucket string, key string, body []byte) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+	return err
+}
+
+func (c *sdkS3Client) DeleteObject(ctx context.Context, bucket string, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (c *sdkS3Client) CopyObject(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string) error {
+	_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(url.PathEscape(srcBucket + "/" + srcKey)),
+	})
+	return err
+}
+
+// isNoSuchBucket returns whether `err` is caused by a non-existent bucket.
+func isNoSuchBucket(err error) bool {
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchBucket) {
+		return true
+	}
+
+	// `DeleteBucket` does not model `NoSuchBucket` error, fallback to checking the error code.
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// VolumeSnapshotClass parameters supported in `CreateSnapshot`.
+const (
+	// ParamManifestPrefix is the prefix to store manifests of snapshots under, in the bucket of their source volumes.
+	ParamManifestPrefix = "manifestPrefix"
+)
+
+// DefaultManifestPrefix is the default prefix manifests of snapshots are stored under.
+const DefaultManifestPrefix = "csi-snapshot-manifests/"
+
+// restoredVolumeIDPrefix is the prefix of IDs of volumes restored from snapshots, which can't be a part of bucket names.
+// Restored volumes are views of the objects of their source volumes, so they're not backed by any bucket or prefix
+// of their own, and deleting them doesn't delete any objects.
+const restoredVolumeIDPrefix = "restored:"
+
+// A manifest is a snapshot of a volume, which lists its objects at the time the snapshot is taken instead of copying them.
+type manifest struct {
+	SourceVolumeID string    `json:"sourceVolumeId"`
+	Bucket         string    `json:"bucket"`
+	Prefix         string    `json:"prefix,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Objects        []Object  `json:"objects"`
+}
+
+// sizeBytes returns the total size of the objects in the manifest.
+func (m *manifest) sizeBytes() int64 {
+	var size int64
+	for _, obj := range m.Objects {
+		size += obj.Size
+	}
+	return size
+}
+
+// CreateSnapshot takes a snapshot of a volume as a manifest of its objects, i.e. their keys, ETags and sizes, stored as
+// a JSON object named after the snapshot under `manifestPrefix` parameter in the bucket of the volume. Objects are not
+// copied, so the snapshot doesn't preserve the contents of objects, but it records which objects the volume had and
+// allows detecting changes to them.
+func (cs *S3ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+	klog.V(4).Infof("CreateSnapshot: called with args %#v", req)
+
+	name, sourceVolumeID := req.GetName(), req.GetSourceVolumeId()
+	if len(name) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot name not provided")
+	}
+	if len(sourceVolumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Source volume ID not provided")
+	}
+	if strings.HasPrefix(sourceVolumeID, restoredVolumeIDPrefix) {
+		return nil, status.Errorf(codes.InvalidArgument, "Volume %s is restored from a snapshot, take a snapshot of its source volume instead", sourceVolumeID)
+	}
+
+	manifestPrefix := req.GetParameters()[ParamManifestPrefix]
+	if manifestPrefix == "" {
+		manifestPrefix = DefaultManifestPrefix
+	}
+	manifestPrefix = strings.TrimSuffix(manifestPrefix, "/") + "/"
+
+	vol := parseVolumeID(sourceVolumeID)
+	key := manifestPrefix + name + ".json"
+	snapshotID := vol.bucket + "/" + key
+
+	// `CreateSnapshot` is retried with the same name until it succeeds, the manifest is not re-created if it exists.
+	m, err := cs.getManifest(ctx, vol.bucket, key)
+	switch {
+	case err == nil && m.SourceVolumeID != sourceVolumeID:
+		return nil, status.Errorf(codes.AlreadyExists, "Snapshot %s already exists for another volume %s", name, m.SourceVolumeID)
+	case err == nil:
+		return snapshotResponse(snapshotID, m), nil
+	case !errors.Is(err, ErrObjectNotFound):
+		return nil, status.Errorf(codes.Internal, "Could not get manifest %q in bucket %q: %v", key, vol.bucket, err)
+	}
+
+	klog.V(4).Infof("CreateSnapshot: listing objects under prefix %q in bucket %s", vol.prefix, vol.bucket)
+	objects, err := cs.client.ListObjects(ctx, vol.bucket, vol.prefix)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not list objects under prefix %q in bucket %q: %v", vol.prefix, vol.bucket, err)
+	}
+	m = &manifest{SourceVolumeID: sourceVolumeID, Bucket: vol.bucket, Prefix: vol.prefix, CreatedAt: time.Now().UTC(), Objects: []Object{}}
+	for _, obj := range objects {
+		// Manifests are stored in the bucket of the volume, and they're part of volumes backed by dedicated buckets.
+		if !strings.HasPrefix(obj.Key, manifestPrefix) {
+			m.Objects = append(m.Objects, obj)
+		}
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not encode manifest: %v", err)
+	}
+	if err := cs.client.PutObject(ctx, vol.bucket, key, body); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not write manifest %q to bucket %q: %v", key, vol.bucket, err)
+	}
+	klog.V(4).Infof("CreateSnapshot: wrote manifest of %d objects to %q in bucket %s", len(m.Objects), key, vol.bucket)
+
+	return snapshotResponse(snapshotID, m), nil
+}
+
+// DeleteSnapshot deletes the manifest of a snapshot. The objects listed in the manifest are not deleted.
+func (cs *S3ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+	klog.V(4).Infof("DeleteSnapshot: called with args %#v", req)
+
+	snapshotID := req.GetSnapshotId()
+	if len(snapshotID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot ID not provided")
+	}
+
+	bucket, key, ok := strings.Cut(snapshotID, "/")
+	if !ok || key == "" {
+		// Not a snapshot created by the CSI Driver, there is nothing to delete.
+		return &csi.DeleteSnapshotResponse{}, nil
+	}
+	if err := cs.client.DeleteObject(ctx, bucket, key); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not delete manifest %q in bucket %q: %v", key, bucket, err)
+	}
+
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+// createVolumeFromSnapshot creates a read-only volume restored from the snapshot in `source`, which mounts the
+// bucket and prefix of the source volume of the snapshot with the location of its manifest in `snapshotManifest`
+// volume attribute. Restored volumes are read-only, as writes would go to their source volumes.
+func (cs *S3ControllerServer) createVolumeFromSnapshot(ctx context.Context, req *csi.CreateVolumeRequest, source *csi.VolumeContentSource_SnapshotSource, volumeCtx map[string]string) (*csi.CreateVolumeResponse, error) {
+	snapshotID := source.GetSnapshotId()
+	bucket, key, ok := strings.Cut(snapshotID, "/")
+	if !ok || key == "" {
+		return nil, status.Errorf(codes.NotFound, "Snapshot %s not found", snapshotID)
+	}
+	m, err := cs.getManifest(ctx, bucket, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, status.Errorf(codes.NotFound, "Snapshot %s not found", snapshotID)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not get manifest %q in bucket %q: %v", key, bucket, err)
+	}
+
+	for _, volCap := range req.GetVolumeCapabilities() {
+		if volCap.GetAccessMode().GetMode() != csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+			return nil, status.Error(codes.InvalidArgument, "Volumes restored from snapshots can only be mounted read-only, use `ReadOnlyMany` access mode")
+		}
+	}
+
+	klog.V(4).Infof("CreateVolume: restoring volume %s from snapshot %s of volume %s", req.GetName(), snapshotID, m.SourceVolumeID)
+	volumeCtx[volumecontext.BucketName] = m.Bucket
+	if m.Prefix != "" {
+		volumeCtx[volumecontext.Prefix] = m.Prefix
+	}
+	volumeCtx[volumecontext.SnapshotManifest] = snapshotID
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      restoredVolumeIDPrefix + req.GetName(),
+			CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+			VolumeContext: volumeCtx,
+			ContentSource: req.GetVolumeContentSource(),
+		},
+	}, nil
+}
+
+// getManifest returns the manifest at `key` in `bucket`, or [ErrObjectNotFound] if it does not exist.
+func (cs *S3ControllerServer) getManifest(ctx context.Context, bucket, key string) (*manifest, error) {
+	body, err := cs.client.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func snapshotResponse(snapshotID string, m *manifest) *csi.CreateSnapshotResponse {
+	return &csi.CreateSnapshotResponse{
+		Snapshot: &csi.Snapshot{
+			SnapshotId:     snapshotID,
+			SourceVolumeId: m.SourceVolumeID,
+			SizeBytes:      m.sizeBytes(),
+			CreationTime:   timestamppb.New(m.CreatedAt),
+			ReadyToUse:     true,
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+var readOnlyVolCap = &csi.VolumeCapability{
+	AccessType: &csi.VolumeCapability_Mount{
+		Mount: &csi.VolumeCapability_MountVolume{},
+	},
+	AccessMode: &csi.VolumeCapability_AccessMode{
+		Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+	},
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	t.Run("Writes a manifest of the objects of the volume", func(t *testing.T) {
+		client := &fakeS3Client{objects: map[string][]byte{
+			"shared-bucket/pvc-1234/a.txt":  []byte("hello"),
+			"shared-bucket/pvc-1234/b.txt":  []byte("world!"),
+			"shared-bucket/pvc-5678/c.txt":  []byte("other volume"),
+			"other-bucket/pvc-1234/d.txt":   []byte("other bucket"),
+			"shared-bucket/pvc-1234-e.txt":  []byte("sibling prefix"),
+			"shared-bucket/manifests/x.txt": []byte("unrelated"),
+		}}
+		server := controller.NewS3ControllerServer(client)
+
+		resp, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{
+			Name:           "snapshot-1",
+			SourceVolumeId: "shared-bucket/pvc-1234",
+			Parameters:     map[string]string{controller.ParamManifestPrefix: "manifests"},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, "shared-bucket/manifests/snapshot-1.json", resp.GetSnapshot().GetSnapshotId())
+		assert.Equals(t, "shared-bucket/pvc-1234", resp.GetSnapshot().GetSourceVolumeId())
+		assert.Equals(t, int64(11), resp.GetSnapshot().GetSizeBytes())
+		assert.Equals(t, true, resp.GetSnapshot().GetReadyToUse())
+
+		var manifest struct {
+			Bucket  string              `json:"bucket"`
+			Prefix  string              `json:"prefix"`
+			Objects []controller.Object `json:"objects"`
+		}
+		assert.NoError(t, json.Unmarshal(client.objects["shared-bucket/manifests/snapshot-1.json"], &manifest))
+		assert.Equals(t, "shared-bucket", manifest.Bucket)
+		assert.Equals(t, "pvc-1234/", manifest.Prefix)
+		assert.Equals(t, []controller.Object{
+			{Key: "pvc-1234/a.txt", ETag: `"pvc-1234/a.txt"`, Size: 5},
+			{Key: "pvc-1234/b.txt", ETag: `"pvc-1234/b.txt"`, Size: 6},
+		}, manifest.Objects)
+	})
+
+	t.Run("Excludes manifests from volumes backed by dedicated buckets", func(t *testing.T) {
+		client := &fakeS3Client{objects: map[string][]byte{
+			"s3-csi-pvc-1234/a.txt": []byte("hello"),
+		}}
+		server := controller.NewS3ControllerServer(client)
+
+		first, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "s3-csi-pvc-1234"})
+		assert.NoError(t, err)
+		assert.Equals(t, "s3-csi-pvc-1234/"+controller.DefaultManifestPrefix+"snapshot-1.json", first.GetSnapshot().GetSnapshotId())
+
+		second, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-2", SourceVolumeId: "s3-csi-pvc-1234"})
+		assert.NoError(t, err)
+		assert.Equals(t, int64(5), second.GetSnapshot().GetSizeBytes())
+	})
+
+	t.Run("Is idempotent", func(t *testing.T) {
+		client := &fakeS3Client{objects: map[string][]byte{"shared-bucket/pvc-1234/a.txt": []byte("hello")}}
+		server := controller.NewS3ControllerServer(client)
+		req := &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "shared-bucket/pvc-1234"}
+
+		first, err := server.CreateSnapshot(context.Background(), req)
+		assert.NoError(t, err)
+		client.objects["shared-bucket/pvc-1234/b.txt"] = []byte("written after the snapshot")
+
+		second, err := server.CreateSnapshot(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equals(t, first.GetSnapshot().GetSizeBytes(), second.GetSnapshot().GetSizeBytes())
+		assert.Equals(t, first.GetSnapshot().GetCreationTime().AsTime(), second.GetSnapshot().GetCreationTime().AsTime())
+	})
+
+	t.Run("Fails if the snapshot exists for another volume", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "shared-bucket/pvc-1234"})
+		assert.NoError(t, err)
+		_, err = server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "shared-bucket/pvc-5678"})
+		assert.Equals(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("Fails without name or source volume ID", func(t *testing.T) {
+		server := controller.NewS3ControllerServer(&fakeS3Client{})
+
+		_, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{SourceVolumeId: "shared-bucket/pvc-1234"})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		_, err = server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1"})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	client := &fakeS3Client{objects: map[string][]byte{"shared-bucket/pvc-1234/a.txt": []byte("hello")}}
+	server := controller.NewS3ControllerServer(client)
+
+	resp, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "shared-bucket/pvc-1234"})
+	assert.NoError(t, err)
+
+	_, err = server.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: resp.GetSnapshot().GetSnapshotId()})
+	assert.NoError(t, err)
+	assert.Equals(t, map[string][]byte{"shared-bucket/pvc-1234/a.txt": []byte("hello")}, client.objects)
+
+	// Deleting a deleted snapshot succeeds
+	_, err = server.DeleteSnapshot(context.Background(), &csi.DeleteSnapshotRequest{SnapshotId: resp.GetSnapshot().GetSnapshotId()})
+	assert.NoError(t, err)
+}
+
+func TestCreateVolumeFromSnapshot(t *testing.T) {
+	snapshot := func(t *testing.T) (*fakeS3Client, *controller.S3ControllerServer, string) {
+		client := &fakeS3Client{objects: map[string][]byte{"shared-bucket/pvc-1234/a.txt": []byte("hello")}}
+		server := controller.NewS3ControllerServer(client)
+		resp, err := server.CreateSnapshot(context.Background(), &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "shared-bucket/pvc-1234"})
+		assert.NoError(t, err)
+		return client, server, resp.GetSnapshot().GetSnapshotId()
+	}
+	fromSnapshot := func(snapshotID string) *csi.VolumeContentSource {
+		return &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+			Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: snapshotID},
+		}}
+	}
+
+	t.Run("Mounts the source volume read-only with the manifest", func(t *testing.T) {
+		client, server, snapshotID := snapshot(t)
+
+		resp, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:                "pvc-9999",
+			VolumeCapabilities:  []*csi.VolumeCapability{readOnlyVolCap},
+			VolumeContentSource: fromSnapshot(snapshotID),
+			Parameters:          map[string]string{controller.ParamBucketName: "another-bucket"},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, "restored:pvc-9999", resp.GetVolume().GetVolumeId())
+		assert.Equals(t, map[string]string{
+			"bucketName":       "shared-bucket",
+			"prefix":           "pvc-1234/",
+			"snapshotManifest": snapshotID,
+		}, resp.GetVolume().GetVolumeContext())
+		assert.Equals(t, snapshotID, resp.GetVolume().GetContentSource().GetSnapshot().GetSnapshotId())
+		assert.Equals(t, 0, len(client.createdBuckets))
+
+		// Deleting the restored volume doesn't delete the objects of its source volume
+		_, err = server.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: resp.GetVolume().GetVolumeId()})
+		assert.NoError(t, err)
+		assert.Equals(t, 0, len(client.deletedBuckets))
+		assert.Equals(t, 0, len(client.deletedPrefixes))
+	})
+
+	t.Run("Fails with writable access modes", func(t *testing.T) {
+		_, server, snapshotID := snapshot(t)
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:                "pvc-9999",
+			VolumeCapabilities:  []*csi.VolumeCapability{multiWriterVolCap},
+			VolumeContentSource: fromSnapshot(snapshotID),
+		})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Fails if the snapshot does not exist", func(t *testing.T) {
+		_, server, _ := snapshot(t)
+
+		_, err := server.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+			Name:                "pvc-9999",
+			VolumeCapabilities:  []*csi.VolumeCapability{readOnlyVolCap},
+			VolumeContentSource: fromSnapshot("shared-bucket/csi-snapshot-manifests/missing.json"),
+		})
+		assert.Equals(t, codes.NotFound, status.Code(err))
+	})
+}
@@ -21,16 +21,27 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"time"
 
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/platform"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/version"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/util"
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 )
 
@@ -44,6 +55,9 @@ const (
 
 	// This is the plugin directory for CSI driver mounted in the container.
 	containerPluginDir = "/csi"
+
+	// nodeStateFile is the name of the file in the plugin directory to persist published volumes across restarts.
+	nodeStateFile = "node-state.json"
 )
 
 type Driver struct {
@@ -51,10 +65,24 @@ type Driver struct {
 	Srv      *grpc.Server
 	NodeID   string
 
+	// Limits configures limits of the gRPC server, it's not limited by default.
+	Limits ServerLimits
+	// NodeStateFile is optional, and the path to persist volumes published by the node server across restarts.
+	NodeStateFile string
+
 	NodeServer *node.S3NodeServer
+	// ControllerServer is optional, and only set if the driver is running as a controller to provide dynamic provisioning.
+	// The driver's default controller service is used otherwise, which does not support any operations.
+	ControllerServer *controller.S3ControllerServer
+
+	// srvMu guards Srv and stopped, as Stop might be called concurrently with Run.
+	srvMu   sync.Mutex
+	stopped bool
 }
 
-func NewDriver(endpoint string, mpVersion string, nodeID string) (*Driver, error) {
+// NewDriver returns a new driver serving the node service on `endpoint`, spawning Mountpoint processes with
+// the mounter of `mounterKind` (see [mounter.KindSystemd] and [mounter.KindProcess]).
+func NewDriver(endpoint string, mpVersion string, nodeID string, mounterKind string) (*Driver, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, fmt.Errorf("cannot create in-cluster config: %w", err)
@@ -74,18 +102,46 @@ func NewDriver(endpoint string, mpVersion string, nodeID string) (*Driver, error
 	klog.Infof("Driver version: %v, Git commit: %v, build date: %v, nodeID: %v, mount-s3 version: %v, kubernetes version: %v",
 		version.DriverVersion, version.GitCommit, version.BuildDate, nodeID, mpVersion, kubernetesVersion)
 
-	systemd_mounter, err := mounter.NewSystemdMounter(mpVersion, kubernetesVersion)
+	k8sNode, err := clientset.CoreV1().Nodes().Get(context.Background(), nodeID, metav1.GetOptions{})
 	if err != nil {
-		klog.Fatalln(err)
+		klog.Warningf("Failed to get node %s, its platform and Availability Zone won't be validated: %v", nodeID, err)
+		k8sNode = nil
+		if err := platform.Check(runtime.GOOS, runtime.GOARCH); err != nil {
+			return nil, fmt.Errorf("refusing to register the node plugin on an unsupported platform: %w", err)
+		}
+	} else if err := platform.CheckRunningOn(k8sNode, runtime.GOOS, runtime.GOARCH); err != nil {
+		return nil, fmt.Errorf("refusing to register the node plugin on an unsupported platform: %w", err)
+	}
+
+	var mpMounter mounter.Mounter
+	switch mounterKind {
+	case mounter.KindSystemd, "":
+		mpMounter, err = mounter.NewSystemdMounter(mpVersion, kubernetesVersion)
+		if err != nil {
+			klog.Fatalln(err)
+		}
+	case mounter.KindProcess:
+		klog.Infof("Spawning Mountpoint processes as child processes of the CSI Driver Node Pod")
+		mpMounter = mounter.NewProcessMounter(mpVersion, kubernetesVersion)
+	default:
+		return nil, fmt.Errorf("unknown mounter %q, must be %q or %q", mounterKind, mounter.KindSystemd, mounter.KindProcess)
 	}
 
 	credentialProvider := mounter.NewCredentialProvider(clientset.CoreV1(), containerPluginDir, mounter.RegionFromIMDSOnce)
-	nodeServer := node.NewS3NodeServer(nodeID, systemd_mounter, credentialProvider)
+	nodeServer := node.NewS3NodeServer(nodeID, mpMounter, credentialProvider)
+	nodeServer.EventRecorder = newEventRecorder(clientset, nodeID)
+	nodeServer.MountpointVersion = mpVersion
+	nodeServer.ZoneID = nodeZoneID(k8sNode)
+	nodeServer.VolumeAttributesClasses = node.NewVolumeAttributesClassResolver(clientset)
+	nodeServer.Nodes = clientset.CoreV1().Nodes()
+	nodeServer.CSIDrivers = clientset.StorageV1().CSIDrivers()
+	nodeServer.DriverName = driverName
 
 	return &Driver{
-		Endpoint:   endpoint,
-		NodeID:     nodeID,
-		NodeServer: nodeServer,
+		Endpoint:      endpoint,
+		NodeID:        nodeID,
+		NodeServer:    nodeServer,
+		NodeStateFile: filepath.Join(containerPluginDir, nodeStateFile),
 	}, nil
 }
 
@@ -98,6 +154,26 @@ func (d *Driver) Run() error {
 		go tokenFileTender(ctx, tokenFile, "/csi/token")
 	}
 
+	if d.NodeServer != nil {
+		if d.NodeStateFile != "" {
+			if err := d.NodeServer.RestoreState(d.NodeStateFile); err != nil {
+				klog.Errorf("Failed to restore published volumes: %v", err)
+			}
+		}
+		go d.NodeServer.MonitorMounts(ctx, node.MountMonitorInterval)
+		go d.NodeServer.ReportMountpointCapacity(ctx, node.MountpointCapacityReportInterval)
+		if d.NodeServer.PurgeCachesOnDiskPressure {
+			go d.NodeServer.WatchDiskPressure(ctx, node.DiskPressureCheckInterval)
+		}
+		if len(d.NodeServer.ReissueTokenServiceAccounts) > 0 {
+			go func() {
+				if err := d.NodeServer.WatchTokens(ctx); err != nil {
+					klog.Errorf("Failed to watch service account tokens, they're only re-issued close to expiry: %v", err)
+				}
+			}()
+		}
+	}
+
 	scheme, addr, err := ParseEndpoint(d.Endpoint)
 	if err != nil {
 		return err
@@ -132,22 +208,43 @@ func (d *Driver) Run() error {
 		return resp, err
 	}
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(logErr),
+		grpc.ChainUnaryInterceptor(observeRPCDuration, d.Limits.UnaryInterceptor(), logErr),
 		grpc.MaxRecvMsgSize(grpcServerMaxReceiveMessageSize),
 	}
-	d.Srv = grpc.NewServer(opts...)
+	srv := grpc.NewServer(opts...)
+
+	csi.RegisterIdentityServer(srv, d)
+	if d.ControllerServer != nil {
+		csi.RegisterControllerServer(srv, d.ControllerServer)
+	} else {
+		csi.RegisterControllerServer(srv, d)
+	}
+	if d.NodeServer != nil {
+		csi.RegisterNodeServer(srv, d.NodeServer)
+	}
 
-	csi.RegisterIdentityServer(d.Srv, d)
-	csi.RegisterControllerServer(d.Srv, d)
-	csi.RegisterNodeServer(d.Srv, d.NodeServer)
+	d.srvMu.Lock()
+	if d.stopped {
+		d.srvMu.Unlock()
+		listener.Close()
+		return nil
+	}
+	d.Srv = srv
+	d.srvMu.Unlock()
 
 	klog.Infof("Listening for connections on address: %#v", listener.Addr())
-	return d.Srv.Serve(listener)
+	return srv.Serve(listener)
 }
 
+// Stop stops the server, it's safe to call concurrently with Run and before Run, in which case Run returns right away.
 func (d *Driver) Stop() {
 	klog.Infof("Stopping server")
-	d.Srv.Stop()
+	d.srvMu.Lock()
+	defer d.srvMu.Unlock()
+	d.stopped = true
+	if d.Srv != nil {
+		d.Srv.Stop()
+	}
 }
 
 func tokenFileTender(ctx context.Context, sourcePath string, destPath string) {
@@ -166,6 +263,25 @@ func tokenFileTender(ctx context.Context, sourcePath string, destPath string) {
 	}
 }
 
+// newEventRecorder returns a new event recorder to emit events to the objects in the cluster.
+func newEventRecorder(clientset *kubernetes.Clientset, nodeID string) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: driverName, Host: nodeID})
+}
+
+// nodeZoneID returns the Availability Zone ID of `k8sNode` from its labels, or an empty string if it's not known.
+func nodeZoneID(k8sNode *corev1.Node) string {
+	if k8sNode == nil {
+		return ""
+	}
+	zoneID := k8sNode.Labels[topology.KeyZoneID]
+	if zoneID == "" {
+		klog.Warningf("Node %s does not have %s label, shared cache buckets won't be validated", k8sNode.Name, topology.KeyZoneID)
+	}
+	return zoneID
+}
+
 func kubernetesVersion(clientset *kubernetes.Clientset) (string, error) {
 	version, err := clientset.ServerVersion()
 	if err != nil {
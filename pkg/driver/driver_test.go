@@ -0,0 +1,53 @@
+package driver_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestDriverStop(t *testing.T) {
+	t.Run("before run", func(t *testing.T) {
+		drv := &driver.Driver{Endpoint: "unix://" + filepath.Join(t.TempDir(), "csi.sock")}
+		drv.Stop()
+		assert.NoError(t, drv.Run())
+	})
+
+	t.Run("concurrently with run", func(t *testing.T) {
+		drv := &driver.Driver{Endpoint: "unix://" + filepath.Join(t.TempDir(), "csi.sock")}
+		done := make(chan error)
+		go func() { done <- drv.Run() }()
+		drv.Stop()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Run to return after Stop")
+		}
+	})
+}
+
+func TestGetPluginCapabilities(t *testing.T) {
+	hasTopology := func(drv *driver.Driver) bool {
+		resp, err := drv.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+		assert.NoError(t, err)
+		for _, cap := range resp.GetCapabilities() {
+			if cap.GetService().GetType() == csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.Equals(t, true, hasTopology(&driver.Driver{}))
+	assert.Equals(t, true, hasTopology(&driver.Driver{NodeServer: &node.S3NodeServer{ZoneID: "use1-az4"}}))
+	assert.Equals(t, false, hasTopology(&driver.Driver{NodeServer: &node.S3NodeServer{}}))
+}
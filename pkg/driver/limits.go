@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+var (
+	rpcInflight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi",
+		Name:      "rpc_inflight",
+		Help:      "Number of CSI RPCs being handled by method.",
+	}, []string{"method"})
+	rpcWaiting = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi",
+		Name:      "rpc_waiting",
+		Help:      "Number of CSI RPCs waiting for a slot due to the concurrency limit by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(rpcInflight, rpcWaiting)
+}
+
+// limitedMethods are the RPCs subject to the concurrency limit, as they mount or unmount volumes.
+// Other RPCs are cheap, and some of them are used for health checks, so they're never queued.
+var limitedMethods = map[string]bool{
+	"/csi.v1.Node/NodeStageVolume":     true,
+	"/csi.v1.Node/NodeUnstageVolume":   true,
+	"/csi.v1.Node/NodePublishVolume":   true,
+	"/csi.v1.Node/NodeUnpublishVolume": true,
+}
+
+// unmountMethods are the RPCs limited by `MaxConcurrentUnmounts` instead of `MaxConcurrentMounts` if it's set.
+var unmountMethods = map[string]bool{
+	"/csi.v1.Node/NodeUnstageVolume":   true,
+	"/csi.v1.Node/NodeUnpublishVolume": true,
+}
+
+// ServerLimits configures limits of the CSI gRPC server to not starve the driver,
+// e.g. with a flood of `NodePublishVolume` calls after kubelet restarts in a node with hundreds of Pods.
+type ServerLimits struct {
+	// RequestTimeout is the deadline of each RPC, RPCs only have the deadlines set by their callers if it's zero.
+	RequestTimeout time.Duration
+	// MaxConcurrentMounts is the maximum number of mount and unmount RPCs to handle concurrently,
+	// they're not limited if it's zero. RPCs over the limit wait for a slot until their deadline.
+	MaxConcurrentMounts int
+	// MaxConcurrentUnmounts is the maximum number of unmount RPCs to handle concurrently, in a pool separate from
+	// mount RPCs, so unmounts of a draining node don't wait behind mounts and can run with a different parallelism.
+	// Unmount RPCs share the limit of `MaxConcurrentMounts` if it's zero.
+	MaxConcurrentUnmounts int
+}
+
+// UnaryInterceptor returns a gRPC interceptor enforcing the limits, and recording inflight RPCs.
+func (l ServerLimits) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	var mountSlots chan struct{}
+	if l.MaxConcurrentMounts > 0 {
+		mountSlots = make(chan struct{}, l.MaxConcurrentMounts)
+	}
+	unmountSlots, maxUnmounts := mountSlots, l.MaxConcurrentMounts
+	if l.MaxConcurrentUnmounts > 0 {
+		unmountSlots, maxUnmounts = make(chan struct{}, l.MaxConcurrentUnmounts), l.MaxConcurrentUnmounts
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.RequestTimeout)
+			defer cancel()
+		}
+
+		slots, limit := mountSlots, l.MaxConcurrentMounts
+		if unmountMethods[info.FullMethod] {
+			slots, limit = unmountSlots, maxUnmounts
+		}
+		if slots != nil && limitedMethods[info.FullMethod] {
+			waiting := rpcWaiting.WithLabelValues(info.FullMethod)
+			waiting.Inc()
+			select {
+			case slots <- struct{}{}:
+				waiting.Dec()
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				waiting.Dec()
+				klog.Warningf("%s: timed out waiting for one of %d concurrent slots: %v", info.FullMethod, limit, ctx.Err())
+				return nil, status.Errorf(codes.ResourceExhausted, "Too many concurrent requests, timed out waiting for a slot: %v", ctx.Err())
+			}
+		}
+
+		inflight := rpcInflight.WithLabelValues(info.FullMethod)
+		inflight.Inc()
+		defer inflight.Dec()
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,94 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestServerLimits(t *testing.T) {
+	publish := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodePublishVolume"}
+	unpublish := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodeUnpublishVolume"}
+	probe := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Identity/Probe"}
+
+	t.Run("request timeout", func(t *testing.T) {
+		interceptor := driver.ServerLimits{RequestTimeout: time.Minute}.UnaryInterceptor()
+		_, err := interceptor(context.Background(), nil, probe, func(ctx context.Context, req interface{}) (interface{}, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Fatal("Expected RPC to have a deadline")
+			}
+			return nil, nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrency limit", func(t *testing.T) {
+		interceptor := driver.ServerLimits{RequestTimeout: 100 * time.Millisecond, MaxConcurrentMounts: 1}.UnaryInterceptor()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := interceptor(context.Background(), nil, publish, func(ctx context.Context, req interface{}) (interface{}, error) {
+				close(started)
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+		<-started
+
+		// Mount RPCs over the limit wait for a slot until their deadline
+		_, err := interceptor(context.Background(), nil, publish, func(ctx context.Context, req interface{}) (interface{}, error) {
+			t.Fatal("Expected RPC over the limit not to be handled")
+			return nil, nil
+		})
+		assert.Equals(t, codes.ResourceExhausted, status.Code(err))
+
+		// Other RPCs are not limited
+		_, err = interceptor(context.Background(), nil, probe, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		assert.NoError(t, err)
+
+		close(release)
+		assert.NoError(t, <-done)
+
+		// The slot is released once the RPC is handled
+		_, err = interceptor(context.Background(), nil, publish, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("separate unmount limit", func(t *testing.T) {
+		interceptor := driver.ServerLimits{RequestTimeout: 100 * time.Millisecond, MaxConcurrentMounts: 1, MaxConcurrentUnmounts: 2}.UnaryInterceptor()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := interceptor(context.Background(), nil, publish, func(ctx context.Context, req interface{}) (interface{}, error) {
+				close(started)
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+		<-started
+
+		// Unmount RPCs don't wait behind mount RPCs
+		_, err := interceptor(context.Background(), nil, unpublish, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		assert.NoError(t, err)
+
+		close(release)
+		assert.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,29 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "s3_csi",
+	Name:      "rpc_duration_seconds",
+	Help:      "Duration of CSI RPCs by method and gRPC status code.",
+	Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"method", "code"})
+
+func init() {
+	prometheus.MustRegister(rpcDuration)
+}
+
+// observeRPCDuration is a gRPC interceptor to record duration of each CSI RPC.
+func observeRPCDuration(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	rpcDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+	return resp, err
+}
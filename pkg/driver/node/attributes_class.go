@@ -0,0 +1,118 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// A VolumeAttributesClassResolver resolves the parameters of the VolumeAttributesClass of volumes being mounted.
+//
+// Kubelet only passes the volume attributes of PersistentVolumes, which are immutable, so the mutable parameters
+// of a volume are resolved from the VolumeAttributesClass currently set on its PersistentVolume.
+// It requires permission to get Pods, PersistentVolumeClaims, PersistentVolumes and VolumeAttributesClasses.
+type VolumeAttributesClassResolver struct {
+	client kubernetes.Interface
+}
+
+// NewVolumeAttributesClassResolver returns a new resolver using `client`.
+func NewVolumeAttributesClassResolver(client kubernetes.Interface) *VolumeAttributesClassResolver {
+	return &VolumeAttributesClassResolver{client: client}
+}
+
+// Resolve returns `volumeCtx` with the parameters of the VolumeAttributesClass of the PersistentVolume of `volumeID`
+// applied, and the name of the class. Only [volumecontext.MutableAttributes] are applied.
+//
+// The PersistentVolume is looked up from the volumes of the workload Pod, so `volumeCtx` is returned as is
+// if the Pod is unknown (i.e., `podInfoOnMount` is not enabled), and for CSI ephemeral volumes.
+func (r *VolumeAttributesClassResolver) Resolve(ctx context.Context, volumeID string, volumeCtx map[string]string) (map[string]string, string, error) {
+	podName, podNamespace := volumeCtx[volumecontext.CSIPodName], volumeCtx[volumecontext.CSIPodNamespace]
+	if podName == "" || podNamespace == "" || volumeCtx[volumecontext.CSIEphemeral] == "true" {
+		return volumeCtx, "", nil
+	}
+
+	pod, err := r.client.CoreV1().Pods(podNamespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get Pod %s/%s: %w", podNamespace, podName, err)
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := r.client.CoreV1().PersistentVolumeClaims(podNamespace).Get(ctx, volume.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get PersistentVolumeClaim %s/%s: %w", podNamespace, volume.PersistentVolumeClaim.ClaimName, err)
+		}
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		pv, err := r.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get PersistentVolume %s: %w", pvc.Spec.VolumeName, err)
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.VolumeHandle != volumeID {
+			continue
+		}
+
+		className := pv.Spec.VolumeAttributesClassName
+		if className == nil || *className == "" {
+			return volumeCtx, "", nil
+		}
+		vac, err := r.client.StorageV1beta1().VolumeAttributesClasses().Get(ctx, *className, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get VolumeAttributesClass %s: %w", *className, err)
+		}
+
+		volumeCtx = maps.Clone(volumeCtx)
+		for _, key := range volumecontext.MutableAttributes {
+			if value, ok := vac.Parameters[key]; ok {
+				volumeCtx[key] = value
+			}
+		}
+		return volumeCtx, *className, nil
+	}
+
+	klog.V(4).Infof("Volume %s is not found in volumes of Pod %s/%s, its VolumeAttributesClass is not applied", volumeID, podNamespace, podName)
+	return volumeCtx, "", nil
+}
+
+// applyVolumeAttributesClass returns `volumeCtx` of the volume being published at `target` with the parameters
+// of its VolumeAttributesClass applied, if [S3NodeServer.ApplyVolumeAttributesClasses] is enabled.
+//
+// Parameters are only resolved on the first publish of a target, republishes keep the parameters the volume
+// is mounted with, as changes of the class are applied on the next mount of the volume.
+func (ns *S3NodeServer) applyVolumeAttributesClass(ctx context.Context, volumeID, target string, volumeCtx map[string]string) (map[string]string, error) {
+	if !ns.ApplyVolumeAttributesClasses || ns.VolumeAttributesClasses == nil {
+		return volumeCtx, nil
+	}
+
+	if published, ok := ns.publishedVolumes.get(target); ok && published.volumeID == volumeID {
+		volumeCtx = maps.Clone(volumeCtx)
+		for _, key := range volumecontext.MutableAttributes {
+			if value, ok := published.volumeCtx[key]; ok {
+				volumeCtx[key] = value
+			} else {
+				delete(volumeCtx, key)
+			}
+		}
+		return volumeCtx, nil
+	}
+
+	volumeCtx, className, err := ns.VolumeAttributesClasses.Resolve(ctx, volumeID, volumeCtx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "Failed to resolve VolumeAttributesClass of volume %s: %v", volumeID, err)
+	}
+	if className != "" {
+		klog.V(4).Infof("NodePublishVolume: applying VolumeAttributesClass %s to volume %s", className, volumeID)
+	}
+	return volumeCtx, nil
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// Actions recorded in audit logs of S3 volumes.
+const (
+	auditActionAttach = "attach"
+	auditActionDetach = "detach"
+)
+
+// auditLoggerName is the name of the logger emitting audit logs, so they can be filtered from the rest of the logs.
+const auditLoggerName = "audit"
+
+// audit logs a workload Pod attaching to or detaching from the bucket of `vol` at `target`, so security teams can
+// reconstruct which Pods had access to which buckets at a given time, with which identity and whether they could write.
+// Audit logs are emitted regardless of the log verbosity, and they're one JSON object per line with `--log-format=json`.
+func audit(action, target string, vol publishedVolume) {
+	klog.Background().WithName(auditLoggerName).Info("S3 volume "+action,
+		"action", action,
+		"bucket", vol.bucket,
+		logging.KeyVolumeID, vol.volumeID,
+		logging.KeyPodUID, vol.volumeCtx[volumecontext.CSIPodUID],
+		"pod_namespace", vol.volumeCtx[volumecontext.CSIPodNamespace],
+		"pod_name", vol.volumeCtx[volumecontext.CSIPodName],
+		"service_account", vol.volumeCtx[volumecontext.CSIServiceAccountName],
+		"authentication_source", authenticationSourceLabel(vol.volumeCtx),
+		"iam_role", vol.roleARN,
+		"read_only", vol.isReadOnly(),
+		"mount_options", vol.args,
+		logging.KeyTargetPath, target)
+}
+
+// isReadOnly returns whether the workload Pod of `vol` has read-only access to the bucket.
+func (v publishedVolume) isReadOnly() bool {
+	return v.readOnly || slices.Contains(v.args, mountpoint.ArgReadOnly)
+}
+
+// accessSummary returns a human-readable summary of how the workload Pod of `vol` accesses the bucket to add to events,
+// e.g. "read-write access as service account default/training with IAM role arn:aws:iam::111122223333:role/training".
+func (v publishedVolume) accessSummary() string {
+	access := "read-write"
+	if v.isReadOnly() {
+		access = "read-only"
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s access", access)
+	if serviceAccount := v.volumeCtx[volumecontext.CSIServiceAccountName]; serviceAccount != "" {
+		fmt.Fprintf(&summary, " as service account %s/%s", v.volumeCtx[volumecontext.CSIPodNamespace], serviceAccount)
+	}
+	if v.roleARN != "" {
+		fmt.Fprintf(&summary, " with IAM role %s", v.roleARN)
+	}
+	return summary.String()
+}
+
+// roleARNOf returns the ARN of the IAM role used to access the bucket with `credentials`, or empty if it's not known,
+// e.g. with long-term credentials. The role in `stsRoleArn` volume attribute is assumed on top of `credentials` if it's set.
+func roleARNOf(volumeCtx map[string]string, credentials *mounter.MountCredentials) string {
+	if roleARN := volumeCtx[volumecontext.STSRoleARN]; roleARN != "" {
+		return roleARN
+	}
+	if credentials == nil {
+		return ""
+	}
+	return credentials.AwsRoleArn
+}
@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -21,6 +22,9 @@ const (
 // ErrInvalidCredentials is returned when given AWS Credentials contains invalid characters.
 var ErrInvalidCredentials = errors.New("aws-profile: Invalid AWS Credentials")
 
+// ErrProfileNotFound is returned when given AWS Profile does not exist in given credentials and config files.
+var ErrProfileNotFound = errors.New("aws-profile: Profile not found")
+
 // An AWSProfile represents an AWS profile with it's credentials and config files.
 type AWSProfile struct {
 	Name            string
@@ -56,6 +60,70 @@ func CreateAWSProfile(basepath string, accessKeyID string, secretAccessKey strin
 	}, nil
 }
 
+// CreateAWSProfileFromCredentialsFile creates an AWS Profile with given contents of a `credentials` file, which might
+// contain multiple profiles, and selects the profile `name` from it.
+// Created credentials and config files can be clean up with `CleanupAWSProfile`.
+//
+// Only static credentials (see `credentialsFileKeys`) are accepted, as any other setting (e.g., `credential_process`,
+// `credential_source` or `web_identity_token_file`) would make Mountpoint run processes or use files and identity of the host.
+func CreateAWSProfileFromCredentialsFile(basepath string, name string, credentials string) (AWSProfile, error) {
+	if err := checkStaticCredentialsFile(credentials); err != nil {
+		return AWSProfile{}, err
+	}
+	if !hasProfile(credentials, "["+name+"]") {
+		return AWSProfile{}, fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+	}
+
+	// Config file is created empty to not fall back to the config file of the host.
+	configPath := filepath.Join(basepath, awsProfileConfigFilename)
+	err := writeAWSProfileFile(configPath, "")
+	if err != nil {
+		return AWSProfile{}, fmt.Errorf("aws-profile: Failed to create config file %s: %v", configPath, err)
+	}
+
+	credentialsPath := filepath.Join(basepath, awsProfileCredentialsFilename)
+	err = writeAWSProfileFile(credentialsPath, credentials)
+	if err != nil {
+		return AWSProfile{}, fmt.Errorf("aws-profile: Failed to create credentials file %s: %v", credentialsPath, err)
+	}
+
+	return AWSProfile{
+		Name:            name,
+		ConfigPath:      configPath,
+		CredentialsPath: credentialsPath,
+	}, nil
+}
+
+// CreateAWSProfileWithCredentialProcess creates an AWS Profile that obtains credentials by running given `process`,
+// which must be controlled by the CSI Driver as its run on the host.
+// Created credentials and config files can be clean up with `CleanupAWSProfile`.
+func CreateAWSProfileWithCredentialProcess(basepath string, process string) (AWSProfile, error) {
+	if !isValidCredential(process) {
+		return AWSProfile{}, ErrInvalidCredentials
+	}
+
+	name := awsProfileName
+
+	configPath := filepath.Join(basepath, awsProfileConfigFilename)
+	err := writeAWSProfileFile(configPath, configFileContents(name)+"credential_process="+process+"\n")
+	if err != nil {
+		return AWSProfile{}, fmt.Errorf("aws-profile: Failed to create config file %s: %v", configPath, err)
+	}
+
+	// Credentials file is created empty to not fall back to the credentials file of the host.
+	credentialsPath := filepath.Join(basepath, awsProfileCredentialsFilename)
+	err = writeAWSProfileFile(credentialsPath, "")
+	if err != nil {
+		return AWSProfile{}, fmt.Errorf("aws-profile: Failed to create credentials file %s: %v", credentialsPath, err)
+	}
+
+	return AWSProfile{
+		Name:            name,
+		ConfigPath:      configPath,
+		CredentialsPath: credentialsPath,
+	}, nil
+}
+
 // CleanupAWSProfile cleans up credentials and config files created in given `basepath` via `CreateAWSProfile`.
 func CleanupAWSProfile(basepath string) error {
 	configPath := filepath.Join(basepath, awsProfileConfigFilename)
@@ -114,6 +182,40 @@ func configFileContents(profile string) string {
 	return fmt.Sprintf("[profile %s]\n", profile)
 }
 
+// hasProfile returns whether given credentials file `contents` has a section with given `header`.
+func hasProfile(contents string, header string) bool {
+	for _, line := range strings.Split(contents, "\n") {
+		if strings.TrimSpace(line) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialsFileKeys are the only settings allowed in credentials files passed by users.
+var credentialsFileKeys = []string{"aws_access_key_id", "aws_secret_access_key", "aws_session_token"}
+
+// checkStaticCredentialsFile returns an error if given credentials file `contents` has anything other than
+// section headers, comments and static credentials.
+func checkStaticCredentialsFile(contents string) error {
+	lines := strings.FieldsFunc(contents, func(r rune) bool { return r == '\n' || r == '\r' })
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			continue
+		}
+		key, _, found := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !found || !slices.Contains(credentialsFileKeys, key) {
+			return fmt.Errorf("%w: only %v settings are supported in credentials file", ErrInvalidCredentials, credentialsFileKeys)
+		}
+	}
+	return nil
+}
+
 // isValidCredential checks whether given credential file contains any non-printable characters.
 func isValidCredential(s string) bool {
 	return !strings.ContainsFunc(s, func(r rune) bool { return !unicode.IsPrint(r) })
@@ -59,6 +59,65 @@ func TestCreatingAWSProfile(t *testing.T) {
 	})
 }
 
+func TestCreatingAWSProfileFromCredentialsFile(t *testing.T) {
+	credentials := "# Comment\n[default]\naws_access_key_id=default-key\naws_secret_access_key=default-secret\n\n" +
+		"[team-a]\naws_access_key_id = " + testAccessKeyId + "\naws_secret_access_key = " + testSecretAccessKey + "\naws_session_token = " + testSessionToken + "\n"
+
+	t.Run("select profile from credentials file", func(t *testing.T) {
+		profile, err := awsprofile.CreateAWSProfileFromCredentialsFile(t.TempDir(), "team-a", credentials)
+		assertNoError(t, err)
+		assertEquals(t, "team-a", profile.Name)
+		assertCredentialsFromAWSProfile(t, profile, testAccessKeyId, testSecretAccessKey, testSessionToken)
+
+		credentialsStat, err := os.Stat(profile.CredentialsPath)
+		assertNoError(t, err)
+		assertEquals(t, 0400, credentialsStat.Mode())
+	})
+
+	t.Run("fail if profile does not exist", func(t *testing.T) {
+		_, err := awsprofile.CreateAWSProfileFromCredentialsFile(t.TempDir(), "team-b", credentials)
+		assertEquals(t, true, errors.Is(err, awsprofile.ErrProfileNotFound))
+	})
+
+	t.Run("fail if credentials file has settings other than static credentials", func(t *testing.T) {
+		for name, setting := range map[string]string{
+			"credential_process":      "credential_process = /bin/sh -c exit",
+			"uppercase key":           "CREDENTIAL_PROCESS=/bin/sh -c exit",
+			"credential_source":       "credential_source=Ec2InstanceMetadata",
+			"web_identity_token_file": "web_identity_token_file=/var/run/secrets/token",
+			"role_arn":                "role_arn=arn:aws:iam::123456789012:role/Driver",
+			"source_profile":          "source_profile=default",
+			"sso_start_url":           "sso_start_url=https://example.awsapps.com/start",
+			"line without value":      "credential_process",
+			"carriage return":         "aws_session_token=token\rcredential_process=/bin/sh -c exit",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := awsprofile.CreateAWSProfileFromCredentialsFile(t.TempDir(), "team-a", credentials+setting+"\n")
+				assertEquals(t, true, errors.Is(err, awsprofile.ErrInvalidCredentials))
+			})
+		}
+	})
+}
+
+func TestCreatingAWSProfileWithCredentialProcess(t *testing.T) {
+	t.Run("create config with credential process", func(t *testing.T) {
+		profile, err := awsprofile.CreateAWSProfileWithCredentialProcess(t.TempDir(), "cat /var/lib/kubelet/plugins/s3.csi.aws.com/pod-vol.credentials.json")
+		assertNoError(t, err)
+
+		sharedConfig, err := config.LoadSharedConfigProfile(context.Background(), profile.Name, func(c *config.LoadSharedConfigOptions) {
+			c.ConfigFiles = []string{profile.ConfigPath}
+			c.CredentialsFiles = []string{profile.CredentialsPath}
+		})
+		assertNoError(t, err)
+		assertEquals(t, "cat /var/lib/kubelet/plugins/s3.csi.aws.com/pod-vol.credentials.json", sharedConfig.CredentialProcess)
+	})
+
+	t.Run("fail if process contains non-printable characters", func(t *testing.T) {
+		_, err := awsprofile.CreateAWSProfileWithCredentialProcess(t.TempDir(), "cat /path\n[profile other]")
+		assertEquals(t, true, errors.Is(err, awsprofile.ErrInvalidCredentials))
+	})
+}
+
 func TestCleaningUpAWSProfile(t *testing.T) {
 	t.Run("clean config and credentials files", func(t *testing.T) {
 		basepath := t.TempDir()
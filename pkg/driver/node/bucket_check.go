@@ -0,0 +1,65 @@
+package node
+
+import (
+	"cmp"
+	"context"
+
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketcheck"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// A BucketChecker checks buckets are accessible before mounting them, see [bucketcheck.Checker].
+type BucketChecker interface {
+	CheckBucket(ctx context.Context, input bucketcheck.Input) error
+}
+
+// checkBucket checks `bucket` is accessible with given mount `credentials` and `args` if a [BucketChecker] is configured,
+// and returns a gRPC status error describing the problem otherwise, e.g. `PermissionDenied` for missing permissions.
+//
+// Volumes already published at `target` are not checked again when kubelet republishes them, and volumes are not checked
+// if the CSI Driver cannot use their credentials itself or the region of the bucket is not known.
+func (ns *S3NodeServer) checkBucket(ctx context.Context, volumeID, target, bucket string, volumeCtx map[string]string, credentials *mounter.MountCredentials, args mountpoint.Args) error {
+	if ns.BucketChecker == nil {
+		return nil
+	}
+	if _, ok := ns.publishedVolumes.get(target); ok {
+		return nil
+	}
+
+	sdkCredentials, ok := ns.credentialProvider.SDKCredentials(volumeID, volumeCtx, credentials)
+	if !ok {
+		klog.V(4).Infof("NodePublishVolume: skipping pre-flight check of bucket %s, its credentials cannot be used by the CSI Driver", bucket)
+		return nil
+	}
+
+	region, ok := args.Value(mountpoint.ArgRegion)
+	if !ok {
+		region = cmp.Or(credentials.Region, credentials.DefaultRegion, envprovider.Region())
+	}
+	if region == "" {
+		klog.V(4).Infof("NodePublishVolume: skipping pre-flight check of bucket %s, its region is not known", bucket)
+		return nil
+	}
+
+	prefix, _ := args.Value(mountpoint.ArgPrefix)
+	endpoint, _ := args.Value(mountpoint.ArgEndpointURL)
+	identity := ""
+	if roleARN := roleARNOf(volumeCtx, credentials); roleARN != "" {
+		identity = "role " + roleARN
+	}
+
+	return ns.BucketChecker.CheckBucket(ctx, bucketcheck.Input{
+		Bucket:         bucket,
+		Prefix:         prefix,
+		Region:         region,
+		Endpoint:       endpoint,
+		ForcePathStyle: args.Has(mountpoint.ArgForcePathStyle),
+		Credentials:    sdkCredentials,
+		Identity:       identity,
+		HTTPClient:     mounter.HTTPClientFor(volumeCtx),
+	})
+}
@@ -0,0 +1,134 @@
+// Package bucketcheck provides pre-flight checks of S3 buckets before mounting them.
+package bucketcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// DefaultTimeout is the default timeout of checking a bucket.
+const DefaultTimeout = 10 * time.Second
+
+// An Input represents a bucket to check and how to access it, which should be the same as the mount.
+type Input struct {
+	Bucket string
+	// Prefix is optional, and the prefix of the bucket the volume is scoped to.
+	Prefix string
+	Region string
+	// Endpoint is optional, and the URL of an S3-compatible endpoint.
+	Endpoint       string
+	ForcePathStyle bool
+	// Credentials to access the bucket with, the default credentials chain of the CSI Driver is used if nil.
+	Credentials aws.CredentialsProvider
+	// Identity is optional, and a description of the credentials (e.g., the IAM role) used in error messages.
+	Identity string
+	// HTTPClient is optional, and the HTTP client to send requests with, e.g. through the proxy of the volume.
+	HTTPClient aws.HTTPClient
+}
+
+// A Checker checks that buckets exist and are accessible with the credentials of a mount,
+// so misconfigurations fail with actionable errors instead of exit codes of Mountpoint.
+//
+// It sends a `HeadBucket` request to check the bucket exists, and a `ListObjectsV2` request for a single object
+// under the prefix of the volume to check the credentials are allowed to list it, which Mountpoint needs to mount it.
+// Errors other than a missing bucket or denied access, e.g. network errors, are inconclusive and ignored,
+// as Mountpoint reports them itself.
+type Checker struct {
+	timeout time.Duration
+}
+
+// NewChecker returns a new checker timing out after `timeout` for each bucket.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// CheckBucket checks the bucket in `input`, and returns a gRPC status error describing the problem if it's not accessible.
+func (c *Checker) CheckBucket(ctx context.Context, input Input) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.Region))
+	if err != nil {
+		klog.V(4).Infof("NodePublishVolume: skipping pre-flight check of bucket %s, could not load AWS config: %v", input.Bucket, err)
+		return nil
+	}
+	if input.Credentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(input.Credentials)
+	}
+	if input.HTTPClient != nil {
+		cfg.HTTPClient = input.HTTPClient
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if input.Endpoint != "" {
+			o.BaseEndpoint = aws.String(input.Endpoint)
+		}
+		o.UsePathStyle = input.ForcePathStyle
+	})
+
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(input.Bucket)})
+	switch {
+	case isStatus(err, http.StatusNotFound):
+		return status.Errorf(codes.NotFound, "Bucket %q not found, please check the bucket name and that it exists", input.Bucket)
+	case isStatus(err, http.StatusMovedPermanently):
+		return status.Errorf(codes.FailedPrecondition, "Bucket %q is not in region %s, please set the region of the bucket with `region` mount option", input.Bucket, input.Region)
+	case err != nil && !isStatus(err, http.StatusForbidden):
+		klog.V(4).Infof("NodePublishVolume: pre-flight check of bucket %s is inconclusive: %v", input.Bucket, err)
+		return nil
+	}
+
+	// `HeadBucket` might be denied if `s3:ListBucket` is only allowed for a prefix, so the prefix is listed regardless.
+	_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(input.Bucket),
+		Prefix:  aws.String(input.Prefix),
+		MaxKeys: aws.Int32(1),
+	})
+	switch {
+	case isStatus(err, http.StatusForbidden):
+		return status.Errorf(codes.PermissionDenied, "Access denied to bucket %q: missing s3:ListBucket permission%s%s", input.Bucket, onPrefix(input.Prefix), forIdentity(input.Identity))
+	case isStatus(err, http.StatusNotFound):
+		return status.Errorf(codes.NotFound, "Bucket %q not found, please check the bucket name and that it exists", input.Bucket)
+	case err != nil:
+		klog.V(4).Infof("NodePublishVolume: pre-flight check of bucket %s is inconclusive: %v", input.Bucket, err)
+	}
+	return nil
+}
+
+// isStatus returns whether `err` is an error response from S3 with given HTTP status `code`.
+func isStatus(err error, code int) bool {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() == code
+	}
+	var apiErr smithy.APIError
+	if code == http.StatusNotFound && errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "NoSuchBucket" || apiErr.ErrorCode() == "NotFound"
+	}
+	return false
+}
+
+func onPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return fmt.Sprintf(" on prefix %q", prefix)
+}
+
+func forIdentity(identity string) string {
+	if identity == "" {
+		return ""
+	}
+	return " for " + identity
+}
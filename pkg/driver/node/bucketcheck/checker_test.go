@@ -0,0 +1,72 @@
+package bucketcheck_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketcheck"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+const emptyListResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><KeyCount>0</KeyCount><MaxKeys>1</MaxKeys><IsTruncated>false</IsTruncated></ListBucketResult>`
+
+const accessDeniedResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`
+
+func TestCheckingBuckets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket := strings.TrimPrefix(r.URL.Path, "/")
+		isList := r.Method == http.MethodGet
+		switch {
+		case bucket == "missing-bucket":
+			w.WriteHeader(http.StatusNotFound)
+		case bucket == "denied-bucket", bucket == "prefix-only-bucket" && (!isList || r.URL.Query().Get("prefix") != "team-a/"):
+			w.WriteHeader(http.StatusForbidden)
+			if isList {
+				w.Write([]byte(accessDeniedResponse))
+			}
+		case bucket == "unavailable-bucket":
+			w.WriteHeader(http.StatusNotImplemented)
+		case isList:
+			w.Write([]byte(emptyListResponse))
+		}
+	}))
+	defer server.Close()
+
+	checker := bucketcheck.NewChecker(5 * time.Second)
+	check := func(bucket, prefix string) error {
+		return checker.CheckBucket(context.Background(), bucketcheck.Input{
+			Bucket:         bucket,
+			Prefix:         prefix,
+			Region:         "us-east-1",
+			Endpoint:       server.URL,
+			ForcePathStyle: true,
+			Credentials:    credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+			Identity:       "role arn:aws:iam::111122223333:role/test-role",
+		})
+	}
+
+	assert.NoError(t, check("existing-bucket", ""))
+	assert.NoError(t, check("prefix-only-bucket", "team-a/"))
+	assert.NoError(t, check("unavailable-bucket", ""))
+
+	err := check("missing-bucket", "")
+	assert.Equals(t, codes.NotFound, status.Code(err))
+
+	err = check("denied-bucket", "")
+	assert.Equals(t, codes.PermissionDenied, status.Code(err))
+	assert.Equals(t, `Access denied to bucket "denied-bucket": missing s3:ListBucket permission for role arn:aws:iam::111122223333:role/test-role`, status.Convert(err).Message())
+
+	err = check("prefix-only-bucket", "team-b/")
+	assert.Equals(t, codes.PermissionDenied, status.Code(err))
+	assert.Equals(t, `Access denied to bucket "prefix-only-bucket": missing s3:ListBucket permission on prefix "team-b/" for role arn:aws:iam::111122223333:role/test-role`, status.Convert(err).Message())
+}
@@ -0,0 +1,139 @@
+// Package bucketregion provides utilities for detecting AWS Regions of S3 buckets.
+package bucketregion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultCacheTTL is the duration detected bucket regions are cached for.
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultTimeout is the default timeout of detecting the region of a bucket.
+const DefaultTimeout = 5 * time.Second
+
+// headerBucketRegion is the header S3 returns the region of the bucket in.
+const headerBucketRegion = "X-Amz-Bucket-Region"
+
+// An Input represents a bucket to detect the region of and how to access it, which should be the same as the mount.
+type Input struct {
+	Bucket string
+	// Endpoint is optional, and the URL of the endpoint of the volume. The regional S3 endpoint of the node is used if empty.
+	Endpoint       string
+	ForcePathStyle bool
+	// Credentials to sign the request with, the default credentials chain of the CSI Driver is used if nil.
+	Credentials aws.CredentialsProvider
+	// HTTPClient is optional, and the HTTP client to send requests with, e.g. through the proxy of the volume.
+	HTTPClient aws.HTTPClient
+}
+
+// A Detector detects regions of S3 buckets and caches them for a short duration.
+//
+// It sends a `HeadBucket` request signed with the credentials of the volume to the S3 endpoint of the node's region,
+// whose partition the bucket must be in, or to the endpoint of the volume. S3 responds with the region of the bucket
+// in `x-amz-bucket-region` header regardless of whether the request is authorized or redirected to another region.
+type Detector struct {
+	nodeRegion func() (string, error)
+	ttl        time.Duration
+	timeout    time.Duration
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedRegion
+}
+
+type cachedRegion struct {
+	region    string
+	expiresAt time.Time
+}
+
+// NewDetector returns a new detector sending requests in the region returned by `nodeRegion`, e.g. the region of the
+// node from IMDS, and caching regions for `ttl`.
+func NewDetector(nodeRegion func() (string, error), ttl time.Duration) *Detector {
+	return &Detector{
+		nodeRegion: nodeRegion,
+		ttl:        ttl,
+		timeout:    DefaultTimeout,
+		now:        time.Now,
+		cache:      make(map[string]cachedRegion),
+	}
+}
+
+// Region returns the region of the bucket in `input`.
+func (d *Detector) Region(ctx context.Context, input Input) (string, error) {
+	cacheKey := input.Endpoint + "/" + input.Bucket
+	if region, ok := d.cached(cacheKey); ok {
+		return region, nil
+	}
+
+	nodeRegion, err := d.nodeRegion()
+	if nodeRegion == "" {
+		return "", fmt.Errorf("failed to detect region of bucket %q: region of the node is not known: %v", input.Bucket, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(nodeRegion))
+	if err != nil {
+		return "", fmt.Errorf("failed to detect region of bucket %q: could not load AWS config: %w", input.Bucket, err)
+	}
+	if input.Credentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(input.Credentials)
+	}
+	if input.HTTPClient != nil {
+		cfg.HTTPClient = input.HTTPClient
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if input.Endpoint != "" {
+			o.BaseEndpoint = aws.String(input.Endpoint)
+		}
+		o.UsePathStyle = input.ForcePathStyle
+		// The region is in the response of the first attempt, even if the request fails.
+		o.RetryMaxAttempts = 1
+	})
+
+	var region string
+	out, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(input.Bucket)})
+	if err == nil {
+		region = aws.ToString(out.BucketRegion)
+	} else {
+		var respErr *awshttp.ResponseError
+		if !errors.As(err, &respErr) {
+			return "", fmt.Errorf("failed to detect region of bucket %q: %w", input.Bucket, err)
+		}
+		region = respErr.Response.Header.Get(headerBucketRegion)
+	}
+	if region == "" {
+		return "", fmt.Errorf("failed to detect region of bucket %q: no region in response: %v", input.Bucket, err)
+	}
+
+	d.mu.Lock()
+	d.cache[cacheKey] = cachedRegion{region: region, expiresAt: d.now().Add(d.ttl)}
+	d.mu.Unlock()
+	return region, nil
+}
+
+func (d *Detector) cached(key string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.cache[key]
+	if !ok {
+		return "", false
+	}
+	if d.now().After(entry.expiresAt) {
+		delete(d.cache, key)
+		return "", false
+	}
+	return entry.region, true
+}
@@ -0,0 +1,110 @@
+package bucketregion_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketregion"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestDetectingBucketRegion(t *testing.T) {
+	requests := 0
+	unsigned := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=test-access-key/") {
+			unsigned++
+		}
+		switch r.URL.Path {
+		case "/us-bucket":
+			w.Header().Set("X-Amz-Bucket-Region", "us-east-1")
+			w.WriteHeader(http.StatusForbidden)
+		case "/eu-bucket":
+			w.Header().Set("X-Amz-Bucket-Region", "eu-west-1")
+			w.Header().Set("Location", "https://eu-bucket.s3.eu-west-1.amazonaws.com/")
+			w.WriteHeader(http.StatusMovedPermanently)
+		case "/ap-bucket":
+			w.Header().Set("X-Amz-Bucket-Region", "ap-southeast-2")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	nodeRegion := func() (string, error) { return "us-east-1", nil }
+	input := func(bucket string) bucketregion.Input {
+		return bucketregion.Input{
+			Bucket:         bucket,
+			Endpoint:       server.URL,
+			ForcePathStyle: true,
+			Credentials:    credentials.NewStaticCredentialsProvider("test-access-key", "test-secret-key", ""),
+		}
+	}
+
+	t.Run("detects region from response header with signed requests", func(t *testing.T) {
+		requests, unsigned = 0, 0
+		detector := bucketregion.NewDetector(nodeRegion, time.Minute)
+
+		for bucket, want := range map[string]string{
+			"us-bucket": "us-east-1",
+			"eu-bucket": "eu-west-1",
+			"ap-bucket": "ap-southeast-2",
+		} {
+			region, err := detector.Region(context.Background(), input(bucket))
+			assert.NoError(t, err)
+			assert.Equals(t, want, region)
+		}
+
+		assert.Equals(t, 3, requests)
+		assert.Equals(t, 0, unsigned)
+	})
+
+	t.Run("caches detected regions", func(t *testing.T) {
+		requests = 0
+		detector := bucketregion.NewDetector(nodeRegion, time.Minute)
+
+		for range 3 {
+			region, err := detector.Region(context.Background(), input("eu-bucket"))
+			assert.NoError(t, err)
+			assert.Equals(t, "eu-west-1", region)
+		}
+		assert.Equals(t, 1, requests)
+	})
+
+	t.Run("detects region again after cache expires", func(t *testing.T) {
+		requests = 0
+		detector := bucketregion.NewDetector(nodeRegion, time.Nanosecond)
+
+		for range 2 {
+			_, err := detector.Region(context.Background(), input("eu-bucket"))
+			assert.NoError(t, err)
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equals(t, 2, requests)
+	})
+
+	t.Run("fails for non-existent buckets", func(t *testing.T) {
+		detector := bucketregion.NewDetector(nodeRegion, time.Minute)
+		if _, err := detector.Region(context.Background(), input("non-existent-bucket")); err == nil {
+			t.Fatal("Expected an error for non-existent bucket")
+		}
+	})
+
+	t.Run("fails without sending requests if region of the node is not known", func(t *testing.T) {
+		requests = 0
+		detector := bucketregion.NewDetector(func() (string, error) { return "", errors.New("IMDS is not available") }, time.Minute)
+		if _, err := detector.Region(context.Background(), input("eu-bucket")); err == nil {
+			t.Fatal("Expected an error if region of the node is not known")
+		}
+		assert.Equals(t, 0, requests)
+	})
+}
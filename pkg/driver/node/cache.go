@@ -0,0 +1,113 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// Special values of `metadataTTL` volume attribute supported by Mountpoint.
+const (
+	metadataTTLIndefinite = "indefinite"
+	metadataTTLMinimal    = "minimal"
+)
+
+// cacheSizeHeadroomPercent is the percentage of `cacheDirSizeLimit` left unused by Mountpoint's cache, as Mountpoint
+// might exceed `--max-cache-size` briefly before evicting, and exceeding the size limit of an `emptyDir` cache
+// gets the Pod evicted.
+const cacheSizeHeadroomPercent = 5
+
+// setCacheArgs validates and translates cache configuration passed via volume context to Mountpoint arguments.
+//
+// If a cache is configured with `cacheType` or `cachePersistentVolumeClaim`, Mountpoint uses a local cache directory
+// next to `target`, optionally limited by `cacheDirSizeLimit`. The claim is only mounted into Mountpoint Pods, whose cache
+// directory is replaced by `aws-s3-csi-mounter`.
+//
+// `cacheType: ephemeral` and `cacheStorageClassName` are rejected, as they need a volume mounted into Mountpoint Pods
+// and can't be honoured by Mountpoint spawned by the node plugin.
+func setCacheArgs(target string, volumeCtx map[string]string, args *mountpoint.Args) error {
+	if ttl, ok := volumeCtx[volumecontext.MetadataTTL]; ok {
+		if ttl != metadataTTLIndefinite && ttl != metadataTTLMinimal {
+			if _, err := strconv.ParseUint(ttl, 10, 64); err != nil {
+				return status.Errorf(codes.InvalidArgument, "Metadata TTL %q must be a number of seconds, %q or %q", ttl, metadataTTLIndefinite, metadataTTLMinimal)
+			}
+		}
+		args.Set(mountpoint.ArgMetadataTTL, ttl)
+	}
+
+	if storageClass, ok := volumeCtx[volumecontext.CacheStorageClass]; ok {
+		return status.Errorf(codes.InvalidArgument, "Cache storage class %q is only supported by Mountpoint Pods, use %q %q instead",
+			storageClass, volumecontext.CacheType, volumecontext.CacheTypeEmptyDir)
+	}
+
+	cacheType, hasCacheType := volumeCtx[volumecontext.CacheType]
+	claimName, hasClaim := volumeCtx[volumecontext.CachePVC]
+	sizeLimit, hasSizeLimit := volumeCtx[volumecontext.CacheDirSizeLimit]
+	if !hasCacheType && !hasClaim {
+		if hasSizeLimit {
+			return status.Errorf(codes.InvalidArgument, "Cache size limit requires %q or %q volume attribute", volumecontext.CacheType, volumecontext.CachePVC)
+		}
+		return nil
+	}
+
+	if hasCacheType && hasClaim {
+		return status.Errorf(codes.InvalidArgument, "Only one of %q and %q can be specified", volumecontext.CacheType, volumecontext.CachePVC)
+	}
+
+	if hasClaim {
+		if errs := validation.IsDNS1123Subdomain(claimName); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "Invalid cache PersistentVolumeClaim name %q: %s", claimName, strings.Join(errs, "; "))
+		}
+	} else if cacheType == volumecontext.CacheTypeEphemeral {
+		return status.Errorf(codes.InvalidArgument, "Cache type %q is only supported by Mountpoint Pods, use %q instead",
+			cacheType, volumecontext.CacheTypeEmptyDir)
+	} else if cacheType != volumecontext.CacheTypeEmptyDir {
+		return status.Errorf(codes.InvalidArgument, "Unsupported cache type %q, supported cache type is %q",
+			cacheType, volumecontext.CacheTypeEmptyDir)
+	}
+
+	if hasSizeLimit {
+		maxCacheSizeMiB, err := parseCacheSizeLimit(sizeLimit)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid cache size limit %q: %v", sizeLimit, err)
+		}
+		args.Set(mountpoint.ArgMaxCacheSize, strconv.FormatInt(maxCacheSizeMiB, 10))
+	}
+
+	if existing, ok := args.Value(mountpoint.ArgCache); ok {
+		klog.Warningf("NodePublishVolume: cache directory %q from mount options is overridden by cache volume attributes", existing)
+	}
+
+	cacheDir := mounter.CacheDir(target)
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return status.Errorf(codes.Internal, "Could not create cache directory %q: %v", cacheDir, err)
+	}
+	args.Set(mountpoint.ArgCache, cacheDir)
+
+	return nil
+}
+
+// parseCacheSizeLimit parses given size limit in Kubernetes quantity format (e.g., "10Gi") and returns the maximum
+// size of Mountpoint's cache in MiB, leaving [cacheSizeHeadroomPercent] of the limit unused.
+func parseCacheSizeLimit(sizeLimit string) (int64, error) {
+	quantity, err := resource.ParseQuantity(sizeLimit)
+	if err != nil {
+		return 0, err
+	}
+	sizeLimitMiB := quantity.Value() / (1024 * 1024)
+	if sizeLimitMiB < 1 {
+		return 0, fmt.Errorf("must be at least 1Mi")
+	}
+	return max(sizeLimitMiB-sizeLimitMiB*cacheSizeHeadroomPercent/100, 1), nil
+}
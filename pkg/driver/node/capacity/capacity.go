@@ -0,0 +1,30 @@
+// Package capacity provides the annotations the node plugin advertises its Mountpoint capacity with on its Node object,
+// if the number of Mountpoint processes in the node is limited.
+package capacity
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationMaxMountpoints is the maximum number of Mountpoint processes the node plugin runs in the node.
+	AnnotationMaxMountpoints = "s3.csi.aws.com/max-mountpoints"
+	// AnnotationActiveMountpoints is the number of Mountpoint processes running in the node.
+	AnnotationActiveMountpoints = "s3.csi.aws.com/active-mountpoints"
+)
+
+// Spare returns the number of Mountpoint processes that can still be spawned in `node`,
+// or false if its capacity is not advertised, i.e. Mountpoint processes are not limited.
+func Spare(node *corev1.Node) (int, bool) {
+	maxMountpoints, err := strconv.Atoi(node.Annotations[AnnotationMaxMountpoints])
+	if err != nil || maxMountpoints <= 0 {
+		return 0, false
+	}
+	active, err := strconv.Atoi(node.Annotations[AnnotationActiveMountpoints])
+	if err != nil {
+		return 0, false
+	}
+	return max(maxMountpoints-active, 0), true
+}
@@ -0,0 +1,42 @@
+package capacity_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/capacity"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestSpare(t *testing.T) {
+	for name, test := range map[string]struct {
+		annotations map[string]string
+		spare       int
+		advertised  bool
+	}{
+		"not advertised": {},
+		"not limited": {
+			annotations: map[string]string{capacity.AnnotationMaxMountpoints: "0", capacity.AnnotationActiveMountpoints: "3"},
+		},
+		"invalid": {
+			annotations: map[string]string{capacity.AnnotationMaxMountpoints: "ten", capacity.AnnotationActiveMountpoints: "3"},
+		},
+		"spare capacity": {
+			annotations: map[string]string{capacity.AnnotationMaxMountpoints: "10", capacity.AnnotationActiveMountpoints: "3"},
+			spare:       7,
+			advertised:  true,
+		},
+		"over capacity": {
+			annotations: map[string]string{capacity.AnnotationMaxMountpoints: "10", capacity.AnnotationActiveMountpoints: "12"},
+			advertised:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spare, advertised := capacity.Spare(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}})
+			assert.Equals(t, test.spare, spare)
+			assert.Equals(t, test.advertised, advertised)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// A compositeEntry represents a bucket mounted at a directory under the target path of a composite volume.
+type compositeEntry struct {
+	BucketName string `json:"bucketName"`
+	// Prefix is optional, and scopes the mount to a prefix in the bucket.
+	Prefix string `json:"prefix,omitempty"`
+	// Path is the name of the directory under the target path to mount the bucket at.
+	Path string `json:"path"`
+}
+
+// parseCompositeEntries parses `buckets` volume attribute of a composite volume,
+// a JSON array of buckets to mount under the target path, e.g.
+// `[{"bucketName": "images", "path": "images"}, {"bucketName": "labels", "prefix": "2024/", "path": "labels"}]`.
+// It returns nil if the volume is not a composite volume.
+func parseCompositeEntries(volumeCtx map[string]string) ([]compositeEntry, error) {
+	attr, ok := volumeCtx[volumecontext.Buckets]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := volumeCtx[volumecontext.BucketName]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Only one of %q and %q can be specified", volumecontext.BucketName, volumecontext.Buckets)
+	}
+	if _, ok := volumeCtx[volumecontext.Prefix]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%q cannot be used with %q, specify a prefix per bucket instead", volumecontext.Prefix, volumecontext.Buckets)
+	}
+
+	var entries []compositeEntry
+	if err := json.Unmarshal([]byte(attr), &entries); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %q: %v", volumecontext.Buckets, err)
+	}
+	if len(entries) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%q must contain at least one bucket", volumecontext.Buckets)
+	}
+
+	paths := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.BucketName == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "Bucket name not provided for path %q in %q", entry.Path, volumecontext.Buckets)
+		}
+		if entry.Path == "" || entry.Path == "." || entry.Path == ".." || strings.ContainsRune(entry.Path, '/') {
+			return nil, status.Errorf(codes.InvalidArgument, "Path %q of bucket %q must be a single directory name", entry.Path, entry.BucketName)
+		}
+		if paths[entry.Path] {
+			return nil, status.Errorf(codes.InvalidArgument, "Path %q is used by multiple buckets", entry.Path)
+		}
+		paths[entry.Path] = true
+		if entry.Prefix != "" && !strings.HasSuffix(entry.Prefix, "/") {
+			return nil, status.Errorf(codes.InvalidArgument, "Prefix %q of bucket %q must end with \"/\"", entry.Prefix, entry.BucketName)
+		}
+	}
+	return entries, nil
+}
+
+// publishComposite mounts each bucket of a composite volume at a directory under `target`, with the common `args`.
+//
+// Each bucket is served by its own Mountpoint process and tracked as a separate published volume,
+// so their health is monitored independently. If any bucket fails to mount, the buckets mounted so far are unmounted.
+func (ns *S3NodeServer) publishComposite(ctx context.Context, req *csi.NodePublishVolumeRequest, entries []compositeEntry, args mountpoint.Args, retryPolicy MountRetryPolicy) (*csi.NodePublishVolumeResponse, error) {
+	volumeID, target, volumeCtx := req.GetVolumeId(), req.GetTargetPath(), req.GetVolumeContext()
+	eventVol := publishedVolume{volumeID: volumeID, volumeCtx: volumeCtx}
+
+	credentials, err := ns.provideCredentials(ctx, volumeID, volumeCtx, req.GetSecrets(), args)
+	if err != nil {
+		klog.Errorf("NodePublishVolume: failed to provide credentials: %v", err)
+		ns.recordCredentialsFailed(eventVol, err)
+		return nil, err
+	}
+	ns.recordEvent(eventVol, corev1.EventTypeNormal, EventReasonCredentialsResolved,
+		"Resolved credentials for volume %s (authentication source: %s)", volumeID, authenticationSourceLabel(volumeCtx))
+
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
+
+	roleARN := roleARNOf(volumeCtx, credentials)
+
+	var mounted []string
+	for _, entry := range entries {
+		entryTarget := filepath.Join(target, entry.Path)
+
+		// Each Mountpoint process needs its own arguments, e.g., for its own cache directory and the region of its bucket.
+		entryArgs := mountpoint.ParseArgs(args.SortedList())
+		prefix, err := expandPrefix(entry.Prefix, volumeCtx)
+		if prefix != "" {
+			entryArgs.Set(mountpoint.ArgPrefix, prefix)
+		}
+		if err == nil {
+			err = setCacheArgs(entryTarget, volumeCtx, &entryArgs)
+		}
+		if err == nil {
+			err = ns.applyMountOptionsPolicy(volumeID, volumeCtx, &entryArgs)
+		}
+		if err == nil {
+			err = setDriverArgs(entry.BucketName, req.GetVolumeCapability(), volumeCtx, &entryArgs)
+		}
+		if err == nil {
+			ns.setDetectedRegion(ctx, volumeID, entry.BucketName, volumeCtx, credentials, &entryArgs)
+			err = ns.checkBucket(ctx, volumeID, entryTarget, entry.BucketName, volumeCtx, credentials, entryArgs)
+		}
+		if err != nil {
+			ns.unmountComposite(ctx, volumeID, target)
+			return nil, err
+		}
+
+		klog.V(4).InfoS("NodePublishVolume: mounting bucket of composite volume", "bucket", entry.BucketName, "options", entryArgs.SortedList(),
+			logging.KeyVolumeID, volumeID, logging.KeyPodUID, volumeCtx[volumecontext.CSIPodUID], logging.KeyTargetPath, entryTarget)
+
+		err = retryPolicy.withRetries(volumeID, entryTarget, func() error {
+			return ns.Mounter.Mount(entry.BucketName, entryTarget, credentials, entryArgs)
+		})
+		if err != nil {
+			mountFailuresTotal.Inc()
+			ns.recordEvent(eventVol, corev1.EventTypeWarning, EventReasonMountFailed,
+				"Could not mount bucket %s for volume %s: %v", entry.BucketName, volumeID, err)
+			ns.unmountComposite(ctx, volumeID, target)
+			return nil, status.Errorf(codes.Internal, "Could not mount %q at %q: %v", entry.BucketName, entryTarget, err)
+		}
+
+		entryVol := publishedVolume{
+			volumeID:  volumeID,
+			bucket:    entry.BucketName,
+			volumeCtx: volumeCtx,
+			secrets:   req.GetSecrets(),
+			args:      entryArgs.SortedList(),
+			roleARN:   roleARN,
+		}
+		ns.publishedVolumes.add(entryTarget, entryVol)
+		audit(auditActionAttach, entryTarget, entryVol)
+		eventVol = entryVol
+		mounted = append(mounted, entry.BucketName)
+	}
+
+	klog.V(4).InfoS("NodePublishVolume: mounted composite volume", "buckets", mounted,
+		logging.KeyVolumeID, volumeID, logging.KeyPodUID, volumeCtx[volumecontext.CSIPodUID], logging.KeyTargetPath, target)
+	ns.recordEvent(eventVol, corev1.EventTypeNormal, EventReasonMounted, "Mounted buckets %s for volume %s with %s",
+		strings.Join(mounted, ", "), volumeID, eventVol.accessSummary())
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// unmountComposite unmounts buckets of a composite volume mounted at directories under `target`, and removes the directories,
+// as kubelet only removes `target` if it's empty. It's a no-op for other volumes as `target` is empty once they're unmounted.
+//
+// It returns one of the published volumes of the buckets if there is any, and the first error while still trying to
+// unmount the rest of the buckets.
+func (ns *S3NodeServer) unmountComposite(ctx context.Context, volumeID, target string) (publishedVolume, bool, error) {
+	var (
+		unmountedVol publishedVolume
+		published    bool
+	)
+
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return unmountedVol, published, nil
+		}
+		return unmountedVol, published, status.Errorf(codes.Internal, "Could not read target path %q: %v", target, err)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	// Buckets are unmounted concurrently, as each of them might wait for its grace period.
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		entryTarget := filepath.Join(target, entry.Name())
+		vol, ok := ns.publishedVolumes.get(entryTarget)
+		gracePeriod := ns.UnmountGracePeriod
+		if ok {
+			unmountedVol, published = vol, true
+			if value, err := ns.unmountGracePeriodFor(vol.volumeCtx); err == nil {
+				gracePeriod = value
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ns.unmountIfMounted(ctx, "NodeUnpublishVolume", volumeID, entryTarget, gracePeriod); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			ns.publishedVolumes.remove(entryTarget)
+			if ok {
+				audit(auditActionDetach, entryTarget, vol)
+			}
+			if err := os.Remove(entryTarget); err != nil && !os.IsNotExist(err) {
+				klog.V(4).Infof("NodeUnpublishVolume: failed to remove %s: %v", entryTarget, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return unmountedVol, published, firstErr
+}
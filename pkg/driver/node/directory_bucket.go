@@ -0,0 +1,50 @@
+package node
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// expressOneZoneStorageClass is the only storage class supported by S3 Express One Zone directory buckets.
+const expressOneZoneStorageClass = "EXPRESS_ONEZONE"
+
+// isDirectoryBucket returns whether `bucket` is an S3 Express One Zone directory bucket,
+// either by its name or by `bucketType` volume attribute.
+func isDirectoryBucket(bucket string, volumeCtx map[string]string) (bool, error) {
+	switch bucketType := volumeCtx[volumecontext.BucketType]; bucketType {
+	case "":
+		return strings.HasSuffix(bucket, topology.DirectoryBucketSuffix), nil
+	case volumecontext.BucketTypeDirectory:
+		return true, nil
+	case volumecontext.BucketTypeGeneralPurpose:
+		return false, nil
+	default:
+		return false, status.Errorf(codes.InvalidArgument, "Unsupported bucket type %q, supported bucket types are %q and %q",
+			bucketType, volumecontext.BucketTypeGeneralPurpose, volumecontext.BucketTypeDirectory)
+	}
+}
+
+// setDirectoryBucketArgs rejects mount options directory buckets do not support,
+// and enables incremental uploads for writable volumes to allow appending to existing objects.
+func setDirectoryBucketArgs(args *mountpoint.Args) error {
+	for _, arg := range []mountpoint.ArgKey{mountpoint.ArgForcePathStyle, mountpoint.ArgTransferAcceleration} {
+		if args.Has(arg) {
+			return status.Errorf(codes.InvalidArgument, "Mount option %q is not supported by directory buckets", arg)
+		}
+	}
+	if storageClass, ok := args.Value(mountpoint.ArgStorageClass); ok && storageClass != expressOneZoneStorageClass {
+		return status.Errorf(codes.InvalidArgument, "Storage class %q is not supported by directory buckets, only %q is supported",
+			storageClass, expressOneZoneStorageClass)
+	}
+
+	if !args.Has(mountpoint.ArgReadOnly) {
+		args.Set(mountpoint.ArgIncrementalUpload, mountpoint.ArgNoValue)
+	}
+	return nil
+}
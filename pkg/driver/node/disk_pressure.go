@@ -0,0 +1,126 @@
+package node
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// DiskPressureCheckInterval is the interval to check whether this node is under disk pressure.
+const DiskPressureCheckInterval = 30 * time.Second
+
+// EventReasonCacheReclaimed is the reason of the event emitted to workload Pods whose local caches are purged
+// as this node is under disk pressure.
+const EventReasonCacheReclaimed = "MountpointCacheReclaimed"
+
+// WatchDiskPressure periodically checks the `DiskPressure` condition of this node with given `interval`, and purges
+// local caches of published volumes while it's under disk pressure. It's a no-op if [S3NodeServer.Nodes] is not set.
+//
+// Local caches of Mountpoint are created next to target paths in the kubelet directory, usually on the root volume
+// of the node, but they're not accounted as ephemeral storage of any Pod, so kubelet can't reclaim them by evicting
+// Pods. Mountpoint treats cache blocks removed underneath it as cache misses, so purging them is safe while it's running.
+//
+// It blocks until `ctx` is cancelled.
+func (ns *S3NodeServer) WatchDiskPressure(ctx context.Context, interval time.Duration) {
+	if ns.Nodes == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if ns.underDiskPressure(ctx) {
+			ns.purgeCaches()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// underDiskPressure returns whether this node reports `DiskPressure` condition.
+// Failures to get the node are only logged, as the node is assumed to be healthy then.
+func (ns *S3NodeServer) underDiskPressure(ctx context.Context) bool {
+	node, err := ns.Nodes.Get(ctx, ns.NodeID, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Failed to get node %s to check disk pressure: %v", ns.NodeID, err)
+		return false
+	}
+	return slices.ContainsFunc(node.Status.Conditions, func(c corev1.NodeCondition) bool {
+		return c.Type == corev1.NodeDiskPressure && c.Status == corev1.ConditionTrue
+	})
+}
+
+// purgeCaches removes cache blocks from local cache directories of all published volumes, and emits an event
+// with the reclaimed bytes to the workload Pod using each of them. Cache directories shared by staged volumes are purged once.
+func (ns *S3NodeServer) purgeCaches() {
+	purged := map[string]bool{}
+	for _, vol := range ns.publishedVolumes.snapshot() {
+		args := mountpoint.ParseArgs(vol.args)
+		cacheDir, ok := args.Value(mountpoint.ArgCache)
+		if !ok || purged[cacheDir] {
+			continue
+		}
+		purged[cacheDir] = true
+
+		reclaimed, err := purgeCacheDir(cacheDir)
+		if err != nil {
+			klog.Errorf("Failed to purge cache directory %s of volume %s under disk pressure: %v", cacheDir, vol.volumeID, err)
+		}
+		if reclaimed == 0 {
+			continue
+		}
+
+		cacheReclaimedBytesTotal.Add(float64(reclaimed))
+		klog.Infof("Purged %d bytes from cache directory %s of volume %s under disk pressure", reclaimed, cacheDir, vol.volumeID)
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonCacheReclaimed,
+			"Purged %s of local cache of volume %s as node %s is under disk pressure", resource.NewQuantity(reclaimed, resource.BinarySI), vol.volumeID, ns.NodeID)
+	}
+}
+
+// purgeCacheDir removes all files under `cacheDir` and returns their total size. Directories are kept for Mountpoint
+// to write new cache blocks into. Files removed concurrently by Mountpoint's own eviction are skipped.
+func purgeCacheDir(cacheDir string) (int64, error) {
+	var reclaimed int64
+	err := filepath.WalkDir(cacheDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		reclaimed += info.Size()
+		return nil
+	})
+	return reclaimed, err
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strconv"
 )
 
 const (
@@ -22,6 +23,14 @@ const (
 	EnvSecretAccessKey       = "AWS_SECRET_ACCESS_KEY"
 	EnvSessionToken          = "AWS_SESSION_TOKEN"
 	EnvMountpointCacheKey    = "UNSTABLE_MOUNTPOINT_CACHE_KEY"
+	EnvUseFIPSEndpoint       = "AWS_USE_FIPS_ENDPOINT"
+	EnvUseDualStackEndpoint  = "AWS_USE_DUALSTACK_ENDPOINT"
+	// IMDS settings, e.g. `AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE=IPv6` to reach IMDS over IPv6 on IPv6-only nodes.
+	EnvEC2MetadataServiceEndpointMode = "AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE"
+	EnvEC2MetadataServiceEndpoint     = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	// FUSE settings of Mountpoint, see https://github.com/awslabs/mountpoint-s3/blob/main/doc/CONFIGURATION.md.
+	EnvFUSEMaxBackground       = "UNSTABLE_MOUNTPOINT_MAX_BACKGROUND"
+	EnvFUSECongestionThreshold = "UNSTABLE_MOUNTPOINT_CONGESTION_THRESHOLD"
 )
 
 // Key represents an environment variable name.
@@ -39,6 +48,8 @@ var envAllowlist = []Key{
 	EnvRegion,
 	EnvDefaultRegion,
 	EnvSTSRegionalEndpoints,
+	EnvEC2MetadataServiceEndpointMode,
+	EnvEC2MetadataServiceEndpoint,
 }
 
 // Region returns detected region from environment variables `AWS_REGION` or `AWS_DEFAULT_REGION`.
@@ -51,7 +62,14 @@ func Region() Value {
 	return os.Getenv(EnvDefaultRegion)
 }
 
-// Default returns list of environment variables to pass Mountpoint.
+// UseDualStackEndpoint returns whether dual-stack (IPv4 and IPv6) endpoints of AWS services are enabled
+// by default with `AWS_USE_DUALSTACK_ENDPOINT` environment variable.
+func UseDualStackEndpoint() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvUseDualStackEndpoint))
+	return enabled
+}
+
+// Default returns list of environment variables to pass Mountpoint, including the proxy settings of the CSI Driver.
 func Default() Environment {
 	environment := make(Environment)
 	for _, key := range envAllowlist {
@@ -60,6 +78,9 @@ func Default() Environment {
 			environment[key] = val
 		}
 	}
+	for key, val := range ProxyFromEnvironment() {
+		environment[key] = val
+	}
 	return environment
 }
 
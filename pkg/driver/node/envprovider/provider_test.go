@@ -1,6 +1,7 @@
 package envprovider_test
 
 import (
+	"net/url"
 	"testing"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
@@ -68,6 +69,26 @@ func TestProvidingDefaultEnvironmentVariables(t *testing.T) {
 				"AWS_STS_REGIONAL_ENDPOINTS=regional",
 			},
 		},
+		{
+			name: "IMDS endpoint mode",
+			env: map[string]string{
+				"AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE": "IPv6",
+			},
+			want: []string{
+				"AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE=IPv6",
+			},
+		},
+		{
+			name: "proxy",
+			env: map[string]string{
+				"https_proxy": "http://proxy.internal:3128",
+				"NO_PROXY":    "169.254.169.254",
+			},
+			want: []string{
+				"HTTPS_PROXY=http://proxy.internal:3128",
+				"NO_PROXY=169.254.169.254",
+			},
+		},
 		{
 			name: "additional env variables shouldn't be passed",
 			env: map[string]string{
@@ -219,3 +240,34 @@ func TestEnvironmentList(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyOfVolumes(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://driver-proxy:3128")
+	t.Setenv("NO_PROXY", "169.254.169.254")
+
+	proxy, err := envprovider.ProxyFor(map[string]string{"httpsProxy": "https://volume-proxy:8443", "noProxy": ".internal"})
+	assert.NoError(t, err)
+	assert.Equals(t, envprovider.Environment{"HTTPS_PROXY": "https://volume-proxy:8443", "NO_PROXY": ".internal"}, proxy)
+
+	proxyURL, err := proxy.ProxyFunc()(&url.URL{Scheme: "https", Host: "s3.us-east-1.amazonaws.com"})
+	assert.NoError(t, err)
+	assert.Equals(t, "https://volume-proxy:8443", proxyURL.String())
+
+	proxyURL, err = envprovider.Environment{}.ProxyFunc()(&url.URL{Scheme: "https", Host: "sts.us-east-1.amazonaws.com"})
+	assert.NoError(t, err)
+	assert.Equals(t, "http://driver-proxy:3128", proxyURL.String())
+
+	proxyURL, err = proxy.ProxyFunc()(&url.URL{Scheme: "https", Host: "s3.internal"})
+	assert.NoError(t, err)
+	assert.Equals(t, (*url.URL)(nil), proxyURL)
+
+	for _, attributes := range []map[string]string{
+		{"httpsProxy": "proxy.internal:3128"},
+		{"httpProxy": "socks5://proxy.internal:1080"},
+		{"httpProxy": "http://"},
+	} {
+		if _, err := envprovider.ProxyFor(attributes); err == nil {
+			t.Errorf("Expected proxy settings %v to be invalid", attributes)
+		}
+	}
+}
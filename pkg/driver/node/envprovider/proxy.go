@@ -0,0 +1,85 @@
+package envprovider
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// Proxy settings, which are passed to Mountpoint and used by the AWS SDK calls of the CSI Driver.
+const (
+	EnvHTTPProxy  = "HTTP_PROXY"
+	EnvHTTPSProxy = "HTTPS_PROXY"
+	EnvNoProxy    = "NO_PROXY"
+	// EnvCABundle is the path of a CA bundle in PEM format to trust in addition to the system trust store, e.g. the CA
+	// of a TLS-intercepting proxy, used by the AWS SDK calls of the CSI Driver.
+	EnvCABundle = "AWS_CA_BUNDLE"
+)
+
+// proxyAttributes maps the volume attributes overriding proxy settings to the environment variables they override.
+var proxyAttributes = []struct {
+	attribute string
+	env       Key
+}{
+	{volumecontext.HTTPProxy, EnvHTTPProxy},
+	{volumecontext.HTTPSProxy, EnvHTTPSProxy},
+	{volumecontext.NoProxy, EnvNoProxy},
+}
+
+// ProxyFromEnvironment returns the proxy settings of the CSI Driver from its `HTTP_PROXY`, `HTTPS_PROXY` and `NO_PROXY`
+// environment variables, or their lowercase versions.
+func ProxyFromEnvironment() Environment {
+	cfg := httpproxy.FromEnvironment()
+	env := Environment{}
+	for key, value := range map[Key]Value{EnvHTTPProxy: cfg.HTTPProxy, EnvHTTPSProxy: cfg.HTTPSProxy, EnvNoProxy: cfg.NoProxy} {
+		if value != "" {
+			env.Set(key, value)
+		}
+	}
+	return env
+}
+
+// ProxyFor returns the proxy settings overridden by `httpProxy`, `httpsProxy` and `noProxy` attributes in `volumeCtx`.
+// Proxies must be URLs with `http` or `https` scheme.
+func ProxyFor(volumeCtx map[string]string) (Environment, error) {
+	env := Environment{}
+	for _, p := range proxyAttributes {
+		value, ok := volumeCtx[p.attribute]
+		if !ok {
+			continue
+		}
+		if p.env != EnvNoProxy {
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("%s %q must be a URL with http or https scheme, e.g. http://proxy.internal:3128", p.attribute, value)
+			}
+		}
+		env.Set(p.env, value)
+	}
+	return env, nil
+}
+
+// ProxyFunc returns a function to choose the proxy for a request URL with the proxy settings in `env`, falling back to
+// the ones of the CSI Driver for settings not in `env`.
+func (env Environment) ProxyFunc() func(*url.URL) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if value, ok := env[EnvHTTPProxy]; ok {
+		cfg.HTTPProxy = value
+	}
+	if value, ok := env[EnvHTTPSProxy]; ok {
+		cfg.HTTPSProxy = value
+	}
+	if value, ok := env[EnvNoProxy]; ok {
+		cfg.NoProxy = value
+	}
+	return cfg.ProxyFunc()
+}
+
+// CABundle returns the path of the CA bundle in `AWS_CA_BUNDLE` environment variable, or an empty string if it's unset.
+func CABundle() string {
+	return os.Getenv(EnvCABundle)
+}
@@ -0,0 +1,39 @@
+package node
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// setExpressCacheArgs validates `cacheExpressBucket` volume attribute and translates it to `--cache-xz` Mountpoint argument,
+// which makes Mountpoint share its cache with other Mountpoint instances via an S3 Express One Zone directory bucket.
+//
+// Directory bucket names contain the ID of their Availability Zone, e.g. `cache--use1-az4--x-s3`. Using a cache bucket
+// in another zone than this node would add cross-zone latency and data transfer costs for every cache hit, so it's
+// rejected if the zone of this node is known.
+func (ns *S3NodeServer) setExpressCacheArgs(volumeCtx map[string]string, args *mountpoint.Args) error {
+	bucket, ok := volumeCtx[volumecontext.CacheExpressBucket]
+	if !ok {
+		return nil
+	}
+
+	zoneID, ok := topology.DirectoryBucketZoneID(bucket)
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "Shared cache bucket %q must be an S3 Express One Zone directory bucket, e.g. \"cache--use1-az4--x-s3\"", bucket)
+	}
+	if ns.ZoneID != "" && zoneID != ns.ZoneID {
+		return status.Errorf(codes.FailedPrecondition, "Shared cache bucket %q is in Availability Zone %s, but this node is in %s",
+			bucket, zoneID, ns.ZoneID)
+	}
+
+	if existing, ok := args.Value(mountpoint.ArgCacheXZ); ok {
+		klog.Warningf("NodePublishVolume: shared cache bucket %q from mount options is overridden by %q volume attribute", existing, volumecontext.CacheExpressBucket)
+	}
+	args.Set(mountpoint.ArgCacheXZ, bucket)
+	return nil
+}
@@ -0,0 +1,47 @@
+package node
+
+import (
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// respectsPodFSGroup returns whether the volume opted in to use `fsGroup` of the workload Pod as the group of its files.
+func respectsPodFSGroup(volumeCtx map[string]string) bool {
+	return volumeCtx[volumecontext.RespectPodFSGroup] == "true"
+}
+
+// setFSGroupArgs sets `--gid` to `fsGroup` of the workload Pod passed by kubelet in `volCap`, and `--allow-other`
+// for the workload Pod to access the mount, if the volume opted in with `respectPodFSGroup`.
+//
+// Kubelet only passes `fsGroup` to drivers with `VOLUME_MOUNT_GROUP` capability (see [S3NodeServer.VolumeMountGroup]),
+// and if the `fsGroupPolicy` of the CSIDriver object is `File`. Otherwise, the volume is mounted as is.
+func setFSGroupArgs(volCap *csi.VolumeCapability, volumeCtx map[string]string, args *mountpoint.Args) error {
+	if !respectsPodFSGroup(volumeCtx) {
+		return nil
+	}
+
+	fsGroup := volCap.GetMount().GetVolumeMountGroup()
+	if fsGroup == "" {
+		return nil
+	}
+	if _, err := strconv.ParseUint(fsGroup, 10, 32); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid fsGroup %q: %v", fsGroup, err)
+	}
+
+	if gid, ok := args.Value(mountpoint.ArgGID); ok && gid != fsGroup {
+		return status.Errorf(codes.InvalidArgument, "Mount option %s=%s conflicts with fsGroup %s of the Pod, remove it to use %q", mountpoint.ArgGID, gid, fsGroup, volumecontext.RespectPodFSGroup)
+	}
+	args.Set(mountpoint.ArgGID, fsGroup)
+
+	// Mountpoint does not allow `--allow-other` and `--allow-root` together.
+	if !args.Has(mountpoint.ArgAllowRoot) {
+		args.Set(mountpoint.ArgAllowOther, mountpoint.ArgNoValue)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// maxFUSEBackground is the highest number of background FUSE requests the kernel accepts.
+const maxFUSEBackground = 65535
+
+// setFUSEArgs validates and translates FUSE settings passed via volume context to Mountpoint arguments.
+//
+// `fuseMaxBackground` is the maximum number of outstanding background FUSE requests (e.g., readahead), and
+// `fuseCongestionThreshold` is the number of outstanding background requests at which the kernel starts
+// throttling new ones, which can't exceed `fuseMaxBackground`. Raising them allows more concurrent requests
+// to Mountpoint, for example for high-throughput sequential reads.
+func setFUSEArgs(volumeCtx map[string]string, args *mountpoint.Args) error {
+	maxBackground, hasMaxBackground, err := parseFUSESetting(volumeCtx, volumecontext.FUSEMaxBackground)
+	if err != nil {
+		return err
+	}
+	congestionThreshold, hasCongestionThreshold, err := parseFUSESetting(volumeCtx, volumecontext.FUSECongestionThreshold)
+	if err != nil {
+		return err
+	}
+	if hasMaxBackground && hasCongestionThreshold && congestionThreshold > maxBackground {
+		return status.Errorf(codes.InvalidArgument, "%s %d cannot be higher than %s %d",
+			volumecontext.FUSECongestionThreshold, congestionThreshold, volumecontext.FUSEMaxBackground, maxBackground)
+	}
+
+	if hasMaxBackground {
+		args.Set(mountpoint.ArgFUSEMaxBackground, strconv.Itoa(maxBackground))
+	}
+	if hasCongestionThreshold {
+		args.Set(mountpoint.ArgFUSECongestionThreshold, strconv.Itoa(congestionThreshold))
+	}
+	return nil
+}
+
+// parseFUSESetting parses FUSE setting `attribute` in `volumeCtx`, which must be between 1 and [maxFUSEBackground].
+func parseFUSESetting(volumeCtx map[string]string, attribute string) (int, bool, error) {
+	value, ok := volumeCtx[attribute]
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxFUSEBackground {
+		return 0, false, status.Errorf(codes.InvalidArgument, "%s %q must be a number between 1 and %d", attribute, value, maxFUSEBackground)
+	}
+	return n, true, nil
+}
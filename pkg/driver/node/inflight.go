@@ -0,0 +1,71 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"k8s.io/klog/v2"
+)
+
+// An inflightPublish represents a `NodePublishVolume` call being handled.
+type inflightPublish struct {
+	req  *csi.NodePublishVolumeRequest
+	done chan struct{}
+	resp *csi.NodePublishVolumeResponse
+	err  error
+}
+
+// inflightPublishes tracks `NodePublishVolume` calls being handled by volume ID and target path,
+// to coalesce retries of kubelet while a previous call is still in flight, e.g. waiting for Mountpoint to start.
+type inflightPublishes struct {
+	mu  sync.Mutex
+	ops map[string]*inflightPublish
+}
+
+func newInflightPublishes() *inflightPublishes {
+	return &inflightPublishes{ops: make(map[string]*inflightPublish)}
+}
+
+// do calls `publish` for `req` unless there is already a call in flight for the same volume and target path.
+//
+// An identical request waits for the call in flight and returns its result, so retries don't start duplicate mounts.
+// A different request, e.g. with refreshed service account tokens, is aborted for kubelet to retry it later,
+// as the call in flight might be using outdated information.
+func (p *inflightPublishes) do(ctx context.Context, req *csi.NodePublishVolumeRequest, publish func() (*csi.NodePublishVolumeResponse, error)) (*csi.NodePublishVolumeResponse, error) {
+	key := req.GetVolumeId() + ":" + req.GetTargetPath()
+
+	p.mu.Lock()
+	if op, ok := p.ops[key]; ok {
+		p.mu.Unlock()
+		if !proto.Equal(protoadapt.MessageV2Of(op.req), protoadapt.MessageV2Of(req)) {
+			return nil, status.Errorf(codes.Aborted, "Another operation is in progress for volume %s at %s", req.GetVolumeId(), req.GetTargetPath())
+		}
+
+		klog.V(4).Infof("NodePublishVolume: waiting for the operation in progress for volume %s at %s", req.GetVolumeId(), req.GetTargetPath())
+		select {
+		case <-op.done:
+			return op.resp, op.err
+		case <-ctx.Done():
+			return nil, status.Errorf(codes.Aborted, "Timed out waiting for the operation in progress for volume %s at %s: %v", req.GetVolumeId(), req.GetTargetPath(), ctx.Err())
+		}
+	}
+
+	op := &inflightPublish{req: req, done: make(chan struct{})}
+	p.ops[key] = op
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.ops, key)
+		p.mu.Unlock()
+		close(op.done)
+	}()
+
+	op.resp, op.err = publish()
+	return op.resp, op.err
+}
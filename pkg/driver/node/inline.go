@@ -0,0 +1,86 @@
+package node
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// kubeletVolumeContextPrefix is the prefix of the volume context keys populated by kubelet, e.g. with Pod information.
+const kubeletVolumeContextPrefix = "csi.storage.k8s.io/"
+
+// inlineVolumeAttributes are the only volume attributes allowed on CSI ephemeral (inline) volumes.
+//
+// Inline volumes are declared by anyone who can create Pods rather than cluster admins creating PVs, so attributes
+// changing where and with which identity Mountpoint connects (e.g., `endpointUrl`, `stsRoleArn` or `vaultRole`)
+// or how it runs on the node (e.g., cache attributes) are not allowed.
+var inlineVolumeAttributes = []string{
+	volumecontext.BucketName,
+	volumecontext.Prefix,
+	volumecontext.AuthenticationSource,
+	volumecontext.STSRegion,
+	volumecontext.MountOptions,
+}
+
+// inlineMountOptions are the only Mountpoint options allowed in `mountOptions` of CSI ephemeral (inline) volumes.
+var inlineMountOptions = []mountpoint.ArgKey{
+	mountpoint.ArgReadOnly,
+	mountpoint.ArgRegion,
+	mountpoint.ArgAllowOther,
+	mountpoint.ArgAllowRoot,
+	mountpoint.ArgMetadataTTL,
+	"--allow-delete",
+	"--allow-overwrite",
+	"--uid",
+	"--gid",
+	"--dir-mode",
+	"--file-mode",
+}
+
+// isInlineVolume returns whether the volume is a CSI ephemeral (inline) volume declared in the workload Pod's spec.
+func isInlineVolume(volumeCtx map[string]string) bool {
+	return volumeCtx[volumecontext.CSIEphemeral] == "true"
+}
+
+// inlineVolumeContext validates the volume context and secrets of a CSI ephemeral (inline) volume, and returns
+// its volume context with pod-level authentication, as the driver's identity must not be available to anyone
+// who can create Pods.
+func (ns *S3NodeServer) inlineVolumeContext(volumeCtx map[string]string, secrets map[string]string) (map[string]string, error) {
+	if !ns.AllowInlineVolumes {
+		return nil, status.Error(codes.InvalidArgument, "CSI ephemeral (inline) volumes are not enabled in the CSI Driver")
+	}
+
+	if len(secrets) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "`nodePublishSecretRef` is not supported for inline volumes")
+	}
+
+	for key := range volumeCtx {
+		if !strings.HasPrefix(key, kubeletVolumeContextPrefix) && !slices.Contains(inlineVolumeAttributes, key) {
+			return nil, status.Errorf(codes.InvalidArgument, "Volume attribute %q is not supported for inline volumes, supported attributes are %v", key, inlineVolumeAttributes)
+		}
+	}
+
+	if source := volumeCtx[volumecontext.AuthenticationSource]; source != "" && source != mounter.AuthenticationSourcePod {
+		return nil, status.Errorf(codes.InvalidArgument, "Inline volumes only support %q authentication source, got: %s", mounter.AuthenticationSourcePod, source)
+	}
+
+	if mountOptions := volumeCtx[volumecontext.MountOptions]; mountOptions != "" {
+		args := mountpoint.ParseArgs(strings.Split(mountOptions, ","))
+		for _, key := range args.Keys() {
+			if !slices.Contains(inlineMountOptions, key) {
+				return nil, status.Errorf(codes.InvalidArgument, "Mount option %q is not supported for inline volumes, supported options are %v", key, inlineMountOptions)
+			}
+		}
+	}
+
+	inlineCtx := maps.Clone(volumeCtx)
+	inlineCtx[volumecontext.AuthenticationSource] = mounter.AuthenticationSourcePod
+	return inlineCtx, nil
+}
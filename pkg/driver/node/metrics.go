@@ -0,0 +1,125 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// Metrics exposed by the node plugin if `--metrics-address` is passed.
+//
+// Metrics of volumes are labelled with `volume_id` but not with the workload Pods using them, as Pods are short-lived
+// and would make the number of series grow unbounded. Metrics of a volume are deleted once it's not published in the node anymore.
+var (
+	activeMounts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3_csi_node",
+		Name:      "active_mounts",
+		Help:      "Number of volumes currently published in the node.",
+	})
+	mountFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "mount_failures_total",
+		Help:      "Total number of failures to spawn Mountpoint for a volume.",
+	})
+	cacheReclaimedBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "cache_reclaimed_bytes_total",
+		Help:      "Total number of bytes purged from local caches of volumes while the node was under disk pressure.",
+	})
+	mountpointLimitRejectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "mountpoint_limit_rejections_total",
+		Help:      "Total number of volumes not mounted as the node was running the maximum number of Mountpoint processes.",
+	})
+	credentialFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "credential_failures_total",
+		Help:      "Total number of failures to provide or refresh credentials for a volume by authentication source.",
+	}, []string{"authentication_source"})
+	mountHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi_node",
+		Name:      "mount_healthy",
+		Help:      "Whether all Mountpoint mounts of a published volume are healthy (1) or any of them is broken (0).",
+	}, []string{"volume_id"})
+	brokenMountsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "broken_mounts_total",
+		Help:      "Total number of times a Mountpoint mount of a published volume was detected as broken.",
+	}, []string{"volume_id"})
+	mountRecoveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "mount_recoveries_total",
+		Help:      "Total number of attempts to re-establish broken Mountpoint mounts by result.",
+	}, []string{"volume_id", "result"})
+	credentialsLastRefreshTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi_node",
+		Name:      "credentials_last_refresh_timestamp_seconds",
+		Help:      "Unix time short-lived credentials of a published volume were last refreshed, by authentication source.",
+	}, []string{"volume_id", "authentication_source"})
+	credentialsExpirationTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3_csi_node",
+		Name:      "credentials_expiration_timestamp_seconds",
+		Help:      "Unix time the last refreshed short-lived credentials of a published volume expire, they must be refreshed before then.",
+	}, []string{"volume_id"})
+	tokenReissuesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "token_reissues_total",
+		Help:      "Total number of service account tokens of pod-level credentials re-issued by the CSI Driver before expiry by result.",
+	}, []string{"result"})
+	forceUnmountsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3_csi_node",
+		Name:      "force_unmounts_total",
+		Help:      "Total number of hung unmounts escalated to forceful unmounts by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		activeMounts,
+		mountFailuresTotal,
+		mountpointLimitRejectionsTotal,
+		cacheReclaimedBytesTotal,
+		credentialFailuresTotal,
+		mountHealthy,
+		brokenMountsTotal,
+		mountRecoveriesTotal,
+		credentialsLastRefreshTimestamp,
+		credentialsExpirationTimestamp,
+		tokenReissuesTotal,
+		forceUnmountsTotal,
+	)
+}
+
+// authenticationSourceLabel returns authentication source of the volume with given `volumeCtx` to use in metrics.
+func authenticationSourceLabel(volumeCtx map[string]string) string {
+	if source := volumeCtx[volumecontext.AuthenticationSource]; source != mounter.AuthenticationSourceUnspecified {
+		return source
+	}
+	return mounter.AuthenticationSourceDriver
+}
+
+// recordCredentialsRefresh records when short-lived `credentials` of the volume with given `volumeCtx`
+// were refreshed and expire. Long-term credentials are not recorded.
+func recordCredentialsRefresh(volumeID string, volumeCtx map[string]string, credentials *mounter.MountCredentials) {
+	if !credentials.RefreshedAt.IsZero() {
+		credentialsLastRefreshTimestamp.WithLabelValues(volumeID, authenticationSourceLabel(volumeCtx)).Set(float64(credentials.RefreshedAt.Unix()))
+	}
+	if !credentials.Expiration.IsZero() {
+		credentialsExpirationTimestamp.WithLabelValues(volumeID).Set(float64(credentials.Expiration.Unix()))
+		klog.V(4).InfoS("NodePublishVolume: Credentials are refreshed", "refreshedAt", credentials.RefreshedAt, "expiration", credentials.Expiration,
+			logging.KeyVolumeID, volumeID, logging.KeyPodUID, volumeCtx[volumecontext.CSIPodUID])
+	}
+}
+
+// deleteVolumeMetrics deletes all metrics of the volume with given `volumeID`, once it's not published in the node anymore.
+func deleteVolumeMetrics(volumeID string) {
+	labels := prometheus.Labels{"volume_id": volumeID}
+	mountHealthy.DeletePartialMatch(labels)
+	brokenMountsTotal.DeletePartialMatch(labels)
+	mountRecoveriesTotal.DeletePartialMatch(labels)
+	credentialsLastRefreshTimestamp.DeletePartialMatch(labels)
+	credentialsExpirationTimestamp.DeletePartialMatch(labels)
+}
@@ -0,0 +1,309 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// MountMonitorInterval is the interval to check health of published volumes.
+const MountMonitorInterval = 30 * time.Second
+
+// Reasons of the events emitted to the workload Pods by the mount monitor.
+const (
+	EventReasonMountUnhealthy = "MountpointUnhealthy"
+	EventReasonMountRecovered = "MountpointRecovered"
+)
+
+// A publishedVolume represents a volume published to a target path by `NodePublishVolume`.
+// It contains everything needed to re-establish the mount if the Mountpoint process serving it dies.
+type publishedVolume struct {
+	volumeID  string
+	bucket    string
+	volumeCtx map[string]string
+	// secrets is the contents of the Secret referenced by `nodePublishSecretRef` of the volume, if any.
+	secrets map[string]string
+	// secretsPending is whether the volume uses `nodePublishSecretRef`, but it was restored after a restart of the node plugin
+	// without its secrets, which are not persisted. Kubelet passes them again when it republishes the volume.
+	secretsPending bool
+	// args is kept as a list because `mountpoint.Args` is mutated during mount operation.
+	args []string
+	// stagingTarget is the path Mountpoint is mounted at if the volume is bind mounted to the target path, or empty otherwise.
+	stagingTarget string
+	// readOnly is whether the bind mount to the target path is read-only, only used if the volume is staged.
+	readOnly bool
+	// roleARN is the ARN of the IAM role used to access the bucket if it's known, only used in audit logs and events.
+	roleARN string
+	// sourceTarget is the target path of the same volume and workload Pod this target path is bind mounted from, or empty otherwise.
+	sourceTarget string
+	// released is whether kubelet unpublished this target path while others were still bind mounted from it.
+	released bool
+}
+
+// podRef returns a reference to the workload Pod using this volume, or nil if the Pod information is not available.
+// Pod information is only passed in volume context if `podInfoOnMount` is enabled on the CSIDriver object.
+func (v publishedVolume) podRef() *corev1.ObjectReference {
+	name, namespace := v.volumeCtx[volumecontext.CSIPodName], v.volumeCtx[volumecontext.CSIPodNamespace]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       name,
+		Namespace:  namespace,
+		UID:        types.UID(v.volumeCtx[volumecontext.CSIPodUID]),
+	}
+}
+
+// publishedVolumes keeps track of volumes published in this node by their target paths.
+type publishedVolumes struct {
+	mu       sync.Mutex
+	byTarget map[string]publishedVolume
+	// stateFile is the path to persist published volumes to on every change, or empty if they're not persisted.
+	stateFile string
+}
+
+func newPublishedVolumes() *publishedVolumes {
+	return &publishedVolumes{byTarget: make(map[string]publishedVolume)}
+}
+
+func (p *publishedVolumes) add(target string, vol publishedVolume) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byTarget[target] = vol
+	activeMounts.Set(float64(len(p.byTarget)))
+	p.persist()
+}
+
+func (p *publishedVolumes) get(target string) (publishedVolume, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vol, ok := p.byTarget[target]
+	return vol, ok
+}
+
+func (p *publishedVolumes) remove(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vol, ok := p.byTarget[target]
+	delete(p.byTarget, target)
+	if ok && !p.hasVolume(vol.volumeID) {
+		deleteVolumeMetrics(vol.volumeID)
+	}
+	activeMounts.Set(float64(len(p.byTarget)))
+	p.persist()
+}
+
+// hasVolume returns whether the volume with given `volumeID` is published to any target path,
+// it must be called with `mu` held.
+func (p *publishedVolumes) hasVolume(volumeID string) bool {
+	for _, vol := range p.byTarget {
+		if vol.volumeID == volumeID {
+			return true
+		}
+	}
+	return false
+}
+
+// recordHealth records whether mounts of the volume with given `volumeID` are healthy, unless it has been unpublished
+// from all target paths since they were checked, as its metrics are deleted then.
+func (p *publishedVolumes) recordHealth(volumeID string, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.hasVolume(volumeID) {
+		return
+	}
+	if healthy {
+		mountHealthy.WithLabelValues(volumeID).Set(1)
+	} else {
+		mountHealthy.WithLabelValues(volumeID).Set(0)
+	}
+}
+
+func (p *publishedVolumes) snapshot() map[string]publishedVolume {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.byTarget)
+}
+
+// persistTo persists published volumes to `stateFile` now and on every change from now on.
+func (p *publishedVolumes) persistTo(stateFile string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stateFile = stateFile
+	return writeState(p.stateFile, p.byTarget)
+}
+
+// persist persists published volumes to the state file if configured, it must be called with `mu` held.
+// Failures are only logged, as the state file is only needed to restore published volumes after a restart.
+func (p *publishedVolumes) persist() {
+	if p.stateFile == "" {
+		return
+	}
+	if err := writeState(p.stateFile, p.byTarget); err != nil {
+		klog.Errorf("Failed to persist published volumes: %v", err)
+	}
+}
+
+// MonitorMounts periodically checks health of published volumes in this node with given `interval`.
+//
+// If the Mountpoint process serving a volume is terminated unexpectedly (e.g., due to getting OOM-killed),
+// its mount becomes corrupted and fails with "transport endpoint is not connected" errors until it's re-mounted.
+// Such mounts are reported via `s3_csi_node_mount_healthy` metric and events on the workload Pods,
+// and they're re-established using the same options they were published with.
+//
+// Re-established mounts are visible to the running containers that mounts the volume with `HostToContainer` mount propagation,
+// other containers will see the new mount after a restart.
+//
+// It blocks until `ctx` is cancelled.
+func (ns *S3NodeServer) MonitorMounts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ns.checkMounts(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkMounts checks all published volumes once and re-mounts the corrupted ones.
+//
+// A volume might be published to multiple target paths, e.g. for multiple Pods in the node, so it's reported as healthy
+// only if all of its checked mounts are healthy or re-mounted.
+func (ns *S3NodeServer) checkMounts(ctx context.Context) {
+	healthy := map[string]bool{}
+	defer func() {
+		for volumeID, ok := range healthy {
+			ns.publishedVolumes.recordHealth(volumeID, ok)
+		}
+	}()
+	markHealthy := func(volumeID string, ok bool) {
+		if prev, seen := healthy[volumeID]; seen {
+			ok = ok && prev
+		}
+		healthy[volumeID] = ok
+	}
+
+	for target, vol := range ns.publishedVolumes.snapshot() {
+		// Released target paths are not used by the workload Pod anymore, they're unmounted once
+		// no target path is bind mounted from them, and those are re-mounted on their own if they're corrupted.
+		if vol.released {
+			ns.releaseSource(ctx, target)
+			continue
+		}
+
+		_, err := ns.Mounter.IsMountPoint(target)
+		if err == nil {
+			markHealthy(vol.volumeID, true)
+			if len(ns.ReissueTokenServiceAccounts) > 0 && vol.sourceTarget == "" {
+				ns.refreshToken(ctx, "MonitorMounts", target, vol)
+			}
+			continue
+		}
+
+		if os.IsNotExist(err) {
+			klog.V(4).Infof("MonitorMounts: target path %s does not exist anymore, no longer tracking it", target)
+			ns.publishedVolumes.remove(target)
+			continue
+		}
+
+		if !mount.IsCorruptedMnt(err) {
+			klog.V(4).Infof("MonitorMounts: failed to check if target path %s is a mount point: %v", target, err)
+			continue
+		}
+
+		klog.Warningf("MonitorMounts: Mountpoint serving volume %s at %s is not running anymore: %v, re-mounting", vol.volumeID, target, err)
+		brokenMountsTotal.WithLabelValues(vol.volumeID).Inc()
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonMountUnhealthy,
+			"Mountpoint serving volume %s is not running anymore: %v", vol.volumeID, err)
+
+		if err := ns.recoverMount(ctx, target, vol); err != nil {
+			klog.Errorf("MonitorMounts: failed to re-mount volume %s at %s: %v", vol.volumeID, target, err)
+			mountRecoveriesTotal.WithLabelValues(vol.volumeID, "failure").Inc()
+			markHealthy(vol.volumeID, false)
+			continue
+		}
+
+		klog.Infof("MonitorMounts: volume %s was re-mounted at %s", vol.volumeID, target)
+		markHealthy(vol.volumeID, true)
+		mountRecoveriesTotal.WithLabelValues(vol.volumeID, "success").Inc()
+		ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonMountRecovered,
+			"Volume %s was re-mounted, containers not using HostToContainer mount propagation need to be restarted to access it", vol.volumeID)
+	}
+}
+
+// recoverMount re-mounts given `vol` at `target` using the same options it was published with.
+func (ns *S3NodeServer) recoverMount(ctx context.Context, target string, vol publishedVolume) error {
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
+
+	// The volume might be unpublished while we were waiting for the lock.
+	vol, ok := ns.publishedVolumes.get(target)
+	if !ok {
+		return nil
+	}
+
+	// Mountpoint would fall back to other credentials without the secrets of the volume.
+	if vol.secretsPending {
+		return errors.New("secrets of the volume are not restored after a restart of the node plugin, waiting for kubelet to republish it")
+	}
+
+	args := mountpoint.ParseArgs(vol.args)
+
+	credentials, err := ns.provideCredentials(ctx, vol.volumeID, vol.volumeCtx, vol.secrets, args)
+	if err != nil {
+		return err
+	}
+
+	if vol.stagingTarget == "" {
+		// `Mount` unmounts the corrupted mount at `target` before mounting it again.
+		if err := ns.Mounter.Mount(vol.bucket, target, credentials, args); err != nil {
+			return err
+		}
+		// Target paths bind mounted from another one are re-mounted on their own, as their source might be released.
+		// Released sources are unmounted by the next check once nothing is bind mounted from them.
+		if vol.sourceTarget != "" {
+			vol.sourceTarget = ""
+			ns.publishedVolumes.add(target, vol)
+		}
+		return nil
+	}
+
+	// The bind mount at `target` still points to the terminated Mountpoint process,
+	// it needs to be re-created after re-mounting the staging target path.
+	if err := ns.Mounter.Unmount(target); err != nil {
+		return err
+	}
+	return ns.mountStaged(vol.bucket, vol.stagingTarget, target, credentials, args, vol.readOnly)
+}
+
+// recordEvent emits an event to the workload Pod using `vol` if an event recorder is configured.
+func (ns *S3NodeServer) recordEvent(vol publishedVolume, eventType, reason, messageFmt string, args ...interface{}) {
+	if ns.EventRecorder == nil {
+		return
+	}
+
+	podRef := vol.podRef()
+	if podRef == nil {
+		klog.V(4).Infof("No Pod information in volume context of %s, skipping %s event", vol.volumeID, reason)
+		return
+	}
+
+	ns.EventRecorder.Eventf(podRef, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mountpolicy"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// Reasons of the events emitted to the workload Pods if the mount options policy denies some options.
+const (
+	EventReasonMountOptionsStripped = "MountOptionsStripped"
+	EventReasonMountOptionsRejected = "MountOptionsRejected"
+)
+
+// applyMountOptionsPolicy applies the mount options policy configured by the cluster admin to `args`.
+// The policy file is loaded on each call, so changes to the ConfigMap it's mounted from are picked up without a restart.
+func (ns *S3NodeServer) applyMountOptionsPolicy(volumeID string, volumeCtx map[string]string, args *mountpoint.Args) error {
+	if ns.MountOptionsPolicyFile == "" {
+		return nil
+	}
+
+	policy, err := mountpolicy.Load(ns.MountOptionsPolicyFile)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not load mount options policy: %v", err)
+	}
+	if policy == nil {
+		return nil
+	}
+
+	vol := publishedVolume{volumeID: volumeID, volumeCtx: volumeCtx}
+	namespace := volumeCtx[volumecontext.CSIPodNamespace]
+
+	denied, err := policy.Apply(namespace, args)
+	if errors.Is(err, mountpolicy.ErrDenied) {
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonMountOptionsRejected,
+			"Mount options %v of volume %s are not allowed in namespace %q by the cluster's mount options policy", denied, volumeID, namespace)
+		return status.Errorf(codes.InvalidArgument, "Mount options %v are not allowed in namespace %q by the cluster's mount options policy", denied, namespace)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "Could not apply mount options policy: %v", err)
+	}
+
+	if len(denied) > 0 {
+		klog.Warningf("NodePublishVolume: mount options %v of volume %s are not allowed in namespace %q, removing them", denied, volumeID, namespace)
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonMountOptionsStripped,
+			"Mount options %v of volume %s are not allowed in namespace %q by the cluster's mount options policy and ignored", denied, volumeID, namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/google/renameio"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+const (
+	// assumedRoleSessionDuration is the duration of the assumed role sessions,
+	// which is the maximum allowed duration for role chaining.
+	assumedRoleSessionDuration = time.Hour
+	// assumedRoleRefreshWindow is the remaining duration of an assumed role session to refresh it.
+	// Kubelet republishes volumes (i.e., calls `NodePublishVolume` again) periodically, which refreshes the session.
+	assumedRoleRefreshWindow = 15 * time.Minute
+	// assumedRoleSessionNameMaxLength is the maximum length of a role session name allowed by STS.
+	assumedRoleSessionNameMaxLength = 64
+)
+
+// An AssumeRoleInput represents an IAM role to assume with given base credentials.
+type AssumeRoleInput struct {
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	Region      string
+	// Endpoint is the URL of a custom STS endpoint, the regional STS endpoint of `Region` is used if empty,
+	// which is the dual-stack one if `AWS_USE_DUALSTACK_ENDPOINT` is enabled.
+	Endpoint string
+	// BaseCredentials to assume the role with, the default credentials chain of the CSI Driver is used if nil.
+	BaseCredentials aws.CredentialsProvider
+	// WebIdentityTokenFile is the path of a web identity token to assume the role with instead of `BaseCredentials`.
+	WebIdentityTokenFile string
+	// Policy is an inline session policy in JSON to scope down permissions of the session, if not empty.
+	Policy string
+	// HTTPClient is optional, and the HTTP client to send requests to STS with, see [HTTPClientFor].
+	HTTPClient aws.HTTPClient
+}
+
+// A RoleAssumer assumes IAM roles and returns short-lived session credentials.
+type RoleAssumer interface {
+	AssumeRole(ctx context.Context, input AssumeRoleInput) (aws.Credentials, error)
+}
+
+// stsRoleAssumer is the default [RoleAssumer] using STS.
+type stsRoleAssumer struct{}
+
+func (stsRoleAssumer) AssumeRole(ctx context.Context, input AssumeRoleInput) (aws.Credentials, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(input.Region))
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("could not load AWS config: %w", err)
+	}
+	if input.BaseCredentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(input.BaseCredentials)
+	}
+	if input.HTTPClient != nil {
+		cfg.HTTPClient = input.HTTPClient
+	}
+
+	client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if input.Endpoint != "" {
+			o.BaseEndpoint = aws.String(input.Endpoint)
+		}
+	})
+
+	if input.WebIdentityTokenFile != "" {
+		provider := stscreds.NewWebIdentityRoleProvider(client, input.RoleARN, stscreds.IdentityTokenFile(input.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = input.SessionName
+			o.Duration = assumedRoleSessionDuration
+			if input.Policy != "" {
+				o.Policy = aws.String(input.Policy)
+			}
+		})
+		return provider.Retrieve(ctx)
+	}
+
+	assumeRoleInput := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(input.RoleARN),
+		RoleSessionName: aws.String(input.SessionName),
+		DurationSeconds: aws.Int32(int32(assumedRoleSessionDuration.Seconds())),
+	}
+	if input.ExternalID != "" {
+		assumeRoleInput.ExternalId = aws.String(input.ExternalID)
+	}
+	if input.Policy != "" {
+		assumeRoleInput.Policy = aws.String(input.Policy)
+	}
+
+	output, err := client.AssumeRole(ctx, assumeRoleInput)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(output.Credentials.SessionToken),
+		CanExpire:       true,
+		Expires:         aws.ToTime(output.Credentials.Expiration),
+	}, nil
+}
+
+// processCredentials is the output format of `credential_process`,
+// see https://docs.aws.amazon.com/sdkref/latest/guide/feature-process-credentials.html.
+type processCredentials struct {
+	Version         int       `json:"Version"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// SetRoleAssumer sets the [RoleAssumer] used for volumes with `stsRoleArn`. STS is used by default.
+func (c *CredentialProvider) SetRoleAssumer(roleAssumer RoleAssumer) {
+	c.roleAssumer = roleAssumer
+}
+
+// AssumeRole assumes the role in `stsRoleArn` volume attribute with given `base` credentials, and returns
+// mount credentials that provide the session credentials of the assumed role to Mountpoint.
+//
+// Session credentials are written to a file in the plugin directory, which Mountpoint reads via `credential_process`
+// whenever its credentials expire. The session is refreshed on each call once its close to expiry, which keeps
+// the file up-to-date as kubelet republishes volumes periodically. The session is scoped down to the volume
+// if `scopedSessionPolicy` volume attribute is enabled.
+func (c *CredentialProvider) AssumeRole(ctx context.Context, volumeID string, volumeCtx map[string]string, args mountpoint.Args, base *MountCredentials) (*MountCredentials, error) {
+	roleARN := volumeCtx[volumecontext.STSRoleARN]
+	if base.CredentialsFileContents != "" {
+		return nil, status.Error(codes.InvalidArgument, "`stsRoleArn` cannot be used with `awsProfile`")
+	}
+	if c.usesCredentialBackend(volumeCtx) {
+		return nil, status.Errorf(codes.InvalidArgument, "`stsRoleArn` cannot be used with `authenticationSource: %s`, configure the role in %s instead",
+			base.AuthenticationSource, base.AuthenticationSource)
+	}
+
+	// Roles trusting the CSI Driver's own identity would otherwise be assumable by anyone who can create volumes.
+	// An external ID does not prevent that, as it's set by the same users in the volume.
+	if base.AuthenticationSource == AuthenticationSourceDriver && !base.fromSecret && !slices.Contains(c.defaultSTSConfig.AllowedRoleARNs, roleARN) {
+		return nil, status.Errorf(codes.PermissionDenied, "`stsRoleArn` %s must be allowed in the CSI Driver to be assumed with the CSI Driver's credentials, "+
+			"or the volume must use volume-level or pod-level credentials, see %s", roleARN, stsRoleDocsPage)
+	}
+
+	podID := volumeCtx[volumecontext.CSIPodUID]
+	if podID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing Pod info. Please make sure to enable `podInfoOnMountCompat`, see "+podLevelCredentialsDocsPage)
+	}
+
+	region, err := c.stsRegion(volumeCtx, args)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Failed to detect STS AWS Region: %v. Please explicitly set the AWS Region, see %s", err, stsConfigDocsPage)
+	}
+
+	endpoint, err := c.stsEndpoint(volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := scopedSessionPolicy(volumeCtx, args, region)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := HTTPClientFor(volumeCtx)
+	return c.provideSessionCredentials(ctx, volumeID, podID, "", AssumeRoleInput{
+		RoleARN:         roleARN,
+		ExternalID:      volumeCtx[volumecontext.STSExternalID],
+		SessionName:     assumedRoleSessionName(podID),
+		Region:          region,
+		Endpoint:        endpoint,
+		BaseCredentials: c.baseCredentials(base, podID, volumeID, region, endpoint, httpClient),
+		Policy:          policy,
+		HTTPClient:      httpClient,
+	}, base)
+}
+
+// provideSessionCredentials assumes the role in `input` for given pod and volume unless there are session credentials
+// that are not close to expiry, and returns mount credentials that provide the session credentials to Mountpoint.
+// If the role is assumed with the web identity of the service account `subject`, a denied access is reported
+// as a missing trust.
+func (c *CredentialProvider) provideSessionCredentials(ctx context.Context, volumeID string, podID string, subject string, input AssumeRoleInput, base *MountCredentials) (*MountCredentials, error) {
+	return c.provideRefreshedCredentials(volumeID, podID, base, func() (aws.Credentials, error) {
+		klog.V(4).Infof("NodePublishVolume: Assuming role %s for volume %s", input.RoleARN, volumeID)
+
+		sessionCredentials, err := c.roleAssumer.AssumeRole(ctx, input)
+		if err != nil {
+			if subject != "" && input.WebIdentityTokenFile != "" {
+				if trustErr := trustPolicyError(err, input.RoleARN, subject); trustErr != nil {
+					return aws.Credentials{}, trustErr
+				}
+			}
+			return aws.Credentials{}, status.Errorf(codes.PermissionDenied, "Failed to assume role %s: %v", input.RoleARN, err)
+		}
+		return sessionCredentials, nil
+	})
+}
+
+// provideRefreshedCredentials returns mount credentials that provide short-lived credentials for given pod and volume
+// to Mountpoint via `credential_process`, which are obtained with `retrieve` unless the ones written for them
+// previously are not close to expiry.
+func (c *CredentialProvider) provideRefreshedCredentials(volumeID string, podID string, base *MountCredentials, retrieve func() (aws.Credentials, error)) (*MountCredentials, error) {
+	credentialsPath := c.sessionCredentialsPathContainer(podID, volumeID)
+	refreshedAt, expiration, ok := sessionCredentialsExpiration(credentialsPath)
+	if !ok || time.Until(expiration) < assumedRoleRefreshWindow {
+		sessionCredentials, err := retrieve()
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeSessionCredentials(credentialsPath, sessionCredentials)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to write session credentials: %v", err)
+		}
+		refreshedAt, expiration = time.Now(), sessionCredentials.Expires
+	}
+
+	hostPluginDir := hostPluginDirWithDefault()
+
+	return &MountCredentials{
+		AuthenticationSource: base.AuthenticationSource,
+
+		CredentialProcess: "cat " + path.Join(hostPluginDir, c.sessionCredentialsFilename(podID, volumeID)),
+
+		Region:        base.Region,
+		DefaultRegion: base.DefaultRegion,
+		StsEndpoints:  base.StsEndpoints,
+
+		// Ensure to disable IMDS provider
+		DisableIMDSProvider: true,
+
+		MountpointCacheKey: base.MountpointCacheKey,
+
+		RefreshedAt: refreshedAt,
+		Expiration:  expiration,
+	}, nil
+}
+
+// baseCredentials returns credentials provider to assume a role with from given `base` mount credentials.
+// Web identity tokens are exchanged via `httpClient` if it's not nil.
+func (c *CredentialProvider) baseCredentials(base *MountCredentials, podID string, volumeID string, region string, endpoint string, httpClient aws.HTTPClient) aws.CredentialsProvider {
+	if base.AccessKeyID != "" && base.SecretAccessKey != "" {
+		return credentials.NewStaticCredentialsProvider(base.AccessKeyID, base.SecretAccessKey, base.SessionToken)
+	}
+
+	if c.isDriverCredentialProcess(base) {
+		return c.driverCredentialProcessProvider()
+	}
+
+	if base.AuthenticationSource == AuthenticationSourcePod {
+		stsOptions := sts.Options{Region: region, HTTPClient: httpClient}
+		if endpoint != "" {
+			stsOptions.BaseEndpoint = aws.String(endpoint)
+		} else if envprovider.UseDualStackEndpoint() {
+			stsOptions.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		}
+		stsClient := sts.New(stsOptions)
+		return stscreds.NewWebIdentityRoleProvider(stsClient, base.AwsRoleArn, stscreds.IdentityTokenFile(c.tokenPathContainer(podID, volumeID)))
+	}
+
+	// Use the default credentials chain of the CSI Driver, i.e., IRSA or IMDS.
+	return nil
+}
+
+// SDKCredentials returns a credentials provider resolving the same credentials as given `mountCredentials` of the volume,
+// so the CSI Driver can access the bucket on behalf of Mountpoint, e.g. to check it before mounting.
+// It returns nil to use the default credentials chain of the CSI Driver, and false if the CSI Driver cannot use
+// the mount credentials itself, e.g. the profiles passed with `awsProfile`.
+func (c *CredentialProvider) SDKCredentials(volumeID string, volumeCtx map[string]string, mountCredentials *MountCredentials) (aws.CredentialsProvider, bool) {
+	podID := volumeCtx[volumecontext.CSIPodUID]
+	if volumeCtx[volumecontext.STSRoleARN] != "" || c.usesCredentialBackend(volumeCtx) {
+		content, err := os.ReadFile(c.sessionCredentialsPathContainer(podID, volumeID))
+		if err != nil {
+			return nil, false
+		}
+		var creds processCredentials
+		if err := json.Unmarshal(content, &creds); err != nil {
+			return nil, false
+		}
+		return credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken), true
+	}
+
+	if c.isDriverCredentialProcess(mountCredentials) {
+		return c.driverCredentialProcessProvider(), true
+	}
+
+	if mountCredentials.CredentialsFileContents != "" || mountCredentials.CredentialProcess != "" {
+		return nil, false
+	}
+
+	endpoint, err := c.stsEndpoint(volumeCtx)
+	if err != nil {
+		return nil, false
+	}
+	return c.baseCredentials(mountCredentials, podID, volumeID, mountCredentials.Region, endpoint, HTTPClientFor(volumeCtx)), true
+}
+
+// CleanupSessionCredentials cleans any created session credentials files for given volume and pod.
+func (c *CredentialProvider) CleanupSessionCredentials(volumeID string, podID string) error {
+	err := os.Remove(c.sessionCredentialsPathContainer(podID, volumeID))
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *CredentialProvider) sessionCredentialsPathContainer(podID string, volumeID string) string {
+	return path.Join(c.containerPluginDir, c.sessionCredentialsFilename(podID, volumeID))
+}
+
+func (c *CredentialProvider) sessionCredentialsFilename(podID string, volumeID string) string {
+	return strings.TrimSuffix(c.tokenFilename(podID, volumeID), ".token") + ".credentials.json"
+}
+
+// assumedRoleSessionName returns the role session name for given `podID`, which is visible in CloudTrail.
+func assumedRoleSessionName(podID string) string {
+	name := "s3-csi-" + podID
+	if len(name) > assumedRoleSessionNameMaxLength {
+		name = name[:assumedRoleSessionNameMaxLength]
+	}
+	return name
+}
+
+// sessionCredentialsExpiration returns when session credentials written to `path` were refreshed and expire,
+// and whether they could be read.
+func sessionCredentialsExpiration(path string) (time.Time, time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			klog.V(4).Infof("NodePublishVolume: Failed to stat session credentials %s: %v", path, err)
+		}
+		return time.Time{}, time.Time{}, false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		klog.V(4).Infof("NodePublishVolume: Failed to read session credentials %s: %v", path, err)
+		return time.Time{}, time.Time{}, false
+	}
+
+	var creds processCredentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		klog.V(4).Infof("NodePublishVolume: Failed to parse session credentials %s: %v", path, err)
+		return time.Time{}, time.Time{}, false
+	}
+	return info.ModTime(), creds.Expiration, true
+}
+
+// writeSessionCredentials atomically writes `creds` to `path` in `credential_process` output format.
+func writeSessionCredentials(path string, creds aws.Credentials) error {
+	content, err := json.Marshal(processCredentials{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expires,
+	})
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(path, content, serviceAccountTokenPerm)
+}
@@ -0,0 +1,462 @@
+package mounter_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeRoleAssumer struct {
+	inputs  []mounter.AssumeRoleInput
+	expires time.Time
+	err     error
+}
+
+func (f *fakeRoleAssumer) AssumeRole(_ context.Context, input mounter.AssumeRoleInput) (aws.Credentials, error) {
+	f.inputs = append(f.inputs, input)
+	if f.err != nil {
+		return aws.Credentials{}, f.err
+	}
+	return aws.Credentials{
+		AccessKeyID:     "assumed-access-key",
+		SecretAccessKey: "assumed-secret-key",
+		SessionToken:    "assumed-session-token",
+		CanExpire:       true,
+		Expires:         f.expires,
+	}, nil
+}
+
+func TestAssumingRoleFromVolumeAttribute(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("HOST_PLUGIN_DIR", "/test/csi/plugin/dir")
+
+	volumeCtx := map[string]string{
+		"stsRoleArn":                 "arn:aws:iam::123456789012:role/Chained",
+		"stsExternalId":              "test-external-id",
+		"csi.storage.k8s.io/pod.uid": "test-pod",
+	}
+
+	setup := func(t *testing.T, expires time.Time) (*mounter.CredentialProvider, *fakeRoleAssumer, string) {
+		pluginDir := t.TempDir()
+		roleAssumer := &fakeRoleAssumer{expires: expires}
+		provider := mounter.NewCredentialProvider(nil, pluginDir, mounter.RegionFromIMDSOnce)
+		provider.SetRoleAssumer(roleAssumer)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{AllowedRoleARNs: []string{"arn:aws:iam::123456789012:role/Chained"}}))
+		return provider, roleAssumer, pluginDir
+	}
+	assumeRole := func(t *testing.T, provider *mounter.CredentialProvider, volumeCtx map[string]string) (*mounter.MountCredentials, error) {
+		args := mountpoint.ParseArgs(nil)
+		base, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, args)
+		assertEquals(t, nil, err)
+		return provider.AssumeRole(context.Background(), "test-vol-id", volumeCtx, args, base)
+	}
+
+	t.Run("writes session credentials", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		provider, roleAssumer, pluginDir := setup(t, expires)
+
+		credentials, err := assumeRole(t, provider, volumeCtx)
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "arn:aws:iam::123456789012:role/Chained", roleAssumer.inputs[0].RoleARN)
+		assertEquals(t, "test-external-id", roleAssumer.inputs[0].ExternalID)
+		assertEquals(t, "s3-csi-test-pod", roleAssumer.inputs[0].SessionName)
+		assertEquals(t, "eu-west-1", roleAssumer.inputs[0].Region)
+		if roleAssumer.inputs[0].BaseCredentials == nil {
+			t.Fatal("Expected driver's static credentials to be used as base credentials")
+		}
+
+		assertEquals(t, "", credentials.AccessKeyID)
+		assertEquals(t, true, credentials.DisableIMDSProvider)
+		assertEquals(t, "eu-west-1", credentials.Region)
+		assertEquals(t, true, expires.Equal(credentials.Expiration))
+		assertEquals(t, false, credentials.RefreshedAt.IsZero())
+		filename, ok := strings.CutPrefix(credentials.CredentialProcess, "cat /test/csi/plugin/dir/")
+		if !ok {
+			t.Fatalf("Unexpected credential process %q", credentials.CredentialProcess)
+		}
+
+		content, err := os.ReadFile(path.Join(pluginDir, filename))
+		assertEquals(t, nil, err)
+		var sessionCredentials struct {
+			Version         int
+			AccessKeyId     string
+			SecretAccessKey string
+			SessionToken    string
+			Expiration      time.Time
+		}
+		assertEquals(t, nil, json.Unmarshal(content, &sessionCredentials))
+		assertEquals(t, 1, sessionCredentials.Version)
+		assertEquals(t, "assumed-access-key", sessionCredentials.AccessKeyId)
+		assertEquals(t, "assumed-secret-key", sessionCredentials.SecretAccessKey)
+		assertEquals(t, "assumed-session-token", sessionCredentials.SessionToken)
+		assertEquals(t, true, expires.Equal(sessionCredentials.Expiration))
+
+		assertEquals(t, nil, provider.CleanupSessionCredentials("test-vol-id", "test-pod"))
+		_, err = os.Stat(path.Join(pluginDir, filename))
+		assertEquals(t, true, os.IsNotExist(err))
+		// Cleaning up again should not fail
+		assertEquals(t, nil, provider.CleanupSessionCredentials("test-vol-id", "test-pod"))
+	})
+
+	t.Run("reuses session until close to expiry", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t, time.Now().Add(time.Hour))
+
+		first, err := assumeRole(t, provider, volumeCtx)
+		assertEquals(t, nil, err)
+		second, err := assumeRole(t, provider, volumeCtx)
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		// Reused session should report when it was actually refreshed
+		assertEquals(t, true, first.Expiration.Equal(second.Expiration))
+		assertEquals(t, false, second.RefreshedAt.After(first.RefreshedAt.Add(time.Second)))
+
+		// Removing the session credentials should assume the role again
+		roleAssumer.expires = time.Now().Add(5 * time.Minute)
+		assertEquals(t, nil, provider.CleanupSessionCredentials("test-vol-id", "test-pod"))
+		_, err = assumeRole(t, provider, volumeCtx)
+		assertEquals(t, nil, err)
+		assertEquals(t, 2, len(roleAssumer.inputs))
+
+		// Session expires within the refresh window, so it should be refreshed
+		_, err = assumeRole(t, provider, volumeCtx)
+		assertEquals(t, nil, err)
+		assertEquals(t, 3, len(roleAssumer.inputs))
+	})
+
+	t.Run("fails without pod info", func(t *testing.T) {
+		provider, _, _ := setup(t, time.Now().Add(time.Hour))
+
+		_, err := assumeRole(t, provider, map[string]string{"stsRoleArn": "arn:aws:iam::123456789012:role/Chained", "stsExternalId": "test-external-id"})
+		if err == nil {
+			t.Fatal("Expected an error without pod info")
+		}
+	})
+
+	t.Run("fails with driver's credentials for roles not allowed", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t, time.Now().Add(time.Hour))
+
+		for name, volumeCtx := range map[string]map[string]string{
+			"without external ID": {
+				"stsRoleArn":                 "arn:aws:iam::123456789012:role/Other",
+				"csi.storage.k8s.io/pod.uid": "test-pod",
+			},
+			"with external ID": {
+				"stsRoleArn":                 "arn:aws:iam::123456789012:role/Other",
+				"stsExternalId":              "test-external-id",
+				"csi.storage.k8s.io/pod.uid": "test-pod",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := assumeRole(t, provider, volumeCtx)
+				assertEquals(t, codes.PermissionDenied, status.Code(err))
+				assertEquals(t, 0, len(roleAssumer.inputs))
+			})
+		}
+	})
+
+	t.Run("assumes allowed role with driver's credentials without external ID", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t, time.Now().Add(time.Hour))
+
+		_, err := assumeRole(t, provider, map[string]string{
+			"stsRoleArn":                 "arn:aws:iam::123456789012:role/Chained",
+			"csi.storage.k8s.io/pod.uid": "test-pod",
+		})
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "", roleAssumer.inputs[0].ExternalID)
+	})
+
+	t.Run("assumes role not allowed with credentials from secret", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t, time.Now().Add(time.Hour))
+
+		secretCtx := map[string]string{
+			"stsRoleArn":                 "arn:aws:iam::123456789012:role/Other",
+			"csi.storage.k8s.io/pod.uid": "test-pod",
+		}
+		base, err := provider.ProvideFromSecret(secretCtx, map[string]string{
+			"key_id":     "secret-access-key-id",
+			"access_key": "secret-secret-access-key",
+		})
+		assertEquals(t, nil, err)
+		_, err = provider.AssumeRole(context.Background(), "test-vol-id", secretCtx, mountpoint.ParseArgs(nil), base)
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+	})
+
+	t.Run("fails with profile from shared credentials file", func(t *testing.T) {
+		provider, _, _ := setup(t, time.Now().Add(time.Hour))
+
+		base, err := provider.ProvideFromSecret(map[string]string{}, map[string]string{
+			"credentials": "[default]\naws_access_key_id = test-access-key\naws_secret_access_key = test-secret-key\n",
+		})
+		assertEquals(t, nil, err)
+		_, err = provider.AssumeRole(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil), base)
+		if err == nil {
+			t.Fatal("Expected an error with awsProfile")
+		}
+	})
+}
+
+func TestProvidingPodLevelCredentialsWithCustomSTSEndpoint(t *testing.T) {
+	clientset := fake.NewSimpleClientset(serviceAccount("test-sa", "test-ns", map[string]string{
+		"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test",
+	}))
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("HOST_PLUGIN_DIR", "/test/csi/plugin/dir")
+
+	volumeContext := func() map[string]string {
+		return map[string]string{
+			"authenticationSource":                   "pod",
+			"csi.storage.k8s.io/pod.uid":             "test-pod",
+			"csi.storage.k8s.io/pod.namespace":       "test-ns",
+			"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+			"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
+				"sts.amazonaws.com": {
+					Token: "test-service-account-token",
+				},
+			}),
+		}
+	}
+
+	setup := func(t *testing.T) (*mounter.CredentialProvider, *fakeRoleAssumer, string) {
+		pluginDir := t.TempDir()
+		roleAssumer := &fakeRoleAssumer{expires: time.Now().Add(time.Hour)}
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, mounter.RegionFromIMDSOnce)
+		provider.SetRoleAssumer(roleAssumer)
+		return provider, roleAssumer, pluginDir
+	}
+
+	t.Run("exchanges token with driver-level endpoint", func(t *testing.T) {
+		provider, roleAssumer, pluginDir := setup(t)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{Endpoint: "https://vpce-1234.sts.eu-west-1.vpce.amazonaws.com"}))
+
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeContext(), mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "arn:aws:iam::123456789012:role/Test", roleAssumer.inputs[0].RoleARN)
+		assertEquals(t, "https://vpce-1234.sts.eu-west-1.vpce.amazonaws.com", roleAssumer.inputs[0].Endpoint)
+		assertEquals(t, "eu-west-1", roleAssumer.inputs[0].Region)
+		assertEquals(t, path.Join(pluginDir, "test-pod-test-vol-id.token"), roleAssumer.inputs[0].WebIdentityTokenFile)
+
+		assertEquals(t, "", credentials.WebTokenPath)
+		assertEquals(t, true, credentials.DisableIMDSProvider)
+		assertEquals(t, "cat /test/csi/plugin/dir/test-pod-test-vol-id.credentials.json", credentials.CredentialProcess)
+		assertEquals(t, "test-ns/test-sa", credentials.MountpointCacheKey)
+	})
+
+	t.Run("volume attribute overrides driver-level endpoint", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{Endpoint: "https://vpce-1234.sts.eu-west-1.vpce.amazonaws.com"}))
+
+		volumeCtx := volumeContext()
+		volumeCtx["stsEndpoint"] = "https://sts.example.com"
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, "https://sts.example.com", roleAssumer.inputs[0].Endpoint)
+	})
+
+	t.Run("uses regional endpoint via Mountpoint without endpoint", func(t *testing.T) {
+		provider, roleAssumer, _ := setup(t)
+
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeContext(), mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, 0, len(roleAssumer.inputs))
+		assertEquals(t, "", credentials.CredentialProcess)
+		assertEquals(t, "/test/csi/plugin/dir/test-pod-test-vol-id.token", credentials.WebTokenPath)
+	})
+
+	t.Run("exchanges token itself with dual-stack endpoints", func(t *testing.T) {
+		t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "true")
+		provider, roleAssumer, _ := setup(t)
+
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeContext(), mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "", roleAssumer.inputs[0].Endpoint)
+		assertEquals(t, "", credentials.WebTokenPath)
+		assertEquals(t, "cat /test/csi/plugin/dir/test-pod-test-vol-id.credentials.json", credentials.CredentialProcess)
+	})
+
+	t.Run("fails with invalid endpoint", func(t *testing.T) {
+		provider, _, _ := setup(t)
+
+		volumeCtx := volumeContext()
+		volumeCtx["stsEndpoint"] = "sts.example.com"
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		if err == nil {
+			t.Fatal("Expected an error for invalid stsEndpoint")
+		}
+
+		volumeCtx["stsEndpoint"] = "http://sts.example.com"
+		_, err = provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		if err == nil {
+			t.Fatal("Expected an error for stsEndpoint without TLS")
+		}
+
+		err = provider.SetDefaultSTSConfig(mounter.STSConfig{Endpoint: "ftp://sts.example.com"})
+		if err == nil {
+			t.Fatal("Expected an error for invalid driver-level STS endpoint")
+		}
+	})
+}
+
+func TestScopingSessionPolicies(t *testing.T) {
+	clientset := fake.NewSimpleClientset(serviceAccount("test-sa", "test-ns", map[string]string{
+		"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test",
+	}))
+	t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("HOST_PLUGIN_DIR", "/test/csi/plugin/dir")
+
+	setup := func(t *testing.T) (*mounter.CredentialProvider, *fakeRoleAssumer) {
+		roleAssumer := &fakeRoleAssumer{expires: time.Now().Add(time.Hour)}
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		provider.SetRoleAssumer(roleAssumer)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{AllowedRoleARNs: []string{"arn:aws:iam::123456789012:role/Chained"}}))
+		return provider, roleAssumer
+	}
+	t.Run("scopes assumed role to prefix of the volume", func(t *testing.T) {
+		provider, roleAssumer := setup(t)
+		volumeCtx := map[string]string{
+			"bucketName":                 "test-bucket",
+			"stsRoleArn":                 "arn:aws:iam::123456789012:role/Chained",
+			"stsExternalId":              "test-external-id",
+			"scopedSessionPolicy":        "true",
+			"csi.storage.k8s.io/pod.uid": "test-pod",
+		}
+		args := mountpoint.ParseArgs([]string{"--prefix=data/", "--allow-delete"})
+		base, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, args)
+		assertEquals(t, nil, err)
+		_, err = provider.AssumeRole(context.Background(), "test-vol-id", volumeCtx, args, base)
+		assertEquals(t, nil, err)
+
+		assertEquals(t, `{"Version":"2012-10-17","Statement":[`+
+			`{"Effect":"Allow","Action":["s3:ListBucket"],"Resource":["arn:aws:s3:::test-bucket"],"Condition":{"StringLike":{"s3:prefix":"data/*"}}},`+
+			`{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject","s3:AbortMultipartUpload","s3:DeleteObject"],"Resource":["arn:aws:s3:::test-bucket/data/*"]}]}`,
+			roleAssumer.inputs[0].Policy)
+	})
+
+	t.Run("exchanges pod-level token with read-only session policy", func(t *testing.T) {
+		provider, roleAssumer := setup(t)
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", map[string]string{
+			"bucketName":                             "test-bucket",
+			"authenticationSource":                   "pod",
+			"scopedSessionPolicy":                    "true",
+			"csi.storage.k8s.io/pod.uid":             "test-pod",
+			"csi.storage.k8s.io/pod.namespace":       "test-ns",
+			"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+			"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
+				"sts.amazonaws.com": {Token: "test-service-account-token"},
+			}),
+		}, mountpoint.ParseArgs([]string{"--read-only"}))
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "arn:aws:iam::123456789012:role/Test", roleAssumer.inputs[0].RoleARN)
+		assertEquals(t, "", roleAssumer.inputs[0].Endpoint)
+		assertEquals(t, "cat /test/csi/plugin/dir/test-pod-test-vol-id.credentials.json", credentials.CredentialProcess)
+
+		assertEquals(t, `{"Version":"2012-10-17","Statement":[`+
+			`{"Effect":"Allow","Action":["s3:ListBucket"],"Resource":["arn:aws:s3:::test-bucket"]},`+
+			`{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::test-bucket/*"]}]}`,
+			roleAssumer.inputs[0].Policy)
+	})
+
+	t.Run("fails without a role session", func(t *testing.T) {
+		provider, _ := setup(t)
+		_, err := provider.Provide(context.Background(), "test-vol-id", map[string]string{
+			"bucketName":          "test-bucket",
+			"scopedSessionPolicy": "true",
+		}, mountpoint.ParseArgs(nil))
+		if err == nil {
+			t.Fatal("Expected an error with driver-level credentials without stsRoleArn")
+		}
+	})
+}
+
+func TestCheckingTrustOfPodLevelRoles(t *testing.T) {
+	clientset := fake.NewSimpleClientset(serviceAccount("test-sa", "test-ns", map[string]string{
+		"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test",
+	}))
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("HOST_PLUGIN_DIR", "/test/csi/plugin/dir")
+
+	volumeCtx := map[string]string{
+		"authenticationSource":                   "pod",
+		"csi.storage.k8s.io/pod.uid":             "test-pod",
+		"csi.storage.k8s.io/pod.namespace":       "test-ns",
+		"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+		"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
+			"sts.amazonaws.com": {Token: "test-service-account-token"},
+		}),
+	}
+	accessDenied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Not authorized to perform sts:AssumeRoleWithWebIdentity"}
+
+	setup := func(t *testing.T, err error) (*mounter.CredentialProvider, *fakeRoleAssumer) {
+		roleAssumer := &fakeRoleAssumer{expires: time.Now().Add(time.Hour), err: err}
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		provider.SetRoleAssumer(roleAssumer)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{CheckTrust: true}))
+		return provider, roleAssumer
+	}
+
+	t.Run("hands token to Mountpoint once trust is checked", func(t *testing.T) {
+		provider, roleAssumer := setup(t, nil)
+
+		for i := 0; i < 2; i++ {
+			credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+			assertEquals(t, nil, err)
+			assertEquals(t, "/test/csi/plugin/dir/test-pod-test-vol-id.token", credentials.WebTokenPath)
+			assertEquals(t, "", credentials.CredentialProcess)
+		}
+		// Successful checks are reused
+		assertEquals(t, 1, len(roleAssumer.inputs))
+		assertEquals(t, "arn:aws:iam::123456789012:role/Test", roleAssumer.inputs[0].RoleARN)
+	})
+
+	t.Run("fails with missing trust", func(t *testing.T) {
+		provider, _ := setup(t, accessDenied)
+
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, codes.PermissionDenied, status.Code(err))
+		if !strings.Contains(err.Error(), "Role arn:aws:iam::123456789012:role/Test trust policy does not include system:serviceaccount:test-ns:test-sa") {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	})
+
+	t.Run("ignores inconclusive checks", func(t *testing.T) {
+		provider, _ := setup(t, errors.New("connection refused"))
+
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+	})
+
+	t.Run("reports missing trust while exchanging token itself", func(t *testing.T) {
+		provider, _ := setup(t, accessDenied)
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{Endpoint: "https://sts.example.com"}))
+
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, codes.PermissionDenied, status.Code(err))
+		if !strings.Contains(err.Error(), "does not include system:serviceaccount:test-ns:test-sa") {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	})
+}
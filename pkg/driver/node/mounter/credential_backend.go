@@ -0,0 +1,77 @@
+package mounter
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// AuthenticationSourceVault retrieves credentials of volumes from HashiCorp Vault's AWS secrets engine,
+// if a [CredentialBackend] is set for it.
+const AuthenticationSourceVault AuthenticationSource = "vault"
+
+const vaultDocsPage = "https://github.com/awslabs/mountpoint-s3-csi-driver/blob/main/docs/CONFIGURATION.md#credentials-from-hashicorp-vault"
+
+// A CredentialBackend retrieves short-lived credentials for volumes from an external secrets store, e.g. Vault.
+type CredentialBackend interface {
+	Credentials(ctx context.Context, volumeCtx map[string]string) (aws.Credentials, error)
+}
+
+// SetCredentialBackend sets the [CredentialBackend] used for volumes with given `authenticationSource` volume attribute.
+func (c *CredentialProvider) SetCredentialBackend(source AuthenticationSource, backend CredentialBackend) {
+	if c.credentialBackends == nil {
+		c.credentialBackends = make(map[AuthenticationSource]CredentialBackend)
+	}
+	c.credentialBackends[source] = backend
+}
+
+// provideFromBackend provides credentials for the volume retrieved from `backend`.
+//
+// Credentials are written to a file in the plugin directory, which Mountpoint reads via `credential_process`
+// whenever its credentials expire. They're retrieved again on each call once they're close to expiry,
+// which keeps the file up-to-date as kubelet republishes volumes periodically.
+func (c *CredentialProvider) provideFromBackend(ctx context.Context, volumeID string, volumeCtx map[string]string, backend CredentialBackend) (*MountCredentials, error) {
+	source := volumeCtx[volumecontext.AuthenticationSource]
+	klog.V(4).Infof("NodePublishVolume: Using credentials from %s", source)
+
+	if err := checkScopedSessionPolicy(volumeCtx); err != nil {
+		return nil, err
+	}
+
+	podID := volumeCtx[volumecontext.CSIPodUID]
+	if podID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing Pod info. Please make sure to enable `podInfoOnMountCompat`, see "+podLevelCredentialsDocsPage)
+	}
+
+	base := &MountCredentials{
+		AuthenticationSource: source,
+		Region:               os.Getenv(envprovider.EnvRegion),
+		DefaultRegion:        os.Getenv(envprovider.EnvDefaultRegion),
+		StsEndpoints:         os.Getenv(envprovider.EnvSTSRegionalEndpoints),
+	}
+	return c.provideRefreshedCredentials(volumeID, podID, base, func() (aws.Credentials, error) {
+		klog.V(4).Infof("NodePublishVolume: Retrieving credentials from %s for volume %s", source, volumeID)
+
+		credentials, err := backend.Credentials(ctx, volumeCtx)
+		if err != nil {
+			if _, ok := status.FromError(err); ok {
+				return aws.Credentials{}, err
+			}
+			return aws.Credentials{}, status.Errorf(codes.Unavailable, "Failed to retrieve credentials from %s: %v", source, err)
+		}
+		return credentials, nil
+	})
+}
+
+// usesCredentialBackend returns whether credentials of the volume with given `volumeCtx` are retrieved from a [CredentialBackend].
+func (c *CredentialProvider) usesCredentialBackend(volumeCtx map[string]string) bool {
+	_, ok := c.credentialBackends[volumeCtx[volumecontext.AuthenticationSource]]
+	return ok
+}
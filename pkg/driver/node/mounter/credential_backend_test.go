@@ -0,0 +1,122 @@
+package mounter_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+type fakeCredentialBackend struct {
+	calls   int
+	expires time.Time
+	err     error
+}
+
+func (f *fakeCredentialBackend) Credentials(_ context.Context, volumeCtx map[string]string) (aws.Credentials, error) {
+	f.calls++
+	if f.err != nil {
+		return aws.Credentials{}, f.err
+	}
+	return aws.Credentials{
+		AccessKeyID:     "backend-access-key-" + volumeCtx["vaultRole"],
+		SecretAccessKey: "backend-secret-key",
+		SessionToken:    "backend-session-token",
+		CanExpire:       true,
+		Expires:         f.expires,
+	}, nil
+}
+
+func TestProvidingCredentialsFromBackend(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("HOST_PLUGIN_DIR", "/test/csi/plugin/dir")
+
+	volumeCtx := map[string]string{
+		"authenticationSource":       "vault",
+		"vaultRole":                  "s3-reader",
+		"csi.storage.k8s.io/pod.uid": "test-pod",
+	}
+	setup := func(t *testing.T, backend *fakeCredentialBackend) (*mounter.CredentialProvider, string) {
+		pluginDir := t.TempDir()
+		provider := mounter.NewCredentialProvider(nil, pluginDir, mounter.RegionFromIMDSOnce)
+		provider.SetCredentialBackend(mounter.AuthenticationSourceVault, backend)
+		return provider, pluginDir
+	}
+
+	t.Run("writes credentials until close to expiry", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		backend := &fakeCredentialBackend{expires: expires}
+		provider, pluginDir := setup(t, backend)
+
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, "vault", credentials.AuthenticationSource)
+		assertEquals(t, "", credentials.AccessKeyID)
+		assertEquals(t, true, credentials.DisableIMDSProvider)
+		assertEquals(t, "eu-west-1", credentials.Region)
+		assertEquals(t, true, expires.Equal(credentials.Expiration))
+
+		filename, ok := strings.CutPrefix(credentials.CredentialProcess, "cat /test/csi/plugin/dir/")
+		if !ok {
+			t.Fatalf("Unexpected credential process %q", credentials.CredentialProcess)
+		}
+		content, err := os.ReadFile(path.Join(pluginDir, filename))
+		assertEquals(t, nil, err)
+		assertEquals(t, true, strings.Contains(string(content), `"AccessKeyId":"backend-access-key-s3-reader"`))
+
+		// The CSI Driver uses the same credentials
+		sdkCredentials, ok := provider.SDKCredentials("test-vol-id", volumeCtx, credentials)
+		assertEquals(t, true, ok)
+		resolved, err := sdkCredentials.Retrieve(context.Background())
+		assertEquals(t, nil, err)
+		assertEquals(t, "backend-access-key-s3-reader", resolved.AccessKeyID)
+
+		_, err = provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, 1, backend.calls)
+
+		// Credentials close to expiry are retrieved again
+		backend.expires = time.Now().Add(5 * time.Minute)
+		provider, _ = setup(t, backend)
+		_, err = provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		_, err = provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, 3, backend.calls)
+	})
+
+	t.Run("fails if backend fails", func(t *testing.T) {
+		provider, _ := setup(t, &fakeCredentialBackend{err: errors.New("connection refused")})
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("fails without backend", func(t *testing.T) {
+		provider := mounter.NewCredentialProvider(nil, t.TempDir(), mounter.RegionFromIMDSOnce)
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+		assertEquals(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("rejects stsRoleArn", func(t *testing.T) {
+		provider, _ := setup(t, &fakeCredentialBackend{expires: time.Now().Add(time.Hour)})
+		chainedCtx := map[string]string{"stsRoleArn": "arn:aws:iam::123456789012:role/Chained"}
+		for k, v := range volumeCtx {
+			chainedCtx[k] = v
+		}
+		args := mountpoint.ParseArgs(nil)
+		base, err := provider.Provide(context.Background(), "test-vol-id", chainedCtx, args)
+		assertEquals(t, nil, err)
+		_, err = provider.AssumeRole(context.Background(), "test-vol-id", chainedCtx, args, base)
+		assertEquals(t, codes.InvalidArgument, status.Code(err))
+	})
+}
@@ -0,0 +1,70 @@
+package mounter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/processcreds"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+)
+
+// SetDriverCredentialProcess sets the command driver-level credentials are obtained with via `credential_process`,
+// see https://docs.aws.amazon.com/sdkref/latest/guide/feature-process-credentials.html. Driver-level credentials
+// are resolved from the environment of the CSI Driver (i.e., IRSA or IMDS) if it's empty.
+//
+// Mountpoint runs the command whenever its credentials expire, so its executable must exist at the same absolute path
+// where Mountpoint runs (i.e., on the host with the systemd mounter) and in the CSI Driver container, which runs
+// it to access buckets on behalf of Mountpoint (e.g., for `stsRoleArn`).
+func (c *CredentialProvider) SetDriverCredentialProcess(process string) error {
+	if process == "" {
+		c.driverCredentialProcess = ""
+		return nil
+	}
+	if strings.ContainsFunc(process, func(r rune) bool { return !unicode.IsPrint(r) }) {
+		return fmt.Errorf("credential process %q contains non-printable characters", process)
+	}
+	executable := strings.Fields(process)[0]
+	if !filepath.IsAbs(executable) {
+		return fmt.Errorf("executable of credential process %q must be an absolute path", executable)
+	}
+	if info, err := os.Stat(executable); err != nil {
+		return fmt.Errorf("failed to find executable of credential process: %w", err)
+	} else if info.Mode()&0111 == 0 {
+		return fmt.Errorf("executable of credential process %q is not executable", executable)
+	}
+	c.driverCredentialProcess = process
+	return nil
+}
+
+// provideFromCredentialProcess provides driver-level credentials obtained by running the driver's credential process.
+func (c *CredentialProvider) provideFromCredentialProcess() *MountCredentials {
+	klog.V(4).Infof("NodePublishVolume: Using driver identity from credential process")
+
+	return &MountCredentials{
+		AuthenticationSource: AuthenticationSourceDriver,
+		CredentialProcess:    c.driverCredentialProcess,
+		Region:               os.Getenv(envprovider.EnvRegion),
+		DefaultRegion:        os.Getenv(envprovider.EnvDefaultRegion),
+		StsEndpoints:         os.Getenv(envprovider.EnvSTSRegionalEndpoints),
+
+		// Ensure to disable IMDS provider
+		DisableIMDSProvider: true,
+	}
+}
+
+// isDriverCredentialProcess returns whether given mount credentials are obtained with the driver's credential process.
+func (c *CredentialProvider) isDriverCredentialProcess(credentials *MountCredentials) bool {
+	return c.driverCredentialProcess != "" && credentials.CredentialProcess == c.driverCredentialProcess
+}
+
+// driverCredentialProcessProvider returns a credentials provider running the driver's credential process,
+// to resolve the same credentials as Mountpoint in the CSI Driver.
+func (c *CredentialProvider) driverCredentialProcessProvider() aws.CredentialsProvider {
+	return aws.NewCredentialsCache(processcreds.NewProvider(c.driverCredentialProcess))
+}
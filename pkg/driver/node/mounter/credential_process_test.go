@@ -0,0 +1,67 @@
+package mounter_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+func TestProvidingCredentialsFromCredentialProcess(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "driver-access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "driver-secret-key")
+
+	executable := filepath.Join(t.TempDir(), "get-credentials")
+	err := os.WriteFile(executable, []byte(`#!/bin/sh
+echo '{"Version": 1, "AccessKeyId": "process-access-key", "SecretAccessKey": "process-secret-key", "SessionToken": "'"$1"'"}'
+`), 0755)
+	assertEquals(t, nil, err)
+
+	provider := mounter.NewCredentialProvider(nil, t.TempDir(), mounter.RegionFromIMDSOnce)
+
+	t.Run("rejects invalid commands", func(t *testing.T) {
+		for _, process := range []string{"get-credentials", executable + "-missing", executable + "\n--role s3"} {
+			if err := provider.SetDriverCredentialProcess(process); err == nil {
+				t.Fatalf("Expected an error for credential process %q", process)
+			}
+		}
+	})
+
+	assertEquals(t, nil, provider.SetDriverCredentialProcess(executable+" process-session-token"))
+
+	volumeCtx := map[string]string{"authenticationSource": "driver"}
+	credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+	assertEquals(t, nil, err)
+
+	// Mountpoint runs the process instead of using driver's own credentials
+	assertEquals(t, executable+" process-session-token", credentials.CredentialProcess)
+	assertEquals(t, "", credentials.AccessKeyID)
+	assertEquals(t, "", credentials.SecretAccessKey)
+	assertEquals(t, true, credentials.DisableIMDSProvider)
+	assertEquals(t, "eu-west-1", credentials.Region)
+
+	// The CSI Driver resolves the same credentials by running the process
+	sdkCredentials, ok := provider.SDKCredentials("test-vol-id", volumeCtx, credentials)
+	assertEquals(t, true, ok)
+	resolved, err := sdkCredentials.Retrieve(context.Background())
+	assertEquals(t, nil, err)
+	assertEquals(t, "process-access-key", resolved.AccessKeyID)
+	assertEquals(t, "process-session-token", resolved.SessionToken)
+
+	// Credentials from volume's secret take precedence
+	credentials, err = provider.ProvideFromSecret(volumeCtx, map[string]string{"key_id": "secret-access-key", "access_key": "secret-secret-key"})
+	assertEquals(t, nil, err)
+	assertEquals(t, "", credentials.CredentialProcess)
+	assertEquals(t, "secret-access-key", credentials.AccessKeyID)
+
+	// Unsetting the process falls back to driver's own credentials
+	assertEquals(t, nil, provider.SetDriverCredentialProcess(""))
+	credentials, err = provider.Provide(context.Background(), "test-vol-id", volumeCtx, mountpoint.ParseArgs(nil))
+	assertEquals(t, nil, err)
+	assertEquals(t, "", credentials.CredentialProcess)
+	assertEquals(t, "driver-access-key", credentials.AccessKeyID)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"strings"
@@ -16,6 +17,8 @@ import (
 	"github.com/google/renameio"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/klog/v2"
@@ -49,10 +52,50 @@ const serviceAccountTokenAudienceSTS = "sts.amazonaws.com"
 
 const serviceAccountRoleAnnotation = "eks.amazonaws.com/role-arn"
 
+// Keys of the AWS credentials in the Kubernetes Secret referenced by `nodePublishSecretRef` of a volume.
+// These are the same keys used in the driver-level `aws-secret`.
+const (
+	SecretKeyAccessKeyID     = "key_id"
+	SecretKeySecretAccessKey = "access_key"
+	SecretKeySessionToken    = "session_token"
+)
+
+// Key of the AWS shared credentials file in the Kubernetes Secret referenced by `nodePublishSecretRef` of a volume.
+// The profile to use from this file is selected with `awsProfile` volume attribute.
+const SecretKeyCredentialsFile = "credentials"
+
+// SecretKeyConfigFile is the key of an AWS shared config file, which is not supported in the Kubernetes Secret referenced
+// by `nodePublishSecretRef` of a volume, as its settings (e.g., `credential_process`) might run processes on the host.
+const SecretKeyConfigFile = "config"
+
+// defaultAWSProfile is the profile used from the shared credentials file if `awsProfile` volume attribute is not set.
+const defaultAWSProfile = "default"
+
 const podLevelCredentialsDocsPage = "https://github.com/awslabs/mountpoint-s3-csi-driver/blob/main/docs/CONFIGURATION.md#pod-level-credentials"
+const stsRoleDocsPage = "https://github.com/awslabs/mountpoint-s3-csi-driver/blob/main/docs/CONFIGURATION.md#assuming-a-role-with-stsrolearn"
 const stsConfigDocsPage = "https://github.com/awslabs/mountpoint-s3-csi-driver/blob/main/docs/CONFIGURATION.md#configuring-the-sts-region"
 
-var errUnknownRegion = errors.New("NodePublishVolume: Pod-level: unknown region")
+// DefaultServiceAccountWait is the default time to wait for missing service accounts of workload Pods with pod-level credentials.
+const DefaultServiceAccountWait = 10 * time.Second
+
+// serviceAccountInitialBackoff is the initial backoff between lookups of missing service accounts, doubled with each lookup.
+const serviceAccountInitialBackoff = 500 * time.Millisecond
+
+var errUnknownRegion = errors.New("no region found in `stsRegion` volume attribute, driver's `--sts-region`, `--region` mount option, `AWS_REGION` or `AWS_DEFAULT_REGION` env variables")
+
+// An STSConfig configures how the CSI Driver reaches STS for pod-level credentials and `stsRoleArn`.
+type STSConfig struct {
+	// Region is the STS region, it's detected automatically if empty.
+	Region string
+	// Endpoint is the URL of a custom STS endpoint, e.g., a regional STS interface VPC endpoint.
+	// The regional STS endpoint of the STS region is used if empty.
+	Endpoint string
+	// CheckTrust checks the roles of pod-level credentials trust the service accounts of workload Pods
+	// before handing their tokens to Mountpoint, to fail with an actionable error instead of Mountpoint's.
+	CheckTrust bool
+	// AllowedRoleARNs are the only roles `stsRoleArn` can assume with the CSI Driver's own credentials.
+	AllowedRoleARNs []string
+}
 
 type Token struct {
 	Token               string    `json:"token"`
@@ -63,6 +106,33 @@ type CredentialProvider struct {
 	client             k8sv1.CoreV1Interface
 	containerPluginDir string
 	regionFromIMDS     func() (string, error)
+	roleAssumer        RoleAssumer
+	// defaultSTSConfig is the driver-level STS configuration, which can be overridden per volume.
+	defaultSTSConfig STSConfig
+	trustChecks      trustChecks
+	// driverCredentialProcess is the `credential_process` command to obtain driver-level credentials with, if any.
+	driverCredentialProcess string
+	// credentialBackends are the backends to retrieve credentials from by `authenticationSource` volume attribute.
+	credentialBackends map[AuthenticationSource]CredentialBackend
+	// serviceAccountWait is how long to wait for missing service accounts of workload Pods with pod-level credentials.
+	serviceAccountWait time.Duration
+}
+
+// A ServiceAccountNotFoundError is returned for volumes with pod-level credentials if the service account of the
+// workload Pod does not exist, e.g. as it's created after the Pod by GitOps tooling. It's a retryable error with
+// `Unavailable` code, kubelet retries mounting the volume until the service account is created.
+type ServiceAccountNotFoundError struct {
+	Namespace string
+	Name      string
+}
+
+func (e *ServiceAccountNotFoundError) Error() string {
+	return fmt.Sprintf("Service account %s/%s of the Pod not found, waiting for it to be created", e.Namespace, e.Name)
+}
+
+// GRPCStatus returns the gRPC status of the error, which makes it retryable.
+func (e *ServiceAccountNotFoundError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
 }
 
 func NewCredentialProvider(client k8sv1.CoreV1Interface, containerPluginDir string, regionFromIMDS func() (string, error)) *CredentialProvider {
@@ -72,7 +142,31 @@ func NewCredentialProvider(client k8sv1.CoreV1Interface, containerPluginDir stri
 		_, _ = regionFromIMDS()
 	}()
 
-	return &CredentialProvider{client, containerPluginDir, regionFromIMDS}
+	return &CredentialProvider{
+		client:             client,
+		containerPluginDir: containerPluginDir,
+		regionFromIMDS:     regionFromIMDS,
+		roleAssumer:        stsRoleAssumer{},
+		serviceAccountWait: DefaultServiceAccountWait,
+	}
+}
+
+// SetServiceAccountWait sets how long to wait for missing service accounts of workload Pods with pod-level credentials
+// within a single call, before failing with [ServiceAccountNotFoundError]. Missing service accounts fail immediately if 0.
+func (c *CredentialProvider) SetServiceAccountWait(wait time.Duration) {
+	c.serviceAccountWait = wait
+}
+
+// SetDefaultSTSConfig sets the driver-level STS configuration used for volumes that do not configure
+// `stsRegion` or `stsEndpoint` volume attributes.
+func (c *CredentialProvider) SetDefaultSTSConfig(config STSConfig) error {
+	if config.Endpoint != "" {
+		if err := validateSTSEndpoint(config.Endpoint); err != nil {
+			return err
+		}
+	}
+	c.defaultSTSConfig = config
+	return nil
 }
 
 // CleanupToken cleans any created service token files for given volume and pod.
@@ -84,6 +178,15 @@ func (c *CredentialProvider) CleanupToken(volumeID string, podID string) error {
 	return err
 }
 
+// UsesPodCredentialFiles returns whether credentials of given volume are written to files per workload Pod
+// (e.g., service account tokens or session credentials of `stsRoleArn`), which are removed once the volume
+// is unpublished for that Pod, so they cannot be shared by a Mountpoint process serving multiple Pods.
+func (c *CredentialProvider) UsesPodCredentialFiles(volumeCtx map[string]string) bool {
+	authenticationSource := volumeCtx[volumecontext.AuthenticationSource]
+	return authenticationSource == AuthenticationSourcePod || authenticationSource == AuthenticationSourceVault ||
+		c.usesCredentialBackend(volumeCtx) || volumeCtx[volumecontext.STSRoleARN] != ""
+}
+
 // Provide provides mount credentials for given volume and volume context.
 // Depending on the configuration, it either returns driver-level or pod-level credentials.
 func (c *CredentialProvider) Provide(ctx context.Context, volumeID string, volumeCtx map[string]string, args mountpoint.Args) (*MountCredentials, error) {
@@ -91,17 +194,99 @@ func (c *CredentialProvider) Provide(ctx context.Context, volumeID string, volum
 		return nil, status.Error(codes.InvalidArgument, "Missing volume context")
 	}
 
+	if volumeCtx[volumecontext.AWSProfile] != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "`awsProfile` requires a shared credentials file in the volume's secret referenced by `nodePublishSecretRef`")
+	}
+
 	authenticationSource := volumeCtx[volumecontext.AuthenticationSource]
+	if backend, ok := c.credentialBackends[authenticationSource]; ok {
+		return c.provideFromBackend(ctx, volumeID, volumeCtx, backend)
+	}
+
 	switch authenticationSource {
 	case AuthenticationSourcePod:
 		return c.provideFromPod(ctx, volumeID, volumeCtx, args)
 	case AuthenticationSourceUnspecified, AuthenticationSourceDriver:
+		if err := checkScopedSessionPolicy(volumeCtx); err != nil {
+			return nil, err
+		}
+		if c.driverCredentialProcess != "" {
+			return c.provideFromCredentialProcess(), nil
+		}
 		return c.provideFromDriver()
+	case AuthenticationSourceVault:
+		return nil, status.Error(codes.FailedPrecondition, "`authenticationSource: vault` requires Vault to be configured in the CSI Driver, see "+vaultDocsPage)
 	default:
 		return nil, fmt.Errorf("unknown `authenticationSource`: %s, only `driver` (default option if not specified) and `pod` supported", authenticationSource)
 	}
 }
 
+// ProvideFromSecret provides mount credentials for given volume from `secrets`,
+// which are the contents of the Kubernetes Secret referenced by `nodePublishSecretRef` of the volume.
+//
+// Secret credentials are only supported with driver-level authentication, and driver's own credentials
+// (i.e., IRSA and IMDS) are not passed to Mountpoint to prevent falling back to driver's identity.
+func (c *CredentialProvider) ProvideFromSecret(volumeCtx map[string]string, secrets map[string]string) (*MountCredentials, error) {
+	authenticationSource := volumeCtx[volumecontext.AuthenticationSource]
+	if authenticationSource != AuthenticationSourceUnspecified && authenticationSource != AuthenticationSourceDriver {
+		return nil, status.Errorf(codes.InvalidArgument, "`nodePublishSecretRef` is only supported with `driver` authentication source, got: %s", authenticationSource)
+	}
+	if err := checkScopedSessionPolicy(volumeCtx); err != nil {
+		return nil, err
+	}
+
+	if _, ok := secrets[SecretKeyConfigFile]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Volume's secret must not contain %q key, only static credentials are supported in %q key", SecretKeyConfigFile, SecretKeyCredentialsFile)
+	}
+
+	klog.V(4).Infof("NodePublishVolume: Using credentials from volume's secret")
+
+	if credentialsFile := secrets[SecretKeyCredentialsFile]; credentialsFile != "" {
+		profile := volumeCtx[volumecontext.AWSProfile]
+		if profile == "" {
+			profile = defaultAWSProfile
+		}
+
+		return &MountCredentials{
+			AuthenticationSource:    AuthenticationSourceDriver,
+			ProfileName:             profile,
+			CredentialsFileContents: credentialsFile,
+			Region:                  os.Getenv(envprovider.EnvRegion),
+			DefaultRegion:           os.Getenv(envprovider.EnvDefaultRegion),
+			StsEndpoints:            os.Getenv(envprovider.EnvSTSRegionalEndpoints),
+
+			// Ensure to disable IMDS provider
+			DisableIMDSProvider: true,
+
+			fromSecret: true,
+		}, nil
+	}
+
+	if volumeCtx[volumecontext.AWSProfile] != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "`awsProfile` requires volume's secret to contain %q key", SecretKeyCredentialsFile)
+	}
+
+	accessKeyID, secretAccessKey := secrets[SecretKeyAccessKeyID], secrets[SecretKeySecretAccessKey]
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Volume's secret must contain %q and %q keys", SecretKeyAccessKeyID, SecretKeySecretAccessKey)
+	}
+
+	return &MountCredentials{
+		AuthenticationSource: AuthenticationSourceDriver,
+		AccessKeyID:          accessKeyID,
+		SecretAccessKey:      secretAccessKey,
+		SessionToken:         secrets[SecretKeySessionToken],
+		Region:               os.Getenv(envprovider.EnvRegion),
+		DefaultRegion:        os.Getenv(envprovider.EnvDefaultRegion),
+		StsEndpoints:         os.Getenv(envprovider.EnvSTSRegionalEndpoints),
+
+		// Ensure to disable IMDS provider
+		DisableIMDSProvider: true,
+
+		fromSecret: true,
+	}, nil
+}
+
 func (c *CredentialProvider) provideFromDriver() (*MountCredentials, error) {
 	klog.V(4).Infof("NodePublishVolume: Using driver identity")
 
@@ -148,7 +333,12 @@ func (c *CredentialProvider) provideFromPod(ctx context.Context, volumeID string
 
 	region, err := c.stsRegion(volumeCtx, args)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "Failed to detect STS AWS Region, please explicitly set the AWS Region, see "+stsConfigDocsPage)
+		return nil, status.Errorf(codes.InvalidArgument, "Failed to detect STS AWS Region: %v. Please explicitly set the AWS Region, see %s", err, stsConfigDocsPage)
+	}
+
+	stsEndpoint, err := c.stsEndpoint(volumeCtx)
+	if err != nil {
+		return nil, err
 	}
 
 	defaultRegion := os.Getenv(envprovider.EnvDefaultRegion)
@@ -172,8 +362,9 @@ func (c *CredentialProvider) provideFromPod(ctx context.Context, volumeID string
 	podNamespace := volumeCtx[volumecontext.CSIPodNamespace]
 	podServiceAccount := volumeCtx[volumecontext.CSIServiceAccountName]
 	cacheKey := podNamespace + "/" + podServiceAccount
+	subject := serviceAccountSubject(podNamespace, podServiceAccount)
 
-	return &MountCredentials{
+	credentials := &MountCredentials{
 		AuthenticationSource: AuthenticationSourcePod,
 
 		Region:        region,
@@ -194,7 +385,40 @@ func (c *CredentialProvider) provideFromPod(ctx context.Context, volumeID string
 		DisableIMDSProvider: true,
 
 		MountpointCacheKey: cacheKey,
-	}, nil
+
+		// The token is refreshed by kubelet and written on each call, Mountpoint reads it once its credentials expire
+		RefreshedAt: time.Now(),
+		Expiration:  stsToken.ExpirationTimestamp,
+	}
+
+	// Mountpoint can only use the IPv4-only regional STS endpoint and can't pass a session policy, so with a custom
+	// or dual-stack STS endpoint or a scoped session policy the CSI Driver exchanges the service account token for
+	// session credentials itself. If `stsRoleArn` is set, the exchange happens as part of assuming that role instead.
+	if volumeCtx[volumecontext.STSRoleARN] == "" {
+		policy, err := scopedSessionPolicy(volumeCtx, args, region)
+		if err != nil {
+			return nil, err
+		}
+		input := AssumeRoleInput{
+			RoleARN:              awsRoleARN,
+			SessionName:          assumedRoleSessionName(podID),
+			Region:               region,
+			Endpoint:             stsEndpoint,
+			WebIdentityTokenFile: c.tokenPathContainer(podID, volumeID),
+			Policy:               policy,
+			HTTPClient:           HTTPClientFor(volumeCtx),
+		}
+		if stsEndpoint != "" || policy != "" || envprovider.UseDualStackEndpoint() {
+			return c.provideSessionCredentials(ctx, volumeID, podID, subject, input, credentials)
+		}
+		if c.defaultSTSConfig.CheckTrust {
+			if err := c.checkTrust(ctx, input, subject); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return credentials, nil
 }
 
 func (c *CredentialProvider) writeToken(podID string, volumeID string, token *Token) error {
@@ -224,8 +448,11 @@ func (c *CredentialProvider) findPodServiceAccountRole(ctx context.Context, volu
 		return "", status.Error(codes.InvalidArgument, "Missing Pod info. Please make sure to enable `podInfoOnMountCompat`, see "+podLevelCredentialsDocsPage)
 	}
 
-	response, err := c.client.ServiceAccounts(podNamespace).Get(ctx, podServiceAccount, metav1.GetOptions{})
+	response, err := c.getServiceAccount(ctx, podNamespace, podServiceAccount)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", &ServiceAccountNotFoundError{Namespace: podNamespace, Name: podServiceAccount}
+		}
 		return "", status.Errorf(codes.InvalidArgument, "Failed to get pod's service account %s/%s: %v", podNamespace, podServiceAccount, err)
 	}
 
@@ -238,15 +465,42 @@ func (c *CredentialProvider) findPodServiceAccountRole(ctx context.Context, volu
 	return roleArn, nil
 }
 
+// getServiceAccount gets service account `name` in `namespace`. If it does not exist, it's looked up again with a
+// backoff for up to `serviceAccountWait`, as service accounts might be created after the Pods using them.
+func (c *CredentialProvider) getServiceAccount(ctx context.Context, namespace, name string) (*corev1.ServiceAccount, error) {
+	deadline := time.Now().Add(c.serviceAccountWait)
+	backoff := serviceAccountInitialBackoff
+	for {
+		serviceAccount, err := c.client.ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			return serviceAccount, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, err
+		}
+		backoff = min(backoff, remaining)
+		klog.V(4).Infof("NodePublishVolume: Pod-level: Service account %s/%s not found, looking it up again in %s", namespace, name, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 // stsRegion tries to detect AWS region to use for STS.
 //
 // It looks for the following (in-order):
 //  1. `stsRegion` passed via volume context
-//  2. Region set for S3 bucket via mount options
-//  3. `AWS_REGION` or `AWS_DEFAULT_REGION` env variables
-//  4. Calling IMDS to detect region
+//  2. Driver-level STS region
+//  3. Region set for S3 bucket via mount options
+//  4. `AWS_REGION` or `AWS_DEFAULT_REGION` env variables
+//  5. Calling IMDS to detect region
 //
-// It returns an error if all of them fails.
+// It returns an error describing all attempted sources if all of them fails.
 func (c *CredentialProvider) stsRegion(volumeCtx map[string]string, args mountpoint.Args) (string, error) {
 	region := volumeCtx[volumecontext.STSRegion]
 	if region != "" {
@@ -254,6 +508,12 @@ func (c *CredentialProvider) stsRegion(volumeCtx map[string]string, args mountpo
 		return region, nil
 	}
 
+	region = c.defaultSTSConfig.Region
+	if region != "" {
+		klog.V(5).Infof("NodePublishVolume: Pod-level: Detected STS region %s from driver-level configuration", region)
+		return region, nil
+	}
+
 	if region, ok := args.Value(mountpoint.ArgRegion); ok {
 		klog.V(5).Infof("NodePublishVolume: Pod-level: Detected STS region %s from S3 bucket region", region)
 		return region, nil
@@ -271,16 +531,46 @@ func (c *CredentialProvider) stsRegion(volumeCtx map[string]string, args mountpo
 		return region, nil
 	}
 
-	// We're ignoring the error here, makes a call to IMDS only once and logs the error in case of error
-	region, _ = c.regionFromIMDS()
+	// Makes a call to IMDS only once and logs the error in case of error
+	region, err := c.regionFromIMDS()
 	if region != "" {
 		klog.V(5).Infof("NodePublishVolume: Pod-level: Detected STS region %s from IMDS", region)
 		return region, nil
 	}
+	if err != nil {
+		return "", fmt.Errorf("%w, and failed to detect region from IMDS: %v", errUnknownRegion, err)
+	}
 
 	return "", errUnknownRegion
 }
 
+// stsEndpoint returns the custom STS endpoint to use for given volume, or an empty string to use the regional
+// STS endpoint. `stsEndpoint` volume attribute takes precedence over the driver-level STS endpoint.
+func (c *CredentialProvider) stsEndpoint(volumeCtx map[string]string) (string, error) {
+	endpoint := volumeCtx[volumecontext.STSEndpoint]
+	if endpoint == "" {
+		return c.defaultSTSConfig.Endpoint, nil
+	}
+
+	if err := validateSTSEndpoint(endpoint); err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid `stsEndpoint` volume attribute: %v", err)
+	}
+	return endpoint, nil
+}
+
+// validateSTSEndpoint validates that `endpoint` is an absolute HTTPS URL, as service account tokens and
+// session credentials are sent to and received from it.
+func validateSTSEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to parse STS endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("STS endpoint %q must be an absolute URL with https scheme", endpoint)
+	}
+	return nil
+}
+
 func hostPluginDirWithDefault() string {
 	hostPluginDir := os.Getenv(hostPluginDirEnv)
 	if hostPluginDir == "" {
@@ -6,12 +6,15 @@ import (
 	"errors"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
@@ -72,6 +75,96 @@ func TestProvidingDriverLevelCredentialsWithEmptyEnv(t *testing.T) {
 	assertEquals(t, credentials.AwsRoleArn, "")
 }
 
+func TestProvidingCredentialsFromSecret(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "driver-access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "driver-secret-key")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/Driver")
+
+	provider := mounter.NewCredentialProvider(nil, "", mounter.RegionFromIMDSOnce)
+
+	t.Run("uses credentials from secret", func(t *testing.T) {
+		credentials, err := provider.ProvideFromSecret(map[string]string{}, map[string]string{
+			"key_id":        "test-access-key",
+			"access_key":    "test-secret-key",
+			"session_token": "test-session-token",
+		})
+		assertEquals(t, nil, err)
+
+		assertEquals(t, credentials.AuthenticationSource, mounter.AuthenticationSourceDriver)
+		assertEquals(t, credentials.AccessKeyID, "test-access-key")
+		assertEquals(t, credentials.SecretAccessKey, "test-secret-key")
+		assertEquals(t, credentials.SessionToken, "test-session-token")
+		assertEquals(t, credentials.Region, "eu-west-1")
+		assertEquals(t, credentials.WebTokenPath, "")
+		assertEquals(t, credentials.AwsRoleArn, "")
+		assertEquals(t, credentials.DisableIMDSProvider, true)
+	})
+
+	t.Run("uses profile from shared credentials file in secret", func(t *testing.T) {
+		credentials, err := provider.ProvideFromSecret(map[string]string{"awsProfile": "team-a"}, map[string]string{
+			"credentials": "[team-a]\naws_access_key_id=test-access-key\naws_secret_access_key=test-secret-key\n",
+		})
+		assertEquals(t, nil, err)
+
+		assertEquals(t, credentials.AuthenticationSource, mounter.AuthenticationSourceDriver)
+		assertEquals(t, credentials.ProfileName, "team-a")
+		assertEquals(t, credentials.CredentialsFileContents, "[team-a]\naws_access_key_id=test-access-key\naws_secret_access_key=test-secret-key\n")
+		assertEquals(t, credentials.AccessKeyID, "")
+		assertEquals(t, credentials.DisableIMDSProvider, true)
+	})
+
+	t.Run("uses default profile from shared credentials file in secret", func(t *testing.T) {
+		credentials, err := provider.ProvideFromSecret(map[string]string{}, map[string]string{
+			"credentials": "[default]\naws_access_key_id=test-access-key\naws_secret_access_key=test-secret-key\n",
+		})
+		assertEquals(t, nil, err)
+		assertEquals(t, credentials.ProfileName, "default")
+	})
+
+	t.Run("fails with shared config file", func(t *testing.T) {
+		_, err := provider.ProvideFromSecret(map[string]string{"awsProfile": "team-a"}, map[string]string{
+			"credentials": "[team-a]\naws_access_key_id=test-access-key\naws_secret_access_key=test-secret-key\n",
+			"config":      "[profile team-a]\ncredential_process=/bin/sh -c exit\n",
+		})
+		if err == nil {
+			t.Fatal("Expected an error for shared config file in volume's secret")
+		}
+	})
+
+	t.Run("fails with profile but without shared credentials file", func(t *testing.T) {
+		_, err := provider.ProvideFromSecret(map[string]string{"awsProfile": "team-a"}, map[string]string{
+			"key_id":     "test-access-key",
+			"access_key": "test-secret-key",
+		})
+		if err == nil {
+			t.Fatal("Expected an error for profile without shared credentials file")
+		}
+
+		_, err = provider.Provide(context.Background(), "test-vol-id", map[string]string{"awsProfile": "team-a"}, mountpoint.ParseArgs(nil))
+		if err == nil {
+			t.Fatal("Expected an error for profile without volume's secret")
+		}
+	})
+
+	t.Run("fails with missing keys", func(t *testing.T) {
+		_, err := provider.ProvideFromSecret(map[string]string{}, map[string]string{"key_id": "test-access-key"})
+		if err == nil {
+			t.Fatal("Expected an error for secret without access_key")
+		}
+	})
+
+	t.Run("fails with pod-level authentication", func(t *testing.T) {
+		_, err := provider.ProvideFromSecret(map[string]string{"authenticationSource": "pod"}, map[string]string{
+			"key_id":     "test-access-key",
+			"access_key": "test-secret-key",
+		})
+		if err == nil {
+			t.Fatal("Expected an error for secret with pod-level authentication")
+		}
+	})
+}
+
 func TestProvidingPodLevelCredentials(t *testing.T) {
 	pluginDir := t.TempDir()
 	clientset := fake.NewSimpleClientset(serviceAccount("test-sa", "test-ns", map[string]string{
@@ -83,6 +176,7 @@ func TestProvidingPodLevelCredentials(t *testing.T) {
 	t.Setenv("AWS_STS_REGIONAL_ENDPOINTS", "regional")
 
 	provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, mounter.RegionFromIMDSOnce)
+	tokenExpiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
 
 	credentials, err := provider.Provide(context.Background(), "test-vol-id", map[string]string{
 		"authenticationSource":                   "pod",
@@ -91,7 +185,8 @@ func TestProvidingPodLevelCredentials(t *testing.T) {
 		"csi.storage.k8s.io/serviceAccount.name": "test-sa",
 		"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
 			"sts.amazonaws.com": {
-				Token: "test-service-account-token",
+				Token:               "test-service-account-token",
+				ExpirationTimestamp: tokenExpiration,
 			},
 		}),
 	}, mountpoint.ParseArgs(nil))
@@ -119,11 +214,67 @@ func TestProvidingPodLevelCredentials(t *testing.T) {
 
 	assertEquals(t, credentials.MountpointCacheKey, "test-ns/test-sa")
 
+	// Should report expiration of the service account token
+	assertEquals(t, true, credentials.Expiration.Equal(tokenExpiration))
+	assertEquals(t, false, credentials.RefreshedAt.IsZero())
+
 	token, err := os.ReadFile(tokenFilePath(credentials, pluginDir))
 	assertEquals(t, nil, err)
 	assertEquals(t, "test-service-account-token", string(token))
 }
 
+func TestProvidingPodLevelCredentialsWithServiceAccountCreatedLater(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	volumeContext := map[string]string{
+		"authenticationSource":                   "pod",
+		"csi.storage.k8s.io/pod.uid":             "test-pod",
+		"csi.storage.k8s.io/pod.namespace":       "test-ns",
+		"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+		"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
+			"sts.amazonaws.com": {
+				Token: "test-service-account-token",
+			},
+		}),
+	}
+
+	t.Run("created while waiting", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset()
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		provider.SetServiceAccountWait(5 * time.Second)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_, _ = clientset.CoreV1().ServiceAccounts("test-ns").Create(context.Background(), serviceAccount("test-sa", "test-ns", map[string]string{
+				"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test",
+			}), metav1.CreateOptions{})
+		}()
+
+		credentials, err := provider.Provide(context.Background(), "test-vol-id", volumeContext, mountpoint.ParseArgs(nil))
+		assertEquals(t, nil, err)
+		assertEquals(t, "arn:aws:iam::123456789012:role/Test", credentials.AwsRoleArn)
+	})
+
+	t.Run("still missing after waiting", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset()
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		provider.SetServiceAccountWait(200 * time.Millisecond)
+
+		start := time.Now()
+		_, err := provider.Provide(context.Background(), "test-vol-id", volumeContext, mountpoint.ParseArgs(nil))
+		if time.Since(start) < 200*time.Millisecond {
+			t.Errorf("Expected to wait for the service account before failing, failed after %s", time.Since(start))
+		}
+
+		var notFound *mounter.ServiceAccountNotFoundError
+		if !errors.As(err, &notFound) {
+			t.Fatalf("Expected ServiceAccountNotFoundError, got %v", err)
+		}
+		assertEquals(t, "test-ns", notFound.Namespace)
+		assertEquals(t, "test-sa", notFound.Name)
+		assertEquals(t, codes.Unavailable, status.Code(err))
+	})
+}
+
 func TestProvidingPodLevelCredentialsWithMissingInformation(t *testing.T) {
 	pluginDir := t.TempDir()
 	clientset := fake.NewSimpleClientset(
@@ -134,6 +285,7 @@ func TestProvidingPodLevelCredentialsWithMissingInformation(t *testing.T) {
 	)
 
 	provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, mounter.RegionFromIMDSOnce)
+	provider.SetServiceAccountWait(0)
 
 	for name, test := range map[string]struct {
 		volumeID      string
@@ -257,6 +409,8 @@ func TestProvidingPodLevelCredentialsRegionPopulation(t *testing.T) {
 		assertEquals(t, nil, credentials)
 		if err == nil {
 			t.Error("it should fail if there is not any region information")
+		} else if !strings.Contains(err.Error(), "failed to detect region from IMDS: unknown region") {
+			t.Errorf("it should report why region detection failed, got: %v", err)
 		}
 
 		_, err = os.ReadFile(path.Join(pluginDir, "test-pod-test-vol-id.token"))
@@ -389,6 +543,21 @@ func TestProvidingPodLevelCredentialsRegionPopulation(t *testing.T) {
 		assertEquals(t, "test-service-account-token", string(token))
 	})
 
+	t.Run("region from driver-level configuration", func(t *testing.T) {
+		pluginDir := t.TempDir()
+		provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, func() (string, error) {
+			return "us-east-1", nil
+		})
+		assertEquals(t, nil, provider.SetDefaultSTSConfig(mounter.STSConfig{Region: "ca-central-1"}))
+
+		t.Setenv("AWS_REGION", "eu-west-1")
+
+		credentials, err := provider.Provide(context.Background(), volumeID, volumeContext, mountpoint.ParseArgs([]string{"--region=us-west-1"}))
+		assertEquals(t, nil, err)
+		assertEquals(t, credentials.Region, "ca-central-1")
+		assertEquals(t, credentials.DefaultRegion, "ca-central-1")
+	})
+
 	t.Run("region from volume context", func(t *testing.T) {
 		pluginDir := t.TempDir()
 		provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, func() (string, error) {
@@ -569,6 +738,37 @@ func TestCleaningUpTokenFileForAVolume(t *testing.T) {
 	})
 }
 
+func TestUsingPodCredentialFiles(t *testing.T) {
+	provider := mounter.NewCredentialProvider(nil, t.TempDir(), mounter.RegionFromIMDSOnce)
+
+	for name, test := range map[string]struct {
+		volumeCtx map[string]string
+		expected  bool
+	}{
+		"driver-level credentials": {volumeCtx: map[string]string{}, expected: false},
+		"explicit driver-level credentials": {
+			volumeCtx: map[string]string{"authenticationSource": "driver"},
+			expected:  false,
+		},
+		"pod-level credentials": {
+			volumeCtx: map[string]string{"authenticationSource": "pod"},
+			expected:  true,
+		},
+		"vault": {
+			volumeCtx: map[string]string{"authenticationSource": "vault"},
+			expected:  true,
+		},
+		"driver-level credentials with stsRoleArn": {
+			volumeCtx: map[string]string{"stsRoleArn": "arn:aws:iam::123456789012:role/Chained"},
+			expected:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertEquals(t, test.expected, provider.UsesPodCredentialFiles(test.volumeCtx))
+		})
+	}
+}
+
 type tokens = map[string]struct {
 	Token               string `json:"token"`
 	ExpirationTimestamp time.Time
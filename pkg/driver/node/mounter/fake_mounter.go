@@ -16,3 +16,7 @@ func (m *FakeMounter) Unmount(target string) error {
 func (m *FakeMounter) IsMountPoint(target string) (bool, error) {
 	return false, nil
 }
+
+func (m *FakeMounter) BindMount(source string, target string, readOnly bool) error {
+	return nil
+}
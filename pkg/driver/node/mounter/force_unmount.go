@@ -0,0 +1,115 @@
+package mounter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/awsprofile"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
+)
+
+// A ForceUnmounter can forcefully unmount hung mounts, e.g. of a Mountpoint process not responding after a network
+// partition, which would otherwise block unmounts indefinitely.
+type ForceUnmounter interface {
+	// ForceUnmount aborts the FUSE connection of the Mountpoint process serving `target`, which makes the process exit
+	// and fails pending operations on the mount, and then detaches `target` lazily (`MNT_DETACH`).
+	ForceUnmount(target string) error
+}
+
+var (
+	// fuseConnectionsDir is where `fusectl` filesystem is mounted, which allows aborting FUSE connections.
+	fuseConnectionsDir = "/sys/fs/fuse/connections"
+	// mountInfoPath is the mount table to look up device numbers of FUSE mounts in, which identify their connections.
+	// It's read instead of calling `stat(2)` on the mount, as it would hang if Mountpoint is not responding.
+	mountInfoPath = "/proc/self/mountinfo"
+)
+
+// ForceUnmount aborts the FUSE connection of `target` and detaches it lazily with `umount --lazy` on the host.
+func (m *SystemdMounter) ForceUnmount(target string) error {
+	timeoutCtx, cancel := context.WithTimeout(m.Ctx, 30*time.Second)
+	defer cancel()
+
+	if err := abortFUSEConnection(target); err != nil {
+		return err
+	}
+
+	output, err := m.Runner.RunOneshot(timeoutCtx, &system.ExecConfig{
+		Name:        "mount-s3-umount-lazy-" + uuid.New().String() + ".service",
+		Description: "Mountpoint for Amazon S3 CSI driver lazy unmount",
+		ExecPath:    "/usr/bin/umount",
+		Args:        []string{"--lazy", target},
+	})
+	if err != nil {
+		return fmt.Errorf("Lazy unmount failed: %w unmount output: %s", err, output)
+	}
+
+	cleanupAfterUnmount(target)
+	return nil
+}
+
+// ForceUnmount aborts the FUSE connection of `target` and detaches it lazily with `umount2(2)`.
+func (m *ProcessMounter) ForceUnmount(target string) error {
+	if err := abortFUSEConnection(target); err != nil {
+		return err
+	}
+
+	if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+		return fmt.Errorf("Lazy unmount failed: %w", err)
+	}
+
+	cleanupAfterUnmount(target)
+	return nil
+}
+
+// abortFUSEConnection aborts the FUSE connection of the mount at `target` via `fusectl` filesystem, which is mounted
+// if it's not mounted in the container yet. It's a no-op if `target` is not mounted.
+func abortFUSEConnection(target string) error {
+	infos, err := mount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read mount table: %w", err)
+	}
+
+	connection := ""
+	for _, info := range infos {
+		if info.MountPoint == target {
+			// FUSE connections are named after the minor device number of their mounts.
+			connection = strconv.Itoa(info.Minor)
+		}
+	}
+	if connection == "" {
+		klog.V(4).Infof("ForceUnmount: %s is not mounted, skipping aborting its FUSE connection", target)
+		return nil
+	}
+
+	abortFile := filepath.Join(fuseConnectionsDir, connection, "abort")
+	if _, err := os.Stat(filepath.Dir(abortFile)); os.IsNotExist(err) {
+		if err := unix.Mount("fusectl", fuseConnectionsDir, "fusectl", 0, ""); err != nil && err != unix.EBUSY {
+			return fmt.Errorf("Failed to mount fusectl at %s: %w", fuseConnectionsDir, err)
+		}
+	}
+	if err := os.WriteFile(abortFile, []byte("1"), 0600); err != nil {
+		return fmt.Errorf("Failed to abort FUSE connection %s of %s: %w", connection, target, err)
+	}
+	klog.V(4).Infof("ForceUnmount: Aborted FUSE connection %s of %s", connection, target)
+	return nil
+}
+
+// cleanupAfterUnmount removes AWS profile and local cache of Mountpoint unmounted from `target`, like `Unmount`.
+func cleanupAfterUnmount(target string) {
+	basepath := filepath.Dir(target)
+	if err := awsprofile.CleanupAWSProfile(basepath); err != nil {
+		klog.V(4).Infof("ForceUnmount: Failed to clean up AWS Profile in %s: %v", basepath, err)
+	}
+	if err := os.RemoveAll(CacheDir(target)); err != nil {
+		klog.V(4).Infof("ForceUnmount: Failed to clean up cache directory of %s: %v", target, err)
+	}
+}
@@ -0,0 +1,33 @@
+package mounter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestAbortingFUSEConnection(t *testing.T) {
+	dir := t.TempDir()
+	fuseConnectionsDir = filepath.Join(dir, "connections")
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	t.Cleanup(func() {
+		fuseConnectionsDir, mountInfoPath = "/sys/fs/fuse/connections", "/proc/self/mountinfo"
+	})
+
+	target := "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	assert.NoError(t, os.WriteFile(mountInfoPath, []byte(
+		"22 1 259:1 / / rw,relatime shared:1 - ext4 /dev/root rw\n"+
+			"1022 22 0:312 / "+target+" rw,nosuid,nodev,relatime shared:512 - fuse mountpoint-s3 rw,user_id=0,group_id=0\n",
+	), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(fuseConnectionsDir, "312"), 0755))
+
+	assert.NoError(t, abortFUSEConnection(target))
+	abort, err := os.ReadFile(filepath.Join(fuseConnectionsDir, "312", "abort"))
+	assert.NoError(t, err)
+	assert.Equals(t, "1", string(abort))
+
+	// Targets that are not mounted anymore are skipped
+	assert.NoError(t, abortFUSEConnection("/var/lib/kubelet/pods/other-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"))
+}
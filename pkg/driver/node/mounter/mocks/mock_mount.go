@@ -90,6 +90,20 @@ func (m *MockMounter) EXPECT() *MockMounterMockRecorder {
 	return m.recorder
 }
 
+// BindMount mocks base method.
+func (m *MockMounter) BindMount(source, target string, readOnly bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindMount", source, target, readOnly)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindMount indicates an expected call of BindMount.
+func (mr *MockMounterMockRecorder) BindMount(source, target, readOnly interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindMount", reflect.TypeOf((*MockMounter)(nil).BindMount), source, target, readOnly)
+}
+
 // IsMountPoint mocks base method.
 func (m *MockMounter) IsMountPoint(target string) (bool, error) {
 	m.ctrl.T.Helper()
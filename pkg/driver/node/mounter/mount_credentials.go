@@ -1,6 +1,8 @@
 package mounter
 
 import (
+	"time"
+
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/awsprofile"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 )
@@ -18,6 +20,13 @@ type MountCredentials struct {
 	ConfigFilePath            string
 	SharedCredentialsFilePath string
 
+	// -- Profile provider from shared credentials file passed by users
+	ProfileName             string
+	CredentialsFileContents string
+
+	// -- Process provider, the process must be controlled by the CSI Driver as its run on the host
+	CredentialProcess string
+
 	// -- STS provider
 	WebTokenPath string
 	AwsRoleArn   string
@@ -32,11 +41,29 @@ type MountCredentials struct {
 
 	// -- TODO - Move somewhere better
 	MountpointCacheKey string
+
+	// -- Additional environment variables passed via `mountpointEnv` volume attribute,
+	// variables set by the fields above take precedence over them.
+	MountpointEnv envprovider.Environment
+
+	// fromSecret is whether these credentials are from the volume's secret rather than the CSI Driver's own identity.
+	fromSecret bool
+
+	// -- Observability, not passed to Mountpoint
+	// RefreshedAt is when the short-lived credentials (or the token they are obtained with) were last refreshed,
+	// zero for long-term credentials.
+	RefreshedAt time.Time
+	// Expiration is when the short-lived credentials (or the token they are obtained with) expire,
+	// zero for long-term credentials or if unknown.
+	Expiration time.Time
 }
 
 // Get environment variables to pass to mount-s3 for authentication.
 func (mc *MountCredentials) Env(awsProfile awsprofile.AWSProfile) envprovider.Environment {
 	env := envprovider.Environment{}
+	for key, value := range mc.MountpointEnv {
+		env.Set(key, value)
+	}
 
 	// For profile provider from long-term credentials
 	if awsProfile.Name != "" {
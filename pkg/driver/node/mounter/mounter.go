@@ -3,7 +3,11 @@ package mounter
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
@@ -19,6 +23,33 @@ type Mounter interface {
 	Mount(bucketName string, target string, credentials *MountCredentials, args mountpoint.Args) error
 	Unmount(target string) error
 	IsMountPoint(target string) (bool, error)
+	BindMount(source string, target string, readOnly bool) error
+}
+
+// IsBusy returns whether `err` returned by `Unmount` is due to the mount being busy, i.e. files still being open on it.
+// `umount` invoked via systemd only reports it in its output, while `umount(2)` returns `EBUSY`.
+func IsBusy(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || (err != nil && strings.Contains(err.Error(), "target is busy"))
+}
+
+// cacheDirName is the name of the local cache directory of Mountpoint, created next to the target path.
+const cacheDirName = "mountpoint-cache"
+
+// CacheDir returns the local cache directory for Mountpoint mounted at `target`.
+//
+// Kubernetes creates target path in the form of "/var/lib/kubelet/pods/<pod-uuid>/volumes/kubernetes.io~csi/<volume-id>/mount",
+// so the directory of the target path is unique for this mount. The cache directory is removed in `Unmount`.
+func CacheDir(target string) string {
+	return filepath.Join(filepath.Dir(target), cacheDirName)
+}
+
+// configFileName is the name of the Mountpoint configuration file, created next to the target path.
+const configFileName = "mountpoint-config.toml"
+
+// ConfigFile returns the path of the configuration file for Mountpoint mounted at `target`.
+// Similar to [CacheDir], it's unique for this mount and it's removed in `Unmount`.
+func ConfigFile(target string) string {
+	return filepath.Join(filepath.Dir(target), configFileName)
 }
 
 const MountS3PathEnv = "MOUNT_S3_PATH"
@@ -0,0 +1,122 @@
+package mounter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/awsprofile"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
+)
+
+// Kinds of mounters to spawn Mountpoint processes with.
+const (
+	// KindSystemd spawns Mountpoint processes on the host via systemd, see [SystemdMounter].
+	KindSystemd = "systemd"
+	// KindProcess spawns Mountpoint processes as child processes of the CSI Driver Node Pod, see [ProcessMounter].
+	KindProcess = "process"
+)
+
+// A ProcessMounter is a [SystemdMounter] spawning Mountpoint processes as child processes of the CSI Driver Node Pod
+// instead of systemd services on the host, for environments where the host's systemd is not accessible (e.g., Bottlerocket)
+// and spawning Mountpoint Pods is not allowed.
+//
+// Mounts are established in the container, so the kubelet directory must be mounted with `Bidirectional` mount
+// propagation to make them visible to the workload Pods. Mountpoint processes crashing or killed for exceeding their
+// [ProcessMounter.Supervisor] limits are restarted in place, which only makes the volume usable for containers started
+// afterwards: running containers keep their bind of the broken mount, so the workload Pod must be restarted.
+// They terminate with the container, in which case their mounts are re-established by the mount monitor once the
+// container restarts.
+type ProcessMounter struct {
+	*SystemdMounter
+	// Supervisor supervises Mountpoint processes, its restart policy and resource limits can be configured.
+	Supervisor *system.ProcessSupervisor
+}
+
+// NewProcessMounter returns a new mounter spawning Mountpoint processes as child processes.
+func NewProcessMounter(mpVersion string, kubernetesVersion string) *ProcessMounter {
+	m := &ProcessMounter{SystemdMounter: newSystemdMounter(nil, mpVersion, kubernetesVersion)}
+	m.foreground = true
+	m.Supervisor = system.NewProcessSupervisor(func(config *system.ExecConfig) (bool, error) {
+		return m.IsMountPoint(processTarget(config))
+	}, m.prepareRestart)
+	m.Runner = m.Supervisor
+	return m
+}
+
+// prepareRestart returns whether the exited Mountpoint process described by `config` should be restarted, which
+// is only the case if its mount is still in place, i.e., it was not unmounted (forcefully) before it exited.
+// The broken mount is detached lazily, so the restarted process can mount at the same target. Running containers
+// still see the broken mount, as they hold their own bind of it.
+func (m *ProcessMounter) prepareRestart(config *system.ExecConfig) (bool, error) {
+	target := processTarget(config)
+	isMountPoint, err := m.IsMountPoint(target)
+	if err != nil || !isMountPoint {
+		return false, err
+	}
+	if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+		return false, fmt.Errorf("Failed to detach broken mount: %w", err)
+	}
+	return true, nil
+}
+
+// processTarget returns the target of the Mountpoint process described by `config`, which is always its last
+// argument, see [SystemdMounter.Mount].
+func processTarget(config *system.ExecConfig) string {
+	return config.Args[len(config.Args)-1]
+}
+
+// Unmount unmounts `target` with `umount(2)`, as the CSI Driver Node Pod does not have `umount` binary.
+// Mountpoint process serving `target` exits once it's unmounted.
+func (m *ProcessMounter) Unmount(target string) error {
+	basepath := filepath.Dir(target)
+	if err := awsprofile.CleanupAWSProfile(basepath); err != nil {
+		klog.V(4).Infof("Unmount: Failed to clean up AWS Profile in %s: %v", basepath, err)
+	}
+	removeConfigFile(target)
+
+	if err := unix.Unmount(target, 0); err != nil && err != unix.EINVAL && err != unix.ENOENT {
+		return fmt.Errorf("Unmount failed: %w", err)
+	}
+
+	// Remove the local cache only after Mountpoint is terminated.
+	if err := os.RemoveAll(CacheDir(target)); err != nil {
+		klog.V(4).Infof("Unmount: Failed to clean up cache directory of %s: %v", target, err)
+	}
+	return nil
+}
+
+// BindMount bind mounts an existing `mount-s3` mount at `source` to `target` with `mount(2)`,
+// and makes it read-only if `readOnly` is set.
+func (m *ProcessMounter) BindMount(source string, target string, readOnly bool) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("Failed to create target directory: %w", err)
+	}
+
+	isMountPoint, err := m.IsMountPoint(target)
+	if err != nil {
+		return fmt.Errorf("Could not check if %q is a mount point: %w", target, err)
+	}
+	if isMountPoint {
+		klog.V(4).Infof("BindMount: Target path %q is already mounted", target)
+		return nil
+	}
+
+	if err := unix.Mount(source, target, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("Bind mount failed: %w", err)
+	}
+
+	if readOnly {
+		// Bind mounts inherit mount flags of their source, and can only be made read-only with a remount.
+		if err := unix.Mount("", target, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY, ""); err != nil {
+			if unmountErr := unix.Unmount(target, 0); unmountErr != nil {
+				klog.V(4).Infof("BindMount: Failed to unmount %q after failing to make it read-only: %v", target, unmountErr)
+			}
+			return fmt.Errorf("Read-only bind mount failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package mounter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+)
+
+// HTTPClientFor returns an HTTP client for the AWS SDK calls made on behalf of a volume, which uses the proxy
+// overridden by `httpProxy`, `httpsProxy` and `noProxy` attributes in `volumeCtx` and trusts the CA bundle in
+// `AWS_CA_BUNDLE` environment variable of the CSI Driver.
+//
+// It returns nil if neither is configured, as the default HTTP client of the AWS SDK already uses the proxy
+// environment variables of the CSI Driver.
+func HTTPClientFor(volumeCtx map[string]string) aws.HTTPClient {
+	proxy, err := envprovider.ProxyFor(volumeCtx)
+	if err != nil {
+		// Volume attributes are validated before providing credentials, so this should never happen.
+		klog.V(4).Infof("Ignoring invalid proxy settings of volume: %v", err)
+		proxy = nil
+	}
+	caBundle := envprovider.CABundle()
+	if len(proxy) == 0 && caBundle == "" {
+		return nil
+	}
+
+	var rootCAs *x509.CertPool
+	if caBundle != "" {
+		rootCAs = loadCABundle(caBundle)
+	}
+	proxyFunc := proxy.ProxyFunc()
+	return awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+		if rootCAs != nil {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = rootCAs
+		}
+	})
+}
+
+// loadCABundle returns the system trust store with the certificates in the CA bundle at `path` appended,
+// or nil to use the system trust store if the CA bundle cannot be read.
+func loadCABundle(path string) *x509.CertPool {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		klog.Errorf("Failed to read CA bundle %s, using the system trust store: %v", path, err)
+		return nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		klog.Errorf("No certificates found in CA bundle %s, using the system trust store", path)
+		return nil
+	}
+	return pool
+}
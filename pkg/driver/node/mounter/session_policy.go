@@ -0,0 +1,141 @@
+package mounter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// directoryBucketSuffix is the suffix of the names of directory buckets.
+const directoryBucketSuffix = "--x-s3"
+
+// sessionPolicyDocument represents an IAM policy document passed as the inline session policy of assumed roles.
+type sessionPolicyDocument struct {
+	Version   string
+	Statement []sessionPolicyStatement
+}
+
+type sessionPolicyStatement struct {
+	Effect    string
+	Action    []string
+	Resource  []string
+	Condition map[string]map[string]string `json:",omitempty"`
+}
+
+// scopedSessionPolicyEnabled returns whether `scopedSessionPolicy` volume attribute is enabled in `volumeCtx`.
+func scopedSessionPolicyEnabled(volumeCtx map[string]string) (bool, error) {
+	value, ok := volumeCtx[volumecontext.ScopedSessionPolicy]
+	if !ok {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "%s %q must be a boolean", volumecontext.ScopedSessionPolicy, value)
+	}
+	return enabled, nil
+}
+
+// checkScopedSessionPolicy returns an error if `scopedSessionPolicy` volume attribute is enabled in `volumeCtx`
+// of a volume using driver-level credentials without `stsRoleArn`, as there is no role session to scope down.
+func checkScopedSessionPolicy(volumeCtx map[string]string) error {
+	enabled, err := scopedSessionPolicyEnabled(volumeCtx)
+	if err != nil {
+		return err
+	}
+	if enabled && volumeCtx[volumecontext.STSRoleARN] == "" {
+		return status.Errorf(codes.InvalidArgument, "%s requires %s or pod-level credentials", volumecontext.ScopedSessionPolicy, volumecontext.STSRoleARN)
+	}
+	return nil
+}
+
+// scopedSessionPolicy returns an inline session policy which scopes permissions of an assumed role down to
+// what Mountpoint needs for the volume in `volumeCtx` mounted with `args`, or an empty policy if it's not enabled
+// with `scopedSessionPolicy` volume attribute. Permissions of the session are the intersection of the role's
+// permissions and the session policy, so it never grants more than the role allows.
+//
+// Objects can be listed and read under the prefix of the volume, and written unless the volume is mounted with
+// `--read-only`. Deleting objects is only allowed with `--allow-delete`. Directory buckets don't support permissions
+// per object, so only read-only sessions can be enforced for them.
+func scopedSessionPolicy(volumeCtx map[string]string, args mountpoint.Args, region string) (string, error) {
+	enabled, err := scopedSessionPolicyEnabled(volumeCtx)
+	if err != nil || !enabled {
+		return "", err
+	}
+
+	bucket := volumeCtx[volumecontext.BucketName]
+	if bucket == "" {
+		return "", status.Errorf(codes.InvalidArgument, "%s requires %s", volumecontext.ScopedSessionPolicy, volumecontext.BucketName)
+	}
+	prefix, _ := args.Value(mountpoint.ArgPrefix)
+	readOnly := args.Has(mountpoint.ArgReadOnly)
+	partition := partitionForRegion(region)
+
+	var statements []sessionPolicyStatement
+	if strings.HasSuffix(bucket, directoryBucketSuffix) {
+		statements = append(statements, directoryBucketStatement(partition, bucket, readOnly))
+	} else {
+		listBucket := sessionPolicyStatement{
+			Effect:   "Allow",
+			Action:   []string{"s3:ListBucket"},
+			Resource: []string{"arn:" + partition + ":s3:::" + bucket},
+		}
+		if prefix != "" {
+			listBucket.Condition = map[string]map[string]string{"StringLike": {"s3:prefix": prefix + "*"}}
+		}
+		actions := []string{"s3:GetObject"}
+		if !readOnly {
+			actions = append(actions, "s3:PutObject", "s3:AbortMultipartUpload")
+			if args.Has(mountpoint.ArgAllowDelete) {
+				actions = append(actions, "s3:DeleteObject")
+			}
+		}
+		statements = append(statements, listBucket, sessionPolicyStatement{
+			Effect:   "Allow",
+			Action:   actions,
+			Resource: []string{"arn:" + partition + ":s3:::" + bucket + "/" + prefix + "*"},
+		})
+	}
+
+	// The shared cache in an S3 Express One Zone bucket is written even for read-only volumes.
+	if cacheBucket := volumeCtx[volumecontext.CacheExpressBucket]; cacheBucket != "" {
+		statements = append(statements, directoryBucketStatement(partition, cacheBucket, false))
+	}
+
+	policy, err := json.Marshal(sessionPolicyDocument{Version: "2012-10-17", Statement: statements})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to create session policy: %v", err)
+	}
+	return string(policy), nil
+}
+
+// directoryBucketStatement returns a statement allowing to create sessions for directory `bucket`,
+// which are restricted to read-only sessions if `readOnly` is set.
+func directoryBucketStatement(partition, bucket string, readOnly bool) sessionPolicyStatement {
+	statement := sessionPolicyStatement{
+		Effect:   "Allow",
+		Action:   []string{"s3express:CreateSession"},
+		Resource: []string{"arn:" + partition + ":s3express:*:*:bucket/" + bucket},
+	}
+	if readOnly {
+		statement.Condition = map[string]map[string]string{"StringEquals": {"s3express:SessionMode": "ReadOnly"}}
+	}
+	return statement
+}
+
+// partitionForRegion returns the AWS partition of `region`, e.g. `aws-cn` for `cn-north-1`.
+func partitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
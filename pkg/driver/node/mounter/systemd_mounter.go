@@ -19,29 +19,43 @@ import (
 // https://github.com/awslabs/mountpoint-s3/blob/9ed8b6243f4511e2013b2f4303a9197c3ddd4071/mountpoint-s3/src/cli.rs#L421
 const mountpointDeviceName = "mountpoint-s3"
 
+// DefaultMountTimeout is the default timeout for Mountpoint to establish a mount.
+const DefaultMountTimeout = 30 * time.Second
+
 type SystemdMounter struct {
-	Ctx               context.Context
-	Runner            ServiceRunner
-	Mounter           mount.Interface
-	MpVersion         string
-	MountS3Path       string
+	Ctx         context.Context
+	Runner      ServiceRunner
+	Mounter     mount.Interface
+	MpVersion   string
+	MountS3Path string
+	// MountTimeout is the timeout for Mountpoint to establish a mount, [DefaultMountTimeout] is used if it's zero.
+	MountTimeout time.Duration
+	// UseConfigFile is whether to pass options to Mountpoint via a configuration file written to [ConfigFile]
+	// rather than command-line arguments, which avoids quoting issues with values containing whitespace.
+	UseConfigFile     bool
 	kubernetesVersion string
+	// foreground is whether to run Mountpoint in foreground, which is needed if `Runner` supervises Mountpoint processes
+	// itself instead of systemd, see [ProcessMounter].
+	foreground bool
 }
 
 func NewSystemdMounter(mpVersion string, kubernetesVersion string) (*SystemdMounter, error) {
-	ctx := context.Background()
 	runner, err := system.StartOsSystemdSupervisor()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start systemd supervisor: %w", err)
 	}
+	return newSystemdMounter(runner, mpVersion, kubernetesVersion), nil
+}
+
+func newSystemdMounter(runner ServiceRunner, mpVersion string, kubernetesVersion string) *SystemdMounter {
 	return &SystemdMounter{
-		Ctx:               ctx,
+		Ctx:               context.Background(),
 		Runner:            runner,
 		Mounter:           mount.New(""),
 		MpVersion:         mpVersion,
 		MountS3Path:       MountS3Path(),
 		kubernetesVersion: kubernetesVersion,
-	}, nil
+	}
 }
 
 // IsMountPoint returns whether given `target` is a `mount-s3` mount.
@@ -87,7 +101,11 @@ func (m *SystemdMounter) Mount(bucketName string, target string, credentials *Mo
 	if target == "" {
 		return fmt.Errorf("target is empty")
 	}
-	timeoutCtx, cancel := context.WithTimeout(m.Ctx, 30*time.Second)
+	mountTimeout := m.MountTimeout
+	if mountTimeout == 0 {
+		mountTimeout = DefaultMountTimeout
+	}
+	timeoutCtx, cancel := context.WithTimeout(m.Ctx, mountTimeout)
 	defer cancel()
 
 	cleanupDir := false
@@ -132,11 +150,23 @@ func (m *SystemdMounter) Mount(bucketName string, target string, credentials *Mo
 	var authenticationSource AuthenticationSource
 	if credentials != nil {
 		var awsProfile awsprofile.AWSProfile
-		if credentials.AccessKeyID != "" && credentials.SecretAccessKey != "" {
-			// Kubernetes creates target path in the form of "/var/lib/kubelet/pods/<pod-uuid>/volumes/kubernetes.io~csi/<volume-id>/mount".
-			// So the directory of the target path is unique for this mount, and we can use it to write credentials and config files.
-			// These files will be cleaned up in `Unmount`.
-			basepath := filepath.Dir(target)
+		// Kubernetes creates target path in the form of "/var/lib/kubelet/pods/<pod-uuid>/volumes/kubernetes.io~csi/<volume-id>/mount".
+		// So the directory of the target path is unique for this mount, and we can use it to write credentials and config files.
+		// These files will be cleaned up in `Unmount`.
+		basepath := filepath.Dir(target)
+		if credentials.CredentialProcess != "" {
+			awsProfile, err = awsprofile.CreateAWSProfileWithCredentialProcess(basepath, credentials.CredentialProcess)
+			if err != nil {
+				klog.V(4).Infof("Mount: Failed to create AWS Profile with credential process in %s: %v", basepath, err)
+				return fmt.Errorf("Mount: Failed to create AWS Profile with credential process in %s: %v", basepath, err)
+			}
+		} else if credentials.CredentialsFileContents != "" {
+			awsProfile, err = awsprofile.CreateAWSProfileFromCredentialsFile(basepath, credentials.ProfileName, credentials.CredentialsFileContents)
+			if err != nil {
+				klog.V(4).Infof("Mount: Failed to create AWS Profile %q in %s: %v", credentials.ProfileName, basepath, err)
+				return fmt.Errorf("Mount: Failed to create AWS Profile %q in %s: %v", credentials.ProfileName, basepath, err)
+			}
+		} else if credentials.AccessKeyID != "" && credentials.SecretAccessKey != "" {
 			awsProfile, err = awsprofile.CreateAWSProfile(basepath, credentials.AccessKeyID, credentials.SecretAccessKey, credentials.SessionToken)
 			if err != nil {
 				klog.V(4).Infof("Mount: Failed to create AWS Profile in %s: %v", basepath, err)
@@ -146,7 +176,10 @@ func (m *SystemdMounter) Mount(bucketName string, target string, credentials *Mo
 
 		authenticationSource = credentials.AuthenticationSource
 
-		env = credentials.Env(awsProfile)
+		// Variables of the credentials take precedence over the defaults of the CSI Driver, e.g. the region and the proxy.
+		for key, value := range credentials.Env(awsProfile) {
+			env.Set(key, value)
+		}
 	}
 
 	// Move `--aws-max-attempts` to env if provided
@@ -154,7 +187,24 @@ func (m *SystemdMounter) Mount(bucketName string, target string, credentials *Mo
 		env.Set(envprovider.EnvMaxAttempts, maxAttempts)
 	}
 
+	// Move FUSE settings to env if provided
+	if maxBackground, ok := args.Remove(mountpoint.ArgFUSEMaxBackground); ok {
+		env.Set(envprovider.EnvFUSEMaxBackground, maxBackground)
+	}
+	if congestionThreshold, ok := args.Remove(mountpoint.ArgFUSECongestionThreshold); ok {
+		env.Set(envprovider.EnvFUSECongestionThreshold, congestionThreshold)
+	}
+
 	args.Set(mountpoint.ArgUserAgentPrefix, UserAgent(authenticationSource, m.kubernetesVersion))
+	if m.UseConfigFile {
+		args, err = writeConfigFile(target, args)
+		if err != nil {
+			return err
+		}
+	}
+	if m.foreground {
+		args.Set(mountpoint.ArgForeground, mountpoint.ArgNoValue)
+	}
 
 	output, err := m.Runner.StartService(timeoutCtx, &system.ExecConfig{
 		Name:        "mount-s3-" + m.MpVersion + "-" + uuid.New().String() + ".service",
@@ -165,6 +215,7 @@ func (m *SystemdMounter) Mount(bucketName string, target string, credentials *Mo
 	})
 
 	if err != nil {
+		removeConfigFile(target)
 		return fmt.Errorf("Mount failed: %w output: %s", err, output)
 	}
 	if output != "" {
@@ -183,6 +234,7 @@ func (m *SystemdMounter) Unmount(target string) error {
 	if err != nil {
 		klog.V(4).Infof("Unmount: Failed to clean up AWS Profile in %s: %v", basepath, err)
 	}
+	removeConfigFile(target)
 
 	output, err := m.Runner.RunOneshot(timeoutCtx, &system.ExecConfig{
 		Name:        "mount-s3-umount-" + uuid.New().String() + ".service",
@@ -196,5 +248,85 @@ func (m *SystemdMounter) Unmount(target string) error {
 	if output != "" {
 		klog.V(5).Infof("umount output: %s", output)
 	}
+
+	// Remove the local cache only after Mountpoint is terminated.
+	if err := os.RemoveAll(CacheDir(target)); err != nil {
+		klog.V(4).Infof("Unmount: Failed to clean up cache directory of %s: %v", target, err)
+	}
+	return nil
+}
+
+// BindMount bind mounts an existing `mount-s3` mount at `source` to `target`, and makes it read-only if `readOnly` is set.
+// It's used to share a single Mountpoint process staged in `NodeStageVolume` across multiple workload Pods.
+//
+// Like `Unmount`, `mount` is invoked on the host to make sure the bind mount is visible to the workload Pods.
+// The bind mount can be unmounted with `Unmount`.
+func (m *SystemdMounter) BindMount(source string, target string, readOnly bool) error {
+	timeoutCtx, cancel := context.WithTimeout(m.Ctx, 30*time.Second)
+	defer cancel()
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("Failed to create target directory: %w", err)
+	}
+
+	isMountPoint, err := m.IsMountPoint(target)
+	if err != nil {
+		return fmt.Errorf("Could not check if %q is a mount point: %w", target, err)
+	}
+	if isMountPoint {
+		klog.V(4).Infof("BindMount: Target path %q is already mounted", target)
+		return nil
+	}
+
+	if err := m.runMount(timeoutCtx, "--bind", source, target); err != nil {
+		return fmt.Errorf("Bind mount failed: %w", err)
+	}
+
+	if readOnly {
+		// Bind mounts inherit mount flags of their source, and can only be made read-only with a remount.
+		if err := m.runMount(timeoutCtx, "-o", "remount,bind,ro", target); err != nil {
+			if unmountErr := m.Unmount(target); unmountErr != nil {
+				klog.V(4).Infof("BindMount: Failed to unmount %q after failing to make it read-only: %v", target, unmountErr)
+			}
+			return fmt.Errorf("Read-only bind mount failed: %w", err)
+		}
+	}
 	return nil
 }
+
+// runMount runs `mount` with given `args` on the host.
+func (m *SystemdMounter) runMount(ctx context.Context, args ...string) error {
+	output, err := m.Runner.RunOneshot(ctx, &system.ExecConfig{
+		Name:        "mount-s3-bind-" + uuid.New().String() + ".service",
+		Description: "Mountpoint for Amazon S3 CSI driver bind mount",
+		ExecPath:    "/usr/bin/mount",
+		Args:        args,
+	})
+	if err != nil {
+		return fmt.Errorf("%w mount output: %s", err, output)
+	}
+	if output != "" {
+		klog.V(5).Infof("mount output: %s", output)
+	}
+	return nil
+}
+
+// writeConfigFile renders `args` into [ConfigFile] of `target`, and returns the arguments to pass Mountpoint instead.
+func writeConfigFile(target string, args mountpoint.Args) (mountpoint.Args, error) {
+	path := ConfigFile(target)
+	// Configuration files passed via mount options would be nested, and they might not exist on the host.
+	args.Remove(mountpoint.ArgConfig)
+	if err := os.WriteFile(path, args.Config(), 0600); err != nil {
+		return args, fmt.Errorf("Mount: Failed to write Mountpoint configuration file %s: %w", path, err)
+	}
+	configArgs := mountpoint.ParseArgs(nil)
+	configArgs.Set(mountpoint.ArgConfig, path)
+	return configArgs, nil
+}
+
+// removeConfigFile removes [ConfigFile] of `target` if it exists.
+func removeConfigFile(target string) {
+	if err := os.Remove(ConfigFile(target)); err != nil && !os.IsNotExist(err) {
+		klog.V(4).Infof("Failed to clean up Mountpoint configuration file of %s: %v", target, err)
+	}
+}
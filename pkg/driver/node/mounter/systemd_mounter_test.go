@@ -11,10 +11,12 @@ import (
 	"testing"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/awsprofile"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
 	mock_driver "github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter/mocks"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
 	"github.com/golang/mock/gomock"
 	"k8s.io/mount-utils"
 )
@@ -124,6 +126,27 @@ func TestS3MounterMount(t *testing.T) {
 				})
 			},
 		},
+		{
+			name:        "success: FUSE settings",
+			bucketName:  testBucketName,
+			targetPath:  testTargetPath,
+			credentials: nil,
+			options:     []string{"--fuse-max-background=256", "--fuse-congestion-threshold=192"},
+			before: func(t *testing.T, env *mounterTestEnv) {
+				env.mockRunner.EXPECT().StartService(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, config *system.ExecConfig) (string, error) {
+					for _, a := range config.Args {
+						if strings.HasPrefix(a, "--fuse-") {
+							t.Fatalf("FUSE setting %q should not be passed as an argument", a)
+						}
+					}
+					if !slices.Contains(config.Env, "UNSTABLE_MOUNTPOINT_MAX_BACKGROUND=256") ||
+						!slices.Contains(config.Env, "UNSTABLE_MOUNTPOINT_CONGESTION_THRESHOLD=192") {
+						t.Fatalf("Bad env %v", config.Env)
+					}
+					return "success", nil
+				})
+			},
+		},
 		{
 			name:        "failure: fails on mount failure",
 			bucketName:  testBucketName,
@@ -166,6 +189,36 @@ func TestS3MounterMount(t *testing.T) {
 	}
 }
 
+func TestMountingWithConfigFile(t *testing.T) {
+	env := initMounterTestEnv(t)
+	env.mounter.UseConfigFile = true
+	target := filepath.Join(t.TempDir(), "mount")
+	configFile := mounter.ConfigFile(target)
+
+	env.mockRunner.EXPECT().StartService(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, config *system.ExecConfig) (string, error) {
+		assert.Equals(t, []string{"--config=" + configFile, "test-bucket", target}, config.Args)
+		assert.Equals(t, true, slices.Contains(config.Env, "AWS_MAX_ATTEMPTS=5"))
+
+		contents, err := os.ReadFile(configFile)
+		assert.NoError(t, err)
+		lines := strings.Split(string(contents), "\n")
+		assert.Equals(t, `allow-other = true`, lines[0])
+		assert.Equals(t, `prefix = "dir with spaces/"`, lines[1])
+		assert.Equals(t, true, strings.HasPrefix(lines[2], `user-agent-prefix = "s3-csi-driver/`))
+		return "success", nil
+	})
+	err := env.mounter.Mount("test-bucket", target, nil, mountpoint.ParseArgs([]string{
+		"--allow-other", "--prefix=dir with spaces/", "--aws-max-attempts=5", "--config=/etc/passwd",
+	}))
+	assert.NoError(t, err)
+
+	env.mockRunner.EXPECT().RunOneshot(gomock.Any(), gomock.Any()).Return("", nil)
+	assert.NoError(t, env.mounter.Unmount(target))
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected configuration file to be removed after unmount, got: %v", err)
+	}
+}
+
 func TestProvidingEnvVariablesForMountpointProcess(t *testing.T) {
 	tests := map[string]struct {
 		profile     awsprofile.AWSProfile
@@ -239,6 +292,16 @@ func TestProvidingEnvVariablesForMountpointProcess(t *testing.T) {
 				"UNSTABLE_MOUNTPOINT_CACHE_KEY=test_cache_key",
 			},
 		},
+		"Mountpoint Env": {
+			credentials: &mounter.MountCredentials{
+				Region:        "us-west-2",
+				MountpointEnv: envprovider.Environment{"HTTPS_PROXY": "http://proxy:3128", "AWS_REGION": "eu-west-1"},
+			},
+			expected: []string{
+				"AWS_REGION=us-west-2",
+				"HTTPS_PROXY=http://proxy:3128",
+			},
+		},
 		"All Combined": {
 			credentials: &mounter.MountCredentials{
 				WebTokenPath:              "/path/to/web/token",
@@ -362,3 +425,63 @@ func TestIsMountPoint(t *testing.T) {
 		})
 	}
 }
+
+func TestBindMount(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "staging")
+
+	t.Run("Read-write", func(t *testing.T) {
+		env := initMounterTestEnv(t)
+		target := filepath.Join(t.TempDir(), "mount")
+
+		env.mockRunner.EXPECT().RunOneshot(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, config *system.ExecConfig) (string, error) {
+			if !reflect.DeepEqual([]string{"--bind", source, target}, config.Args) {
+				t.Fatalf("Unexpected mount arguments: %v", config.Args)
+			}
+			return "", nil
+		})
+
+		if err := env.mounter.BindMount(source, target, false); err != nil {
+			t.Fatalf("BindMount failed: %v", err)
+		}
+		if _, err := os.Stat(target); err != nil {
+			t.Fatalf("Expected target path to be created: %v", err)
+		}
+		env.mockCtl.Finish()
+	})
+
+	t.Run("Read-only", func(t *testing.T) {
+		env := initMounterTestEnv(t)
+		target := filepath.Join(t.TempDir(), "mount")
+
+		gomock.InOrder(
+			env.mockRunner.EXPECT().RunOneshot(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, config *system.ExecConfig) (string, error) {
+				if !reflect.DeepEqual([]string{"--bind", source, target}, config.Args) {
+					t.Fatalf("Unexpected mount arguments: %v", config.Args)
+				}
+				return "", nil
+			}),
+			env.mockRunner.EXPECT().RunOneshot(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, config *system.ExecConfig) (string, error) {
+				if !reflect.DeepEqual([]string{"-o", "remount,bind,ro", target}, config.Args) {
+					t.Fatalf("Unexpected mount arguments: %v", config.Args)
+				}
+				return "", nil
+			}),
+		)
+
+		if err := env.mounter.BindMount(source, target, true); err != nil {
+			t.Fatalf("BindMount failed: %v", err)
+		}
+		env.mockCtl.Finish()
+	})
+
+	t.Run("Already mounted", func(t *testing.T) {
+		env := initMounterTestEnv(t)
+		target := filepath.Join(t.TempDir(), "mount")
+		env.mounter.Mounter = mount.NewFakeMounter([]mount.MountPoint{{Device: "mountpoint-s3", Path: target}})
+
+		if err := env.mounter.BindMount(source, target, false); err != nil {
+			t.Fatalf("BindMount failed: %v", err)
+		}
+		env.mockCtl.Finish()
+	})
+}
@@ -0,0 +1,119 @@
+package mounter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// DefaultTokenExpiration is the lifetime of service account tokens issued by the CSI Driver itself if the lifetime
+// of the tokens kubelet requests is not known, which is the default of `expirationSeconds` in the Helm chart.
+const DefaultTokenExpiration = time.Hour
+
+// TokenExpiration returns when the service account token for STS in given `volumeCtx` expires,
+// and whether the volume has such a token, i.e., it uses pod-level credentials.
+func TokenExpiration(volumeCtx map[string]string) (time.Time, bool) {
+	tokens, err := parseTokens(volumeCtx)
+	if err != nil || tokens[serviceAccountTokenAudienceSTS] == nil {
+		return time.Time{}, false
+	}
+	return tokens[serviceAccountTokenAudienceSTS].ExpirationTimestamp, true
+}
+
+// TokenExpirationOf returns the lifetime of service account tokens for STS kubelet requests for `csiDriver`,
+// or [DefaultTokenExpiration] if it's not configured in its `tokenRequests`.
+func TokenExpirationOf(csiDriver *storagev1.CSIDriver) time.Duration {
+	for _, tokenRequest := range csiDriver.Spec.TokenRequests {
+		if tokenRequest.Audience == serviceAccountTokenAudienceSTS && tokenRequest.ExpirationSeconds != nil {
+			return time.Duration(*tokenRequest.ExpirationSeconds) * time.Second
+		}
+	}
+	return DefaultTokenExpiration
+}
+
+// TokenExists returns whether the service account token of given pod and volume is written for Mountpoint.
+func (c *CredentialProvider) TokenExists(podID string, volumeID string) bool {
+	_, err := os.Stat(c.tokenPathContainer(podID, volumeID))
+	return err == nil
+}
+
+// TokenPath returns the path of the service account token of given pod and volume written for Mountpoint,
+// in the directory returned by [CredentialProvider.TokenDir].
+func (c *CredentialProvider) TokenPath(podID string, volumeID string) string {
+	return c.tokenPathContainer(podID, volumeID)
+}
+
+// TokenDir returns the directory service account tokens are written to for Mountpoint.
+func (c *CredentialProvider) TokenDir() string {
+	return c.containerPluginDir
+}
+
+// ReissueToken requests a new service account token for STS for the workload Pod in `volumeCtx`, bound to the Pod,
+// valid for `expiration`, and returns a copy of `volumeCtx` with the new token to provide credentials with.
+// It's used to refresh tokens of pod-level credentials if kubelet does not republish the volume before they expire.
+func (c *CredentialProvider) ReissueToken(ctx context.Context, volumeCtx map[string]string, expiration time.Duration) (map[string]string, error) {
+	tokens, err := parseTokens(volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, serviceAccount := volumeCtx[volumecontext.CSIPodNamespace], volumeCtx[volumecontext.CSIServiceAccountName]
+	podName, podUID := volumeCtx[volumecontext.CSIPodName], volumeCtx[volumecontext.CSIPodUID]
+	if namespace == "" || serviceAccount == "" || podName == "" || podUID == "" {
+		return nil, fmt.Errorf("missing Pod information to request a service account token")
+	}
+
+	tokenRequest, err := c.client.ServiceAccounts(namespace).CreateToken(ctx, serviceAccount, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         []string{serviceAccountTokenAudienceSTS},
+			ExpirationSeconds: ptrTo(int64(expiration.Seconds())),
+			BoundObjectRef: &authenticationv1.BoundObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       podName,
+				UID:        types.UID(podUID),
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request service account token: %w", err)
+	}
+
+	tokens[serviceAccountTokenAudienceSTS] = &Token{
+		Token:               tokenRequest.Status.Token,
+		ExpirationTimestamp: tokenRequest.Status.ExpirationTimestamp.Time,
+	}
+	tokensJSON, err := json.Marshal(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	reissued := make(map[string]string, len(volumeCtx))
+	for k, v := range volumeCtx {
+		reissued[k] = v
+	}
+	reissued[volumecontext.CSIServiceAccountTokens] = string(tokensJSON)
+	return reissued, nil
+}
+
+// parseTokens parses service account tokens passed by kubelet in `volumeCtx`, keyed by their audiences.
+func parseTokens(volumeCtx map[string]string) (map[string]*Token, error) {
+	var tokens map[string]*Token
+	if err := json.Unmarshal([]byte(volumeCtx[volumecontext.CSIServiceAccountTokens]), &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse service account tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,120 @@
+package mounter_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+func TestReissuingServiceAccountTokens(t *testing.T) {
+	pluginDir := t.TempDir()
+	clientset := fake.NewSimpleClientset(serviceAccount("test-sa", "test-ns", map[string]string{
+		"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test",
+	}))
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	reissuedExpiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var tokenRequest *authenticationv1.TokenRequest
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tokenRequest = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		tokenRequest.Status = authenticationv1.TokenRequestStatus{
+			Token:               "reissued-service-account-token",
+			ExpirationTimestamp: metav1.NewTime(reissuedExpiration),
+		}
+		return true, tokenRequest, nil
+	})
+
+	provider := mounter.NewCredentialProvider(clientset.CoreV1(), pluginDir, mounter.RegionFromIMDSOnce)
+	expiration := time.Now().Add(5 * time.Minute).UTC().Truncate(time.Second)
+	volumeCtx := map[string]string{
+		"authenticationSource":                   "pod",
+		"csi.storage.k8s.io/pod.name":            "test-pod-name",
+		"csi.storage.k8s.io/pod.uid":             "test-pod",
+		"csi.storage.k8s.io/pod.namespace":       "test-ns",
+		"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+		"csi.storage.k8s.io/serviceAccount.tokens": serviceAccountTokens(t, tokens{
+			"sts.amazonaws.com": {
+				Token:               "test-service-account-token",
+				ExpirationTimestamp: expiration,
+			},
+		}),
+	}
+
+	got, ok := mounter.TokenExpiration(volumeCtx)
+	assertEquals(t, true, ok)
+	assertEquals(t, true, got.Equal(expiration))
+	assertEquals(t, false, provider.TokenExists("test-pod", "test-vol-id"))
+
+	reissued, err := provider.ReissueToken(context.Background(), volumeCtx, 2*time.Hour)
+	assertEquals(t, nil, err)
+
+	// The token is requested for STS with the given lifetime and bound to the workload Pod
+	assertEquals(t, "sts.amazonaws.com", tokenRequest.Spec.Audiences[0])
+	assertEquals(t, int64(7200), *tokenRequest.Spec.ExpirationSeconds)
+	assertEquals(t, "Pod", tokenRequest.Spec.BoundObjectRef.Kind)
+	assertEquals(t, "test-pod-name", tokenRequest.Spec.BoundObjectRef.Name)
+	assertEquals(t, "test-pod", string(tokenRequest.Spec.BoundObjectRef.UID))
+
+	got, ok = mounter.TokenExpiration(reissued)
+	assertEquals(t, true, ok)
+	assertEquals(t, true, got.Equal(reissuedExpiration))
+
+	// The original volume context is not modified
+	got, _ = mounter.TokenExpiration(volumeCtx)
+	assertEquals(t, true, got.Equal(expiration))
+
+	// Providing credentials with the re-issued volume context writes the new token for Mountpoint
+	credentials, err := provider.Provide(context.Background(), "test-vol-id", reissued, mountpoint.ParseArgs(nil))
+	assertEquals(t, nil, err)
+	assertEquals(t, true, provider.TokenExists("test-pod", "test-vol-id"))
+	assertEquals(t, tokenFilePath(credentials, pluginDir), provider.TokenPath("test-pod", "test-vol-id"))
+	assertEquals(t, true, credentials.Expiration.Equal(reissuedExpiration))
+
+	token, err := os.ReadFile(tokenFilePath(credentials, pluginDir))
+	assertEquals(t, nil, err)
+	assertEquals(t, "reissued-service-account-token", string(token))
+
+	// Volumes without service account tokens, e.g. using driver-level credentials, have nothing to re-issue
+	_, ok = mounter.TokenExpiration(map[string]string{"authenticationSource": "driver"})
+	assertEquals(t, false, ok)
+}
+
+func TestTokenExpirationOfCSIDriver(t *testing.T) {
+	for name, test := range map[string]struct {
+		tokenRequests []storagev1.TokenRequest
+		expected      time.Duration
+	}{
+		"no token requests": {expected: mounter.DefaultTokenExpiration},
+		"token request for STS": {
+			tokenRequests: []storagev1.TokenRequest{
+				{Audience: "vault", ExpirationSeconds: ptr.To(int64(600))},
+				{Audience: "sts.amazonaws.com", ExpirationSeconds: ptr.To(int64(7200))},
+			},
+			expected: 2 * time.Hour,
+		},
+		"token request for STS without expiration": {
+			tokenRequests: []storagev1.TokenRequest{{Audience: "sts.amazonaws.com"}},
+			expected:      mounter.DefaultTokenExpiration,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			csiDriver := &storagev1.CSIDriver{Spec: storagev1.CSIDriverSpec{TokenRequests: test.tokenRequests}}
+			assertEquals(t, test.expected, mounter.TokenExpirationOf(csiDriver))
+		})
+	}
+}
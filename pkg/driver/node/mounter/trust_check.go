@@ -0,0 +1,74 @@
+package mounter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// trustCheckValidity is how long a successful check of a role's trust policy is reused for the same service account,
+// as kubelet republishes volumes periodically.
+const trustCheckValidity = time.Hour
+
+const podLevelRoleConfigDocsPage = "https://github.com/awslabs/mountpoint-s3-csi-driver/blob/main/docs/CONFIGURATION.md#pod-level-identity-service-account-configuration-for-eks-clusters"
+
+// trustChecks records when roles were last checked to trust service accounts, keyed by `<role ARN> <subject>`.
+type trustChecks struct {
+	mu        sync.Mutex
+	checkedAt map[string]time.Time
+}
+
+// serviceAccountSubject returns the `sub` claim of service account tokens for given service account.
+func serviceAccountSubject(namespace, serviceAccount string) string {
+	return "system:serviceaccount:" + namespace + ":" + serviceAccount
+}
+
+// checkTrust checks the trust policy of the role in `input` allows the service account `subject` to assume it
+// with the web identity token in `input`, by assuming the role once before handing the token to Mountpoint.
+// It returns a `PermissionDenied` error naming the role and the subject if STS denies it, and ignores
+// other errors (e.g., network errors) as Mountpoint reports them itself.
+func (c *CredentialProvider) checkTrust(ctx context.Context, input AssumeRoleInput, subject string) error {
+	key := input.RoleARN + " " + subject
+	c.trustChecks.mu.Lock()
+	checkedAt, ok := c.trustChecks.checkedAt[key]
+	c.trustChecks.mu.Unlock()
+	if ok && time.Since(checkedAt) < trustCheckValidity {
+		return nil
+	}
+
+	_, err := c.roleAssumer.AssumeRole(ctx, input)
+	if err != nil {
+		if trustErr := trustPolicyError(err, input.RoleARN, subject); trustErr != nil {
+			return trustErr
+		}
+		klog.V(4).Infof("NodePublishVolume: check of trust policy of role %s is inconclusive: %v", input.RoleARN, err)
+		return nil
+	}
+
+	c.trustChecks.mu.Lock()
+	if c.trustChecks.checkedAt == nil {
+		c.trustChecks.checkedAt = map[string]time.Time{}
+	}
+	c.trustChecks.checkedAt[key] = time.Now()
+	c.trustChecks.mu.Unlock()
+	return nil
+}
+
+// trustPolicyError returns a `PermissionDenied` error describing the missing trust if `err` is an access denied error
+// of STS for assuming `roleARN` with web identity of the service account `subject`, or nil otherwise.
+func trustPolicyError(err error, roleARN, subject string) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "AccessDenied" {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, fmt.Sprintf("Role %s trust policy does not include %s: "+
+		"the role's trust policy must allow sts:AssumeRoleWithWebIdentity for the cluster's OIDC provider with `sub` condition %q, see %s",
+		roleARN, subject, subject, podLevelRoleConfigDocsPage))
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// mountpointEnvAllowlist are the environment variables that can be passed to Mountpoint via `mountpointEnv` volume attribute.
+var mountpointEnvAllowlist = []envprovider.Key{
+	envprovider.EnvMaxAttempts,
+	"AWS_RETRY_MODE",
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+	"http_proxy",
+	"https_proxy",
+	"no_proxy",
+}
+
+// mountpointEnvUnstablePrefix is the prefix of environment variables configuring experimental features of Mountpoint,
+// which can be passed via `mountpointEnv` volume attribute unless they're managed by the CSI Driver.
+const mountpointEnvUnstablePrefix = "UNSTABLE_MOUNTPOINT_"
+
+// mountpointEnvFor parses `mountpointEnv` volume attribute in `volumeCtx`, a JSON object of environment variables
+// to set for the Mountpoint process of the volume. Only variables in [mountpointEnvAllowlist] and experimental
+// Mountpoint variables are allowed, so volumes cannot override credentials or other settings managed by the CSI Driver.
+//
+// Proxy settings in `httpProxy`, `httpsProxy` and `noProxy` volume attributes take precedence over the ones in `mountpointEnv`.
+func mountpointEnvFor(volumeCtx map[string]string) (envprovider.Environment, error) {
+	env := envprovider.Environment{}
+	if value, ok := volumeCtx[volumecontext.MountpointEnv]; ok {
+		if err := json.Unmarshal([]byte(value), &env); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s must be a JSON object of environment variables: %v", volumecontext.MountpointEnv, err)
+		}
+		for key := range env {
+			if !isAllowedMountpointEnv(key) {
+				return nil, status.Errorf(codes.InvalidArgument, "Environment variable %q is not allowed in %s, allowed variables are %s and %s*",
+					key, volumecontext.MountpointEnv, strings.Join(mountpointEnvAllowlist, ", "), mountpointEnvUnstablePrefix)
+			}
+		}
+	}
+
+	proxy, err := envprovider.ProxyFor(volumeCtx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid proxy: %v", err)
+	}
+	for key, value := range proxy {
+		env.Set(key, value)
+	}
+
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return env, nil
+}
+
+// isAllowedMountpointEnv returns whether environment variable `key` can be passed via `mountpointEnv` volume attribute.
+func isAllowedMountpointEnv(key envprovider.Key) bool {
+	switch key {
+	case envprovider.EnvMountpointCacheKey, envprovider.EnvFUSEMaxBackground, envprovider.EnvFUSECongestionThreshold:
+		return false
+	}
+	return slices.Contains(mountpointEnvAllowlist, key) || (strings.HasPrefix(key, mountpointEnvUnstablePrefix) && len(key) > len(mountpointEnvUnstablePrefix))
+}
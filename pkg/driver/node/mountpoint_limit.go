@@ -0,0 +1,137 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/capacity"
+)
+
+// EventReasonMountpointLimitReached is the reason of the event emitted to workload Pods whose volumes are not
+// mounted as this node is already running [S3NodeServer.MaxMountpoints] Mountpoint processes.
+const EventReasonMountpointLimitReached = "MountpointLimitReached"
+
+// MountpointCapacityReportInterval is the interval to update the Mountpoint capacity annotations of this node.
+const MountpointCapacityReportInterval = 5 * time.Second
+
+// mountpointReservations keeps track of Mountpoint processes being spawned, which are not published volumes yet.
+type mountpointReservations struct {
+	mu       sync.Mutex
+	reserved int
+}
+
+// countMountpoints returns the number of Mountpoint processes serving published volumes.
+// Staged volumes share a Mountpoint process per staging target path, and target paths
+// bind mounted from another target path share its Mountpoint process.
+func (p *publishedVolumes) countMountpoints() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stagingTargets := map[string]bool{}
+	count := 0
+	for _, vol := range p.byTarget {
+		switch {
+		case vol.stagingTarget != "":
+			stagingTargets[vol.stagingTarget] = true
+		case vol.sourceTarget == "":
+			count++
+		}
+	}
+	return count + len(stagingTargets)
+}
+
+// isStaged returns whether any published volume is mounted at `stagingTarget`.
+func (p *publishedVolumes) isStaged(stagingTarget string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, vol := range p.byTarget {
+		if vol.stagingTarget == stagingTarget {
+			return true
+		}
+	}
+	return false
+}
+
+// reserveMountpoints reserves `n` Mountpoint processes to publish `vol`, and returns a function to release the reservation
+// once they're spawned and tracked as published volumes, or have failed to spawn. It fails with `ResourceExhausted`
+// if this node would run more than [S3NodeServer.MaxMountpoints] Mountpoint processes, so kubelet retries the publish
+// with a backoff rather than Mountpoint processes exhausting the node.
+func (ns *S3NodeServer) reserveMountpoints(vol publishedVolume, n int) (func(), error) {
+	if ns.MaxMountpoints <= 0 || n == 0 {
+		return func() {}, nil
+	}
+
+	ns.mountpointReservations.mu.Lock()
+	defer ns.mountpointReservations.mu.Unlock()
+	active := ns.publishedVolumes.countMountpoints() + ns.mountpointReservations.reserved
+	if active+n > ns.MaxMountpoints {
+		mountpointLimitRejectionsTotal.Inc()
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonMountpointLimitReached,
+			"Could not mount volume %s as node %s is running %d of at most %d Mountpoint processes", vol.volumeID, ns.NodeID, active, ns.MaxMountpoints)
+		return nil, status.Errorf(codes.ResourceExhausted, "Node %s is running %d of at most %d Mountpoint processes, %d more are needed to mount volume %s",
+			ns.NodeID, active, ns.MaxMountpoints, n, vol.volumeID)
+	}
+	ns.mountpointReservations.reserved += n
+
+	return func() {
+		ns.mountpointReservations.mu.Lock()
+		defer ns.mountpointReservations.mu.Unlock()
+		ns.mountpointReservations.reserved -= n
+	}, nil
+}
+
+// ReportMountpointCapacity periodically populates [capacity.AnnotationMaxMountpoints] and [capacity.AnnotationActiveMountpoints]
+// on the Node object of this node with given `interval`, only patching it if the number of Mountpoint processes
+// has changed. It's a no-op if [S3NodeServer.MaxMountpoints] or [S3NodeServer.Nodes] is not set.
+//
+// It blocks until `ctx` is cancelled.
+func (ns *S3NodeServer) ReportMountpointCapacity(ctx context.Context, interval time.Duration) {
+	if ns.MaxMountpoints <= 0 || ns.Nodes == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := -1
+	for {
+		if active := ns.publishedVolumes.countMountpoints(); active != reported {
+			if err := ns.patchMountpointCapacity(ctx, active); err != nil {
+				klog.Errorf("Failed to report Mountpoint capacity of node %s: %v", ns.NodeID, err)
+			} else {
+				reported = active
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ns *S3NodeServer) patchMountpointCapacity(ctx context.Context, active int) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				capacity.AnnotationMaxMountpoints:    strconv.Itoa(ns.MaxMountpoints),
+				capacity.AnnotationActiveMountpoints: strconv.Itoa(active),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ns.Nodes.Patch(ctx, ns.NodeID, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
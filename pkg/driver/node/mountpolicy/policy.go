@@ -0,0 +1,143 @@
+// Package mountpolicy provides a cluster-wide policy to allow or deny Mountpoint options per namespace.
+//
+// The policy is configured by the cluster admin with a ConfigMap mounted into the CSI Driver Node Pods, for example:
+//
+//	default:
+//	  deny: ["allow-other"]
+//	namespaces:
+//	  prod:
+//	    deny: ["allow-delete", "allow-overwrite"]
+//	    action: reject
+//	  sandbox:
+//	    allow: ["region", "prefix", "cache"]
+package mountpolicy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// An Action is what to do with the options denied by a policy.
+type Action string
+
+const (
+	// ActionStrip removes denied options and continues to mount. It's the default action.
+	ActionStrip Action = "strip"
+	// ActionReject fails the mount if any denied options are passed.
+	ActionReject Action = "reject"
+)
+
+// A Rule allows or denies options passed to Mountpoint.
+//
+// If `Allow` is not empty, only the options listed there are allowed. Options listed in `Deny` are always denied.
+// Options can be specified with or without the "--" prefix, i.e., "allow-delete" and "--allow-delete" are the same.
+type Rule struct {
+	Allow  []string `json:"allow,omitempty"`
+	Deny   []string `json:"deny,omitempty"`
+	Action Action   `json:"action,omitempty"`
+}
+
+// A Policy contains the rules for each namespace, and the default rule for namespaces without a rule.
+type Policy struct {
+	Default    *Rule           `json:"default,omitempty"`
+	Namespaces map[string]Rule `json:"namespaces,omitempty"`
+}
+
+// exemptArgs are the options that are never denied, as they're set by the CSI Driver to enforce volume's access mode.
+// Other options set by the CSI Driver are added after the policy is applied, so [Policy.Apply] only sees them if
+// they're passed via mount options or volume attributes.
+var exemptArgs = []mountpoint.ArgKey{mountpoint.ArgReadOnly}
+
+// ErrDenied is returned from [Policy.Apply] if the rule's action is [ActionReject] and there are denied options.
+var ErrDenied = errors.New("mount options denied by policy")
+
+// Load loads the policy from the file at `path`. It returns nil if the file does not exist.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read mount options policy from %s: %w", path, err)
+	}
+
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse mount options policy from %s: %w", path, err)
+	}
+
+	for namespace, rule := range policy.Namespaces {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid mount options policy for namespace %q: %w", namespace, err)
+		}
+	}
+	if policy.Default != nil {
+		if err := policy.Default.validate(); err != nil {
+			return nil, fmt.Errorf("invalid default mount options policy: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// Apply applies the rule for `namespace` to `args`, and returns the denied options.
+// Denied options are removed from `args` if the rule's action is [ActionStrip],
+// otherwise `args` is left untouched and an error wrapping [ErrDenied] is returned.
+func (p *Policy) Apply(namespace string, args *mountpoint.Args) ([]string, error) {
+	rule, ok := p.Namespaces[namespace]
+	if !ok {
+		if p.Default == nil {
+			return nil, nil
+		}
+		rule = *p.Default
+	}
+
+	var denied []string
+	for _, key := range args.Keys() {
+		if !rule.allows(key) {
+			denied = append(denied, key)
+		}
+	}
+
+	if len(denied) == 0 {
+		return nil, nil
+	}
+
+	if rule.Action == ActionReject {
+		return denied, fmt.Errorf("%w in namespace %q: %v", ErrDenied, namespace, denied)
+	}
+
+	for _, key := range denied {
+		args.Remove(key)
+	}
+	return denied, nil
+}
+
+func (r *Rule) allows(key mountpoint.ArgKey) bool {
+	if slices.Contains(exemptArgs, key) {
+		return true
+	}
+	if deny := mountpoint.ParseArgs(r.Deny); deny.Has(key) {
+		return false
+	}
+	if len(r.Allow) > 0 {
+		allow := mountpoint.ParseArgs(r.Allow)
+		return allow.Has(key)
+	}
+	return true
+}
+
+func (r *Rule) validate() error {
+	switch r.Action {
+	case "", ActionStrip, ActionReject:
+		return nil
+	default:
+		return fmt.Errorf("unsupported action %q, supported actions are %q and %q", r.Action, ActionStrip, ActionReject)
+	}
+}
@@ -0,0 +1,123 @@
+package mountpolicy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mountpolicy"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+const testPolicy = `
+default:
+  deny: ["allow-other"]
+namespaces:
+  prod:
+    deny: ["allow-delete", "--allow-overwrite"]
+    action: reject
+  sandbox:
+    allow: ["region", "prefix"]
+`
+
+func TestApplyingMountOptionsPolicy(t *testing.T) {
+	policy := loadPolicy(t, testPolicy)
+
+	testCases := []struct {
+		name       string
+		namespace  string
+		args       []string
+		wantArgs   []string
+		wantDenied []string
+		wantErr    bool
+	}{
+		{
+			name:       "strips options denied by default rule",
+			namespace:  "default",
+			args:       []string{"allow-other", "allow-delete"},
+			wantArgs:   []string{"--allow-delete"},
+			wantDenied: []string{"--allow-other"},
+		},
+		{
+			name:       "rejects options denied in namespace",
+			namespace:  "prod",
+			args:       []string{"allow-delete", "allow-overwrite", "region=us-west-2"},
+			wantArgs:   []string{"--allow-delete", "--allow-overwrite", "--region=us-west-2"},
+			wantDenied: []string{"--allow-delete", "--allow-overwrite"},
+			wantErr:    true,
+		},
+		{
+			name:      "allows options not denied in namespace",
+			namespace: "prod",
+			args:      []string{"allow-other", "region=us-west-2"},
+			wantArgs:  []string{"--allow-other", "--region=us-west-2"},
+		},
+		{
+			name:       "strips options not in allowlist",
+			namespace:  "sandbox",
+			args:       []string{"allow-delete", "region=us-west-2", "prefix=foo/"},
+			wantArgs:   []string{"--prefix=foo/", "--region=us-west-2"},
+			wantDenied: []string{"--allow-delete"},
+		},
+		{
+			name:      "never denies read-only",
+			namespace: "sandbox",
+			args:      []string{"read-only"},
+			wantArgs:  []string{"--read-only"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := mountpoint.ParseArgs(tc.args)
+			denied, err := policy.Apply(tc.namespace, &args)
+			if tc.wantErr {
+				if !errors.Is(err, mountpolicy.ErrDenied) {
+					t.Fatalf("Expected ErrDenied, got %v", err)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equals(t, tc.wantDenied, denied)
+			assert.Equals(t, tc.wantArgs, args.SortedList())
+		})
+	}
+}
+
+func TestLoadingMountOptionsPolicy(t *testing.T) {
+	t.Run("returns nil for non-existent file", func(t *testing.T) {
+		policy, err := mountpolicy.Load(filepath.Join(t.TempDir(), "policy.yaml"))
+		assert.NoError(t, err)
+		if policy != nil {
+			t.Fatalf("Expected no policy, got %v", policy)
+		}
+	})
+
+	t.Run("fails for unknown fields", func(t *testing.T) {
+		path := writePolicy(t, "namespaces:\n  prod:\n    denied: [\"allow-delete\"]\n")
+		if _, err := mountpolicy.Load(path); err == nil {
+			t.Fatal("Expected an error for unknown field")
+		}
+	})
+
+	t.Run("fails for unknown actions", func(t *testing.T) {
+		path := writePolicy(t, "default:\n  deny: [\"allow-delete\"]\n  action: warn\n")
+		if _, err := mountpolicy.Load(path); err == nil {
+			t.Fatal("Expected an error for unknown action")
+		}
+	})
+}
+
+func loadPolicy(t *testing.T, content string) *mountpolicy.Policy {
+	policy, err := mountpolicy.Load(writePolicy(t, content))
+	assert.NoError(t, err)
+	return policy
+}
+
+func writePolicy(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
@@ -18,32 +18,53 @@ package node
 
 import (
 	"context"
+	"errors"
 	"maps"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	typedstoragev1 "k8s.io/client-go/kubernetes/typed/storage/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
+	"k8s.io/utils/keymutex"
 
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketregion"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
-	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/targetpath"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/util"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
 )
 
 var kubeletPath = util.KubeletPath()
 
 var (
-	nodeCaps = []csi.NodeServiceCapability_RPC_Type{}
+	nodeCaps = []csi.NodeServiceCapability_RPC_Type{
+		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+	}
 )
 
 var (
 	volumeCaps = []csi.VolumeCapability_AccessMode{
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		},
 		{
 			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 		},
@@ -53,40 +74,186 @@ var (
 	}
 )
 
+// Reasons of the events emitted to the workload Pods for milestones of their mounts,
+// so the progress of a mount is visible with `kubectl describe pod` without going through the CSI Driver logs.
+const (
+	EventReasonCredentialsResolved = "MountpointCredentialsResolved"
+	EventReasonCredentialsFailed   = "MountpointCredentialsFailed"
+	EventReasonMounted             = "MountpointMounted"
+	EventReasonMountFailed         = "MountpointMountFailed"
+	EventReasonUnmounted           = "MountpointUnmounted"
+	// EventReasonServiceAccountNotFound is emitted instead of `MountpointCredentialsFailed` while the service account
+	// of a workload Pod with pod-level credentials is not created yet.
+	EventReasonServiceAccountNotFound = "ServiceAccountNotFound"
+)
+
 // S3NodeServer is the implementation of the csi.NodeServer interface
 type S3NodeServer struct {
-	NodeID             string
-	Mounter            mounter.Mounter
+	NodeID  string
+	Mounter mounter.Mounter
+	// EventRecorder is optional, and used to emit events to the workload Pods about health of their volumes.
+	EventRecorder record.EventRecorder
+	// BucketRegionDetector is optional, and used to detect regions of buckets if it's not configured explicitly.
+	BucketRegionDetector *bucketregion.Detector
+	// MountOptionsPolicyFile is optional, and the path of the policy file to allow or deny mount options per namespace.
+	MountOptionsPolicyFile string
+	// MountpointVersion is optional, and the version of Mountpoint installed in this node.
+	// Volumes pinned to a different version via `mountpointVersion` volume attribute are not mounted if it's set.
+	MountpointVersion string
+	// MountRetryPolicy configures how failed mounts are retried, it can be overridden per volume via volume attributes.
+	MountRetryPolicy MountRetryPolicy
+	// UnmountGracePeriod is how long to retry unmounting busy mounts, e.g. while Mountpoint is still uploading files
+	// being written. It can be overridden per volume via `unmountGracePeriod` volume attribute.
+	UnmountGracePeriod time.Duration
+	// ForceUnmountTimeout is how long to wait for an unmount before unmounting forcefully, which aborts the FUSE
+	// connection of the hung Mountpoint process. Hung unmounts are not escalated if it's zero.
+	ForceUnmountTimeout time.Duration
+	// VolumeAttributesClasses is optional, and used to resolve mutable parameters of volumes from their VolumeAttributesClass
+	// if ApplyVolumeAttributesClasses is enabled.
+	VolumeAttributesClasses *VolumeAttributesClassResolver
+	// VolumeCondition is whether to advertise `VOLUME_CONDITION` capability and report conditions of volumes on
+	// `NodeGetVolumeStats`, see [S3NodeServer.volumeCondition]. It's enabled by default.
+	VolumeCondition bool
+	// VolumeMountGroup is whether to advertise `VOLUME_MOUNT_GROUP` capability, so kubelet passes `fsGroup` of
+	// workload Pods to volumes with `respectPodFSGroup` instead of changing ownership of their files itself.
+	// It should only be enabled if `fsGroupPolicy` of the CSIDriver object is `File`.
+	VolumeMountGroup bool
+	// AllowInlineVolumes is whether to mount CSI ephemeral (inline) volumes, which are restricted to a subset of
+	// volume attributes and pod-level credentials, see [inlineVolumeAttributes].
+	AllowInlineVolumes bool
+	// ApplyVolumeAttributesClasses is whether to apply parameters of the VolumeAttributesClass of volumes when mounting them,
+	// which overrides [volumecontext.MutableAttributes] of their PersistentVolumes.
+	ApplyVolumeAttributesClasses bool
+	// BucketChecker is optional, and used to check buckets are accessible with the credentials of the volume before mounting them.
+	BucketChecker BucketChecker
+	// ZoneID is optional, and the ID of the Availability Zone of this node (e.g., `use1-az4`).
+	// It's advertised as the topology of this node, and shared cache buckets in other zones are rejected if it's set.
+	ZoneID string
+	// ReissueTokenServiceAccounts are the service accounts in "namespace/name" format to re-issue tokens of pod-level
+	// credentials for close to expiry if kubelet has not republished their volumes with new tokens, see [TokenReissueWindow].
+	// Tokens are only re-issued for the listed service accounts, as the CSI Driver is only granted to create their tokens.
+	ReissueTokenServiceAccounts []string
+	// MaxMountpoints is the maximum number of Mountpoint processes to run in this node, volumes needing more
+	// fail to publish with `ResourceExhausted`. Mountpoint processes are not limited if it's zero.
+	MaxMountpoints int
+	// PurgeCachesOnDiskPressure is whether to purge local caches of published volumes while this node is under disk pressure,
+	// see [S3NodeServer.WatchDiskPressure].
+	PurgeCachesOnDiskPressure bool
+	// Nodes is optional, and used to advertise the Mountpoint capacity of this node on its Node object if MaxMountpoints is set,
+	// and to check whether this node is under disk pressure if PurgeCachesOnDiskPressure is set.
+	Nodes typedcorev1.NodeInterface
+	// CSIDrivers is optional, and used to re-issue service account tokens with the same lifetime as `tokenRequests` of
+	// the CSIDriver object named DriverName if ReissueTokenServiceAccounts is set.
+	CSIDrivers typedstoragev1.CSIDriverInterface
+	// DriverName is the name of the CSIDriver object of this driver.
+	DriverName string
+
 	credentialProvider *mounter.CredentialProvider
+	publishedVolumes   *publishedVolumes
+	inflightPublishes  *inflightPublishes
+	// mountpointReservations keeps track of Mountpoint processes being spawned to enforce MaxMountpoints.
+	mountpointReservations mountpointReservations
+	prefetches             *prefetches
+	// targetLocks serializes operations on the same target path, as mounts might be re-established by `MonitorMounts` concurrently.
+	targetLocks keymutex.KeyMutex
+	// stagingLocks serializes operations on the same staging target path, it's always acquired after `targetLocks` if both are needed.
+	stagingLocks keymutex.KeyMutex
 }
 
 func NewS3NodeServer(nodeID string, mounter mounter.Mounter, credentialProvider *mounter.CredentialProvider) *S3NodeServer {
-	return &S3NodeServer{NodeID: nodeID, Mounter: mounter, credentialProvider: credentialProvider}
+	return &S3NodeServer{
+		NodeID:             nodeID,
+		Mounter:            mounter,
+		credentialProvider: credentialProvider,
+		MountRetryPolicy:   DefaultMountRetryPolicy,
+		VolumeCondition:    true,
+		publishedVolumes:   newPublishedVolumes(),
+		inflightPublishes:  newInflightPublishes(),
+		prefetches:         newPrefetches(),
+		targetLocks:        keymutex.NewHashed(0),
+		stagingLocks:       keymutex.NewHashed(0),
+	}
+}
+
+// SetDefaultSTSConfig sets the driver-level STS configuration used for pod-level credentials and `stsRoleArn`.
+func (ns *S3NodeServer) SetDefaultSTSConfig(config mounter.STSConfig) error {
+	return ns.credentialProvider.SetDefaultSTSConfig(config)
+}
+
+// SetServiceAccountWait sets how long to wait for missing service accounts of workload Pods with pod-level credentials.
+func (ns *S3NodeServer) SetServiceAccountWait(wait time.Duration) {
+	ns.credentialProvider.SetServiceAccountWait(wait)
+}
+
+// SetCredentialBackend sets the backend to retrieve credentials from for volumes with given `authenticationSource`.
+func (ns *S3NodeServer) SetCredentialBackend(source mounter.AuthenticationSource, backend mounter.CredentialBackend) {
+	ns.credentialProvider.SetCredentialBackend(source, backend)
+}
+
+// SetDriverCredentialProcess sets the `credential_process` command to obtain driver-level credentials with.
+func (ns *S3NodeServer) SetDriverCredentialProcess(process string) error {
+	return ns.credentialProvider.SetDriverCredentialProcess(process)
 }
 
+// NodeStageVolume only validates the request, the volume is mounted at the staging target path lazily
+// by the first `NodePublishVolume` call as mount options policy and credentials might depend on the workload Pod,
+// which is not known in this call.
 func (ns *S3NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
-	volumeCtx := req.GetVolumeContext()
-	if volumeCtx[volumecontext.AuthenticationSource] == mounter.AuthenticationSourcePod {
-		podID := volumeCtx[volumecontext.CSIPodUID]
-		volumeID := req.GetVolumeId()
-		if podID != "" && volumeID != "" {
-			err := ns.credentialProvider.CleanupToken(volumeID, podID)
-			if err != nil {
-				klog.V(4).Infof("NodeStageVolume: Failed to cleanup token for pod/volume %s/%s: %v", podID, volumeID, err)
-			}
-		}
+	klog.V(4).Infof("NodeStageVolume: called for volume %s with staging target path %s", req.GetVolumeId(), req.GetStagingTargetPath())
+
+	if len(req.GetVolumeId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	return nil, status.Error(codes.Unimplemented, "")
+	if len(req.GetStagingTargetPath()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Staging target path not provided")
+	}
+
+	if req.GetVolumeCapability() == nil {
+		return nil, status.Error(codes.InvalidArgument, "Volume capability not provided")
+	}
+
+	if !ns.isValidVolumeCapabilities([]*csi.VolumeCapability{req.GetVolumeCapability()}) {
+		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
+	}
+
+	return &csi.NodeStageVolumeResponse{}, nil
 }
 
+// NodeUnstageVolume unmounts the volume mounted at the staging target path, if any.
+// Kubelet only calls this once all workload Pods using the volume in this node are unpublished.
 func (ns *S3NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	klog.V(4).Infof("NodeUnstageVolume: called for volume %s with staging target path %s", req.GetVolumeId(), req.GetStagingTargetPath())
+
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	stagingTarget := req.GetStagingTargetPath()
+	if len(stagingTarget) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Staging target path not provided")
+	}
+
+	ns.stagingLocks.LockKey(stagingTarget)
+	defer ns.stagingLocks.UnlockKey(stagingTarget)
+
+	if err := ns.unmountIfMounted(ctx, "NodeUnstageVolume", volumeID, stagingTarget, ns.UnmountGracePeriod); err != nil {
+		return nil, err
+	}
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
 }
 
 func (ns *S3NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
 	klog.V(4).Infof("NodePublishVolume: new request: %+v", logSafeNodePublishVolumeRequest(req))
 
+	return ns.inflightPublishes.do(ctx, req, func() (*csi.NodePublishVolumeResponse, error) {
+		return ns.nodePublishVolume(ctx, req)
+	})
+}
+
+func (ns *S3NodeServer) nodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
@@ -94,8 +261,22 @@ func (ns *S3NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePubl
 
 	volumeCtx := req.GetVolumeContext()
 
+	inline := isInlineVolume(volumeCtx)
+	if inline {
+		inlineCtx, err := ns.inlineVolumeContext(volumeCtx, req.GetSecrets())
+		if err != nil {
+			return nil, err
+		}
+		volumeCtx = inlineCtx
+	}
+
+	compositeEntries, err := parseCompositeEntries(volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
 	bucket, ok := volumeCtx[volumecontext.BucketName]
-	if !ok {
+	if !ok && compositeEntries == nil {
 		return nil, status.Error(codes.InvalidArgument, "Bucket name not provided")
 	}
 
@@ -108,17 +289,47 @@ func (ns *S3NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePubl
 		klog.Errorf("NodePublishVolume: target path %q is not in kubelet path %q. This might cause mounting issues, please ensure you have correct kubelet path configured.", target, kubeletPath)
 	}
 
+	volumeCtx, err = ns.applyVolumeAttributesClass(ctx, volumeID, target, volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinnedVersion := volumeCtx[volumecontext.MountpointVersion]; pinnedVersion != "" && ns.MountpointVersion != "" && pinnedVersion != ns.MountpointVersion {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume is pinned to Mountpoint version %s, but version %s is installed in this node", pinnedVersion, ns.MountpointVersion)
+	}
+
 	volCap := req.GetVolumeCapability()
 	if volCap == nil {
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not provided")
 	}
 
-	if !ns.isValidVolumeCapabilities([]*csi.VolumeCapability{volCap}) {
+	// Kubelet always uses `SINGLE_NODE_WRITER` access mode for CSI ephemeral (inline) volumes,
+	// which is fine as inline volumes are only accessible by the Pod they're declared in.
+	if inline {
+		if volCap.GetMount() == nil || volCap.GetAccessMode().GetMode() != csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
+			return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
+		}
+	} else if !ns.isValidVolumeCapabilities([]*csi.VolumeCapability{volCap}) {
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
 	}
 
+	// Volumes are mounted once at the staging target path and bind mounted to target paths of the workload Pods,
+	// unless Mountpoint needs to be spawned with credentials, `fsGroup` or a prefix specific to the workload Pod,
+	// or the volume is a composite volume. Staging target path is not passed for CSI ephemeral (inline) volumes.
+	// Credentials written per workload Pod (e.g., from Vault or `stsRoleArn`) are removed once the Pod is unpublished,
+	// so they're not shared either.
+	stagingTarget := req.GetStagingTargetPath()
+	staged := stagingTarget != "" && !ns.credentialProvider.UsesPodCredentialFiles(volumeCtx) &&
+		!respectsPodFSGroup(volumeCtx) && !hasPrefixVariables(volumeCtx[volumecontext.Prefix]) && compositeEntries == nil
+
+	mountTarget := target
+	if staged {
+		mountTarget = stagingTarget
+	}
+
 	mountpointArgs := []string{}
-	if req.GetReadonly() || volCap.GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+	// `readOnly` of the workload Pod's volume mount is applied to the bind mount for staged volumes.
+	if (req.GetReadonly() && !staged) || volCap.GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
 		mountpointArgs = append(mountpointArgs, mountpoint.ArgReadOnly)
 	}
 
@@ -127,21 +338,198 @@ func (ns *S3NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePubl
 		mountpointArgs = append(mountpointArgs, mountFlags...)
 	}
 
+	// Mount options can also be passed via volume context, which is the only way to pass them for CSI ephemeral (inline) volumes.
+	if mountOptions := volumeCtx[volumecontext.MountOptions]; mountOptions != "" {
+		mountpointArgs = append(mountpointArgs, strings.Split(mountOptions, ",")...)
+	}
+
 	args := mountpoint.ParseArgs(mountpointArgs)
 
-	credentials, err := ns.credentialProvider.Provide(ctx, req.VolumeId, req.VolumeContext, args)
+	if err := ns.applySELinuxContext(publishedVolume{volumeID: volumeID, volumeCtx: volumeCtx}, &args); err != nil {
+		return nil, err
+	}
+
+	// Volumes with `ReadOnlyMany` access mode, or mounted with `readOnly: true` without staging, are mounted with `--read-only`,
+	// and mount options enabling writes are rejected rather than silently having no effect.
+	if err := args.ValidateReadOnly(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid mount options: %v", err)
+	}
+
+	// Volumes can be scoped to a prefix in the bucket via volume context, which is also used by dynamically provisioned volumes in a shared bucket.
+	if prefix, ok := volumeCtx[volumecontext.Prefix]; ok {
+		if !strings.HasSuffix(prefix, "/") {
+			return nil, status.Errorf(codes.InvalidArgument, "Prefix %q must end with \"/\"", prefix)
+		}
+		prefix, err := expandPrefix(prefix, volumeCtx)
+		if err != nil {
+			return nil, err
+		}
+		args.Set(mountpoint.ArgPrefix, prefix)
+	}
+
+	if err := setEndpointArgs(volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if err := ns.setExpressCacheArgs(volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if logLevel, ok := volumeCtx[volumecontext.LogLevel]; ok {
+		if err := args.SetLogLevel(logLevel); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid log level: %v", err)
+		}
+	}
+
+	if err := setFUSEArgs(volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if err := setMaxThroughputArg(volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if err := setSSEArgs(volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if _, err := mountpointEnvFor(volumeCtx); err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err := ns.mountRetryPolicyFor(volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ns.unmountGracePeriodFor(volumeCtx); err != nil {
+		return nil, err
+	}
+
+	prefetchPaths, err := parsePrefetchPaths(volumeCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if compositeEntries != nil {
+		if prefetchPaths != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is not supported for composite volumes", volumecontext.PrefetchPaths)
+		}
+		if _, ok := volumeCtx[volumecontext.ScopedSessionPolicy]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is not supported for composite volumes", volumecontext.ScopedSessionPolicy)
+		}
+		mountpoints := 0
+		for _, entry := range compositeEntries {
+			if _, republished := ns.publishedVolumes.get(filepath.Join(target, entry.Path)); !republished {
+				mountpoints++
+			}
+		}
+		release, err := ns.reserveMountpoints(publishedVolume{volumeID: volumeID, volumeCtx: volumeCtx}, mountpoints)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return ns.publishComposite(ctx, req, compositeEntries, args, retryPolicy)
+	}
+
+	if err := setCacheArgs(mountTarget, volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	// The policy is applied once mount options and volume attributes (e.g., `prefix` or `endpointUrl`) are translated to
+	// arguments, but before the arguments set by the CSI Driver itself, which the cluster admin can't opt out of.
+	if err := ns.applyMountOptionsPolicy(volumeID, volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	if err := setDriverArgs(bucket, volCap, volumeCtx, &args); err != nil {
+		return nil, err
+	}
+
+	// Volumes published more than once for the same workload Pod are bind mounted from the target path they're
+	// published at first, rather than spawning another Mountpoint process with the same options and credentials.
+	if !staged {
+		sharedVol := publishedVolume{volumeID: volumeID, bucket: bucket, volumeCtx: volumeCtx, secrets: req.GetSecrets(), args: args.SortedList()}
+		if published, ok := ns.publishedVolumes.get(target); ok {
+			if published.sourceTarget != "" {
+				return ns.republishFromSource(ctx, target, sharedVol)
+			}
+		} else if source, ok := ns.publishedVolumes.findSource(target, sharedVol); ok {
+			return ns.publishFromSource(target, source, sharedVol)
+		}
+	}
+
+	// Only used to emit events until the volume is mounted.
+	eventVol := publishedVolume{volumeID: volumeID, volumeCtx: volumeCtx}
+
+	// Republishes and staged volumes already mounted at the staging target path don't spawn Mountpoint processes.
+	mountpoints := 1
+	if _, republished := ns.publishedVolumes.get(target); republished || (staged && ns.publishedVolumes.isStaged(stagingTarget)) {
+		mountpoints = 0
+	}
+	release, err := ns.reserveMountpoints(eventVol, mountpoints)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	credentials, err := ns.provideCredentials(ctx, req.VolumeId, volumeCtx, req.GetSecrets(), args)
 	if err != nil {
 		klog.Errorf("NodePublishVolume: failed to provide credentials: %v", err)
+		ns.recordCredentialsFailed(eventVol, err)
+		return nil, err
+	}
+	ns.recordEvent(eventVol, corev1.EventTypeNormal, EventReasonCredentialsResolved,
+		"Resolved credentials for volume %s (authentication source: %s)", volumeID, authenticationSourceLabel(volumeCtx))
+
+	ns.setDetectedRegion(ctx, volumeID, bucket, volumeCtx, credentials, &args)
+
+	if err := ns.checkBucket(ctx, volumeID, target, bucket, volumeCtx, credentials, args); err != nil {
+		ns.recordEvent(eventVol, corev1.EventTypeWarning, EventReasonMountFailed,
+			"Could not mount bucket %s for volume %s: %v", bucket, volumeID, status.Convert(err).Message())
 		return nil, err
 	}
 
-	klog.V(4).Infof("NodePublishVolume: mounting %s at %s with options %v", bucket, target, args.SortedList())
+	klog.V(4).InfoS("NodePublishVolume: mounting", "bucket", bucket, "options", args.SortedList(),
+		logging.KeyVolumeID, volumeID, logging.KeyPodUID, volumeCtx[volumecontext.CSIPodUID], logging.KeyTargetPath, target)
+
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
 
-	if err := ns.Mounter.Mount(bucket, target, credentials, args); err != nil {
+	publishedVol := publishedVolume{
+		volumeID:  volumeID,
+		bucket:    bucket,
+		volumeCtx: volumeCtx,
+		secrets:   req.GetSecrets(),
+		args:      args.SortedList(),
+		roleARN:   roleARNOf(volumeCtx, credentials),
+	}
+
+	if staged {
+		publishedVol.stagingTarget = stagingTarget
+		publishedVol.readOnly = req.GetReadonly()
+	}
+	err = retryPolicy.withRetries(volumeID, target, func() error {
+		if staged {
+			return ns.mountStaged(bucket, stagingTarget, target, credentials, args, req.GetReadonly())
+		}
+		return ns.Mounter.Mount(bucket, target, credentials, args)
+	})
+	if err != nil {
+		mountFailuresTotal.Inc()
 		os.Remove(target)
+		ns.recordEvent(eventVol, corev1.EventTypeWarning, EventReasonMountFailed,
+			"Could not mount bucket %s for volume %s: %v", bucket, volumeID, err)
 		return nil, status.Errorf(codes.Internal, "Could not mount %q at %q: %v", bucket, target, err)
 	}
-	klog.V(4).Infof("NodePublishVolume: %s was mounted", target)
+	klog.V(4).InfoS("NodePublishVolume: mounted",
+		logging.KeyVolumeID, volumeID, logging.KeyPodUID, volumeCtx[volumecontext.CSIPodUID], logging.KeyTargetPath, target)
+
+	ns.publishedVolumes.add(target, publishedVol)
+	audit(auditActionAttach, target, publishedVol)
+	ns.recordEvent(publishedVol, corev1.EventTypeNormal, EventReasonMounted, "Mounted bucket %s for volume %s with %s",
+		bucket, volumeID, publishedVol.accessSummary())
+	ns.startPrefetch(target, publishedVol, prefetchPaths)
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
@@ -183,56 +571,79 @@ func (ns *S3NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUn
 		return nil, status.Error(codes.InvalidArgument, "Target path not provided")
 	}
 
-	mounted, err := ns.Mounter.IsMountPoint(target)
-	if err != nil && os.IsNotExist(err) {
-		klog.V(4).Infof("NodeUnpublishVolume: target path %s does not exist, skipping unmount", target)
-		return &csi.NodeUnpublishVolumeResponse{}, nil
-	} else if err != nil && mount.IsCorruptedMnt(err) {
-		klog.V(4).Infof("NodeUnpublishVolume: target path %s is corrupted: %v, will try to unmount", target, err)
-		mounted = true
-	} else if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
-	}
-	if !mounted {
-		klog.V(4).Infof("NodeUnpublishVolume: target path %s not mounted, skipping unmount", target)
+	// The target path this one is bind mounted from is released once the lock of this one is released.
+	var sourceTarget string
+	defer func() {
+		if sourceTarget != "" {
+			ns.releaseSource(ctx, sourceTarget)
+		}
+	}()
+
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
+
+	publishedVol, published := ns.publishedVolumes.get(target)
+
+	// Other target paths of the workload Pod might be bind mounted from this one, it's only unmounted once they're unpublished too.
+	if published && ns.publishedVolumes.hasDependents(target) {
+		klog.V(4).Infof("NodeUnpublishVolume: volume %s is bind mounted from %s to other target paths, it will be unmounted once they're unpublished", volumeID, target)
+		publishedVol.released = true
+		ns.publishedVolumes.add(target, publishedVol)
 		return &csi.NodeUnpublishVolumeResponse{}, nil
 	}
+	ns.prefetches.stop(target)
 
-	klog.V(4).Infof("NodeUnpublishVolume: unmounting %s", target)
-	err = ns.Mounter.Unmount(target)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
+	gracePeriod := ns.UnmountGracePeriod
+	if published {
+		// The grace period is validated on publish, it falls back to the driver-level one for volumes published before.
+		if value, err := ns.unmountGracePeriodFor(publishedVol.volumeCtx); err == nil {
+			gracePeriod = value
+		}
+	}
+	if err := ns.unmountIfMounted(ctx, "NodeUnpublishVolume", volumeID, target, gracePeriod); err != nil {
+		return nil, err
+	}
+	// The volume is only forgotten once it's unmounted, so retries of kubelet use the same grace period.
+	ns.publishedVolumes.remove(target)
+	if published {
+		audit(auditActionDetach, target, publishedVol)
+	}
+	if compositeVol, compositePublished, err := ns.unmountComposite(ctx, volumeID, target); err != nil {
+		return nil, err
+	} else if compositePublished {
+		publishedVol, published = compositeVol, true
+	}
+	if published {
+		ns.recordEvent(publishedVol, corev1.EventTypeNormal, EventReasonUnmounted, "Unmounted volume %s", volumeID)
 	}
 
-	targetPath, err := targetpath.Parse(target)
-	if err == nil {
-		if targetPath.VolumeID != volumeID {
-			klog.V(4).Infof("NodeUnpublishVolume: Volume ID from parsed target path differs from Volume ID passed: %s (parsed) != %s (passed)", targetPath.VolumeID, volumeID)
-		} else {
-			err := ns.credentialProvider.CleanupToken(targetPath.VolumeID, targetPath.PodID)
-			if err != nil {
-				klog.V(4).Infof("NodeUnpublishVolume: Failed to cleanup token for pod/volume %s/%s: %v", targetPath.PodID, volumeID, err)
-			}
-		}
+	// Credentials are shared with the target path this one was bind mounted from, they're cleaned up once it's unmounted.
+	if published && publishedVol.sourceTarget != "" {
+		sourceTarget = publishedVol.sourceTarget
 	} else {
-		klog.V(4).Infof("NodeUnpublishVolume: Failed to parse target path %s: %v", target, err)
+		ns.cleanupCredentials(volumeID, target)
 	}
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
-func (ns *S3NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
-}
-
+// NodeExpandVolume is not supported as there is nothing to resize for a Mountpoint mount,
+// volumes are expanded by the controller service alone without requiring node expansion.
 func (ns *S3NodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (ns *S3NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
 	klog.V(4).Infof("NodeGetCapabilities: called with args %+v", req)
+	rpcTypes := slices.Clone(nodeCaps)
+	if ns.VolumeCondition {
+		rpcTypes = append(rpcTypes, csi.NodeServiceCapability_RPC_VOLUME_CONDITION)
+	}
+	if ns.VolumeMountGroup {
+		rpcTypes = append(rpcTypes, csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP)
+	}
 	var caps []*csi.NodeServiceCapability
-	for _, cap := range nodeCaps {
+	for _, cap := range rpcTypes {
 		c := &csi.NodeServiceCapability{
 			Type: &csi.NodeServiceCapability_Rpc{
 				Rpc: &csi.NodeServiceCapability_RPC{
@@ -248,9 +659,14 @@ func (ns *S3NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGe
 func (ns *S3NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
 	klog.V(4).Infof("NodeGetInfo: called with args %+v", req)
 
-	return &csi.NodeGetInfoResponse{
+	resp := &csi.NodeGetInfoResponse{
 		NodeId: ns.NodeID,
-	}, nil
+	}
+	// The zone is advertised to schedule workloads using directory buckets to the zone of their buckets.
+	if ns.ZoneID != "" {
+		resp.AccessibleTopology = topology.ForZone(ns.ZoneID)
+	}
+	return resp, nil
 }
 
 func (ns *S3NodeServer) isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
@@ -272,6 +688,253 @@ func (ns *S3NodeServer) isValidVolumeCapabilities(volCaps []*csi.VolumeCapabilit
 	return foundAll
 }
 
+// setEndpointArgs validates and sets arguments for S3-compatible endpoints passed via volume context.
+func setEndpointArgs(volumeCtx map[string]string, args *mountpoint.Args) error {
+	if endpointURL, ok := volumeCtx[volumecontext.EndpointURL]; ok {
+		u, err := url.Parse(endpointURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return status.Errorf(codes.InvalidArgument, "Endpoint URL %q must be an absolute http or https URL", endpointURL)
+		}
+		args.Set(mountpoint.ArgEndpointURL, endpointURL)
+	}
+
+	if forcePathStyle, ok := volumeCtx[volumecontext.ForcePathStyle]; ok {
+		enabled, err := strconv.ParseBool(forcePathStyle)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Force path style %q must be a boolean", forcePathStyle)
+		}
+		if enabled {
+			args.Set(mountpoint.ArgForcePathStyle, mountpoint.ArgNoValue)
+		}
+	}
+
+	for _, variant := range endpointVariants {
+		if err := setEndpointVariantArg(volumeCtx, variant.attribute, variant.arg, args); err != nil {
+			return err
+		}
+	}
+
+	if caBundleSecretRef, ok := volumeCtx[volumecontext.CABundleSecretRef]; ok {
+		if errs := validation.IsDNS1123Subdomain(caBundleSecretRef); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "CA bundle secret reference %q is not a valid Secret name: %s", caBundleSecretRef, strings.Join(errs, ", "))
+		}
+		// Mountpoint spawned by the node plugin uses the trust store of the host, and would fail to verify the
+		// endpoint's certificate, CA bundles are only mounted into Mountpoint Pods spawned by `aws-s3-csi-controller`.
+		return status.Errorf(codes.InvalidArgument, "CA bundle secret reference %q is only supported by Mountpoint Pods, "+
+			"install the CA in the trust store of the host for Mountpoint spawned by the node plugin instead", caBundleSecretRef)
+	}
+
+	return nil
+}
+
+// endpointVariants are the volume attributes to use a FIPS or dual-stack variant of the S3 endpoint,
+// and the environment variables of the node plugin to use them by default.
+var endpointVariants = []struct {
+	attribute string
+	env       envprovider.Key
+	arg       mountpoint.ArgKey
+}{
+	{volumecontext.UseFIPSEndpoint, envprovider.EnvUseFIPSEndpoint, mountpoint.ArgFIPS},
+	{volumecontext.UseDualStackEndpoint, envprovider.EnvUseDualStackEndpoint, mountpoint.ArgDualStack},
+}
+
+// setEndpointVariantArg sets `arg` to use a FIPS or dual-stack variant of the S3 endpoint if its enabled by `attribute`
+// in `volumeCtx`. The driver-level default is applied by [setDefaultEndpointVariantArgs] instead.
+func setEndpointVariantArg(volumeCtx map[string]string, attribute string, arg mountpoint.ArgKey, args *mountpoint.Args) error {
+	value, ok := volumeCtx[attribute]
+	if !ok {
+		return nil
+	}
+	return setEndpointVariantValue(attribute, value, arg, args)
+}
+
+// setDefaultEndpointVariantArgs sets the FIPS or dual-stack variant arguments enabled by the environment of the node plugin,
+// for volumes not configuring them. Endpoint variants only apply to AWS endpoints, so the driver-level default is ignored
+// for volumes with an endpoint URL.
+func setDefaultEndpointVariantArgs(volumeCtx map[string]string, args *mountpoint.Args) error {
+	if args.Has(mountpoint.ArgEndpointURL) {
+		return nil
+	}
+	for _, variant := range endpointVariants {
+		if _, ok := volumeCtx[variant.attribute]; ok {
+			continue
+		}
+		if value := os.Getenv(variant.env); value != "" {
+			if err := setEndpointVariantValue(variant.attribute, value, variant.arg, args); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setEndpointVariantValue sets or removes `arg` depending on the boolean `value` of `attribute`.
+func setEndpointVariantValue(attribute, value string, arg mountpoint.ArgKey, args *mountpoint.Args) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s %q must be a boolean", attribute, value)
+	}
+	if !enabled {
+		args.Remove(arg)
+		return nil
+	}
+	if args.Has(mountpoint.ArgEndpointURL) {
+		return status.Errorf(codes.InvalidArgument, "%s cannot be used with an endpoint URL", attribute)
+	}
+
+	args.Set(arg, mountpoint.ArgNoValue)
+	return nil
+}
+
+// setDriverArgs sets the Mountpoint arguments the CSI Driver adds on its own, rather than translating them from
+// mount options or volume attributes of the volume, i.e., `fsGroup` of the workload Pod, driver-level endpoint variants,
+// and the arguments specific to the type of `bucket`. They're set after the mount options policy is applied, and the region
+// of `bucket` is detected once credentials are provided, see [S3NodeServer.setDetectedRegion].
+func setDriverArgs(bucket string, volCap *csi.VolumeCapability, volumeCtx map[string]string, args *mountpoint.Args) error {
+	if err := setFSGroupArgs(volCap, volumeCtx, args); err != nil {
+		return err
+	}
+	if err := setDefaultEndpointVariantArgs(volumeCtx, args); err != nil {
+		return err
+	}
+	return setBucketArgs(bucket, volumeCtx, args)
+}
+
+// setBucketArgs sets Mountpoint arguments specific to the type of `bucket`.
+func setBucketArgs(bucket string, volumeCtx map[string]string, args *mountpoint.Args) error {
+	directoryBucket, err := isDirectoryBucket(bucket, volumeCtx)
+	if err != nil {
+		return err
+	}
+	if directoryBucket {
+		return setDirectoryBucketArgs(args)
+	}
+	return nil
+}
+
+// setDetectedRegion sets `--region` argument to the region of `bucket` if the region is not configured explicitly
+// via mount options or environment variables. Otherwise, Mountpoint would use the region of the node, which
+// fails for buckets in other regions. The region is detected with the same `credentials` and endpoint as the mount.
+//
+// It's a no-op unless [S3NodeServer.BucketRegionDetector] is set. Directory buckets and volumes whose credentials
+// cannot be used by the CSI Driver are skipped, and if the detection fails, Mountpoint is invoked without a region as before.
+func (ns *S3NodeServer) setDetectedRegion(ctx context.Context, volumeID, bucket string, volumeCtx map[string]string, credentials *mounter.MountCredentials, args *mountpoint.Args) {
+	if ns.BucketRegionDetector == nil || args.Has(mountpoint.ArgRegion) || envprovider.Region() != "" {
+		return
+	}
+	if directoryBucket, err := isDirectoryBucket(bucket, volumeCtx); err != nil || directoryBucket {
+		return
+	}
+
+	sdkCredentials, ok := ns.credentialProvider.SDKCredentials(volumeID, volumeCtx, credentials)
+	if !ok {
+		klog.V(4).Infof("NodePublishVolume: skipping region detection of bucket %s, its credentials cannot be used by the CSI Driver", bucket)
+		return
+	}
+
+	endpoint, _ := args.Value(mountpoint.ArgEndpointURL)
+	region, err := ns.BucketRegionDetector.Region(ctx, bucketregion.Input{
+		Bucket:         bucket,
+		Endpoint:       endpoint,
+		ForcePathStyle: args.Has(mountpoint.ArgForcePathStyle),
+		Credentials:    sdkCredentials,
+		HTTPClient:     mounter.HTTPClientFor(volumeCtx),
+	})
+	if err != nil {
+		klog.Warningf("NodePublishVolume: failed to detect region of bucket %s, Mountpoint will use the region of the node: %v", bucket, err)
+		return
+	}
+
+	klog.V(4).Infof("NodePublishVolume: detected region %s for bucket %s", region, bucket)
+	args.Set(mountpoint.ArgRegion, region)
+}
+
+// mountStaged mounts `bucket` at `stagingTarget` if it's not mounted yet, and bind mounts it to `target`.
+// The bind mount is made read-only if `readOnly` is set, which allows sharing the same Mountpoint process
+// with workload Pods mounting the volume as read-only.
+func (ns *S3NodeServer) mountStaged(bucket, stagingTarget, target string, credentials *mounter.MountCredentials, args mountpoint.Args, readOnly bool) error {
+	ns.stagingLocks.LockKey(stagingTarget)
+	defer ns.stagingLocks.UnlockKey(stagingTarget)
+
+	// `Mount` is a no-op if `stagingTarget` is already mounted, and re-mounts it if it's corrupted.
+	if err := ns.Mounter.Mount(bucket, stagingTarget, credentials, args); err != nil {
+		return err
+	}
+
+	return ns.Mounter.BindMount(stagingTarget, target, readOnly)
+}
+
+// unmountIfMounted unmounts `target` if it's a `mount-s3` mount, either a Mountpoint mount or a bind mount of it.
+// Busy mounts are retried until `gracePeriod` elapses, see `unmountWithGracePeriod`.
+func (ns *S3NodeServer) unmountIfMounted(ctx context.Context, rpcName, volumeID, target string, gracePeriod time.Duration) error {
+	var mounted bool
+	err := ns.callWithHangTimeout(func() (err error) {
+		mounted, err = ns.Mounter.IsMountPoint(target)
+		return err
+	})
+	if errors.Is(err, errHung) {
+		if err := ns.forceUnmount(rpcName, volumeID, target, err); err != nil {
+			return status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
+		}
+		return nil
+	} else if err != nil && os.IsNotExist(err) {
+		klog.V(4).Infof("%s: target path %s does not exist, skipping unmount", rpcName, target)
+		return nil
+	} else if err != nil && mount.IsCorruptedMnt(err) {
+		klog.V(4).Infof("%s: target path %s is corrupted: %v, will try to unmount", rpcName, target, err)
+		mounted = true
+	} else if err != nil {
+		return status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
+	}
+	if !mounted {
+		klog.V(4).Infof("%s: target path %s not mounted, skipping unmount", rpcName, target)
+		return nil
+	}
+
+	klog.V(4).InfoS(rpcName+": unmounting", logging.KeyVolumeID, volumeID, logging.KeyTargetPath, target)
+	if err := ns.unmountWithGracePeriod(ctx, rpcName, volumeID, target, gracePeriod); err != nil {
+		return status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
+	}
+	return nil
+}
+
+// provideCredentials provides mount credentials for given volume.
+// If the volume references a Kubernetes Secret via `nodePublishSecretRef`, its contents are passed as `secrets`
+// and credentials in the secret are used, otherwise credentials are provided based on the authentication source of the volume.
+func (ns *S3NodeServer) provideCredentials(ctx context.Context, volumeID string, volumeCtx map[string]string, secrets map[string]string, args mountpoint.Args) (*mounter.MountCredentials, error) {
+	var credentials *mounter.MountCredentials
+	var err error
+	if len(secrets) > 0 {
+		credentials, err = ns.credentialProvider.ProvideFromSecret(volumeCtx, secrets)
+	} else {
+		credentials, err = ns.credentialProvider.Provide(ctx, volumeID, volumeCtx, args)
+	}
+	if err == nil && volumeCtx[volumecontext.STSRoleARN] != "" {
+		credentials, err = ns.credentialProvider.AssumeRole(ctx, volumeID, volumeCtx, args, credentials)
+	}
+	if err != nil {
+		credentialFailuresTotal.WithLabelValues(authenticationSourceLabel(volumeCtx)).Inc()
+		return nil, err
+	}
+	recordCredentialsRefresh(volumeID, volumeCtx, credentials)
+	if credentials.MountpointEnv, err = mountpointEnvFor(volumeCtx); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// recordCredentialsFailed emits an event to the workload Pod of `vol` as its credentials failed to be provided with `err`.
+func (ns *S3NodeServer) recordCredentialsFailed(vol publishedVolume, err error) {
+	var notFound *mounter.ServiceAccountNotFoundError
+	if errors.As(err, &notFound) {
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonServiceAccountNotFound,
+			"Service account %s/%s not found, mounting volume %s will be retried once it's created", notFound.Namespace, notFound.Name, vol.volumeID)
+		return
+	}
+	ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonCredentialsFailed,
+		"Failed to provide credentials for volume %s: %v", vol.volumeID, err)
+}
+
 // logSafeNodePublishVolumeRequest returns a copy of given `csi.NodePublishVolumeRequest`
 // with sensitive fields removed.
 func logSafeNodePublishVolumeRequest(req *csi.NodePublishVolumeRequest) *csi.NodePublishVolumeRequest {
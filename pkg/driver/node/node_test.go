@@ -4,11 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/awsprofile"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketcheck"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/bucketregion"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
 	mock_driver "github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter/mocks"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
@@ -17,7 +26,16 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 )
 
 type nodeServerTestEnv struct {
@@ -175,179 +193,2840 @@ func TestNodePublishVolume(t *testing.T) {
 			},
 		},
 		{
-			name: "fail: missing volume id",
+			name: "success: prefix from volume context",
 			testFunc: func(t *testing.T) {
 				nodeTestEnv := initNodeServerTestEnv(t)
 				ctx := context.Background()
 				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
 					VolumeCapability: stdVolCap,
 					TargetPath:       targetPath,
-					VolumeContext:    map[string]string{"bucketName": bucketName},
+					VolumeContext:    map[string]string{"bucketName": bucketName, "prefix": "pvc-123/"},
 				}
 
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{"--prefix=pvc-123/"}))).Return(nil)
 				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
-				if err == nil {
+				if err != nil {
 					t.Fatalf("NodePublishVolume is failed: %v", err)
 				}
+
 				nodeTestEnv.mockCtl.Finish()
 			},
 		},
-	}
+		{
+			name: "success: credentials from node publish secret",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName},
+					Secrets:          map[string]string{"key_id": "test-access-key", "access_key": "test-secret-key"},
+				}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, tc.testFunc)
-	}
-}
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs(nil))).
+					Do(func(_, _ string, credentials *mounter.MountCredentials, _ mountpoint.Args) {
+						assert.Equals(t, "test-access-key", credentials.AccessKeyID)
+						assert.Equals(t, "test-secret-key", credentials.SecretAccessKey)
+					}).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume is failed: %v", err)
+				}
 
-func TestNodeUnpublishVolume(t *testing.T) {
-	var (
-		volumeId   = "test-volume-id"
-		targetPath = "/target/path"
-	)
-	testCases := []struct {
-		name     string
-		testFunc func(t *testing.T)
-	}{
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
 		{
-			name: "success: happy path",
+			name: "success: S3-compatible endpoint from volume context",
 			testFunc: func(t *testing.T) {
 				nodeTestEnv := initNodeServerTestEnv(t)
 				ctx := context.Background()
-				req := &csi.NodeUnpublishVolumeRequest{
-					VolumeId:   volumeId,
-					TargetPath: targetPath,
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":     bucketName,
+						"endpointUrl":    "https://minio.example.com:9000",
+						"forcePathStyle": "true",
+					},
 				}
 
-				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
-				nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil)
-				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{"--endpoint-url=https://minio.example.com:9000", "--force-path-style"}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
 				if err != nil {
-					t.Fatalf("NodePublishVolume failed: %v", err)
+					t.Fatalf("NodePublishVolume is failed: %v", err)
 				}
 
 				nodeTestEnv.mockCtl.Finish()
 			},
 		},
 		{
-			name: "success: not mounted",
+			name: "success: FIPS and dual-stack endpoints",
 			testFunc: func(t *testing.T) {
+				t.Setenv("AWS_USE_FIPS_ENDPOINT", "true")
+				t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
 				nodeTestEnv := initNodeServerTestEnv(t)
 				ctx := context.Background()
-				req := &csi.NodeUnpublishVolumeRequest{
-					VolumeId:   volumeId,
-					TargetPath: targetPath,
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":           bucketName,
+						"useDualStackEndpoint": "true",
+					},
 				}
 
-				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, nil)
-				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{"--fips", "--dual-stack"}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
 				if err != nil {
-					t.Fatalf("NodePublishVolume failed: %v", err)
+					t.Fatalf("NodePublishVolume is failed: %v", err)
 				}
 
 				nodeTestEnv.mockCtl.Finish()
 			},
 		},
 		{
-			name: "failure: unmount failure is error",
+			name: "success: driver-level FIPS endpoint is overridden by volume context",
 			testFunc: func(t *testing.T) {
+				t.Setenv("AWS_USE_FIPS_ENDPOINT", "true")
 				nodeTestEnv := initNodeServerTestEnv(t)
 				ctx := context.Background()
-				req := &csi.NodeUnpublishVolumeRequest{
-					VolumeId:   volumeId,
-					TargetPath: targetPath,
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":      bucketName,
+						"useFipsEndpoint": "false",
+					},
 				}
 
-				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
-				nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(errors.New(""))
-				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
-				if err == nil {
-					t.Fatalf("NodePublishVolume must fail")
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs(nil))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume is failed: %v", err)
 				}
 
 				nodeTestEnv.mockCtl.Finish()
 			},
 		},
 		{
-			name: "success: inexistent dir",
+			name: "success: region detected from bucket",
 			testFunc: func(t *testing.T) {
+				t.Setenv("AWS_REGION", "")
+				t.Setenv("AWS_DEFAULT_REGION", "")
+				t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+				t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+				s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Authorization") == "" {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					w.Header().Set("X-Amz-Bucket-Region", "eu-west-1")
+					w.WriteHeader(http.StatusMovedPermanently)
+				}))
+				defer s3Server.Close()
+
 				nodeTestEnv := initNodeServerTestEnv(t)
+				nodeRegion := func() (string, error) { return "us-east-1", nil }
+				nodeTestEnv.server.BucketRegionDetector = bucketregion.NewDetector(nodeRegion, time.Minute)
 				ctx := context.Background()
-				req := &csi.NodeUnpublishVolumeRequest{
-					VolumeId:   volumeId,
-					TargetPath: targetPath,
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName, "endpointUrl": s3Server.URL},
 				}
 
-				expectedError := fs.ErrNotExist
-				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, expectedError)
-				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{"--endpoint-url=" + s3Server.URL, "--region=eu-west-1"}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
 				if err != nil {
-					t.Fatalf("NodePublishVolume failed: %v", err)
+					t.Fatalf("NodePublishVolume is failed: %v", err)
 				}
 
 				nodeTestEnv.mockCtl.Finish()
 			},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, tc.testFunc)
-	}
+		{
+			name: "success: log level from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName, "logLevel": "trace"},
+				}
 
-	t.Run("Cleaning Service Account Token", func(t *testing.T) {
-		containerPluginDir := t.TempDir()
-		credentialProvider := mounter.NewCredentialProvider(nil, containerPluginDir, mounter.RegionFromIMDSOnce)
-		nodeServer := node.NewS3NodeServer("test-node-id", &dummyMounter{}, credentialProvider)
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{"--debug", "--debug-crt"}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume is failed: %v", err)
+				}
 
-		podID := uuid.New().String()
-		volID := "test-vol-id"
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: cache configuration from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				target := filepath.Join(t.TempDir(), "mount")
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       target,
+					VolumeContext: map[string]string{
+						"bucketName":        bucketName,
+						"cacheType":         "emptyDir",
+						"cacheDirSizeLimit": "2Gi",
+						"metadataTTL":       "indefinite",
+					},
+				}
 
-		serviceAccountTokenPath := filepath.Join(containerPluginDir, fmt.Sprintf("%s-%s.token", podID, volID))
-		_, err := os.Create(serviceAccountTokenPath)
-		assert.Equals(t, nil, err)
+				cacheDir := mounter.CacheDir(target)
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(target), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{
+						"--cache=" + cacheDir,
+						"--max-cache-size=1946",
+						"--metadata-ttl=indefinite",
+					}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume is failed: %v", err)
+				}
 
-		targetPath := fmt.Sprintf("/var/lib/kubelet/pods/%s/volumes/kubernetes.io~csi/%s/mount", podID, volID)
+				stat, err := os.Stat(cacheDir)
+				assert.NoError(t, err)
+				assert.Equals(t, true, stat.IsDir())
 
-		_, err = nodeServer.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
-			VolumeId:   volID,
-			TargetPath: targetPath,
-		})
-		assert.Equals(t, nil, err)
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: cache persistent volume claim from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				target := filepath.Join(t.TempDir(), "mount")
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       target,
+					VolumeContext: map[string]string{
+						"bucketName":                 bucketName,
+						"cachePersistentVolumeClaim": "mountpoint-cache",
+						"cacheDirSizeLimit":          "1Gi",
+					},
+				}
 
-		_, err = os.Stat(serviceAccountTokenPath)
-		assert.Equals(t, cmpopts.AnyError, err)
-		assert.Equals(t, true, errors.Is(err, fs.ErrNotExist))
-	})
-}
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(target), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{
+						"--cache=" + mounter.CacheDir(target),
+						"--max-cache-size=973",
+					}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.NoError(t, err)
 
-func TestNodeGetCapabilities(t *testing.T) {
-	nodeTestEnv := initNodeServerTestEnv(t)
-	ctx := context.Background()
-	req := &csi.NodeGetCapabilitiesRequest{}
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: incremental upload for directory buckets",
+			testFunc: func(t *testing.T) {
+				for _, tc := range []struct {
+					bucket    string
+					volumeCtx map[string]string
+					readOnly  bool
+					args      []string
+				}{
+					{bucket: "test-bucket--usw2-az1--x-s3", args: []string{"--incremental-upload"}},
+					{bucket: "test-bucket", volumeCtx: map[string]string{"bucketType": "directory"}, args: []string{"--incremental-upload"}},
+					{bucket: "test-bucket--usw2-az1--x-s3", readOnly: true, args: []string{"--read-only"}},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					volumeCtx := map[string]string{"bucketName": tc.bucket}
+					for k, v := range tc.volumeCtx {
+						volumeCtx[k] = v
+					}
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    volumeCtx,
+						Readonly:         tc.readOnly,
+					}
 
-	resp, err := nodeTestEnv.server.NodeGetCapabilities(ctx, req)
-	if err != nil {
-		t.Fatalf("NodeGetCapabilities failed: %v", err)
-	}
+					nodeTestEnv.mockMounter.EXPECT().Mount(
+						gomock.Eq(tc.bucket), gomock.Eq(targetPath), gomock.Any(),
+						gomock.Eq(mountpoint.ParseArgs(tc.args))).Return(nil)
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					if err != nil {
+						t.Fatalf("NodePublishVolume is failed: %v", err)
+					}
 
-	capabilities := resp.GetCapabilities()
-	if len(capabilities) != 0 {
-		t.Fatalf("NodeGetCapabilities failed: capabilities not empty")
-	}
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "fail: multi node single writer access mode for persistent volume",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId: volumeId,
+					VolumeCapability: &csi.VolumeCapability{
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{},
+						},
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER,
+						},
+					},
+					TargetPath:    targetPath,
+					VolumeContext: map[string]string{"bucketName": bucketName},
+				}
 
-	nodeTestEnv.mockCtl.Finish()
-}
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.Equals(t, codes.InvalidArgument, status.Code(err))
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: prefix without trailing slash",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName, "prefix": "pvc-123"},
+				}
 
-var _ mounter.Mounter = &dummyMounter{}
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.Equals(t, codes.InvalidArgument, status.Code(err))
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: invalid S3-compatible endpoint configuration",
+			testFunc: func(t *testing.T) {
+				for _, volumeCtx := range []map[string]string{
+					{"bucketName": bucketName, "endpointUrl": "minio.example.com"},
+					{"bucketName": bucketName, "forcePathStyle": "yes please"},
+					{"bucketName": bucketName, "caBundleSecretRef": "Invalid_Name"},
+					{"bucketName": bucketName, "endpointUrl": "https://minio.example.com:9000", "caBundleSecretRef": "minio-ca"},
+					{"bucketName": bucketName, "useFipsEndpoint": "yes please"},
+					{"bucketName": bucketName, "endpointUrl": "https://minio.example.com:9000", "useDualStackEndpoint": "true"},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    volumeCtx,
+					}
 
-type dummyMounter struct {
-}
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "success: FUSE settings from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":              bucketName,
+						"fuseMaxBackground":       "256",
+						"fuseCongestionThreshold": "192",
+					},
+				}
 
-func (d *dummyMounter) Mount(bucketName string, target string, credentials *mounter.MountCredentials, args mountpoint.Args) error {
-	return nil
-}
-func (d *dummyMounter) Unmount(target string) error {
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{
+						"--fuse-max-background=256",
+						"--fuse-congestion-threshold=192",
+					}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.NoError(t, err)
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: invalid FUSE settings",
+			testFunc: func(t *testing.T) {
+				for _, volumeCtx := range []map[string]string{
+					{"bucketName": bucketName, "fuseMaxBackground": "0"},
+					{"bucketName": bucketName, "fuseMaxBackground": "65536"},
+					{"bucketName": bucketName, "fuseCongestionThreshold": "lots"},
+					{"bucketName": bucketName, "fuseMaxBackground": "64", "fuseCongestionThreshold": "128"},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    volumeCtx,
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "success: maximum throughput from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":            bucketName,
+						"mountOptions":          "maximum-throughput-gbps=100",
+						"maximumThroughputGbps": "5",
+					},
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().Mount(
+					gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+					gomock.Eq(mountpoint.ParseArgs([]string{
+						"--maximum-throughput-gbps=5",
+					}))).Return(nil)
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.NoError(t, err)
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: invalid maximum throughput",
+			testFunc: func(t *testing.T) {
+				for _, value := range []string{"0", "-1", "1.5", "fast"} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    map[string]string{"bucketName": bucketName, "maximumThroughputGbps": value},
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "success: environment variables from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":    bucketName,
+						"mountpointEnv": `{"HTTPS_PROXY": "http://proxy:3128", "NO_PROXY": "169.254.169.254", "UNSTABLE_MOUNTPOINT_FEATURE": "1"}`,
+					},
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, _ string, credentials *mounter.MountCredentials, _ mountpoint.Args) error {
+						env := credentials.Env(awsprofile.AWSProfile{})
+						assert.Equals(t, "http://proxy:3128", env["HTTPS_PROXY"])
+						assert.Equals(t, "169.254.169.254", env["NO_PROXY"])
+						assert.Equals(t, "1", env["UNSTABLE_MOUNTPOINT_FEATURE"])
+						return nil
+					})
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.NoError(t, err)
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: invalid environment variables",
+			testFunc: func(t *testing.T) {
+				for _, value := range []string{
+					`HTTPS_PROXY=http://proxy:3128`,
+					`{"AWS_ACCESS_KEY_ID": "key"}`,
+					`{"UNSTABLE_MOUNTPOINT_CACHE_KEY": "other-volume"}`,
+					`{"LD_PRELOAD": "/tmp/lib.so"}`,
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    map[string]string{"bucketName": bucketName, "mountpointEnv": value},
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "success: proxy from volume context",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext: map[string]string{
+						"bucketName":    bucketName,
+						"mountpointEnv": `{"HTTPS_PROXY": "http://proxy:3128"}`,
+						"httpsProxy":    "http://team-proxy:3128",
+						"noProxy":       ".internal",
+					},
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ string, _ string, credentials *mounter.MountCredentials, _ mountpoint.Args) error {
+						env := credentials.Env(awsprofile.AWSProfile{})
+						assert.Equals(t, "http://team-proxy:3128", env["HTTPS_PROXY"])
+						assert.Equals(t, ".internal", env["NO_PROXY"])
+						return nil
+					})
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.NoError(t, err)
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: invalid proxy",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName, "httpProxy": "proxy:3128"},
+				}
+
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.Equals(t, codes.InvalidArgument, status.Code(err))
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: server-side encryption from volume context",
+			testFunc: func(t *testing.T) {
+				keyARN := "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+				for _, tc := range []struct {
+					volumeCtx map[string]string
+					args      []string
+				}{
+					{
+						volumeCtx: map[string]string{"bucketName": bucketName, "kmsKeyId": keyARN},
+						args:      []string{"--sse=aws:kms", "--sse-kms-key-id=" + keyARN},
+					},
+					{
+						volumeCtx: map[string]string{"bucketName": bucketName, "sseType": "aws:kms:dsse", "kmsKeyId": keyARN},
+						args:      []string{"--sse=aws:kms:dsse", "--sse-kms-key-id=" + keyARN},
+					},
+					{
+						volumeCtx: map[string]string{"bucketName": bucketName, "sseType": "AES256", "mountOptions": "sse=aws:kms,sse-kms-key-id=" + keyARN},
+						args:      []string{"--sse=AES256"},
+					},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    tc.volumeCtx,
+					}
+
+					nodeTestEnv.mockMounter.EXPECT().Mount(
+						gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+						gomock.Eq(mountpoint.ParseArgs(tc.args))).Return(nil)
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.NoError(t, err)
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "fail: invalid server-side encryption",
+			testFunc: func(t *testing.T) {
+				for _, volumeCtx := range []map[string]string{
+					{"bucketName": bucketName, "sseType": "aws:kms:unknown"},
+					{"bucketName": bucketName, "kmsKeyId": "1234abcd-12ab-34cd-56ef-1234567890ab"},
+					{"bucketName": bucketName, "kmsKeyId": "arn:aws:kms:us-east-1:111122223333:alias/my-key"},
+					{"bucketName": bucketName, "sseType": "AES256", "kmsKeyId": "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    volumeCtx,
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "fail: invalid cache configuration",
+			testFunc: func(t *testing.T) {
+				for _, volumeCtx := range []map[string]string{
+					{"bucketName": bucketName, "cacheType": "hostPath"},
+					{"bucketName": bucketName, "cacheType": "ephemeral"},
+					{"bucketName": bucketName, "cacheType": "ephemeral", "cacheStorageClassName": "gp3"},
+					{"bucketName": bucketName, "cacheType": "emptyDir", "cacheStorageClassName": "gp3"},
+					{"bucketName": bucketName, "cacheDirSizeLimit": "1Gi"},
+					{"bucketName": bucketName, "cacheType": "emptyDir", "cacheDirSizeLimit": "lots"},
+					{"bucketName": bucketName, "cacheType": "emptyDir", "cacheDirSizeLimit": "1Ki"},
+					{"bucketName": bucketName, "cachePersistentVolumeClaim": "Mountpoint Cache"},
+					{"bucketName": bucketName, "cachePersistentVolumeClaim": "mountpoint-cache", "cacheType": "emptyDir"},
+					{"bucketName": bucketName, "metadataTTL": "-1"},
+					{"bucketName": bucketName, "metadataTTL": "1h"},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       filepath.Join(t.TempDir(), "mount"),
+						VolumeContext:    volumeCtx,
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "fail: unsupported directory bucket configuration",
+			testFunc: func(t *testing.T) {
+				for _, volumeCtx := range []map[string]string{
+					{"bucketName": bucketName, "bucketType": "express"},
+					{"bucketName": bucketName, "bucketType": "directory", "mountOptions": "force-path-style"},
+					{"bucketName": "test-bucket--usw2-az1--x-s3", "mountOptions": "transfer-acceleration"},
+					{"bucketName": "test-bucket--usw2-az1--x-s3", "mountOptions": "storage-class=STANDARD_IA"},
+				} {
+					nodeTestEnv := initNodeServerTestEnv(t)
+					ctx := context.Background()
+					req := &csi.NodePublishVolumeRequest{
+						VolumeId:         volumeId,
+						VolumeCapability: stdVolCap,
+						TargetPath:       targetPath,
+						VolumeContext:    volumeCtx,
+					}
+
+					_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+					assert.Equals(t, codes.InvalidArgument, status.Code(err))
+					nodeTestEnv.mockCtl.Finish()
+				}
+			},
+		},
+		{
+			name: "fail: unsupported log level",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeId:         volumeId,
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName, "logLevel": "verbose"},
+				}
+
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				assert.Equals(t, codes.InvalidArgument, status.Code(err))
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "fail: write options in read only mounts",
+			testFunc: func(t *testing.T) {
+				for name, req := range map[string]*csi.NodePublishVolumeRequest{
+					"reader only volume access type": {
+						VolumeId: volumeId,
+						VolumeCapability: &csi.VolumeCapability{
+							AccessType: &csi.VolumeCapability_Mount{
+								Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"allow-delete"}},
+							},
+							AccessMode: &csi.VolumeCapability_AccessMode{
+								Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+							},
+						},
+						TargetPath:    targetPath,
+						VolumeContext: map[string]string{"bucketName": bucketName},
+					},
+					"read only volume mount": {
+						VolumeId: volumeId,
+						VolumeCapability: &csi.VolumeCapability{
+							AccessType: &csi.VolumeCapability_Mount{
+								Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"allow-overwrite"}},
+							},
+							AccessMode: &csi.VolumeCapability_AccessMode{
+								Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+							},
+						},
+						TargetPath:    targetPath,
+						VolumeContext: map[string]string{"bucketName": bucketName},
+						Readonly:      true,
+					},
+				} {
+					t.Run(name, func(t *testing.T) {
+						nodeTestEnv := initNodeServerTestEnv(t)
+						_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+						assert.Equals(t, codes.InvalidArgument, status.Code(err))
+						nodeTestEnv.mockCtl.Finish()
+					})
+				}
+			},
+		},
+		{
+			name: "fail: missing volume id",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodePublishVolumeRequest{
+					VolumeCapability: stdVolCap,
+					TargetPath:       targetPath,
+					VolumeContext:    map[string]string{"bucketName": bucketName},
+				}
+
+				_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+				if err == nil {
+					t.Fatalf("NodePublishVolume is failed: %v", err)
+				}
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, tc.testFunc)
+	}
+}
+
+func TestNodeUnpublishVolume(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		targetPath = "/target/path"
+	)
+	testCases := []struct {
+		name     string
+		testFunc func(t *testing.T)
+	}{
+		{
+			name: "success: happy path",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   volumeId,
+					TargetPath: targetPath,
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+				nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil)
+				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume failed: %v", err)
+				}
+
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: not mounted",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   volumeId,
+					TargetPath: targetPath,
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, nil)
+				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume failed: %v", err)
+				}
+
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "failure: unmount failure is error",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   volumeId,
+					TargetPath: targetPath,
+				}
+
+				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+				nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(errors.New(""))
+				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				if err == nil {
+					t.Fatalf("NodePublishVolume must fail")
+				}
+
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+		{
+			name: "success: inexistent dir",
+			testFunc: func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				ctx := context.Background()
+				req := &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   volumeId,
+					TargetPath: targetPath,
+				}
+
+				expectedError := fs.ErrNotExist
+				nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, expectedError)
+				_, err := nodeTestEnv.server.NodeUnpublishVolume(ctx, req)
+				if err != nil {
+					t.Fatalf("NodePublishVolume failed: %v", err)
+				}
+
+				nodeTestEnv.mockCtl.Finish()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, tc.testFunc)
+	}
+
+	t.Run("Cleaning Service Account Token", func(t *testing.T) {
+		containerPluginDir := t.TempDir()
+		credentialProvider := mounter.NewCredentialProvider(nil, containerPluginDir, mounter.RegionFromIMDSOnce)
+		nodeServer := node.NewS3NodeServer("test-node-id", &dummyMounter{}, credentialProvider)
+
+		podID := uuid.New().String()
+		volID := "test-vol-id"
+
+		serviceAccountTokenPath := filepath.Join(containerPluginDir, fmt.Sprintf("%s-%s.token", podID, volID))
+		_, err := os.Create(serviceAccountTokenPath)
+		assert.Equals(t, nil, err)
+
+		targetPath := fmt.Sprintf("/var/lib/kubelet/pods/%s/volumes/kubernetes.io~csi/%s/mount", podID, volID)
+
+		_, err = nodeServer.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+			VolumeId:   volID,
+			TargetPath: targetPath,
+		})
+		assert.Equals(t, nil, err)
+
+		_, err = os.Stat(serviceAccountTokenPath)
+		assert.Equals(t, cmpopts.AnyError, err)
+		assert.Equals(t, true, errors.Is(err, fs.ErrNotExist))
+	})
+}
+
+func TestStagedVolumes(t *testing.T) {
+	var (
+		volumeId      = "test-volume-id"
+		bucketName    = "test-bucket-name"
+		stagingTarget = "/staging/path"
+		stdVolCap     = &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					MountFlags: []string{"--allow-delete"},
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		}
+	)
+
+	t.Run("Staging only validates the request", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+
+		_, err := nodeTestEnv.server.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+			VolumeId:          volumeId,
+			StagingTargetPath: stagingTarget,
+			VolumeCapability:  stdVolCap,
+		})
+		assert.NoError(t, err)
+
+		_, err = nodeTestEnv.server.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+			VolumeId:         volumeId,
+			VolumeCapability: stdVolCap,
+		})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("Pods share the Mountpoint process mounted at the staging target path", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+		expectedArgs := mountpoint.ParseArgs([]string{"--allow-delete"})
+
+		for i, readOnly := range []bool{false, true} {
+			target := fmt.Sprintf("/target/path-%d", i)
+			nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(stagingTarget), gomock.Any(), gomock.Eq(expectedArgs)).Return(nil)
+			nodeTestEnv.mockMounter.EXPECT().BindMount(gomock.Eq(stagingTarget), gomock.Eq(target), gomock.Eq(readOnly)).Return(nil)
+
+			_, err := nodeTestEnv.server.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				VolumeId:          volumeId,
+				VolumeCapability:  stdVolCap,
+				StagingTargetPath: stagingTarget,
+				TargetPath:        target,
+				Readonly:          readOnly,
+				VolumeContext:     map[string]string{"bucketName": bucketName},
+			})
+			assert.NoError(t, err)
+		}
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("Volumes with Pod-level credentials are mounted at the target path", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+		t.Setenv("AWS_REGION", "eu-west-1")
+
+		clientset := fake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-sa",
+			Namespace:   "test-ns",
+			Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test"},
+		}})
+		credentialProvider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		nodeTestEnv.server = node.NewS3NodeServer("test-nodeID", nodeTestEnv.mockMounter, credentialProvider)
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq("/target/path"), gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := nodeTestEnv.server.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeId:          volumeId,
+			VolumeCapability:  stdVolCap,
+			StagingTargetPath: stagingTarget,
+			TargetPath:        "/target/path",
+			VolumeContext: map[string]string{
+				"bucketName":                               bucketName,
+				"authenticationSource":                     "pod",
+				"csi.storage.k8s.io/pod.uid":               "test-pod-uid",
+				"csi.storage.k8s.io/pod.namespace":         "test-ns",
+				"csi.storage.k8s.io/serviceAccount.name":   "test-sa",
+				"csi.storage.k8s.io/serviceAccount.tokens": `{"sts.amazonaws.com":{"token":"test-token"}}`,
+			},
+		})
+		assert.NoError(t, err)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("Unstaging unmounts the staging target path", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+		req := &csi.NodeUnstageVolumeRequest{
+			VolumeId:          volumeId,
+			StagingTargetPath: stagingTarget,
+		}
+
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(stagingTarget)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(stagingTarget)).Return(nil)
+		_, err := nodeTestEnv.server.NodeUnstageVolume(ctx, req)
+		assert.NoError(t, err)
+
+		// Volume is never mounted at the staging target path if there was no `NodePublishVolume` call.
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(stagingTarget)).Return(false, fs.ErrNotExist)
+		_, err = nodeTestEnv.server.NodeUnstageVolume(ctx, req)
+		assert.NoError(t, err)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestInlineVolumes(t *testing.T) {
+	var (
+		volumeId   = "csi-8f4bdd7d1c4e0a7a"
+		bucketName = "test-bucket-name"
+		targetPath = "/target/path"
+	)
+
+	setup := func(t *testing.T) *nodeServerTestEnv {
+		t.Setenv("AWS_REGION", "eu-west-1")
+		nodeTestEnv := initNodeServerTestEnv(t)
+		clientset := fake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-sa",
+			Namespace:   "test-ns",
+			Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/Test"},
+		}})
+		credentialProvider := mounter.NewCredentialProvider(clientset.CoreV1(), t.TempDir(), mounter.RegionFromIMDSOnce)
+		nodeTestEnv.server = node.NewS3NodeServer("test-nodeID", nodeTestEnv.mockMounter, credentialProvider)
+		nodeTestEnv.server.AllowInlineVolumes = true
+		return nodeTestEnv
+	}
+	request := func(attributes map[string]string) *csi.NodePublishVolumeRequest {
+		volumeCtx := map[string]string{
+			"bucketName":                               bucketName,
+			"csi.storage.k8s.io/ephemeral":             "true",
+			"csi.storage.k8s.io/pod.uid":               "test-pod-uid",
+			"csi.storage.k8s.io/pod.namespace":         "test-ns",
+			"csi.storage.k8s.io/serviceAccount.name":   "test-sa",
+			"csi.storage.k8s.io/serviceAccount.tokens": `{"sts.amazonaws.com":{"token":"test-token"}}`,
+		}
+		maps.Copy(volumeCtx, attributes)
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+			TargetPath:    targetPath,
+			VolumeContext: volumeCtx,
+		}
+	}
+
+	t.Run("mounts with mount options from volume context and pod-level credentials", func(t *testing.T) {
+		nodeTestEnv := setup(t)
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--allow-delete", "--region=us-west-2"}))).
+			DoAndReturn(func(_, _ string, credentials *mounter.MountCredentials, _ mountpoint.Args) error {
+				assert.Equals(t, mounter.AuthenticationSourcePod, credentials.AuthenticationSource)
+				return nil
+			})
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{
+			"mountOptions": "allow-delete,region us-west-2",
+		}))
+		assert.NoError(t, err)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("rejected if not enabled", func(t *testing.T) {
+		nodeTestEnv := setup(t)
+		nodeTestEnv.server.AllowInlineVolumes = false
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(nil))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	for name, attributes := range map[string]map[string]string{
+		"role to assume":           {"stsRoleArn": "arn:aws:iam::123456789012:role/Admin"},
+		"vault role":               {"authenticationSource": "vault", "vaultRole": "admin"},
+		"custom endpoint":          {"endpointUrl": "https://attacker.example.com"},
+		"driver-level credentials": {"authenticationSource": "driver"},
+		"privileged mount option":  {"mountOptions": "allow-delete,cache /etc"},
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			nodeTestEnv := setup(t)
+
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(attributes))
+			assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+			nodeTestEnv.mockCtl.Finish()
+		})
+	}
+
+	t.Run("rejects secrets", func(t *testing.T) {
+		nodeTestEnv := setup(t)
+
+		req := request(nil)
+		req.Secrets = map[string]string{"key_id": "test-key-id", "access_key": "test-access-key"}
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestRepeatedPublishesForSamePod(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		firstPath  = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/first-pv/mount"
+		secondPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/second-pv/mount"
+	)
+
+	request := func(target, podUID string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: target,
+			VolumeContext: map[string]string{
+				"bucketName":                 bucketName,
+				"csi.storage.k8s.io/pod.uid": podUID,
+			},
+		}
+	}
+	unpublish := func(nodeTestEnv *nodeServerTestEnv, target string) {
+		t.Helper()
+		_, err := nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: target})
+		assert.NoError(t, err)
+	}
+
+	t.Run("Repeated publishes are bind mounted from the first one", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(firstPath), gomock.Any(), gomock.Any()).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(ctx, request(firstPath, "test-pod-uid"))
+		assert.NoError(t, err)
+
+		nodeTestEnv.mockMounter.EXPECT().BindMount(gomock.Eq(firstPath), gomock.Eq(secondPath), gomock.Eq(false)).Return(nil).Times(2)
+		_, err = nodeTestEnv.server.NodePublishVolume(ctx, request(secondPath, "test-pod-uid"))
+		assert.NoError(t, err)
+
+		// Republishing keeps the bind mount
+		_, err = nodeTestEnv.server.NodePublishVolume(ctx, request(secondPath, "test-pod-uid"))
+		assert.NoError(t, err)
+
+		// The first target path is kept mounted until the second one is unpublished
+		unpublish(nodeTestEnv, firstPath)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(secondPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(secondPath)).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(firstPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(firstPath)).Return(nil)
+		unpublish(nodeTestEnv, secondPath)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("Publishes for different Pods are mounted separately", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx := context.Background()
+		otherPath := "/var/lib/kubelet/pods/other-pod-uid/volumes/kubernetes.io~csi/first-pv/mount"
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(firstPath), gomock.Any(), gomock.Any()).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(otherPath), gomock.Any(), gomock.Any()).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(ctx, request(firstPath, "test-pod-uid"))
+		assert.NoError(t, err)
+		_, err = nodeTestEnv.server.NodePublishVolume(ctx, request(otherPath, "other-pod-uid"))
+		assert.NoError(t, err)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("Bind mounted target paths are re-mounted on their own", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(firstPath), gomock.Any(), gomock.Any()).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().BindMount(gomock.Eq(firstPath), gomock.Eq(secondPath), gomock.Eq(false)).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(ctx, request(firstPath, "test-pod-uid"))
+		assert.NoError(t, err)
+		_, err = nodeTestEnv.server.NodePublishVolume(ctx, request(secondPath, "test-pod-uid"))
+		assert.NoError(t, err)
+		unpublish(nodeTestEnv, firstPath)
+
+		// Mountpoint process got killed, the released first target path is unmounted once the second one is re-mounted
+		corruptedErr := &fs.PathError{Op: "stat", Path: secondPath, Err: syscall.ENOTCONN}
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(secondPath)).Return(false, corruptedErr)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(firstPath)).Return(false, corruptedErr).AnyTimes()
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(secondPath), gomock.Any(), gomock.Any()).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(firstPath)).DoAndReturn(func(string) error {
+			cancel()
+			return nil
+		})
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(secondPath)).Return(true, nil).AnyTimes()
+
+		nodeTestEnv.server.MonitorMounts(ctx, time.Millisecond)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestMonitorMounts(t *testing.T) {
+	var (
+		volumeId   = "monitored-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/target/path"
+	)
+
+	nodeTestEnv := initNodeServerTestEnv(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId: volumeId,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					MountFlags: []string{"--allow-delete"},
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			"bucketName":                       bucketName,
+			"csi.storage.k8s.io/pod.name":      "test-pod",
+			"csi.storage.k8s.io/pod.namespace": "test-ns",
+		},
+	}
+	expectedArgs := mountpoint.ParseArgs([]string{"--allow-delete"})
+
+	eventRecorder := record.NewFakeRecorder(10)
+	nodeTestEnv.server.EventRecorder = eventRecorder
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Eq(expectedArgs))
+	_, err := nodeTestEnv.server.NodePublishVolume(ctx, req)
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+	// Mountpoint process got killed, and the mount become corrupted
+	corruptedErr := &fs.PathError{Op: "stat", Path: targetPath, Err: syscall.ENOTCONN}
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, corruptedErr)
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Eq(expectedArgs)).
+		DoAndReturn(func(string, string, *mounter.MountCredentials, mountpoint.Args) error {
+			cancel()
+			return nil
+		})
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil).AnyTimes()
+
+	nodeTestEnv.server.MonitorMounts(ctx, time.Millisecond)
+
+	assertEvents(t, eventRecorder, "Warning "+node.EventReasonMountUnhealthy, "Normal "+node.EventReasonMountRecovered)
+	assert.Equals(t, map[string]float64{
+		"s3_csi_node_mount_healthy":          1,
+		"s3_csi_node_broken_mounts_total":    1,
+		"s3_csi_node_mount_recoveries_total": 1,
+	}, volumeMetrics(t, volumeId))
+
+	// Metrics of the volume are deleted once it's unpublished
+	nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil)
+	_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+	assert.NoError(t, err)
+	assert.Equals(t, map[string]float64{}, volumeMetrics(t, volumeId))
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+// volumeMetrics returns the sum of the values of each node metric of the volume with given `volumeID` by their names.
+// Metrics of volumes must not be labelled with the workload Pods using them.
+func volumeMetrics(t *testing.T, volumeID string) map[string]float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["volume_id"] != volumeID {
+				continue
+			}
+			if _, ok := labels["pod_uid"]; ok {
+				t.Fatalf("Expected %s not to be labelled with pod_uid", family.GetName())
+			}
+			values[family.GetName()] += metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+		}
+	}
+	return values
+}
+
+func TestPinnedMountpointVersion(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(pinnedVersion string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":        bucketName,
+				"mountpointImage":   "mp-image:" + pinnedVersion,
+				"mountpointVersion": pinnedVersion,
+			},
+		}
+	}
+
+	t.Run("same version", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.MountpointVersion = "1.10.0"
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("1.10.0"))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("different version", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.MountpointVersion = "1.12.0"
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("1.10.0"))
+		assert.Equals(t, codes.FailedPrecondition, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestMountLifecycleEvents(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(authenticationSource string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": "test-ns",
+				"authenticationSource":             authenticationSource,
+			},
+		}
+	}
+
+	t.Run("mounted and unmounted", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("driver"))
+		assert.NoError(t, err)
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath))
+		_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+		assert.NoError(t, err)
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonUnmounted)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("mount failed", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+			Return(errors.New("mount-s3 exited with 1"))
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("driver"))
+		assert.Equals(t, codes.Internal, status.Code(err))
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Warning "+node.EventReasonMountFailed)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("credentials failed", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("pod"))
+		if err == nil {
+			t.Fatal("Expected an error without service account tokens")
+		}
+		assertEvents(t, eventRecorder, "Warning "+node.EventReasonCredentialsFailed)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestCompositeVolume(t *testing.T) {
+	volumeId := "test-volume-id"
+	buckets := `[{"bucketName": "images", "path": "images"}, {"bucketName": "labels", "prefix": "2024/", "path": "labels"}]`
+
+	request := func(targetPath string, volumeCtx map[string]string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath:    targetPath,
+			VolumeContext: volumeCtx,
+		}
+	}
+
+	t.Run("mounts and unmounts each bucket", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+		targetPath := t.TempDir()
+		imagesTarget, labelsTarget := filepath.Join(targetPath, "images"), filepath.Join(targetPath, "labels")
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq("images"), gomock.Eq(imagesTarget), gomock.Any(), gomock.Eq(mountpoint.ParseArgs(nil))).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq("labels"), gomock.Eq(labelsTarget), gomock.Any(), gomock.Eq(mountpoint.ParseArgs([]string{"--prefix=2024/"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(targetPath, map[string]string{
+			"buckets":                          buckets,
+			"csi.storage.k8s.io/pod.name":      "test-pod",
+			"csi.storage.k8s.io/pod.namespace": "test-ns",
+		}))
+		assert.NoError(t, err)
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+		// The mocked mounter does not create the mount points
+		assert.NoError(t, os.Mkdir(imagesTarget, 0750))
+		assert.NoError(t, os.Mkdir(labelsTarget, 0750))
+
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, nil)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(imagesTarget)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(imagesTarget)).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(labelsTarget)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(labelsTarget)).Return(nil)
+		_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+		assert.NoError(t, err)
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonUnmounted)
+
+		entries, err := os.ReadDir(targetPath)
+		assert.NoError(t, err)
+		assert.Equals(t, 0, len(entries))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("unmounts mounted buckets if a bucket fails to mount", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		targetPath := t.TempDir()
+		imagesTarget, labelsTarget := filepath.Join(targetPath, "images"), filepath.Join(targetPath, "labels")
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq("images"), gomock.Eq(imagesTarget), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(string, string, any, mountpoint.Args) error { return os.Mkdir(imagesTarget, 0750) })
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq("labels"), gomock.Eq(labelsTarget), gomock.Any(), gomock.Any()).
+			Return(errors.New("mount-s3 exited with 1"))
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(imagesTarget)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(imagesTarget)).Return(nil)
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(targetPath, map[string]string{"buckets": buckets}))
+		assert.Equals(t, codes.Internal, status.Code(err))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("invalid configurations", func(t *testing.T) {
+		for name, volumeCtx := range map[string]map[string]string{
+			"bucketName and buckets": {"bucketName": "images", "buckets": buckets},
+			"prefix and buckets":     {"prefix": "2024/", "buckets": buckets},
+			"invalid JSON":           {"buckets": `{"bucketName": "images"}`},
+			"no buckets":             {"buckets": `[]`},
+			"missing bucket name":    {"buckets": `[{"path": "images"}]`},
+			"nested path":            {"buckets": `[{"bucketName": "images", "path": "data/images"}]`},
+			"parent path":            {"buckets": `[{"bucketName": "images", "path": ".."}]`},
+			"duplicate paths":        {"buckets": `[{"bucketName": "images", "path": "data"}, {"bucketName": "labels", "path": "data"}]`},
+			"prefix without slash":   {"buckets": `[{"bucketName": "images", "prefix": "2024", "path": "images"}]`},
+		} {
+			t.Run(name, func(t *testing.T) {
+				nodeTestEnv := initNodeServerTestEnv(t)
+				_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(t.TempDir(), volumeCtx))
+				assert.Equals(t, codes.InvalidArgument, status.Code(err))
+				nodeTestEnv.mockCtl.Finish()
+			})
+		}
+	})
+}
+
+func TestRespectPodFSGroup(t *testing.T) {
+	var (
+		volumeId      = "test-volume-id"
+		bucketName    = "test-bucket-name"
+		stagingTarget = "/var/lib/kubelet/plugins/kubernetes.io/csi/s3.csi.aws.com/volume-hash/globalmount"
+		targetPath    = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(fsGroup string, mountFlags []string, respectPodFSGroup string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{
+					MountFlags:       mountFlags,
+					VolumeMountGroup: fsGroup,
+				}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			StagingTargetPath: stagingTarget,
+			TargetPath:        targetPath,
+			VolumeContext:     map[string]string{"bucketName": bucketName, "respectPodFSGroup": respectPodFSGroup},
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		req          *csi.NodePublishVolumeRequest
+		expectedArgs []string
+	}{
+		{
+			name:         "sets gid and allow-other without staging",
+			req:          request("2000", nil, "true"),
+			expectedArgs: []string{"--allow-other", "--gid=2000"},
+		},
+		{
+			name:         "keeps allow-root",
+			req:          request("2000", []string{"allow-root", "gid=2000"}, "true"),
+			expectedArgs: []string{"--allow-root", "--gid=2000"},
+		},
+		{
+			name:         "no fsGroup",
+			req:          request("", nil, "true"),
+			expectedArgs: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeTestEnv := initNodeServerTestEnv(t)
+			nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Eq(mountpoint.ParseArgs(tc.expectedArgs))).Return(nil)
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), tc.req)
+			assert.NoError(t, err)
+			nodeTestEnv.mockCtl.Finish()
+		})
+	}
+
+	t.Run("not opted in", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(stagingTarget), gomock.Any(), gomock.Eq(mountpoint.ParseArgs(nil))).Return(nil)
+		nodeTestEnv.mockMounter.EXPECT().BindMount(gomock.Eq(stagingTarget), gomock.Eq(targetPath), gomock.Eq(false)).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("2000", nil, ""))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("conflicting gid", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("2000", []string{"gid=1000"}, "true"))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestPrefixVariables(t *testing.T) {
+	var (
+		volumeId      = "test-volume-id"
+		bucketName    = "test-bucket-name"
+		stagingTarget = "/var/lib/kubelet/plugins/kubernetes.io/csi/s3.csi.aws.com/volume-hash/globalmount"
+		targetPath    = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(prefix string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			StagingTargetPath: stagingTarget,
+			TargetPath:        targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"prefix":                           prefix,
+				"csi.storage.k8s.io/pod.name":      "training-0",
+				"csi.storage.k8s.io/pod.namespace": "ml",
+				"csi.storage.k8s.io/pod.uid":       "test-pod-uid",
+			},
+		}
+	}
+
+	t.Run("expands variables without staging", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--prefix=jobs/ml/training-0/test-pod-uid/"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("jobs/$(POD_NAMESPACE)/$(POD_NAME)/$(POD_UID)/"))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("unknown variable", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("jobs/$(NODE_NAME)/"))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("missing Pod information", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("jobs/$(SERVICE_ACCOUNT_NAME)/"))
+		assert.Equals(t, codes.FailedPrecondition, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestMountRetries(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(volumeCtx map[string]string) *csi.NodePublishVolumeRequest {
+		volumeCtx["bucketName"] = bucketName
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath:    targetPath,
+			VolumeContext: volumeCtx,
+		}
+	}
+
+	t.Run("no retries by default", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+			Return(errors.New("mount-s3 timed out")).Times(1)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{}))
+		assert.Equals(t, codes.Internal, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("retries with driver-level policy", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.MountRetryPolicy = node.MountRetryPolicy{Retries: 1, Backoff: time.Millisecond}
+		gomock.InOrder(
+			nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+				Return(errors.New("mount-s3 timed out")),
+			nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+				Return(nil),
+		)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{}))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("retries with volume-level policy", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+			Return(errors.New("mount-s3 timed out")).Times(3)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{
+			"mountRetries":      "2",
+			"mountRetryBackoff": "1ms",
+		}))
+		assert.Equals(t, codes.Internal, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("invalid volume-level policy", func(t *testing.T) {
+		for _, volumeCtx := range []map[string]string{
+			{"mountRetries": "-1"},
+			{"mountRetries": "many"},
+			{"mountRetryBackoff": "5"},
+			{"mountRetryBackoff": "0s"},
+		} {
+			nodeTestEnv := initNodeServerTestEnv(t)
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(volumeCtx))
+			assert.Equals(t, codes.InvalidArgument, status.Code(err))
+			nodeTestEnv.mockCtl.Finish()
+		}
+	})
+}
+
+func TestUnmountGracePeriod(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+		busyErr    = errors.New("Unmount failed: exit status 32 unmount output: umount: /target: target is busy.")
+	)
+	request := &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath}
+
+	t.Run("busy mounts fail immediately by default", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(busyErr).Times(1)
+		_, err := nodeTestEnv.server.NodeUnpublishVolume(context.Background(), request)
+		assert.Equals(t, codes.Internal, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("busy mounts are retried within grace period", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.UnmountGracePeriod = time.Minute
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		gomock.InOrder(
+			nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(fmt.Errorf("Unmount failed: %w", syscall.EBUSY)),
+			nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil),
+		)
+		_, err := nodeTestEnv.server.NodeUnpublishVolume(context.Background(), request)
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("busy mounts fail once grace period elapses", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.UnmountGracePeriod = 50 * time.Millisecond
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(busyErr).MinTimes(2)
+		_, err := nodeTestEnv.server.NodeUnpublishVolume(context.Background(), request)
+		assert.Equals(t, codes.Internal, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.UnmountGracePeriod = time.Minute
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(errors.New("permission denied")).Times(1)
+		_, err := nodeTestEnv.server.NodeUnpublishVolume(context.Background(), request)
+		assert.Equals(t, codes.Internal, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("invalid volume-level grace period", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+			},
+			TargetPath:    targetPath,
+			VolumeContext: map[string]string{"bucketName": "test-bucket-name", "unmountGracePeriod": "-1s"},
+		})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+// hungMounter is a [mounter.ForceUnmounter] whose `Unmount` or `IsMountPoint` hang until it's forcefully unmounted.
+type hungMounter struct {
+	dummyMounter
+	hangIsMountPoint bool
+	aborted          chan struct{}
+	forced           []string
+}
+
+func (m *hungMounter) Unmount(target string) error {
+	<-m.aborted
+	return nil
+}
+
+func (m *hungMounter) IsMountPoint(target string) (bool, error) {
+	if m.hangIsMountPoint {
+		<-m.aborted
+	}
+	return true, nil
+}
+
+func (m *hungMounter) ForceUnmount(target string) error {
+	m.forced = append(m.forced, target)
+	close(m.aborted)
+	return nil
+}
+
+func TestForceUnmount(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	for name, hangIsMountPoint := range map[string]bool{"hung unmount": false, "hung stat": true} {
+		t.Run(name, func(t *testing.T) {
+			m := &hungMounter{hangIsMountPoint: hangIsMountPoint, aborted: make(chan struct{})}
+			credentialProvider := mounter.NewCredentialProvider(nil, t.TempDir(), mounter.RegionFromIMDSOnce)
+			server := node.NewS3NodeServer("test-nodeID", m, credentialProvider)
+			server.ForceUnmountTimeout = 10 * time.Millisecond
+
+			_, err := server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+			assert.NoError(t, err)
+			assert.Equals(t, []string{targetPath}, m.forced)
+		})
+	}
+}
+
+func TestVolumeAttributesClass(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "test-claim"},
+			}}}},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-claim", Namespace: "test-ns"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "test-pv"},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+			Spec: corev1.PersistentVolumeSpec{
+				VolumeAttributesClassName: ptr.To("short-ttl"),
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com", VolumeHandle: volumeId},
+				},
+			},
+		},
+		&storagev1beta1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "short-ttl"},
+			DriverName: "s3.csi.aws.com",
+			Parameters: map[string]string{"metadataTTL": "5", "bucketName": "ignored"},
+		},
+	)
+
+	nodeTestEnv := initNodeServerTestEnv(t)
+	nodeTestEnv.server.VolumeAttributesClasses = node.NewVolumeAttributesClassResolver(clientset)
+	nodeTestEnv.server.ApplyVolumeAttributesClasses = true
+
+	publish := func() error {
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"metadataTTL":                      "indefinite",
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": "test-ns",
+			},
+		})
+		return err
+	}
+
+	// Mutable parameters of the class override volume attributes of the PersistentVolume
+	expectedArgs := mountpoint.ParseArgs([]string{"--metadata-ttl=5"})
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Eq(expectedArgs)).Return(nil)
+	assert.NoError(t, publish())
+
+	// Republishes keep the parameters the volume is mounted with, even if the class of the volume is changed
+	pv, err := clientset.CoreV1().PersistentVolumes().Get(context.Background(), "test-pv", metav1.GetOptions{})
+	assert.NoError(t, err)
+	pv.Spec.VolumeAttributesClassName = ptr.To("missing-class")
+	_, err = clientset.CoreV1().PersistentVolumes().Update(context.Background(), pv, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Eq(expectedArgs)).Return(nil)
+	assert.NoError(t, publish())
+
+	// New mounts fail until the class of the volume exists
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+	nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil)
+	_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+	assert.NoError(t, err)
+	assert.Equals(t, codes.Unavailable, status.Code(publish()))
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+// assertEvents asserts that `recorder` has recorded events with given prefixes in order, and nothing else.
+func assertEvents(t *testing.T, recorder *record.FakeRecorder, prefixes ...string) {
+	t.Helper()
+	assert.Equals(t, len(prefixes), len(recorder.Events))
+	for _, prefix := range prefixes {
+		if event := <-recorder.Events; !strings.HasPrefix(event, prefix) {
+			t.Fatalf("Expected %s event, got %q", prefix, event)
+		}
+	}
+}
+
+func TestMountpointLimit(t *testing.T) {
+	var (
+		bucketName  = "test-bucket-name"
+		firstTarget = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/first-volume/mount"
+		otherTarget = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/other-volume/mount"
+	)
+
+	request := func(volumeID, target string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeID,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: target,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": "test-ns",
+			},
+		}
+	}
+
+	nodeTestEnv := initNodeServerTestEnv(t)
+	ctx := context.Background()
+	eventRecorder := record.NewFakeRecorder(10)
+	nodeTestEnv.server.EventRecorder = eventRecorder
+	nodeTestEnv.server.MaxMountpoints = 1
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(firstTarget), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	_, err := nodeTestEnv.server.NodePublishVolume(ctx, request("first-volume", firstTarget))
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+	// Republishing doesn't spawn another Mountpoint process
+	_, err = nodeTestEnv.server.NodePublishVolume(ctx, request("first-volume", firstTarget))
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+	_, err = nodeTestEnv.server.NodePublishVolume(ctx, request("other-volume", otherTarget))
+	assert.Equals(t, codes.ResourceExhausted, status.Code(err))
+	assertEvents(t, eventRecorder, "Warning "+node.EventReasonMountpointLimitReached)
+
+	// The other volume is mounted once the first one is unmounted
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(firstTarget)).Return(true, nil)
+	nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(firstTarget)).Return(nil)
+	_, err = nodeTestEnv.server.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{VolumeId: "first-volume", TargetPath: firstTarget})
+	assert.NoError(t, err)
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(otherTarget), gomock.Any(), gomock.Any()).Return(nil)
+	_, err = nodeTestEnv.server.NodePublishVolume(ctx, request("other-volume", otherTarget))
+	assert.NoError(t, err)
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+func TestConcurrentPublishes(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(volumeCtx map[string]string) *csi.NodePublishVolumeRequest {
+		volumeCtx["bucketName"] = bucketName
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath:    targetPath,
+			VolumeContext: volumeCtx,
+		}
+	}
+
+	nodeTestEnv := initNodeServerTestEnv(t)
+	mounting, mounted := make(chan struct{}), make(chan struct{})
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(string, string, *mounter.MountCredentials, mountpoint.Args) error {
+			close(mounting)
+			<-mounted
+			return nil
+		}).Times(1)
+
+	errs := make(chan error)
+	publish := func() {
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{}))
+		errs <- err
+	}
+	go publish()
+	<-mounting
+
+	// A different request for the same target is aborted while the mount is in progress
+	_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(map[string]string{"cache": "emptyDir"}))
+	assert.Equals(t, codes.Aborted, status.Code(err))
+
+	// A retry of the same request that gives up waiting is aborted too
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = nodeTestEnv.server.NodePublishVolume(ctx, request(map[string]string{}))
+	assert.Equals(t, codes.Aborted, status.Code(err))
+
+	// A retry of the same request waits for the mount in progress instead of mounting again
+	go publish()
+	time.Sleep(100 * time.Millisecond)
+
+	close(mounted)
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+	nodeTestEnv.mockCtl.Finish()
+}
+
+func TestRestoreState(t *testing.T) {
+	var (
+		volumeId      = "test-volume-id"
+		bucketName    = "test-bucket-name"
+		targetPath    = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+		staleTarget   = "/var/lib/kubelet/pods/test-pod-uid-2/volumes/kubernetes.io~csi/test-volume-id/mount"
+		tokens        = `{"sts.amazonaws.com":{"token":"test-token"}}`
+		stateFile     = filepath.Join(t.TempDir(), "node-state.json")
+		eventRecorder = record.NewFakeRecorder(10)
+	)
+
+	request := func(target string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: target,
+			VolumeContext: map[string]string{
+				"bucketName":                               bucketName,
+				"csi.storage.k8s.io/pod.name":              "test-pod",
+				"csi.storage.k8s.io/pod.namespace":         "test-ns",
+				"csi.storage.k8s.io/serviceAccount.tokens": tokens,
+			},
+		}
+	}
+
+	// Publish volumes before the restart
+	nodeTestEnv := initNodeServerTestEnv(t)
+	assert.NoError(t, nodeTestEnv.server.RestoreState(stateFile))
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+	for _, target := range []string{targetPath, staleTarget} {
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(target))
+		assert.NoError(t, err)
+	}
+	nodeTestEnv.mockCtl.Finish()
+
+	state, err := os.ReadFile(stateFile)
+	assert.NoError(t, err)
+	if strings.Contains(string(state), "test-token") {
+		t.Fatalf("Expected service account tokens not to be persisted, got %s", state)
+	}
+
+	// Restore volumes after the restart, the stale one was unmounted while the node plugin was not running
+	nodeTestEnv = initNodeServerTestEnv(t)
+	nodeTestEnv.server.EventRecorder = eventRecorder
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(staleTarget)).Return(false, os.ErrNotExist)
+	assert.NoError(t, nodeTestEnv.server.RestoreState(stateFile))
+
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+	nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath))
+	_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder, "Normal "+node.EventReasonUnmounted)
+
+	nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(staleTarget)).Return(false, os.ErrNotExist)
+	_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: staleTarget})
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder)
+	nodeTestEnv.mockCtl.Finish()
+
+	// Unpublished volumes are not restored again
+	nodeTestEnv = initNodeServerTestEnv(t)
+	assert.NoError(t, nodeTestEnv.server.RestoreState(stateFile))
+	nodeTestEnv.mockCtl.Finish()
+
+	t.Run("does not persist secrets", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "node-state.json")
+
+		nodeTestEnv := initNodeServerTestEnv(t)
+		assert.NoError(t, nodeTestEnv.server.RestoreState(stateFile))
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+		req := request(targetPath)
+		req.Secrets = map[string]string{"key_id": "test-access-key", "access_key": "test-secret-key"}
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+
+		state, err := os.ReadFile(stateFile)
+		assert.NoError(t, err)
+		for _, secret := range req.Secrets {
+			if strings.Contains(string(state), secret) {
+				t.Fatalf("Expected secrets not to be persisted, got %s", state)
+			}
+		}
+
+		// The restored volume is only re-mounted with its secrets once kubelet republishes it
+		nodeTestEnv = initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		assert.NoError(t, nodeTestEnv.server.RestoreState(stateFile))
+		ctx, cancel := context.WithCancel(context.Background())
+		corruptedErr := &fs.PathError{Op: "stat", Path: targetPath, Err: syscall.ENOTCONN}
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).
+			DoAndReturn(func(string) (bool, error) {
+				cancel()
+				return false, corruptedErr
+			}).AnyTimes()
+		nodeTestEnv.server.MonitorMounts(ctx, time.Millisecond)
+		nodeTestEnv.mockCtl.Finish()
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(bucket, target string, credentials *mounter.MountCredentials, args mountpoint.Args) error {
+				assert.Equals(t, "test-access-key", credentials.AccessKeyID)
+				return nil
+			})
+		_, err = nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("corrupted state file", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "node-state.json")
+		assert.NoError(t, os.WriteFile(stateFile, []byte("{"), 0600))
+
+		nodeTestEnv := initNodeServerTestEnv(t)
+		if err := nodeTestEnv.server.RestoreState(stateFile); err == nil {
+			t.Fatal("Expected an error for a corrupted state file")
+		}
+
+		// The state file is still replaced with the published volumes
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(targetPath))
+		assert.NoError(t, err)
+		state, err := os.ReadFile(stateFile)
+		assert.NoError(t, err)
+		if !strings.Contains(string(state), targetPath) {
+			t.Fatalf("Expected %s to be persisted, got %s", targetPath, state)
+		}
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestPurgingCachesOnDiskPressure(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = filepath.Join(t.TempDir(), "mount")
+		cacheBlock = filepath.Join(mounter.CacheDir(targetPath), "v1", "block")
+	)
+
+	nodeTestEnv := initNodeServerTestEnv(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventRecorder := record.NewFakeRecorder(10)
+	nodeTestEnv.server.EventRecorder = eventRecorder
+	nodeTestEnv.server.Nodes = fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeTestEnv.server.NodeID},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+		}},
+	}).CoreV1().Nodes()
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).Return(nil)
+	_, err := nodeTestEnv.server.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId: volumeId,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			"bucketName":                       bucketName,
+			"cacheType":                        "emptyDir",
+			"csi.storage.k8s.io/pod.name":      "test-pod",
+			"csi.storage.k8s.io/pod.namespace": "test-ns",
+		},
+	})
+	assert.NoError(t, err)
+	assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Normal "+node.EventReasonMounted)
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(cacheBlock), 0700))
+	assert.NoError(t, os.WriteFile(cacheBlock, make([]byte, 1024), 0600))
+
+	go nodeTestEnv.server.WatchDiskPressure(ctx, time.Hour)
+
+	select {
+	case event := <-eventRecorder.Events:
+		assert.Equals(t, "Warning "+node.EventReasonCacheReclaimed+" Purged 1Ki of local cache of volume test-volume-id as node test-nodeID is under disk pressure", event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the cache to be purged")
+	}
+	_, err = os.Stat(cacheBlock)
+	assert.Equals(t, true, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Dir(cacheBlock))
+	assert.NoError(t, err)
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+func TestSharedCacheBucket(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(cacheBucket string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":         bucketName,
+				"cacheExpressBucket": cacheBucket,
+			},
+		}
+	}
+
+	t.Run("cache bucket in the same zone", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.ZoneID = "use1-az4"
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--cache-xz=cache--use1-az4--x-s3"})))
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("cache--use1-az4--x-s3"))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--cache-xz=cache--usw2-az1--x-s3"})))
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("cache--usw2-az1--x-s3"))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("cache bucket in another zone", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.ZoneID = "use1-az4"
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("cache--use1-az6--x-s3"))
+		assert.Equals(t, codes.FailedPrecondition, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("not a directory bucket", func(t *testing.T) {
+		for _, cacheBucket := range []string{"cache", "--use1-az4--x-s3", "cache--x-s3", "cache--use1--az4--x-s3"} {
+			nodeTestEnv := initNodeServerTestEnv(t)
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(cacheBucket))
+			assert.Equals(t, codes.InvalidArgument, status.Code(err))
+			nodeTestEnv.mockCtl.Finish()
+		}
+	})
+}
+
+func TestMountOptionsPolicy(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/target/path"
+	)
+
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(policyFile, []byte(`
+default:
+  deny: ["allow-other"]
+namespaces:
+  prod:
+    deny: ["allow-delete"]
+    action: reject
+  restricted:
+    deny: ["prefix", "endpoint-url"]
+    action: reject
+  sandbox:
+    allow: ["prefix"]
+`), 0644)
+	assert.NoError(t, err)
+
+	request := func(namespace string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{
+						MountFlags: []string{"--allow-delete", "--allow-other"},
+					},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": namespace,
+			},
+		}
+	}
+
+	t.Run("strips denied options", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+		nodeTestEnv.server.MountOptionsPolicyFile = policyFile
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--allow-delete"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("default"))
+		assert.NoError(t, err)
+
+		if event := <-eventRecorder.Events; !strings.HasPrefix(event, "Warning "+node.EventReasonMountOptionsStripped) {
+			t.Fatalf("Expected a %s event, got %s", node.EventReasonMountOptionsStripped, event)
+		}
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("rejects denied options", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+		nodeTestEnv.server.MountOptionsPolicyFile = policyFile
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request("prod"))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		if event := <-eventRecorder.Events; !strings.HasPrefix(event, "Warning "+node.EventReasonMountOptionsRejected) {
+			t.Fatalf("Expected a %s event, got %s", node.EventReasonMountOptionsRejected, event)
+		}
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("applies to options from volume attributes", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+		nodeTestEnv.server.MountOptionsPolicyFile = policyFile
+
+		req := request("restricted")
+		req.VolumeCapability.GetMount().MountFlags = nil
+		req.VolumeContext["prefix"] = "team-a/"
+		req.VolumeContext["endpointUrl"] = "https://s3.example.com"
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		if event := <-eventRecorder.Events; !strings.HasPrefix(event, "Warning "+node.EventReasonMountOptionsRejected) {
+			t.Fatalf("Expected a %s event, got %s", node.EventReasonMountOptionsRejected, event)
+		}
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	// `fsGroup` of the workload Pod sets `--allow-other`, and the environment of the node plugin sets `--fips`.
+	driverArgsRequest := func(namespace string) *csi.NodePublishVolumeRequest {
+		req := request(namespace)
+		req.VolumeCapability.GetMount().VolumeMountGroup = "2000"
+		req.VolumeContext["respectPodFSGroup"] = "true"
+		return req
+	}
+
+	t.Run("does not deny options set by the driver", func(t *testing.T) {
+		t.Setenv("AWS_USE_FIPS_ENDPOINT", "true")
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.EventRecorder = record.NewFakeRecorder(10)
+		nodeTestEnv.server.MountOptionsPolicyFile = policyFile
+
+		// `--allow-other` passed via mount options is stripped, but it's still set for `fsGroup`.
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--allow-delete", "--allow-other", "--gid=2000", "--fips"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), driverArgsRequest("default"))
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("does not strip options set by the driver with an allowlist", func(t *testing.T) {
+		t.Setenv("AWS_USE_FIPS_ENDPOINT", "true")
+		nodeTestEnv := initNodeServerTestEnv(t)
+		nodeTestEnv.server.EventRecorder = record.NewFakeRecorder(10)
+		nodeTestEnv.server.MountOptionsPolicyFile = policyFile
+
+		req := driverArgsRequest("sandbox")
+		req.VolumeContext["prefix"] = "team-a/"
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--prefix=team-a/", "--allow-other", "--gid=2000", "--fips"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.NoError(t, err)
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestSELinuxContext(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+
+	request := func(mountFlags ...string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{MountFlags: mountFlags},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+				},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": "default",
+			},
+		}
+	}
+
+	t.Run("removes the context from Mountpoint arguments", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(),
+			gomock.Eq(mountpoint.ParseArgs([]string{"--allow-other"}))).Return(nil)
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(),
+			request("--allow-other", `context="system_u:object_r:container_file_t:s0:c1,c2"`))
+		assert.NoError(t, err)
+
+		if event := <-eventRecorder.Events; !strings.HasPrefix(event, "Warning "+node.EventReasonSELinuxContextNotApplied) {
+			t.Fatalf("Expected a %s event, got %s", node.EventReasonSELinuxContextNotApplied, event)
+		}
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("rejects invalid context", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(`context="container_file_t"`))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestNodeGetCapabilities(t *testing.T) {
+	nodeTestEnv := initNodeServerTestEnv(t)
+	ctx := context.Background()
+	req := &csi.NodeGetCapabilitiesRequest{}
+
+	resp, err := nodeTestEnv.server.NodeGetCapabilities(ctx, req)
+	if err != nil {
+		t.Fatalf("NodeGetCapabilities failed: %v", err)
+	}
+
+	capabilities := resp.GetCapabilities()
+	if len(capabilities) != 3 ||
+		capabilities[0].GetRpc().GetType() != csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME ||
+		capabilities[1].GetRpc().GetType() != csi.NodeServiceCapability_RPC_GET_VOLUME_STATS ||
+		capabilities[2].GetRpc().GetType() != csi.NodeServiceCapability_RPC_VOLUME_CONDITION {
+		t.Fatalf("NodeGetCapabilities failed: unexpected capabilities %v", capabilities)
+	}
+
+	nodeTestEnv.server.VolumeMountGroup = true
+	resp, err = nodeTestEnv.server.NodeGetCapabilities(ctx, req)
+	if err != nil {
+		t.Fatalf("NodeGetCapabilities failed: %v", err)
+	}
+	capabilities = resp.GetCapabilities()
+	if len(capabilities) != 4 || capabilities[3].GetRpc().GetType() != csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP {
+		t.Fatalf("NodeGetCapabilities failed: unexpected capabilities with volume mount group %v", capabilities)
+	}
+
+	nodeTestEnv.server.VolumeCondition = false
+	resp, err = nodeTestEnv.server.NodeGetCapabilities(ctx, req)
+	if err != nil {
+		t.Fatalf("NodeGetCapabilities failed: %v", err)
+	}
+	for _, capability := range resp.GetCapabilities() {
+		if capability.GetRpc().GetType() == csi.NodeServiceCapability_RPC_VOLUME_CONDITION {
+			t.Fatalf("NodeGetCapabilities failed: unexpected volume condition capability %v", resp.GetCapabilities())
+		}
+	}
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+	)
+	nodeTestEnv := initNodeServerTestEnv(t)
+	ctx := context.Background()
+	targetPath := t.TempDir()
+
+	nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+	_, err := nodeTestEnv.server.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId: volumeId,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+		},
+		TargetPath:    targetPath,
+		VolumeContext: map[string]string{"bucketName": bucketName},
+	})
+	assert.NoError(t, err)
+
+	getVolumeStats := func(volumeID, volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+		return nodeTestEnv.server.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: volumeID, VolumePath: volumePath})
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		resp, err := getVolumeStats(volumeId, targetPath)
+		assert.NoError(t, err)
+		assert.Equals(t, false, resp.GetVolumeCondition().GetAbnormal())
+		assert.Equals(t, 2, len(resp.GetUsage()))
+		assert.Equals(t, csi.VolumeUsage_BYTES, resp.GetUsage()[0].GetUnit())
+		assert.Equals(t, csi.VolumeUsage_INODES, resp.GetUsage()[1].GetUnit())
+	})
+
+	t.Run("Mountpoint is not running", func(t *testing.T) {
+		corruptedErr := &fs.PathError{Op: "stat", Path: targetPath, Err: syscall.ENOTCONN}
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, corruptedErr)
+		resp, err := getVolumeStats(volumeId, targetPath)
+		assert.NoError(t, err)
+		assert.Equals(t, true, resp.GetVolumeCondition().GetAbnormal())
+		if !strings.Contains(resp.GetVolumeCondition().GetMessage(), "not running anymore") {
+			t.Fatalf("Unexpected volume condition message %q", resp.GetVolumeCondition().GetMessage())
+		}
+		assert.Equals(t, 1, len(resp.GetUsage()))
+	})
+
+	t.Run("not mounted", func(t *testing.T) {
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(false, nil)
+		resp, err := getVolumeStats(volumeId, targetPath)
+		assert.NoError(t, err)
+		assert.Equals(t, true, resp.GetVolumeCondition().GetAbnormal())
+	})
+
+	t.Run("without volume condition capability", func(t *testing.T) {
+		nodeTestEnv.server.VolumeCondition = false
+		defer func() { nodeTestEnv.server.VolumeCondition = true }()
+		nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+		resp, err := getVolumeStats(volumeId, targetPath)
+		assert.NoError(t, err)
+		if resp.GetVolumeCondition() != nil {
+			t.Fatalf("Expected no volume condition, got %v", resp.GetVolumeCondition())
+		}
+		assert.Equals(t, 2, len(resp.GetUsage()))
+	})
+
+	t.Run("not published", func(t *testing.T) {
+		_, err := getVolumeStats(volumeId, "/var/lib/kubelet/pods/unknown-pod/volumes/kubernetes.io~csi/test-volume-id/mount")
+		assert.Equals(t, codes.NotFound, status.Code(err))
+		_, err = getVolumeStats("another-volume-id", targetPath)
+		assert.Equals(t, codes.NotFound, status.Code(err))
+		_, err = getVolumeStats(volumeId, "")
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+func TestNodeGetInfo(t *testing.T) {
+	nodeTestEnv := initNodeServerTestEnv(t)
+
+	resp, err := nodeTestEnv.server.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+	assert.NoError(t, err)
+	assert.Equals(t, "test-nodeID", resp.GetNodeId())
+	if resp.GetAccessibleTopology() != nil {
+		t.Fatalf("Expected no topology if the zone is not known, got %v", resp.GetAccessibleTopology())
+	}
+
+	nodeTestEnv.server.ZoneID = "use1-az4"
+	resp, err = nodeTestEnv.server.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+	assert.NoError(t, err)
+	assert.Equals(t, map[string]string{"topology.k8s.aws/zone-id": "use1-az4"}, resp.GetAccessibleTopology().GetSegments())
+
+	nodeTestEnv.mockCtl.Finish()
+}
+
+var _ mounter.Mounter = &dummyMounter{}
+
+type dummyMounter struct {
+}
+
+func (d *dummyMounter) Mount(bucketName string, target string, credentials *mounter.MountCredentials, args mountpoint.Args) error {
+	return nil
+}
+func (d *dummyMounter) Unmount(target string) error {
 	return nil
 }
 func (d *dummyMounter) IsMountPoint(target string) (bool, error) {
 	return true, nil
 }
+func (d *dummyMounter) BindMount(source string, target string, readOnly bool) error {
+	return nil
+}
+
+func TestMountedEventsDescribeAccess(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::111122223333:role/test-role")
+
+	request := func(mode csi.VolumeCapability_AccessMode_Mode) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                             bucketName,
+				"csi.storage.k8s.io/pod.name":            "test-pod",
+				"csi.storage.k8s.io/pod.namespace":       "test-ns",
+				"csi.storage.k8s.io/serviceAccount.name": "test-sa",
+			},
+		}
+	}
+
+	for name, test := range map[string]struct {
+		mode          csi.VolumeCapability_AccessMode_Mode
+		expectedEvent string
+	}{
+		"read-write": {
+			mode:          csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			expectedEvent: "Normal MountpointMounted Mounted bucket test-bucket-name for volume test-volume-id with read-write access as service account test-ns/test-sa with IAM role arn:aws:iam::111122223333:role/test-role",
+		},
+		"read-only": {
+			mode:          csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			expectedEvent: "Normal MountpointMounted Mounted bucket test-bucket-name for volume test-volume-id with read-only access as service account test-ns/test-sa with IAM role arn:aws:iam::111122223333:role/test-role",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			nodeTestEnv := initNodeServerTestEnv(t)
+			eventRecorder := record.NewFakeRecorder(10)
+			nodeTestEnv.server.EventRecorder = eventRecorder
+
+			nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(test.mode))
+			assert.NoError(t, err)
+
+			assert.Equals(t, 2, len(eventRecorder.Events))
+			<-eventRecorder.Events
+			assert.Equals(t, test.expectedEvent, <-eventRecorder.Events)
+
+			nodeTestEnv.mockMounter.EXPECT().IsMountPoint(gomock.Eq(targetPath)).Return(true, nil)
+			nodeTestEnv.mockMounter.EXPECT().Unmount(gomock.Eq(targetPath)).Return(nil)
+			_, err = nodeTestEnv.server.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: volumeId, TargetPath: targetPath})
+			assert.NoError(t, err)
+			assertEvents(t, eventRecorder, "Normal "+node.EventReasonUnmounted)
+
+			nodeTestEnv.mockCtl.Finish()
+		})
+	}
+}
+
+type fakeBucketChecker struct {
+	inputs []bucketcheck.Input
+	err    error
+}
+
+func (c *fakeBucketChecker) CheckBucket(ctx context.Context, input bucketcheck.Input) error {
+	c.inputs = append(c.inputs, input)
+	return c.err
+}
+
+func TestBucketPreflightCheck(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+		targetPath = "/var/lib/kubelet/pods/test-pod-uid/volumes/kubernetes.io~csi/test-volume-id/mount"
+	)
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::111122223333:role/test-role")
+
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId: volumeId,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			"bucketName":                       bucketName,
+			"prefix":                           "team-a/",
+			"csi.storage.k8s.io/pod.name":      "test-pod",
+			"csi.storage.k8s.io/pod.namespace": "test-ns",
+		},
+	}
+
+	t.Run("fails without mounting if the bucket is not accessible", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+		checker := &fakeBucketChecker{err: status.Error(codes.PermissionDenied, "Access denied to bucket")}
+		nodeTestEnv.server.BucketChecker = checker
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+		assert.Equals(t, codes.PermissionDenied, status.Code(err))
+		assertEvents(t, eventRecorder, "Normal "+node.EventReasonCredentialsResolved, "Warning "+node.EventReasonMountFailed)
+
+		assert.Equals(t, 1, len(checker.inputs))
+		input := checker.inputs[0]
+		assert.Equals(t, bucketName, input.Bucket)
+		assert.Equals(t, "team-a/", input.Prefix)
+		assert.Equals(t, "eu-west-1", input.Region)
+		assert.Equals(t, "role arn:aws:iam::111122223333:role/test-role", input.Identity)
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("checks the bucket only on the first publish", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		checker := &fakeBucketChecker{}
+		nodeTestEnv.server.BucketChecker = checker
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).Times(2)
+		for range 2 {
+			_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), req)
+			assert.NoError(t, err)
+		}
+		assert.Equals(t, 1, len(checker.inputs))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
+
+func TestPrefetchingVolumes(t *testing.T) {
+	var (
+		volumeId   = "test-volume-id"
+		bucketName = "test-bucket-name"
+	)
+
+	request := func(targetPath, prefetchPaths string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId: volumeId,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+			},
+			TargetPath: targetPath,
+			VolumeContext: map[string]string{
+				"bucketName":                       bucketName,
+				"prefetchPaths":                    prefetchPaths,
+				"csi.storage.k8s.io/pod.name":      "test-pod",
+				"csi.storage.k8s.io/pod.namespace": "test-ns",
+			},
+		}
+	}
+	nextEvent := func(t *testing.T, recorder *record.FakeRecorder) string {
+		t.Helper()
+		select {
+		case event := <-recorder.Events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an event")
+			return ""
+		}
+	}
+
+	t.Run("reads files under prefetch paths and reports progress", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+		eventRecorder := record.NewFakeRecorder(10)
+		nodeTestEnv.server.EventRecorder = eventRecorder
+
+		// The mock mounter does not mount anything, so files are read from the target directory directly
+		targetPath := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(targetPath, "models", "llama"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(targetPath, "models", "llama", "weights.bin"), []byte("weights"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(targetPath, "models", "config.json"), []byte("{}"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(targetPath, "other.bin"), []byte("other"), 0o644))
+
+		nodeTestEnv.mockMounter.EXPECT().Mount(gomock.Eq(bucketName), gomock.Eq(targetPath), gomock.Any(), gomock.Any())
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(targetPath, "/models/, missing"))
+		assert.NoError(t, err)
+
+		// Prefetch events are emitted in the background, so events are consumed one by one
+		for _, reason := range []string{node.EventReasonCredentialsResolved, node.EventReasonMounted} {
+			if event := nextEvent(t, eventRecorder); !strings.HasPrefix(event, "Normal "+reason) {
+				t.Fatalf("Expected %s event, got %q", reason, event)
+			}
+		}
+		assert.Equals(t, "Normal MountpointPrefetchStarted Prefetching models, missing of volume test-volume-id", nextEvent(t, eventRecorder))
+		assert.Equals(t, "Normal MountpointPrefetchProgress Prefetched models of volume test-volume-id: 2 files (9 bytes)", nextEvent(t, eventRecorder))
+		if event := nextEvent(t, eventRecorder); !strings.HasPrefix(event, "Warning MountpointPrefetchFailed Failed to prefetch missing of volume test-volume-id") {
+			t.Fatalf("Unexpected event %q", event)
+		}
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+
+	t.Run("rejects paths escaping the volume", func(t *testing.T) {
+		nodeTestEnv := initNodeServerTestEnv(t)
+
+		_, err := nodeTestEnv.server.NodePublishVolume(context.Background(), request(t.TempDir(), "models/../../etc"))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+
+		nodeTestEnv.mockCtl.Finish()
+	})
+}
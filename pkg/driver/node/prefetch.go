@@ -0,0 +1,176 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// Reasons of the events emitted to the workload Pods for prefetching their volumes.
+const (
+	EventReasonPrefetchStarted   = "MountpointPrefetchStarted"
+	EventReasonPrefetchProgress  = "MountpointPrefetchProgress"
+	EventReasonPrefetchCompleted = "MountpointPrefetchCompleted"
+	EventReasonPrefetchFailed    = "MountpointPrefetchFailed"
+)
+
+// prefetchBufferSize is the size of reads issued while prefetching files, which matches the part size of Mountpoint.
+const prefetchBufferSize = 8 * 1024 * 1024
+
+// parsePrefetchPaths parses `prefetchPaths` volume attribute, a comma-separated list of paths relative to the root
+// of the volume. Paths are cleaned, and paths escaping the root of the volume are rejected.
+func parsePrefetchPaths(volumeCtx map[string]string) ([]string, error) {
+	value := volumeCtx[volumecontext.PrefetchPaths]
+	if value == "" {
+		return nil, nil
+	}
+
+	var paths []string
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if slices.Contains(strings.Split(p, "/"), "..") {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid %s: path %q must not contain \"..\"", volumecontext.PrefetchPaths, p)
+		}
+		paths = append(paths, strings.TrimPrefix(path.Clean("/"+p), "/"))
+	}
+	return paths, nil
+}
+
+// prefetches tracks prefetches of published volumes by target path, so they're started once per target
+// and cancelled once the target is unpublished.
+type prefetches struct {
+	mu     sync.Mutex
+	cancel map[string]context.CancelFunc
+}
+
+func newPrefetches() *prefetches {
+	return &prefetches{cancel: make(map[string]context.CancelFunc)}
+}
+
+// start runs `prefetch` for `target` in the background, unless it's already started for `target`.
+func (p *prefetches) start(target string, prefetch func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.cancel[target]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel[target] = cancel
+	go prefetch(ctx)
+}
+
+// stop cancels the prefetch of `target` if it's still running.
+func (p *prefetches) stop(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cancel, ok := p.cancel[target]; ok {
+		cancel()
+		delete(p.cancel, target)
+	}
+}
+
+// startPrefetch starts prefetching `paths` of given `vol` mounted at `target` in the background, so latency-sensitive
+// workloads don't pay the cost of first reads, e.g. while loading a model. Files are read through the mount,
+// so they're cached by Mountpoint if caching is configured for the volume. Progress is reported as events on the workload Pod.
+func (ns *S3NodeServer) startPrefetch(target string, vol publishedVolume, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	ns.prefetches.start(target, func(ctx context.Context) {
+		ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonPrefetchStarted,
+			"Prefetching %s of volume %s", strings.Join(paths, ", "), vol.volumeID)
+
+		start := time.Now()
+		var totalFiles, totalBytes int64
+		var failed bool
+		for _, p := range paths {
+			files, bytes, err := prefetchPath(ctx, filepath.Join(target, p))
+			totalFiles, totalBytes = totalFiles+files, totalBytes+bytes
+			if ctx.Err() != nil {
+				klog.V(4).Infof("Prefetch of volume %s at %s cancelled after %d files (%d bytes)", vol.volumeID, target, totalFiles, totalBytes)
+				return
+			}
+			if err != nil {
+				failed = true
+				klog.Warningf("Failed to prefetch %s of volume %s at %s: %v", p, vol.volumeID, target, err)
+				ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonPrefetchFailed,
+					"Failed to prefetch %s of volume %s after %d files (%d bytes): %v", p, vol.volumeID, files, bytes, err)
+				continue
+			}
+			ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonPrefetchProgress,
+				"Prefetched %s of volume %s: %d files (%d bytes)", p, vol.volumeID, files, bytes)
+		}
+
+		if !failed {
+			ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonPrefetchCompleted,
+				"Prefetched volume %s: %d files (%d bytes) in %s", vol.volumeID, totalFiles, totalBytes, time.Since(start).Round(time.Second))
+		}
+	})
+}
+
+// prefetchPath reads all regular files under `root` (or `root` itself if it's a file), and returns the number of files
+// and bytes read. It stops on the first error or once `ctx` is cancelled.
+func prefetchPath(ctx context.Context, root string) (files, bytes int64, err error) {
+	buf := make([]byte, prefetchBufferSize)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		n, err := readFile(p, buf)
+		bytes += n
+		if err != nil {
+			return err
+		}
+		files++
+		return nil
+	})
+	return files, bytes, err
+}
+
+// readFile reads file at `p` sequentially into `buf` and discards its content, and returns the number of bytes read.
+func readFile(p string, buf []byte) (int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var total int64
+	for {
+		n, err := f.Read(buf)
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+	}
+}
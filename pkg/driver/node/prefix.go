@@ -0,0 +1,51 @@
+package node
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// prefixVariables are the variables that can be used in prefixes, in the same `$(VAR)` syntax as `subPathExpr`,
+// mapped to the volume context keys populated by kubelet with the workload Pod's information.
+var prefixVariables = map[string]string{
+	"POD_NAME":             volumecontext.CSIPodName,
+	"POD_NAMESPACE":        volumecontext.CSIPodNamespace,
+	"POD_UID":              volumecontext.CSIPodUID,
+	"SERVICE_ACCOUNT_NAME": volumecontext.CSIServiceAccountName,
+}
+
+var prefixVariableRegexp = regexp.MustCompile(`\$\(([^)]*)\)`)
+
+// hasPrefixVariables returns whether `prefix` uses any variables, which makes the mount specific to a workload Pod.
+func hasPrefixVariables(prefix string) bool {
+	return strings.Contains(prefix, "$(")
+}
+
+// expandPrefix expands variables in `prefix` with the workload Pod's information from `volumeCtx`,
+// e.g. `jobs/$(POD_NAME)/` to `jobs/training-0/`, to isolate Pods writing to the same bucket.
+//
+// Kubelet handles `subPath` and `subPathExpr` of volume mounts itself and the CSI Driver never sees them,
+// so prefixes with variables are the way to give each Pod its own prefix of the bucket.
+func expandPrefix(prefix string, volumeCtx map[string]string) (string, error) {
+	var err error
+	expanded := prefixVariableRegexp.ReplaceAllStringFunc(prefix, func(match string) string {
+		name := match[2 : len(match)-1]
+		key, ok := prefixVariables[name]
+		if !ok {
+			err = status.Errorf(codes.InvalidArgument, "Unknown variable %q in prefix %q", name, prefix)
+			return match
+		}
+		value := volumeCtx[key]
+		if value == "" {
+			err = status.Errorf(codes.FailedPrecondition, "Variable %q in prefix %q requires %q in volume context, is podInfoOnMount enabled in the CSIDriver object?", name, prefix, key)
+			return match
+		}
+		return value
+	})
+	return expanded, err
+}
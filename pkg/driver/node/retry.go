@@ -0,0 +1,70 @@
+package node
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// A MountRetryPolicy configures how failed mounts are retried within a `NodePublishVolume` call.
+//
+// A failed mount is retried up to `Retries` times after a backoff, which starts with `Backoff` and doubles with
+// each retry up to `MaxBackoff`. Kubelet also retries failed `NodePublishVolume` calls with its own backoff,
+// so retrying here is mostly useful for transient failures on slow nodes, e.g. while Mountpoint is starting.
+type MountRetryPolicy struct {
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultMountRetryPolicy is the default policy to retry failed mounts, which leaves retries to kubelet.
+var DefaultMountRetryPolicy = MountRetryPolicy{
+	Retries:    0,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+}
+
+// mountRetryPolicyFor returns the retry policy for the volume, using `mountRetries` and `mountRetryBackoff`
+// volume attributes to override the driver-level policy if they're set.
+func (ns *S3NodeServer) mountRetryPolicyFor(volumeCtx map[string]string) (MountRetryPolicy, error) {
+	policy := ns.MountRetryPolicy
+	if retries, ok := volumeCtx[volumecontext.MountRetries]; ok {
+		value, err := strconv.Atoi(retries)
+		if err != nil || value < 0 {
+			return policy, status.Errorf(codes.InvalidArgument, "Invalid %s %q: must be a non-negative integer", volumecontext.MountRetries, retries)
+		}
+		policy.Retries = value
+	}
+	if backoff, ok := volumeCtx[volumecontext.MountRetryBackoff]; ok {
+		value, err := time.ParseDuration(backoff)
+		if err != nil || value <= 0 {
+			return policy, status.Errorf(codes.InvalidArgument, "Invalid %s %q: must be a positive duration, e.g. \"5s\"", volumecontext.MountRetryBackoff, backoff)
+		}
+		policy.Backoff = value
+	}
+	return policy, nil
+}
+
+// withRetries calls `mount` until it succeeds or the retries of the policy are exhausted, and returns its last error.
+func (p MountRetryPolicy) withRetries(volumeID, target string, mount func() error) error {
+	backoff := p.Backoff
+	for retry := 0; ; retry++ {
+		err := mount()
+		if err == nil || retry >= p.Retries {
+			return err
+		}
+
+		klog.Warningf("NodePublishVolume: failed to mount volume %s at %s, retrying in %s (%d/%d): %v", volumeID, target, backoff, retry+1, p.Retries, err)
+		time.Sleep(backoff)
+		if p.MaxBackoff > 0 {
+			backoff = min(backoff*2, p.MaxBackoff)
+		} else {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package node
+
+import (
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// EventReasonSELinuxContextNotApplied is the reason of the event emitted to the workload Pods
+// if the SELinux context passed by kubelet cannot be applied to the mount.
+const EventReasonSELinuxContextNotApplied = "SELinuxContextNotApplied"
+
+// seLinuxContextArg is the mount option kubelet passes with the SELinux context of the workload Pod
+// if the CSIDriver object has `seLinuxMount: true`, e.g., `context="system_u:object_r:container_file_t:s0:c1,c2"`.
+const seLinuxContextArg = "--context"
+
+// extractSELinuxContext removes the SELinux context mount option from `args`, and returns the context without quotes.
+// It returns an empty string if no context is passed.
+func extractSELinuxContext(args *mountpoint.Args) (string, error) {
+	value, ok := args.Remove(seLinuxContextArg)
+	if !ok {
+		return "", nil
+	}
+
+	context := strings.Trim(value, `"`)
+	// An SELinux context is in `user:role:type:level` format, where level might contain colons as well.
+	if parts := strings.SplitN(context, ":", 4); len(parts) != 4 || slices.Contains(parts, "") {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid SELinux context %q, expected \"user:role:type:level\"", value)
+	}
+	return context, nil
+}
+
+// applySELinuxContext handles the SELinux context passed by kubelet in `args` for `vol`.
+//
+// Mountpoint performs the FUSE mount itself and does not accept security context options, so the context is removed
+// from `args` to not fail the mount, and the mount gets the default label of FUSE file systems from the host's policy.
+// A warning event is emitted to the workload Pod to make this visible, as the workload might be denied access to
+// the mount on SELinux-enforcing hosts.
+func (ns *S3NodeServer) applySELinuxContext(vol publishedVolume, args *mountpoint.Args) error {
+	context, err := extractSELinuxContext(args)
+	if err != nil || context == "" {
+		return err
+	}
+
+	klog.Warningf("NodePublishVolume: SELinux context %q of volume %s cannot be applied to Mountpoint mounts, using the default label of FUSE file systems", context, vol.volumeID)
+	ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonSELinuxContextNotApplied,
+		"SELinux context %q cannot be applied to volume %s, the host's SELinux policy must allow access to FUSE file systems", context, vol.volumeID)
+	return nil
+}
@@ -0,0 +1,185 @@
+package node
+
+import (
+	"context"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/targetpath"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// sharesMountWith returns whether `vol` can be bind mounted from `source`, i.e., `source` is published for the same
+// volume and workload Pod, and it's served by its own Mountpoint process with the same options and credentials.
+//
+// Kubelet publishes a volume once per Pod even if it's mounted at multiple paths, but Pods can use multiple
+// PersistentVolumes with the same volume handle (e.g., with different `claimRef`s), which are published separately.
+func (vol publishedVolume) sharesMountWith(source publishedVolume) bool {
+	podUID := vol.volumeCtx[volumecontext.CSIPodUID]
+	if podUID == "" || source.volumeCtx[volumecontext.CSIPodUID] != podUID {
+		return false
+	}
+	if source.stagingTarget != "" || source.sourceTarget != "" || source.released {
+		return false
+	}
+	// The cache directory is derived from the target path. The region of the bucket is detected after looking for
+	// a source, once credentials are provided, so the region of the source is ignored if `vol` doesn't set one.
+	ignored := []mountpoint.ArgKey{mountpoint.ArgCache}
+	if args := mountpoint.ParseArgs(vol.args); !args.Has(mountpoint.ArgRegion) {
+		ignored = append(ignored, mountpoint.ArgRegion)
+	}
+	return source.volumeID == vol.volumeID && source.bucket == vol.bucket &&
+		slices.Equal(argsWithout(source.args, ignored), argsWithout(vol.args, ignored)) &&
+		maps.Equal(source.secrets, vol.secrets) &&
+		maps.Equal(withoutTokens(source.volumeCtx), withoutTokens(vol.volumeCtx))
+}
+
+// argsWithout returns `args` without the arguments with given `keys`.
+func argsWithout(args []string, keys []mountpoint.ArgKey) []string {
+	parsed := mountpoint.ParseArgs(args)
+	for _, key := range keys {
+		parsed.Remove(key)
+	}
+	return parsed.SortedList()
+}
+
+// withoutTokens returns `volumeCtx` without service account tokens, which kubelet issues on every publish.
+func withoutTokens(volumeCtx map[string]string) map[string]string {
+	volumeCtx = maps.Clone(volumeCtx)
+	delete(volumeCtx, volumecontext.CSIServiceAccountTokens)
+	return volumeCtx
+}
+
+// findSource returns another target path that `vol` can be bind mounted from at `target`.
+func (p *publishedVolumes) findSource(target string, vol publishedVolume) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for source, sourceVol := range p.byTarget {
+		if source != target && vol.sharesMountWith(sourceVol) {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// hasDependents returns whether any published target path is bind mounted from `source`.
+func (p *publishedVolumes) hasDependents(source string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, vol := range p.byTarget {
+		if vol.sourceTarget == source {
+			return true
+		}
+	}
+	return false
+}
+
+// republishFromSource handles republishes of `vol` at `target`, which is bind mounted from another target path.
+// Kubelet republishes volumes with new service account tokens, which the Mountpoint process of the source reads
+// from the token file of the volume and the workload Pod, and the source is not republished anymore once it's released.
+func (ns *S3NodeServer) republishFromSource(ctx context.Context, target string, vol publishedVolume) (*csi.NodePublishVolumeResponse, error) {
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
+
+	// The volume might be unpublished or re-mounted on its own while we were waiting for the lock.
+	published, ok := ns.publishedVolumes.get(target)
+	if !ok || published.sourceTarget == "" {
+		return nil, status.Errorf(codes.Aborted, "Volume %s was unpublished or re-mounted at %q concurrently", vol.volumeID, target)
+	}
+	if _, err := ns.provideCredentials(ctx, vol.volumeID, vol.volumeCtx, vol.secrets, mountpoint.ParseArgs(vol.args)); err != nil {
+		klog.Errorf("NodePublishVolume: failed to provide credentials: %v", err)
+		return nil, err
+	}
+	vol.sourceTarget, vol.roleARN = published.sourceTarget, published.roleARN
+	ns.publishedVolumes.add(target, vol)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// publishFromSource publishes `vol` at `target` by bind mounting it from `source`, rather than spawning
+// another Mountpoint process with the same options and credentials. Sources are only unmounted once
+// all target paths bind mounted from them are unpublished.
+//
+// Target locks are hashed, so the locks of a target path and its source are never held at the same time to avoid
+// deadlocks on collisions. Only the source is locked here, concurrent publishes of `target` are coalesced by `inflightPublishes`.
+func (ns *S3NodeServer) publishFromSource(target, source string, vol publishedVolume) (*csi.NodePublishVolumeResponse, error) {
+	ns.targetLocks.LockKey(source)
+	defer ns.targetLocks.UnlockKey(source)
+
+	// The source might be unpublished while we were waiting for the lock, kubelet retries to publish the volume then.
+	sourceVol, ok := ns.publishedVolumes.get(source)
+	if !ok || !vol.sharesMountWith(sourceVol) {
+		return nil, status.Errorf(codes.Aborted, "Target path %q to bind mount volume %s from was unpublished concurrently", source, vol.volumeID)
+	}
+
+	if err := ns.Mounter.BindMount(source, target, false); err != nil {
+		mountFailuresTotal.Inc()
+		os.Remove(target)
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonMountFailed,
+			"Could not bind mount volume %s from another target path: %v", vol.volumeID, err)
+		return nil, status.Errorf(codes.Internal, "Could not bind mount %q at %q: %v", source, target, err)
+	}
+	klog.V(4).Infof("NodePublishVolume: volume %s was bind mounted from %s to %s", vol.volumeID, source, target)
+
+	vol.sourceTarget = source
+	vol.roleARN = sourceVol.roleARN
+	ns.publishedVolumes.add(target, vol)
+	audit(auditActionAttach, target, vol)
+	ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonMounted, "Mounted bucket %s for volume %s with %s, bind mounted from another target path of the Pod",
+		vol.bucket, vol.volumeID, vol.accessSummary())
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// releaseSource unmounts `source` if it was unpublished while other target paths were bind mounted from it,
+// and none of them is left. It's called once the last target path bind mounted from `source` is unpublished,
+// or periodically by `MonitorMounts`. The lock of that target path must not be held.
+func (ns *S3NodeServer) releaseSource(ctx context.Context, source string) {
+	ns.targetLocks.LockKey(source)
+	defer ns.targetLocks.UnlockKey(source)
+
+	vol, ok := ns.publishedVolumes.get(source)
+	if !ok || !vol.released || ns.publishedVolumes.hasDependents(source) {
+		return
+	}
+
+	gracePeriod := ns.UnmountGracePeriod
+	if value, err := ns.unmountGracePeriodFor(vol.volumeCtx); err == nil {
+		gracePeriod = value
+	}
+	if err := ns.unmountIfMounted(ctx, "NodeUnpublishVolume", vol.volumeID, source, gracePeriod); err != nil {
+		klog.Errorf("NodeUnpublishVolume: failed to unmount released target path %s of volume %s: %v", source, vol.volumeID, err)
+		return
+	}
+	ns.publishedVolumes.remove(source)
+	audit(auditActionDetach, source, vol)
+	ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonUnmounted, "Unmounted volume %s", vol.volumeID)
+	ns.cleanupCredentials(vol.volumeID, source)
+}
+
+// cleanupCredentials removes the service account token and session credentials written for the volume and workload Pod
+// published at `target`.
+func (ns *S3NodeServer) cleanupCredentials(volumeID, target string) {
+	targetPath, err := targetpath.Parse(target)
+	if err != nil {
+		klog.V(4).Infof("NodeUnpublishVolume: Failed to parse target path %s: %v", target, err)
+		return
+	}
+	if targetPath.VolumeID != volumeID {
+		klog.V(4).Infof("NodeUnpublishVolume: Volume ID from parsed target path differs from Volume ID passed: %s (parsed) != %s (passed)", targetPath.VolumeID, volumeID)
+		return
+	}
+	if err := ns.credentialProvider.CleanupToken(targetPath.VolumeID, targetPath.PodID); err != nil {
+		klog.V(4).Infof("NodeUnpublishVolume: Failed to cleanup token for pod/volume %s/%s: %v", targetPath.PodID, volumeID, err)
+	}
+	if err := ns.credentialProvider.CleanupSessionCredentials(targetPath.VolumeID, targetPath.PodID); err != nil {
+		klog.V(4).Infof("NodeUnpublishVolume: Failed to cleanup session credentials for pod/volume %s/%s: %v", targetPath.PodID, volumeID, err)
+	}
+}
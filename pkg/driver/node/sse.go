@@ -0,0 +1,56 @@
+package node
+
+import (
+	"regexp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// kmsKeyARNPattern matches ARNs of KMS keys, including multi-Region keys. Mountpoint only accepts KMS keys by ARN,
+// not by key ID or alias.
+var kmsKeyARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]{12}:key/[a-zA-Z0-9-]+$`)
+
+// setSSEArgs validates and translates server-side encryption settings passed via volume context to Mountpoint arguments,
+// which take precedence over the ones in mount options if any.
+//
+// `sseType` is the server-side encryption type of objects Mountpoint uploads, and `kmsKeyId` is the ARN of the KMS key
+// to encrypt them with. `sseType` defaults to `aws:kms` if only `kmsKeyId` is set.
+func setSSEArgs(volumeCtx map[string]string, args *mountpoint.Args) error {
+	sseType, hasSSEType := volumeCtx[volumecontext.SSEType]
+	kmsKeyID, hasKMSKeyID := volumeCtx[volumecontext.KMSKeyID]
+	if !hasSSEType && !hasKMSKeyID {
+		return nil
+	}
+
+	if !hasSSEType {
+		sseType = volumecontext.SSETypeKMS
+	}
+	switch sseType {
+	case volumecontext.SSETypeS3:
+		if hasKMSKeyID {
+			return status.Errorf(codes.InvalidArgument, "%s can only be used with %s %q or %q",
+				volumecontext.KMSKeyID, volumecontext.SSEType, volumecontext.SSETypeKMS, volumecontext.SSETypeKMSDSSE)
+		}
+	case volumecontext.SSETypeKMS, volumecontext.SSETypeKMSDSSE:
+	default:
+		return status.Errorf(codes.InvalidArgument, "%s %q must be one of %q, %q or %q", volumecontext.SSEType, sseType,
+			volumecontext.SSETypeS3, volumecontext.SSETypeKMS, volumecontext.SSETypeKMSDSSE)
+	}
+	if hasKMSKeyID && !kmsKeyARNPattern.MatchString(kmsKeyID) {
+		return status.Errorf(codes.InvalidArgument, "%s %q must be the ARN of a KMS key, e.g. \"arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab\"",
+			volumecontext.KMSKeyID, kmsKeyID)
+	}
+
+	args.Set(mountpoint.ArgSSE, sseType)
+	if hasKMSKeyID {
+		args.Set(mountpoint.ArgSSEKMSKeyID, kmsKeyID)
+	} else if sseType == volumecontext.SSETypeS3 {
+		// A KMS key from mount options can't be used with `AES256`.
+		args.Remove(mountpoint.ArgSSEKMSKeyID)
+	}
+	return nil
+}
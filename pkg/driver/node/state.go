@@ -0,0 +1,160 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// stateVersion is the version of the state file format, state files of other versions are ignored.
+const stateVersion = 1
+
+// A nodeState is the contents of the state file, which persists published volumes in this node across restarts of the node plugin.
+type nodeState struct {
+	Version int                        `json:"version"`
+	Volumes map[string]persistedVolume `json:"volumes"`
+}
+
+// A persistedVolume is a `publishedVolume` as it's persisted in the state file.
+type persistedVolume struct {
+	VolumeID      string            `json:"volumeID"`
+	Bucket        string            `json:"bucket"`
+	VolumeContext map[string]string `json:"volumeContext"`
+	UsesSecrets   bool              `json:"usesSecrets,omitempty"`
+	Args          []string          `json:"args"`
+	StagingTarget string            `json:"stagingTarget,omitempty"`
+	ReadOnly      bool              `json:"readOnly,omitempty"`
+	RoleARN       string            `json:"roleARN,omitempty"`
+	SourceTarget  string            `json:"sourceTarget,omitempty"`
+	Released      bool              `json:"released,omitempty"`
+}
+
+func newPersistedVolume(vol publishedVolume) persistedVolume {
+	// Service account tokens are short-lived, and secrets are sensitive, kubelet passes them again when it republishes the volume.
+	volumeCtx := maps.Clone(vol.volumeCtx)
+	delete(volumeCtx, volumecontext.CSIServiceAccountTokens)
+
+	return persistedVolume{
+		VolumeID:      vol.volumeID,
+		Bucket:        vol.bucket,
+		VolumeContext: volumeCtx,
+		UsesSecrets:   len(vol.secrets) > 0 || vol.secretsPending,
+		Args:          vol.args,
+		StagingTarget: vol.stagingTarget,
+		ReadOnly:      vol.readOnly,
+		RoleARN:       vol.roleARN,
+		SourceTarget:  vol.sourceTarget,
+		Released:      vol.released,
+	}
+}
+
+func (v persistedVolume) publishedVolume() publishedVolume {
+	return publishedVolume{
+		volumeID:       v.VolumeID,
+		bucket:         v.Bucket,
+		volumeCtx:      v.VolumeContext,
+		secretsPending: v.UsesSecrets,
+		args:           v.Args,
+		stagingTarget:  v.StagingTarget,
+		readOnly:       v.ReadOnly,
+		roleARN:        v.RoleARN,
+		sourceTarget:   v.SourceTarget,
+		released:       v.Released,
+	}
+}
+
+// RestoreState restores volumes published in this node before a restart of the node plugin from `stateFile`,
+// and persists published volumes to `stateFile` from now on. It should be called once before serving any RPCs.
+//
+// Mountpoint processes are spawned via systemd and keep running while the node plugin restarts, but the node plugin
+// loses track of the volumes they serve. Restored volumes are monitored and re-mounted by `MonitorMounts`, and they
+// emit events and clean up their metrics once unpublished, as if they were published after the restart.
+// Volumes that were unmounted while the node plugin was not running are not restored.
+func (ns *S3NodeServer) RestoreState(stateFile string) error {
+	// A corrupted state file is overwritten below, as the node plugin should still persist the volumes published from now on.
+	state, readErr := readState(stateFile)
+
+	restored := 0
+	for target, vol := range state.Volumes {
+		isMountPoint, err := ns.Mounter.IsMountPoint(target)
+		if os.IsNotExist(err) || (err == nil && !isMountPoint) {
+			klog.V(4).Infof("RestoreState: volume %s is not mounted at %s anymore, not restoring it", vol.VolumeID, target)
+			continue
+		}
+		if err != nil && !mount.IsCorruptedMnt(err) {
+			klog.V(4).Infof("RestoreState: failed to check if target path %s is a mount point: %v, restoring it anyway", target, err)
+		}
+		ns.publishedVolumes.add(target, vol.publishedVolume())
+		restored++
+	}
+	klog.Infof("RestoreState: restored %d of %d published volumes from %s", restored, len(state.Volumes), stateFile)
+
+	return errors.Join(readErr, ns.publishedVolumes.persistTo(stateFile))
+}
+
+// readState reads the state file at `path`. A missing state file or a state file of another version
+// results in an empty state, as there is nothing to restore.
+func readState(path string) (nodeState, error) {
+	state := nodeState{Version: stateVersion, Volumes: map[string]persistedVolume{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	var persisted nodeState
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return state, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if persisted.Version != stateVersion {
+		klog.Warningf("RestoreState: ignoring state file %s of unsupported version %d", path, persisted.Version)
+		return state, nil
+	}
+	if persisted.Volumes != nil {
+		state.Volumes = persisted.Volumes
+	}
+	return state, nil
+}
+
+// writeState atomically replaces the state file at `path` with `volumes`.
+// Secrets from `nodePublishSecretRef` are never persisted, but the state file is still only readable by its owner.
+func writeState(path string, volumes map[string]publishedVolume) error {
+	state := nodeState{Version: stateVersion, Volumes: make(map[string]persistedVolume, len(volumes))}
+	for target, vol := range volumes {
+		state.Volumes[target] = newPersistedVolume(vol)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file %s: %w", path, err)
+	}
+	return nil
+}
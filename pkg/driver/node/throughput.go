@@ -0,0 +1,29 @@
+package node
+
+import (
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// setMaxThroughputArg validates and translates `maximumThroughputGbps` passed via volume context to Mountpoint's
+// `--maximum-throughput-gbps` argument, which takes precedence over the one in mount options if any.
+//
+// Mountpoint sizes its concurrent S3 requests to reach this throughput target, so a low value keeps a single volume
+// from saturating the network bandwidth of the node.
+func setMaxThroughputArg(volumeCtx map[string]string, args *mountpoint.Args) error {
+	value, ok := volumeCtx[volumecontext.MaxThroughputGbps]
+	if !ok {
+		return nil
+	}
+	gbps, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || gbps == 0 {
+		return status.Errorf(codes.InvalidArgument, "%s %q must be a positive number of Gbps", volumecontext.MaxThroughputGbps, value)
+	}
+	args.Set(mountpoint.ArgMaxThroughputGbps, strconv.FormatUint(gbps, 10))
+	return nil
+}
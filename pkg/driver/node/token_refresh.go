@@ -0,0 +1,147 @@
+package node
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+)
+
+// TokenReissueWindow is how long before expiry service account tokens of pod-level credentials are re-issued
+// by the CSI Driver if kubelet has not republished the volume with a new token yet.
+const TokenReissueWindow = 20 * time.Minute
+
+// Reasons of the events emitted to the workload Pods about refreshing their service account tokens.
+const (
+	EventReasonTokenReissued      = "MountpointTokenReissued"
+	EventReasonTokenReissueFailed = "MountpointTokenReissueFailed"
+)
+
+// refreshTokensAt re-issues service account tokens of published volumes whose token is written at `tokenPath` if needed.
+func (ns *S3NodeServer) refreshTokensAt(ctx context.Context, tokenPath string) {
+	for target, vol := range ns.publishedVolumes.snapshot() {
+		if ns.credentialProvider.TokenPath(vol.volumeCtx[volumecontext.CSIPodUID], vol.volumeID) == tokenPath {
+			ns.refreshToken(ctx, "WatchTokens", target, vol)
+		}
+	}
+}
+
+// refreshToken re-issues the service account token of `vol` published at `target` if it uses pod-level credentials
+// of a service account in [S3NodeServer.ReissueTokenServiceAccounts], and its token expires within [TokenReissueWindow]
+// or the token file written for Mountpoint is missing.
+//
+// Kubelet passes a new token on every republish, but republishes might be delayed or skipped (e.g., if kubelet
+// is restarted or its sync loop is stuck), and Mountpoint fails to refresh its credentials with an expired token.
+// The CSI Driver requests a token bound to the workload Pod itself in that case, and provides credentials again
+// with it, so Mountpoint reads the new token once its credentials expire. The new token has the same lifetime as
+// the tokens kubelet requests for the CSIDriver object, see [S3NodeServer.tokenExpiration].
+//
+// `caller` is the prefix of the logs, i.e., the loop refreshing the token.
+func (ns *S3NodeServer) refreshToken(ctx context.Context, caller, target string, vol publishedVolume) {
+	if vol.volumeCtx[volumecontext.AuthenticationSource] != mounter.AuthenticationSourcePod {
+		return
+	}
+	serviceAccount := vol.volumeCtx[volumecontext.CSIPodNamespace] + "/" + vol.volumeCtx[volumecontext.CSIServiceAccountName]
+	if !slices.Contains(ns.ReissueTokenServiceAccounts, serviceAccount) {
+		return
+	}
+	expiration, ok := mounter.TokenExpiration(vol.volumeCtx)
+	podUID := vol.volumeCtx[volumecontext.CSIPodUID]
+	if !ok || (time.Until(expiration) > TokenReissueWindow && ns.credentialProvider.TokenExists(podUID, vol.volumeID)) {
+		return
+	}
+
+	ns.targetLocks.LockKey(target)
+	defer ns.targetLocks.UnlockKey(target)
+
+	// The volume might be unpublished or republished with a new token while we were waiting for the lock.
+	vol, ok = ns.publishedVolumes.get(target)
+	if !ok {
+		return
+	}
+	if expiration, _ := mounter.TokenExpiration(vol.volumeCtx); time.Until(expiration) > TokenReissueWindow && ns.credentialProvider.TokenExists(podUID, vol.volumeID) {
+		return
+	}
+
+	klog.V(4).Infof("%s: Re-issuing service account token of volume %s at %s, it expires at %s", caller, vol.volumeID, target, expiration)
+	volumeCtx, err := ns.credentialProvider.ReissueToken(ctx, vol.volumeCtx, ns.tokenExpiration(ctx, caller))
+	if err == nil {
+		_, err = ns.provideCredentials(ctx, vol.volumeID, volumeCtx, vol.secrets, mountpoint.ParseArgs(vol.args))
+	}
+	if err != nil {
+		klog.Errorf("%s: failed to re-issue service account token of volume %s at %s: %v", caller, vol.volumeID, target, err)
+		tokenReissuesTotal.WithLabelValues("failure").Inc()
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonTokenReissueFailed,
+			"Failed to re-issue service account token of volume %s expiring at %s, Mountpoint will fail to refresh its credentials: %v",
+			vol.volumeID, expiration.Format(time.RFC3339), err)
+		return
+	}
+
+	vol.volumeCtx = volumeCtx
+	ns.publishedVolumes.add(target, vol)
+	tokenReissuesTotal.WithLabelValues("success").Inc()
+	ns.recordEvent(vol, corev1.EventTypeNormal, EventReasonTokenReissued,
+		"Service account token of volume %s was re-issued, as it was not refreshed by kubelet before expiring", vol.volumeID)
+}
+
+// tokenExpiration returns the lifetime of service account tokens for STS in `tokenRequests` of the CSIDriver object,
+// so re-issued tokens live as long as the ones kubelet requests. It falls back to [mounter.DefaultTokenExpiration]
+// if [S3NodeServer.CSIDrivers] is not set or the CSIDriver object cannot be read.
+func (ns *S3NodeServer) tokenExpiration(ctx context.Context, caller string) time.Duration {
+	if ns.CSIDrivers == nil {
+		return mounter.DefaultTokenExpiration
+	}
+	csiDriver, err := ns.CSIDrivers.Get(ctx, ns.DriverName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("%s: failed to get CSIDriver %s, re-issuing tokens with expiration of %s: %v", caller, ns.DriverName, mounter.DefaultTokenExpiration, err)
+		return mounter.DefaultTokenExpiration
+	}
+	return mounter.TokenExpirationOf(csiDriver)
+}
+
+// WatchTokens watches service account tokens written for Mountpoint, and re-issues them if they're removed or
+// replaced unexpectedly (e.g., by a cleanup of the host's plugin directory) while their volumes are still published.
+// Tokens close to expiry are also re-issued by [S3NodeServer.MonitorMounts] for [S3NodeServer.ReissueTokenServiceAccounts].
+//
+// It blocks until `ctx` is cancelled.
+func (ns *S3NodeServer) WatchTokens(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(ns.credentialProvider.TokenDir()); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if strings.HasSuffix(event.Name, ".token") && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+				klog.V(4).Infof("WatchTokens: service account token %s is removed or renamed, checking its volume", event.Name)
+				ns.refreshTokensAt(ctx, filepath.Clean(event.Name))
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			klog.Warningf("WatchTokens: failed to watch service account tokens: %v", err)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
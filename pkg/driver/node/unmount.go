@@ -0,0 +1,126 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+)
+
+// EventReasonForceUnmounted is the reason of the event emitted to workload Pods once their hung volume is
+// forcefully unmounted, see [S3NodeServer.ForceUnmountTimeout].
+const EventReasonForceUnmounted = "MountpointForceUnmounted"
+
+// unmountBusyRetryInterval is the maximum interval to retry unmounting a busy mount within its grace period.
+const unmountBusyRetryInterval = time.Second
+
+// unmountGracePeriodFor returns the grace period to unmount the volume with, using `unmountGracePeriod` volume attribute
+// to override the driver-level grace period if it's set.
+func (ns *S3NodeServer) unmountGracePeriodFor(volumeCtx map[string]string) (time.Duration, error) {
+	value, ok := volumeCtx[volumecontext.UnmountGracePeriod]
+	if !ok {
+		return ns.UnmountGracePeriod, nil
+	}
+	gracePeriod, err := time.ParseDuration(value)
+	if err != nil || gracePeriod < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "Invalid %s %q: must be a non-negative duration, e.g. \"30s\"", volumecontext.UnmountGracePeriod, value)
+	}
+	return gracePeriod, nil
+}
+
+// unmountWithGracePeriod unmounts `target`, and retries while the mount is busy until `gracePeriod` elapses or `ctx`
+// is done. A mount is busy while files are still open on it, e.g. while Mountpoint is uploading files being written
+// by processes of the workload Pod that didn't exit yet, and unmounting it would fail those uploads.
+func (ns *S3NodeServer) unmountWithGracePeriod(ctx context.Context, rpcName, volumeID, target string, gracePeriod time.Duration) error {
+	deadline := time.Now().Add(gracePeriod)
+	for {
+		err := ns.unmountOrEscalate(rpcName, volumeID, target)
+		remaining := time.Until(deadline)
+		if err == nil || !mounter.IsBusy(err) || remaining <= 0 {
+			return err
+		}
+
+		klog.V(4).InfoS(rpcName+": target path is busy, retrying unmount within grace period",
+			logging.KeyVolumeID, volumeID, logging.KeyTargetPath, target, "remaining", remaining, "error", err)
+		select {
+		case <-time.After(min(unmountBusyRetryInterval, remaining)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// errHung is returned by `callWithHangTimeout` if the call does not complete within `ForceUnmountTimeout`.
+var errHung = errors.New("operation is hung")
+
+// callWithHangTimeout calls `op`, and returns an error wrapping `errHung` if it does not complete within
+// `ForceUnmountTimeout`, e.g. as `stat(2)` or `umount(2)` block while Mountpoint is not responding after a network
+// partition. The hung call is left running in the background, and it returns once the mount is forcefully unmounted.
+func (ns *S3NodeServer) callWithHangTimeout(op func() error) error {
+	if ns.ForceUnmountTimeout <= 0 {
+		return op()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+
+	select {
+	case err := <-done:
+		// The call might time out on its own before `ForceUnmountTimeout`, e.g. `umount` invoked via systemd.
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %w", errHung, err)
+		}
+		return err
+	case <-time.After(ns.ForceUnmountTimeout):
+		return fmt.Errorf("%w: did not complete within %s", errHung, ns.ForceUnmountTimeout)
+	}
+}
+
+// unmountOrEscalate unmounts `target`, and forcefully unmounts it if the unmount is hung.
+func (ns *S3NodeServer) unmountOrEscalate(rpcName, volumeID, target string) error {
+	err := ns.callWithHangTimeout(func() error {
+		return ns.Mounter.Unmount(target)
+	})
+	if errors.Is(err, errHung) {
+		return ns.forceUnmount(rpcName, volumeID, target, err)
+	}
+	return err
+}
+
+// forceUnmount forcefully unmounts `target` after an operation on it hung with `hungErr`, if the mounter supports it.
+// It emits an event to the workload Pod of the volume, as writes still pending on the mount are lost.
+func (ns *S3NodeServer) forceUnmount(rpcName, volumeID, target string, hungErr error) error {
+	forceUnmounter, ok := ns.Mounter.(mounter.ForceUnmounter)
+	if !ok {
+		return hungErr
+	}
+
+	klog.Warningf("%s: volume %s at %s is hung, unmounting forcefully: %v", rpcName, volumeID, target, hungErr)
+	vol, published := ns.publishedVolumes.get(target)
+	if err := forceUnmounter.ForceUnmount(target); err != nil {
+		forceUnmountsTotal.WithLabelValues("failure").Inc()
+		if published {
+			ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonForceUnmounted,
+				"Failed to forcefully unmount hung volume %s: %v", volumeID, err)
+		}
+		return fmt.Errorf("%w, and forceful unmount failed: %w", hungErr, err)
+	}
+
+	forceUnmountsTotal.WithLabelValues("success").Inc()
+	if published {
+		ns.recordEvent(vol, corev1.EventTypeWarning, EventReasonForceUnmounted,
+			"Volume %s was forcefully unmounted as it was hung (%v), writes still pending on it are lost", volumeID, hungErr)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package vault provides a client retrieving short-lived AWS credentials for volumes from HashiCorp Vault's
+// AWS secrets engine.
+package vault
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// DefaultMount is the default path the AWS secrets engine is mounted at in Vault.
+const DefaultMount = "aws"
+
+// DefaultKubernetesAuthPath is the default path Vault's Kubernetes auth method is mounted at.
+const DefaultKubernetesAuthPath = "kubernetes"
+
+// DefaultAudience is the default audience of the service account tokens of workload Pods to login to Vault with,
+// which must be requested by kubelet via `tokenRequests` of the CSIDriver object.
+const DefaultAudience = "vault"
+
+// mountPattern matches the paths the AWS secrets engine can be mounted at, which must be a single path segment.
+var mountPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Config configures how to access Vault.
+type Config struct {
+	// Address is the URL of Vault, e.g. `https://vault.example.com:8200`.
+	Address string
+	// Namespace is the Vault Enterprise namespace to send requests to, if any.
+	Namespace string
+	// KubernetesRole is the role to login with Vault's Kubernetes auth method using the service account token
+	// of the workload Pod, so Vault policies apply to the workload rather than the CSI Driver.
+	KubernetesRole string
+	// KubernetesAuthPath is the path Vault's Kubernetes auth method is mounted at, [DefaultKubernetesAuthPath] if empty.
+	KubernetesAuthPath string
+	// Audience is the audience of the service account tokens to login with, [DefaultAudience] if empty.
+	Audience string
+}
+
+// A Client retrieves credentials from the AWS secrets engine of Vault, see
+// https://developer.hashicorp.com/vault/docs/secrets/aws. Each call logs in with the service account token of the
+// workload Pod and generates new credentials, which Vault revokes once their lease or the login token expires.
+type Client struct {
+	client *http.Client
+	config Config
+	now    func() time.Time
+}
+
+// NewClient returns a new client accessing Vault with given `config`.
+func NewClient(config Config) (*Client, error) {
+	if _, err := url.ParseRequestURI(config.Address); err != nil {
+		return nil, fmt.Errorf("invalid Vault address %q: %w", config.Address, err)
+	}
+	if config.KubernetesRole == "" {
+		return nil, errors.New("Vault Kubernetes auth role is required to login with service account tokens of workload Pods")
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	config.KubernetesAuthPath = cmp.Or(config.KubernetesAuthPath, DefaultKubernetesAuthPath)
+	config.Audience = cmp.Or(config.Audience, DefaultAudience)
+	return &Client{
+		client: &http.Client{Timeout: 10 * time.Second},
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Credentials retrieves credentials for the volume with given `volumeCtx` from the role in `vaultRole` volume attribute
+// of the AWS secrets engine mounted at `vaultMount` volume attribute, or [DefaultMount] if it's not set.
+// It logs in with the service account token of the workload Pod passed by kubelet in `volumeCtx`.
+// It implements `mounter.CredentialBackend`.
+func (c *Client) Credentials(ctx context.Context, volumeCtx map[string]string) (aws.Credentials, error) {
+	role := volumeCtx[volumecontext.VaultRole]
+	if role == "" {
+		return aws.Credentials{}, status.Errorf(codes.InvalidArgument, "`authenticationSource: vault` requires `%s` volume attribute", volumecontext.VaultRole)
+	}
+	mount := cmp.Or(volumeCtx[volumecontext.VaultMount], DefaultMount)
+	if !mountPattern.MatchString(mount) {
+		return aws.Credentials{}, status.Errorf(codes.InvalidArgument, "`%s` volume attribute must be a single path segment, got: %q", volumecontext.VaultMount, mount)
+	}
+
+	jwt, err := c.serviceAccountToken(volumeCtx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	token, tokenExpiration, err := c.login(ctx, jwt)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	var resp struct {
+		LeaseDuration int64 `json:"lease_duration"`
+		Data          struct {
+			AccessKey     string `json:"access_key"`
+			SecretKey     string `json:"secret_key"`
+			SecurityToken string `json:"security_token"`
+			SessionToken  string `json:"session_token"`
+		} `json:"data"`
+	}
+	path := fmt.Sprintf("/v1/%s/creds/%s", url.PathEscape(mount), url.PathEscape(role))
+	if err := c.send(ctx, http.MethodGet, path, token, nil, &resp); err != nil {
+		return aws.Credentials{}, fmt.Errorf("failed to retrieve credentials from Vault role %s/%s: %w", mount, role, err)
+	}
+	if resp.Data.AccessKey == "" || resp.Data.SecretKey == "" {
+		return aws.Credentials{}, fmt.Errorf("no credentials in response of Vault role %s/%s", mount, role)
+	}
+
+	credentials := aws.Credentials{
+		AccessKeyID:     resp.Data.AccessKey,
+		SecretAccessKey: resp.Data.SecretKey,
+		SessionToken:    cmp.Or(resp.Data.SessionToken, resp.Data.SecurityToken),
+		Source:          "Vault",
+	}
+	// Leases are revoked once the token they're created with expires, even if their own duration is longer.
+	if resp.LeaseDuration > 0 {
+		credentials.CanExpire = true
+		credentials.Expires = c.now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+	}
+	if !tokenExpiration.IsZero() && (!credentials.CanExpire || tokenExpiration.Before(credentials.Expires)) {
+		credentials.CanExpire = true
+		credentials.Expires = tokenExpiration
+	}
+	return credentials, nil
+}
+
+// serviceAccountToken returns the service account token of the workload Pod with the configured audience from `volumeCtx`.
+func (c *Client) serviceAccountToken(volumeCtx map[string]string) (string, error) {
+	var tokens map[string]struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(volumeCtx[volumecontext.CSIServiceAccountTokens]), &tokens); err != nil || tokens[c.config.Audience].Token == "" {
+		return "", status.Errorf(codes.InvalidArgument, "`authenticationSource: vault` requires a service account token of the workload Pod for %q audience, "+
+			"please make sure it's in `tokenRequests` of the CSIDriver object", c.config.Audience)
+	}
+	return tokens[c.config.Audience].Token, nil
+}
+
+// login logs in with Vault's Kubernetes auth method using the service account token `jwt` of a workload Pod,
+// and returns the Vault token and when it expires, zero if it never expires.
+func (c *Client) login(ctx context.Context, jwt string) (string, time.Time, error) {
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	err := c.send(ctx, http.MethodPost, "/v1/auth/"+strings.Trim(c.config.KubernetesAuthPath, "/")+"/login", "",
+		map[string]string{"role": c.config.KubernetesRole, "jwt": jwt}, &resp)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to login to Vault with Kubernetes auth role %s: %w", c.config.KubernetesRole, err)
+	}
+	var expiration time.Time
+	if resp.Auth.LeaseDuration > 0 {
+		expiration = c.now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+	return resp.Auth.ClientToken, expiration, nil
+}
+
+// send sends a request to Vault authenticated with `token` if it's not empty, with the JSON encoded `body`
+// if it's not nil, and decodes its response into `out`.
+func (c *Client) send(ctx context.Context, method string, path string, token string, body any, out any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.config.Address+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		err := fmt.Errorf("Vault responded with %s: %s", resp.Status, strings.Join(errResp.Errors, ", "))
+		if resp.StatusCode == http.StatusForbidden {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
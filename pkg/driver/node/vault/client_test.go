@@ -0,0 +1,143 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/vault"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestRetrievingCredentialsFromVault(t *testing.T) {
+	logins, tokenLease := []string{}, 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "s3-workloads" || body["jwt"] == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			logins = append(logins, body["jwt"])
+			w.Write([]byte(`{"auth": {"client_token": "token-of-` + body["jwt"] + `", "lease_duration": ` + strconv.Itoa(tokenLease) + `}}`))
+		case "/v1/aws/creds/s3-reader", "/v1/team-a-aws/creds/s3-reader":
+			if r.Header.Get("X-Vault-Token") != "token-of-workload-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors": ["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"lease_duration": 900, "data": {"access_key": "vault-access-key", "secret_key": "vault-secret-key", "session_token": "vault-session-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer server.Close()
+
+	volumeCtx := func(attributes map[string]string) map[string]string {
+		volumeCtx := map[string]string{
+			"csi.storage.k8s.io/serviceAccount.tokens": `{"vault":{"token":"workload-token"},"sts.amazonaws.com":{"token":"sts-token"}}`,
+		}
+		for k, v := range attributes {
+			volumeCtx[k] = v
+		}
+		return volumeCtx
+	}
+
+	t.Run("logs in with service account token of the workload", func(t *testing.T) {
+		logins = nil
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads"})
+		assert.NoError(t, err)
+
+		credentials, err := client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "s3-reader"}))
+		assert.NoError(t, err)
+		assert.Equals(t, "vault-access-key", credentials.AccessKeyID)
+		assert.Equals(t, "vault-secret-key", credentials.SecretAccessKey)
+		assert.Equals(t, "vault-session-token", credentials.SessionToken)
+		assert.Equals(t, true, credentials.CanExpire)
+
+		// Each call logs in with the token of the workload, as tokens of different workloads have different policies
+		_, err = client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "s3-reader", "vaultMount": "team-a-aws"}))
+		assert.NoError(t, err)
+		assert.Equals(t, 2, len(logins))
+		assert.Equals(t, "workload-token", logins[1])
+	})
+
+	t.Run("expires credentials with the login token", func(t *testing.T) {
+		tokenLease = 60
+		defer func() { tokenLease = 3600 }()
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads"})
+		assert.NoError(t, err)
+
+		credentials, err := client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "s3-reader"}))
+		assert.NoError(t, err)
+		if time.Until(credentials.Expires) > time.Minute {
+			t.Fatalf("Expected credentials to expire with the login token, got expiration %v", credentials.Expires)
+		}
+	})
+
+	t.Run("uses configured audience", func(t *testing.T) {
+		logins = nil
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads", Audience: "sts.amazonaws.com"})
+		assert.NoError(t, err)
+
+		_, err = client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "s3-reader"}))
+		assert.Equals(t, codes.PermissionDenied, status.Code(err))
+		assert.Equals(t, "sts-token", logins[0])
+	})
+
+	t.Run("fails without service account token of the workload", func(t *testing.T) {
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads"})
+		assert.NoError(t, err)
+
+		_, err = client.Credentials(context.Background(), map[string]string{"vaultRole": "s3-reader"})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.Credentials(context.Background(), map[string]string{
+			"vaultRole": "s3-reader",
+			"csi.storage.k8s.io/serviceAccount.tokens": `{"sts.amazonaws.com":{"token":"sts-token"}}`,
+		})
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("fails for missing role", func(t *testing.T) {
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads"})
+		assert.NoError(t, err)
+
+		_, err = client.Credentials(context.Background(), volumeCtx(nil))
+		assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "missing"}))
+		if err == nil {
+			t.Fatal("Expected an error for a missing Vault role")
+		}
+	})
+
+	t.Run("rejects mounts other than a single path segment", func(t *testing.T) {
+		client, err := vault.NewClient(vault.Config{Address: server.URL, KubernetesRole: "s3-workloads"})
+		assert.NoError(t, err)
+
+		for _, mount := range []string{"team-a/aws", "../sys", "..", "aws?list=true", "aws#", "/aws"} {
+			_, err = client.Credentials(context.Background(), volumeCtx(map[string]string{"vaultRole": "s3-reader", "vaultMount": mount}))
+			assert.Equals(t, codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("rejects invalid config", func(t *testing.T) {
+		_, err := vault.NewClient(vault.Config{Address: "vault.example.com", KubernetesRole: "s3-workloads"})
+		if err == nil {
+			t.Fatal("Expected an error for an invalid address")
+		}
+		_, err = vault.NewClient(vault.Config{Address: server.URL})
+		if err == nil {
+			t.Fatal("Expected an error without Kubernetes auth role")
+		}
+	})
+}
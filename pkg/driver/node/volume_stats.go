@@ -0,0 +1,112 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// NodeGetVolumeStats reports the usage of the volume at `VolumePath`, either a target path or a staging target path,
+// as reported by Mountpoint, and its condition, which is abnormal if:
+//   - the Mountpoint process serving it is not running anymore, i.e. the FUSE connection is lost,
+//   - it's not mounted, e.g. as it failed to be re-mounted after its Mountpoint process was terminated,
+//   - or the service account token of its pod-level credentials expired, so Mountpoint fails to refresh its credentials.
+//
+// Kubelet reports abnormal conditions with `kubelet_volume_stats_health_status_abnormal` metric if `CSIVolumeHealth`
+// feature gate is enabled.
+func (ns *S3NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	klog.V(4).Infof("NodeGetVolumeStats: called with args %+v", req)
+
+	volumeID, volumePath := req.GetVolumeId(), req.GetVolumePath()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume path not provided")
+	}
+
+	vol, published := ns.publishedVolumes.get(volumePath)
+	if published && vol.volumeID != volumeID {
+		return nil, status.Errorf(codes.NotFound, "Volume %s is not published at %s", volumeID, volumePath)
+	}
+	if !published && !ns.publishedVolumes.isStaged(volumePath) {
+		return nil, status.Errorf(codes.NotFound, "Volume %s is not published or staged at %s", volumeID, volumePath)
+	}
+
+	condition := ns.volumeCondition(volumePath, vol, published)
+	resp := &csi.NodeGetVolumeStatsResponse{
+		Usage:           volumeUsage(volumePath, condition.Abnormal),
+		VolumeCondition: condition,
+	}
+
+	if !ns.VolumeCondition {
+		resp.VolumeCondition = nil
+	}
+	return resp, nil
+}
+
+// volumeCondition returns the condition of the volume mounted at `volumePath`, where `vol` is the published volume
+// at `volumePath` if `published`, or it's a staging target path otherwise.
+func (ns *S3NodeServer) volumeCondition(volumePath string, vol publishedVolume, published bool) *csi.VolumeCondition {
+	isMountPoint, err := ns.Mounter.IsMountPoint(volumePath)
+	switch {
+	case err != nil && mount.IsCorruptedMnt(err):
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Mountpoint serving the volume is not running anymore: %v", err)}
+	case err != nil && os.IsNotExist(err):
+		return &csi.VolumeCondition{Abnormal: true, Message: "Volume path does not exist"}
+	case err != nil:
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Failed to check the volume is mounted: %v", err)}
+	case !isMountPoint:
+		return &csi.VolumeCondition{Abnormal: true, Message: "Volume is not mounted"}
+	}
+
+	if published && vol.volumeCtx[volumecontext.AuthenticationSource] == mounter.AuthenticationSourcePod {
+		if expiration, ok := mounter.TokenExpiration(vol.volumeCtx); ok && !expiration.IsZero() && time.Now().After(expiration) {
+			return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf(
+				"Service account token of pod-level credentials expired at %s, Mountpoint fails to refresh its credentials until kubelet republishes the volume",
+				expiration.Format(time.RFC3339))}
+		}
+	}
+
+	return &csi.VolumeCondition{Message: "Volume is mounted"}
+}
+
+// volumeUsage returns the usage of the volume mounted at `volumePath` as reported by Mountpoint. Usage is not known
+// for abnormal volumes, which are reported with zero usage as kubelet requires at least one usage.
+func volumeUsage(volumePath string, abnormal bool) []*csi.VolumeUsage {
+	if abnormal {
+		return []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES}}
+	}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(volumePath, &stat); err != nil {
+		klog.V(4).Infof("NodeGetVolumeStats: failed to get usage of %s: %v", volumePath, err)
+		return []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES}}
+	}
+
+	blockSize := int64(stat.Bsize)
+	return []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Total:     int64(stat.Blocks) * blockSize,
+			Available: int64(stat.Bavail) * blockSize,
+			Used:      int64(stat.Blocks-stat.Bfree) * blockSize,
+		},
+		{
+			Unit:      csi.VolumeUsage_INODES,
+			Total:     int64(stat.Files),
+			Available: int64(stat.Ffree),
+			Used:      int64(stat.Files - stat.Ffree),
+		},
+	}
+}
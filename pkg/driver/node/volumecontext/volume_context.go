@@ -3,11 +3,95 @@ package volumecontext
 
 const (
 	BucketName           = "bucketName"
+	BucketType           = "bucketType"
+	Buckets              = "buckets"
+	Prefix               = "prefix"
 	AuthenticationSource = "authenticationSource"
+	AWSProfile           = "awsProfile"
 	STSRegion            = "stsRegion"
+	STSEndpoint          = "stsEndpoint"
+	STSRoleARN           = "stsRoleArn"
+	STSExternalID        = "stsExternalId"
+	ScopedSessionPolicy  = "scopedSessionPolicy"
+	VaultRole            = "vaultRole"
+	VaultMount           = "vaultMount"
+	MountOptions         = "mountOptions"
+	LogLevel             = "logLevel"
+	EndpointURL          = "endpointUrl"
+	ForcePathStyle       = "forcePathStyle"
+	UseFIPSEndpoint      = "useFipsEndpoint"
+	UseDualStackEndpoint = "useDualStackEndpoint"
+	CABundleSecretRef    = "caBundleSecretRef"
+	HTTPProxy            = "httpProxy"
+	HTTPSProxy           = "httpsProxy"
+	NoProxy              = "noProxy"
+	CacheType            = "cacheType"
+	CacheDirSizeLimit    = "cacheDirSizeLimit"
+	CacheStorageClass    = "cacheStorageClassName"
+	CachePVC             = "cachePersistentVolumeClaim"
+	CacheExpressBucket   = "cacheExpressBucket"
+	MetadataTTL          = "metadataTTL"
+	RespectPodFSGroup    = "respectPodFSGroup"
+	MountRetries         = "mountRetries"
+	MountRetryBackoff    = "mountRetryBackoff"
+	MountTimeout         = "mountTimeout"
+	UnmountGracePeriod   = "unmountGracePeriod"
+	SnapshotManifest     = "snapshotManifest"
+	PrefetchPaths        = "prefetchPaths"
+	MaxThroughputGbps    = "maximumThroughputGbps"
+	SSEType              = "sseType"
+	KMSKeyID             = "kmsKeyId"
+	MountpointEnv        = "mountpointEnv"
+
+	FUSEMaxBackground       = "fuseMaxBackground"
+	FUSECongestionThreshold = "fuseCongestionThreshold"
+
+	Mounter                    = "mounter"
+	MountpointImage            = "mountpointImage"
+	MountpointVersion          = "mountpointVersion"
+	MountpointImagePullSecrets = "mountpointImagePullSecrets"
+
+	MountpointPodLabels          = "mountpointPodLabels"
+	MountpointPodAnnotations     = "mountpointPodAnnotations"
+	MountpointPodTolerations     = "mountpointPodTolerations"
+	MountpointPodNodeSelector    = "mountpointPodNodeSelector"
+	MountpointPodNodeAffinity    = "mountpointPodNodeAffinity"
+	MountpointPodSecurityContext = "mountpointPodSecurityContext"
 
 	CSIServiceAccountName   = "csi.storage.k8s.io/serviceAccount.name"
 	CSIServiceAccountTokens = "csi.storage.k8s.io/serviceAccount.tokens"
+	CSIPodName              = "csi.storage.k8s.io/pod.name"
 	CSIPodNamespace         = "csi.storage.k8s.io/pod.namespace"
 	CSIPodUID               = "csi.storage.k8s.io/pod.uid"
+	CSIEphemeral            = "csi.storage.k8s.io/ephemeral"
+)
+
+// MutableAttributes are the volume attributes that can be changed on bound volumes with the parameters of
+// a VolumeAttributesClass, they're applied on the next mount of the volume.
+var MutableAttributes = []string{CacheDirSizeLimit, MetadataTTL, LogLevel}
+
+// Supported values of `bucketType` volume attribute.
+const (
+	BucketTypeGeneralPurpose = "general-purpose"
+	BucketTypeDirectory      = "directory"
+)
+
+// Supported values of `sseType` volume attribute.
+const (
+	SSETypeS3      = "AES256"
+	SSETypeKMS     = "aws:kms"
+	SSETypeKMSDSSE = "aws:kms:dsse"
+)
+
+// Supported values of `mounter` volume attribute.
+const (
+	// MounterSystemd opts the volume out of Mountpoint Pods, it's only mounted by the CSI Driver Node Pod
+	// with its own mounter, e.g. via systemd.
+	MounterSystemd = "systemd"
+)
+
+// Supported values of `cacheType` volume attribute.
+const (
+	CacheTypeEmptyDir  = "emptyDir"
+	CacheTypeEphemeral = "ephemeral"
 )
@@ -0,0 +1,61 @@
+// Package platform provides detection of platforms the CSI Driver supports, which are Linux nodes on
+// architectures Mountpoint is released for.
+package platform
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// OS is the only operating system supported by the CSI Driver.
+const OS = "linux"
+
+// Architectures are the CPU architectures supported by the CSI Driver.
+var Architectures = []string{"amd64", "arm64"}
+
+// Check returns an error describing why the platform with `goos` and `goarch` (in the format of
+// `runtime.GOOS` and `runtime.GOARCH`) is not supported, or nil if it's supported.
+func Check(goos, goarch string) error {
+	if goos != OS {
+		return fmt.Errorf("operating system %q is not supported, only %q is supported", goos, OS)
+	}
+	if !slices.Contains(Architectures, goarch) {
+		return fmt.Errorf("architecture %q is not supported, supported architectures are %v", goarch, Architectures)
+	}
+	return nil
+}
+
+// CheckNode returns an error describing why `node` is not supported from its well-known `kubernetes.io/os` and
+// `kubernetes.io/arch` labels, or nil if it's supported. Nodes without these labels are assumed to be supported.
+func CheckNode(node *corev1.Node) error {
+	goos, ok := node.Labels[corev1.LabelOSStable]
+	if !ok {
+		goos = OS
+	}
+	goarch, ok := node.Labels[corev1.LabelArchStable]
+	if !ok {
+		goarch = Architectures[0]
+	}
+	if err := Check(goos, goarch); err != nil {
+		return fmt.Errorf("node %s is not supported: %w", node.Name, err)
+	}
+	return nil
+}
+
+// CheckRunningOn returns an error if the current process, built for `goos` and `goarch`, is not supported
+// or does not match the platform of `node` (e.g., an `amd64` image running under emulation on an `arm64` node),
+// or nil otherwise.
+func CheckRunningOn(node *corev1.Node, goos, goarch string) error {
+	if err := Check(goos, goarch); err != nil {
+		return err
+	}
+	if err := CheckNode(node); err != nil {
+		return err
+	}
+	if nodeArch, ok := node.Labels[corev1.LabelArchStable]; ok && nodeArch != goarch {
+		return fmt.Errorf("node %s has architecture %q but the CSI Driver is built for %q, please check the image of the CSI Driver is not running under emulation", node.Name, nodeArch, goarch)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package platform_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/platform"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestCheckingPlatforms(t *testing.T) {
+	assert.NoError(t, platform.Check("linux", "amd64"))
+	assert.NoError(t, platform.Check("linux", "arm64"))
+	assert.Equals(t, `operating system "windows" is not supported, only "linux" is supported`, platform.Check("windows", "amd64").Error())
+	assert.Equals(t, `architecture "s390x" is not supported, supported architectures are [amd64 arm64]`, platform.Check("linux", "s390x").Error())
+}
+
+func TestCheckingNodes(t *testing.T) {
+	node := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Labels: labels}}
+	}
+
+	assert.NoError(t, platform.CheckNode(node(nil)))
+	assert.NoError(t, platform.CheckNode(node(map[string]string{corev1.LabelOSStable: "linux", corev1.LabelArchStable: "arm64"})))
+	assert.Equals(t, `node test-node is not supported: operating system "windows" is not supported, only "linux" is supported`,
+		platform.CheckNode(node(map[string]string{corev1.LabelOSStable: "windows"})).Error())
+
+	assert.NoError(t, platform.CheckRunningOn(node(map[string]string{corev1.LabelArchStable: "amd64"}), "linux", "amd64"))
+	assert.NoError(t, platform.CheckRunningOn(node(nil), "linux", "arm64"))
+	assert.Equals(t, `node test-node has architecture "arm64" but the CSI Driver is built for "amd64", please check the image of the CSI Driver is not running under emulation`,
+		platform.CheckRunningOn(node(map[string]string{corev1.LabelArchStable: "arm64"}), "linux", "amd64").Error())
+}
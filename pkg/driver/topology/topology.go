@@ -0,0 +1,55 @@
+// Package topology provides utilities for topology of volumes, which is only constrained for volumes
+// backed by S3 Express One Zone directory buckets as they're located in a single Availability Zone.
+package topology
+
+import (
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// KeyZoneID is the topology key of the Availability Zone ID (e.g., `use1-az4`), which is also the well-known label
+// of nodes with the ID of their Availability Zone.
+//
+// Zone IDs are used rather than zone names (e.g., `us-east-1a`) as zone names are mapped to different physical zones
+// in each AWS account, and directory bucket names contain zone IDs.
+const KeyZoneID = "topology.k8s.aws/zone-id"
+
+// DirectoryBucketSuffix is the suffix of S3 Express One Zone directory bucket names.
+const DirectoryBucketSuffix = "--x-s3"
+
+// DirectoryBucketZoneID returns the Availability Zone ID of directory bucket `bucket` from its name,
+// which is in `<base-name>--<zone-id>--x-s3` format. It returns false if `bucket` is not a directory bucket name.
+func DirectoryBucketZoneID(bucket string) (string, bool) {
+	name, ok := strings.CutSuffix(bucket, DirectoryBucketSuffix)
+	if !ok {
+		return "", false
+	}
+	base, zoneID, ok := strings.Cut(name, "--")
+	if !ok || base == "" || zoneID == "" || strings.Contains(zoneID, "--") {
+		return "", false
+	}
+	return zoneID, true
+}
+
+// ForZone returns the topology of the Availability Zone with `zoneID`.
+func ForZone(zoneID string) *csi.Topology {
+	return &csi.Topology{Segments: map[string]string{KeyZoneID: zoneID}}
+}
+
+// Allows returns whether the Availability Zone with `zoneID` satisfies `requirements`, i.e. it's in one of
+// the requisite topologies, or there are no requisite topologies constraining zones.
+func Allows(requirements *csi.TopologyRequirement, zoneID string) bool {
+	constrained := false
+	for _, requisite := range requirements.GetRequisite() {
+		value, ok := requisite.GetSegments()[KeyZoneID]
+		if !ok {
+			continue
+		}
+		constrained = true
+		if value == zoneID {
+			return true
+		}
+	}
+	return !constrained
+}
@@ -0,0 +1,46 @@
+package topology_test
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/topology"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestDirectoryBucketZoneID(t *testing.T) {
+	for bucket, zoneID := range map[string]string{
+		"cache--use1-az4--x-s3":       "use1-az4",
+		"my-bucket--usw2-az1--x-s3":   "usw2-az1",
+		"general-purpose":             "",
+		"--use1-az4--x-s3":            "",
+		"cache--x-s3":                 "",
+		"cache--use1--az4--x-s3":      "",
+		"cache--use1-az4--x-s3-extra": "",
+	} {
+		t.Run(bucket, func(t *testing.T) {
+			got, ok := topology.DirectoryBucketZoneID(bucket)
+			assert.Equals(t, zoneID, got)
+			assert.Equals(t, zoneID != "", ok)
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	requisite := func(segments ...map[string]string) *csi.TopologyRequirement {
+		requirements := &csi.TopologyRequirement{}
+		for _, s := range segments {
+			requirements.Requisite = append(requirements.Requisite, &csi.Topology{Segments: s})
+		}
+		return requirements
+	}
+
+	assert.Equals(t, true, topology.Allows(nil, "use1-az4"))
+	assert.Equals(t, true, topology.Allows(requisite(map[string]string{"topology.kubernetes.io/zone": "us-east-1a"}), "use1-az4"))
+	assert.Equals(t, true, topology.Allows(requisite(
+		map[string]string{topology.KeyZoneID: "use1-az6"},
+		map[string]string{topology.KeyZoneID: "use1-az4"},
+	), "use1-az4"))
+	assert.Equals(t, false, topology.Allows(requisite(map[string]string{topology.KeyZoneID: "use1-az6"}), "use1-az4"))
+}
@@ -9,14 +9,37 @@ import (
 )
 
 const (
-	ArgForeground      = "--foreground"
-	ArgReadOnly        = "--read-only"
-	ArgAllowOther      = "--allow-other"
-	ArgAllowRoot       = "--allow-root"
-	ArgRegion          = "--region"
-	ArgCache           = "--cache"
-	ArgUserAgentPrefix = "--user-agent-prefix"
-	ArgAWSMaxAttempts  = "--aws-max-attempts"
+	ArgForeground           = "--foreground"
+	ArgReadOnly             = "--read-only"
+	ArgAllowOther           = "--allow-other"
+	ArgAllowRoot            = "--allow-root"
+	ArgRegion               = "--region"
+	ArgPrefix               = "--prefix"
+	ArgCache                = "--cache"
+	ArgCacheXZ              = "--cache-xz"
+	ArgUserAgentPrefix      = "--user-agent-prefix"
+	ArgAWSMaxAttempts       = "--aws-max-attempts"
+	ArgDebug                = "--debug"
+	ArgDebugCRT             = "--debug-crt"
+	ArgNoLog                = "--no-log"
+	ArgLogMetrics           = "--log-metrics"
+	ArgEndpointURL          = "--endpoint-url"
+	ArgForcePathStyle       = "--force-path-style"
+	ArgMaxCacheSize         = "--max-cache-size"
+	ArgMetadataTTL          = "--metadata-ttl"
+	ArgIncrementalUpload    = "--incremental-upload"
+	ArgStorageClass         = "--storage-class"
+	ArgTransferAcceleration = "--transfer-acceleration"
+	ArgFIPS                 = "--fips"
+	ArgDualStack            = "--dual-stack"
+	ArgMaxThroughputGbps    = "--maximum-throughput-gbps"
+	ArgSSE                  = "--sse"
+	ArgSSEKMSKeyID          = "--sse-kms-key-id"
+	ArgConfig               = "--config"
+
+	// FUSE settings are not command-line arguments of Mountpoint, they're passed via environment variables by the mounter.
+	ArgFUSEMaxBackground       = "--fuse-max-background"
+	ArgFUSECongestionThreshold = "--fuse-congestion-threshold"
 )
 
 // An ArgKey represents the key of an argument.
@@ -52,32 +75,10 @@ func ParseArgs(passedArgs []string) Args {
 	args := sets.New[arg]()
 
 	for _, a := range passedArgs {
-		var key, value string
-
-		parts := strings.SplitN(strings.Trim(a, " "), "=", 2)
-		if len(parts) == 2 {
-			// Ex: `--key=value` or `key=value`
-			key, value = parts[0], parts[1]
-		} else {
-			// Ex: `--key value` or `key value`
-			// Ex: `--key` or `key`
-			parts = strings.SplitN(strings.Trim(parts[0], " "), " ", 2)
-			if len(parts) == 1 {
-				// Ex: `--key` or `key`
-				key = parts[0]
-				value = ArgNoValue
-			} else {
-				// Ex: `--key value` or `key value`
-				key, value = parts[0], strings.Trim(parts[1], " ")
-			}
-		}
-
-		// prepend -- if it's not already there
-		key = normalizeKey(key)
+		key, value := parseArg(a)
 
 		// disallow options that don't make sense in CSI
-		switch key {
-		case "--foreground", "-f", "--help", "-h", "--version", "-v":
+		if isIgnoredArg(key) {
 			continue
 		}
 
@@ -87,6 +88,41 @@ func ParseArgs(passedArgs []string) Args {
 	return Args{args}
 }
 
+// parseArg parses given unnormalized argument and returns its normalized key and value.
+func parseArg(a string) (ArgKey, ArgValue) {
+	var key, value string
+
+	parts := strings.SplitN(strings.Trim(a, " "), "=", 2)
+	if len(parts) == 2 {
+		// Ex: `--key=value` or `key=value`
+		key, value = parts[0], parts[1]
+	} else {
+		// Ex: `--key value` or `key value`
+		// Ex: `--key` or `key`
+		parts = strings.SplitN(strings.Trim(parts[0], " "), " ", 2)
+		if len(parts) == 1 {
+			// Ex: `--key` or `key`
+			key = parts[0]
+			value = ArgNoValue
+		} else {
+			// Ex: `--key value` or `key value`
+			key, value = parts[0], strings.Trim(parts[1], " ")
+		}
+	}
+
+	// prepend -- if it's not already there
+	return normalizeKey(key), value
+}
+
+// isIgnoredArg returns whether given key is an option that doesn't make sense in CSI, and ignored while parsing.
+func isIgnoredArg(key ArgKey) bool {
+	switch key {
+	case "--foreground", "-f", "--help", "-h", "--version", "-v":
+		return true
+	}
+	return false
+}
+
 // Set sets or replaces value of given key.
 func (a *Args) Set(key ArgKey, value ArgValue) {
 	key = normalizeKey(key)
@@ -125,6 +161,16 @@ func (a *Args) SortedList() []string {
 	return args
 }
 
+// Keys returns ordered list of normalized keys of the arguments.
+func (a *Args) Keys() []ArgKey {
+	keys := make([]ArgKey, 0, a.args.Len())
+	for _, arg := range a.args.UnsortedList() {
+		keys = append(keys, arg.key)
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
 // find tries to find given key from [Args], and returns whole entry, and whether the key was found.
 func (a *Args) find(key ArgKey) (arg, bool) {
 	key = normalizeKey(key)
@@ -414,3 +414,52 @@ func TestCreatingMountpointArgsFromAlreadyParsedArgs(t *testing.T) {
 	parsedArgs := mountpoint.ParseArgs(args.SortedList())
 	assert.Equals(t, want, parsedArgs.SortedList())
 }
+
+func TestSettingLogLevelOfMountpointArgs(t *testing.T) {
+	testCases := []struct {
+		level string
+		want  []string
+	}{
+		{level: mountpoint.LogLevelOff, want: []string{"--no-log"}},
+		{level: mountpoint.LogLevelInfo, want: []string{}},
+		{level: mountpoint.LogLevelDebug, want: []string{"--debug"}},
+		{level: mountpoint.LogLevelTrace, want: []string{"--debug", "--debug-crt"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.level, func(t *testing.T) {
+			args := mountpoint.ParseArgs(nil)
+			assert.NoError(t, args.SetLogLevel(tc.level))
+			assert.Equals(t, tc.want, args.SortedList())
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		args := mountpoint.ParseArgs(nil)
+		if err := args.SetLogLevel("verbose"); err == nil {
+			t.Fatal("expected an error for unsupported log level")
+		}
+	})
+}
+
+func TestRenderingMountpointArgsAsConfig(t *testing.T) {
+	args := mountpoint.ParseArgs([]string{
+		"--allow-other",
+		"--cache /tmp/s3-cache",
+		"region=us-west-2",
+		`prefix=dir with "quotes"\`,
+		"--cache-xz=/tmp/b",
+		"--cache-xz=/tmp/a",
+	})
+	args.Set("--user-agent-prefix", "s3-csi-driver/1.11.0 credential-source#pod k8s/v1.30.6-eks-7f9249a")
+
+	assert.Equals(t, `allow-other = true
+cache = "/tmp/s3-cache"
+cache-xz = ["/tmp/a", "/tmp/b"]
+prefix = "dir with \"quotes\"\\"
+region = "us-west-2"
+user-agent-prefix = "s3-csi-driver/1.11.0 credential-source#pod k8s/v1.30.6-eks-7f9249a"
+`, string(args.Config()))
+
+	empty := mountpoint.ParseArgs(nil)
+	assert.Equals(t, "", string(empty.Config()))
+}
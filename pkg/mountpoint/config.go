@@ -0,0 +1,64 @@
+package mountpoint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Config renders the arguments as a Mountpoint configuration file in TOML format, to pass via [ArgConfig]
+// instead of command-line arguments.
+//
+// Each argument is rendered as a key without the "--" prefix, options without any value are rendered
+// as `true`, and keys specified multiple times are rendered as arrays. Values are always quoted,
+// so values with whitespace or quotes are passed to Mountpoint as they are.
+func (a *Args) Config() []byte {
+	values := map[ArgKey][]ArgValue{}
+	for _, arg := range a.args.UnsortedList() {
+		values[arg.key] = append(values[arg.key], arg.value)
+	}
+
+	var b strings.Builder
+	for _, key := range a.Keys() {
+		vals := values[key]
+		slices.Sort(vals)
+		fmt.Fprintf(&b, "%s = ", strings.TrimLeft(key, "-"))
+		switch {
+		case len(vals) == 1 && vals[0] == ArgNoValue:
+			b.WriteString("true")
+		case len(vals) == 1:
+			b.WriteString(quoteTOML(vals[0]))
+		default:
+			quoted := make([]string, 0, len(vals))
+			for _, v := range vals {
+				quoted = append(quoted, quoteTOML(v))
+			}
+			fmt.Fprintf(&b, "[%s]", strings.Join(quoted, ", "))
+		}
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// quoteTOML returns `s` as a TOML basic string.
+func quoteTOML(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package mountpoint
+
+import "fmt"
+
+// Log levels that can be configured for Mountpoint.
+const (
+	LogLevelOff   = "off"
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+	LogLevelTrace = "trace"
+)
+
+// SetLogLevel sets the arguments to configure Mountpoint's log verbosity to given `level`.
+//
+// `debug` enables Mountpoint's debug logs, and `trace` additionally enables debug logs of the AWS Common Runtime.
+// `info` is Mountpoint's default log level and does not set any arguments.
+func (a *Args) SetLogLevel(level string) error {
+	switch level {
+	case LogLevelOff:
+		a.Set(ArgNoLog, ArgNoValue)
+	case LogLevelInfo:
+	case LogLevelDebug:
+		a.Set(ArgDebug, ArgNoValue)
+	case LogLevelTrace:
+		a.Set(ArgDebug, ArgNoValue)
+		a.Set(ArgDebugCRT, ArgNoValue)
+	default:
+		return fmt.Errorf("unsupported log level %q, supported log levels are %q, %q, %q and %q",
+			level, LogLevelOff, LogLevelInfo, LogLevelDebug, LogLevelTrace)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mountpoint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Arguments with numeric values validated by [ValidateArgs].
+const (
+	ArgUID      = "--uid"
+	ArgGID      = "--gid"
+	ArgDirMode  = "--dir-mode"
+	ArgFileMode = "--file-mode"
+)
+
+// Arguments enabling writes that conflict with [ArgReadOnly], validated by [ValidateArgs] and [Args.ValidateReadOnly].
+const (
+	ArgAllowDelete    = "--allow-delete"
+	ArgAllowOverwrite = "--allow-overwrite"
+)
+
+// writeArgs are the arguments enabling writes, which have no effect in read-only mounts.
+var writeArgs = []ArgKey{ArgAllowDelete, ArgAllowOverwrite, ArgIncrementalUpload}
+
+// managedArgs are the arguments set by the CSI Driver, and passing them via mount options has no effect.
+var managedArgs = []ArgKey{ArgUserAgentPrefix, ArgConfig}
+
+// ValidateArgs validates given list of unnormalized arguments passed as mount options,
+// and returns an error describing all problems found.
+//
+// It rejects options that are ignored or overridden by the CSI Driver, options specified multiple times
+// with conflicting values, options enabling writes along with `--read-only`, and malformed values for ownership
+// and permission options.
+// Unknown options are not rejected as they might be supported by newer Mountpoint versions.
+func ValidateArgs(passedArgs []string) error {
+	var errs []error
+	seen := make(map[ArgKey]ArgValue)
+
+	for _, a := range passedArgs {
+		key, value := parseArg(a)
+
+		if isIgnoredArg(key) {
+			errs = append(errs, fmt.Errorf("%s is not supported", key))
+			continue
+		}
+
+		for _, managed := range managedArgs {
+			if key == managed {
+				errs = append(errs, fmt.Errorf("%s is managed by the CSI Driver and cannot be specified", key))
+			}
+		}
+
+		if previous, ok := seen[key]; ok && previous != value {
+			errs = append(errs, fmt.Errorf("%s is specified multiple times with conflicting values %q and %q", key, previous, value))
+		}
+		seen[key] = value
+
+		switch key {
+		case ArgUID, ArgGID:
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a non-negative integer, got %q", key, value))
+			}
+		case ArgDirMode, ArgFileMode:
+			if mode, err := strconv.ParseUint(value, 8, 32); err != nil || mode > 0777 {
+				errs = append(errs, fmt.Errorf("%s must be an octal permission between 0000 and 0777, got %q", key, value))
+			}
+		}
+	}
+
+	if _, ok := seen[ArgReadOnly]; ok {
+		for _, key := range writeArgs {
+			if _, ok := seen[key]; ok {
+				errs = append(errs, fmt.Errorf("%s cannot be used with %s", key, ArgReadOnly))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateReadOnly returns an error if `a` makes the mount read-only with [ArgReadOnly], but also contains
+// arguments enabling writes, e.g. `--allow-delete`, which would give the false impression that writes are allowed.
+func (a *Args) ValidateReadOnly() error {
+	if !a.Has(ArgReadOnly) {
+		return nil
+	}
+	var conflicting []string
+	for _, key := range writeArgs {
+		if a.Has(key) {
+			conflicting = append(conflicting, key)
+		}
+	}
+	if len(conflicting) > 0 {
+		return fmt.Errorf("%s cannot be used in read-only mounts", strings.Join(conflicting, ", "))
+	}
+	return nil
+}
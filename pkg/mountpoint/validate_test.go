@@ -0,0 +1,64 @@
+package mountpoint_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/mountpoint"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestValidatingMountpointArgs(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		for _, args := range [][]string{
+			nil,
+			{"allow-delete", "region us-west-2", "uid=1000", "gid=2000", "dir-mode=0755", "file-mode=644"},
+			{"uid=1000", "--uid 1000"},
+			{"--some-future-option"},
+		} {
+			assert.NoError(t, mountpoint.ValidateArgs(args))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, args := range map[string][]string{
+			"ignored option":        {"foreground"},
+			"managed option":        {"user-agent-prefix=foo"},
+			"config file":           {"config=/etc/passwd"},
+			"conflicting uid":       {"uid=1000", "uid=2000"},
+			"conflicting region":    {"region us-west-2", "--region=eu-west-1"},
+			"non-numeric gid":       {"gid=admin"},
+			"negative uid":          {"uid=-1"},
+			"non-octal file mode":   {"file-mode=0999"},
+			"out of range dir mode": {"dir-mode=7777"},
+			"read-only and delete":  {"read-only", "allow-delete"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				if err := mountpoint.ValidateArgs(args); err == nil {
+					t.Fatalf("Expected an error for %v", args)
+				}
+			})
+		}
+	})
+}
+
+func TestValidatingReadOnlyArgs(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"allow-delete", "allow-overwrite", "incremental-upload"},
+		{"read-only", "uid=1000"},
+	} {
+		parsed := mountpoint.ParseArgs(args)
+		assert.NoError(t, parsed.ValidateReadOnly())
+	}
+
+	for _, args := range [][]string{
+		{"read-only", "allow-delete"},
+		{"--read-only", "--allow-overwrite"},
+		{"read-only", "incremental-upload"},
+	} {
+		parsed := mountpoint.ParseArgs(args)
+		if err := parsed.ValidateReadOnly(); err == nil {
+			t.Fatalf("Expected an error for %v", args)
+		}
+	}
+}
@@ -18,16 +18,47 @@ import (
 type Options struct {
 	// Fd will be passed over Unix socket using `SCM_RIGHTS`, not as part of the serialized JSON.
 	Fd         int      `json:"-"`
+	VolumeID   string   `json:"volumeID"`
 	BucketName string   `json:"bucketName"`
 	Args       []string `json:"args"`
 	Env        []string `json:"env"`
 }
 
+// ProtocolVersion is the version of the protocol used to pass mount options between the CSI Driver Node Pod
+// and Mountpoint Pods. The version 1 had no version field, and is assumed if the field is missing.
+//
+// The CSI Driver Node Pod and Mountpoint Pods might be running different versions during upgrades, so the protocol
+// is only extended in backward compatible ways: new fields must be safe to ignore by older receivers, and messages
+// that cannot be handled without a new feature must list it in `features` to be rejected by receivers not supporting it.
+const ProtocolVersion = 2
+
+// A Feature represents an optional feature of the protocol that a message requires the receiver to support.
+type Feature = string
+
+// supportedFeatures are the features supported by this receiver. A message requiring any other feature is rejected
+// by `Recv` instead of being handled partially. Features added in later protocol versions are registered here.
+var supportedFeatures = map[Feature]bool{}
+
+// An envelope represents the message sent over the Unix socket, mount options with the protocol metadata.
+// It's serialized as a single JSON object, with the fields of [Options] at the top-level as in the version 1.
+// Messages are framed by the connection, as each connection carries a single message until EOF.
+type envelope struct {
+	Version  int       `json:"version,omitempty"`
+	Features []Feature `json:"features,omitempty"`
+	Options
+}
+
 // Send sends given mount `options` to given `sockPath` to be received by `Recv` function on the other end.
 func Send(ctx context.Context, sockPath string, options Options) error {
+	return sendMessage(ctx, sockPath, envelope{Version: ProtocolVersion, Options: options})
+}
+
+// sendMessage sends given `msg` to given `sockPath`, passing the file descriptor of its options using `SCM_RIGHTS`.
+func sendMessage(ctx context.Context, sockPath string, msg envelope) error {
 	warnAboutLongUnixSocketPath(sockPath)
+	options := msg.Options
 
-	message, err := json.Marshal(&options)
+	message, err := json.Marshal(&msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message to send %s: %w", sockPath, err)
 	}
@@ -116,11 +147,15 @@ func Recv(ctx context.Context, sockPath string) (Options, error) {
 		unixRightsBuf = append(unixRightsBuf, unixRights[:unixRightsN]...)
 	}
 
-	var options Options
-	err = json.Unmarshal(messageBuf, &options)
+	var msg envelope
+	err = json.Unmarshal(messageBuf, &msg)
 	if err != nil {
 		return Options{}, fmt.Errorf("failed to decode mount options from unix socket %s: %w", sockPath, err)
 	}
+	if err := checkCompatibility(msg); err != nil {
+		return Options{}, fmt.Errorf("incompatible mount options from unix socket %s: %w", sockPath, err)
+	}
+	options := msg.Options
 
 	fds, err := parseUnixRights(unixRightsBuf)
 	if err != nil {
@@ -135,6 +170,30 @@ func Recv(ctx context.Context, sockPath string) (Options, error) {
 	return options, nil
 }
 
+// checkCompatibility returns an error if `msg` requires features not supported by this receiver.
+// Messages from older senders are always compatible, and messages from newer senders are compatible
+// as long as they don't require any unsupported features.
+func checkCompatibility(msg envelope) error {
+	version := msg.Version
+	if version == 0 {
+		version = 1
+	}
+	if version != ProtocolVersion {
+		klog.Infof("Received mount options with protocol version %d, this receiver uses version %d", version, ProtocolVersion)
+	}
+
+	var unsupported []Feature
+	for _, feature := range msg.Features {
+		if !supportedFeatures[feature] {
+			unsupported = append(unsupported, feature)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("sender with protocol version %d requires unsupported features %v, Mountpoint Pod might need to be upgraded", version, unsupported)
+	}
+	return nil
+}
+
 // parseUnixRights parses given socket control message to extract passed file descriptors.
 func parseUnixRights(buf []byte) ([]int, error) {
 	socketControlMessages, err := syscall.ParseSocketControlMessage(buf)
@@ -2,6 +2,7 @@ package mountoptions_test
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -63,6 +64,76 @@ func TestSendingAndReceivingMountOptions(t *testing.T) {
 	assert.Equals(t, want, got)
 }
 
+func TestMountOptionsProtocolCompatibility(t *testing.T) {
+	file, err := os.Open(os.DevNull)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	testCases := []struct {
+		name       string
+		message    string
+		compatible bool
+	}{
+		{
+			name:       "version 1 without version field",
+			message:    `{"volumeID": "test-vol", "bucketName": "test-bucket", "args": ["--read-only"], "env": null}`,
+			compatible: true,
+		},
+		{
+			name:       "newer version with unknown fields",
+			message:    `{"version": 99, "volumeID": "test-vol", "bucketName": "test-bucket", "args": ["--read-only"], "env": null, "newField": true}`,
+			compatible: true,
+		},
+		{
+			name:       "newer version requiring unsupported features",
+			message:    `{"version": 99, "features": ["future-feature"], "volumeID": "test-vol", "bucketName": "test-bucket"}`,
+			compatible: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mountSock := filepath.Join(t.TempDir(), "m")
+
+			type result struct {
+				options mountoptions.Options
+				err     error
+			}
+			c := make(chan result)
+			go func() {
+				options, err := mountoptions.Recv(defaultContext(t), mountSock)
+				c <- result{options, err}
+			}()
+
+			err = util.WaitForUnixSocket(defaultTimeout, 500*time.Millisecond, mountSock)
+			assert.NoError(t, err)
+			sendRawMessage(t, mountSock, tc.message, int(file.Fd()))
+
+			got := <-c
+			if !tc.compatible {
+				if got.err == nil {
+					t.Fatalf("Expected an error for incompatible message %s", tc.message)
+				}
+				return
+			}
+			assert.NoError(t, got.err)
+			assert.Equals(t, "test-vol", got.options.VolumeID)
+			assert.Equals(t, "test-bucket", got.options.BucketName)
+			assert.Equals(t, []string{"--read-only"}, got.options.Args)
+		})
+	}
+}
+
+// sendRawMessage sends `message` as-is with `fd` to `sockPath`, as an older or newer sender would.
+func sendRawMessage(t *testing.T, sockPath string, message string, fd int) {
+	t.Helper()
+	conn, err := net.Dial("unix", sockPath)
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, _, err = conn.(*net.UnixConn).WriteMsgUnix([]byte(message), syscall.UnixRights(fd), nil)
+	assert.NoError(t, err)
+}
+
 const defaultTimeout = 10 * time.Second
 
 func defaultContext(t *testing.T) context.Context {
@@ -1,11 +1,23 @@
 package mppod
 
 import (
+	"cmp"
+	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
 )
 
 // Labels populated on spawned Mountpoint Pods.
@@ -16,11 +28,71 @@ const (
 	LabelCSIDriverVersion  = "s3.csi.aws.com/mounted-by-csi-driver-version"
 )
 
+// Annotations populated on Mountpoint Pods by the controller if resource recommendations are enabled.
+// Peak values are the highest usage observed, and recommendations add a safety margin on top of them.
+const (
+	AnnotationPeakCPU           = "s3.csi.aws.com/peak-cpu"
+	AnnotationPeakMemory        = "s3.csi.aws.com/peak-memory"
+	AnnotationRecommendedCPU    = "s3.csi.aws.com/recommended-cpu"
+	AnnotationRecommendedMemory = "s3.csi.aws.com/recommended-memory"
+)
+
+// AnnotationWorkloadPod is populated on Mountpoint Pods with the namespace and name of the workload Pod
+// they provide a volume for, in `namespace/name` format.
+const AnnotationWorkloadPod = "s3.csi.aws.com/workload-pod"
+
+// Conditions populated on Mountpoint Pods by the controller to describe their attachment to their workload Pods.
+// Readiness of Mountpoint Pods themselves is described by their `Ready` condition.
+const (
+	// ConditionWorkloadAttached is true while the workload Pod of a Mountpoint Pod is active.
+	ConditionWorkloadAttached corev1.PodConditionType = "s3.csi.aws.com/WorkloadAttached"
+	// ConditionUnmountPending is true while a Mountpoint Pod is running after its workload Pod terminated,
+	// i.e., it's waiting for kubelet to unmount the volume so Mountpoint can exit.
+	ConditionUnmountPending corev1.PodConditionType = "s3.csi.aws.com/UnmountPending"
+)
+
+// AnnotationRestartCount is populated on Mountpoint Pods respawned by the controller after a failure,
+// with the number of times the Mountpoint Pod has been restarted for the same workload Pod and volume.
+const AnnotationRestartCount = "s3.csi.aws.com/restart-count"
+
+// AnnotationUpgradeAvailable is populated on running Mountpoint Pods by the controller with the version of Mountpoint
+// that Mountpoint Pods newly spawned for the same volume would run, if it's different from theirs.
+const AnnotationUpgradeAvailable = "s3.csi.aws.com/upgrade-available"
+
+// AnnotationMaxThroughputGbps is populated on Mountpoint Pods with the throughput target of Mountpoint in Gbps,
+// if it's configured for their volume with `maximumThroughputGbps` volume attribute.
+const AnnotationMaxThroughputGbps = "s3.csi.aws.com/maximum-throughput-gbps"
+
+// AnnotationVolumeAttributesClass is populated on Mountpoint Pods with the name of the VolumeAttributesClass
+// of their volume at the time they're spawned, whose parameters they're running with.
+// Changing the class of a volume only affects Mountpoint Pods spawned afterwards.
+const AnnotationVolumeAttributesClass = "s3.csi.aws.com/volume-attributes-class"
+
+// CABundleSecretKey is the key of the CA bundle in the Secret referenced by `caBundleSecretRef` volume attribute.
+const CABundleSecretKey = "ca.crt"
+
+// CABundlePath is the path the CA bundle is mounted at in Mountpoint Pods,
+// which is the path of the system trust store in the Mountpoint image.
+const CABundlePath = "/etc/pki/tls/certs/ca-bundle.crt"
+
+const caBundleVolumeName = "ca-bundle"
+
+// CacheDirPath is the path the cache volume is mounted at in Mountpoint Pods.
+const CacheDirPath = "/cache"
+
+const cacheVolumeName = "cache"
+
+// MetricsPortName is the name of the container port Mountpoint Pods expose metrics of Mountpoint on, if enabled.
+const MetricsPortName = "metrics"
+
 // A ContainerConfig represents configuration for containers in the spawned Mountpoint Pods.
 type ContainerConfig struct {
 	Command         string
 	Image           string
 	ImagePullPolicy corev1.PullPolicy
+	// ImagePullSecrets are names of the Secrets in the namespace of Mountpoint Pods to pull the image with,
+	// they can be overridden per volume with `mountpointImagePullSecrets`.
+	ImagePullSecrets []string
 }
 
 // A Config represents configuration for spawned Mountpoint Pods.
@@ -29,11 +101,37 @@ type Config struct {
 	MountpointVersion string
 	Container         ContainerConfig
 	CSIDriverVersion  string
+	// NodeSelector is added to Mountpoint Pods in addition to the constraint to schedule them into the same node
+	// as their workload Pods, which makes Mountpoint Pods unschedulable on nodes not matching it.
+	NodeSelector map[string]string
+	// MountTimeout is the timeout for Mountpoint Pods to receive mount options from the CSI Driver Node Pod,
+	// the default of `aws-s3-csi-mounter` is used if it's zero. It can be overridden per volume with `mountTimeout`.
+	MountTimeout time.Duration
+	// SecurityContext is overlaid onto the security context of Mountpoint containers, which drops all capabilities
+	// and disallows privilege escalation by default. It can be overridden per volume with `mountpointPodSecurityContext`.
+	SecurityContext *corev1.SecurityContext
+	// MetricsPort is the port Mountpoint Pods expose metrics of Mountpoint on in Prometheus format,
+	// metrics are not exposed if it's zero.
+	MetricsPort int32
+	// Proxy is the proxy settings as environment variables (e.g., `HTTPS_PROXY`) to set in Mountpoint containers,
+	// they can be overridden per volume with `httpProxy`, `httpsProxy` and `noProxy`.
+	Proxy envprovider.Environment
+	// CABundleSecretRef is the name of the Secret with the CA bundle to mount into Mountpoint Pods, e.g. to trust
+	// a TLS-intercepting proxy. It can be overridden per volume with `caBundleSecretRef`.
+	CABundleSecretRef string
+	// PriorityClassName is the priority class of Mountpoint Pods whose workload Pods' priority class is not mapped
+	// in PriorityClasses, the default priority of the cluster is used if it's empty.
+	PriorityClassName string
+	// PriorityClasses maps priority classes of workload Pods to the priority classes of their Mountpoint Pods,
+	// e.g. to never preempt Mountpoint Pods of critical workloads before the ones of batch jobs.
+	PriorityClasses map[string]string
 }
 
 // A Creator allows creating specification for Mountpoint Pods to schedule.
 type Creator struct {
 	config Config
+	// resources is the resources of Mountpoint containers, it can be updated while creating Mountpoint Pods concurrently.
+	resources atomic.Pointer[corev1.ResourceRequirements]
 }
 
 // NewCreator creates a new creator with the given `config`.
@@ -41,24 +139,37 @@ func NewCreator(config Config) *Creator {
 	return &Creator{config: config}
 }
 
-// Create returns a new Mountpoint Pod spec to schedule for given `pod` and `pvc`.
+// SetResources sets resources of the containers in Mountpoint Pods created after this call.
+func (c *Creator) SetResources(resources corev1.ResourceRequirements) {
+	c.resources.Store(&resources)
+}
+
+// Resources returns resources of the containers in Mountpoint Pods, or nil if they're not set.
+func (c *Creator) Resources() *corev1.ResourceRequirements {
+	return c.resources.Load()
+}
+
+// Create returns a new Mountpoint Pod spec to schedule for given `pod` and `pv`.
 //
 // It automatically assigns Mountpoint Pod to `pod`'s node.
-// The name of the Mountpoint Pod is consistently generated from `pod` and `pvc` using `MountpointPodNameFor` function.
-func (c *Creator) Create(pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim) *corev1.Pod {
+// The name of the Mountpoint Pod is consistently generated from `pod` and `pv` using `MountpointPodNameFor` function.
+func (c *Creator) Create(pod *corev1.Pod, pv *corev1.PersistentVolume) *corev1.Pod {
 	node := pod.Spec.NodeName
-	name := MountpointPodNameFor(string(pod.UID), pvc.Spec.VolumeName)
+	name := MountpointPodNameFor(string(pod.UID), pv.Name)
 
-	return &corev1.Pod{
+	mpPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: c.config.Namespace,
 			Labels: map[string]string{
 				LabelMountpointVersion: c.config.MountpointVersion,
 				LabelPodUID:            string(pod.UID),
-				LabelVolumeName:        pvc.Spec.VolumeName,
+				LabelVolumeName:        pv.Name,
 				LabelCSIDriverVersion:  c.config.CSIDriverVersion,
 			},
+			Annotations: map[string]string{
+				AnnotationWorkloadPod: pod.Namespace + "/" + pod.Name,
+			},
 		},
 		Spec: corev1.PodSpec{
 			// Mountpoint terminates with zero exit code on a successful termination,
@@ -111,4 +222,198 @@ func (c *Creator) Create(pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim) *co
 			},
 		},
 	}
+
+	setImagePullSecrets(mpPod, c.config.Container.ImagePullSecrets)
+	setPriorityClass(mpPod, pod, c.config.PriorityClassName, c.config.PriorityClasses)
+	setSecurityContext(mpPod, c.config.SecurityContext)
+
+	if c.config.MountTimeout > 0 {
+		setMountTimeout(mpPod, c.config.MountTimeout)
+	}
+
+	if c.config.MetricsPort > 0 {
+		exposeMetrics(mpPod, c.config.MetricsPort)
+	}
+
+	if len(c.config.NodeSelector) > 0 {
+		mpPod.Spec.NodeSelector = maps.Clone(c.config.NodeSelector)
+	}
+
+	if resources := c.resources.Load(); resources != nil {
+		mpPod.Spec.Containers[0].Resources = *resources
+	}
+
+	if className := pv.Spec.VolumeAttributesClassName; className != nil && *className != "" {
+		mpPod.Annotations[AnnotationVolumeAttributesClass] = *className
+	}
+
+	var volumeAttributes map[string]string
+	if pv.Spec.CSI != nil {
+		volumeAttributes = pv.Spec.CSI.VolumeAttributes
+	}
+	setProxy(mpPod, c.config.Proxy, volumeAttributes)
+	if caBundleSecretRef := cmp.Or(volumeAttributes[volumecontext.CABundleSecretRef], c.config.CABundleSecretRef); caBundleSecretRef != "" {
+		addCABundle(mpPod, caBundleSecretRef)
+	}
+
+	if pv.Spec.CSI != nil {
+		if claimName := pv.Spec.CSI.VolumeAttributes[volumecontext.CachePVC]; claimName != "" {
+			addCacheClaim(mpPod, claimName)
+		} else if cacheType := pv.Spec.CSI.VolumeAttributes[volumecontext.CacheType]; cacheType != "" {
+			addCache(mpPod, cacheType, pv.Spec.CSI.VolumeAttributes)
+		}
+		if mountTimeout, err := parseMountTimeout(pv.Spec.CSI.VolumeAttributes[volumecontext.MountTimeout]); err == nil && mountTimeout > 0 {
+			setMountTimeout(mpPod, mountTimeout)
+		}
+		if gbps, err := strconv.ParseUint(pv.Spec.CSI.VolumeAttributes[volumecontext.MaxThroughputGbps], 10, 64); err == nil && gbps > 0 {
+			mpPod.Annotations[AnnotationMaxThroughputGbps] = strconv.FormatUint(gbps, 10)
+		}
+		addOverrides(mpPod, pv.Spec.CSI.VolumeAttributes)
+		pinMountpointVersion(mpPod, pv.Spec.CSI.VolumeAttributes)
+		if secrets, err := ParseImagePullSecrets(pv.Spec.CSI.VolumeAttributes[volumecontext.MountpointImagePullSecrets]); err == nil && len(secrets) > 0 {
+			setImagePullSecrets(mpPod, secrets)
+		}
+	}
+
+	return mpPod
+}
+
+// MountpointVersionFor returns the version of Mountpoint that Mountpoint Pods created for `pv` run,
+// which is the version pinned with volume attributes if any, or the configured version otherwise.
+func (c *Creator) MountpointVersionFor(pv *corev1.PersistentVolume) string {
+	if pv.Spec.CSI != nil {
+		attributes := pv.Spec.CSI.VolumeAttributes
+		if validateMountpointVersion(attributes) == nil && attributes[volumecontext.MountpointImage] != "" {
+			return attributes[volumecontext.MountpointVersion]
+		}
+	}
+	return c.config.MountpointVersion
+}
+
+// addCABundle mounts the CA bundle in the Secret named `secretName` into `mpPod` as the trust store of Mountpoint.
+// The Secret must be in the same namespace as the Mountpoint Pod and contain the CA bundle in PEM format at [CABundleSecretKey].
+//
+// Mountpoint does not allow configuring a custom CA bundle, but it loads the CA bundle from well-known paths,
+// so the Secret is mounted at [CABundlePath] to replace the trust store of the Mountpoint image.
+func addCABundle(mpPod *corev1.Pod, secretName string) {
+	mpPod.Spec.Volumes = append(mpPod.Spec.Volumes, corev1.Volume{
+		Name: caBundleVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+				Items:      []corev1.KeyToPath{{Key: CABundleSecretKey, Path: CABundleSecretKey}},
+			},
+		},
+	})
+	mpPod.Spec.Containers[0].VolumeMounts = append(mpPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      caBundleVolumeName,
+		MountPath: CABundlePath,
+		SubPath:   CABundleSecretKey,
+		ReadOnly:  true,
+	})
+}
+
+// addCache adds a volume of `cacheType` to `mpPod` to use as the local cache directory of Mountpoint.
+// The volume is sized using `cacheDirSizeLimit` volume attribute if specified.
+//
+// The volume attributes are validated by the CSI Driver Node Pod before mounting,
+// invalid size limits are ignored here as the mount would fail anyway.
+func addCache(mpPod *corev1.Pod, cacheType string, volumeAttributes map[string]string) {
+	var sizeLimit *resource.Quantity
+	if limit, err := resource.ParseQuantity(volumeAttributes[volumecontext.CacheDirSizeLimit]); err == nil {
+		sizeLimit = &limit
+	}
+
+	var source corev1.VolumeSource
+	switch cacheType {
+	case volumecontext.CacheTypeEmptyDir:
+		source.EmptyDir = &corev1.EmptyDirVolumeSource{SizeLimit: sizeLimit}
+	case volumecontext.CacheTypeEphemeral:
+		spec := corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		}
+		if sizeLimit != nil {
+			spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: *sizeLimit}
+		}
+		if storageClass := volumeAttributes[volumecontext.CacheStorageClass]; storageClass != "" {
+			spec.StorageClassName = &storageClass
+		}
+		source.Ephemeral = &corev1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{Spec: spec},
+		}
+	default:
+		return
+	}
+
+	mpPod.Spec.Volumes = append(mpPod.Spec.Volumes, corev1.Volume{
+		Name:         cacheVolumeName,
+		VolumeSource: source,
+	})
+	mpPod.Spec.Containers[0].VolumeMounts = append(mpPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      cacheVolumeName,
+		MountPath: CacheDirPath,
+	})
+}
+
+// addCacheClaim mounts the PersistentVolumeClaim named `claimName` into `mpPod` to use as the local cache directory of Mountpoint.
+// The claim must be in the same namespace as the Mountpoint Pod, and it might be shared by multiple Mountpoint Pods
+// in the same node, so each Mountpoint Pod uses a sub-directory named after itself, which is kept across restarts of Mountpoint.
+func addCacheClaim(mpPod *corev1.Pod, claimName string) {
+	mpPod.Spec.Volumes = append(mpPod.Spec.Volumes, corev1.Volume{
+		Name: cacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+		},
+	})
+	mpPod.Spec.Containers[0].VolumeMounts = append(mpPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      cacheVolumeName,
+		MountPath: CacheDirPath,
+		SubPath:   mpPod.Name,
+	})
+}
+
+// setImagePullSecrets sets image pull secrets of `mpPod` to the Secrets named `secrets`, replacing the existing ones.
+func setImagePullSecrets(mpPod *corev1.Pod, secrets []string) {
+	mpPod.Spec.ImagePullSecrets = nil
+	for _, name := range secrets {
+		mpPod.Spec.ImagePullSecrets = append(mpPod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+}
+
+// setMountTimeout sets the timeout for `aws-s3-csi-mounter` in `mpPod` to receive mount options.
+func setMountTimeout(mpPod *corev1.Pod, timeout time.Duration) {
+	setArg(mpPod, "--mount-sock-recv-timeout", timeout.String())
+}
+
+// exposeMetrics configures `aws-s3-csi-mounter` in `mpPod` to expose metrics of Mountpoint on `port`.
+func exposeMetrics(mpPod *corev1.Pod, port int32) {
+	setArg(mpPod, "--metrics-address", ":"+strconv.Itoa(int(port)))
+	mpPod.Spec.Containers[0].Ports = append(mpPod.Spec.Containers[0].Ports, corev1.ContainerPort{
+		Name:          MetricsPortName,
+		ContainerPort: port,
+		Protocol:      corev1.ProtocolTCP,
+	})
+}
+
+// setArg sets `flag` of `aws-s3-csi-mounter` in `mpPod` to `value`, replacing the existing value if any.
+func setArg(mpPod *corev1.Pod, flag, value string) {
+	container := &mpPod.Spec.Containers[0]
+	arg := flag + "=" + value
+	if i := slices.IndexFunc(container.Args, func(a string) bool { return strings.HasPrefix(a, flag+"=") }); i >= 0 {
+		container.Args[i] = arg
+		return
+	}
+	container.Args = append(container.Args, arg)
+}
+
+// parseMountTimeout parses `attr` as a positive duration, e.g. `5m`.
+func parseMountTimeout(attr string) (time.Duration, error) {
+	if attr == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(attr)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("must be a positive duration, e.g. \"5m\", got %q", attr)
+	}
+	return timeout, nil
 }
@@ -2,11 +2,15 @@ package mppod_test
 
 import (
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
 )
@@ -39,14 +43,16 @@ func TestCreatingMountpointPods(t *testing.T) {
 
 	mpPod := creator.Create(&corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			UID: types.UID(testPodUID),
+			Name:      "test-pod",
+			Namespace: "test-ns",
+			UID:       types.UID(testPodUID),
 		},
 		Spec: corev1.PodSpec{
 			NodeName: testNode,
 		},
-	}, &corev1.PersistentVolumeClaim{
-		Spec: corev1.PersistentVolumeClaimSpec{
-			VolumeName: testVolName,
+	}, &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: testVolName,
 		},
 	})
 
@@ -59,6 +65,9 @@ func TestCreatingMountpointPods(t *testing.T) {
 		mppod.LabelVolumeName:        testVolName,
 		mppod.LabelCSIDriverVersion:  csiDriverVersion,
 	}, mpPod.Labels)
+	assert.Equals(t, map[string]string{
+		mppod.AnnotationWorkloadPod: "test-ns/test-pod",
+	}, mpPod.Annotations)
 
 	assert.Equals(t, corev1.RestartPolicyOnFailure, mpPod.Spec.RestartPolicy)
 	assert.Equals(t, []corev1.Volume{
@@ -95,3 +104,412 @@ func TestCreatingMountpointPods(t *testing.T) {
 		},
 	}, mpPod.Spec.Containers[0].VolumeMounts)
 }
+
+func TestCreatingMountpointPodsWithCABundle(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"})
+
+	mpPod := creator.Create(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"},
+	}, &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:           "s3.csi.aws.com",
+					VolumeAttributes: map[string]string{"caBundleSecretRef": "minio-ca"},
+				},
+			},
+		},
+	})
+
+	assert.Equals(t, corev1.Volume{
+		Name: "ca-bundle",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: "minio-ca",
+				Items:      []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+			},
+		},
+	}, mpPod.Spec.Volumes[1])
+	assert.Equals(t, corev1.VolumeMount{
+		Name:      "ca-bundle",
+		MountPath: mppod.CABundlePath,
+		SubPath:   "ca.crt",
+		ReadOnly:  true,
+	}, mpPod.Spec.Containers[0].VolumeMounts[1])
+}
+
+func TestCreatingMountpointPodsWithProxy(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{
+		Namespace:         "mount-s3",
+		Proxy:             envprovider.Environment{"HTTPS_PROXY": "http://proxy.internal:3128", "NO_PROXY": "169.254.169.254"},
+		CABundleSecretRef: "proxy-ca",
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, nil)
+		assert.Equals(t, []corev1.EnvVar{
+			{Name: "HTTPS_PROXY", Value: "http://proxy.internal:3128"},
+			{Name: "NO_PROXY", Value: "169.254.169.254"},
+		}, mpPod.Spec.Containers[0].Env)
+		assert.Equals(t, "proxy-ca", mpPod.Spec.Volumes[1].Secret.SecretName)
+	})
+
+	t.Run("overridden by volume", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"httpsProxy":        "http://team-proxy.internal:3128",
+			"caBundleSecretRef": "team-proxy-ca",
+		})
+		assert.Equals(t, []corev1.EnvVar{
+			{Name: "HTTPS_PROXY", Value: "http://team-proxy.internal:3128"},
+			{Name: "NO_PROXY", Value: "169.254.169.254"},
+		}, mpPod.Spec.Containers[0].Env)
+		assert.Equals(t, "team-proxy-ca", mpPod.Spec.Volumes[1].Secret.SecretName)
+	})
+}
+
+func TestCreatingMountpointPodsWithCache(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"})
+
+	t.Run("emptyDir", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{"cacheType": "emptyDir", "cacheDirSizeLimit": "5Gi"})
+
+		assert.Equals(t, corev1.Volume{
+			Name: "cache",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: ptr.To(resource.MustParse("5Gi"))},
+			},
+		}, mpPod.Spec.Volumes[1])
+		assert.Equals(t, corev1.VolumeMount{
+			Name:      "cache",
+			MountPath: mppod.CacheDirPath,
+		}, mpPod.Spec.Containers[0].VolumeMounts[1])
+	})
+
+	t.Run("ephemeral", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"cacheType":             "ephemeral",
+			"cacheDirSizeLimit":     "10Gi",
+			"cacheStorageClassName": "gp3",
+		})
+
+		assert.Equals(t, corev1.Volume{
+			Name: "cache",
+			VolumeSource: corev1.VolumeSource{
+				Ephemeral: &corev1.EphemeralVolumeSource{
+					VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+						Spec: corev1.PersistentVolumeClaimSpec{
+							AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+							Resources: corev1.VolumeResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+							},
+							StorageClassName: ptr.To("gp3"),
+						},
+					},
+				},
+			},
+		}, mpPod.Spec.Volumes[1])
+		assert.Equals(t, corev1.VolumeMount{
+			Name:      "cache",
+			MountPath: mppod.CacheDirPath,
+		}, mpPod.Spec.Containers[0].VolumeMounts[1])
+	})
+
+	t.Run("persistent volume claim", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{"cachePersistentVolumeClaim": "mountpoint-cache", "cacheDirSizeLimit": "10Gi"})
+
+		assert.Equals(t, corev1.Volume{
+			Name: "cache",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "mountpoint-cache"},
+			},
+		}, mpPod.Spec.Volumes[1])
+		assert.Equals(t, corev1.VolumeMount{
+			Name:      "cache",
+			MountPath: mppod.CacheDirPath,
+			SubPath:   mpPod.Name,
+		}, mpPod.Spec.Containers[0].VolumeMounts[1])
+	})
+
+	t.Run("no cache", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{"metadataTTL": "60"})
+
+		assert.Equals(t, 1, len(mpPod.Spec.Volumes))
+		assert.Equals(t, 1, len(mpPod.Spec.Containers[0].VolumeMounts))
+	})
+}
+
+func TestCreatingMountpointPodsWithResources(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"})
+	create := func() *corev1.Pod {
+		return creator.Create(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"},
+		}, &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+		})
+	}
+
+	assert.Equals(t, corev1.ResourceRequirements{}, create().Spec.Containers[0].Resources)
+
+	creator.SetResources(mppod.BuiltinResourceProfiles["large"])
+	assert.Equals(t, mppod.BuiltinResourceProfiles["large"], create().Spec.Containers[0].Resources)
+}
+
+func TestCreatingMountpointPodsWithOverrides(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"})
+
+	t.Run("labels, annotations and tolerations", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"mountpointPodLabels":      `{"cost-center": "ml-research"}`,
+			"mountpointPodAnnotations": `{"sidecar.istio.io/inject": "false", "traffic.sidecar.istio.io/excludeOutboundPorts": "443,80"}`,
+			"mountpointPodTolerations": `[{"key": "nvidia.com/gpu", "operator": "Exists", "effect": "NoSchedule"}]`,
+		})
+
+		assert.Equals(t, "ml-research", mpPod.Labels["cost-center"])
+		assert.Equals(t, "test-pod-uid", mpPod.Labels[mppod.LabelPodUID])
+		assert.Equals(t, "false", mpPod.Annotations["sidecar.istio.io/inject"])
+		assert.Equals(t, "443,80", mpPod.Annotations["traffic.sidecar.istio.io/excludeOutboundPorts"])
+		assert.Equals(t, "default/test-pod", mpPod.Annotations[mppod.AnnotationWorkloadPod])
+		assert.Equals(t, []corev1.Toleration{{
+			Key:      "nvidia.com/gpu",
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}}, mpPod.Spec.Tolerations)
+	})
+
+	t.Run("invalid attributes are ignored", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"mountpointPodLabels":      `{"s3.csi.aws.com/pod-uid": "other-uid"}`,
+			"mountpointPodAnnotations": `not json`,
+			"mountpointPodTolerations": `[{"operator": "Maybe"}]`,
+		})
+
+		assert.Equals(t, "test-pod-uid", mpPod.Labels[mppod.LabelPodUID])
+		assert.Equals(t, 1, len(mpPod.Annotations))
+		assert.Equals(t, 0, len(mpPod.Spec.Tolerations))
+	})
+}
+
+func TestValidatingVolumeAttributes(t *testing.T) {
+	testCases := []struct {
+		name       string
+		attributes map[string]string
+		valid      bool
+	}{
+		{name: "no attributes", attributes: nil, valid: true},
+		{name: "valid labels", attributes: map[string]string{"mountpointPodLabels": `{"team": "ml", "example.com/tier": "gold"}`}, valid: true},
+		{name: "labels not in JSON", attributes: map[string]string{"mountpointPodLabels": "team=ml"}, valid: false},
+		{name: "invalid label value", attributes: map[string]string{"mountpointPodLabels": `{"team": "ml research"}`}, valid: false},
+		{name: "reserved label", attributes: map[string]string{"mountpointPodLabels": `{"s3.csi.aws.com/volume-name": "other"}`}, valid: false},
+		{name: "invalid annotation key", attributes: map[string]string{"mountpointPodAnnotations": `{"not a key": "value"}`}, valid: false},
+		{name: "valid tolerations", attributes: map[string]string{"mountpointPodTolerations": `[{"key": "gpu", "operator": "Equal", "value": "true", "effect": "NoExecute"}]`}, valid: true},
+		{name: "toleration with value and Exists", attributes: map[string]string{"mountpointPodTolerations": `[{"key": "gpu", "operator": "Exists", "value": "true"}]`}, valid: false},
+		{name: "valid node affinity", attributes: map[string]string{"mountpointPodNodeAffinity": `[{"key": "zone", "operator": "In", "values": ["a", "b"]}]`}, valid: true},
+		{name: "node affinity without values", attributes: map[string]string{"mountpointPodNodeAffinity": `[{"key": "zone", "operator": "In"}]`}, valid: false},
+		{name: "invalid node selector", attributes: map[string]string{"mountpointPodNodeSelector": `{"pool": ["gpu"]}`}, valid: false},
+		{name: "pinned Mountpoint version", attributes: map[string]string{"mountpointImage": "public.ecr.aws/mountpoint-s3/mountpoint:1.10.0", "mountpointVersion": "1.10.0"}, valid: true},
+		{name: "Mountpoint image without version", attributes: map[string]string{"mountpointImage": "public.ecr.aws/mountpoint-s3/mountpoint:1.10.0"}, valid: false},
+		{name: "Mountpoint version without image", attributes: map[string]string{"mountpointVersion": "1.10.0"}, valid: false},
+		{name: "toleration with unsupported effect", attributes: map[string]string{"mountpointPodTolerations": `[{"key": "gpu", "effect": "NoRun"}]`}, valid: false},
+		{name: "valid security context", attributes: map[string]string{"mountpointPodSecurityContext": `{"runAsUser": 1000, "seccompProfile": {"type": "RuntimeDefault"}}`}, valid: true},
+		{name: "privileged security context", attributes: map[string]string{"mountpointPodSecurityContext": `{"allowPrivilegeEscalation": true}`}, valid: false},
+		{name: "security context with unsupported seccomp profile", attributes: map[string]string{"mountpointPodSecurityContext": `{"seccompProfile": {"type": "Custom"}}`}, valid: false},
+		{name: "valid image pull secrets", attributes: map[string]string{"mountpointImagePullSecrets": "mirror-creds, backup-mirror-creds"}, valid: true},
+		{name: "invalid image pull secret", attributes: map[string]string{"mountpointImagePullSecrets": "mirror-creds,Mirror_Creds"}, valid: false},
+		{name: "valid mount timeout", attributes: map[string]string{"mountTimeout": "5m"}, valid: true},
+		{name: "mount timeout without unit", attributes: map[string]string{"mountTimeout": "300"}, valid: false},
+		{name: "negative mount timeout", attributes: map[string]string{"mountTimeout": "-1m"}, valid: false},
+		{name: "valid cache claim", attributes: map[string]string{"cachePersistentVolumeClaim": "mountpoint-cache"}, valid: true},
+		{name: "invalid cache claim", attributes: map[string]string{"cachePersistentVolumeClaim": "Mountpoint Cache"}, valid: false},
+		{name: "valid proxy", attributes: map[string]string{"httpsProxy": "http://proxy.internal:3128", "noProxy": ".internal"}, valid: true},
+		{name: "proxy without scheme", attributes: map[string]string{"httpsProxy": "proxy.internal:3128"}, valid: false},
+		{name: "cache claim with cache type", attributes: map[string]string{"cachePersistentVolumeClaim": "mountpoint-cache", "cacheType": "emptyDir"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := mppod.ValidateVolumeAttributes(tc.attributes)
+			assert.Equals(t, tc.valid, err == nil)
+		})
+	}
+}
+
+func TestCreatingMountpointPodsWithNodeConstraints(t *testing.T) {
+	create := func(config mppod.Config, volumeAttributes map[string]string) *corev1.Pod {
+		return mppod.NewCreator(config).Create(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"},
+			Spec:       corev1.PodSpec{NodeName: "test-node"},
+		}, &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{
+						Driver:           "s3.csi.aws.com",
+						VolumeAttributes: volumeAttributes,
+					},
+				},
+			},
+		})
+	}
+	sameNodeRequirement := corev1.NodeSelectorRequirement{
+		Key:      metav1.ObjectNameField,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{"test-node"},
+	}
+
+	t.Run("default node selector", func(t *testing.T) {
+		mpPod := create(mppod.Config{Namespace: "mount-s3", NodeSelector: map[string]string{"s3-cache": "nvme"}}, nil)
+		assert.Equals(t, map[string]string{"s3-cache": "nvme"}, mpPod.Spec.NodeSelector)
+	})
+
+	t.Run("node selector and affinity of the volume", func(t *testing.T) {
+		mpPod := create(mppod.Config{Namespace: "mount-s3", NodeSelector: map[string]string{"s3-cache": "nvme", "pool": "default"}}, map[string]string{
+			"mountpointPodNodeSelector": `{"pool": "gpu"}`,
+			"mountpointPodNodeAffinity": `[{"key": "karpenter.k8s.aws/instance-local-nvme", "operator": "Gt", "values": ["100"]}]`,
+		})
+
+		assert.Equals(t, map[string]string{"s3-cache": "nvme", "pool": "gpu"}, mpPod.Spec.NodeSelector)
+		assert.Equals(t, []corev1.NodeSelectorTerm{{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{
+				Key:      "karpenter.k8s.aws/instance-local-nvme",
+				Operator: corev1.NodeSelectorOpGt,
+				Values:   []string{"100"},
+			}},
+			MatchFields: []corev1.NodeSelectorRequirement{sameNodeRequirement},
+		}}, mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms)
+	})
+
+	t.Run("no node constraints", func(t *testing.T) {
+		mpPod := create(mppod.Config{Namespace: "mount-s3"}, nil)
+		assert.Equals(t, map[string]string(nil), mpPod.Spec.NodeSelector)
+		assert.Equals(t, []corev1.NodeSelectorTerm{{
+			MatchFields: []corev1.NodeSelectorRequirement{sameNodeRequirement},
+		}}, mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms)
+	})
+}
+
+func TestCreatingMountpointPodsWithPinnedVersion(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{
+		Namespace:         "mount-s3",
+		MountpointVersion: "1.12.0",
+		Container:         mppod.ContainerConfig{Image: "mp-image:1.12.0"},
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{"mountpointImage": "mp-image:1.10.0", "mountpointVersion": "1.10.0"})
+		assert.Equals(t, "mp-image:1.10.0", mpPod.Spec.Containers[0].Image)
+		assert.Equals(t, "1.10.0", mpPod.Labels[mppod.LabelMountpointVersion])
+	})
+
+	t.Run("not pinned", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, nil)
+		assert.Equals(t, "mp-image:1.12.0", mpPod.Spec.Containers[0].Image)
+		assert.Equals(t, "1.12.0", mpPod.Labels[mppod.LabelMountpointVersion])
+	})
+
+	t.Run("image without version is ignored", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{"mountpointImage": "mp-image:1.10.0"})
+		assert.Equals(t, "mp-image:1.12.0", mpPod.Spec.Containers[0].Image)
+	})
+}
+
+func TestCreatingMountpointPodsWithMountTimeout(t *testing.T) {
+
+	t.Run("default", func(t *testing.T) {
+		mpPod := createWithAttributes(mppod.NewCreator(mppod.Config{Namespace: "mount-s3"}), nil)
+		assert.Equals(t, []string(nil), mpPod.Spec.Containers[0].Args)
+	})
+
+	t.Run("driver-level", func(t *testing.T) {
+		creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3", MountTimeout: 5 * time.Minute})
+		mpPod := createWithAttributes(creator, nil)
+		assert.Equals(t, []string{"--mount-sock-recv-timeout=5m0s"}, mpPod.Spec.Containers[0].Args)
+	})
+
+	t.Run("volume-level", func(t *testing.T) {
+		creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3", MountTimeout: 5 * time.Minute})
+		mpPod := createWithAttributes(creator, map[string]string{"mountTimeout": "30s"})
+		assert.Equals(t, []string{"--mount-sock-recv-timeout=30s"}, mpPod.Spec.Containers[0].Args)
+	})
+}
+
+func TestCreatingMountpointPodsWithMaxThroughput(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"})
+
+	mpPod := createWithAttributes(creator, map[string]string{"maximumThroughputGbps": "5"})
+	assert.Equals(t, "5", mpPod.Annotations[mppod.AnnotationMaxThroughputGbps])
+
+	// Invalid values fail to mount, and are not reflected
+	for _, attributes := range []map[string]string{nil, {"maximumThroughputGbps": "0"}, {"maximumThroughputGbps": "fast"}} {
+		mpPod := createWithAttributes(creator, attributes)
+		_, ok := mpPod.Annotations[mppod.AnnotationMaxThroughputGbps]
+		assert.Equals(t, false, ok)
+	}
+}
+
+func TestCreatingMountpointPodsWithImagePullSecrets(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{
+		Namespace: "mount-s3",
+		Container: mppod.ContainerConfig{ImagePullSecrets: []string{"default-creds"}},
+	})
+
+	mpPod := createWithAttributes(creator, nil)
+	assert.Equals(t, []corev1.LocalObjectReference{{Name: "default-creds"}}, mpPod.Spec.ImagePullSecrets)
+
+	// Secrets of the volume replace the default ones
+	mpPod = createWithAttributes(creator, map[string]string{"mountpointImagePullSecrets": "mirror-creds, backup-mirror-creds"})
+	assert.Equals(t, []corev1.LocalObjectReference{{Name: "mirror-creds"}, {Name: "backup-mirror-creds"}}, mpPod.Spec.ImagePullSecrets)
+
+	// Invalid values fail to mount, and are not reflected
+	mpPod = createWithAttributes(creator, map[string]string{"mountpointImagePullSecrets": "Mirror_Creds"})
+	assert.Equals(t, []corev1.LocalObjectReference{{Name: "default-creds"}}, mpPod.Spec.ImagePullSecrets)
+}
+
+func TestCreatingMountpointPodsWithMetrics(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:           "s3.csi.aws.com",
+					VolumeAttributes: map[string]string{"mountTimeout": "30s"},
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"}}
+
+	t.Run("disabled", func(t *testing.T) {
+		mpPod := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"}).Create(pod, pv)
+		assert.Equals(t, []string{"--mount-sock-recv-timeout=30s"}, mpPod.Spec.Containers[0].Args)
+		assert.Equals(t, []corev1.ContainerPort(nil), mpPod.Spec.Containers[0].Ports)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		creator := mppod.NewCreator(mppod.Config{Namespace: "mount-s3", MountTimeout: 5 * time.Minute, MetricsPort: 9811})
+		mpPod := creator.Create(pod, pv)
+		assert.Equals(t, []string{"--mount-sock-recv-timeout=30s", "--metrics-address=:9811"}, mpPod.Spec.Containers[0].Args)
+		assert.Equals(t, []corev1.ContainerPort{{
+			Name:          mppod.MetricsPortName,
+			ContainerPort: 9811,
+			Protocol:      corev1.ProtocolTCP,
+		}}, mpPod.Spec.Containers[0].Ports)
+	})
+}
+
+// createWithAttributes creates a Mountpoint Pod with `creator` for a workload Pod using a volume with `volumeAttributes`.
+func createWithAttributes(creator *mppod.Creator, volumeAttributes map[string]string) *corev1.Pod {
+	return creator.Create(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", UID: "test-pod-uid"},
+	}, &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:           "s3.csi.aws.com",
+					VolumeAttributes: volumeAttributes,
+				},
+			},
+		},
+	})
+}
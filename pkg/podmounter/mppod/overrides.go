@@ -0,0 +1,281 @@
+package mppod
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/volumecontext"
+)
+
+// reservedKeyPrefix is the prefix of the labels and annotations populated by the CSI Driver,
+// they cannot be set via volume attributes.
+const reservedKeyPrefix = "s3.csi.aws.com/"
+
+// ValidateVolumeAttributes validates volume attributes used to customize Mountpoint Pods,
+// i.e. `mountpointPodLabels`, `mountpointPodAnnotations`, `mountpointPodTolerations`,
+// `mountpointPodNodeSelector`, `mountpointPodNodeAffinity`, `mountpointPodSecurityContext`, `mountpointImage`,
+// `mountpointVersion`, `mountpointImagePullSecrets`, `mountTimeout`, `cachePersistentVolumeClaim`
+// and the proxy settings `httpProxy`, `httpsProxy` and `noProxy`.
+func ValidateVolumeAttributes(volumeAttributes map[string]string) error {
+	var errs []error
+	if err := validateMountpointVersion(volumeAttributes); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseLabels(volumeAttributes[volumecontext.MountpointPodLabels]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodLabels, err))
+	}
+	if _, err := parseAnnotations(volumeAttributes[volumecontext.MountpointPodAnnotations]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodAnnotations, err))
+	}
+	if _, err := parseTolerations(volumeAttributes[volumecontext.MountpointPodTolerations]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodTolerations, err))
+	}
+	if _, err := parseLabels(volumeAttributes[volumecontext.MountpointPodNodeSelector]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodNodeSelector, err))
+	}
+	if _, err := parseNodeSelectorRequirements(volumeAttributes[volumecontext.MountpointPodNodeAffinity]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodNodeAffinity, err))
+	}
+	if _, err := parseSecurityContext(volumeAttributes[volumecontext.MountpointPodSecurityContext]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointPodSecurityContext, err))
+	}
+	if _, err := ParseImagePullSecrets(volumeAttributes[volumecontext.MountpointImagePullSecrets]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountpointImagePullSecrets, err))
+	}
+	if _, err := parseMountTimeout(volumeAttributes[volumecontext.MountTimeout]); err != nil {
+		errs = append(errs, fmt.Errorf("invalid %s: %w", volumecontext.MountTimeout, err))
+	}
+	if err := validateCacheClaim(volumeAttributes); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := envprovider.ProxyFor(volumeAttributes); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// addOverrides adds labels, annotations, tolerations, node constraints and security context configured
+// in `volumeAttributes` to `mpPod`.
+//
+// Labels and annotations populated by the CSI Driver always take precedence, and node selector and security context
+// of the volume take precedence over the defaults of Mountpoint Pods. Invalid attributes are ignored here,
+// as they're rejected by the controller's validating webhook on PersistentVolumes (see [ValidateVolumeAttributes]).
+func addOverrides(mpPod *corev1.Pod, volumeAttributes map[string]string) {
+	if labels, err := parseLabels(volumeAttributes[volumecontext.MountpointPodLabels]); err == nil {
+		for key, value := range labels {
+			if _, ok := mpPod.Labels[key]; !ok {
+				mpPod.Labels[key] = value
+			}
+		}
+	}
+	if annotations, err := parseAnnotations(volumeAttributes[volumecontext.MountpointPodAnnotations]); err == nil {
+		for key, value := range annotations {
+			if _, ok := mpPod.Annotations[key]; !ok {
+				mpPod.Annotations[key] = value
+			}
+		}
+	}
+	if tolerations, err := parseTolerations(volumeAttributes[volumecontext.MountpointPodTolerations]); err == nil {
+		mpPod.Spec.Tolerations = append(mpPod.Spec.Tolerations, tolerations...)
+	}
+	if nodeSelector, err := parseLabels(volumeAttributes[volumecontext.MountpointPodNodeSelector]); err == nil && len(nodeSelector) > 0 {
+		if mpPod.Spec.NodeSelector == nil {
+			mpPod.Spec.NodeSelector = make(map[string]string, len(nodeSelector))
+		}
+		maps.Copy(mpPod.Spec.NodeSelector, nodeSelector)
+	}
+	if requirements, err := parseNodeSelectorRequirements(volumeAttributes[volumecontext.MountpointPodNodeAffinity]); err == nil {
+		addNodeSelectorRequirements(mpPod, requirements)
+	}
+	if securityContext, err := parseSecurityContext(volumeAttributes[volumecontext.MountpointPodSecurityContext]); err == nil {
+		setSecurityContext(mpPod, securityContext)
+	}
+}
+
+// pinMountpointVersion sets the image of `mpPod` to the one pinned with `mountpointImage` and `mountpointVersion`
+// volume attributes, if any. Mountpoint Pods are not shared between workload Pods, so Mountpoint Pods of different
+// versions never serve the same workload Pod and volume.
+func pinMountpointVersion(mpPod *corev1.Pod, volumeAttributes map[string]string) {
+	if validateMountpointVersion(volumeAttributes) != nil {
+		return
+	}
+	image, version := volumeAttributes[volumecontext.MountpointImage], volumeAttributes[volumecontext.MountpointVersion]
+	if image == "" {
+		return
+	}
+	mpPod.Spec.Containers[0].Image = image
+	mpPod.Labels[LabelMountpointVersion] = version
+}
+
+// validateMountpointVersion validates that `mountpointImage` and `mountpointVersion` volume attributes are either
+// both set or not set, as the version is used to label Mountpoint Pods running the image.
+func validateMountpointVersion(volumeAttributes map[string]string) error {
+	image, version := volumeAttributes[volumecontext.MountpointImage], volumeAttributes[volumecontext.MountpointVersion]
+	if (image == "") != (version == "") {
+		return fmt.Errorf("%s and %s must be set together", volumecontext.MountpointImage, volumecontext.MountpointVersion)
+	}
+	if strings.ContainsAny(image, " \t\n") {
+		return fmt.Errorf("invalid %s %q", volumecontext.MountpointImage, image)
+	}
+	if errs := validation.IsValidLabelValue(version); len(errs) > 0 {
+		return fmt.Errorf("invalid %s %q: %s", volumecontext.MountpointVersion, version, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ParseImagePullSecrets parses `attr` as a comma-separated list of Secret names, e.g. `mirror-creds,backup-mirror-creds`.
+func ParseImagePullSecrets(attr string) ([]string, error) {
+	if attr == "" {
+		return nil, nil
+	}
+	var secrets []string
+	for _, name := range strings.Split(attr, ",") {
+		name = strings.TrimSpace(name)
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid Secret name %q: %s", name, strings.Join(errs, "; "))
+		}
+		secrets = append(secrets, name)
+	}
+	return secrets, nil
+}
+
+// validateCacheClaim validates that `cachePersistentVolumeClaim` volume attribute, if set, is a valid name
+// and not combined with `cacheType`.
+func validateCacheClaim(volumeAttributes map[string]string) error {
+	claimName, ok := volumeAttributes[volumecontext.CachePVC]
+	if !ok {
+		return nil
+	}
+	if _, ok := volumeAttributes[volumecontext.CacheType]; ok {
+		return fmt.Errorf("only one of %s and %s can be set", volumecontext.CacheType, volumecontext.CachePVC)
+	}
+	if errs := validation.IsDNS1123Subdomain(claimName); len(errs) > 0 {
+		return fmt.Errorf("invalid %s %q: %s", volumecontext.CachePVC, claimName, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// addNodeSelectorRequirements adds `requirements` to the required node affinity of `mpPod`.
+// Requirements within a node selector term are ANDed, so they're added to each term along with the constraint
+// to schedule Mountpoint Pods into the same node as their workload Pods.
+func addNodeSelectorRequirements(mpPod *corev1.Pod, requirements []corev1.NodeSelectorRequirement) {
+	if len(requirements) == 0 {
+		return
+	}
+	terms := mpPod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for i := range terms {
+		terms[i].MatchExpressions = append(terms[i].MatchExpressions, requirements...)
+	}
+}
+
+// parseLabels parses `attr` as a JSON object of labels, e.g. `{"team": "ml"}`.
+func parseLabels(attr string) (map[string]string, error) {
+	labels, err := parseMetadata(attr)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range labels {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid value %q of %q: %s", value, key, strings.Join(errs, "; "))
+		}
+	}
+	return labels, nil
+}
+
+// parseAnnotations parses `attr` as a JSON object of annotations, e.g. `{"sidecar.istio.io/inject": "false"}`.
+func parseAnnotations(attr string) (map[string]string, error) {
+	return parseMetadata(attr)
+}
+
+// parseMetadata parses `attr` as a JSON object with valid and non-reserved keys.
+func parseMetadata(attr string) (map[string]string, error) {
+	if attr == "" {
+		return nil, nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(attr), &metadata); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of strings: %w", err)
+	}
+	for key := range metadata {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if strings.HasPrefix(key, reservedKeyPrefix) {
+			return nil, fmt.Errorf("key %q uses reserved prefix %q", key, reservedKeyPrefix)
+		}
+	}
+	return metadata, nil
+}
+
+// parseNodeSelectorRequirements parses `attr` as a JSON array of node selector requirements,
+// e.g. `[{"key": "karpenter.k8s.aws/instance-local-nvme", "operator": "Exists"}]`.
+func parseNodeSelectorRequirements(attr string) ([]corev1.NodeSelectorRequirement, error) {
+	if attr == "" {
+		return nil, nil
+	}
+
+	var requirements []corev1.NodeSelectorRequirement
+	if err := json.Unmarshal([]byte(attr), &requirements); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of node selector requirements: %w", err)
+	}
+	for _, requirement := range requirements {
+		if errs := validation.IsQualifiedName(requirement.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid key %q: %s", requirement.Key, strings.Join(errs, "; "))
+		}
+		switch requirement.Operator {
+		case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+			if len(requirement.Values) == 0 {
+				return nil, fmt.Errorf("values of %q must be non-empty for operator %q", requirement.Key, requirement.Operator)
+			}
+		case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+			if len(requirement.Values) > 0 {
+				return nil, fmt.Errorf("values of %q must be empty for operator %q", requirement.Key, requirement.Operator)
+			}
+		case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			if len(requirement.Values) != 1 {
+				return nil, fmt.Errorf("values of %q must have a single element for operator %q", requirement.Key, requirement.Operator)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", requirement.Operator)
+		}
+	}
+	return requirements, nil
+}
+
+// parseTolerations parses `attr` as a JSON array of tolerations,
+// e.g. `[{"key": "nvidia.com/gpu", "operator": "Exists", "effect": "NoSchedule"}]`.
+func parseTolerations(attr string) ([]corev1.Toleration, error) {
+	if attr == "" {
+		return nil, nil
+	}
+
+	var tolerations []corev1.Toleration
+	if err := json.Unmarshal([]byte(attr), &tolerations); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of tolerations: %w", err)
+	}
+	for _, toleration := range tolerations {
+		switch toleration.Operator {
+		case corev1.TolerationOpEqual, "":
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				return nil, fmt.Errorf("value must be empty if operator is %q", corev1.TolerationOpExists)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("unsupported effect %q", toleration.Effect)
+		}
+	}
+	return tolerations, nil
+}
@@ -0,0 +1,47 @@
+package mppod
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParsePriorityClasses parses `attr` in "workload-class=mountpoint-class,workload-class2=mountpoint-class2" format,
+// which maps priority classes of workload Pods to the priority classes of their Mountpoint Pods.
+func ParsePriorityClasses(attr string) (map[string]string, error) {
+	if attr == "" {
+		return nil, nil
+	}
+	classes := map[string]string{}
+	for _, mapping := range strings.Split(attr, ",") {
+		workloadClass, mountpointClass, ok := strings.Cut(strings.TrimSpace(mapping), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid priority class mapping %q: must be in \"workload-class=mountpoint-class\" format", mapping)
+		}
+		for _, name := range []string{workloadClass, mountpointClass} {
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid priority class name %q: %s", name, strings.Join(errs, "; "))
+			}
+		}
+		if _, ok := classes[workloadClass]; ok {
+			return nil, fmt.Errorf("priority class %q is mapped more than once", workloadClass)
+		}
+		classes[workloadClass] = mountpointClass
+	}
+	return classes, nil
+}
+
+// setPriorityClass sets the priority class of `mpPod` from the priority class of its `workloadPod` mapped in
+// `classes`, or `defaultClass` if it's not mapped. The default priority of the cluster is used if both are empty.
+//
+// Mountpoint Pods with a lower priority than their workload Pods might be preempted while the workload Pods keep running
+// with a broken mount, so Mountpoint Pods of critical workloads should have at least the priority of their workloads.
+func setPriorityClass(mpPod, workloadPod *corev1.Pod, defaultClass string, classes map[string]string) {
+	if class, ok := classes[workloadPod.Spec.PriorityClassName]; ok {
+		mpPod.Spec.PriorityClassName = class
+		return
+	}
+	mpPod.Spec.PriorityClassName = defaultClass
+}
@@ -0,0 +1,66 @@
+package mppod_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestParsingPriorityClasses(t *testing.T) {
+	classes, err := mppod.ParsePriorityClasses("business-critical=mountpoint-critical, batch=mountpoint-batch")
+	assert.NoError(t, err)
+	assert.Equals(t, map[string]string{"business-critical": "mountpoint-critical", "batch": "mountpoint-batch"}, classes)
+
+	classes, err = mppod.ParsePriorityClasses("")
+	assert.NoError(t, err)
+	assert.Equals(t, map[string]string(nil), classes)
+
+	for _, attr := range []string{
+		"business-critical",
+		"=mountpoint-critical",
+		"business-critical=Mountpoint_Critical",
+		"batch=mountpoint-batch,batch=mountpoint-critical",
+	} {
+		if _, err := mppod.ParsePriorityClasses(attr); err == nil {
+			t.Errorf("Expected priority classes %q to be invalid", attr)
+		}
+	}
+}
+
+func TestCreatingMountpointPodsWithPriorityClasses(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vol"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "s3.csi.aws.com"},
+			},
+		},
+	}
+	podWithPriorityClass := func(class string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{UID: "test-pod-uid"},
+			Spec:       corev1.PodSpec{PriorityClassName: class},
+		}
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		mpPod := mppod.NewCreator(mppod.Config{Namespace: "mount-s3"}).Create(podWithPriorityClass("business-critical"), pv)
+		assert.Equals(t, "", mpPod.Spec.PriorityClassName)
+	})
+
+	t.Run("mapped by workload priority class", func(t *testing.T) {
+		creator := mppod.NewCreator(mppod.Config{
+			Namespace:         "mount-s3",
+			PriorityClassName: "mountpoint-default",
+			PriorityClasses:   map[string]string{"business-critical": "mountpoint-critical", "batch": "mountpoint-batch"},
+		})
+		assert.Equals(t, "mountpoint-critical", creator.Create(podWithPriorityClass("business-critical"), pv).Spec.PriorityClassName)
+		assert.Equals(t, "mountpoint-batch", creator.Create(podWithPriorityClass("batch"), pv).Spec.PriorityClassName)
+		assert.Equals(t, "mountpoint-default", creator.Create(podWithPriorityClass("other"), pv).Spec.PriorityClassName)
+		assert.Equals(t, "mountpoint-default", creator.Create(podWithPriorityClass(""), pv).Spec.PriorityClassName)
+	})
+}
@@ -0,0 +1,32 @@
+package mppod
+
+import (
+	"maps"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/envprovider"
+)
+
+// setProxy sets the proxy settings in `proxy`, overridden by `httpProxy`, `httpsProxy` and `noProxy` in `volumeAttributes`,
+// as environment variables of the Mountpoint container in `mpPod`. `aws-s3-csi-mounter` passes them to Mountpoint.
+//
+// The volume attributes are validated by [ValidateVolumeAttributes], invalid proxies are ignored here as the mount would fail anyway.
+func setProxy(mpPod *corev1.Pod, proxy envprovider.Environment, volumeAttributes map[string]string) {
+	env := maps.Clone(proxy)
+	if overrides, err := envprovider.ProxyFor(volumeAttributes); err == nil {
+		if env == nil {
+			env = envprovider.Environment{}
+		}
+		maps.Copy(env, overrides)
+	}
+	keys := make([]envprovider.Key, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		mpPod.Spec.Containers[0].Env = append(mpPod.Spec.Containers[0].Env, corev1.EnvVar{Name: key, Value: env[key]})
+	}
+}
@@ -0,0 +1,74 @@
+package mppod
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourceProfilesKey is the key of the resource profiles in the ConfigMap watched by the controller.
+const ResourceProfilesKey = "profiles.yaml"
+
+// Built-in resource profiles for Mountpoint Pods, sized for the memory Mountpoint uses for prefetching and uploads.
+var BuiltinResourceProfiles = map[string]corev1.ResourceRequirements{
+	"small": {
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+	},
+	"medium": {
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	},
+	"large": {
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+	},
+}
+
+// A ResourceProfiles represents the resource profiles configured in [ResourceProfilesKey] of the ConfigMap.
+//
+// Profiles are merged with [BuiltinResourceProfiles] and can override them.
+// `DefaultProfile` is the profile applied to newly spawned Mountpoint Pods, no resources are set if it's empty.
+type ResourceProfiles struct {
+	DefaultProfile string                                 `json:"defaultProfile"`
+	Profiles       map[string]corev1.ResourceRequirements `json:"profiles,omitempty"`
+}
+
+// ParseResourceProfiles parses given resource profiles in YAML and returns resources of the default profile.
+func ParseResourceProfiles(data []byte) (corev1.ResourceRequirements, error) {
+	var profiles ResourceProfiles
+	if err := yaml.UnmarshalStrict(data, &profiles); err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("failed to parse resource profiles: %w", err)
+	}
+
+	if profiles.DefaultProfile == "" {
+		return corev1.ResourceRequirements{}, nil
+	}
+
+	all := maps.Clone(BuiltinResourceProfiles)
+	maps.Copy(all, profiles.Profiles)
+
+	resources, ok := all[profiles.DefaultProfile]
+	if !ok {
+		names := make([]string, 0, len(all))
+		for name := range all {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return corev1.ResourceRequirements{}, fmt.Errorf("unknown default resource profile %q, available profiles are %v",
+			profiles.DefaultProfile, names)
+	}
+
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			return corev1.ResourceRequirements{}, fmt.Errorf("%s request %s of resource profile %q exceeds its limit %s",
+				name, request.String(), profiles.DefaultProfile, limit.String())
+		}
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,98 @@
+package mppod_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestParsingResourceProfiles(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      string
+		resources corev1.ResourceRequirements
+		wantErr   bool
+	}{
+		{
+			name:      "empty",
+			data:      "",
+			resources: corev1.ResourceRequirements{},
+		},
+		{
+			name:      "built-in profile",
+			data:      "defaultProfile: medium",
+			resources: mppod.BuiltinResourceProfiles["medium"],
+		},
+		{
+			name: "custom profile",
+			data: `
+defaultProfile: xlarge
+profiles:
+  xlarge:
+    requests:
+      cpu: "2"
+      memory: 4Gi
+    limits:
+      memory: 16Gi
+`,
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")},
+			},
+		},
+		{
+			name: "overridden built-in profile",
+			data: `
+defaultProfile: small
+profiles:
+  small:
+    requests:
+      memory: 64Mi
+`,
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+			},
+		},
+		{
+			name:    "unknown profile",
+			data:    "defaultProfile: huge",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    "default: small",
+			wantErr: true,
+		},
+		{
+			name: "request exceeding limit",
+			data: `
+defaultProfile: broken
+profiles:
+  broken:
+    requests:
+      memory: 1Gi
+    limits:
+      memory: 512Mi
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resources, err := mppod.ParseResourceProfiles([]byte(tc.data))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got resources %v", resources)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equals(t, tc.resources, resources)
+		})
+	}
+}
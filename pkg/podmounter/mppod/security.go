@@ -0,0 +1,190 @@
+package mppod
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAppArmorProfile is the annotation populated on Mountpoint Pods with the AppArmor profile
+// of the Mountpoint container, if it's configured. Kubernetes versions before 1.30 only read AppArmor profiles
+// from this annotation, later versions read them from the security context and require both to match.
+const AnnotationAppArmorProfile = corev1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix + "mountpoint"
+
+// ParseSeccompProfile parses `profile` as a seccomp profile in `RuntimeDefault`, `Unconfined`
+// or `Localhost/<path>` format, where `<path>` is relative to kubelet's seccomp profile root.
+func ParseSeccompProfile(profile string) (*corev1.SeccompProfile, error) {
+	if profile == "" {
+		return nil, nil
+	}
+	profileType, localhostProfile, _ := strings.Cut(profile, "/")
+	seccompProfile := &corev1.SeccompProfile{Type: corev1.SeccompProfileType(profileType)}
+	if localhostProfile != "" {
+		seccompProfile.LocalhostProfile = &localhostProfile
+	}
+	if err := validateSeccompProfile(seccompProfile); err != nil {
+		return nil, err
+	}
+	return seccompProfile, nil
+}
+
+// ParseAppArmorProfile parses `profile` as an AppArmor profile in `RuntimeDefault`, `Unconfined`
+// or `Localhost/<name>` format, where `<name>` is a profile loaded in the node.
+func ParseAppArmorProfile(profile string) (*corev1.AppArmorProfile, error) {
+	if profile == "" {
+		return nil, nil
+	}
+	profileType, localhostProfile, _ := strings.Cut(profile, "/")
+	appArmorProfile := &corev1.AppArmorProfile{Type: corev1.AppArmorProfileType(profileType)}
+	if localhostProfile != "" {
+		appArmorProfile.LocalhostProfile = &localhostProfile
+	}
+	if err := validateAppArmorProfile(appArmorProfile); err != nil {
+		return nil, err
+	}
+	return appArmorProfile, nil
+}
+
+// ValidateSecurityContext validates that `securityContext` of Mountpoint containers doesn't grant them
+// any privileges, as Mountpoint Pods only receive a FUSE file descriptor from the CSI Driver Node Pod
+// and they don't need privileges to mount.
+func ValidateSecurityContext(securityContext *corev1.SecurityContext) error {
+	if securityContext == nil {
+		return nil
+	}
+
+	var errs []error
+	if securityContext.Privileged != nil && *securityContext.Privileged {
+		errs = append(errs, errors.New("privileged containers are not allowed"))
+	}
+	if securityContext.AllowPrivilegeEscalation != nil && *securityContext.AllowPrivilegeEscalation {
+		errs = append(errs, errors.New("privilege escalation is not allowed"))
+	}
+	if securityContext.Capabilities != nil && len(securityContext.Capabilities.Add) > 0 {
+		errs = append(errs, errors.New("adding capabilities is not allowed"))
+	}
+	if securityContext.ProcMount != nil && *securityContext.ProcMount != corev1.DefaultProcMount {
+		errs = append(errs, fmt.Errorf("unsupported proc mount %q", *securityContext.ProcMount))
+	}
+	if securityContext.RunAsUser != nil && *securityContext.RunAsUser < 0 {
+		errs = append(errs, fmt.Errorf("invalid user ID %d", *securityContext.RunAsUser))
+	}
+	if securityContext.RunAsGroup != nil && *securityContext.RunAsGroup < 0 {
+		errs = append(errs, fmt.Errorf("invalid group ID %d", *securityContext.RunAsGroup))
+	}
+	if securityContext.RunAsNonRoot != nil && *securityContext.RunAsNonRoot && securityContext.RunAsUser != nil && *securityContext.RunAsUser == 0 {
+		errs = append(errs, errors.New("runAsNonRoot conflicts with running as user 0"))
+	}
+	if securityContext.SeccompProfile != nil {
+		if err := validateSeccompProfile(securityContext.SeccompProfile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if securityContext.AppArmorProfile != nil {
+		if err := validateAppArmorProfile(securityContext.AppArmorProfile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setSecurityContext overlays the fields set in `securityContext` onto the security context of the Mountpoint
+// container in `mpPod`, and mirrors its AppArmor profile into [AnnotationAppArmorProfile].
+// Capabilities to drop replace the default ones if they're set.
+func setSecurityContext(mpPod *corev1.Pod, securityContext *corev1.SecurityContext) {
+	if securityContext == nil {
+		return
+	}
+	securityContext = securityContext.DeepCopy()
+
+	dst := mpPod.Spec.Containers[0].SecurityContext
+	if securityContext.Capabilities != nil && securityContext.Capabilities.Drop != nil {
+		dst.Capabilities = &corev1.Capabilities{Drop: securityContext.Capabilities.Drop}
+	}
+	if securityContext.SELinuxOptions != nil {
+		dst.SELinuxOptions = securityContext.SELinuxOptions
+	}
+	if securityContext.RunAsUser != nil {
+		dst.RunAsUser = securityContext.RunAsUser
+	}
+	if securityContext.RunAsGroup != nil {
+		dst.RunAsGroup = securityContext.RunAsGroup
+	}
+	if securityContext.RunAsNonRoot != nil {
+		dst.RunAsNonRoot = securityContext.RunAsNonRoot
+	}
+	if securityContext.ReadOnlyRootFilesystem != nil {
+		dst.ReadOnlyRootFilesystem = securityContext.ReadOnlyRootFilesystem
+	}
+	if securityContext.SeccompProfile != nil {
+		dst.SeccompProfile = securityContext.SeccompProfile
+	}
+	if securityContext.AppArmorProfile != nil {
+		dst.AppArmorProfile = securityContext.AppArmorProfile
+		mpPod.Annotations[AnnotationAppArmorProfile] = appArmorAnnotationValue(securityContext.AppArmorProfile)
+	}
+}
+
+// parseSecurityContext parses `attr` as a JSON object of a container security context,
+// e.g. `{"runAsUser": 1000, "readOnlyRootFilesystem": true, "seccompProfile": {"type": "RuntimeDefault"}}`.
+func parseSecurityContext(attr string) (*corev1.SecurityContext, error) {
+	if attr == "" {
+		return nil, nil
+	}
+
+	var securityContext corev1.SecurityContext
+	if err := json.Unmarshal([]byte(attr), &securityContext); err != nil {
+		return nil, fmt.Errorf("must be a JSON object of a security context: %w", err)
+	}
+	if err := ValidateSecurityContext(&securityContext); err != nil {
+		return nil, err
+	}
+	return &securityContext, nil
+}
+
+func validateSeccompProfile(profile *corev1.SeccompProfile) error {
+	switch profile.Type {
+	case corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeUnconfined:
+		if profile.LocalhostProfile != nil {
+			return fmt.Errorf("localhost seccomp profile can only be set with type %q", corev1.SeccompProfileTypeLocalhost)
+		}
+	case corev1.SeccompProfileTypeLocalhost:
+		if profile.LocalhostProfile == nil || *profile.LocalhostProfile == "" {
+			return fmt.Errorf("seccomp profile of type %q requires a localhost profile", corev1.SeccompProfileTypeLocalhost)
+		}
+	default:
+		return fmt.Errorf("unsupported seccomp profile type %q", profile.Type)
+	}
+	return nil
+}
+
+func validateAppArmorProfile(profile *corev1.AppArmorProfile) error {
+	switch profile.Type {
+	case corev1.AppArmorProfileTypeRuntimeDefault, corev1.AppArmorProfileTypeUnconfined:
+		if profile.LocalhostProfile != nil {
+			return fmt.Errorf("localhost AppArmor profile can only be set with type %q", corev1.AppArmorProfileTypeLocalhost)
+		}
+	case corev1.AppArmorProfileTypeLocalhost:
+		if profile.LocalhostProfile == nil || *profile.LocalhostProfile == "" {
+			return fmt.Errorf("AppArmor profile of type %q requires a localhost profile", corev1.AppArmorProfileTypeLocalhost)
+		}
+	default:
+		return fmt.Errorf("unsupported AppArmor profile type %q", profile.Type)
+	}
+	return nil
+}
+
+// appArmorAnnotationValue returns the value of [AnnotationAppArmorProfile] for `profile`.
+func appArmorAnnotationValue(profile *corev1.AppArmorProfile) string {
+	switch profile.Type {
+	case corev1.AppArmorProfileTypeLocalhost:
+		return corev1.DeprecatedAppArmorBetaProfileNamePrefix + *profile.LocalhostProfile
+	case corev1.AppArmorProfileTypeUnconfined:
+		return corev1.DeprecatedAppArmorBetaProfileNameUnconfined
+	default:
+		return corev1.DeprecatedAppArmorBetaProfileRuntimeDefault
+	}
+}
@@ -0,0 +1,74 @@
+package mppod_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/podmounter/mppod"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestParsingSecurityProfiles(t *testing.T) {
+	seccompProfile, err := mppod.ParseSeccompProfile("Localhost/profiles/mountpoint.json")
+	assert.NoError(t, err)
+	assert.Equals(t, &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: ptr.To("profiles/mountpoint.json")}, seccompProfile)
+
+	appArmorProfile, err := mppod.ParseAppArmorProfile("RuntimeDefault")
+	assert.NoError(t, err)
+	assert.Equals(t, &corev1.AppArmorProfile{Type: corev1.AppArmorProfileTypeRuntimeDefault}, appArmorProfile)
+
+	for _, profile := range []string{"Default", "Localhost", "RuntimeDefault/profile"} {
+		if _, err := mppod.ParseSeccompProfile(profile); err == nil {
+			t.Errorf("Expected seccomp profile %q to be invalid", profile)
+		}
+		if _, err := mppod.ParseAppArmorProfile(profile); err == nil {
+			t.Errorf("Expected AppArmor profile %q to be invalid", profile)
+		}
+	}
+}
+
+func TestCreatingMountpointPodsWithSecurityContext(t *testing.T) {
+	creator := mppod.NewCreator(mppod.Config{
+		Namespace: "mount-s3",
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:       ptr.To(int64(1000)),
+			RunAsNonRoot:    ptr.To(true),
+			SeccompProfile:  &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			AppArmorProfile: &corev1.AppArmorProfile{Type: corev1.AppArmorProfileTypeRuntimeDefault},
+		},
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, nil)
+		assert.Equals(t, &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			RunAsUser:                ptr.To(int64(1000)),
+			RunAsNonRoot:             ptr.To(true),
+			SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			AppArmorProfile:          &corev1.AppArmorProfile{Type: corev1.AppArmorProfileTypeRuntimeDefault},
+		}, mpPod.Spec.Containers[0].SecurityContext)
+		assert.Equals(t, "runtime/default", mpPod.Annotations[mppod.AnnotationAppArmorProfile])
+	})
+
+	t.Run("overridden by volume", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"mountpointPodSecurityContext": `{"runAsUser": 2000, "readOnlyRootFilesystem": true, "appArmorProfile": {"type": "Localhost", "localhostProfile": "mountpoint"}}`,
+		})
+		securityContext := mpPod.Spec.Containers[0].SecurityContext
+		assert.Equals(t, ptr.To(int64(2000)), securityContext.RunAsUser)
+		assert.Equals(t, ptr.To(true), securityContext.ReadOnlyRootFilesystem)
+		assert.Equals(t, &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}, securityContext.SeccompProfile)
+		assert.Equals(t, "localhost/mountpoint", mpPod.Annotations[mppod.AnnotationAppArmorProfile])
+	})
+
+	t.Run("privileges are not granted", func(t *testing.T) {
+		mpPod := createWithAttributes(creator, map[string]string{
+			"mountpointPodSecurityContext": `{"privileged": true, "capabilities": {"add": ["SYS_ADMIN"]}}`,
+		})
+		assert.Equals(t, (*bool)(nil), mpPod.Spec.Containers[0].SecurityContext.Privileged)
+		assert.Equals(t, &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}, mpPod.Spec.Containers[0].SecurityContext.Capabilities)
+	})
+}
@@ -0,0 +1,137 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultCgroupRoot is the default mount point of cgroup v2.
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// cpuMaxPeriod is the period of `cpu.max` in microseconds, which is the kernel's default.
+const cpuMaxPeriod = 100000
+
+// selfCgroupPath is the cgroup membership file of the current process.
+var selfCgroupPath = "/proc/self/cgroup"
+
+// ResourceLimits are the resource limits of a service started by [ProcessSupervisor], enforced with cgroup v2.
+type ResourceLimits struct {
+	// MemoryMax is the maximum memory in bytes (`memory.max`), the service is killed if it exceeds it. Not limited if 0.
+	MemoryMax int64
+	// CPUMaxMillis is the maximum CPU time in thousandths of a CPU (`cpu.max`), the service is throttled if it
+	// exceeds it. Not limited if 0.
+	CPUMaxMillis int64
+}
+
+// IsZero returns whether no resources are limited.
+func (l ResourceLimits) IsZero() bool {
+	return l.MemoryMax == 0 && l.CPUMaxMillis == 0
+}
+
+// A cgroupManager starts processes in child cgroups of the current process' cgroup to limit their resources,
+// which are nested within the limits of the current process' cgroup (e.g., of its container).
+type cgroupManager struct {
+	// parent is the cgroup of the current process, whose children are limited.
+	parent string
+	limits ResourceLimits
+}
+
+// newCgroupManager enables the memory and CPU controllers for child cgroups of the current process' cgroup under
+// `root`, to start processes limited by `limits` in them. cgroup v2 only allows enabling controllers for children of
+// cgroups without processes ("no internal processes" rule), so all processes in the current cgroup (i.e., the current
+// process and anything else running in its container) are moved into a leaf cgroup first. It must be called before
+// any process is started in a child cgroup.
+func newCgroupManager(root string, limits ResourceLimits) (*cgroupManager, error) {
+	self, err := currentCgroup()
+	if err != nil {
+		return nil, err
+	}
+	parent := filepath.Join(root, self)
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available at %s: %w", parent, err)
+	}
+
+	procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read processes of cgroup %s: %w", parent, err)
+	}
+	pids := append(strings.Fields(string(procs)), strconv.Itoa(os.Getpid()))
+	for _, pid := range pids {
+		if err := writeCgroupFile(filepath.Join(parent, "supervisor"), "cgroup.procs", pid); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeCgroupFile(parent, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+		return nil, err
+	}
+	return &cgroupManager{parent: parent, limits: limits}, nil
+}
+
+// create creates a new child cgroup for service `name` limited by the manager's limits, and returns a file descriptor
+// of it to start the service's process in with [cgroupSysProcAttr], and a function to remove the cgroup once the
+// process exits. The descriptor must be closed once the process is started.
+func (m *cgroupManager) create(name string) (*os.File, func(), error) {
+	dir := filepath.Join(m.parent, name)
+	if m.limits.MemoryMax > 0 {
+		if err := writeCgroupFile(dir, "memory.max", strconv.FormatInt(m.limits.MemoryMax, 10)); err != nil {
+			return nil, nil, err
+		}
+	}
+	if m.limits.CPUMaxMillis > 0 {
+		quota := m.limits.CPUMaxMillis * cpuMaxPeriod / 1000
+		if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuMaxPeriod)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	remove := func() {
+		// Only empty cgroups can be removed, which is the case once the process has exited.
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			klog.V(4).Infof("Failed to remove cgroup %s: %v", dir, err)
+		}
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("Failed to open cgroup %s: %w", dir, err)
+	}
+	return fd, remove, nil
+}
+
+// writeCgroupFile writes `value` to `file` of cgroup `dir`, which is created if it does not exist.
+func writeCgroupFile(dir string, file string, value string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create cgroup %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("Failed to write %q to %s of cgroup %s: %w", value, file, dir, err)
+	}
+	return nil
+}
+
+// currentCgroup returns the cgroup v2 path of the current process relative to the cgroup root.
+func currentCgroup() (string, error) {
+	f, err := os.Open(selfCgroupPath)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read cgroup of current process: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// cgroup v2 has a single hierarchy with ID 0 and no controllers, e.g. `0::/kubepods/pod1/container1`.
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("Failed to read cgroup of current process: %w", err)
+	}
+	return "", fmt.Errorf("cgroup v2 is not available, the current process is not in a cgroup v2 hierarchy")
+}
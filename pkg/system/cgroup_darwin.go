@@ -0,0 +1,11 @@
+package system
+
+import (
+	"errors"
+	"syscall"
+)
+
+// cgroupSysProcAttr is not supported on Darwin, as there are no cgroups.
+func cgroupSysProcAttr(fd int) (*syscall.SysProcAttr, error) {
+	return nil, errors.New("cgroups are not supported on darwin")
+}
@@ -0,0 +1,9 @@
+package system
+
+import "syscall"
+
+// cgroupSysProcAttr returns attributes to start a process directly in the cgroup of `fd`, so it never runs
+// outside of its limits (requires Linux 5.7+).
+func cgroupSysProcAttr(fd int) (*syscall.SysProcAttr, error) {
+	return &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: fd}, nil
+}
@@ -0,0 +1,242 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// processOutputLimit is the maximum number of bytes of output kept for each process started by [ProcessSupervisor].
+const processOutputLimit = 64 * 1024
+
+// processReadyPollInterval is the interval to check whether a service started by [ProcessSupervisor] is ready.
+const processReadyPollInterval = 100 * time.Millisecond
+
+// processRestartTimeout is the timeout for a restarted service to get ready.
+const processRestartTimeout = time.Minute
+
+// processRestartResetAfter is how long a service needs to run to reset its restart count.
+const processRestartResetAfter = 10 * time.Minute
+
+// A ProcessRestartPolicy configures how services started by [ProcessSupervisor] are restarted if they exit with an error.
+type ProcessRestartPolicy struct {
+	// MaxRestarts is the maximum number of times to restart a service in a row, services are not restarted if 0.
+	// The count is reset once a service runs longer than 10 minutes.
+	MaxRestarts int
+	// Backoff is the initial backoff before restarting a service, doubled with each restart up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultProcessRestartPolicy is the default restart policy of [ProcessSupervisor].
+var DefaultProcessRestartPolicy = ProcessRestartPolicy{MaxRestarts: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// A ProcessSupervisor runs services as child processes of the current process, as an alternative to [SystemdSupervisor]
+// for environments without access to systemd of the host.
+//
+// Services must run in foreground, and they're considered started once `isReady` returns true for them. Services
+// exiting with an error after getting ready (e.g., killed for exceeding their memory limit) are restarted according
+// to RestartPolicy if `prepareRestart` allows it, while services exiting successfully are not restarted. Resources of
+// services can be limited with [ProcessSupervisor.EnableLimits], which starts each of them in its own cgroup. Services
+// terminate with the current process.
+type ProcessSupervisor struct {
+	RestartPolicy ProcessRestartPolicy
+	// CgroupRoot is where cgroup v2 is mounted, [DefaultCgroupRoot] is used if empty.
+	CgroupRoot string
+
+	isReady        func(config *ExecConfig) (bool, error)
+	prepareRestart func(config *ExecConfig) (bool, error)
+	// cgroups starts services in cgroups limiting their resources, or nil if they're not limited.
+	cgroups *cgroupManager
+}
+
+// NewProcessSupervisor returns a new supervisor considering services started once `isReady` returns true for them.
+// Services exiting with an error are restarted if `prepareRestart` returns true for them, which is called before each
+// restart to clean up after the exited process (e.g., its broken mount). They're never restarted if it's nil.
+func NewProcessSupervisor(isReady func(config *ExecConfig) (bool, error), prepareRestart func(config *ExecConfig) (bool, error)) *ProcessSupervisor {
+	return &ProcessSupervisor{RestartPolicy: DefaultProcessRestartPolicy, isReady: isReady, prepareRestart: prepareRestart}
+}
+
+// EnableLimits limits resources of each service to `limits` from now on, by starting them in cgroups of their own.
+// It must be called before any service is started, as the cgroup of the current process can't have child cgroups
+// with controllers enabled once it has child processes. It fails if cgroup v2 is not available.
+func (s *ProcessSupervisor) EnableLimits(limits ResourceLimits) error {
+	if limits.IsZero() {
+		return nil
+	}
+	root := s.CgroupRoot
+	if root == "" {
+		root = DefaultCgroupRoot
+	}
+	cgroups, err := newCgroupManager(root, limits)
+	if err != nil {
+		return fmt.Errorf("Failed to set up cgroups to limit resources of processes: %w", err)
+	}
+	s.cgroups = cgroups
+	return nil
+}
+
+// A process is a running process of a service started by [ProcessSupervisor].
+type process struct {
+	// exited receives the result of the process once it exits.
+	exited    chan error
+	startedAt time.Time
+}
+
+// StartService starts the service described by `config` as a child process, and waits until it's ready.
+// The service is killed if it does not get ready before `ctx` is done, and it's supervised afterwards.
+func (s *ProcessSupervisor) StartService(ctx context.Context, config *ExecConfig) (string, error) {
+	p, output, err := s.start(ctx, config)
+	if err != nil {
+		return output, err
+	}
+	go s.supervise(config, p)
+	return output, nil
+}
+
+// start starts the service described by `config` as a child process, and waits until it's ready or `ctx` is done.
+func (s *ProcessSupervisor) start(ctx context.Context, config *ExecConfig) (*process, string, error) {
+	output := &limitedBuffer{limit: processOutputLimit}
+	cmd := exec.Command(config.ExecPath, config.Args...)
+	cmd.Env = config.Env
+	cmd.Stdout, cmd.Stderr = output, output
+
+	removeCgroup := func() {}
+	if s.cgroups != nil {
+		// The process is started directly in its cgroup, so it never runs without limits.
+		cgroup, remove, err := s.cgroups.create(config.Name)
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to limit resources of process: %w", err)
+		}
+		defer cgroup.Close()
+		removeCgroup = remove
+		cmd.SysProcAttr, err = cgroupSysProcAttr(int(cgroup.Fd()))
+		if err != nil {
+			removeCgroup()
+			return nil, "", fmt.Errorf("Failed to limit resources of process: %w", err)
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		removeCgroup()
+		return nil, "", fmt.Errorf("Failed to start process: %w", err)
+	}
+	klog.V(5).Infof("Started process %s for %s, pid: %d", config.ExecPath, config.Name, cmd.Process.Pid)
+
+	p := &process{exited: make(chan error, 1), startedAt: time.Now()}
+	go func() {
+		err := cmd.Wait()
+		removeCgroup()
+		if err != nil {
+			klog.Warningf("Process %s for %s exited: %v, output: %s", config.ExecPath, config.Name, err, output.String())
+		} else {
+			klog.V(4).Infof("Process %s for %s exited", config.ExecPath, config.Name)
+		}
+		p.exited <- err
+	}()
+
+	ticker := time.NewTicker(processReadyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-p.exited:
+			if err == nil {
+				err = fmt.Errorf("process exited before getting ready")
+			}
+			return nil, output.String(), err
+		case <-ticker.C:
+			ready, err := s.isReady(config)
+			if err != nil {
+				klog.V(4).Infof("Failed to check if %s is ready: %v", config.Name, err)
+			}
+			if ready {
+				return p, output.String(), nil
+			}
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return nil, output.String(), fmt.Errorf("Failed to start process, context cancelled")
+		}
+	}
+}
+
+// supervise waits for process `p` of the service described by `config` to exit, and restarts the service with
+// backoff according to the restart policy if it exits with an error.
+func (s *ProcessSupervisor) supervise(config *ExecConfig, p *process) {
+	restarts := 0
+	for {
+		err := <-p.exited
+		if err == nil || s.prepareRestart == nil {
+			return
+		}
+		if time.Since(p.startedAt) > processRestartResetAfter {
+			restarts = 0
+		}
+		if restarts >= s.RestartPolicy.MaxRestarts {
+			klog.Warningf("Process %s for %s exited after %d restarts, not restarting it anymore", config.ExecPath, config.Name, restarts)
+			return
+		}
+		restarts++
+		time.Sleep(min(s.RestartPolicy.Backoff<<(restarts-1), s.RestartPolicy.MaxBackoff))
+
+		restart, err := s.prepareRestart(config)
+		if err != nil {
+			klog.Warningf("Failed to prepare restarting process %s for %s, not restarting it: %v", config.ExecPath, config.Name, err)
+			return
+		}
+		if !restart {
+			klog.V(4).Infof("Process %s for %s is not needed anymore, not restarting it", config.ExecPath, config.Name)
+			return
+		}
+
+		klog.Infof("Restarting process %s for %s, restart %d of %d", config.ExecPath, config.Name, restarts, s.RestartPolicy.MaxRestarts)
+		ctx, cancel := context.WithTimeout(context.Background(), processRestartTimeout)
+		restarted, output, err := s.start(ctx, config)
+		cancel()
+		if err != nil {
+			klog.Warningf("Failed to restart process %s for %s: %v, output: %s", config.ExecPath, config.Name, err, output)
+			restarted = &process{exited: make(chan error, 1), startedAt: time.Now()}
+			restarted.exited <- err
+		}
+		p = restarted
+	}
+}
+
+// RunOneshot runs the command described by `config` as a child process, and waits until it exits.
+func (s *ProcessSupervisor) RunOneshot(ctx context.Context, config *ExecConfig) (string, error) {
+	cmd := exec.CommandContext(ctx, config.ExecPath, config.Args...)
+	cmd.Env = config.Env
+	output, err := cmd.CombinedOutput()
+	out := strings.TrimSpace(string(output))
+	if err != nil {
+		return out, fmt.Errorf("Failed to run %s: %w", config.ExecPath, err)
+	}
+	return out, nil
+}
+
+// A limitedBuffer keeps the first `limit` bytes written to it, and discards the rest.
+type limitedBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		b.buf.Write(p[:min(len(p), remaining)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(b.buf.String())
+}
@@ -0,0 +1,197 @@
+package system_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/system"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestProcessSupervisor(t *testing.T) {
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	supervisor := system.NewProcessSupervisor(func(config *system.ExecConfig) (bool, error) {
+		_, err := os.Stat(readyFile)
+		return err == nil, nil
+	}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("Ready service", func(t *testing.T) {
+		out, err := supervisor.StartService(ctx, &system.ExecConfig{
+			Name:     "ready.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "echo starting; touch " + readyFile + "; sleep 1"},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, "starting", out)
+		os.Remove(readyFile)
+	})
+
+	t.Run("Service exiting before getting ready", func(t *testing.T) {
+		out, err := supervisor.StartService(ctx, &system.ExecConfig{
+			Name:     "failing.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "echo failed to mount >&2; exit 1"},
+		})
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+		assert.Equals(t, "failed to mount", out)
+	})
+
+	t.Run("Service not getting ready in time", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer cancel()
+		_, err := supervisor.StartService(ctx, &system.ExecConfig{
+			Name:     "slow.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "sleep 10"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "context cancelled") {
+			t.Fatalf("Expected a cancellation error, got: %v", err)
+		}
+	})
+
+	t.Run("Oneshot", func(t *testing.T) {
+		out, err := supervisor.RunOneshot(ctx, &system.ExecConfig{
+			Name:     "oneshot.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "echo done"},
+		})
+		assert.NoError(t, err)
+		assert.Equals(t, "done", out)
+	})
+}
+
+func TestProcessSupervisorRestarts(t *testing.T) {
+	dir := t.TempDir()
+	readyFile, stopFile := filepath.Join(dir, "ready"), filepath.Join(dir, "stop")
+	restarts := make(chan struct{}, 10)
+	supervisor := system.NewProcessSupervisor(func(config *system.ExecConfig) (bool, error) {
+		_, err := os.Stat(readyFile)
+		return err == nil, nil
+	}, func(config *system.ExecConfig) (bool, error) {
+		restarts <- struct{}{}
+		_, err := os.Stat(stopFile)
+		return os.IsNotExist(err), nil
+	})
+	supervisor.RestartPolicy = system.ProcessRestartPolicy{MaxRestarts: 2, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+	waitRestarts := func(t *testing.T, expected int) {
+		t.Helper()
+		for i := range expected {
+			select {
+			case <-restarts:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Expected %d restarts, got %d", expected, i)
+			}
+		}
+		select {
+		case <-restarts:
+			t.Fatalf("Expected only %d restarts", expected)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	t.Run("Restarts failing service up to the limit", func(t *testing.T) {
+		_, err := supervisor.StartService(context.Background(), &system.ExecConfig{
+			Name:     "crashing.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "touch " + readyFile + "; sleep 0.2; exit 1"},
+		})
+		assert.NoError(t, err)
+		waitRestarts(t, 2)
+	})
+
+	t.Run("Does not restart successfully exited service", func(t *testing.T) {
+		_, err := supervisor.StartService(context.Background(), &system.ExecConfig{
+			Name:     "unmounted.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "touch " + readyFile + "; sleep 0.2"},
+		})
+		assert.NoError(t, err)
+		waitRestarts(t, 0)
+	})
+
+	t.Run("Does not restart service if not needed anymore", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(stopFile, nil, 0644))
+		_, err := supervisor.StartService(context.Background(), &system.ExecConfig{
+			Name:     "force-unmounted.service",
+			ExecPath: "/bin/sh",
+			Args:     []string{"-c", "touch " + readyFile + "; sleep 0.2; exit 1"},
+		})
+		assert.NoError(t, err)
+		waitRestarts(t, 1)
+	})
+}
+
+func TestProcessSupervisorLimits(t *testing.T) {
+	// cgroup v2 is faked with a directory, as moving processes between real cgroups needs privileges
+	self, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		t.Skipf("cgroups are not available: %v", err)
+	}
+	var selfCgroup string
+	for _, line := range strings.Split(string(self), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			selfCgroup = path
+		}
+	}
+	if selfCgroup == "" {
+		t.Skipf("cgroup v2 is not available: %s", self)
+	}
+	root := t.TempDir()
+	parent := filepath.Join(root, selfCgroup)
+	assert.NoError(t, os.MkdirAll(parent, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(parent, "cgroup.controllers"), []byte("cpu memory"), 0644))
+
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	supervisor := system.NewProcessSupervisor(func(config *system.ExecConfig) (bool, error) {
+		_, err := os.Stat(readyFile)
+		return err == nil, nil
+	}, nil)
+	supervisor.CgroupRoot = root
+	assert.NoError(t, supervisor.EnableLimits(system.ResourceLimits{MemoryMax: 2 << 30, CPUMaxMillis: 500}))
+
+	readFile := func(path ...string) string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(append([]string{parent}, path...)...))
+		assert.NoError(t, err)
+		return string(content)
+	}
+	// Controllers are enabled before any process is started, once the current process is moved out of its cgroup
+	assert.Equals(t, strconv.Itoa(os.Getpid()), readFile("supervisor", "cgroup.procs"))
+	assert.Equals(t, "+cpu +memory", readFile("cgroup.subtree_control"))
+
+	// Processes are started directly in their cgroups, which fails with a directory faking a cgroup,
+	// so the process never runs without its limits
+	_, err = supervisor.StartService(context.Background(), &system.ExecConfig{
+		Name:     "limited.service",
+		ExecPath: "/bin/sh",
+		Args:     []string{"-c", "touch " + readyFile + "; sleep 1"},
+	})
+	if err == nil {
+		t.Fatal("Expected starting the process in a fake cgroup to fail")
+	}
+	if _, err := os.Stat(readyFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected the process not to run outside of its cgroup, got: %v", err)
+	}
+	assert.Equals(t, "2147483648", readFile("limited.service", "memory.max"))
+	assert.Equals(t, "50000 100000", readFile("limited.service", "cpu.max"))
+}
+
+func TestProcessSupervisorLimitsWithoutCgroupV2(t *testing.T) {
+	supervisor := system.NewProcessSupervisor(nil, nil)
+	supervisor.CgroupRoot = t.TempDir()
+	if err := supervisor.EnableLimits(system.ResourceLimits{MemoryMax: 1 << 30}); err == nil {
+		t.Fatal("Expected enabling limits to fail without cgroup v2")
+	}
+	// Limits are not enabled without any limit
+	assert.NoError(t, supervisor.EnableLimits(system.ResourceLimits{}))
+}
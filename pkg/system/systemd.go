@@ -397,7 +397,7 @@ func (sd *SystemdSupervisor) runUnit(ctx context.Context, config *ExecConfig, se
 			}
 
 		case <-ctx.Done():
-			return readOutput(), fmt.Errorf("Failed to start systemd unit, context cancelled")
+			return readOutput(), fmt.Errorf("Failed to start systemd unit, context cancelled: %w", ctx.Err())
 		}
 	}
 	return readOutput(), nil
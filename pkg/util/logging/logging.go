@@ -0,0 +1,74 @@
+// Package logging configures log output format of the CSI Driver binaries.
+package logging
+
+import (
+	"flag"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// Supported log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Common keys used in structured logs of the CSI Driver binaries.
+const (
+	KeyVolumeID   = "volume_id"
+	KeyPodUID     = "pod_uid"
+	KeyTargetPath = "target_path"
+)
+
+// RegisterFlag registers `--log-format` flag with given `defaultFormat` to the default flag set and returns its value.
+func RegisterFlag(defaultFormat string) *string {
+	return RegisterFlagSet(flag.CommandLine, defaultFormat)
+}
+
+// RegisterFlagSet registers `--log-format` flag with given `defaultFormat` to `flags` and returns its value,
+// e.g. for subcommands with flag sets of their own.
+func RegisterFlagSet(flags *flag.FlagSet, defaultFormat string) *string {
+	return flags.String("log-format", defaultFormat, fmt.Sprintf("Format of the logs, either %q or %q.", FormatText, FormatJSON))
+}
+
+// ValidateFormat returns an error if `format` is not a supported log format.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported log format %q, supported formats are %q and %q", format, FormatText, FormatJSON)
+	}
+}
+
+// ZapOptions returns options to configure a zap logger to emit logs in given `format`.
+func ZapOptions(format string) []zap.Opts {
+	if format == FormatJSON {
+		return []zap.Opts{zap.JSONEncoder()}
+	}
+	return []zap.Opts{zap.ConsoleEncoder()}
+}
+
+// SetupKlog configures klog to emit logs in given `format`.
+//
+// With JSON format, klog is backed by a zap logger writing one JSON object per line to stderr,
+// and structured logs (e.g., `klog.InfoS`) emit their key-value pairs as JSON fields.
+// With text format, klog's configuration is left untouched.
+func SetupKlog(format string) error {
+	if err := ValidateFormat(format); err != nil {
+		return err
+	}
+
+	if format != FormatJSON {
+		return nil
+	}
+
+	// klog filters logs using its own `-v` flag before passing them to the logger,
+	// so the zap logger should emit logs at all verbosity levels.
+	opts := append(ZapOptions(format), zap.Level(zapcore.Level(-128)))
+	klog.SetLogger(zap.New(opts...))
+	return nil
+}
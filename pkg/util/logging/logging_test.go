@@ -0,0 +1,16 @@
+package logging_test
+
+import (
+	"testing"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/logging"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/util/testutil/assert"
+)
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, logging.ValidateFormat(logging.FormatText))
+	assert.NoError(t, logging.ValidateFormat(logging.FormatJSON))
+	if err := logging.ValidateFormat("yaml"); err == nil {
+		t.Fatal("expected an error for unsupported log format")
+	}
+}
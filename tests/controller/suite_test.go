@@ -77,7 +77,7 @@ var _ = BeforeSuite(func() {
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme})
 	Expect(err).ToNot(HaveOccurred())
 
-	err = csicontroller.NewReconciler(k8sManager.GetClient(), mppod.Config{
+	err = csicontroller.NewReconciler(k8sManager.GetClient(), k8sManager.GetEventRecorderFor(csicontroller.Name), mppod.Config{
 		Namespace:         mountpointNamespace,
 		MountpointVersion: mountpointVersion,
 		Container: mppod.ContainerConfig{
@@ -86,7 +86,7 @@ var _ = BeforeSuite(func() {
 			ImagePullPolicy: mountpointImagePullPolicy,
 		},
 		CSIDriverVersion: version.GetVersion().DriverVersion,
-	}).SetupWithManager(k8sManager)
+	}, csicontroller.DefaultRestartPolicy).SetupWithManager(ctx, k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
@@ -2,6 +2,7 @@ package e2e
 
 import (
 	"flag"
+	"os"
 	"testing"
 
 	"github.com/awslabs/aws-s3-csi-driver/tests/e2e-kubernetes/s3client"
@@ -26,11 +27,13 @@ func init() {
 	flag.StringVar(&BucketPrefix, "bucket-prefix", "local", "prefix for temporary buckets")
 	flag.BoolVar(&Performance, "performance", false, "run performance tests")
 	flag.BoolVar(&IMDSAvailable, "imds-available", false, "indicates whether instance metadata service is available")
+	flag.BoolVar(&DirectoryBuckets, "directory-buckets", os.Getenv("S3_CSI_E2E_DIRECTORY_BUCKETS") == "true", "run tests for S3 Express One Zone directory buckets")
 	flag.Parse()
 
 	s3client.DefaultRegion = BucketRegion
 	custom_testsuites.DefaultRegion = BucketRegion
 	custom_testsuites.IMDSAvailable = IMDSAvailable
+	custom_testsuites.DirectoryBucketTests = DirectoryBuckets
 }
 
 func TestE2E(t *testing.T) {
@@ -60,6 +63,8 @@ var CSITestSuites = []func() framework.TestSuite{
 	custom_testsuites.InitS3MountOptionsTestSuite,
 	custom_testsuites.InitS3CSICredentialsTestSuite,
 	custom_testsuites.InitS3CSICacheTestSuite,
+	custom_testsuites.InitS3CSIPrefixTestSuite,
+	custom_testsuites.InitS3CSIDirectoryBucketTestSuite,
 }
 
 // This executes testSuites for csi volumes.
@@ -25,6 +25,8 @@ function eksctl_create_cluster() {
   NODE_TYPE=${10}
   AMI_FAMILY=${11}
   K8S_VERSION=${12}
+  IP_FAMILY=${13:-IPv4}
+  EKSCTL_PATCH_IPV6_FILE=${14:-}
 
   eksctl_delete_cluster "$BIN" "$CLUSTER_NAME" "$REGION"
 
@@ -42,6 +44,12 @@ function eksctl_create_cluster() {
   CLUSTER_FILE_TMP="${CLUSTER_FILE}.tmp"
   ${KUBECTL_BIN} patch -f $CLUSTER_FILE --local --type json --patch "$(cat $EKSCTL_PATCH_FILE)" -o yaml > $CLUSTER_FILE_TMP
   mv $CLUSTER_FILE_TMP $CLUSTER_FILE
+  if [[ "${IP_FAMILY}" == "IPv6" ]]; then
+    # IPv6 clusters require OIDC and the VPC CNI, CoreDNS and kube-proxy addons to be managed by eksctl,
+    # and IMDS to be reachable over IPv6 from the nodes.
+    ${KUBECTL_BIN} patch -f $CLUSTER_FILE --local --type json --patch "$(cat $EKSCTL_PATCH_IPV6_FILE)" -o yaml > $CLUSTER_FILE_TMP
+    mv $CLUSTER_FILE_TMP $CLUSTER_FILE
+  fi
   ${BIN} create cluster -f "${CLUSTER_FILE}" --kubeconfig "${KUBECONFIG}"
 
   if [ -n "$CI_ROLE_ARN" ]; then
@@ -36,6 +36,7 @@ function helm_install_driver() {
   REPOSITORY=${4}
   TAG=${5}
   KUBECONFIG=${6}
+  IP_FAMILY=${7:-IPv4}
   helm_uninstall_driver \
     "$HELM_BIN" \
     "$KUBECTL_BIN" \
@@ -48,6 +49,7 @@ function helm_install_driver() {
     --set image.pullPolicy=Always \
     --set node.serviceAccount.create=true \
     --set node.podInfoOnMountCompat.enable=true \
+    --set node.ipFamily=${IP_FAMILY} \
     --kubeconfig ${KUBECONFIG}
   $KUBECTL_BIN rollout status daemonset s3-csi-node -n kube-system --timeout=60s --kubeconfig $KUBECONFIG
   $KUBECTL_BIN get pods -A --kubeconfig $KUBECONFIG
@@ -27,6 +27,8 @@ KUBECTL_BIN=${KUBECTL_INSTALL_PATH}/kubectl
 CLUSTER_TYPE=${CLUSTER_TYPE:-kops}
 ARCH=${ARCH:-x86}
 AMI_FAMILY=${AMI_FAMILY:-AmazonLinux2}
+# IPv6 is only supported with eksctl, which creates an IPv6-only cluster
+IP_FAMILY=${IP_FAMILY:-IPv4}
 
 # kops: must include patch version (e.g. 1.19.1)
 # eksctl: mustn't include patch version (e.g. 1.19)
@@ -38,6 +40,13 @@ K8S_VERSION_EKSCTL=${K8S_VERSION_EKSCTL:-${K8S_VERSION%.*}}
 # We need to ensure that we're using all testing matrix variables in the cluster name
 # because they all run in parallel and conflicting name would break other tests.
 CLUSTER_NAME="s3-csi-cluster-${CLUSTER_TYPE}-${AMI_FAMILY,,}-${ARCH}"
+if [[ "${IP_FAMILY}" == "IPv6" ]]; then
+    if [[ "${CLUSTER_TYPE}" != "eksctl" ]]; then
+        echo "IP_FAMILY=IPv6 is only supported with CLUSTER_TYPE=eksctl"
+        exit 1
+    fi
+    CLUSTER_NAME="${CLUSTER_NAME}-ipv6"
+fi
 
 if [[ "${CLUSTER_TYPE}" == "eksctl" ]]; then
     # EKS does not allow cluster names with ".", we're replacing them with "-".
@@ -72,6 +81,7 @@ HELM_RELEASE_NAME=mountpoint-s3-csi-driver
 
 EKSCTL_VERSION=${EKSCTL_VERSION:-0.202.0}
 EKSCTL_PATCH_FILE=${EKSCTL_PATCH_FILE:-${BASE_DIR}/eksctl-patch.json}
+EKSCTL_PATCH_IPV6_FILE=${EKSCTL_PATCH_IPV6_FILE:-${BASE_DIR}/eksctl-patch-ipv6.json}
 CI_ROLE_ARN=${CI_ROLE_ARN:-""}
 
 mkdir -p ${TEST_DIR}
@@ -134,7 +144,9 @@ function create_cluster() {
       "$CI_ROLE_ARN" \
       "$INSTANCE_TYPE" \
       "$AMI_FAMILY" \
-      "$K8S_VERSION_EKSCTL"
+      "$K8S_VERSION_EKSCTL" \
+      "$IP_FAMILY" \
+      "$EKSCTL_PATCH_IPV6_FILE"
   fi
 }
 
@@ -194,7 +206,8 @@ elif [[ "${ACTION}" == "install_driver" ]]; then
     "$HELM_RELEASE_NAME" \
     "${REGISTRY}/${IMAGE_NAME}" \
     "${TAG}" \
-    "${KUBECONFIG}"
+    "${KUBECONFIG}" \
+    "${IP_FAMILY}"
 elif [[ "${ACTION}" == "run_tests" ]]; then
   set +e
   pushd tests/e2e-kubernetes
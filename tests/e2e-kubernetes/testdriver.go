@@ -14,11 +14,12 @@ import (
 )
 
 var (
-	CommitId      string
-	BucketRegion  string // assumed to be the same as k8s cluster's region
-	BucketPrefix  string
-	Performance   bool
-	IMDSAvailable bool
+	CommitId         string
+	BucketRegion     string // assumed to be the same as k8s cluster's region
+	BucketPrefix     string
+	Performance      bool
+	IMDSAvailable    bool
+	DirectoryBuckets bool
 )
 
 type s3Driver struct {
@@ -30,6 +31,7 @@ type s3Volume struct {
 	bucketName           string
 	deleteBucket         s3client.DeleteBucketFunc
 	authenticationSource string
+	volumeAttributes     map[string]string
 }
 
 var _ framework.TestDriver = &s3Driver{}
@@ -93,6 +95,7 @@ func (d *s3Driver) CreateVolume(ctx context.Context, config *framework.PerTestCo
 		bucketName:           bucketName,
 		deleteBucket:         deleteBucket,
 		authenticationSource: custom_testsuites.AuthenticationSourceFromContext(ctx),
+		volumeAttributes:     custom_testsuites.VolumeAttributesFromContext(ctx),
 	}
 }
 
@@ -104,6 +107,10 @@ func (d *s3Driver) GetPersistentVolumeSource(readOnly bool, fsType string, testV
 		f.Logf("Using authentication source %s for volume", volume.authenticationSource)
 		volumeAttributes["authenticationSource"] = volume.authenticationSource
 	}
+	for key, value := range volume.volumeAttributes {
+		f.Logf("Using volume attribute %s=%s for volume", key, value)
+		volumeAttributes[key] = value
+	}
 
 	return &v1.PersistentVolumeSource{
 		CSI: &v1.CSIPersistentVolumeSource{
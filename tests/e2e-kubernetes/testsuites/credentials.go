@@ -911,3 +911,17 @@ func AuthenticationSourceFromContext(ctx context.Context) string {
 	val, _ := ctx.Value(authenticationSourceKey).(string)
 	return val
 }
+
+const volumeAttributesKey contextKey = "volumeAttributes"
+
+// contextWithVolumeAttributes enhances given context with given additional volume attributes.
+// This value is used by `s3Volume.CreateVolume` and `s3Volume.GetPersistentVolumeSource` similar to `contextWithAuthenticationSource`.
+func contextWithVolumeAttributes(ctx context.Context, volumeAttributes map[string]string) context.Context {
+	return context.WithValue(ctx, volumeAttributesKey, volumeAttributes)
+}
+
+// VolumeAttributesFromContext returns additional volume attributes set for given context.
+func VolumeAttributesFromContext(ctx context.Context) map[string]string {
+	val, _ := ctx.Value(volumeAttributesKey).(map[string]string)
+	return val
+}
@@ -0,0 +1,115 @@
+package custom_testsuites
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/kubernetes/test/e2e/framework"
+	e2epod "k8s.io/kubernetes/test/e2e/framework/pod"
+	e2eskipper "k8s.io/kubernetes/test/e2e/framework/skipper"
+	e2evolume "k8s.io/kubernetes/test/e2e/framework/volume"
+	storageframework "k8s.io/kubernetes/test/e2e/storage/framework"
+	admissionapi "k8s.io/pod-security-admission/api"
+)
+
+// DirectoryBucketTests indicates whether to run tests relying on features of S3 Express One Zone directory buckets.
+// Set by `--directory-buckets` flag or `S3_CSI_E2E_DIRECTORY_BUCKETS=true` environment variable.
+var DirectoryBucketTests bool
+
+type s3CSIDirectoryBucketTestSuite struct {
+	tsInfo storageframework.TestSuiteInfo
+}
+
+func InitS3CSIDirectoryBucketTestSuite() storageframework.TestSuite {
+	return &s3CSIDirectoryBucketTestSuite{
+		tsInfo: storageframework.TestSuiteInfo{
+			Name: "directorybucket",
+			TestPatterns: []storageframework.TestPattern{
+				storageframework.DefaultFsPreprovisionedPV,
+			},
+		},
+	}
+}
+
+func (t *s3CSIDirectoryBucketTestSuite) GetTestSuiteInfo() storageframework.TestSuiteInfo {
+	return t.tsInfo
+}
+
+func (t *s3CSIDirectoryBucketTestSuite) SkipUnsupportedTests(_ storageframework.TestDriver, pattern storageframework.TestPattern) {
+	if pattern.VolType != storageframework.PreprovisionedPV {
+		e2eskipper.Skipf("Suite %q does not support %v", t.tsInfo.Name, pattern.VolType)
+	}
+	if !DirectoryBucketTests {
+		e2eskipper.Skipf("Suite %q requires --directory-buckets", t.tsInfo.Name)
+	}
+}
+
+func (t *s3CSIDirectoryBucketTestSuite) DefineTests(driver storageframework.TestDriver, pattern storageframework.TestPattern) {
+	f := framework.NewFrameworkWithCustomTimeouts(NamespacePrefix+"directorybucket", storageframework.GetDriverTimeouts(driver))
+	f.NamespacePodSecurityLevel = admissionapi.LevelRestricted
+
+	type local struct {
+		resources []*storageframework.VolumeResource
+		config    *storageframework.PerTestConfig
+	}
+	var l local
+
+	cleanup := func(ctx context.Context) {
+		var errs []error
+		for _, resource := range l.resources {
+			errs = append(errs, resource.CleanupResource(ctx))
+		}
+		framework.ExpectNoError(errors.NewAggregate(errs), "while cleanup resource")
+	}
+	BeforeEach(func(ctx context.Context) {
+		l = local{}
+		l.config = driver.PrepareTest(ctx, f)
+		l.config.Prefix = S3ExpressTestIdentifier
+		DeferCleanup(cleanup)
+	})
+
+	It("should append to existing objects with incremental uploads enabled automatically", func(ctx context.Context) {
+		resource := createVolumeResourceWithMountOptions(ctx, l.config, pattern, nil)
+		l.resources = append(l.resources, resource)
+
+		By("Creating pod with a volume")
+		pod := e2epod.MakePod(f.Namespace.Name, nil, []*v1.PersistentVolumeClaim{resource.Pvc}, admissionapi.LevelRestricted, "")
+		pod, err := createPod(ctx, f.ClientSet, f.Namespace.Name, pod)
+		framework.ExpectNoError(err)
+		defer func() {
+			framework.ExpectNoError(e2epod.DeletePodWithWait(ctx, f.ClientSet, pod))
+		}()
+
+		fileInVol := fmt.Sprintf("%s/file.txt", e2epod.VolumeMountPath1)
+		seed := time.Now().UTC().UnixNano()
+		toWrite := 1024 // 1KB
+
+		By("Checking write to a volume")
+		checkWriteToPath(f, pod, fileInVol, toWrite, seed)
+		By("Checking appending to an existing file")
+		e2evolume.VerifyExecInPodSucceed(f, pod, fmt.Sprintf("echo appended >> %s", fileInVol))
+		e2evolume.VerifyExecInPodSucceed(f, pod, fmt.Sprintf("tail -c 9 %s | grep -Fq appended", fileInVol))
+		By("Checking the original content is preserved")
+		checkReadFromPath(f, pod, fileInVol, toWrite, seed)
+	})
+
+	It("should fail to mount with mount options not supported by directory buckets", func(ctx context.Context) {
+		resource := createVolumeResourceWithMountOptions(ctx, l.config, pattern, []string{"storage-class STANDARD_IA"})
+		l.resources = append(l.resources, resource)
+
+		By("Creating pod with a volume")
+		pod, err := e2epod.CreateUnschedulablePod(ctx, f.ClientSet, f.Namespace.Name, nil, []*v1.PersistentVolumeClaim{resource.Pvc}, admissionapi.LevelRestricted, "")
+		framework.ExpectNoError(err)
+		defer func() {
+			framework.ExpectNoError(e2epod.DeletePodWithWait(ctx, f.ClientSet, pod))
+		}()
+
+		By("Checking the pod fails to start")
+		err = e2epod.WaitTimeoutForPodRunningInNamespace(ctx, f.ClientSet, pod.Name, f.Namespace.Name, 30*time.Second)
+		framework.ExpectError(err)
+	})
+}
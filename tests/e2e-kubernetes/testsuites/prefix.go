@@ -0,0 +1,112 @@
+package custom_testsuites
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/onsi/ginkgo/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/kubernetes/test/e2e/framework"
+	e2epod "k8s.io/kubernetes/test/e2e/framework/pod"
+	e2eskipper "k8s.io/kubernetes/test/e2e/framework/skipper"
+	storageframework "k8s.io/kubernetes/test/e2e/storage/framework"
+	admissionapi "k8s.io/pod-security-admission/api"
+)
+
+type s3CSIPrefixTestSuite struct {
+	tsInfo storageframework.TestSuiteInfo
+}
+
+func InitS3CSIPrefixTestSuite() storageframework.TestSuite {
+	return &s3CSIPrefixTestSuite{
+		tsInfo: storageframework.TestSuiteInfo{
+			Name: "prefix",
+			TestPatterns: []storageframework.TestPattern{
+				storageframework.DefaultFsPreprovisionedPV,
+			},
+		},
+	}
+}
+
+func (t *s3CSIPrefixTestSuite) GetTestSuiteInfo() storageframework.TestSuiteInfo {
+	return t.tsInfo
+}
+
+func (t *s3CSIPrefixTestSuite) SkipUnsupportedTests(_ storageframework.TestDriver, pattern storageframework.TestPattern) {
+	if pattern.VolType != storageframework.PreprovisionedPV {
+		e2eskipper.Skipf("Suite %q does not support %v", t.tsInfo.Name, pattern.VolType)
+	}
+}
+
+func (t *s3CSIPrefixTestSuite) DefineTests(driver storageframework.TestDriver, pattern storageframework.TestPattern) {
+	f := framework.NewFrameworkWithCustomTimeouts(NamespacePrefix+"prefix", storageframework.GetDriverTimeouts(driver))
+	f.NamespacePodSecurityLevel = admissionapi.LevelRestricted
+
+	type local struct {
+		resources []*storageframework.VolumeResource
+		config    *storageframework.PerTestConfig
+	}
+	var l local
+
+	cleanup := func(ctx context.Context) {
+		var errs []error
+		for _, resource := range l.resources {
+			errs = append(errs, resource.CleanupResource(ctx))
+		}
+		framework.ExpectNoError(errors.NewAggregate(errs), "while cleanup resource")
+	}
+	BeforeEach(func(ctx context.Context) {
+		l = local{}
+		l.config = driver.PrepareTest(ctx, f)
+		DeferCleanup(cleanup)
+	})
+
+	It("should only access objects under the prefix specified in volume attributes", func(ctx context.Context) {
+		prefix := "test-prefix/"
+		resource := createVolumeResourceWithMountOptions(contextWithVolumeAttributes(ctx, map[string]string{"prefix": prefix}), l.config, pattern, []string{"allow-delete"})
+		l.resources = append(l.resources, resource)
+		bucketName := bucketNameFromVolumeResource(resource)
+
+		By("Putting an object outside of the prefix")
+		client := s3.NewFromConfig(awsConfig(ctx))
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("outside-prefix.txt"),
+			Body:   strings.NewReader("hello"),
+		})
+		framework.ExpectNoError(err)
+
+		By("Creating pod with a volume")
+		pod := e2epod.MakePod(f.Namespace.Name, nil, []*v1.PersistentVolumeClaim{resource.Pvc}, admissionapi.LevelRestricted, "")
+		pod, err = createPod(ctx, f.ClientSet, f.Namespace.Name, pod)
+		framework.ExpectNoError(err)
+		defer func() {
+			framework.ExpectNoError(e2epod.DeletePodWithWait(ctx, f.ClientSet, pod))
+		}()
+
+		volPath := e2epod.VolumeMountPath1
+		fileInVol := fmt.Sprintf("%s/file.txt", volPath)
+		seed := time.Now().UTC().UnixNano()
+		toWrite := 1024 // 1KB
+
+		By("Checking write to a volume")
+		checkWriteToPath(f, pod, fileInVol, toWrite, seed)
+		By("Checking read from a volume")
+		checkReadFromPath(f, pod, fileInVol, toWrite, seed)
+
+		By("Checking the object is written under the prefix")
+		_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(prefix + "file.txt"),
+		})
+		framework.ExpectNoError(err)
+
+		By("Checking objects outside of the prefix are not visible")
+		checkListingPathWithEntries(f, pod, volPath, []string{"file.txt"})
+	})
+}
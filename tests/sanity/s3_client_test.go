@@ -0,0 +1,149 @@
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
+)
+
+// inMemoryS3Client is an in-memory [controller.S3Client] to run the controller server without S3.
+type inMemoryS3Client struct {
+	mu sync.Mutex
+	// buckets maps bucket names to their objects.
+	buckets map[string]map[string][]byte
+}
+
+var _ controller.S3Client = &inMemoryS3Client{}
+
+func newInMemoryS3Client() *inMemoryS3Client {
+	return &inMemoryS3Client{buckets: map[string]map[string][]byte{}}
+}
+
+func (c *inMemoryS3Client) CreateBucket(ctx context.Context, bucket string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.buckets[bucket]; !ok {
+		c.buckets[bucket] = map[string][]byte{}
+	}
+	return nil
+}
+
+func (c *inMemoryS3Client) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.buckets[bucket]
+	return ok, nil
+}
+
+func (c *inMemoryS3Client) DeleteBucket(ctx context.Context, bucket string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.buckets, bucket)
+	return nil
+}
+
+func (c *inMemoryS3Client) DeletePrefix(ctx context.Context, bucket string, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects, err := c.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(objects, key)
+		}
+	}
+	return nil
+}
+
+func (c *inMemoryS3Client) PrefixUsage(ctx context.Context, bucket string, prefix string, maxObjects int64) (controller.Usage, error) {
+	objects, err := c.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		return controller.Usage{}, err
+	}
+	var usage controller.Usage
+	for _, obj := range objects {
+		if maxObjects > 0 && usage.Objects >= maxObjects {
+			usage.Partial = true
+			break
+		}
+		usage.Bytes += obj.Size
+		usage.Objects++
+	}
+	return usage, nil
+}
+
+func (c *inMemoryS3Client) ListObjects(ctx context.Context, bucket string, prefix string) ([]controller.Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects, err := c.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var list []controller.Object
+	for key, body := range objects {
+		if strings.HasPrefix(key, prefix) {
+			list = append(list, controller.Object{Key: key, ETag: fmt.Sprintf("%q", fmt.Sprint(len(body))), Size: int64(len(body))})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+func (c *inMemoryS3Client) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects, err := c.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	body, ok := objects[key]
+	if !ok {
+		return nil, controller.ErrObjectNotFound
+	}
+	return body, nil
+}
+
+func (c *inMemoryS3Client) PutObject(ctx context.Context, bucket string, key string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects, err := c.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	objects[key] = body
+	return nil
+}
+
+func (c *inMemoryS3Client) DeleteObject(ctx context.Context, bucket string, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objects, err := c.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	delete(objects, key)
+	return nil
+}
+
+func (c *inMemoryS3Client) CopyObject(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string) error {
+	body, err := c.GetObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	return c.PutObject(ctx, dstBucket, dstKey, body)
+}
+
+// bucket returns the objects of `bucket`, the caller must hold the lock.
+func (c *inMemoryS3Client) bucket(bucket string) (map[string][]byte, error) {
+	objects, ok := c.buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("bucket %q does not exist", bucket)
+	}
+	return objects, nil
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -28,6 +29,7 @@ import (
 	sanity "github.com/kubernetes-csi/csi-test/pkg/sanity"
 
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver"
+	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/controller"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node"
 	"github.com/awslabs/aws-s3-csi-driver/pkg/driver/node/mounter"
 )
@@ -41,8 +43,19 @@ const (
 
 var s3Driver *driver.Driver
 
+// unsupportedSpecs are the specs of csi-test testing behavior the driver intentionally doesn't have.
+var unsupportedSpecs = []string{
+	// S3 has no notion of capacity, so capacity of volumes is not recorded to compare on retries of `CreateVolume`.
+	"should fail when requesting to create a volume with already existing name and different capacity",
+	// Volumes restored from snapshots are read-only, but csi-test always creates `SINGLE_NODE_WRITER` volumes.
+	"should create volume from an existing source snapshot",
+	// Manifests of snapshots are stored in the buckets of their source volumes, so snapshot names are unique per bucket.
+	"should fail when requesting to create a snapshot with already existing name and different SourceVolumeId",
+}
+
 func TestSanity(t *testing.T) {
 	RegisterFailHandler(Fail)
+	config.GinkgoConfig.SkipStrings = append(config.GinkgoConfig.SkipStrings, unsupportedSpecs...)
 	RunSpecs(t, "Sanity Tests Suite")
 }
 
@@ -67,14 +80,22 @@ func waitDriverIsUp(endpoint string) {
 }
 
 var _ = BeforeSuite(func() {
+	nodeServer := node.NewS3NodeServer(
+		"fake_id",
+		&mounter.FakeMounter{},
+		mounter.NewCredentialProvider(nil, GinkgoT().TempDir(), mounter.RegionFromIMDSOnce),
+	)
+	s3Client := newInMemoryS3Client()
+	controllerServer := controller.NewS3ControllerServer(s3Client)
+	controllerServer.SetCloner(&inlineCloner{client: s3Client})
+	// csi-test v2.2.0 predates `VOLUME_CONDITION` and `MODIFY_VOLUME` capabilities, and fails on capabilities it doesn't know
+	nodeServer.VolumeCondition = false
+	controllerServer.ModifyVolume = false
 	s3Driver = &driver.Driver{
-		Endpoint: endpoint,
-		NodeID:   "fake_id",
-		NodeServer: node.NewS3NodeServer(
-			"fake_id",
-			&mounter.FakeMounter{},
-			mounter.NewCredentialProvider(nil, GinkgoT().TempDir(), mounter.RegionFromIMDSOnce),
-		),
+		Endpoint:         endpoint,
+		NodeID:           "fake_id",
+		NodeServer:       nodeServer,
+		ControllerServer: controllerServer,
 	}
 	go func() {
 		Expect(s3Driver.Run()).NotTo(HaveOccurred())
@@ -98,3 +119,16 @@ var _ = Describe("Amazon S3 CSI Driver", func() {
 	}
 	sanity.GinkgoTest(config)
 })
+
+// inlineCloner clones volumes synchronously in the controller, instead of running clone Jobs in the cluster.
+type inlineCloner struct {
+	client controller.S3Client
+}
+
+func (c *inlineCloner) Clone(ctx context.Context, req controller.CloneRequest) (controller.CloneStatus, error) {
+	err := controller.CopyPrefix(ctx, c.client, req.Source, req.Target, controller.DefaultCloneConcurrency, func(copied, total int) {})
+	if err != nil {
+		return controller.CloneStatus{}, err
+	}
+	return controller.CloneStatus{Done: true}, nil
+}